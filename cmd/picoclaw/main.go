@@ -10,6 +10,7 @@ import (
 	"bufio"
 	"context"
 	"embed"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
@@ -24,20 +25,27 @@ import (
 	"github.com/chzyer/readline"
 	"github.com/sipeed/picoclaw/pkg/agent"
 	"github.com/sipeed/picoclaw/pkg/auth"
+	"github.com/sipeed/picoclaw/pkg/automation"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/costs"
 	"github.com/sipeed/picoclaw/pkg/cron"
 	"github.com/sipeed/picoclaw/pkg/devices"
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+	"github.com/sipeed/picoclaw/pkg/devices/sources"
 	"github.com/sipeed/picoclaw/pkg/health"
 	"github.com/sipeed/picoclaw/pkg/heartbeat"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/migrate"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/providers/ollama"
+	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/state"
 	"github.com/sipeed/picoclaw/pkg/tools"
-	"github.com/sipeed/picoclaw/pkg/voice"
+	"github.com/sipeed/picoclaw/pkg/utils"
 	"github.com/sipeed/picoclaw/pkg/webui"
 )
 
@@ -145,6 +153,14 @@ func main() {
 		authCmd()
 	case "cron":
 		cronCmd()
+	case "automation":
+		automationCmd()
+	case "models":
+		modelsCmd()
+	case "providers":
+		providersCmd()
+	case "tasks":
+		tasksCmd()
 	case "skills":
 		if len(os.Args) < 3 {
 			skillsHelp()
@@ -215,6 +231,10 @@ func printHelp() {
 	fmt.Println("  webui       Start web-based configuration UI")
 	fmt.Println("  status      Show picoclaw status")
 	fmt.Println("  cron        Manage scheduled tasks")
+	fmt.Println("  automation  Manage event-triggered automations")
+	fmt.Println("  models      Manage local Ollama models (pull, list, rm)")
+	fmt.Println("  providers   Show LLM provider health (status)")
+	fmt.Println("  tasks       Inspect subagent tasks (list, show)")
 	fmt.Println("  migrate     Migrate from OpenClaw to PicoClaw")
 	fmt.Println("  skills      Manage skills (install, list, remove)")
 	fmt.Println("  version     Show version information")
@@ -377,6 +397,9 @@ func migrateHelp() {
 func agentCmd() {
 	message := ""
 	sessionKey := "cli:default"
+	schemaPath := ""
+	recordPath := ""
+	replayPath := ""
 
 	args := os.Args[2:]
 	for i := 0; i < len(args); i++ {
@@ -394,19 +417,73 @@ func agentCmd() {
 				sessionKey = args[i+1]
 				i++
 			}
+		case "--schema":
+			if i+1 < len(args) {
+				schemaPath = args[i+1]
+				i++
+			}
+		case "--record":
+			if i+1 < len(args) {
+				recordPath = args[i+1]
+				i++
+			}
+		case "--replay":
+			if i+1 < len(args) {
+				replayPath = args[i+1]
+				i++
+			}
 		}
 	}
 
+	if (recordPath != "" || replayPath != "") && message == "" {
+		fmt.Println("Error: --record and --replay require -m")
+		os.Exit(1)
+	}
+	if recordPath != "" && replayPath != "" {
+		fmt.Println("Error: --record and --replay cannot be combined")
+		os.Exit(1)
+	}
+
+	var responseFormat *providers.ResponseFormat
+	if schemaPath != "" {
+		if message == "" {
+			fmt.Println("Error: --schema requires -m")
+			os.Exit(1)
+		}
+		rf, err := providers.LoadResponseFormat(schemaPath)
+		if err != nil {
+			fmt.Printf("Error loading schema: %v\n", err)
+			os.Exit(1)
+		}
+		responseFormat = rf
+	}
+
 	cfg, err := loadConfig()
 	if err != nil {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
 
-	provider, err := providers.CreateProvider(cfg)
-	if err != nil {
-		fmt.Printf("Error creating provider: %v\n", err)
-		os.Exit(1)
+	var provider providers.LLMProvider
+	var cassette *providers.Cassette
+	if replayPath != "" {
+		// Replay needs no credentials: every client resolves to the cassette.
+		cassette, err = providers.LoadCassette(replayPath, cassetteOptions(cfg))
+		if err != nil {
+			fmt.Printf("Error loading cassette: %v\n", err)
+			os.Exit(1)
+		}
+		provider = providers.NewReplayProvider(cassette, cfg.Agents.Defaults.Model)
+	} else {
+		provider, err = providers.CreateProvider(cfg)
+		if err != nil {
+			fmt.Printf("Error creating provider: %v\n", err)
+			os.Exit(1)
+		}
+		if recordPath != "" {
+			cassette = providers.NewCassette(recordPath, cassetteOptions(cfg))
+			provider = providers.NewRecordProvider(provider, cfg.Agents.Defaults.Provider, cassette)
+		}
 	}
 
 	msgBus := bus.NewMessageBus()
@@ -421,7 +498,34 @@ func agentCmd() {
 			"skills_available": startupInfo["skills"].(map[string]interface{})["available"],
 		})
 
-	if message != "" {
+	if responseFormat != nil || cassette != nil {
+		// Cassette runs start from an empty session so that the requests
+		// do not depend on earlier conversations.
+		response, err := agentLoop.ProcessDirectWithOptions(context.Background(), message, "cli", "direct", tools.DirectRunOptions{
+			SessionKey:     sessionKey,
+			NoHistory:      cassette != nil,
+			ResponseFormat: responseFormat,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if responseFormat != nil {
+			// Print only the validated JSON so scripts can parse stdout.
+			fmt.Println(response)
+		} else {
+			fmt.Printf("\n%s %s\n", logo, response)
+		}
+		if recordPath != "" {
+			fmt.Fprintf(os.Stderr, "Recorded %d LLM calls to %s\n", cassette.Len(), recordPath)
+		}
+		if replayPath != "" {
+			if n := cassette.Unused(); n > 0 {
+				fmt.Fprintf(os.Stderr, "Error: replay mismatch: %d recorded LLM calls were not made\n", n)
+				os.Exit(1)
+			}
+		}
+	} else if message != "" {
 		ctx := context.Background()
 		response, err := agentLoop.ProcessDirect(ctx, message, sessionKey)
 		if err != nil {
@@ -435,6 +539,43 @@ func agentCmd() {
 	}
 }
 
+// cassetteOptions returns the normalization used for --record and --replay:
+// configured credentials are redacted and local paths replaced, so cassettes
+// can be committed and replayed on another machine.
+func cassetteOptions(cfg *config.Config) providers.CassetteOptions {
+	opts := providers.CassetteOptions{Paths: map[string]string{
+		cfg.WorkspacePath(): "<workspace>",
+	}}
+	if home, err := os.UserHomeDir(); err == nil && home != "/" {
+		opts.Paths[home] = "<home>"
+	}
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, val := range v {
+				k = strings.ToLower(k)
+				if s, ok := val.(string); ok && s != "" &&
+					(strings.Contains(k, "key") || strings.Contains(k, "token") || strings.Contains(k, "secret")) {
+					opts.Secrets = append(opts.Secrets, s)
+					continue
+				}
+				walk(val)
+			}
+		case []interface{}:
+			for _, val := range v {
+				walk(val)
+			}
+		}
+	}
+	var raw interface{}
+	if data, err := json.Marshal(cfg); err == nil && json.Unmarshal(data, &raw) == nil {
+		walk(raw)
+	}
+	return opts
+}
+
 func interactiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 	prompt := fmt.Sprintf("%s You: ", logo)
 
@@ -568,6 +709,12 @@ func gatewayCmd() {
 	// Setup cron tool and service
 	execTimeout := time.Duration(cfg.Tools.Cron.ExecTimeoutMinutes) * time.Minute
 	cronService := setupCronTool(agentLoop, msgBus, cfg.WorkspacePath(), cfg.Agents.Defaults.RestrictToWorkspace, execTimeout, cfg)
+	automationService := setupAutomationTool(agentLoop, msgBus, cfg.WorkspacePath(), cfg.Agents.Defaults.RestrictToWorkspace, execTimeout, cfg)
+	msgBus.ObserveInbound(func(msg bus.InboundMessage) {
+		if !constants.IsInternalChannel(msg.Channel) {
+			automationService.Dispatch(automation.MessageEvent(msg))
+		}
+	})
 
 	heartbeatService := heartbeat.NewHeartbeatService(
 		cfg.WorkspacePath(),
@@ -575,22 +722,43 @@ func gatewayCmd() {
 		cfg.Heartbeat.Enabled,
 	)
 	heartbeatService.SetBus(msgBus)
-	heartbeatService.SetHandler(func(prompt, channel, chatID string) *tools.ToolResult {
+	heartbeatService.SetDedupWindow(time.Duration(cfg.Heartbeat.DedupMinutes) * time.Minute)
+	if cfg.Heartbeat.QuietHours != "" {
+		quiet, err := heartbeat.ParseQuietHours(cfg.Heartbeat.QuietHours)
+		if err != nil {
+			fmt.Printf("Warning: ignoring heartbeat quiet_hours: %v\n", err)
+		} else {
+			heartbeatService.SetQuietHours(quiet)
+		}
+	}
+	heartbeatService.SetTaskHandler(func(task *heartbeat.Task, prompt, channel, chatID string) *tools.ToolResult {
 		// Use cli:direct as fallback if no valid channel
 		if channel == "" || chatID == "" {
 			channel, chatID = "cli", "direct"
 		}
-		// Use ProcessHeartbeat - no session history, each heartbeat is independent
-		response, err := agentLoop.ProcessHeartbeat(context.Background(), prompt, channel, chatID)
+		var response string
+		var err error
+		if task.Agent == "" {
+			// Use ProcessHeartbeat - no session history, each heartbeat is independent
+			response, err = agentLoop.ProcessHeartbeat(context.Background(), prompt, channel, chatID)
+		} else {
+			response, err = agentLoop.ProcessDirectWithOptions(context.Background(), prompt, channel, chatID, tools.DirectRunOptions{
+				AgentID:    task.Agent,
+				SessionKey: "heartbeat:" + task.Name,
+				NoHistory:  true,
+			})
+		}
+		// Errors of the heartbeat run itself are logged and recorded in the
+		// task history, never delivered as alerts.
 		if err != nil {
 			return tools.ErrorResult(fmt.Sprintf("Heartbeat error: %v", err))
 		}
-		if response == "HEARTBEAT_OK" {
+		if heartbeat.IsOK(response) {
 			return tools.SilentResult("Heartbeat OK")
 		}
-		// For heartbeat, always return silent - the subagent result will be
-		// sent to user via processSystemMessage when the async task completes
-		return tools.SilentResult(response)
+		// Anything else is an alert; the heartbeat service delivers it to the
+		// task's target unless the same alert was sent recently.
+		return tools.UserResult(response)
 	})
 
 	channelManager, err := channels.NewManager(cfg, msgBus)
@@ -602,39 +770,6 @@ func gatewayCmd() {
 	// Inject channel manager into agent loop for command handling
 	agentLoop.SetChannelManager(channelManager)
 
-	var transcriber *voice.GroqTranscriber
-	if cfg.Providers.Groq.APIKey != "" {
-		transcriber = voice.NewGroqTranscriber(cfg.Providers.Groq.APIKey)
-		logger.InfoC("voice", "Groq voice transcription enabled")
-	}
-
-	if transcriber != nil {
-		if telegramChannel, ok := channelManager.GetChannel("telegram"); ok {
-			if tc, ok := telegramChannel.(*channels.TelegramChannel); ok {
-				tc.SetTranscriber(transcriber)
-				logger.InfoC("voice", "Groq transcription attached to Telegram channel")
-			}
-		}
-		if discordChannel, ok := channelManager.GetChannel("discord"); ok {
-			if dc, ok := discordChannel.(*channels.DiscordChannel); ok {
-				dc.SetTranscriber(transcriber)
-				logger.InfoC("voice", "Groq transcription attached to Discord channel")
-			}
-		}
-		if slackChannel, ok := channelManager.GetChannel("slack"); ok {
-			if sc, ok := slackChannel.(*channels.SlackChannel); ok {
-				sc.SetTranscriber(transcriber)
-				logger.InfoC("voice", "Groq transcription attached to Slack channel")
-			}
-		}
-		if onebotChannel, ok := channelManager.GetChannel("onebot"); ok {
-			if oc, ok := onebotChannel.(*channels.OneBotChannel); ok {
-				oc.SetTranscriber(transcriber)
-				logger.InfoC("voice", "Groq transcription attached to OneBot channel")
-			}
-		}
-	}
-
 	enabledChannels := channelManager.GetEnabledChannels()
 	if len(enabledChannels) > 0 {
 		fmt.Printf("✓ Channels enabled: %s\n", enabledChannels)
@@ -659,17 +794,27 @@ func gatewayCmd() {
 	fmt.Println("✓ Heartbeat service started")
 
 	stateManager := state.NewManager(cfg.WorkspacePath())
-	deviceService := devices.NewService(devices.Config{
-		Enabled:    cfg.Devices.Enabled,
-		MonitorUSB: cfg.Devices.MonitorUSB,
-	}, stateManager)
+	deviceService := devices.NewService(deviceConfig(cfg.Devices), stateManager)
 	deviceService.SetBus(msgBus)
+	deviceService.AddListener(func(ev *events.DeviceEvent) {
+		automationService.Dispatch(automation.DeviceEvent(ev))
+	})
 	if err := deviceService.Start(ctx); err != nil {
 		fmt.Printf("Error starting device service: %v\n", err)
 	} else if cfg.Devices.Enabled {
 		fmt.Println("✓ Device event service started")
 	}
 
+	automationService.SetDefaultTarget(func() (string, string) {
+		channel, chatID, _ := strings.Cut(stateManager.GetLastChannel(), ":")
+		return channel, chatID
+	})
+	if err := automationService.Start(); err != nil {
+		fmt.Printf("Error starting automation service: %v\n", err)
+	} else {
+		fmt.Println("✓ Automation service started")
+	}
+
 	if err := channelManager.StartAll(ctx); err != nil {
 		fmt.Printf("Error starting channels: %v\n", err)
 	}
@@ -682,6 +827,21 @@ func gatewayCmd() {
 	}()
 	fmt.Printf("✓ Health endpoints available at http://%s:%d/health and /ready\n", cfg.Gateway.Host, cfg.Gateway.Port)
 
+	if probeCfg := cfg.Gateway.ProviderProbe; probeCfg.Enabled {
+		prober := agentLoop.NewProviderProber(time.Duration(probeCfg.TimeoutSeconds) * time.Second)
+		reportPath := providerReportPath(cfg)
+		prober.OnUpdate(func(statuses []providers.ProbeStatus) {
+			healthServer.RegisterCheck("providers", func() (bool, string) {
+				return providers.ProbeReadiness(statuses)
+			})
+			if err := providers.SaveProbeReport(reportPath, statuses); err != nil {
+				logger.WarnCF("providers", "Failed to save provider status", map[string]interface{}{"error": err.Error()})
+			}
+		})
+		go prober.Run(ctx, providerProbeInterval(cfg))
+		fmt.Println("✓ Provider health probes started")
+	}
+
 	go agentLoop.Run(ctx)
 
 	sigChan := make(chan os.Signal, 1)
@@ -692,6 +852,7 @@ func gatewayCmd() {
 	cancel()
 	healthServer.Stop(context.Background())
 	deviceService.Stop()
+	automationService.Stop()
 	heartbeatService.Stop()
 	cronService.Stop()
 	agentLoop.Stop()
@@ -699,6 +860,56 @@ func gatewayCmd() {
 	fmt.Println("✓ Gateway stopped")
 }
 
+// deviceConfig maps the devices section of the config to the device
+// service's sources and routing.
+func deviceConfig(dc config.DevicesConfig) devices.Config {
+	lines := make([]sources.GPIOLine, 0, len(dc.GPIO))
+	for _, g := range dc.GPIO {
+		lines = append(lines, sources.GPIOLine{
+			Chip:       g.Chip,
+			Offset:     g.Line,
+			Name:       g.Name,
+			Edge:       g.Edge,
+			Bias:       g.Bias,
+			DebounceMs: g.DebounceMs,
+		})
+	}
+	rules := make([]devices.Rule, 0, len(dc.Rules))
+	for i, r := range dc.Rules {
+		handling, ok := devices.ParseHandling(r.Handling)
+		if !ok {
+			fmt.Printf("Warning: devices.rules[%d]: unknown handling %q, rule skipped\n", i, r.Handling)
+			continue
+		}
+		rules = append(rules, devices.Rule{
+			Kind:     r.Kind,
+			Action:   r.Action,
+			Vendor:   r.Vendor,
+			Product:  r.Product,
+			DeviceID: r.Device,
+			Handle:   handling,
+			Prompt:   r.Prompt,
+		})
+	}
+	handling, ok := devices.ParseHandling(dc.Handling)
+	if !ok && dc.Handling != "" {
+		fmt.Printf("Warning: devices.handling: unknown handling %q, using notify\n", dc.Handling)
+	}
+	return devices.Config{
+		Enabled:          dc.Enabled,
+		MonitorUSB:       dc.MonitorUSB,
+		MonitorBlock:     dc.MonitorBlock,
+		MonitorNetwork:   dc.MonitorNetwork,
+		MonitorBluetooth: dc.MonitorBluetooth,
+		GPIO:             lines,
+		Agent:            dc.Agent,
+		Target:           dc.Target,
+		DefaultHandling:  handling,
+		Rules:            rules,
+		Debounce:         time.Duration(dc.DebounceSeconds) * time.Second,
+	}
+}
+
 func statusCmd() {
 	cfg, err := loadConfig()
 	if err != nil {
@@ -771,6 +982,65 @@ func statusCmd() {
 				fmt.Printf("  %s (%s): %s\n", provider, cred.AuthMethod, status)
 			}
 		}
+
+		printHeartbeatStatus(cfg)
+		printCostStatus(cfg)
+	}
+}
+
+func printHeartbeatStatus(cfg *config.Config) {
+	if !cfg.Heartbeat.Enabled {
+		fmt.Println("\nHeartbeat: disabled")
+		return
+	}
+	fmt.Printf("\nHeartbeat: every %d min\n", cfg.Heartbeat.Interval)
+
+	history, err := heartbeat.LoadHistory(cfg.WorkspacePath(), 10)
+	if err != nil {
+		fmt.Printf("  Error loading history: %v\n", err)
+		return
+	}
+	if len(history) == 0 {
+		fmt.Println("  No heartbeat runs recorded yet.")
+		return
+	}
+	for _, o := range history {
+		line := fmt.Sprintf("  %s  %-20s %-9s", time.UnixMilli(o.AtMS).Format("2006-01-02 15:04"), o.Task, o.Status)
+		if o.Channel != "" {
+			line += " → " + o.Channel
+		}
+		if o.Summary != "" {
+			line += "  " + utils.Truncate(strings.ReplaceAll(o.Summary, "\n", " "), 60)
+		}
+		fmt.Println(line)
+	}
+}
+
+func printCostStatus(cfg *config.Config) {
+	now := time.Now()
+	from, to := costs.MonthToDate(now)
+	month, err := costs.LoadReport(cfg.WorkspacePath(), from, to)
+	if err != nil {
+		fmt.Printf("\nLLM costs: error loading ledger: %v\n", err)
+		return
+	}
+	from, to = costs.Today(now)
+	today, _ := costs.LoadReport(cfg.WorkspacePath(), from, to)
+
+	fmt.Println("\nLLM costs:")
+	budget := func(limit float64) string {
+		if limit <= 0 {
+			return ""
+		}
+		return fmt.Sprintf(" of $%.2f budget", limit)
+	}
+	fmt.Printf("  Today: $%.4f (%d calls)%s\n", today.Total.CostUSD, today.Total.Calls, budget(cfg.Costs.DailyBudget))
+	fmt.Printf("  Month: $%.4f (%d calls)%s\n", month.Total.CostUSD, month.Total.Calls, budget(cfg.Costs.MonthlyBudget))
+	for i, line := range month.ByModel {
+		if i >= 5 {
+			break
+		}
+		fmt.Printf("    %-30s $%.4f (%d calls)\n", line.Key, line.CostUSD, line.Calls)
 	}
 }
 
@@ -1003,20 +1273,36 @@ func setupCronTool(agentLoop *agent.AgentLoop, msgBus *bus.MessageBus, workspace
 
 	// Create cron service
 	cronService := cron.NewCronService(cronStorePath, nil)
+	cronService.SetDefaultMisfirePolicy(config.Tools.Cron.MisfirePolicy)
+	cronService.SetHistoryLimit(config.Tools.Cron.HistoryLimit)
 
 	// Create and register CronTool
 	cronTool := tools.NewCronTool(cronService, agentLoop, msgBus, workspace, restrict, execTimeout, config)
 	agentLoop.RegisterTool(cronTool)
 
 	// Set the onJob handler
-	cronService.SetOnJob(func(job *cron.CronJob) (string, error) {
-		result := cronTool.ExecuteJob(context.Background(), job)
-		return result, nil
+	cronService.SetOnJob(func(ctx context.Context, job *cron.CronJob) (string, error) {
+		return cronTool.ExecuteJob(ctx, job)
 	})
 
 	return cronService
 }
 
+func setupAutomationTool(agentLoop *agent.AgentLoop, msgBus *bus.MessageBus, workspace string, restrict bool, execTimeout time.Duration, config *config.Config) *automation.Service {
+	storePath := filepath.Join(workspace, "cron", "automations.json")
+
+	automationService := automation.NewService(storePath, workspace, nil)
+
+	automationTool := tools.NewAutomationTool(automationService, agentLoop, msgBus, workspace, restrict, execTimeout, config)
+	agentLoop.RegisterTool(automationTool)
+
+	automationService.SetHandler(func(ctx context.Context, rule *automation.Rule, ev automation.Event) (string, error) {
+		return automationTool.ExecuteRule(ctx, rule, ev)
+	})
+
+	return automationService
+}
+
 func loadConfig() (*config.Config, error) {
 	return config.LoadConfig(getConfigPath())
 }
@@ -1042,7 +1328,7 @@ func cronCmd() {
 	case "list":
 		cronListCmd(cronStorePath)
 	case "add":
-		cronAddCmd(cronStorePath)
+		cronAddCmd(cfg, cronStorePath)
 	case "remove":
 		if len(os.Args) < 4 {
 			fmt.Println("Usage: picoclaw cron remove <job_id>")
@@ -1053,6 +1339,12 @@ func cronCmd() {
 		cronEnableCmd(cronStorePath, false)
 	case "disable":
 		cronEnableCmd(cronStorePath, true)
+	case "history":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: picoclaw cron history <job_id> [-n N]")
+			return
+		}
+		cronHistoryCmd(cronStorePath, os.Args[3])
 	default:
 		fmt.Printf("Unknown cron command: %s\n", subcommand)
 		cronHelp()
@@ -1066,6 +1358,7 @@ func cronHelp() {
 	fmt.Println("  remove <id>       Remove a job by ID")
 	fmt.Println("  enable <id>      Enable a job")
 	fmt.Println("  disable <id>     Disable a job")
+	fmt.Println("  history <id>     Show recent runs of a job (-n N to limit)")
 	fmt.Println()
 	fmt.Println("Add options:")
 	fmt.Println("  -n, --name       Job name")
@@ -1075,6 +1368,15 @@ func cronHelp() {
 	fmt.Println("  -d, --deliver     Deliver response to channel")
 	fmt.Println("  --to             Recipient for delivery")
 	fmt.Println("  --channel        Channel for delivery")
+	fmt.Println("  --retries        Retry a failed run up to N times")
+	fmt.Println("  --retry-backoff  Seconds before the first retry (doubles each time)")
+	fmt.Println("  --timeout        Abort a run after N seconds")
+	fmt.Println("  --misfire        Missed runs policy: skip, run_once, run_all")
+	fmt.Println("  --agent          Agent ID (from agents.list) that handles the job")
+	fmt.Println("  --session        Session mode: fresh, job, chat (default: job)")
+	fmt.Println("  --model          Model override for this job")
+	fmt.Println("  --max-iterations Max tool iterations per run")
+	fmt.Println("  --schema         JSON Schema file the reply must match")
 }
 
 func cronListCmd(storePath string) {
@@ -1113,10 +1415,16 @@ func cronListCmd(storePath string) {
 		fmt.Printf("    Schedule: %s\n", schedule)
 		fmt.Printf("    Status: %s\n", status)
 		fmt.Printf("    Next run: %s\n", nextRun)
+		if job.Payload.Agent != "" {
+			fmt.Printf("    Agent: %s\n", job.Payload.Agent)
+		}
+		if job.Payload.Session != "" {
+			fmt.Printf("    Session: %s\n", job.Payload.Session)
+		}
 	}
 }
 
-func cronAddCmd(storePath string) {
+func cronAddCmd(cfg *config.Config, storePath string) {
 	name := ""
 	message := ""
 	var everySec *int64
@@ -1124,6 +1432,15 @@ func cronAddCmd(storePath string) {
 	deliver := false
 	channel := ""
 	to := ""
+	retries := 0
+	var retryBackoff int64
+	var timeout int64
+	misfire := ""
+	agentID := ""
+	sessionMode := ""
+	model := ""
+	maxIterations := 0
+	schemaPath := ""
 
 	args := os.Args[3:]
 	for i := 0; i < len(args); i++ {
@@ -1162,7 +1479,76 @@ func cronAddCmd(storePath string) {
 				channel = args[i+1]
 				i++
 			}
+		case "--retries":
+			if i+1 < len(args) {
+				fmt.Sscanf(args[i+1], "%d", &retries)
+				i++
+			}
+		case "--retry-backoff":
+			if i+1 < len(args) {
+				fmt.Sscanf(args[i+1], "%d", &retryBackoff)
+				i++
+			}
+		case "--timeout":
+			if i+1 < len(args) {
+				fmt.Sscanf(args[i+1], "%d", &timeout)
+				i++
+			}
+		case "--misfire":
+			if i+1 < len(args) {
+				misfire = args[i+1]
+				i++
+			}
+		case "--agent":
+			if i+1 < len(args) {
+				agentID = args[i+1]
+				i++
+			}
+		case "--session":
+			if i+1 < len(args) {
+				sessionMode = args[i+1]
+				i++
+			}
+		case "--model":
+			if i+1 < len(args) {
+				model = args[i+1]
+				i++
+			}
+		case "--max-iterations":
+			if i+1 < len(args) {
+				fmt.Sscanf(args[i+1], "%d", &maxIterations)
+				i++
+			}
+		case "--schema":
+			if i+1 < len(args) {
+				schemaPath = args[i+1]
+				i++
+			}
+		}
+	}
+
+	if !cron.ValidSessionMode(sessionMode) {
+		fmt.Println("Error: --session must be one of fresh, job, chat")
+		return
+	}
+
+	if agentID != "" && !agentExists(cfg, agentID) {
+		fmt.Printf("Error: agent '%s' not found in agents.list\n", agentID)
+		return
+	}
+
+	if !cron.ValidMisfirePolicy(misfire) {
+		fmt.Println("Error: --misfire must be one of skip, run_once, run_all")
+		return
+	}
+
+	var schema json.RawMessage
+	if schemaPath != "" {
+		if _, err := providers.LoadResponseFormat(schemaPath); err != nil {
+			fmt.Printf("Error loading schema: %v\n", err)
+			return
 		}
+		schema, _ = os.ReadFile(schemaPath)
 	}
 
 	if name == "" {
@@ -1201,9 +1587,78 @@ func cronAddCmd(storePath string) {
 		return
 	}
 
+	if retries > 0 || timeout > 0 || misfire != "" || agentID != "" || sessionMode != "" || model != "" || maxIterations > 0 || schema != nil {
+		if retries > 0 {
+			job.Retry = &cron.CronRetryPolicy{MaxRetries: retries, BackoffMS: retryBackoff * 1000}
+		}
+		job.TimeoutMS = timeout * 1000
+		job.Misfire = misfire
+		job.Payload.Agent = agentID
+		job.Payload.Session = sessionMode
+		job.Payload.Model = model
+		job.Payload.MaxIterations = maxIterations
+		job.Payload.Schema = schema
+		if err := cs.UpdateJob(job); err != nil {
+			fmt.Printf("Error updating job: %v\n", err)
+			return
+		}
+	}
+
 	fmt.Printf("✓ Added job '%s' (%s)\n", job.Name, job.ID)
 }
 
+// agentExists reports whether agentID names an agent in agents.list.
+// Without a list only the implicit "main" agent exists.
+func agentExists(cfg *config.Config, agentID string) bool {
+	id := routing.NormalizeAgentID(agentID)
+	if len(cfg.Agents.List) == 0 {
+		return id == routing.DefaultAgentID
+	}
+	for _, a := range cfg.Agents.List {
+		if routing.NormalizeAgentID(a.ID) == id {
+			return true
+		}
+	}
+	return false
+}
+
+func cronHistoryCmd(storePath, jobID string) {
+	limit := 0
+	args := os.Args[4:]
+	for i := 0; i < len(args); i++ {
+		if (args[i] == "-n" || args[i] == "--limit") && i+1 < len(args) {
+			fmt.Sscanf(args[i+1], "%d", &limit)
+			i++
+		}
+	}
+
+	cs := cron.NewCronService(storePath, nil)
+	job, ok := cs.GetJob(jobID)
+	if !ok {
+		fmt.Printf("✗ Job %s not found\n", jobID)
+		return
+	}
+
+	runs := cs.GetHistory(jobID, limit)
+	if len(runs) == 0 {
+		fmt.Printf("No runs recorded for '%s' yet.\n", job.Name)
+		return
+	}
+
+	fmt.Printf("\nRun history for %s (%s):\n", job.Name, job.ID)
+	fmt.Println("----------------")
+	for _, r := range runs {
+		started := time.UnixMilli(r.StartedAtMS).Format("2006-01-02 15:04:05")
+		fmt.Printf("  %s  %-7s  attempt %d  %dms\n", started, r.Status, r.Attempt, r.DurationMS)
+		if r.Error != "" {
+			fmt.Printf("    Error: %s\n", r.Error)
+		}
+		if r.Output != "" {
+			fmt.Printf("    Output: %s\n", utils.Truncate(r.Output, 120))
+		}
+	}
+}
+
 func cronRemoveCmd(storePath, jobID string) {
 	cs := cron.NewCronService(storePath, nil)
 	if cs.RemoveJob(jobID) {
@@ -1235,6 +1690,444 @@ func cronEnableCmd(storePath string, disable bool) {
 	}
 }
 
+func automationCmd() {
+	if len(os.Args) < 3 {
+		automationHelp()
+		return
+	}
+
+	subcommand := os.Args[2]
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+
+	workspace := cfg.WorkspacePath()
+	storePath := filepath.Join(workspace, "cron", "automations.json")
+
+	switch subcommand {
+	case "list":
+		automationListCmd(storePath, workspace)
+	case "add":
+		automationAddCmd(cfg, storePath, workspace)
+	case "remove":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: picoclaw automation remove <rule_id>")
+			return
+		}
+		svc := automation.NewService(storePath, workspace, nil)
+		if svc.RemoveRule(os.Args[3]) {
+			fmt.Printf("✓ Removed rule %s\n", os.Args[3])
+		} else {
+			fmt.Printf("✗ Rule %s not found\n", os.Args[3])
+		}
+	case "enable", "disable":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: picoclaw automation enable/disable <rule_id>")
+			return
+		}
+		svc := automation.NewService(storePath, workspace, nil)
+		if rule := svc.EnableRule(os.Args[3], subcommand == "enable"); rule != nil {
+			fmt.Printf("✓ Rule '%s' %sd\n", rule.Name, subcommand)
+		} else {
+			fmt.Printf("✗ Rule %s not found\n", os.Args[3])
+		}
+	default:
+		fmt.Printf("Unknown automation command: %s\n", subcommand)
+		automationHelp()
+	}
+}
+
+func modelsCmd() {
+	if len(os.Args) < 3 {
+		modelsHelp()
+		return
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+
+	oc := cfg.Providers.Ollama
+	client := ollama.NewProvider(oc.APIBase, oc.APIKey, oc.Proxy, ollama.Options{NumCtx: oc.NumCtx})
+	ctx := context.Background()
+
+	switch os.Args[2] {
+	case "list", "ls":
+		models, err := client.ListModels(ctx)
+		if err != nil {
+			fmt.Printf("Error listing models: %v\n", err)
+			return
+		}
+		if len(models) == 0 {
+			fmt.Println("No models installed. Pull one with: picoclaw models pull <name>")
+			return
+		}
+		fmt.Println("\nInstalled Models:")
+		fmt.Println("-----------------")
+		for _, m := range models {
+			fmt.Printf("  %-32s %8s  %-8s %-8s %s\n", m.Name, formatBytes(m.Size),
+				m.Details.ParameterSize, m.Details.QuantizationLevel, m.ModifiedAt.Format("2006-01-02"))
+		}
+	case "pull":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: picoclaw models pull <name>")
+			return
+		}
+		name := os.Args[3]
+		lastStatus := ""
+		err := client.PullModel(ctx, name, func(p ollama.PullProgress) {
+			if p.Total > 0 {
+				fmt.Printf("\r  %s %3d%% (%s / %s)   ", p.Status, p.Completed*100/p.Total,
+					formatBytes(p.Completed), formatBytes(p.Total))
+				lastStatus = ""
+				return
+			}
+			if p.Status != lastStatus {
+				fmt.Printf("\n  %s", p.Status)
+				lastStatus = p.Status
+			}
+		})
+		fmt.Println()
+		if err != nil {
+			fmt.Printf("✗ Error pulling %s: %v\n", name, err)
+			return
+		}
+		fmt.Printf("✓ Pulled %s\n", name)
+	case "rm", "remove":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: picoclaw models rm <name>")
+			return
+		}
+		if err := client.DeleteModel(ctx, os.Args[3]); err != nil {
+			fmt.Printf("✗ Error removing %s: %v\n", os.Args[3], err)
+			return
+		}
+		fmt.Printf("✓ Removed %s\n", os.Args[3])
+	default:
+		fmt.Printf("Unknown models command: %s\n", os.Args[2])
+		modelsHelp()
+	}
+}
+
+func providerReportPath(cfg *config.Config) string {
+	return filepath.Join(cfg.WorkspacePath(), "state", "providers.json")
+}
+
+func providerProbeInterval(cfg *config.Config) time.Duration {
+	if s := cfg.Gateway.ProviderProbe.IntervalSeconds; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return 5 * time.Minute
+}
+
+func providersCmd() {
+	if len(os.Args) < 3 || os.Args[2] != "status" {
+		providersHelp()
+		return
+	}
+	probe := len(os.Args) > 3 && os.Args[3] == "--probe"
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+
+	// Use the gateway's results while they are current, else probe now.
+	report, err := providers.LoadProbeReport(providerReportPath(cfg))
+	if probe || err != nil || time.Since(report.Updated) > 2*providerProbeInterval(cfg) {
+		provider, err := providers.CreateProvider(cfg)
+		if err != nil {
+			fmt.Printf("Error creating provider: %v\n", err)
+			return
+		}
+		agentLoop := agent.NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+		prober := agentLoop.NewProviderProber(time.Duration(cfg.Gateway.ProviderProbe.TimeoutSeconds) * time.Second)
+		fmt.Println("Probing providers...")
+		report = &providers.ProbeReport{Updated: time.Now(), Statuses: prober.ProbeAll(context.Background())}
+	} else {
+		fmt.Printf("From the gateway, updated %s ago (--probe to check now)\n", time.Since(report.Updated).Round(time.Second))
+	}
+
+	if len(report.Statuses) == 0 {
+		fmt.Println("No providers configured.")
+		return
+	}
+	fmt.Printf("\n  %-40s %-10s %9s %9s %7s %9s\n", "PROVIDER/MODEL", "STATE", "LATENCY", "AVG", "ERRORS", "COOLDOWN")
+	for _, st := range report.Statuses {
+		cooldown := "-"
+		if st.CooldownSeconds > 0 {
+			cooldown = (time.Duration(st.CooldownSeconds) * time.Second).String()
+		}
+		fmt.Printf("  %-40s %-10s %7dms %7dms %6.0f%% %9s\n", st.Provider+"/"+st.Model, st.State,
+			st.LastLatencyMs, st.AvgLatencyMs, st.ErrorRate*100, cooldown)
+		if st.LastError != "" {
+			fmt.Printf("    last error: %s\n", utils.Truncate(strings.Join(strings.Fields(st.LastError), " "), 120))
+		}
+	}
+}
+
+func providersHelp() {
+	fmt.Println("\nProviders commands:")
+	fmt.Println("  status            Show circuit state, probe latency, error rate and cooldown")
+	fmt.Println("  status --probe    Probe every configured provider/model now")
+}
+
+func tasksCmd() {
+	subcommand := "list"
+	if len(os.Args) > 2 {
+		subcommand = os.Args[2]
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+	store := tools.NewSubagentStore(cfg.WorkspacePath())
+
+	switch subcommand {
+	case "list":
+		tasks, err := store.List()
+		if err != nil {
+			fmt.Printf("Error listing tasks: %v\n", err)
+			return
+		}
+		fmt.Println(tools.FormatSubagentTasks(tasks, 50))
+	case "show":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: picoclaw tasks show <id> [--transcript]")
+			return
+		}
+		task, err := store.Get(os.Args[3])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		transcript := len(os.Args) > 4 && os.Args[4] == "--transcript"
+		fmt.Println(tools.FormatSubagentTask(task, transcript))
+	default:
+		fmt.Printf("Unknown tasks command: %s\n", subcommand)
+		tasksHelp()
+	}
+}
+
+func tasksHelp() {
+	fmt.Println("\nTasks commands:")
+	fmt.Println("  list                      List subagent tasks, newest first")
+	fmt.Println("  show <id> [--transcript]  Show a task's status, usage and result")
+	fmt.Println()
+	fmt.Println("Running tasks are cancelled from chat with /cancel <id>.")
+}
+
+func modelsHelp() {
+	fmt.Println("\nModels commands (Ollama, uses providers.ollama.api_base):")
+	fmt.Println("  list              List installed models")
+	fmt.Println("  pull <name>       Download a model, e.g. qwen2.5:3b")
+	fmt.Println("  rm <name>         Remove an installed model")
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func automationHelp() {
+	fmt.Println("\nAutomation commands:")
+	fmt.Println("  list              List all rules")
+	fmt.Println("  add               Add a new rule")
+	fmt.Println("  remove <id>       Remove a rule by ID")
+	fmt.Println("  enable <id>       Enable a rule")
+	fmt.Println("  disable <id>      Disable a rule")
+	fmt.Println()
+	fmt.Println("Trigger options:")
+	fmt.Println("  -t, --trigger     time, device, person, file or message")
+	fmt.Println("  -e, --every       time: run every N seconds")
+	fmt.Println("  -c, --cron        time: cron expression")
+	fmt.Println("  --device          device: device type (e.g. usb)")
+	fmt.Println("  --on              device: add/remove; file: created/modified/deleted")
+	fmt.Println("  --match           device: vendor/product text; message: regexp")
+	fmt.Println("  --path            file: file, directory or glob in the workspace")
+	fmt.Println("  --from            message: only messages from this channel")
+	fmt.Println("  --min-score       person: minimum confidence (0-1)")
+	fmt.Println()
+	fmt.Println("Condition options:")
+	fmt.Println("  --window          Time of day window, e.g. 22:00-07:00")
+	fmt.Println("  --days            Days for the window, e.g. mon,tue,wed")
+	fmt.Println("  --rate            Rate limit as max/seconds, e.g. 3/3600")
+	fmt.Println()
+	fmt.Println("Action options:")
+	fmt.Println("  -n, --name        Rule name")
+	fmt.Println("  --do              agent, message or command")
+	fmt.Println("  -m, --message     Agent prompt or message text ({{event}} = what happened)")
+	fmt.Println("  --command         Shell command")
+	fmt.Println("  --agent           Agent ID for agent actions")
+	fmt.Println("  --channel         Channel for results (default: last active channel)")
+	fmt.Println("  --to              Chat ID for results")
+	fmt.Println()
+	fmt.Println("Example:")
+	fmt.Println("  picoclaw automation add -n door -t person --min-score 0.8 --window 22:00-07:00 --rate 1/600 --do message -m \"{{event}}\"")
+}
+
+func automationListCmd(storePath, workspace string) {
+	svc := automation.NewService(storePath, workspace, nil)
+	rules := svc.ListRules(true)
+
+	if len(rules) == 0 {
+		fmt.Println("No automation rules.")
+		return
+	}
+
+	fmt.Println("\nAutomation Rules:")
+	fmt.Println("-----------------")
+	for _, rule := range rules {
+		status := "enabled"
+		if !rule.Enabled {
+			status = "disabled"
+		}
+
+		fmt.Printf("  %s (%s)\n", rule.Name, rule.ID)
+		fmt.Printf("    Trigger: %s\n", rule.Trigger.Describe())
+		if w := rule.Conditions.TimeWindow; w != nil {
+			window := w.Start + "-" + w.End
+			if len(w.Days) > 0 {
+				window += " " + strings.Join(w.Days, ",")
+			}
+			fmt.Printf("    Window: %s\n", window)
+		}
+		if rl := rule.Conditions.RateLimit; rl != nil {
+			fmt.Printf("    Rate limit: %d per %ds\n", rl.Max, rl.PeriodSec)
+		}
+		fmt.Printf("    Action: %s\n", rule.Action.Kind)
+		fmt.Printf("    Status: %s (fired %d times)\n", status, rule.State.FireCount)
+		if rule.State.LastError != "" {
+			fmt.Printf("    Last error: %s\n", rule.State.LastError)
+		}
+	}
+}
+
+func automationAddCmd(cfg *config.Config, storePath, workspace string) {
+	var rule automation.Rule
+	var everySec int64
+	cronExpr := ""
+	window := ""
+	days := ""
+	rate := ""
+
+	args := os.Args[3:]
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			break
+		}
+		value := args[i+1]
+		switch args[i] {
+		case "-n", "--name":
+			rule.Name = value
+		case "-t", "--trigger":
+			rule.Trigger.Kind = value
+		case "-e", "--every":
+			fmt.Sscanf(value, "%d", &everySec)
+		case "-c", "--cron":
+			cronExpr = value
+		case "--device":
+			rule.Trigger.Device = value
+		case "--on":
+			rule.Trigger.On = value
+		case "--match":
+			rule.Trigger.Match = value
+		case "--path":
+			rule.Trigger.Path = value
+		case "--from":
+			rule.Trigger.Channel = value
+		case "--min-score":
+			fmt.Sscanf(value, "%f", &rule.Trigger.MinScore)
+		case "--window":
+			window = value
+		case "--days":
+			days = value
+		case "--rate":
+			rate = value
+		case "--do":
+			rule.Action.Kind = value
+		case "-m", "--message":
+			rule.Action.Message = value
+		case "--command":
+			rule.Action.Command = value
+		case "--agent":
+			rule.Action.Agent = value
+		case "--channel":
+			rule.Action.Channel = value
+		case "--to":
+			rule.Action.To = value
+		default:
+			continue
+		}
+		i++
+	}
+
+	if rule.Name == "" {
+		fmt.Println("Error: --name is required")
+		return
+	}
+
+	if rule.Trigger.Kind == automation.TriggerTime {
+		if everySec > 0 {
+			everyMS := everySec * 1000
+			rule.Trigger.Schedule = &cron.CronSchedule{Kind: "every", EveryMS: &everyMS}
+		} else if cronExpr != "" {
+			rule.Trigger.Schedule = &cron.CronSchedule{Kind: "cron", Expr: cronExpr}
+		}
+	}
+
+	if window != "" {
+		start, end, ok := strings.Cut(window, "-")
+		if !ok {
+			fmt.Println("Error: --window must look like 09:00-18:00")
+			return
+		}
+		rule.Conditions.TimeWindow = &automation.TimeWindow{Start: start, End: end}
+		if days != "" {
+			rule.Conditions.TimeWindow.Days = strings.Split(days, ",")
+		}
+	}
+
+	if rate != "" {
+		rl := &automation.RateLimit{}
+		if _, err := fmt.Sscanf(rate, "%d/%d", &rl.Max, &rl.PeriodSec); err != nil {
+			fmt.Println("Error: --rate must look like 3/3600 (max/seconds)")
+			return
+		}
+		rule.Conditions.RateLimit = rl
+	}
+
+	if rule.Action.Agent != "" && !agentExists(cfg, rule.Action.Agent) {
+		fmt.Printf("Error: agent '%s' not found in agents.list\n", rule.Action.Agent)
+		return
+	}
+
+	svc := automation.NewService(storePath, workspace, nil)
+	added, err := svc.AddRule(rule)
+	if err != nil {
+		fmt.Printf("Error adding rule: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✓ Added rule '%s' (%s) on %s\n", added.Name, added.ID, added.Trigger.Describe())
+}
+
 func skillsHelp() {
 	fmt.Println("\nSkills commands:")
 	fmt.Println("  list                    List installed skills")
@@ -43,13 +43,18 @@ func NewContextBuilder(workspace string) *ContextBuilder {
 	}
 }
 
+// SetSkillsFilter limits the skills in the system prompt to the given
+// names. An empty filter includes every skill.
+func (cb *ContextBuilder) SetSkillsFilter(names []string) {
+	cb.skillsLoader.SetFilter(names)
+}
+
 // SetToolsRegistry sets the tools registry for dynamic tool summary generation.
 func (cb *ContextBuilder) SetToolsRegistry(registry *tools.ToolRegistry) {
 	cb.tools = registry
 }
 
 func (cb *ContextBuilder) getIdentity() string {
-	now := time.Now().Format("2006-01-02 15:04 (Monday)")
 	workspacePath, _ := filepath.Abs(filepath.Join(cb.workspace))
 	runtime := fmt.Sprintf("%s %s, Go %s", runtime.GOOS, runtime.GOARCH, runtime.Version())
 
@@ -60,9 +65,6 @@ func (cb *ContextBuilder) getIdentity() string {
 
 You are picoclaw, a helpful AI assistant.
 
-## Current Time
-%s
-
 ## Runtime
 %s
 
@@ -81,7 +83,7 @@ Your workspace is at: %s
 2. **Be helpful and accurate** - When using tools, briefly explain what you're doing.
 
 3. **Memory** - When remembering something, write to %s/memory/MEMORY.md`,
-		now, runtime, workspacePath, workspacePath, workspacePath, workspacePath, toolsSection, workspacePath)
+		runtime, workspacePath, workspacePath, workspacePath, workspacePath, toolsSection, workspacePath)
 }
 
 func (cb *ContextBuilder) buildToolsSection() string {
@@ -157,16 +159,31 @@ func (cb *ContextBuilder) LoadBootstrapFiles() string {
 	return result
 }
 
+// buildTurnContext returns the parts of the system prompt that change from
+// turn to turn. They are sent after the stable prompt from BuildSystemPrompt
+// so that providers can cache everything before them.
+func (cb *ContextBuilder) buildTurnContext(summary, channel, chatID string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Current Time\n%s", time.Now().Format("2006-01-02 15:04 (Monday)"))
+
+	if channel != "" && chatID != "" {
+		fmt.Fprintf(&sb, "\n\n## Current Session\nChannel: %s\nChat ID: %s", channel, chatID)
+	}
+
+	if summary != "" {
+		sb.WriteString("\n\n## Summary of Previous Conversation\n\n" + summary)
+	}
+	return sb.String()
+}
+
+// BuildMessages assembles the request for a turn: the stable system prompt,
+// a second system message with per-turn context, the history and the new
+// user message.
 func (cb *ContextBuilder) BuildMessages(history []providers.Message, summary string, currentMessage string, media []string, channel, chatID string) []providers.Message {
 	messages := []providers.Message{}
 
 	systemPrompt := cb.BuildSystemPrompt()
 
-	// Add Current Session info if provided
-	if channel != "" && chatID != "" {
-		systemPrompt += fmt.Sprintf("\n\n## Current Session\nChannel: %s\nChat ID: %s", channel, chatID)
-	}
-
 	// Log system prompt summary for debugging (debug mode only)
 	logger.DebugCF("agent", "System prompt built",
 		map[string]interface{}{
@@ -185,10 +202,6 @@ func (cb *ContextBuilder) BuildMessages(history []providers.Message, summary str
 			"preview": preview,
 		})
 
-	if summary != "" {
-		systemPrompt += "\n\n## Summary of Previous Conversation\n\n" + summary
-	}
-
 	//This fix prevents the session memory from LLM failure due to elimination of toolu_IDs required from LLM
 	// --- INICIO DEL FIX ---
 	//Diegox-17
@@ -203,6 +216,9 @@ func (cb *ContextBuilder) BuildMessages(history []providers.Message, summary str
 	messages = append(messages, providers.Message{
 		Role:    "system",
 		Content: systemPrompt,
+	}, providers.Message{
+		Role:    "system",
+		Content: cb.buildTurnContext(summary, channel, chatID),
 	})
 
 	messages = append(messages, history...)
@@ -210,6 +226,7 @@ func (cb *ContextBuilder) BuildMessages(history []providers.Message, summary str
 	messages = append(messages, providers.Message{
 		Role:    "user",
 		Content: currentMessage,
+		Media:   media,
 	})
 
 	return messages
@@ -4,12 +4,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/session"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
 // AgentInstance represents a fully configured agent with its own workspace,
@@ -23,7 +26,8 @@ type AgentInstance struct {
 	MaxIterations  int
 	MaxTokens      int
 	Temperature    float64
-	ContextWindow  int
+	ContextWindow  int    // read through contextWindow
+	ProviderName   string // provider for models without a "provider/" prefix
 	Provider       providers.LLMProvider
 	Sessions       *session.SessionManager
 	ContextBuilder *ContextBuilder
@@ -31,6 +35,15 @@ type AgentInstance struct {
 	Subagents      *config.SubagentsConfig
 	SkillsFilter   []string
 	Candidates     []providers.FallbackCandidate
+	Router         *providers.ModelRouter  // nil unless model routing is enabled
+	Reasoning      *config.ReasoningConfig // nil unless reasoning is configured; Effort and BudgetTokens are both set when enabled
+	VoiceReply     string                  // voice.ReplyOff, ReplyAuto or ReplyAlways
+
+	// HardwareDevices are the device path globs the gpio, pwm and serial
+	// tools may use; empty allows every device.
+	HardwareDevices []string
+
+	contextWindowOnce sync.Once
 }
 
 // NewAgentInstance creates an agent instance from config.
@@ -45,6 +58,7 @@ func NewAgentInstance(
 
 	model := resolveAgentModel(agentCfg, defaults)
 	fallbacks := resolveAgentFallbacks(agentCfg, defaults)
+	providerName := resolveAgentProvider(agentCfg, defaults)
 
 	restrict := defaults.RestrictToWorkspace
 	toolsRegistry := tools.NewToolRegistry()
@@ -72,6 +86,7 @@ func NewAgentInstance(
 		subagents = agentCfg.Subagents
 		skillsFilter = agentCfg.Skills
 	}
+	contextBuilder.SetSkillsFilter(skillsFilter)
 
 	maxIter := defaults.MaxToolIterations
 	if maxIter == 0 {
@@ -93,7 +108,7 @@ func NewAgentInstance(
 		Primary:   model,
 		Fallbacks: fallbacks,
 	}
-	candidates := providers.ResolveCandidates(modelCfg, defaults.Provider)
+	candidates := providers.ResolveCandidates(modelCfg, providerName)
 
 	return &AgentInstance{
 		ID:             agentID,
@@ -105,6 +120,7 @@ func NewAgentInstance(
 		MaxTokens:      maxTokens,
 		Temperature:    temperature,
 		ContextWindow:  maxTokens,
+		ProviderName:   providerName,
 		Provider:       provider,
 		Sessions:       sessionsManager,
 		ContextBuilder: contextBuilder,
@@ -112,9 +128,43 @@ func NewAgentInstance(
 		Subagents:      subagents,
 		SkillsFilter:   skillsFilter,
 		Candidates:     candidates,
+		Router:         resolveAgentRouter(agentID, agentCfg, defaults, providerName),
+		Reasoning:      resolveAgentReasoning(agentCfg, defaults),
+		VoiceReply:     resolveAgentVoiceReply(agentCfg, cfg),
+
+		HardwareDevices: resolveAgentHardwareDevices(agentCfg, defaults),
 	}
 }
 
+// contextWindow returns the model's context window in tokens. Providers
+// that report it are asked on first use rather than at startup, so a slow
+// or unreachable server does not hold up the gateway.
+func (a *AgentInstance) contextWindow() int {
+	a.contextWindowOnce.Do(func() { applyProviderContextWindow(a) })
+	return a.ContextWindow
+}
+
+// resolveAgentHardwareDevices resolves the device allow-list for an agent.
+func resolveAgentHardwareDevices(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) []string {
+	if agentCfg != nil && agentCfg.HardwareDevices != nil {
+		return agentCfg.HardwareDevices
+	}
+	return defaults.HardwareDevices
+}
+
+// resolveAgentVoiceReply resolves when an agent's replies are spoken.
+func resolveAgentVoiceReply(agentCfg *config.AgentConfig, cfg *config.Config) string {
+	if agentCfg != nil {
+		if mode := voice.NormalizeReplyMode(agentCfg.VoiceReply); mode != "" {
+			return mode
+		}
+	}
+	if mode := voice.NormalizeReplyMode(cfg.Voice.TTS.Reply); mode != "" {
+		return mode
+	}
+	return voice.ReplyOff
+}
+
 // resolveAgentWorkspace determines the workspace directory for an agent.
 func resolveAgentWorkspace(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) string {
 	if agentCfg != nil && strings.TrimSpace(agentCfg.Workspace) != "" {
@@ -136,6 +186,80 @@ func resolveAgentModel(agentCfg *config.AgentConfig, defaults *config.AgentDefau
 	return defaults.Model
 }
 
+// resolveAgentProvider resolves the provider name for an agent.
+func resolveAgentProvider(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) string {
+	if agentCfg != nil && strings.TrimSpace(agentCfg.Provider) != "" {
+		return strings.TrimSpace(agentCfg.Provider)
+	}
+	return defaults.Provider
+}
+
+// resolveAgentRouter builds the model router for an agent, or returns nil
+// when routing is disabled or misconfigured.
+func resolveAgentRouter(agentID string, agentCfg *config.AgentConfig, defaults *config.AgentDefaults, providerName string) *providers.ModelRouter {
+	routingCfg := defaults.Routing
+	if agentCfg != nil && agentCfg.Routing != nil {
+		routingCfg = agentCfg.Routing
+	}
+	if routingCfg == nil || !routingCfg.Enabled {
+		return nil
+	}
+	router, err := providers.NewModelRouter(routingCfg, providerName)
+	if err != nil {
+		logger.WarnCF("agent", "Invalid model routing config, routing disabled",
+			map[string]interface{}{
+				"agent_id": agentID,
+				"error":    err.Error(),
+			})
+		return nil
+	}
+	return router
+}
+
+// Thinking budgets used when only an effort level is configured.
+var reasoningBudgets = map[string]int{
+	"minimal": 1024,
+	"low":     2048,
+	"medium":  8192,
+	"high":    24576,
+}
+
+// resolveAgentReasoning resolves the reasoning settings for an agent and
+// fills in whichever of effort and budget is missing, so every provider
+// gets the form it understands.
+func resolveAgentReasoning(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) *config.ReasoningConfig {
+	reasoningCfg := defaults.Reasoning
+	if agentCfg != nil && agentCfg.Reasoning != nil {
+		reasoningCfg = agentCfg.Reasoning
+	}
+	if reasoningCfg == nil {
+		return nil
+	}
+
+	r := *reasoningCfg
+	r.Effort = strings.ToLower(strings.TrimSpace(r.Effort))
+	if r.Effort == "none" || r.Effort == "off" {
+		r.Effort, r.BudgetTokens = "", 0
+	}
+	switch {
+	case r.Effort == "" && r.BudgetTokens > 0:
+		switch {
+		case r.BudgetTokens <= reasoningBudgets["low"]:
+			r.Effort = "low"
+		case r.BudgetTokens <= reasoningBudgets["medium"]:
+			r.Effort = "medium"
+		default:
+			r.Effort = "high"
+		}
+	case r.Effort != "" && r.BudgetTokens <= 0:
+		r.BudgetTokens = reasoningBudgets[r.Effort]
+		if r.BudgetTokens == 0 {
+			r.BudgetTokens = reasoningBudgets["medium"]
+		}
+	}
+	return &r
+}
+
 // resolveAgentFallbacks resolves the fallback models for an agent.
 func resolveAgentFallbacks(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) []string {
 	if agentCfg != nil && agentCfg.Model != nil && agentCfg.Model.Fallbacks != nil {
@@ -93,3 +93,36 @@ func TestNewAgentInstance_DefaultsTemperatureWhenUnset(t *testing.T) {
 		t.Fatalf("Temperature = %f, want %f", agent.Temperature, 0.7)
 	}
 }
+
+func TestResolveAgentReasoning(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults *config.ReasoningConfig
+		agent    *config.ReasoningConfig
+		want     *config.ReasoningConfig
+	}{
+		{"unset", nil, nil, nil},
+		{"effort fills budget", &config.ReasoningConfig{Effort: " High "}, nil, &config.ReasoningConfig{Effort: "high", BudgetTokens: 24576}},
+		{"budget fills effort", &config.ReasoningConfig{BudgetTokens: 4000}, nil, &config.ReasoningConfig{Effort: "medium", BudgetTokens: 4000}},
+		{"unknown effort", &config.ReasoningConfig{Effort: "xhigh"}, nil, &config.ReasoningConfig{Effort: "xhigh", BudgetTokens: 8192}},
+		{"agent overrides", &config.ReasoningConfig{Effort: "high"}, &config.ReasoningConfig{Effort: "low", Show: true}, &config.ReasoningConfig{Effort: "low", BudgetTokens: 2048, Show: true}},
+		{"agent disables", &config.ReasoningConfig{Effort: "high"}, &config.ReasoningConfig{Effort: "off", BudgetTokens: 5000}, &config.ReasoningConfig{}},
+	}
+	for _, tt := range tests {
+		got := resolveAgentReasoning(&config.AgentConfig{Reasoning: tt.agent}, &config.AgentDefaults{Reasoning: tt.defaults})
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("%s: resolveAgentReasoning() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveAgentHardwareDevices(t *testing.T) {
+	defaults := &config.AgentDefaults{HardwareDevices: []string{"/dev/ttyUSB*"}}
+	if got := resolveAgentHardwareDevices(&config.AgentConfig{ID: "main"}, defaults); len(got) != 1 || got[0] != "/dev/ttyUSB*" {
+		t.Errorf("inherited devices = %v", got)
+	}
+	agentCfg := &config.AgentConfig{ID: "lab", HardwareDevices: []string{"/dev/gpiochip0", "/dev/ttyACM*"}}
+	if got := resolveAgentHardwareDevices(agentCfg, defaults); len(got) != 2 || got[0] != "/dev/gpiochip0" {
+		t.Errorf("agent devices = %v", got)
+	}
+}
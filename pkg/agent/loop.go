@@ -10,6 +10,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -20,12 +21,14 @@ import (
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/costs"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/state"
 	"github.com/sipeed/picoclaw/pkg/tools"
 	"github.com/sipeed/picoclaw/pkg/utils"
+	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
 type AgentLoop struct {
@@ -35,27 +38,58 @@ type AgentLoop struct {
 	state          *state.Manager
 	running        atomic.Bool
 	summarizing    sync.Map
+	showReasoning  sync.Map // "channel:chatID" -> bool, set by /think
+	voiceReplies   sync.Map // "channel:chatID" -> voice reply mode, set by /voice
 	fallback       *providers.FallbackChain
+	cooldown       *providers.CooldownTracker
+	costs          *costs.Tracker
 	channelManager *channels.Manager
+	subagentStore  *tools.SubagentStore
+	subagents      map[string]*tools.SubagentManager // by spawning agent ID
 }
 
 // processOptions configures how a message is processed
 type processOptions struct {
-	SessionKey      string // Session identifier for history/context
-	Channel         string // Target channel for tool execution
-	ChatID          string // Target chat ID for tool execution
-	UserMessage     string // User message content (may include prefix)
-	DefaultResponse string // Response when LLM returns empty
-	EnableSummary   bool   // Whether to trigger summarization
-	SendResponse    bool   // Whether to send response via bus
-	NoHistory       bool   // If true, don't load session history (for heartbeat)
+	SessionKey      string   // Session identifier for history/context
+	Channel         string   // Target channel for tool execution
+	ChatID          string   // Target chat ID for tool execution
+	UserMessage     string   // User message content (may include prefix)
+	DefaultResponse string   // Response when LLM returns empty
+	EnableSummary   bool     // Whether to trigger summarization
+	SendResponse    bool     // Whether to send response via bus
+	NoHistory       bool     // If true, don't load session history (for heartbeat)
+	Model           string   // Model override for this run (skips the agent's fallback chain)
+	MaxIterations   int      // Tool iteration override for this run (0 = agent default)
+	Media           []string // Files attached to the user message (images for vision models)
+
+	ResponseFormat *providers.ResponseFormat // Reply must be JSON matching this schema
+
+	// Candidates is the fallback chain for the Model override; without it
+	// only Model is tried.
+	Candidates []providers.FallbackCandidate
+
+	// Usage, when set, receives the tokens used by every LLM call.
+	Usage *providers.UsageInfo
+
+	// Tools, when set, replaces the agent's tool registry for this run.
+	Tools *tools.ToolRegistry
+
+	// Reasoning, when set, receives the reasoning summary to show with the
+	// reply when /think is on. The reply itself never includes it.
+	Reasoning *string
+
+	// OnIteration, when set, is called after the tool calls of each
+	// iteration have run and been saved to the session.
+	OnIteration func(iteration int)
 }
 
+// maxStructuredRetries is how many times a reply that does not match the
+// requested schema is sent back to the model for correction.
+const maxStructuredRetries = 2
+
 func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) *AgentLoop {
 	registry := NewAgentRegistry(cfg, provider)
-
-	// Register shared tools to all agents
-	registerSharedTools(cfg, msgBus, registry, provider)
+	costTracker := costs.NewTracker(cfg.WorkspacePath(), cfg.Costs)
 
 	// Set up shared fallback chain
 	cooldown := providers.NewCooldownTracker()
@@ -68,18 +102,72 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		stateManager = state.NewManager(defaultAgent.Workspace)
 	}
 
-	return &AgentLoop{
+	al := &AgentLoop{
 		bus:         msgBus,
 		cfg:         cfg,
 		registry:    registry,
 		state:       stateManager,
 		summarizing: sync.Map{},
 		fallback:    fallbackChain,
+		cooldown:    cooldown,
+		costs:       costTracker,
+
+		subagentStore: tools.NewSubagentStore(cfg.WorkspacePath()),
+		subagents:     make(map[string]*tools.SubagentManager),
 	}
+	costTracker.SetAlertHandler(al.sendBudgetAlert)
+
+	// Register shared tools to all agents
+	al.registerSharedTools()
+	return al
+}
+
+// NewProviderProber returns a prober for every provider/model an agent may
+// call: each agent's primary model and fallbacks. Probe results share the
+// cooldown tracker used by the fallback chain, and probe calls are not
+// metered as LLM costs.
+func (al *AgentLoop) NewProviderProber(timeout time.Duration) *providers.Prober {
+	var targets []providers.ProbeTarget
+	for _, id := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(id)
+		if !ok {
+			continue
+		}
+		candidates := agent.Candidates
+		if len(candidates) == 0 {
+			if ref := providers.ParseModelRef(agent.Model, agent.ProviderName); ref != nil {
+				candidates = []providers.FallbackCandidate{{Provider: ref.Provider, Model: ref.Model}}
+			}
+		}
+		for _, c := range candidates {
+			client, model := al.resolveCandidate(agent, c.Provider, c.Model)
+			targets = append(targets, providers.ProbeTarget{Provider: c.Provider, Model: model, Client: client})
+		}
+	}
+	return providers.NewProber(al.cooldown, targets, timeout)
+}
+
+// sendBudgetAlert delivers a budget alert to costs.alert_channel, or to the
+// last active chat when none is configured.
+func (al *AgentLoop) sendBudgetAlert(message string) {
+	target := al.cfg.Costs.AlertChannel
+	if target == "" && al.state != nil {
+		target = al.state.GetLastChannel()
+	}
+	channel, chatID, ok := strings.Cut(target, ":")
+	if !ok || channel == "" || chatID == "" || constants.IsInternalChannel(channel) {
+		return
+	}
+	al.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: channel,
+		ChatID:  chatID,
+		Content: message,
+	})
 }
 
 // registerSharedTools registers tools that are shared across all agents (web, message, spawn).
-func registerSharedTools(cfg *config.Config, msgBus *bus.MessageBus, registry *AgentRegistry, provider providers.LLMProvider) {
+func (al *AgentLoop) registerSharedTools() {
+	cfg, msgBus, registry, costTracker := al.cfg, al.bus, al.registry, al.costs
 	for _, agentID := range registry.ListAgentIDs() {
 		agent, ok := registry.GetAgent(agentID)
 		if !ok {
@@ -101,9 +189,17 @@ func registerSharedTools(cfg *config.Config, msgBus *bus.MessageBus, registry *A
 		}
 		agent.Tools.Register(tools.NewWebFetchTool(50000))
 
-		// Hardware tools (I2C, SPI) - Linux only, returns error on other platforms
-		agent.Tools.Register(tools.NewI2CTool())
-		agent.Tools.Register(tools.NewSPITool())
+		// Hardware tools (I2C, SPI, GPIO, PWM, serial, sensor, camera) - Linux only, returns error on other platforms
+		agent.Tools.Register(tools.NewI2CTool(agent.HardwareDevices))
+		agent.Tools.Register(tools.NewSPITool(agent.HardwareDevices))
+		agent.Tools.Register(tools.NewGPIOTool(agent.HardwareDevices))
+		agent.Tools.Register(tools.NewPWMTool(agent.HardwareDevices))
+		agent.Tools.Register(tools.NewSerialTool(agent.HardwareDevices))
+		agent.Tools.Register(tools.NewSensorTool(agent.Workspace, agent.HardwareDevices))
+		agent.Tools.Register(tools.NewCameraTool(agent.Workspace, agent.HardwareDevices))
+
+		// Telemetry tool: records metrics in the workspace and charts them
+		agent.Tools.Register(tools.NewTelemetryTool(agent.Workspace, msgBus))
 
 		// Message tool
 		messageTool := tools.NewMessageTool()
@@ -117,15 +213,22 @@ func registerSharedTools(cfg *config.Config, msgBus *bus.MessageBus, registry *A
 		})
 		agent.Tools.Register(messageTool)
 
-		// Spawn tool with allowlist checker
-		subagentManager := tools.NewSubagentManager(provider, agent.Model, agent.Workspace, msgBus)
+		// Spawn tool with allowlist checker, and subagent_status to check on spawned tasks
+		subagentProvider := costTracker.Meter(agent.Provider, costs.Entry{Agent: agentID, Channel: "subagent", Provider: agent.ProviderName})
+		subagentManager := tools.NewSubagentManager(subagentProvider, agent.Model, agent.Workspace, msgBus)
 		subagentManager.SetLLMOptions(agent.MaxTokens, agent.Temperature)
+		subagentManager.SetRunner(al.subagentRunner(agent))
+		subagentManager.SetAgentID(agentID)
+		subagentManager.SetStore(al.subagentStore)
+		subagentManager.SetLimits(subagentLimits(agent.Subagents))
+		al.subagents[agentID] = subagentManager
 		spawnTool := tools.NewSpawnTool(subagentManager)
 		currentAgentID := agentID
 		spawnTool.SetAllowlistChecker(func(targetAgentID string) bool {
 			return registry.CanSpawnSubagent(currentAgentID, targetAgentID)
 		})
 		agent.Tools.Register(spawnTool)
+		agent.Tools.Register(tools.NewSubagentStatusTool(subagentManager))
 
 		// Update context builder with the complete tools registry
 		agent.ContextBuilder.SetToolsRegistry(agent.Tools)
@@ -135,6 +238,10 @@ func registerSharedTools(cfg *config.Config, msgBus *bus.MessageBus, registry *A
 func (al *AgentLoop) Run(ctx context.Context) error {
 	al.running.Store(true)
 
+	if err := al.subagentStore.Recover(); err != nil {
+		logger.WarnCF("agent", "Failed to recover subagent tasks", map[string]interface{}{"error": err.Error()})
+	}
+
 	for al.running.Load() {
 		select {
 		case <-ctx.Done():
@@ -145,9 +252,12 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 				continue
 			}
 
-			response, err := al.processMessage(ctx, msg)
+			var reasoning string
+			response, err := al.handleInbound(ctx, msg, &reasoning)
 			if err != nil {
 				response = fmt.Sprintf("Error processing message: %v", err)
+			} else if response != "" && reasoning != "" {
+				response = reasoning + "\n\n" + response
 			}
 
 			if response != "" {
@@ -169,6 +279,7 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 						Channel: msg.Channel,
 						ChatID:  msg.ChatID,
 						Content: response,
+						Voice:   err == nil && al.voiceReply(msg),
 					})
 				}
 			}
@@ -192,6 +303,24 @@ func (al *AgentLoop) RegisterTool(tool tools.Tool) {
 
 func (al *AgentLoop) SetChannelManager(cm *channels.Manager) {
 	al.channelManager = cm
+
+	// The camera tool takes MaixCam snapshots through the maixcam channel.
+	channel, ok := cm.GetChannel("maixcam")
+	if !ok {
+		return
+	}
+	source, ok := channel.(tools.SnapshotSource)
+	if !ok {
+		return
+	}
+	for _, id := range al.registry.ListAgentIDs() {
+		agent, _ := al.registry.GetAgent(id)
+		if tool, ok := agent.Tools.Get("camera"); ok {
+			if ct, ok := tool.(*tools.CameraTool); ok {
+				ct.SetMaixCam(source)
+			}
+		}
+	}
 }
 
 // RecordLastChannel records the last active channel for this workspace.
@@ -228,6 +357,86 @@ func (al *AgentLoop) ProcessDirectWithChannel(ctx context.Context, content, sess
 	return al.processMessage(ctx, msg)
 }
 
+// ProcessDirectWithOptions runs content on a specific agent and session, as
+// used by scheduled jobs. An empty AgentID uses the agent routed for the
+// channel. Session keys that are not agent-scoped ("agent:...") are scoped to
+// the selected agent; keys scoped to another agent are re-scoped, so the same
+// chat session can be targeted on a different agent.
+func (al *AgentLoop) ProcessDirectWithOptions(ctx context.Context, content, channel, chatID string, opts tools.DirectRunOptions) (string, error) {
+	var agent *AgentInstance
+	if opts.AgentID != "" {
+		a, ok := al.registry.GetAgent(opts.AgentID)
+		if !ok {
+			return "", fmt.Errorf("agent %q not found", opts.AgentID)
+		}
+		agent = a
+	}
+
+	sessionKey := opts.SessionKey
+	if agent == nil {
+		route := al.registry.ResolveRoute(routing.RouteInput{Channel: channel})
+		a, ok := al.registry.GetAgent(route.AgentID)
+		if !ok {
+			a = al.registry.GetDefaultAgent()
+		}
+		agent = a
+		if sessionKey == "" && !opts.ChatSession {
+			sessionKey = route.SessionKey
+		}
+	}
+	if sessionKey == "" && opts.ChatSession {
+		sessionKey = al.chatSessionKey(agent, channel, chatID)
+	}
+
+	if sessionKey == "" {
+		sessionKey = routing.BuildAgentMainSessionKey(agent.ID)
+	} else if parsed := routing.ParseAgentSessionKey(sessionKey); parsed == nil {
+		sessionKey = fmt.Sprintf("agent:%s:%s", agent.ID, sessionKey)
+	} else if parsed.AgentID != agent.ID {
+		sessionKey = fmt.Sprintf("agent:%s:%s", agent.ID, parsed.Rest)
+	}
+
+	if opts.NoHistory {
+		// Only keep the transcript of the latest run.
+		agent.Sessions.SetHistory(sessionKey, nil)
+	}
+
+	return al.runAgentLoop(ctx, agent, processOptions{
+		SessionKey:      sessionKey,
+		Channel:         channel,
+		ChatID:          chatID,
+		UserMessage:     content,
+		DefaultResponse: "I've completed processing but have no response to give.",
+		EnableSummary:   !opts.NoHistory,
+		SendResponse:    opts.SendResponse,
+		NoHistory:       opts.NoHistory,
+		Model:           opts.Model,
+		MaxIterations:   opts.MaxIterations,
+		ResponseFormat:  opts.ResponseFormat,
+	})
+}
+
+// chatSessionKey returns the session that messages from chatID on channel
+// are routed to. Group and channel chats are recognized by their existing
+// session; any other chat is routed as a direct chat.
+func (al *AgentLoop) chatSessionKey(agent *AgentInstance, channel, chatID string) string {
+	for _, kind := range []string{"group", "channel"} {
+		key := strings.ToLower(routing.BuildAgentPeerSessionKey(routing.SessionKeyParams{
+			AgentID: agent.ID,
+			Channel: channel,
+			Peer:    &routing.RoutePeer{Kind: kind, ID: chatID},
+		}))
+		if len(agent.Sessions.GetHistory(key)) > 0 {
+			return key
+		}
+	}
+	route := al.registry.ResolveRoute(routing.RouteInput{
+		Channel: channel,
+		Peer:    &routing.RoutePeer{Kind: "direct", ID: chatID},
+	})
+	return route.SessionKey
+}
+
 // ProcessHeartbeat processes a heartbeat request without session history.
 // Each heartbeat is independent and doesn't accumulate context.
 func (al *AgentLoop) ProcessHeartbeat(ctx context.Context, content, channel, chatID string) (string, error) {
@@ -245,6 +454,12 @@ func (al *AgentLoop) ProcessHeartbeat(ctx context.Context, content, channel, cha
 }
 
 func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
+	return al.handleInbound(ctx, msg, nil)
+}
+
+// handleInbound is processMessage that also stores the reasoning summary to
+// show with the reply in reasoning, when that is not nil.
+func (al *AgentLoop) handleInbound(ctx context.Context, msg bus.InboundMessage, reasoning *string) (string, error) {
 	// Add message preview to log (show full content for error messages)
 	var logContent string
 	if strings.Contains(msg.Content, "Error:") || strings.Contains(msg.Content, "error") {
@@ -303,9 +518,11 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		Channel:         msg.Channel,
 		ChatID:          msg.ChatID,
 		UserMessage:     msg.Content,
+		Media:           msg.Media,
 		DefaultResponse: "I've completed processing but have no response to give.",
 		EnableSummary:   true,
 		SendResponse:    false,
+		Reasoning:       reasoning,
 	})
 }
 
@@ -348,18 +565,29 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 		return "", nil
 	}
 
-	// Use default agent for system messages
+	// System messages go to the agent named in their metadata, such as the
+	// one configured for device events, else to the default agent.
 	agent := al.registry.GetDefaultAgent()
+	if id := msg.Metadata["agent_id"]; id != "" {
+		if named, ok := al.registry.GetAgent(id); ok {
+			agent = named
+		}
+	}
 
 	// Use the origin session for context
 	sessionKey := routing.BuildAgentMainSessionKey(agent.ID)
 
+	defaultResponse := "Background task completed."
+	if msg.Metadata["source"] == "device" {
+		defaultResponse = ""
+	}
+
 	return al.runAgentLoop(ctx, agent, processOptions{
 		SessionKey:      sessionKey,
 		Channel:         originChannel,
 		ChatID:          originChatID,
 		UserMessage:     fmt.Sprintf("[System: %s] %s", msg.SenderID, msg.Content),
-		DefaultResponse: "Background task completed.",
+		DefaultResponse: defaultResponse,
 		EnableSummary:   false,
 		SendResponse:    true,
 	})
@@ -367,6 +595,13 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 
 // runAgentLoop is the core message processing logic.
 func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opts processOptions) (string, error) {
+	reply, _, err := al.runAgentTurn(ctx, agent, opts)
+	return reply, err
+}
+
+// runAgentTurn is runAgentLoop that also returns the number of LLM
+// iterations used.
+func (al *AgentLoop) runAgentTurn(ctx context.Context, agent *AgentInstance, opts processOptions) (string, int, error) {
 	// 0. Record last channel for heartbeat notifications (skip internal channels)
 	if opts.Channel != "" && opts.ChatID != "" {
 		// Don't record internal channels (cli, system, subagent)
@@ -379,7 +614,10 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	}
 
 	// 1. Update tool contexts
-	al.updateToolContexts(agent, opts.Channel, opts.ChatID)
+	if opts.Tools == nil {
+		opts.Tools = agent.Tools
+	}
+	updateToolContexts(opts.Tools, opts.Channel, opts.ChatID, opts.SessionKey)
 
 	// 2. Build messages (skip history for heartbeat)
 	var history []providers.Message
@@ -392,18 +630,25 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 		history,
 		summary,
 		opts.UserMessage,
-		nil,
+		opts.Media,
 		opts.Channel,
 		opts.ChatID,
 	)
 
+	// The schema instruction goes to the model with this turn only; native
+	// structured output enforces the schema, the instruction helps the rest.
+	if opts.ResponseFormat != nil {
+		last := &messages[len(messages)-1]
+		last.Content += "\n\n" + providers.StructuredInstruction(opts.ResponseFormat)
+	}
+
 	// 3. Save user message to session
 	agent.Sessions.AddMessage(opts.SessionKey, "user", opts.UserMessage)
 
 	// 4. Run LLM iteration loop
-	finalContent, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
+	finalContent, reasoning, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
 	if err != nil {
-		return "", err
+		return "", iteration, err
 	}
 
 	// If last tool had ForUser content and we already sent it, we might not need to send final response
@@ -423,16 +668,32 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 		al.maybeSummarize(agent, opts.SessionKey, opts.Channel, opts.ChatID)
 	}
 
-	// 8. Optional: send response via bus
-	if opts.SendResponse {
+	// 8. The reasoning summary is shown with the reply when /think is on.
+	// It is only added to what the user sees, not to the returned reply or
+	// the session history.
+	var shown string
+	if reasoning != "" && al.reasoningShown(agent, opts.Channel, opts.ChatID) {
+		shown = formatReasoning(reasoning)
+	}
+	if opts.Reasoning != nil {
+		*opts.Reasoning = shown
+	}
+
+	// 9. Optional: send response via bus, unless the message tool already
+	// replied in this round
+	if opts.SendResponse && finalContent != "" && !messageSentInRound(opts.Tools) {
+		content := finalContent
+		if shown != "" {
+			content = shown + "\n\n" + content
+		}
 		al.bus.PublishOutbound(bus.OutboundMessage{
 			Channel: opts.Channel,
 			ChatID:  opts.ChatID,
-			Content: finalContent,
+			Content: content,
 		})
 	}
 
-	// 9. Log response
+	// 10. Log response
 	responsePreview := utils.Truncate(finalContent, 120)
 	logger.InfoCF("agent", fmt.Sprintf("Response: %s", responsePreview),
 		map[string]interface{}{
@@ -442,33 +703,176 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 			"final_length": len(finalContent),
 		})
 
-	return finalContent, nil
+	return finalContent, iteration, nil
+}
+
+// reasoningShown reports whether the model's reasoning is shown in a chat:
+// the /think setting for the chat if one was made, else the agent's
+// reasoning.show config.
+func (al *AgentLoop) reasoningShown(agent *AgentInstance, channel, chatID string) bool {
+	if v, ok := al.showReasoning.Load(channel + ":" + chatID); ok {
+		return v.(bool)
+	}
+	return agent.Reasoning != nil && agent.Reasoning.Show
+}
+
+// withReasoning returns options with the reasoning settings added when
+// model supports them. Fallbacks and routed tiers may be models that reject
+// reasoning parameters, so each call checks its own model.
+func withReasoning(options map[string]interface{}, r *config.ReasoningConfig, model string) map[string]interface{} {
+	if r == nil || r.Effort == "" || !providers.SupportsReasoning(model) {
+		return options
+	}
+	withReasoning := make(map[string]interface{}, len(options)+2)
+	for k, v := range options {
+		withReasoning[k] = v
+	}
+	withReasoning["reasoning_effort"] = r.Effort
+	withReasoning["thinking_budget"] = r.BudgetTokens
+	return withReasoning
+}
+
+// formatReasoning collapses reasoning into a single quoted line.
+func formatReasoning(reasoning string) string {
+	return "> 💭 " + utils.Truncate(strings.Join(strings.Fields(reasoning), " "), 300)
+}
+
+// modelProvider returns the client and model name to use for a model
+// reference such as "groq/llama-3.3-70b" or a bare model name. Calls made
+// through the client are recorded in the cost ledger under channel.
+func (al *AgentLoop) modelProvider(agent *AgentInstance, channel, model string) (providers.LLMProvider, string) {
+	ref := providers.ParseModelRef(model, agent.ProviderName)
+	if ref == nil {
+		return al.costs.Meter(agent.Provider, costs.Entry{Agent: agent.ID, Channel: channel}), model
+	}
+	return al.candidateProvider(agent, channel, ref.Provider, ref.Model)
 }
 
-// runLLMIteration executes the LLM call loop with tool handling.
-func (al *AgentLoop) runLLMIteration(ctx context.Context, agent *AgentInstance, messages []providers.Message, opts processOptions) (string, int, error) {
+// candidateProvider returns the client for a fallback candidate. Candidates
+// without a provider, the agent's primary model, and candidates whose
+// provider has no configuration of its own use the agent's provider with the
+// full "provider/model" reference so gateways such as OpenRouter still
+// receive vendor-prefixed model names.
+func (al *AgentLoop) candidateProvider(agent *AgentInstance, channel, provider, model string) (providers.LLMProvider, string) {
+	client, model := al.resolveCandidate(agent, provider, model)
+	return al.costs.Meter(client, costs.Entry{Agent: agent.ID, Channel: channel, Provider: provider}), model
+}
+
+func (al *AgentLoop) resolveCandidate(agent *AgentInstance, provider, model string) (providers.LLMProvider, string) {
+	if provider == "" || al.registry.Providers() == nil {
+		return agent.Provider, model
+	}
+	if providers.NormalizeProvider(provider) == providers.NormalizeProvider(agent.ProviderName) {
+		return agent.Provider, model
+	}
+	// The agent's primary model always runs on the agent's own provider,
+	// which may be a gateway that expects the "vendor/model" name.
+	if primary := providers.ParseModelRef(agent.Model, agent.ProviderName); primary != nil &&
+		primary.Provider == providers.NormalizeProvider(provider) && primary.Model == model {
+		return agent.Provider, strings.TrimSpace(agent.Model)
+	}
+	client, err := al.registry.Providers().Get(provider)
+	if err != nil {
+		logger.DebugCF("agent", "Provider not configured, using agent provider",
+			map[string]interface{}{
+				"agent_id": agent.ID,
+				"provider": provider,
+				"error":    err.Error(),
+			})
+		return agent.Provider, provider + "/" + model
+	}
+	return client, model
+}
+
+// routeModel returns the model and fallback candidates for a routing decision.
+func routeModel(agent *AgentInstance, route providers.RouteDecision) (string, []providers.FallbackCandidate) {
+	if route.Model == "" {
+		return agent.Model, agent.Candidates
+	}
+	return route.Model, route.Candidates
+}
+
+func logRoute(agent *AgentInstance, route providers.RouteDecision, model string) {
+	logger.InfoCF("agent", fmt.Sprintf("Routed to tier %s (%s)", route.Tier, model),
+		map[string]interface{}{
+			"agent_id":  agent.ID,
+			"tier":      route.Tier,
+			"model":     model,
+			"reason":    route.Reason,
+			"escalated": route.Escalated,
+		})
+}
+
+// routeClassifier lets the model router ask its classifier model to pick a tier.
+func (al *AgentLoop) routeClassifier(agent *AgentInstance, channel string) providers.ClassifyFunc {
+	return func(ctx context.Context, model, system, user string) (string, error) {
+		client, model := al.modelProvider(agent, channel, model)
+		resp, err := client.Chat(ctx, []providers.Message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		}, nil, model, map[string]interface{}{
+			"max_tokens":   16,
+			"temperature":  0.0,
+			"prompt_cache": false,
+		})
+		if err != nil {
+			return "", err
+		}
+		return resp.Content, nil
+	}
+}
+
+// runLLMIteration executes the LLM call loop with tool handling. It returns
+// the final content, the model's reasoning across all iterations and the
+// number of iterations used.
+func (al *AgentLoop) runLLMIteration(ctx context.Context, agent *AgentInstance, messages []providers.Message, opts processOptions) (string, string, int, error) {
 	iteration := 0
 	var finalContent string
+	var reasoning []string
+	structuredRetries := 0
+	var attached []string
+
+	maxIterations := agent.MaxIterations
+	if opts.MaxIterations > 0 {
+		maxIterations = opts.MaxIterations
+	}
+	model := agent.Model
+	candidates := agent.Candidates
+	var route providers.RouteDecision
+	routed := false
+	if opts.Model != "" {
+		model = opts.Model
+		candidates = opts.Candidates
+	} else if agent.Router != nil {
+		route = agent.Router.Route(ctx, providers.RouteRequest{
+			Content:  opts.UserMessage,
+			HasMedia: len(opts.Media) > 0,
+			Channel:  opts.Channel,
+		}, al.routeClassifier(agent, opts.Channel))
+		routed = true
+		model, candidates = routeModel(agent, route)
+		logRoute(agent, route, model)
+	}
 
-	for iteration < agent.MaxIterations {
+	for iteration < maxIterations {
 		iteration++
 
 		logger.DebugCF("agent", "LLM iteration",
 			map[string]interface{}{
 				"agent_id":  agent.ID,
 				"iteration": iteration,
-				"max":       agent.MaxIterations,
+				"max":       maxIterations,
 			})
 
 		// Build tool definitions
-		providerToolDefs := agent.Tools.ToProviderDefs()
+		providerToolDefs := opts.Tools.ToProviderDefs()
 
 		// Log LLM request details
 		logger.DebugCF("agent", "LLM request",
 			map[string]interface{}{
 				"agent_id":          agent.ID,
 				"iteration":         iteration,
-				"model":             agent.Model,
+				"model":             model,
 				"messages_count":    len(messages),
 				"tools_count":       len(providerToolDefs),
 				"max_tokens":        agent.MaxTokens,
@@ -488,14 +892,21 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, agent *AgentInstance,
 		var response *providers.LLMResponse
 		var err error
 
+		llmOptions := map[string]interface{}{
+			"max_tokens":       agent.MaxTokens,
+			"temperature":      agent.Temperature,
+			"prompt_cache_key": agent.ID,
+		}
+		if opts.ResponseFormat != nil {
+			llmOptions["response_format"] = opts.ResponseFormat
+		}
 		callLLM := func() (*providers.LLMResponse, error) {
-			if len(agent.Candidates) > 1 && al.fallback != nil {
-				fbResult, fbErr := al.fallback.Execute(ctx, agent.Candidates,
+			request := withAttachments(messages, attached)
+			if len(candidates) > 1 && al.fallback != nil {
+				fbResult, fbErr := al.fallback.Execute(ctx, candidates,
 					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
-						return agent.Provider.Chat(ctx, messages, providerToolDefs, model, map[string]interface{}{
-							"max_tokens":  agent.MaxTokens,
-							"temperature": agent.Temperature,
-						})
+						client, model := al.candidateProvider(agent, opts.Channel, provider, model)
+						return client.Chat(ctx, request, providerToolDefs, model, withReasoning(llmOptions, agent.Reasoning, model))
 					},
 				)
 				if fbErr != nil {
@@ -508,17 +919,15 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, agent *AgentInstance,
 				}
 				return fbResult.Response, nil
 			}
-			return agent.Provider.Chat(ctx, messages, providerToolDefs, agent.Model, map[string]interface{}{
-				"max_tokens":  agent.MaxTokens,
-				"temperature": agent.Temperature,
-			})
+			client, model := al.modelProvider(agent, opts.Channel, model)
+			return client.Chat(ctx, request, providerToolDefs, model, withReasoning(llmOptions, agent.Reasoning, model))
 		}
 
 		// Retry loop for context/token errors
 		maxRetries := 2
 		for retry := 0; retry <= maxRetries; retry++ {
 			response, err = callLLM()
-			if err == nil {
+			if err == nil || ctx.Err() != nil {
 				break
 			}
 
@@ -561,12 +970,56 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, agent *AgentInstance,
 					"iteration": iteration,
 					"error":     err.Error(),
 				})
-			return "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
+			return "", "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
+		}
+		attached = nil
+
+		if response.Reasoning != "" {
+			reasoning = append(reasoning, response.Reasoning)
+		}
+
+		if u := response.Usage; u != nil {
+			if opts.Usage != nil {
+				opts.Usage.Add(u)
+			}
+			logger.DebugCF("agent", "LLM usage",
+				map[string]interface{}{
+					"agent_id":           agent.ID,
+					"iteration":          iteration,
+					"prompt_tokens":      u.PromptTokens,
+					"completion_tokens":  u.CompletionTokens,
+					"cache_read_tokens":  u.CacheReadTokens,
+					"cache_write_tokens": u.CacheWriteTokens,
+					"reasoning_tokens":   u.ReasoningTokens,
+				})
 		}
 
 		// Check if no tool calls - we're done
 		if len(response.ToolCalls) == 0 {
 			finalContent = response.Content
+			if opts.ResponseFormat != nil {
+				structured, err := providers.ValidateStructured(opts.ResponseFormat, finalContent)
+				if err != nil {
+					if structuredRetries >= maxStructuredRetries || iteration >= maxIterations {
+						return "", "", iteration, fmt.Errorf("reply does not match the response schema: %w", err)
+					}
+					structuredRetries++
+					logger.WarnCF("agent", "Reply does not match the response schema, asking again",
+						map[string]interface{}{
+							"agent_id": agent.ID,
+							"retry":    structuredRetries,
+							"error":    err.Error(),
+						})
+					messages = append(messages,
+						providers.Message{Role: "assistant", Content: finalContent},
+						providers.Message{Role: "user", Content: fmt.Sprintf(
+							"Your reply does not match the required JSON Schema: %v\n\n%s",
+							err, providers.StructuredInstruction(opts.ResponseFormat))},
+					)
+					continue
+				}
+				finalContent = structured
+			}
 			logger.InfoCF("agent", "LLM response without tool calls (direct answer)",
 				map[string]interface{}{
 					"agent_id":      agent.ID,
@@ -589,10 +1042,20 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, agent *AgentInstance,
 				"iteration": iteration,
 			})
 
-		// Build assistant message with tool calls
+		if routed {
+			if next, ok := agent.Router.Escalate(route, toolNames); ok {
+				route = next
+				model, candidates = routeModel(agent, route)
+				logRoute(agent, route, model)
+			}
+		}
+
+		// Build assistant message with tool calls. Thinking blocks stay in
+		// memory for the rest of the loop; they are not saved to the session.
 		assistantMsg := providers.Message{
-			Role:    "assistant",
-			Content: response.Content,
+			Role:     "assistant",
+			Content:  response.Content,
+			Thinking: response.Thinking,
 		}
 		for _, tc := range response.ToolCalls {
 			argumentsJSON, _ := json.Marshal(tc.Arguments)
@@ -612,6 +1075,7 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, agent *AgentInstance,
 		agent.Sessions.AddFullMessage(opts.SessionKey, assistantMsg)
 
 		// Execute tool calls
+		var toolMedia []string
 		for _, tc := range response.ToolCalls {
 			argsJSON, _ := json.Marshal(tc.Arguments)
 			argsPreview := utils.Truncate(string(argsJSON), 200)
@@ -638,7 +1102,7 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, agent *AgentInstance,
 				}
 			}
 
-			toolResult := agent.Tools.ExecuteWithContext(ctx, tc.Name, tc.Arguments, opts.Channel, opts.ChatID, asyncCallback)
+			toolResult := opts.Tools.ExecuteWithContext(ctx, tc.Name, tc.Arguments, opts.Channel, opts.ChatID, asyncCallback)
 
 			// Send ForUser content to user immediately if not Silent
 			if !toolResult.Silent && toolResult.ForUser != "" && opts.SendResponse {
@@ -669,37 +1133,80 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, agent *AgentInstance,
 
 			// Save tool result message to session
 			agent.Sessions.AddFullMessage(opts.SessionKey, toolResultMsg)
+			toolMedia = append(toolMedia, toolResult.Media...)
+		}
+
+		attached = toolMedia
+
+		if opts.OnIteration != nil {
+			opts.OnIteration(iteration)
 		}
 	}
 
-	return finalContent, iteration, nil
+	return finalContent, strings.Join(reasoning, "\n\n"), iteration, nil
+}
+
+// withAttachments adds media from the last tool results to a request.
+// Tool messages cannot carry images for most providers, so the media goes
+// in a user turn after them. The turn is sent with the next request only
+// and is not saved to the session; the tool results name the files.
+func withAttachments(messages []providers.Message, media []string) []providers.Message {
+	if len(media) == 0 {
+		return messages
+	}
+	return append(messages[:len(messages):len(messages)], providers.Message{
+		Role:    "user",
+		Content: "Attached: " + strings.Join(media, ", "),
+		Media:   media,
+	})
+}
+
+// messageSentInRound reports whether the message tool in registry sent a
+// message during the current round.
+func messageSentInRound(registry *tools.ToolRegistry) bool {
+	if tool, ok := registry.Get("message"); ok {
+		if mt, ok := tool.(*tools.MessageTool); ok {
+			return mt.HasSentInRound()
+		}
+	}
+	return false
 }
 
 // updateToolContexts updates the context for tools that need channel/chatID info.
-func (al *AgentLoop) updateToolContexts(agent *AgentInstance, channel, chatID string) {
+func updateToolContexts(registry *tools.ToolRegistry, channel, chatID, sessionKey string) {
 	// Use ContextualTool interface instead of type assertions
-	if tool, ok := agent.Tools.Get("message"); ok {
+	if tool, ok := registry.Get("message"); ok {
 		if mt, ok := tool.(tools.ContextualTool); ok {
 			mt.SetContext(channel, chatID)
 		}
 	}
-	if tool, ok := agent.Tools.Get("spawn"); ok {
+	if tool, ok := registry.Get("spawn"); ok {
 		if st, ok := tool.(tools.ContextualTool); ok {
 			st.SetContext(channel, chatID)
 		}
 	}
-	if tool, ok := agent.Tools.Get("subagent"); ok {
+	if tool, ok := registry.Get("subagent"); ok {
 		if st, ok := tool.(tools.ContextualTool); ok {
 			st.SetContext(channel, chatID)
 		}
 	}
+	if tool, ok := registry.Get("telemetry"); ok {
+		if tt, ok := tool.(tools.ContextualTool); ok {
+			tt.SetContext(channel, chatID)
+		}
+	}
+	if tool, ok := registry.Get("cron"); ok {
+		if ct, ok := tool.(tools.SessionAwareTool); ok {
+			ct.SetSessionKey(sessionKey)
+		}
+	}
 }
 
 // maybeSummarize triggers summarization if the session history exceeds thresholds.
 func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, chatID string) {
 	newHistory := agent.Sessions.GetHistory(sessionKey)
 	tokenEstimate := al.estimateTokens(newHistory)
-	threshold := agent.ContextWindow * 75 / 100
+	threshold := agent.contextWindow() * 75 / 100
 
 	if len(newHistory) > 20 || tokenEstimate > threshold {
 		summarizeKey := agent.ID + ":" + sessionKey
@@ -713,7 +1220,7 @@ func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, c
 						Content: "Memory threshold reached. Optimizing conversation history...",
 					})
 				}
-				al.summarizeSession(agent, sessionKey)
+				al.summarizeSession(agent, sessionKey, channel)
 			}()
 		}
 	}
@@ -859,7 +1366,7 @@ func formatToolsForLog(tools []providers.ToolDefinition) string {
 }
 
 // summarizeSession summarizes the conversation history for a session.
-func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
+func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey, channel string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
 
@@ -874,7 +1381,7 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 	toSummarize := history[:len(history)-4]
 
 	// Oversized Message Guard
-	maxMessageTokens := agent.ContextWindow / 2
+	maxMessageTokens := agent.contextWindow() / 2
 	validMessages := make([]providers.Message, 0)
 	omitted := false
 
@@ -901,13 +1408,15 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 		part1 := validMessages[:mid]
 		part2 := validMessages[mid:]
 
-		s1, _ := al.summarizeBatch(ctx, agent, part1, "")
-		s2, _ := al.summarizeBatch(ctx, agent, part2, "")
+		s1, _ := al.summarizeBatch(ctx, agent, channel, part1, "")
+		s2, _ := al.summarizeBatch(ctx, agent, channel, part2, "")
 
 		mergePrompt := fmt.Sprintf("Merge these two conversation summaries into one cohesive summary:\n\n1: %s\n\n2: %s", s1, s2)
-		resp, err := agent.Provider.Chat(ctx, []providers.Message{{Role: "user", Content: mergePrompt}}, nil, agent.Model, map[string]interface{}{
-			"max_tokens":  1024,
-			"temperature": 0.3,
+		client, model := al.modelProvider(agent, channel, agent.Model)
+		resp, err := client.Chat(ctx, []providers.Message{{Role: "user", Content: mergePrompt}}, nil, model, map[string]interface{}{
+			"max_tokens":   1024,
+			"temperature":  0.3,
+			"prompt_cache": false,
 		})
 		if err == nil {
 			finalSummary = resp.Content
@@ -915,7 +1424,7 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 			finalSummary = s1 + " " + s2
 		}
 	} else {
-		finalSummary, _ = al.summarizeBatch(ctx, agent, validMessages, summary)
+		finalSummary, _ = al.summarizeBatch(ctx, agent, channel, validMessages, summary)
 	}
 
 	if omitted && finalSummary != "" {
@@ -930,7 +1439,7 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 }
 
 // summarizeBatch summarizes a batch of messages.
-func (al *AgentLoop) summarizeBatch(ctx context.Context, agent *AgentInstance, batch []providers.Message, existingSummary string) (string, error) {
+func (al *AgentLoop) summarizeBatch(ctx context.Context, agent *AgentInstance, channel string, batch []providers.Message, existingSummary string) (string, error) {
 	prompt := "Provide a concise summary of this conversation segment, preserving core context and key points.\n"
 	if existingSummary != "" {
 		prompt += "Existing context: " + existingSummary + "\n"
@@ -940,9 +1449,11 @@ func (al *AgentLoop) summarizeBatch(ctx context.Context, agent *AgentInstance, b
 		prompt += fmt.Sprintf("%s: %s\n", m.Role, m.Content)
 	}
 
-	response, err := agent.Provider.Chat(ctx, []providers.Message{{Role: "user", Content: prompt}}, nil, agent.Model, map[string]interface{}{
-		"max_tokens":  1024,
-		"temperature": 0.3,
+	client, model := al.modelProvider(agent, channel, agent.Model)
+	response, err := client.Chat(ctx, []providers.Message{{Role: "user", Content: prompt}}, nil, model, map[string]interface{}{
+		"max_tokens":   1024,
+		"temperature":  0.3,
+		"prompt_cache": false,
 	})
 	if err != nil {
 		return "", err
@@ -987,7 +1498,7 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 			if defaultAgent == nil {
 				return "No default agent configured", true
 			}
-			return fmt.Sprintf("Current model: %s", defaultAgent.Model), true
+			return showModel(defaultAgent), true
 		case "channel":
 			return fmt.Sprintf("Current channel: %s", msg.Channel), true
 		case "agents":
@@ -997,6 +1508,24 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 			return fmt.Sprintf("Unknown show target: %s", args[0]), true
 		}
 
+	case "/cost":
+		return al.costCommand(args), true
+
+	case "/tasks":
+		return al.tasksCommand(msg), true
+
+	case "/task":
+		return al.taskCommand(msg, args), true
+
+	case "/cancel":
+		return al.cancelCommand(msg, args), true
+
+	case "/think":
+		return al.thinkCommand(msg, args), true
+
+	case "/voice":
+		return al.voiceCommand(msg, args), true
+
 	case "/list":
 		if len(args) < 1 {
 			return "Usage: /list [models|channels|agents]", true
@@ -1052,6 +1581,156 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 	return "", false
 }
 
+// thinkCommand turns the reasoning summary for the current chat on or off.
+func (al *AgentLoop) thinkCommand(msg bus.InboundMessage, args []string) string {
+	key := msg.Channel + ":" + msg.ChatID
+	if len(args) == 0 {
+		shown := false
+		if agent := al.routedAgent(msg); agent != nil {
+			shown = al.reasoningShown(agent, msg.Channel, msg.ChatID)
+		}
+		if shown {
+			return "Reasoning summaries are on. Use /think off to hide them."
+		}
+		return "Reasoning summaries are off. Use /think on to show them."
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "on":
+		al.showReasoning.Store(key, true)
+		return "Reasoning summaries on. Replies from reasoning models will start with a short summary of their thinking."
+	case "off":
+		al.showReasoning.Store(key, false)
+		return "Reasoning summaries off."
+	default:
+		return "Usage: /think [on|off]"
+	}
+}
+
+// voiceReplyMode returns when replies in a chat are spoken: the /voice
+// setting for the chat if one was made, else the routed agent's
+// voice_reply, else voice.tts.reply.
+func (al *AgentLoop) voiceReplyMode(msg bus.InboundMessage) string {
+	if v, ok := al.voiceReplies.Load(msg.Channel + ":" + msg.ChatID); ok {
+		return v.(string)
+	}
+	agent := al.routedAgent(msg)
+	if agent == nil {
+		return voice.ReplyOff
+	}
+	return agent.VoiceReply
+}
+
+// routedAgent returns the agent that msg is routed to, or the default agent
+// if the route names an unknown one.
+func (al *AgentLoop) routedAgent(msg bus.InboundMessage) *AgentInstance {
+	route := al.registry.ResolveRoute(routing.RouteInput{
+		Channel:    msg.Channel,
+		AccountID:  msg.Metadata["account_id"],
+		Peer:       extractPeer(msg),
+		ParentPeer: extractParentPeer(msg),
+		GuildID:    msg.Metadata["guild_id"],
+		TeamID:     msg.Metadata["team_id"],
+	})
+	agent, ok := al.registry.GetAgent(route.AgentID)
+	if !ok {
+		agent = al.registry.GetDefaultAgent()
+	}
+	return agent
+}
+
+// voiceReply reports whether the reply to msg is spoken. In auto mode only
+// voice messages, which channels mark with metadata["voice"], get voice
+// replies.
+func (al *AgentLoop) voiceReply(msg bus.InboundMessage) bool {
+	if constants.IsInternalChannel(msg.Channel) {
+		return false
+	}
+	switch al.voiceReplyMode(msg) {
+	case voice.ReplyAlways:
+		return true
+	case voice.ReplyAuto:
+		return msg.Metadata["voice"] == "true"
+	}
+	return false
+}
+
+// voiceCommand sets when replies in the current chat are spoken.
+func (al *AgentLoop) voiceCommand(msg bus.InboundMessage, args []string) string {
+	switch strings.ToLower(strings.TrimSpace(al.cfg.Voice.TTS.Backend)) {
+	case "", voice.BackendOff, "none":
+		return "Voice replies are not set up. Configure voice.tts to enable them."
+	}
+	if len(args) == 0 {
+		return fmt.Sprintf("Voice replies: %s. Use /voice auto, /voice always or /voice off.", al.voiceReplyMode(msg))
+	}
+
+	mode := voice.NormalizeReplyMode(args[0])
+	switch mode {
+	case voice.ReplyAuto:
+		al.voiceReplies.Store(msg.Channel+":"+msg.ChatID, mode)
+		return "Voice replies on. Voice messages will be answered with voice."
+	case voice.ReplyAlways:
+		al.voiceReplies.Store(msg.Channel+":"+msg.ChatID, mode)
+		return "Voice replies on for every message."
+	case voice.ReplyOff:
+		al.voiceReplies.Store(msg.Channel+":"+msg.ChatID, mode)
+		return "Voice replies off."
+	default:
+		return "Usage: /voice [auto|always|off]"
+	}
+}
+
+// costCommand reports LLM spend: "/cost" for today and the month so far,
+// "/cost week", "/cost month" or "/cost <days>" for a breakdown.
+func (al *AgentLoop) costCommand(args []string) string {
+	now := time.Now()
+	if len(args) == 0 {
+		today := al.costs.Report(costs.Today(now))
+		month := al.costs.Report(costs.MonthToDate(now))
+		return costs.Format(today, "Today", 5) + "\n\n" +
+			fmt.Sprintf("Month to date: $%.4f, %d calls", month.Total.CostUSD, month.Total.Calls)
+	}
+
+	switch args[0] {
+	case "today":
+		return costs.Format(al.costs.Report(costs.Today(now)), "Today", 10)
+	case "week":
+		return costs.Format(al.costs.Report(costs.LastDays(now, 7)), "Last 7 days", 10)
+	case "month":
+		return costs.Format(al.costs.Report(costs.MonthToDate(now)), "Month to date", 10)
+	default:
+		days, err := strconv.Atoi(args[0])
+		if err != nil || days < 1 {
+			return "Usage: /cost [today|week|month|<days>]"
+		}
+		return costs.Format(al.costs.Report(costs.LastDays(now, days)), fmt.Sprintf("Last %d days", days), 10)
+	}
+}
+
+// showModel describes the agent's model and, with routing enabled, its
+// tiers and the most recent routing decision.
+func showModel(agent *AgentInstance) string {
+	if agent.Router == nil {
+		return fmt.Sprintf("Current model: %s", agent.Model)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Current model: %s (routing enabled)\nTiers:\n", agent.Model)
+	for _, tier := range agent.Router.Tiers() {
+		fmt.Fprintf(&sb, "  %s: %s\n", tier.Name, tier.Model)
+	}
+	fmt.Fprintf(&sb, "  %s: %s\n", providers.DefaultTier, agent.Model)
+
+	if last, ok := agent.Router.Last(); ok {
+		model, _ := routeModel(agent, last)
+		fmt.Fprintf(&sb, "Last route: %s -> %s (%s) at %s", last.Tier, model, last.Reason, last.At.Format("15:04:05"))
+	} else {
+		sb.WriteString("Last route: none yet")
+	}
+	return sb.String()
+}
+
 // extractPeer extracts the routing peer from inbound message metadata.
 func extractPeer(msg bus.InboundMessage) *routing.RoutePeer {
 	peerKind := msg.Metadata["peer_kind"]
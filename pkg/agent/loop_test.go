@@ -2,13 +2,20 @@ package agent
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/tools"
@@ -521,10 +528,12 @@ type failFirstMockProvider struct {
 	currentCall int
 	failError   error
 	successResp string
+	models      []string
 }
 
 func (m *failFirstMockProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts map[string]interface{}) (*providers.LLMResponse, error) {
 	m.currentCall++
+	m.models = append(m.models, model)
 	if m.currentCall <= m.failures {
 		return nil, m.failError
 	}
@@ -613,3 +622,965 @@ func TestAgentLoop_ContextExhaustionRetry(t *testing.T) {
 		t.Errorf("Expected history to be compressed (len < 8), got %d", len(finalHistory))
 	}
 }
+
+type recordingMockProvider struct {
+	models       []string
+	messageCount []int
+	toolCalls    bool
+}
+
+func (m *recordingMockProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts map[string]interface{}) (*providers.LLMResponse, error) {
+	m.models = append(m.models, model)
+	m.messageCount = append(m.messageCount, len(messages))
+	if m.toolCalls {
+		return &providers.LLMResponse{
+			ToolCalls: []providers.ToolCall{{ID: fmt.Sprintf("call-%d", len(m.models)), Name: "missing_tool", Arguments: map[string]interface{}{}}},
+		}, nil
+	}
+	return &providers.LLMResponse{Content: "done"}, nil
+}
+
+func (m *recordingMockProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func newMultiAgentTestLoop(t *testing.T, provider providers.LLMProvider) *AgentLoop {
+	t.Helper()
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true},
+				{ID: "ops", Workspace: filepath.Join(tmpDir, "ops")},
+			},
+		},
+	}
+	return NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+}
+
+func TestProcessDirectWithOptions_AgentAndSession(t *testing.T) {
+	provider := &recordingMockProvider{}
+	al := newMultiAgentTestLoop(t, provider)
+
+	_, err := al.ProcessDirectWithOptions(context.Background(), "report", "telegram", "42", tools.DirectRunOptions{
+		AgentID:    "ops",
+		SessionKey: "cron:job1",
+		Model:      "override-model",
+	})
+	if err != nil {
+		t.Fatalf("ProcessDirectWithOptions failed: %v", err)
+	}
+
+	if len(provider.models) != 1 || provider.models[0] != "override-model" {
+		t.Errorf("models = %v, want [override-model]", provider.models)
+	}
+
+	ops, _ := al.registry.GetAgent("ops")
+	if got := len(ops.Sessions.GetHistory("agent:ops:cron:job1")); got != 2 {
+		t.Errorf("ops job session has %d messages, want 2", got)
+	}
+
+	// A chat session captured on another agent is re-scoped to the target agent.
+	_, err = al.ProcessDirectWithOptions(context.Background(), "again", "telegram", "42", tools.DirectRunOptions{
+		AgentID:    "ops",
+		SessionKey: "agent:main:telegram:group:42",
+	})
+	if err != nil {
+		t.Fatalf("ProcessDirectWithOptions failed: %v", err)
+	}
+	if got := len(ops.Sessions.GetHistory("agent:ops:telegram:group:42")); got != 2 {
+		t.Errorf("re-scoped session has %d messages, want 2", got)
+	}
+	if provider.models[1] != "test-model" {
+		t.Errorf("model without override = %q, want test-model", provider.models[1])
+	}
+}
+
+func TestProcessDirectWithOptions_ChatSession(t *testing.T) {
+	provider := &recordingMockProvider{}
+	al := newMultiAgentTestLoop(t, provider)
+	ops, _ := al.registry.GetAgent("ops")
+	ops.Sessions.AddMessage("agent:ops:telegram:group:42", "user", "hello group")
+
+	// A group chat continues its existing session.
+	opts := tools.DirectRunOptions{AgentID: "ops", ChatSession: true}
+	if _, err := al.ProcessDirectWithOptions(context.Background(), "report", "telegram", "42", opts); err != nil {
+		t.Fatalf("ProcessDirectWithOptions failed: %v", err)
+	}
+	if got := len(ops.Sessions.GetHistory("agent:ops:telegram:group:42")); got != 3 {
+		t.Errorf("group session has %d messages, want 3", got)
+	}
+
+	// Other chats use the session a direct message would be routed to.
+	opts = tools.DirectRunOptions{ChatSession: true}
+	if _, err := al.ProcessDirectWithOptions(context.Background(), "report", "telegram", "7", opts); err != nil {
+		t.Fatalf("ProcessDirectWithOptions failed: %v", err)
+	}
+	main := al.registry.GetDefaultAgent()
+	if got := len(main.Sessions.GetHistory("agent:main:main")); got != 2 {
+		t.Errorf("routed direct session has %d messages, want 2", got)
+	}
+}
+
+func TestProcessDirectWithOptions_SendResponse(t *testing.T) {
+	al := newMultiAgentTestLoop(t, &recordingMockProvider{})
+
+	opts := tools.DirectRunOptions{ChatSession: true, SendResponse: true}
+	if _, err := al.ProcessDirectWithOptions(context.Background(), "report", "telegram", "42", opts); err != nil {
+		t.Fatalf("ProcessDirectWithOptions failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out, ok := al.bus.SubscribeOutbound(ctx)
+	if !ok {
+		t.Fatal("reply was not delivered")
+	}
+	if out.Channel != "telegram" || out.ChatID != "42" || out.Content != "done" {
+		t.Errorf("outbound = %+v", out)
+	}
+}
+
+func TestProcessSystemMessage_DeviceEventToNamedAgent(t *testing.T) {
+	provider := &scriptedMockProvider{replies: []string{"A USB serial adapter appeared. Open the console?", ""}}
+	al := newMultiAgentTestLoop(t, provider)
+
+	device := bus.InboundMessage{
+		Channel:  "system",
+		SenderID: "device:usb",
+		ChatID:   "telegram:42",
+		Content:  "🔌 Device Connected\n\nType: usb\nDevice: Silicon Labs CP210x",
+		Metadata: map[string]string{"source": "device", "agent_id": "ops"},
+	}
+	reply, err := al.processMessage(context.Background(), device)
+	if err != nil {
+		t.Fatalf("processMessage failed: %v", err)
+	}
+	if reply != "A USB serial adapter appeared. Open the console?" {
+		t.Errorf("reply = %q", reply)
+	}
+	if !strings.HasPrefix(provider.lastUser, "[System: device:usb] ") {
+		t.Errorf("user message = %q", provider.lastUser)
+	}
+
+	ops, _ := al.registry.GetAgent("ops")
+	if got := len(ops.Sessions.GetHistory("agent:ops:main")); got != 2 {
+		t.Errorf("ops main session has %d messages, want 2", got)
+	}
+	main := al.registry.GetDefaultAgent()
+	if got := len(main.Sessions.GetHistory("agent:main:main")); got != 0 {
+		t.Errorf("default agent session has %d messages, want 0", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out, ok := al.bus.SubscribeOutbound(ctx)
+	if !ok || out.Channel != "telegram" || out.ChatID != "42" || out.Content != reply {
+		t.Fatalf("outbound = %+v, %v", out, ok)
+	}
+
+	// An agent with nothing to say about a device stays quiet.
+	if reply, err := al.processMessage(context.Background(), device); err != nil || reply != "" {
+		t.Errorf("empty reply = %q, %v", reply, err)
+	}
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel2()
+	if out, ok := al.bus.SubscribeOutbound(ctx2); ok {
+		t.Errorf("unexpected outbound message %+v", out)
+	}
+}
+
+func TestProcessDirectWithOptions_UnknownAgent(t *testing.T) {
+	al := newMultiAgentTestLoop(t, &recordingMockProvider{})
+
+	_, err := al.ProcessDirectWithOptions(context.Background(), "hi", "cli", "direct", tools.DirectRunOptions{AgentID: "nobody"})
+	if err == nil {
+		t.Fatal("expected error for unknown agent")
+	}
+}
+
+func TestProcessDirectWithOptions_FreshSession(t *testing.T) {
+	provider := &recordingMockProvider{}
+	al := newMultiAgentTestLoop(t, provider)
+	opts := tools.DirectRunOptions{SessionKey: "cron:job2:run", NoHistory: true}
+
+	for i := 0; i < 2; i++ {
+		if _, err := al.ProcessDirectWithOptions(context.Background(), "hi", "cli", "direct", opts); err != nil {
+			t.Fatalf("ProcessDirectWithOptions failed: %v", err)
+		}
+	}
+
+	if provider.messageCount[0] != provider.messageCount[1] {
+		t.Errorf("fresh runs sent %v messages, want the same count each run", provider.messageCount)
+	}
+	defaultAgent := al.registry.GetDefaultAgent()
+	if got := len(defaultAgent.Sessions.GetHistory("agent:main:cron:job2:run")); got != 2 {
+		t.Errorf("fresh session kept %d messages, want only the last run (2)", got)
+	}
+}
+
+func TestProcessDirectWithOptions_MaxIterations(t *testing.T) {
+	provider := &recordingMockProvider{toolCalls: true}
+	al := newMultiAgentTestLoop(t, provider)
+
+	_, err := al.ProcessDirectWithOptions(context.Background(), "loop", "cli", "direct", tools.DirectRunOptions{MaxIterations: 3})
+	if err != nil {
+		t.Fatalf("ProcessDirectWithOptions failed: %v", err)
+	}
+	if len(provider.models) != 3 {
+		t.Errorf("provider called %d times, want 3", len(provider.models))
+	}
+}
+
+func TestRunLLMIteration_FallbackUsesCandidateProvider(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "anthropic/claude-test",
+				ModelFallbacks:    []string{"groq/llama-test"},
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	// The agent's own provider is a gateway such as OpenRouter.
+	gateway := &failFirstMockProvider{failures: 1, failError: fmt.Errorf("429 rate limit exceeded")}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), gateway)
+
+	anthropic := &recordingMockProvider{}
+	groq := &recordingMockProvider{}
+	al.registry.Providers().Register("anthropic", anthropic)
+	al.registry.Providers().Register("groq", groq)
+
+	resp, err := al.ProcessDirect(context.Background(), "hello", "cli:direct")
+	if err != nil {
+		t.Fatalf("ProcessDirect failed: %v", err)
+	}
+	if resp != "done" {
+		t.Errorf("response = %q, want done", resp)
+	}
+
+	// The primary model stays on the agent's provider with its full name,
+	// even though an anthropic client is configured; fallbacks use theirs.
+	if len(gateway.models) != 1 || gateway.models[0] != "anthropic/claude-test" {
+		t.Errorf("gateway models = %v, want [anthropic/claude-test]", gateway.models)
+	}
+	if len(anthropic.models) != 0 {
+		t.Errorf("anthropic should not be called, got %v", anthropic.models)
+	}
+	if len(groq.models) != 1 || groq.models[0] != "llama-test" {
+		t.Errorf("groq models = %v, want [llama-test]", groq.models)
+	}
+}
+
+func TestNewAgentLoop_PerAgentProvider(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace: tmpDir,
+				Model:     "test-model",
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true},
+				{ID: "fast", Provider: "groq", Workspace: filepath.Join(tmpDir, "fast"), Model: &config.AgentModelConfig{Primary: "llama-test"}},
+				{ID: "broken", Provider: "nvidia", Workspace: filepath.Join(tmpDir, "broken")},
+			},
+		},
+		Providers: config.ProvidersConfig{
+			Groq: config.ProviderConfig{APIKey: "test-key"},
+		},
+	}
+	defaultProvider := &recordingMockProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), defaultProvider)
+
+	fast, _ := al.registry.GetAgent("fast")
+	if _, ok := fast.Provider.(*providers.HTTPProvider); !ok {
+		t.Errorf("fast agent provider = %T, want *providers.HTTPProvider", fast.Provider)
+	}
+	if len(fast.Candidates) != 1 || fast.Candidates[0].Provider != "groq" {
+		t.Errorf("fast candidates = %+v, want groq", fast.Candidates)
+	}
+
+	// An unconfigured provider keeps the default client.
+	broken, _ := al.registry.GetAgent("broken")
+	if broken.Provider != providers.LLMProvider(defaultProvider) {
+		t.Errorf("broken agent provider = %T, want default provider", broken.Provider)
+	}
+}
+
+type contextWindowMockProvider struct {
+	recordingMockProvider
+	lookups atomic.Int32
+}
+
+func (m *contextWindowMockProvider) ContextWindow(ctx context.Context, model string) (int, error) {
+	m.lookups.Add(1)
+	return 32768, nil
+}
+
+func TestNewProviderProber_Targets(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:      tmpDir,
+				Provider:       "openai",
+				Model:          "gpt-4o",
+				ModelFallbacks: []string{"groq/llama-test"},
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true},
+				{ID: "fast", Provider: "groq", Workspace: filepath.Join(tmpDir, "fast"), Model: &config.AgentModelConfig{Primary: "llama-test"}},
+			},
+		},
+		Providers: config.ProvidersConfig{
+			Groq: config.ProviderConfig{APIKey: "test-key"},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &recordingMockProvider{})
+
+	var got []string
+	for _, st := range al.NewProviderProber(time.Second).Status() {
+		got = append(got, st.Provider+"/"+st.Model+":"+string(st.State))
+	}
+	want := []string{"groq/llama-test:closed", "openai/gpt-4o:closed"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("probe targets = %v, want %v", got, want)
+	}
+}
+
+func TestNewAgentLoop_ProviderContextWindow(t *testing.T) {
+	provider := &contextWindowMockProvider{}
+	al := newMultiAgentTestLoop(t, provider)
+	if n := provider.lookups.Load(); n != 0 {
+		t.Errorf("context window looked up %d times at startup, want on first use", n)
+	}
+	for _, id := range []string{"main", "ops"} {
+		agent, _ := al.registry.GetAgent(id)
+		if window := agent.contextWindow(); window != 32768 {
+			t.Errorf("%s context window = %d, want 32768", id, window)
+		}
+		agent.contextWindow()
+	}
+	if n := provider.lookups.Load(); n != 2 {
+		t.Errorf("context window looked up %d times, want once per agent", n)
+	}
+}
+
+type toolOnceMockProvider struct {
+	recordingMockProvider
+}
+
+func (m *toolOnceMockProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts map[string]interface{}) (*providers.LLMResponse, error) {
+	m.models = append(m.models, model)
+	if len(m.models) == 1 {
+		return &providers.LLMResponse{
+			ToolCalls: []providers.ToolCall{{ID: "call-1", Name: "list_dir", Arguments: map[string]interface{}{"path": "."}}},
+		}, nil
+	}
+	return &providers.LLMResponse{Content: "done"}, nil
+}
+
+func TestRunLLMIteration_ModelRouting(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Routing: &config.RoutingConfig{
+					Enabled: true,
+					Tiers: []config.RoutingTierConfig{
+						{Name: "small", Model: "small-model"},
+						{Name: "big", Model: "big-model"},
+					},
+					Rules: []config.RoutingRule{
+						{Tier: "small", MaxChars: 10},
+						{Tier: "big", Tools: []string{"list_dir"}},
+					},
+				},
+			},
+		},
+	}
+	provider := &toolOnceMockProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	if _, err := al.ProcessDirect(context.Background(), "hi", "cli:route"); err != nil {
+		t.Fatalf("ProcessDirect failed: %v", err)
+	}
+	if strings.Join(provider.models, ",") != "small-model,big-model" {
+		t.Errorf("models = %v, want small-model then big-model after the tool call", provider.models)
+	}
+
+	shown, _ := al.handleCommand(context.Background(), bus.InboundMessage{Content: "/show model"})
+	if !strings.Contains(shown, "small: small-model") || !strings.Contains(shown, "Last route: big -> big-model (rule 2 (tool list_dir))") {
+		t.Errorf("/show model = %q", shown)
+	}
+
+	// Longer messages match no rule and use the agent's own model.
+	provider.models = nil
+	if _, err := al.ProcessDirect(context.Background(), "please summarize the news", "cli:route"); err != nil {
+		t.Fatalf("ProcessDirect failed: %v", err)
+	}
+	if len(provider.models) == 0 || provider.models[0] != "test-model" {
+		t.Errorf("models = %v, want test-model first", provider.models)
+	}
+}
+
+type usageMockProvider struct{}
+
+func (m *usageMockProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts map[string]interface{}) (*providers.LLMResponse, error) {
+	return &providers.LLMResponse{
+		Content: "ok",
+		Usage:   &providers.UsageInfo{PromptTokens: 1_000_000, CompletionTokens: 100_000},
+	}, nil
+}
+
+func (m *usageMockProvider) GetDefaultModel() string { return "gpt-4o" }
+
+func TestCostCommand(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Provider:          "openai",
+				Model:             "gpt-4o",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &usageMockProvider{})
+
+	if _, err := al.ProcessDirect(context.Background(), "hello", "cli:cost"); err != nil {
+		t.Fatalf("ProcessDirect failed: %v", err)
+	}
+
+	out, handled := al.handleCommand(context.Background(), bus.InboundMessage{Content: "/cost today"})
+	if !handled {
+		t.Fatal("/cost was not handled")
+	}
+	if !strings.Contains(out, "$3.5000, 1 calls") || !strings.Contains(out, "openai/gpt-4o") {
+		t.Errorf("/cost today = %q", out)
+	}
+}
+
+type thinkingMockProvider struct {
+	calls     int
+	options   map[string]interface{}
+	roundTrip []providers.ThinkingBlock
+}
+
+func (m *thinkingMockProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts map[string]interface{}) (*providers.LLMResponse, error) {
+	m.calls++
+	m.options = opts
+	if m.calls%2 == 1 {
+		return &providers.LLMResponse{
+			Reasoning: "I should look at the files first.",
+			Thinking:  []providers.ThinkingBlock{{Thinking: "I should look at the files first.", Signature: "sig"}},
+			ToolCalls: []providers.ToolCall{{ID: "call-1", Name: "list_dir", Arguments: map[string]interface{}{"path": "."}}},
+		}, nil
+	}
+	for _, msg := range messages {
+		if msg.Role == "assistant" && len(msg.ToolCalls) > 0 {
+			m.roundTrip = msg.Thinking
+		}
+	}
+	return &providers.LLMResponse{Content: "There is one file.", Reasoning: "Just one entry."}, nil
+}
+
+func (m *thinkingMockProvider) GetDefaultModel() string { return "claude-sonnet-4-5" }
+
+func TestReasoning_RoundTripAndThinkCommand(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "claude-sonnet-4-5",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Reasoning:         &config.ReasoningConfig{Effort: "low"},
+			},
+		},
+	}
+	provider := &thinkingMockProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	out, err := al.ProcessDirect(context.Background(), "what's here?", "cli:think")
+	if err != nil {
+		t.Fatalf("ProcessDirect failed: %v", err)
+	}
+	if out != "There is one file." {
+		t.Errorf("reply with /think off = %q", out)
+	}
+	if provider.options["reasoning_effort"] != "low" || provider.options["thinking_budget"] != 2048 {
+		t.Errorf("options = %v", provider.options)
+	}
+	if len(provider.roundTrip) != 1 || provider.roundTrip[0].Signature != "sig" {
+		t.Errorf("thinking sent back = %+v", provider.roundTrip)
+	}
+
+	think := bus.InboundMessage{Channel: "cli", ChatID: "direct", Content: "/think on"}
+	if reply, handled := al.handleCommand(context.Background(), think); !handled || !strings.Contains(reply, "on") {
+		t.Fatalf("/think on = %q, %v", reply, handled)
+	}
+	// Direct callers such as cron and heartbeat get only the answer; the
+	// summary is shown with the reply sent to the chat.
+	out, err = al.ProcessDirect(context.Background(), "and now?", "cli:think")
+	if err != nil {
+		t.Fatalf("ProcessDirect failed: %v", err)
+	}
+	if out != "There is one file." {
+		t.Errorf("direct reply with /think on = %q", out)
+	}
+	var reasoning string
+	msg := bus.InboundMessage{Channel: "cli", ChatID: "direct", Content: "and now?", SessionKey: "cli:think"}
+	if out, err = al.handleInbound(context.Background(), msg, &reasoning); err != nil {
+		t.Fatalf("handleInbound failed: %v", err)
+	}
+	if want := "> 💭 I should look at the files first. Just one entry."; out != "There is one file." || reasoning != want {
+		t.Errorf("reply with /think on = %q, reasoning %q, want %q", out, reasoning, want)
+	}
+
+	// The session keeps only the answer, not the reasoning summary.
+	agent := al.registry.GetDefaultAgent()
+	history := agent.Sessions.GetHistory("agent:main:main")
+	if last := history[len(history)-1]; last.Content != "There is one file." || last.Thinking != nil {
+		t.Errorf("last session message = %+v", last)
+	}
+}
+
+func TestThinkCommand_StatusUsesRoutedAgent(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true},
+				{ID: "ops", Reasoning: &config.ReasoningConfig{Effort: "low", Show: true}},
+			},
+		},
+		Bindings: []config.AgentBinding{{AgentID: "ops", Match: config.BindingMatch{Channel: "slack"}}},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+
+	if reply := al.thinkCommand(bus.InboundMessage{Channel: "slack", ChatID: "C1"}, nil); !strings.Contains(reply, "are on") {
+		t.Errorf("/think on the ops channel = %q", reply)
+	}
+	if reply := al.thinkCommand(bus.InboundMessage{Channel: "telegram", ChatID: "1"}, nil); !strings.Contains(reply, "are off") {
+		t.Errorf("/think on the default agent = %q", reply)
+	}
+}
+
+func TestVoiceReply_ModesAndCommand(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+
+	spoken := bus.InboundMessage{Channel: "telegram", ChatID: "1", Content: "hi", Metadata: map[string]string{"voice": "true"}}
+	typed := bus.InboundMessage{Channel: "telegram", ChatID: "1", Content: "hi"}
+
+	// Without a TTS backend replies stay text and /voice says so.
+	if al.voiceReply(spoken) {
+		t.Error("voice reply without a TTS backend")
+	}
+	if reply := al.voiceCommand(typed, []string{"auto"}); !strings.Contains(reply, "not set up") {
+		t.Errorf("/voice auto without TTS = %q", reply)
+	}
+
+	cfg.Voice.TTS = config.TTSConfig{Backend: "openai", Reply: "auto"}
+	al = NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	if !al.voiceReply(spoken) || al.voiceReply(typed) {
+		t.Error("auto mode should answer only voice messages with voice")
+	}
+	internal := spoken
+	internal.Channel = "system"
+	if al.voiceReply(internal) {
+		t.Error("voice reply on an internal channel")
+	}
+
+	if reply, handled := al.handleCommand(context.Background(), bus.InboundMessage{Channel: "telegram", ChatID: "1", Content: "/voice always"}); !handled || !strings.Contains(reply, "every message") {
+		t.Fatalf("/voice always = %q, %v", reply, handled)
+	}
+	if !al.voiceReply(typed) {
+		t.Error("always mode should answer typed messages with voice")
+	}
+	if reply := al.voiceCommand(typed, nil); !strings.Contains(reply, "always") {
+		t.Errorf("/voice = %q", reply)
+	}
+	// The setting is per chat.
+	other := spoken
+	other.ChatID = "2"
+	other.Metadata = nil
+	if al.voiceReply(other) {
+		t.Error("/voice always leaked into another chat")
+	}
+
+	al.voiceCommand(typed, []string{"off"})
+	if al.voiceReply(spoken) {
+		t.Error("voice reply after /voice off")
+	}
+	if reply := al.voiceCommand(typed, []string{"loud"}); !strings.HasPrefix(reply, "Usage") {
+		t.Errorf("/voice loud = %q", reply)
+	}
+}
+
+type scriptedMockProvider struct {
+	replies  []string
+	calls    int
+	options  map[string]interface{}
+	lastUser string
+}
+
+func (m *scriptedMockProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts map[string]interface{}) (*providers.LLMResponse, error) {
+	m.options = opts
+	m.lastUser = messages[len(messages)-1].Content
+	reply := m.replies[len(m.replies)-1]
+	if m.calls < len(m.replies) {
+		reply = m.replies[m.calls]
+	}
+	m.calls++
+	return &providers.LLMResponse{Content: reply}, nil
+}
+
+func (m *scriptedMockProvider) GetDefaultModel() string { return "test-model" }
+
+func TestProcessDirectWithOptions_ResponseFormat(t *testing.T) {
+	rf, err := providers.NewResponseFormat([]byte(`{
+		"type": "object",
+		"properties": {"answer": {"type": "integer"}},
+		"required": ["answer"]
+	}`), "answer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+
+	provider := &scriptedMockProvider{replies: []string{"It's 42.", "```json\n{\"answer\": 42}\n```"}}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	out, err := al.ProcessDirectWithOptions(context.Background(), "What is 6*7?", "cli", "direct", tools.DirectRunOptions{ResponseFormat: rf})
+	if err != nil {
+		t.Fatalf("ProcessDirectWithOptions failed: %v", err)
+	}
+	if out != `{"answer": 42}` {
+		t.Errorf("reply = %q", out)
+	}
+	if provider.calls != 2 || provider.options["response_format"] != rf {
+		t.Errorf("calls = %d, options = %v", provider.calls, provider.options)
+	}
+	if !strings.Contains(provider.lastUser, "does not match the required JSON Schema") {
+		t.Errorf("correction prompt = %q", provider.lastUser)
+	}
+
+	// Give up after the retries are used.
+	provider = &scriptedMockProvider{replies: []string{`{"answer": "forty-two"}`}}
+	al = NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	_, err = al.ProcessDirectWithOptions(context.Background(), "What is 6*7?", "cli", "direct", tools.DirectRunOptions{ResponseFormat: rf})
+	if err == nil || !strings.Contains(err.Error(), "does not match the response schema") {
+		t.Errorf("error = %v", err)
+	}
+	if provider.calls != 1+maxStructuredRetries {
+		t.Errorf("calls = %d, want %d", provider.calls, 1+maxStructuredRetries)
+	}
+}
+
+// writeFileProvider asks for one write_file call into its workspace, then
+// answers.
+type writeFileProvider struct {
+	workspace string
+	calls     int
+}
+
+func (m *writeFileProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts map[string]interface{}) (*providers.LLMResponse, error) {
+	m.calls++
+	if m.calls == 1 {
+		return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID:        "call_1",
+			Type:      "function",
+			Name:      "write_file",
+			Arguments: map[string]interface{}{"path": filepath.Join(m.workspace, "note.txt"), "content": "hi"},
+		}}}, nil
+	}
+	return &providers.LLMResponse{Content: "Saved the note."}, nil
+}
+
+func (m *writeFileProvider) GetDefaultModel() string { return "test-model" }
+
+func TestRecordReplay_AgentRun(t *testing.T) {
+	newConfig := func(workspace string) *config.Config {
+		return &config.Config{
+			Agents: config.AgentsConfig{
+				Defaults: config.AgentDefaults{
+					Workspace:           workspace,
+					Model:               "test-model",
+					MaxTokens:           4096,
+					MaxToolIterations:   10,
+					RestrictToWorkspace: true,
+				},
+			},
+		}
+	}
+	cassettePath := filepath.Join(t.TempDir(), "note.cassette.json")
+	opts := tools.DirectRunOptions{NoHistory: true}
+
+	recordWS := t.TempDir()
+	cassette := providers.NewCassette(cassettePath, providers.CassetteOptions{Paths: map[string]string{recordWS: "<workspace>"}})
+	inner := &writeFileProvider{workspace: recordWS}
+	al := NewAgentLoop(newConfig(recordWS), bus.NewMessageBus(), providers.NewRecordProvider(inner, "", cassette))
+	recorded, err := al.ProcessDirectWithOptions(context.Background(), "Save a note", "cli", "direct", opts)
+	if err != nil {
+		t.Fatalf("recording run failed: %v", err)
+	}
+	if cassette.Len() != 2 {
+		t.Fatalf("recorded %d calls, want 2", cassette.Len())
+	}
+
+	replayWS := t.TempDir()
+	loaded, err := providers.LoadCassette(cassettePath, providers.CassetteOptions{Paths: map[string]string{replayWS: "<workspace>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	al = NewAgentLoop(newConfig(replayWS), bus.NewMessageBus(), providers.NewReplayProvider(loaded, "test-model"))
+	replayed, err := al.ProcessDirectWithOptions(context.Background(), "Save a note", "cli", "direct", opts)
+	if err != nil {
+		t.Fatalf("replay run failed: %v", err)
+	}
+	if replayed != recorded || loaded.Unused() != 0 {
+		t.Errorf("replay = %q (unused %d), recorded %q", replayed, loaded.Unused(), recorded)
+	}
+	if data, err := os.ReadFile(filepath.Join(replayWS, "note.txt")); err != nil || string(data) != "hi" {
+		t.Errorf("replayed tool call did not write the replay workspace: %q, %v", data, err)
+	}
+
+	// A different prompt is not in the cassette.
+	loaded, _ = providers.LoadCassette(cassettePath, providers.CassetteOptions{})
+	al = NewAgentLoop(newConfig(t.TempDir()), bus.NewMessageBus(), providers.NewReplayProvider(loaded, "test-model"))
+	_, err = al.ProcessDirectWithOptions(context.Background(), "Delete the note", "cli", "direct", opts)
+	if err == nil || !strings.Contains(err.Error(), "replay mismatch") {
+		t.Errorf("error = %v, want a replay mismatch", err)
+	}
+}
+
+func TestBuildMessages_StableSystemPrefix(t *testing.T) {
+	workspace := t.TempDir()
+	registry := tools.NewToolRegistry()
+	registry.Register(tools.NewWriteFileTool(workspace, true))
+	registry.Register(tools.NewReadFileTool(workspace, true))
+	registry.Register(tools.NewListDirTool(workspace, true))
+	cb := NewContextBuilder(workspace)
+	cb.SetToolsRegistry(registry)
+
+	first := cb.BuildMessages(nil, "", "hi", nil, "telegram", "42")
+	second := cb.BuildMessages(nil, "earlier we talked about cats", "hello", nil, "discord", "7")
+
+	if len(first) != 3 || first[0].Role != "system" || first[1].Role != "system" {
+		t.Fatalf("messages = %+v, want stable system, turn context, user", first)
+	}
+	if first[0].Content != second[0].Content {
+		t.Error("stable system prompt differs between turns")
+	}
+	if strings.Contains(first[0].Content, "Current Time") || strings.Contains(first[0].Content, "Chat ID") {
+		t.Error("per-turn context leaked into the stable system prompt")
+	}
+	if !strings.Contains(second[1].Content, "Chat ID: 7") || !strings.Contains(second[1].Content, "cats") {
+		t.Errorf("turn context = %q", second[1].Content)
+	}
+
+	prompt := first[0].Content
+	if !(strings.Index(prompt, "`list_dir`") < strings.Index(prompt, "`read_file`") &&
+		strings.Index(prompt, "`read_file`") < strings.Index(prompt, "`write_file`")) {
+		t.Error("tool summaries should be sorted by name")
+	}
+}
+
+type snapshotMockTool struct{}
+
+func (m *snapshotMockTool) Name() string        { return "snapshot" }
+func (m *snapshotMockTool) Description() string { return "Takes a picture" }
+func (m *snapshotMockTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+
+func (m *snapshotMockTool) Execute(ctx context.Context, args map[string]interface{}) *tools.ToolResult {
+	return tools.SilentResult("Saved camera/still.jpg").WithMedia("/tmp/still.jpg")
+}
+
+type mediaMockProvider struct {
+	calls [][]providers.Message
+}
+
+func (m *mediaMockProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts map[string]interface{}) (*providers.LLMResponse, error) {
+	m.calls = append(m.calls, messages)
+	switch len(m.calls) {
+	case 1:
+		return &providers.LLMResponse{
+			ToolCalls: []providers.ToolCall{{ID: "call-1", Name: "snapshot", Arguments: map[string]interface{}{}}},
+		}, nil
+	case 2:
+		return &providers.LLMResponse{
+			ToolCalls: []providers.ToolCall{{ID: "call-2", Name: "list_dir", Arguments: map[string]interface{}{"path": "."}}},
+		}, nil
+	}
+	return &providers.LLMResponse{Content: "I see a cat"}, nil
+}
+
+func (m *mediaMockProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestRunLLMIteration_AttachesToolMedia(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &mediaMockProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	al.RegisterTool(&snapshotMockTool{})
+
+	response, err := al.ProcessDirect(context.Background(), "what do you see?", "cli:media")
+	if err != nil {
+		t.Fatalf("ProcessDirect failed: %v", err)
+	}
+	if response != "I see a cat" || len(provider.calls) != 3 {
+		t.Fatalf("response = %q after %d calls", response, len(provider.calls))
+	}
+
+	messages := provider.calls[1]
+	last := messages[len(messages)-1]
+	if last.Role != "user" || len(last.Media) != 1 || last.Media[0] != "/tmp/still.jpg" {
+		t.Errorf("last message = %+v, want a user turn with the still attached", last)
+	}
+	if prev := messages[len(messages)-2]; prev.Role != "tool" || prev.ToolCallID != "call-1" {
+		t.Errorf("message before the attachment = %+v, want the tool result", prev)
+	}
+
+	// The still goes with the request after the snapshot only.
+	for _, m := range provider.calls[2] {
+		if len(m.Media) > 0 || strings.HasPrefix(m.Content, "Attached: ") {
+			t.Errorf("attachment sent again in a later request: %+v", m)
+		}
+	}
+
+	agent := al.registry.GetDefaultAgent()
+	for _, m := range agent.Sessions.GetHistory("cli:media") {
+		if len(m.Media) > 0 || strings.HasPrefix(m.Content, "Attached: ") {
+			t.Errorf("attachment saved to session: %+v", m)
+		}
+	}
+}
+
+func TestWithReasoning_OnlyForReasoningModels(t *testing.T) {
+	base := map[string]interface{}{"max_tokens": 1024, "temperature": 0.7}
+	r := &config.ReasoningConfig{Effort: "low", BudgetTokens: 2048}
+
+	opts := withReasoning(base, r, "o3-mini")
+	if opts["reasoning_effort"] != "low" || opts["thinking_budget"] != 2048 || opts["max_tokens"] != 1024 {
+		t.Errorf("options for o3-mini = %v", opts)
+	}
+	if _, ok := base["reasoning_effort"]; ok {
+		t.Error("base options were modified")
+	}
+	if opts := withReasoning(base, r, "llama-3.3-70b-versatile"); opts["reasoning_effort"] != nil || opts["temperature"] != 0.7 {
+		t.Errorf("options for a fallback without reasoning = %v", opts)
+	}
+	if opts := withReasoning(base, nil, "o3-mini"); opts["reasoning_effort"] != nil {
+		t.Errorf("options without reasoning configured = %v", opts)
+	}
+}
+
+func TestChannelImageReachesProvider(t *testing.T) {
+	var imageURLs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Content json.RawMessage `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, m := range body.Messages {
+			var parts []map[string]interface{}
+			if json.Unmarshal(m.Content, &parts) != nil {
+				continue
+			}
+			for _, p := range parts {
+				if img, ok := p["image_url"].(map[string]interface{}); ok {
+					imageURLs = append(imageURLs, img["url"].(string))
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"content":"a cat"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{Workspace: workspace, Model: "gpt-4o", MaxTokens: 4096, MaxToolIterations: 10},
+		},
+	}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, providers.NewHTTPProvider("key", server.URL, ""))
+
+	// The channel deletes its download as soon as HandleMessage returns.
+	photo := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(photo, []byte("jpeg bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ch := channels.NewBaseChannel("telegram", nil, msgBus, nil)
+	ch.HandleMessage("7", "42", "[image: photo]", []string{photo}, nil)
+	os.Remove(photo)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	if _, err := al.processMessage(ctx, msg); err != nil {
+		t.Fatalf("processMessage failed: %v", err)
+	}
+
+	want := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString([]byte("jpeg bytes"))
+	if len(imageURLs) != 1 || imageURLs[0] != want {
+		t.Errorf("images sent to the provider = %v, want the photo", imageURLs)
+	}
+}
@@ -1,7 +1,9 @@
 package agent
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/logger"
@@ -11,9 +13,10 @@ import (
 
 // AgentRegistry manages multiple agent instances and routes messages to them.
 type AgentRegistry struct {
-	agents   map[string]*AgentInstance
-	resolver *routing.RouteResolver
-	mu       sync.RWMutex
+	agents    map[string]*AgentInstance
+	resolver  *routing.RouteResolver
+	providers *providers.ProviderRegistry
+	mu        sync.RWMutex
 }
 
 // NewAgentRegistry creates a registry from config, instantiating all agents.
@@ -22,8 +25,9 @@ func NewAgentRegistry(
 	provider providers.LLMProvider,
 ) *AgentRegistry {
 	registry := &AgentRegistry{
-		agents:   make(map[string]*AgentInstance),
-		resolver: routing.NewRouteResolver(cfg),
+		agents:    make(map[string]*AgentInstance),
+		resolver:  routing.NewRouteResolver(cfg),
+		providers: providers.NewProviderRegistry(cfg, provider),
 	}
 
 	agentConfigs := cfg.Agents.List
@@ -40,6 +44,18 @@ func NewAgentRegistry(
 			ac := &agentConfigs[i]
 			id := routing.NormalizeAgentID(ac.ID)
 			instance := NewAgentInstance(ac, &cfg.Agents.Defaults, cfg, provider)
+			if ac.Provider != "" {
+				if p, err := registry.providers.Get(ac.Provider); err != nil {
+					logger.WarnCF("agent", "Agent provider unavailable, using default provider",
+						map[string]interface{}{
+							"agent_id": id,
+							"provider": ac.Provider,
+							"error":    err.Error(),
+						})
+				} else {
+					instance.Provider = p
+				}
+			}
 			registry.agents[id] = instance
 			logger.InfoCF("agent", "Registered agent",
 				map[string]interface{}{
@@ -54,6 +70,41 @@ func NewAgentRegistry(
 	return registry
 }
 
+// applyProviderContextWindow asks providers that know their models' context
+// size (e.g. Ollama via /api/show) and uses it instead of the max_tokens guess.
+// It is called through AgentInstance.contextWindow on first use.
+func applyProviderContextWindow(instance *AgentInstance) {
+	cwp, ok := instance.Provider.(providers.ContextWindowProvider)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	window, err := cwp.ContextWindow(ctx, instance.Model)
+	if err != nil {
+		logger.WarnCF("agent", "Could not read model context window",
+			map[string]interface{}{
+				"agent_id": instance.ID,
+				"model":    instance.Model,
+				"error":    err.Error(),
+			})
+		return
+	}
+	instance.ContextWindow = window
+	logger.InfoCF("agent", "Using provider-reported context window",
+		map[string]interface{}{
+			"agent_id":       instance.ID,
+			"model":          instance.Model,
+			"context_window": window,
+		})
+}
+
+// Providers returns the registry of LLM clients shared by all agents.
+func (r *AgentRegistry) Providers() *providers.ProviderRegistry {
+	return r.providers
+}
+
 // GetAgent returns the agent instance for a given ID.
 func (r *AgentRegistry) GetAgent(agentID string) (*AgentInstance, bool) {
 	r.mu.RLock()
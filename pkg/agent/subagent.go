@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// subagentRunner returns the runner for tasks spawned by parent. A task runs
+// as the agent it was given to: with that agent's model and fallbacks,
+// tools, workspace, skills and identity files. parent's subagents.model,
+// when set, replaces the model and fallbacks.
+func (al *AgentLoop) subagentRunner(parent *AgentInstance) tools.SubagentRunner {
+	return func(ctx context.Context, task *tools.SubagentTask, progress func(*tools.ToolLoopResult)) (*tools.ToolLoopResult, error) {
+		agent := parent
+		if task.AgentID != "" {
+			a, ok := al.registry.GetAgent(task.AgentID)
+			if !ok {
+				return nil, fmt.Errorf("agent %q not found", task.AgentID)
+			}
+			agent = a
+		}
+
+		var usage providers.UsageInfo
+		opts := processOptions{
+			SessionKey:      fmt.Sprintf("agent:%s:subagent:%s", agent.ID, task.ID),
+			Channel:         task.OriginChannel,
+			ChatID:          task.OriginChatID,
+			UserMessage:     subagentMessage(parent, agent, task.Task),
+			DefaultResponse: "I've completed processing but have no response to give.",
+			NoHistory:       true,
+			MaxIterations:   task.MaxIterations,
+			Usage:           &usage,
+			// The task runs beside the agent's own turns, so it gets its
+			// own copies of tools that track the current chat and session.
+			Tools: agent.Tools.Clone(),
+		}
+		opts.OnIteration = func(iteration int) {
+			progress(&tools.ToolLoopResult{
+				Iterations: iteration,
+				Usage:      usage,
+				Transcript: agent.Sessions.GetHistory(opts.SessionKey),
+			})
+		}
+		if sub := parent.Subagents; sub != nil && sub.Model != nil && sub.Model.Primary != "" {
+			opts.Model = sub.Model.Primary
+			opts.Candidates = providers.ResolveCandidates(providers.ModelConfig{
+				Primary:   sub.Model.Primary,
+				Fallbacks: sub.Model.Fallbacks,
+			}, agent.ProviderName)
+		}
+
+		// The transcript is kept in the task record, not as a session.
+		content, iterations, err := al.runAgentTurn(ctx, agent, opts)
+		result := &tools.ToolLoopResult{
+			Content:    content,
+			Iterations: iterations,
+			Usage:      usage,
+			Transcript: agent.Sessions.GetHistory(opts.SessionKey),
+		}
+		agent.Sessions.Delete(opts.SessionKey)
+		return result, err
+	}
+}
+
+// subagentMessage wraps a task for the agent running it.
+func subagentMessage(parent, agent *AgentInstance, task string) string {
+	from := "yourself"
+	if parent != agent {
+		from = fmt.Sprintf("agent %q", parent.ID)
+	}
+	return fmt.Sprintf("[Subagent task delegated by %s]\n"+
+		"Complete the task below independently, using your tools as needed. "+
+		"Your final answer is reported back, so end with a clear summary of the result.\n\n%s", from, task)
+}
+
+// subagentLimits returns the limits for tasks an agent spawns.
+func subagentLimits(cfg *config.SubagentsConfig) tools.SubagentLimits {
+	if cfg == nil {
+		return tools.SubagentLimits{}
+	}
+	return tools.SubagentLimits{
+		MaxConcurrent: cfg.MaxConcurrent,
+		Timeout:       time.Duration(cfg.TimeoutSeconds) * time.Second,
+		MaxIterations: cfg.MaxIterations,
+	}
+}
+
+// chatTask returns a stored task if it was spawned from the chat of msg.
+// The CLI and other internal channels see every task.
+func (al *AgentLoop) chatTask(msg bus.InboundMessage, id string) (*tools.SubagentTask, error) {
+	task, err := al.subagentStore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !constants.IsInternalChannel(msg.Channel) &&
+		(task.OriginChannel != msg.Channel || task.OriginChatID != msg.ChatID) {
+		return nil, fmt.Errorf("task %s not found", id)
+	}
+	return task, nil
+}
+
+// tasksCommand lists the subagent tasks spawned from the chat: "/tasks".
+func (al *AgentLoop) tasksCommand(msg bus.InboundMessage) string {
+	all, err := al.subagentStore.List()
+	if err != nil {
+		return fmt.Sprintf("Failed to list tasks: %v", err)
+	}
+	var tasks []*tools.SubagentTask
+	for _, task := range all {
+		if constants.IsInternalChannel(msg.Channel) ||
+			(task.OriginChannel == msg.Channel && task.OriginChatID == msg.ChatID) {
+			tasks = append(tasks, task)
+		}
+	}
+	return tools.FormatSubagentTasks(tasks, 10)
+}
+
+// taskCommand shows one task: "/task <id> [transcript]".
+func (al *AgentLoop) taskCommand(msg bus.InboundMessage, args []string) string {
+	if len(args) < 1 || (len(args) > 1 && args[1] != "transcript") {
+		return "Usage: /task <id> [transcript]"
+	}
+	task, err := al.chatTask(msg, args[0])
+	if err != nil {
+		return err.Error()
+	}
+	return tools.FormatSubagentTask(task, len(args) > 1)
+}
+
+// cancelCommand stops a running task: "/cancel <id>".
+func (al *AgentLoop) cancelCommand(msg bus.InboundMessage, args []string) string {
+	if len(args) != 1 {
+		return "Usage: /cancel <id>"
+	}
+	task, err := al.chatTask(msg, args[0])
+	if err != nil {
+		return err.Error()
+	}
+	manager, ok := al.subagents[task.ParentAgentID]
+	if !ok {
+		return fmt.Sprintf("Task %s is not running", task.ID)
+	}
+	if err := manager.Cancel(task.ID); err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("Cancelled %s.", task.ID)
+}
@@ -0,0 +1,282 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// subagentCall is one LLM request seen by subagentMockProvider.
+type subagentCall struct {
+	model  string
+	system string
+	user   string
+	tools  []string
+}
+
+type subagentMockProvider struct {
+	mu    sync.Mutex
+	calls []subagentCall
+}
+
+func (m *subagentMockProvider) Chat(ctx context.Context, messages []providers.Message, defs []providers.ToolDefinition, model string, opts map[string]interface{}) (*providers.LLMResponse, error) {
+	call := subagentCall{model: model, system: messages[0].Content, user: messages[len(messages)-1].Content}
+	for _, d := range defs {
+		call.tools = append(call.tools, d.Function.Name)
+	}
+	m.mu.Lock()
+	m.calls = append(m.calls, call)
+	m.mu.Unlock()
+	return &providers.LLMResponse{
+		Content: "findings",
+		Usage:   &providers.UsageInfo{PromptTokens: 200, CompletionTokens: 30, TotalTokens: 230},
+	}, nil
+}
+
+func (m *subagentMockProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func newSubagentTestLoop(t *testing.T, provider providers.LLMProvider, subagentModel *config.AgentModelConfig) (*AgentLoop, *bus.MessageBus) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	researcherWorkspace := filepath.Join(tmpDir, "researcher")
+	if err := os.MkdirAll(researcherWorkspace, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(researcherWorkspace, "IDENTITY.md"), []byte("I am the research assistant."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "main-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true, Subagents: &config.SubagentsConfig{AllowAgents: []string{"researcher"}, Model: subagentModel}},
+				{ID: "researcher", Workspace: researcherWorkspace, Model: &config.AgentModelConfig{Primary: "research-model"}},
+			},
+		},
+	}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, provider)
+	researcher, _ := al.registry.GetAgent("researcher")
+	researcher.Tools.Register(&mockCustomTool{})
+	return al, msgBus
+}
+
+// spawnAndWait spawns task from the main agent and waits for its announcement.
+func spawnAndWait(t *testing.T, al *AgentLoop, msgBus *bus.MessageBus, args map[string]interface{}) bus.InboundMessage {
+	t.Helper()
+	main, _ := al.registry.GetAgent("main")
+	spawn, ok := main.Tools.Get("spawn")
+	if !ok {
+		t.Fatal("spawn tool not registered")
+	}
+	spawn.(tools.ContextualTool).SetContext("telegram", "42")
+	if result := spawn.Execute(context.Background(), args); result.IsError {
+		t.Fatalf("spawn failed: %s", result.ForLLM)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("subagent did not report back")
+	}
+	return msg
+}
+
+func TestSubagent_RunsAsTargetAgent(t *testing.T) {
+	provider := &subagentMockProvider{}
+	al, msgBus := newSubagentTestLoop(t, provider, nil)
+
+	msg := spawnAndWait(t, al, msgBus, map[string]interface{}{
+		"task":     "find the datasheet of the BME280",
+		"agent_id": "researcher",
+	})
+	if msg.Channel != "system" || msg.ChatID != "telegram:42" || !strings.Contains(msg.Content, "findings") {
+		t.Errorf("announcement = %+v", msg)
+	}
+
+	if len(provider.calls) != 1 {
+		t.Fatalf("provider called %d times, want 1", len(provider.calls))
+	}
+	call := provider.calls[0]
+	if call.model != "research-model" {
+		t.Errorf("model = %q, want research-model", call.model)
+	}
+	if !strings.Contains(call.system, "I am the research assistant.") {
+		t.Error("system prompt does not include the researcher's identity file")
+	}
+	if !strings.Contains(call.user, "BME280") || !strings.Contains(call.user, `agent "main"`) {
+		t.Errorf("task message = %q", call.user)
+	}
+	hasTool := false
+	for _, name := range call.tools {
+		hasTool = hasTool || name == "mock_custom"
+	}
+	if !hasTool {
+		t.Errorf("tools = %v, want the researcher's tools", call.tools)
+	}
+}
+
+func TestSubagent_ModelOverride(t *testing.T) {
+	provider := &subagentMockProvider{}
+	al, msgBus := newSubagentTestLoop(t, provider, &config.AgentModelConfig{Primary: "cheap-model"})
+
+	spawnAndWait(t, al, msgBus, map[string]interface{}{"task": "summarize", "agent_id": "researcher"})
+	spawnAndWait(t, al, msgBus, map[string]interface{}{"task": "summarize"})
+
+	if len(provider.calls) != 2 {
+		t.Fatalf("provider called %d times, want 2", len(provider.calls))
+	}
+	for _, call := range provider.calls {
+		if call.model != "cheap-model" {
+			t.Errorf("model = %q, want cheap-model", call.model)
+		}
+	}
+	if strings.Contains(provider.calls[1].system, "research assistant") || !strings.Contains(provider.calls[1].user, "yourself") {
+		t.Errorf("task without agent_id should run as the main agent: %q", provider.calls[1].user)
+	}
+}
+
+func TestSubagent_TaskCommands(t *testing.T) {
+	provider := &subagentMockProvider{}
+	al, msgBus := newSubagentTestLoop(t, provider, nil)
+
+	spawnAndWait(t, al, msgBus, map[string]interface{}{
+		"task":     "find the datasheet of the BME280",
+		"label":    "datasheet",
+		"agent_id": "researcher",
+	})
+
+	task, err := al.subagentStore.Get("subagent-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != tools.SubagentCompleted || task.ParentAgentID != "main" || task.Iterations != 1 ||
+		task.Usage.PromptTokens != 200 || len(task.Transcript) != 2 {
+		t.Errorf("stored task = %+v", task)
+	}
+	researcher, _ := al.registry.GetAgent("researcher")
+	if history := researcher.Sessions.GetHistory("agent:researcher:subagent:subagent-1"); len(history) != 0 {
+		t.Errorf("subagent session was kept with %d messages", len(history))
+	}
+
+	ctx := context.Background()
+	chat := bus.InboundMessage{Channel: "telegram", ChatID: "42"}
+	run := func(msg bus.InboundMessage, content string) string {
+		msg.Content = content
+		out, handled := al.handleCommand(ctx, msg)
+		if !handled {
+			t.Fatalf("%s was not handled", content)
+		}
+		return out
+	}
+
+	if out := run(chat, "/tasks"); !strings.Contains(out, "subagent-1  completed") || !strings.Contains(out, "datasheet") {
+		t.Errorf("/tasks = %q", out)
+	}
+	if out := run(chat, "/task 1 transcript"); !strings.Contains(out, "Tokens: 230") || !strings.Contains(out, "[assistant] findings") {
+		t.Errorf("/task = %q", out)
+	}
+	if out := run(chat, "/cancel subagent-1"); !strings.Contains(out, "not running") {
+		t.Errorf("/cancel = %q", out)
+	}
+
+	// Other chats do not see the task.
+	other := bus.InboundMessage{Channel: "telegram", ChatID: "7"}
+	if out := run(other, "/tasks"); out != "No subagent tasks." {
+		t.Errorf("/tasks from another chat = %q", out)
+	}
+	if out := run(other, "/task subagent-1"); !strings.Contains(out, "not found") {
+		t.Errorf("/task from another chat = %q", out)
+	}
+}
+
+// concurrentSubagentProvider holds the subagent's first call until release
+// is closed, then has it send a message before answering.
+type concurrentSubagentProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *concurrentSubagentProvider) Chat(ctx context.Context, messages []providers.Message, defs []providers.ToolDefinition, model string, opts map[string]interface{}) (*providers.LLMResponse, error) {
+	last := messages[len(messages)-1]
+	if last.Role == "tool" {
+		return &providers.LLMResponse{Content: "done"}, nil
+	}
+	if !strings.Contains(last.Content, "[Subagent task") {
+		return &providers.LLMResponse{Content: "main reply"}, nil
+	}
+	close(m.started)
+	<-m.release
+	return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+		ID:        "call_1",
+		Name:      "message",
+		Arguments: map[string]interface{}{"content": "progress"},
+	}}}, nil
+}
+
+func (m *concurrentSubagentProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestSubagent_RunsBesideAgentTurn(t *testing.T) {
+	provider := &concurrentSubagentProvider{started: make(chan struct{}), release: make(chan struct{})}
+	al, msgBus := newSubagentTestLoop(t, provider, nil)
+	main, _ := al.registry.GetAgent("main")
+
+	spawn, _ := main.Tools.Get("spawn")
+	spawn.(tools.ContextualTool).SetContext("telegram", "42")
+	if result := spawn.Execute(context.Background(), map[string]interface{}{"task": "watch the sensor"}); result.IsError {
+		t.Fatalf("spawn failed: %s", result.ForLLM)
+	}
+	<-provider.started
+
+	// The agent handles a message from another chat while the task runs.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := al.processMessage(ctx, bus.InboundMessage{
+		Channel: "telegram", SenderID: "u1", ChatID: "7", Content: "hello", SessionKey: "chat-7",
+	})
+	if err != nil || reply != "main reply" {
+		t.Fatalf("processMessage = %q, %v", reply, err)
+	}
+	close(provider.release)
+
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || out.ChatID != "42" || out.Content != "progress" {
+		t.Errorf("subagent message = %+v, want it sent to chat 42", out)
+	}
+	if _, ok := msgBus.ConsumeInbound(ctx); !ok {
+		t.Fatal("subagent did not report back")
+	}
+
+	// The agent's own tools still point at the chat it handled.
+	message, _ := main.Tools.Get("message")
+	if message.(*tools.MessageTool).HasSentInRound() {
+		t.Error("subagent send was counted in the agent's round")
+	}
+	result := message.Execute(ctx, map[string]interface{}{"content": "hi"})
+	if result.IsError {
+		t.Fatalf("message failed: %s", result.ForLLM)
+	}
+	if out, _ := msgBus.SubscribeOutbound(ctx); out.ChatID != "7" {
+		t.Errorf("agent message went to chat %q, want 7", out.ChatID)
+	}
+}
@@ -0,0 +1,340 @@
+package automation
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/cron"
+)
+
+// Trigger kinds.
+const (
+	TriggerTime    = "time"
+	TriggerDevice  = "device"
+	TriggerPerson  = "person"
+	TriggerFile    = "file"
+	TriggerMessage = "message"
+)
+
+// Action kinds.
+const (
+	ActionAgent   = "agent"
+	ActionMessage = "message"
+	ActionCommand = "command"
+)
+
+// File change types reported by the workspace watcher.
+const (
+	FileCreated  = "created"
+	FileModified = "modified"
+	FileDeleted  = "deleted"
+)
+
+// Trigger describes what starts a rule. Only the fields relevant to Kind are used.
+type Trigger struct {
+	Kind     string             `json:"kind"`
+	Schedule *cron.CronSchedule `json:"schedule,omitempty"` // time
+	Device   string             `json:"device,omitempty"`   // device: kind filter, e.g. "usb"
+	On       string             `json:"on,omitempty"`       // device: add/remove; file: created/modified/deleted
+	Match    string             `json:"match,omitempty"`    // device: vendor/product substring; message: regexp
+	Path     string             `json:"path,omitempty"`     // file: glob relative to the workspace
+	Channel  string             `json:"channel,omitempty"`  // message: only messages from this channel
+	MinScore float64            `json:"minScore,omitempty"` // person: minimum detection confidence (0-1)
+}
+
+// TimeWindow limits a rule to a daily time range. Start may be after End
+// for windows that cross midnight.
+type TimeWindow struct {
+	Start string   `json:"start"`          // HH:MM
+	End   string   `json:"end"`            // HH:MM
+	Days  []string `json:"days,omitempty"` // mon..sun; empty means every day
+}
+
+// RateLimit caps how often a rule may fire.
+type RateLimit struct {
+	Max       int   `json:"max"`
+	PeriodSec int64 `json:"periodSec"`
+}
+
+// Conditions must all hold for a triggered rule to fire.
+type Conditions struct {
+	TimeWindow *TimeWindow `json:"timeWindow,omitempty"`
+	RateLimit  *RateLimit  `json:"rateLimit,omitempty"`
+}
+
+// Action is what a rule does when it fires. An empty Channel/To is filled in
+// from the triggering message, or the last active channel.
+type Action struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"` // agent prompt or message text; {{event}} is replaced with the event summary
+	Command string `json:"command,omitempty"`
+	Agent   string `json:"agent,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	To      string `json:"to,omitempty"`
+}
+
+type RuleState struct {
+	NextRunAtMS   *int64  `json:"nextRunAtMs,omitempty"` // time triggers only
+	LastFiredAtMS *int64  `json:"lastFiredAtMs,omitempty"`
+	RecentFires   []int64 `json:"recentFires,omitempty"` // fire times inside the rate limit period
+	FireCount     int     `json:"fireCount"`
+	LastStatus    string  `json:"lastStatus,omitempty"`
+	LastError     string  `json:"lastError,omitempty"`
+}
+
+type Rule struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Enabled     bool       `json:"enabled"`
+	Trigger     Trigger    `json:"trigger"`
+	Conditions  Conditions `json:"conditions"`
+	Action      Action     `json:"action"`
+	State       RuleState  `json:"state"`
+	CreatedAtMS int64      `json:"createdAtMs"`
+	UpdatedAtMS int64      `json:"updatedAtMs"`
+}
+
+// Event is something that happened and may trigger rules.
+type Event struct {
+	Kind    string            // one of the trigger kinds
+	Summary string            // human readable description, substituted for {{event}}
+	Channel string            // origin channel, for message and person events
+	ChatID  string            // origin chat, for message and person events
+	Fields  map[string]string // kind specific details
+}
+
+// Validate checks that a rule is complete and its patterns compile.
+func (r *Rule) Validate() error {
+	t := r.Trigger
+	switch t.Kind {
+	case TriggerTime:
+		if t.Schedule == nil {
+			return fmt.Errorf("time trigger needs a schedule")
+		}
+		if cron.NextRun(t.Schedule, time.Now().UnixMilli()) == nil {
+			return fmt.Errorf("time trigger schedule never fires")
+		}
+	case TriggerDevice:
+		if t.On != "" && t.On != "add" && t.On != "remove" && t.On != "change" {
+			return fmt.Errorf("invalid device action %q", t.On)
+		}
+	case TriggerPerson:
+		if t.MinScore < 0 || t.MinScore > 1 {
+			return fmt.Errorf("minScore must be between 0 and 1")
+		}
+	case TriggerFile:
+		if t.Path == "" {
+			return fmt.Errorf("file trigger needs a path")
+		}
+		if filepath.IsAbs(t.Path) || strings.HasPrefix(filepath.Clean(t.Path), "..") {
+			return fmt.Errorf("file trigger path must be inside the workspace")
+		}
+		if _, err := filepath.Match(t.Path, ""); err != nil {
+			return fmt.Errorf("invalid file pattern: %w", err)
+		}
+		if t.On != "" && t.On != FileCreated && t.On != FileModified && t.On != FileDeleted {
+			return fmt.Errorf("invalid file change %q", t.On)
+		}
+	case TriggerMessage:
+		if t.Match == "" {
+			return fmt.Errorf("message trigger needs a pattern")
+		}
+		if _, err := regexp.Compile(t.Match); err != nil {
+			return fmt.Errorf("invalid message pattern: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown trigger kind %q", t.Kind)
+	}
+
+	if w := r.Conditions.TimeWindow; w != nil {
+		if _, err := parseClock(w.Start); err != nil {
+			return err
+		}
+		if _, err := parseClock(w.End); err != nil {
+			return err
+		}
+		for _, d := range w.Days {
+			if _, ok := weekdays[strings.ToLower(d)]; !ok {
+				return fmt.Errorf("invalid day %q", d)
+			}
+		}
+	}
+	if rl := r.Conditions.RateLimit; rl != nil && (rl.Max <= 0 || rl.PeriodSec <= 0) {
+		return fmt.Errorf("rate limit needs a positive max and period")
+	}
+
+	switch r.Action.Kind {
+	case ActionAgent, ActionMessage:
+		if r.Action.Message == "" {
+			return fmt.Errorf("%s action needs a message", r.Action.Kind)
+		}
+	case ActionCommand:
+		if r.Action.Command == "" {
+			return fmt.Errorf("command action needs a command")
+		}
+	default:
+		return fmt.Errorf("unknown action kind %q", r.Action.Kind)
+	}
+	return nil
+}
+
+// matches reports whether ev satisfies the rule's trigger. Time triggers
+// are driven by the service loop and never match external events.
+func (t *Trigger) matches(ev Event, pattern *regexp.Regexp) bool {
+	if ev.Kind != t.Kind {
+		return false
+	}
+
+	switch t.Kind {
+	case TriggerDevice:
+		if t.Device != "" && !strings.EqualFold(t.Device, ev.Fields["kind"]) {
+			return false
+		}
+		if t.On != "" && t.On != ev.Fields["action"] {
+			return false
+		}
+		if t.Match != "" {
+			name := strings.ToLower(ev.Fields["vendor"] + " " + ev.Fields["product"])
+			if !strings.Contains(name, strings.ToLower(t.Match)) {
+				return false
+			}
+		}
+		return true
+	case TriggerPerson:
+		if t.MinScore > 0 {
+			score, err := strconv.ParseFloat(ev.Fields["score"], 64)
+			if err != nil || score < t.MinScore {
+				return false
+			}
+		}
+		return true
+	case TriggerFile:
+		if t.Path != ev.Fields["rule_path"] {
+			return false
+		}
+		return t.On == "" || t.On == ev.Fields["change"]
+	case TriggerMessage:
+		if t.Channel != "" && !strings.EqualFold(t.Channel, ev.Channel) {
+			return false
+		}
+		return pattern != nil && pattern.MatchString(ev.Fields["content"])
+	}
+	return false
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether now falls inside the window.
+func (w *TimeWindow) contains(now time.Time) bool {
+	start, err1 := parseClock(w.Start)
+	end, err2 := parseClock(w.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	day := now.Weekday()
+	var inside bool
+	if start <= end {
+		inside = minute >= start && minute < end
+	} else {
+		// Window crosses midnight; the early part belongs to the previous day.
+		inside = minute >= start || minute < end
+		if minute < end {
+			day = (day + 6) % 7
+		}
+	}
+	if !inside {
+		return false
+	}
+
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether the rate limit permits another fire at nowMS,
+// pruning fire times that fell out of the period.
+func (rl *RateLimit) allows(state *RuleState, nowMS int64) bool {
+	cutoff := nowMS - rl.PeriodSec*1000
+	recent := state.RecentFires[:0]
+	for _, ts := range state.RecentFires {
+		if ts > cutoff {
+			recent = append(recent, ts)
+		}
+	}
+	state.RecentFires = recent
+	return len(recent) < rl.Max
+}
+
+// Describe returns a one-line description of the rule's trigger.
+func (t *Trigger) Describe() string {
+	switch t.Kind {
+	case TriggerTime:
+		if t.Schedule == nil {
+			return "time"
+		}
+		switch t.Schedule.Kind {
+		case "every":
+			if t.Schedule.EveryMS != nil {
+				return fmt.Sprintf("every %ds", *t.Schedule.EveryMS/1000)
+			}
+		case "cron":
+			return "cron " + t.Schedule.Expr
+		case "at":
+			return "once"
+		}
+		return "time"
+	case TriggerDevice:
+		desc := "device"
+		if t.Device != "" {
+			desc += " " + t.Device
+		}
+		if t.On != "" {
+			desc += " " + t.On
+		}
+		if t.Match != "" {
+			desc += fmt.Sprintf(" matching %q", t.Match)
+		}
+		return desc
+	case TriggerPerson:
+		if t.MinScore > 0 {
+			return fmt.Sprintf("person detected (score >= %.2f)", t.MinScore)
+		}
+		return "person detected"
+	case TriggerFile:
+		desc := "file " + t.Path
+		if t.On != "" {
+			desc += " " + t.On
+		}
+		return desc
+	case TriggerMessage:
+		desc := fmt.Sprintf("message matching /%s/", t.Match)
+		if t.Channel != "" {
+			desc += " on " + t.Channel
+		}
+		return desc
+	}
+	return t.Kind
+}
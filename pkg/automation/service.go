@@ -0,0 +1,616 @@
+package automation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const defaultPollInterval = 5 * time.Second
+
+// actionTimeout bounds one run of a rule's action.
+const actionTimeout = 10 * time.Minute
+
+// Handler performs a rule's action. The rule passed in is a copy whose
+// Action.Channel/To have already been resolved.
+type Handler func(ctx context.Context, rule *Rule, ev Event) (string, error)
+
+type Store struct {
+	Version int    `json:"version"`
+	Rules   []Rule `json:"rules"`
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Service evaluates automation rules against time, device, camera, file and
+// message events. Rules are persisted next to the cron jobs.
+type Service struct {
+	storePath     string
+	workspace     string
+	store         *Store
+	handler       Handler
+	defaultTarget func() (channel, chatID string)
+	patterns      map[string]*regexp.Regexp
+	firing        map[string]bool
+	files         map[string]map[string]fileStamp
+	pollInterval  time.Duration
+	mu            sync.Mutex
+	running       bool
+	stopChan      chan struct{}
+	inflight      sync.WaitGroup
+	actionCtx     context.Context // cancelled by Stop
+	cancelActions context.CancelFunc
+}
+
+func NewService(storePath, workspace string, handler Handler) *Service {
+	s := &Service{
+		storePath:    storePath,
+		workspace:    workspace,
+		handler:      handler,
+		patterns:     make(map[string]*regexp.Regexp),
+		firing:       make(map[string]bool),
+		files:        make(map[string]map[string]fileStamp),
+		pollInterval: defaultPollInterval,
+	}
+	s.actionCtx, s.cancelActions = context.WithCancel(context.Background())
+	if err := s.loadStore(); err != nil {
+		logger.WarnCF("automation", "Failed to load rules", map[string]interface{}{"error": err.Error()})
+	}
+	return s
+}
+
+func (s *Service) SetHandler(handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handler = handler
+}
+
+// SetDefaultTarget sets the fallback destination for actions that have no
+// explicit target and weren't triggered by a message.
+func (s *Service) SetDefaultTarget(target func() (channel, chatID string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultTarget = target
+}
+
+func (s *Service) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return nil
+	}
+
+	if err := s.loadStore(); err != nil {
+		return fmt.Errorf("failed to load rules: %w", err)
+	}
+
+	now := time.Now().UnixMilli()
+	for i := range s.store.Rules {
+		rule := &s.store.Rules[i]
+		if rule.Enabled && rule.Trigger.Kind == TriggerTime {
+			rule.State.NextRunAtMS = cron.NextRun(rule.Trigger.Schedule, now)
+		}
+	}
+	if err := s.saveStoreUnsafe(); err != nil {
+		return fmt.Errorf("failed to save rules: %w", err)
+	}
+
+	if s.actionCtx.Err() != nil {
+		s.actionCtx, s.cancelActions = context.WithCancel(context.Background())
+	}
+	s.stopChan = make(chan struct{})
+	s.running = true
+	go s.runLoop(s.stopChan)
+
+	return nil
+}
+
+// Stop stops evaluating time and file triggers, cancels running actions and
+// waits for them to return.
+func (s *Service) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	if s.stopChan != nil {
+		close(s.stopChan)
+		s.stopChan = nil
+	}
+	s.cancelActions()
+	s.mu.Unlock()
+
+	s.inflight.Wait()
+}
+
+func (s *Service) runLoop(stopChan chan struct{}) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	s.pollFiles()
+	lastPoll := time.Now()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case now := <-ticker.C:
+			s.checkTimeTriggers(now.UnixMilli())
+			if now.Sub(lastPoll) >= s.pollInterval {
+				s.pollFiles()
+				lastPoll = now
+			}
+		}
+	}
+}
+
+// Dispatch evaluates an event against all enabled rules and fires those that
+// match and whose conditions hold. It returns the number of rules fired,
+// which is always 0 while the service is stopped.
+func (s *Service) Dispatch(ev Event) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return 0
+	}
+
+	now := time.Now()
+	fired := 0
+	for i := range s.store.Rules {
+		rule := &s.store.Rules[i]
+		if !rule.Enabled || rule.Trigger.Kind == TriggerTime {
+			continue
+		}
+		if !rule.Trigger.matches(ev, s.patternUnsafe(rule)) {
+			continue
+		}
+		if s.tryFireUnsafe(rule, ev, now) {
+			fired++
+		}
+	}
+	if fired > 0 {
+		if err := s.saveStoreUnsafe(); err != nil {
+			logger.WarnCF("automation", "Failed to save rules", map[string]interface{}{"error": err.Error()})
+		}
+	}
+	return fired
+}
+
+func (s *Service) checkTimeTriggers(nowMS int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return
+	}
+
+	now := time.UnixMilli(nowMS)
+	changed := false
+	for i := range s.store.Rules {
+		rule := &s.store.Rules[i]
+		if !rule.Enabled || rule.Trigger.Kind != TriggerTime {
+			continue
+		}
+		if rule.State.NextRunAtMS == nil || *rule.State.NextRunAtMS > nowMS {
+			continue
+		}
+
+		rule.State.NextRunAtMS = cron.NextRun(rule.Trigger.Schedule, nowMS)
+		if rule.State.NextRunAtMS == nil {
+			rule.Enabled = false
+		}
+		changed = true
+
+		s.tryFireUnsafe(rule, Event{
+			Kind:    TriggerTime,
+			Summary: "Scheduled time " + now.Format("2006-01-02 15:04"),
+		}, now)
+	}
+	if changed {
+		if err := s.saveStoreUnsafe(); err != nil {
+			logger.WarnCF("automation", "Failed to save rules", map[string]interface{}{"error": err.Error()})
+		}
+	}
+}
+
+// tryFireUnsafe checks the rule's conditions and, if they hold, records the
+// fire and runs the action in the background.
+func (s *Service) tryFireUnsafe(rule *Rule, ev Event, now time.Time) bool {
+	if w := rule.Conditions.TimeWindow; w != nil && !w.contains(now) {
+		logger.DebugCF("automation", "Outside time window", map[string]interface{}{"rule": rule.ID})
+		return false
+	}
+	nowMS := now.UnixMilli()
+	if rl := rule.Conditions.RateLimit; rl != nil && !rl.allows(&rule.State, nowMS) {
+		logger.DebugCF("automation", "Rate limited", map[string]interface{}{"rule": rule.ID})
+		return false
+	}
+	if s.firing[rule.ID] {
+		logger.DebugCF("automation", "Previous run still in progress", map[string]interface{}{"rule": rule.ID})
+		return false
+	}
+
+	rule.State.LastFiredAtMS = &nowMS
+	rule.State.FireCount++
+	if rule.Conditions.RateLimit != nil {
+		rule.State.RecentFires = append(rule.State.RecentFires, nowMS)
+	}
+
+	ruleCopy := *rule
+	ruleCopy.Action.Channel, ruleCopy.Action.To = s.resolveTargetUnsafe(rule, ev)
+	handler := s.handler
+
+	s.firing[rule.ID] = true
+	s.inflight.Add(1)
+	go s.fire(s.actionCtx, handler, &ruleCopy, ev)
+
+	logger.InfoCF("automation", "Rule fired", map[string]interface{}{
+		"rule":    rule.ID,
+		"name":    rule.Name,
+		"trigger": rule.Trigger.Kind,
+		"action":  rule.Action.Kind,
+	})
+	return true
+}
+
+func (s *Service) resolveTargetUnsafe(rule *Rule, ev Event) (string, string) {
+	if rule.Action.Channel != "" && rule.Action.To != "" {
+		return rule.Action.Channel, rule.Action.To
+	}
+	if ev.Channel != "" && ev.ChatID != "" && ev.Kind == TriggerMessage {
+		return ev.Channel, ev.ChatID
+	}
+	if s.defaultTarget != nil {
+		return s.defaultTarget()
+	}
+	return rule.Action.Channel, rule.Action.To
+}
+
+func (s *Service) fire(ctx context.Context, handler Handler, rule *Rule, ev Event) {
+	defer s.inflight.Done()
+
+	var err error
+	if handler != nil {
+		ctx, cancel := context.WithTimeout(ctx, actionTimeout)
+		_, err = handler(ctx, rule, ev)
+		cancel()
+	}
+	if err != nil {
+		logger.WarnCF("automation", "Rule action failed", map[string]interface{}{
+			"rule":  rule.ID,
+			"error": err.Error(),
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.firing, rule.ID)
+	for i := range s.store.Rules {
+		stored := &s.store.Rules[i]
+		if stored.ID != rule.ID {
+			continue
+		}
+		if err != nil {
+			stored.State.LastStatus = "error"
+			stored.State.LastError = err.Error()
+		} else {
+			stored.State.LastStatus = "ok"
+			stored.State.LastError = ""
+		}
+		if err := s.saveStoreUnsafe(); err != nil {
+			logger.WarnCF("automation", "Failed to save rules", map[string]interface{}{"error": err.Error()})
+		}
+		return
+	}
+}
+
+func (s *Service) patternUnsafe(rule *Rule) *regexp.Regexp {
+	if rule.Trigger.Kind != TriggerMessage || rule.Trigger.Match == "" {
+		return nil
+	}
+	if re, ok := s.patterns[rule.Trigger.Match]; ok {
+		return re
+	}
+	re, err := regexp.Compile(rule.Trigger.Match)
+	if err != nil {
+		logger.WarnCF("automation", "Invalid message pattern", map[string]interface{}{
+			"rule":  rule.ID,
+			"error": err.Error(),
+		})
+	}
+	s.patterns[rule.Trigger.Match] = re
+	return re
+}
+
+// pollFiles compares the files matched by each file trigger with the previous
+// poll and dispatches created/modified/deleted events. The first poll of a
+// path only records a baseline.
+func (s *Service) pollFiles() {
+	s.mu.Lock()
+	paths := make(map[string]bool)
+	for _, rule := range s.store.Rules {
+		if rule.Enabled && rule.Trigger.Kind == TriggerFile {
+			paths[rule.Trigger.Path] = true
+		}
+	}
+	for path := range s.files {
+		if !paths[path] {
+			delete(s.files, path)
+		}
+	}
+	s.mu.Unlock()
+
+	for path := range paths {
+		current := s.scanFiles(path)
+
+		s.mu.Lock()
+		previous, seen := s.files[path]
+		s.files[path] = current
+		s.mu.Unlock()
+
+		if !seen {
+			continue
+		}
+		for name, stamp := range current {
+			old, ok := previous[name]
+			switch {
+			case !ok:
+				s.Dispatch(fileEvent(path, name, FileCreated))
+			case !old.modTime.Equal(stamp.modTime) || old.size != stamp.size:
+				s.Dispatch(fileEvent(path, name, FileModified))
+			}
+		}
+		for name := range previous {
+			if _, ok := current[name]; !ok {
+				s.Dispatch(fileEvent(path, name, FileDeleted))
+			}
+		}
+	}
+}
+
+func (s *Service) scanFiles(pattern string) map[string]fileStamp {
+	result := make(map[string]fileStamp)
+
+	full := filepath.Join(s.workspace, pattern)
+	if info, err := os.Stat(full); err == nil && info.IsDir() {
+		full = filepath.Join(full, "*")
+	}
+
+	matches, err := filepath.Glob(full)
+	if err != nil {
+		return result
+	}
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		rel, err := filepath.Rel(s.workspace, m)
+		if err != nil {
+			continue
+		}
+		result[rel] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return result
+}
+
+func fileEvent(rulePath, name, change string) Event {
+	return Event{
+		Kind:    TriggerFile,
+		Summary: fmt.Sprintf("File %s %s", name, change),
+		Fields: map[string]string{
+			"path":      name,
+			"change":    change,
+			"rule_path": rulePath,
+		},
+	}
+}
+
+// DeviceEvent converts a device hotplug event into an automation event.
+func DeviceEvent(ev *events.DeviceEvent) Event {
+	return Event{
+		Kind:    TriggerDevice,
+		Summary: strings.TrimSpace(ev.FormatMessage()),
+		Fields: map[string]string{
+			"kind":         string(ev.Kind),
+			"action":       string(ev.Action),
+			"device_id":    ev.DeviceID,
+			"vendor":       ev.Vendor,
+			"product":      ev.Product,
+			"serial":       ev.Serial,
+			"capabilities": ev.Capabilities,
+		},
+	}
+}
+
+// MessageEvent converts an inbound message into an automation event. MaixCam
+// person detections become person events; everything else is a message event.
+func MessageEvent(msg bus.InboundMessage) Event {
+	if msg.Channel == "maixcam" && msg.Metadata["event"] == "person_detected" {
+		fields := make(map[string]string, len(msg.Metadata))
+		for k, v := range msg.Metadata {
+			fields[k] = v
+		}
+		return Event{
+			Kind:    TriggerPerson,
+			Summary: msg.Content,
+			Channel: msg.Channel,
+			ChatID:  msg.ChatID,
+			Fields:  fields,
+		}
+	}
+
+	return Event{
+		Kind:    TriggerMessage,
+		Summary: fmt.Sprintf("Message from %s on %s: %s", msg.SenderID, msg.Channel, msg.Content),
+		Channel: msg.Channel,
+		ChatID:  msg.ChatID,
+		Fields: map[string]string{
+			"content":   msg.Content,
+			"sender_id": msg.SenderID,
+		},
+	}
+}
+
+func (s *Service) loadStore() error {
+	s.store = &Store{
+		Version: 1,
+		Rules:   []Rule{},
+	}
+
+	data, err := os.ReadFile(s.storePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return json.Unmarshal(data, s.store)
+}
+
+func (s *Service) saveStoreUnsafe() error {
+	dir := filepath.Dir(s.storePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s.store, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.storePath, data, 0600)
+}
+
+// AddRule validates and stores a new rule. ID, timestamps and state are
+// assigned by the service.
+func (s *Service) AddRule(rule Rule) (*Rule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	rule.ID = generateID()
+	rule.Enabled = true
+	rule.State = RuleState{}
+	rule.CreatedAtMS = now
+	rule.UpdatedAtMS = now
+	if rule.Trigger.Kind == TriggerTime {
+		rule.State.NextRunAtMS = cron.NextRun(rule.Trigger.Schedule, now)
+	}
+
+	s.store.Rules = append(s.store.Rules, rule)
+	if err := s.saveStoreUnsafe(); err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func (s *Service) RemoveRule(ruleID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.store.Rules)
+	var rules []Rule
+	for _, rule := range s.store.Rules {
+		if rule.ID != ruleID {
+			rules = append(rules, rule)
+		}
+	}
+	s.store.Rules = rules
+	removed := len(s.store.Rules) < before
+
+	if removed {
+		if err := s.saveStoreUnsafe(); err != nil {
+			logger.WarnCF("automation", "Failed to save rules after remove", map[string]interface{}{"error": err.Error()})
+		}
+	}
+	return removed
+}
+
+func (s *Service) EnableRule(ruleID string, enabled bool) *Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.store.Rules {
+		rule := &s.store.Rules[i]
+		if rule.ID != ruleID {
+			continue
+		}
+		rule.Enabled = enabled
+		rule.UpdatedAtMS = time.Now().UnixMilli()
+		rule.State.NextRunAtMS = nil
+		if enabled && rule.Trigger.Kind == TriggerTime {
+			rule.State.NextRunAtMS = cron.NextRun(rule.Trigger.Schedule, time.Now().UnixMilli())
+		}
+		if err := s.saveStoreUnsafe(); err != nil {
+			logger.WarnCF("automation", "Failed to save rules", map[string]interface{}{"error": err.Error()})
+		}
+		ruleCopy := *rule
+		return &ruleCopy
+	}
+	return nil
+}
+
+func (s *Service) GetRule(ruleID string) (*Rule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rule := range s.store.Rules {
+		if rule.ID == ruleID {
+			return &rule, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Service) ListRules(includeDisabled bool) []Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules := make([]Rule, 0, len(s.store.Rules))
+	for _, rule := range s.store.Rules {
+		if includeDisabled || rule.Enabled {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// ExpandEvent replaces {{event}} in text with the event summary.
+func ExpandEvent(text string, ev Event) string {
+	return strings.ReplaceAll(text, "{{event}}", ev.Summary)
+}
+
+func generateID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,297 @@
+package automation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	rules []Rule
+	evs   []Event
+}
+
+func (r *recorder) handle(ctx context.Context, rule *Rule, ev Event) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = append(r.rules, *rule)
+	r.evs = append(r.evs, ev)
+	return "ok", nil
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.rules)
+}
+
+func newTestService(t *testing.T) (*Service, *recorder, string) {
+	t.Helper()
+	workspace := t.TempDir()
+	rec := &recorder{}
+	s := NewService(filepath.Join(workspace, "cron", "automations.json"), workspace, rec.handle)
+	return s, rec, workspace
+}
+
+func messageRule(name, pattern string) Rule {
+	return Rule{
+		Name:    name,
+		Trigger: Trigger{Kind: TriggerMessage, Match: pattern},
+		Action:  Action{Kind: ActionMessage, Message: "seen: {{event}}"},
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	every := int64(60000)
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr bool
+	}{
+		{"valid time", Rule{Trigger: Trigger{Kind: TriggerTime, Schedule: &cron.CronSchedule{Kind: "every", EveryMS: &every}}, Action: Action{Kind: ActionAgent, Message: "hi"}}, false},
+		{"time without schedule", Rule{Trigger: Trigger{Kind: TriggerTime}, Action: Action{Kind: ActionAgent, Message: "hi"}}, true},
+		{"bad regexp", messageRule("x", "("), true},
+		{"file outside workspace", Rule{Trigger: Trigger{Kind: TriggerFile, Path: "../etc/passwd"}, Action: Action{Kind: ActionCommand, Command: "ls"}}, true},
+		{"unknown trigger", Rule{Trigger: Trigger{Kind: "weather"}, Action: Action{Kind: ActionCommand, Command: "ls"}}, true},
+		{"command without command", Rule{Trigger: Trigger{Kind: TriggerPerson}, Action: Action{Kind: ActionCommand}}, true},
+		{"bad window", Rule{Trigger: Trigger{Kind: TriggerPerson}, Conditions: Conditions{TimeWindow: &TimeWindow{Start: "25:00", End: "07:00"}}, Action: Action{Kind: ActionMessage, Message: "x"}}, true},
+		{"bad rate", Rule{Trigger: Trigger{Kind: TriggerPerson}, Conditions: Conditions{RateLimit: &RateLimit{Max: 0, PeriodSec: 60}}, Action: Action{Kind: ActionMessage, Message: "x"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTriggerMatches(t *testing.T) {
+	usbAdd := DeviceEvent(&events.DeviceEvent{Action: events.ActionAdd, Kind: events.KindUSB, Vendor: "Logitech", Product: "Webcam C920"})
+	person := MessageEvent(bus.InboundMessage{Channel: "maixcam", ChatID: "default", Content: "Person", Metadata: map[string]string{"event": "person_detected", "score": "0.75"}})
+
+	tests := []struct {
+		name    string
+		trigger Trigger
+		ev      Event
+		want    bool
+	}{
+		{"device any", Trigger{Kind: TriggerDevice}, usbAdd, true},
+		{"device kind and action", Trigger{Kind: TriggerDevice, Device: "usb", On: "add"}, usbAdd, true},
+		{"device wrong action", Trigger{Kind: TriggerDevice, On: "remove"}, usbAdd, false},
+		{"device vendor match", Trigger{Kind: TriggerDevice, Match: "webcam"}, usbAdd, true},
+		{"device vendor mismatch", Trigger{Kind: TriggerDevice, Match: "keyboard"}, usbAdd, false},
+		{"person above score", Trigger{Kind: TriggerPerson, MinScore: 0.5}, person, true},
+		{"person below score", Trigger{Kind: TriggerPerson, MinScore: 0.9}, person, false},
+		{"kind mismatch", Trigger{Kind: TriggerMessage, Match: "."}, usbAdd, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.trigger.matches(tt.ev, nil); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeWindowContains(t *testing.T) {
+	// 2026-03-02 is a Monday.
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2026, 3, day, hour, min, 0, 0, time.Local)
+	}
+
+	day := &TimeWindow{Start: "09:00", End: "17:00", Days: []string{"mon"}}
+	if !day.contains(at(2, 9, 0)) || day.contains(at(2, 17, 0)) || day.contains(at(3, 10, 0)) {
+		t.Error("daytime window evaluated incorrectly")
+	}
+
+	night := &TimeWindow{Start: "22:00", End: "07:00", Days: []string{"mon"}}
+	if !night.contains(at(2, 23, 0)) {
+		t.Error("Monday 23:00 should be inside Monday's night window")
+	}
+	if !night.contains(at(3, 6, 59)) {
+		t.Error("Tuesday 06:59 belongs to Monday's night window")
+	}
+	if night.contains(at(2, 6, 0)) {
+		t.Error("Monday 06:00 belongs to Sunday's night window")
+	}
+}
+
+func TestDispatch_MessageRuleAndRateLimit(t *testing.T) {
+	s, rec, _ := newTestService(t)
+
+	rule := messageRule("deploy", `(?i)^deploy\b`)
+	rule.Conditions.RateLimit = &RateLimit{Max: 2, PeriodSec: 3600}
+	added, err := s.AddRule(rule)
+	if err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+
+	s.running = true
+	msg := bus.InboundMessage{Channel: "telegram", ChatID: "42", SenderID: "7", Content: "Deploy now"}
+	for i := 0; i < 3; i++ {
+		s.Dispatch(MessageEvent(msg))
+		s.inflight.Wait()
+	}
+	s.Dispatch(MessageEvent(bus.InboundMessage{Channel: "telegram", ChatID: "42", Content: "no match"}))
+	s.inflight.Wait()
+
+	if got := rec.count(); got != 2 {
+		t.Fatalf("handler called %d times, want 2 (rate limited)", got)
+	}
+	if rec.rules[0].Action.Channel != "telegram" || rec.rules[0].Action.To != "42" {
+		t.Errorf("target = %s/%s, want the origin chat", rec.rules[0].Action.Channel, rec.rules[0].Action.To)
+	}
+
+	got, _ := s.GetRule(added.ID)
+	if got.State.FireCount != 2 || got.State.LastStatus != "ok" {
+		t.Errorf("state = %+v", got.State)
+	}
+}
+
+func TestStop_CancelsAndWaitsForActions(t *testing.T) {
+	workspace := t.TempDir()
+	started := make(chan struct{})
+	var finished bool
+	var deadline bool
+	s := NewService(filepath.Join(workspace, "cron", "automations.json"), workspace,
+		func(ctx context.Context, rule *Rule, ev Event) (string, error) {
+			_, deadline = ctx.Deadline()
+			close(started)
+			<-ctx.Done()
+			time.Sleep(20 * time.Millisecond)
+			finished = true
+			return "", ctx.Err()
+		})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	added, _ := s.AddRule(messageRule("slow", "slow"))
+
+	s.Dispatch(MessageEvent(bus.InboundMessage{Channel: "telegram", ChatID: "42", Content: "slow"}))
+	<-started
+	s.Stop()
+
+	if !finished {
+		t.Error("Stop returned before the running action")
+	}
+	if !deadline {
+		t.Error("action ran without a timeout")
+	}
+	if got, _ := s.GetRule(added.ID); got.State.LastStatus != "error" {
+		t.Errorf("state after stop = %+v", got.State)
+	}
+}
+
+func TestDispatch_AfterStop(t *testing.T) {
+	s, rec, _ := newTestService(t)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	s.AddRule(messageRule("deploy", "deploy"))
+	s.Stop()
+
+	if n := s.Dispatch(MessageEvent(bus.InboundMessage{Channel: "telegram", ChatID: "42", Content: "deploy"})); n != 0 {
+		t.Errorf("Dispatch after Stop fired %d rules, want 0", n)
+	}
+	s.inflight.Wait()
+	if rec.count() != 0 {
+		t.Error("handler ran after Stop")
+	}
+}
+
+func TestDispatch_DefaultTargetAndDisabled(t *testing.T) {
+	s, rec, _ := newTestService(t)
+	s.SetDefaultTarget(func() (string, string) { return "discord", "99" })
+
+	added, _ := s.AddRule(Rule{
+		Name:    "usb",
+		Trigger: Trigger{Kind: TriggerDevice, On: "add"},
+		Action:  Action{Kind: ActionMessage, Message: "{{event}}"},
+	})
+
+	s.running = true
+	ev := DeviceEvent(&events.DeviceEvent{Action: events.ActionAdd, Kind: events.KindUSB, Vendor: "SanDisk"})
+	s.Dispatch(ev)
+	s.inflight.Wait()
+	if rec.count() != 1 || rec.rules[0].Action.Channel != "discord" || rec.rules[0].Action.To != "99" {
+		t.Fatalf("expected one fire to the default target, got %+v", rec.rules)
+	}
+
+	s.EnableRule(added.ID, false)
+	s.Dispatch(ev)
+	s.inflight.Wait()
+	if rec.count() != 1 {
+		t.Error("disabled rule should not fire")
+	}
+}
+
+func TestPollFiles(t *testing.T) {
+	s, rec, workspace := newTestService(t)
+	inbox := filepath.Join(workspace, "inbox")
+	os.MkdirAll(inbox, 0755)
+	os.WriteFile(filepath.Join(inbox, "old.csv"), []byte("a"), 0644)
+
+	s.AddRule(Rule{
+		Name:    "csv",
+		Trigger: Trigger{Kind: TriggerFile, Path: "inbox/*.csv", On: FileCreated},
+		Action:  Action{Kind: ActionCommand, Command: "true"},
+	})
+
+	s.running = true
+	s.pollFiles() // baseline
+	os.WriteFile(filepath.Join(inbox, "new.csv"), []byte("b"), 0644)
+	os.WriteFile(filepath.Join(inbox, "notes.txt"), []byte("c"), 0644)
+	s.pollFiles()
+	s.inflight.Wait()
+
+	if rec.count() != 1 {
+		t.Fatalf("handler called %d times, want 1", rec.count())
+	}
+	if got := rec.evs[0].Fields["path"]; got != filepath.Join("inbox", "new.csv") {
+		t.Errorf("event path = %q", got)
+	}
+}
+
+func TestTimeTriggerAndPersistence(t *testing.T) {
+	s, rec, workspace := newTestService(t)
+	every := int64(60000)
+	added, err := s.AddRule(Rule{
+		Name:    "tick",
+		Trigger: Trigger{Kind: TriggerTime, Schedule: &cron.CronSchedule{Kind: "every", EveryMS: &every}},
+		Action:  Action{Kind: ActionAgent, Message: "check"},
+	})
+	if err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+
+	s.running = true
+	s.checkTimeTriggers(time.Now().UnixMilli())
+	s.inflight.Wait()
+	if rec.count() != 0 {
+		t.Fatal("rule fired before it was due")
+	}
+
+	s.checkTimeTriggers(time.Now().UnixMilli() + every)
+	s.inflight.Wait()
+	if rec.count() != 1 {
+		t.Fatalf("handler called %d times, want 1", rec.count())
+	}
+
+	reloaded := NewService(filepath.Join(workspace, "cron", "automations.json"), workspace, nil)
+	got, ok := reloaded.GetRule(added.ID)
+	if !ok || got.State.FireCount != 1 {
+		t.Errorf("reloaded rule = %+v, %v", got, ok)
+	}
+}
@@ -6,11 +6,12 @@ import (
 )
 
 type MessageBus struct {
-	inbound  chan InboundMessage
-	outbound chan OutboundMessage
-	handlers map[string]MessageHandler
-	closed   bool
-	mu       sync.RWMutex
+	inbound   chan InboundMessage
+	outbound  chan OutboundMessage
+	handlers  map[string]MessageHandler
+	observers []func(InboundMessage)
+	closed    bool
+	mu        sync.RWMutex
 }
 
 func NewMessageBus() *MessageBus {
@@ -27,9 +28,20 @@ func (mb *MessageBus) PublishInbound(msg InboundMessage) {
 	if mb.closed {
 		return
 	}
+	for _, fn := range mb.observers {
+		fn(msg)
+	}
 	mb.inbound <- msg
 }
 
+// ObserveInbound registers fn to see every inbound message before it is
+// queued. Observers run on the publisher's goroutine and must not block.
+func (mb *MessageBus) ObserveInbound(fn func(InboundMessage)) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.observers = append(mb.observers, fn)
+}
+
 func (mb *MessageBus) ConsumeInbound(ctx context.Context) (InboundMessage, bool) {
 	select {
 	case msg := <-mb.inbound:
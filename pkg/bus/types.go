@@ -11,9 +11,11 @@ type InboundMessage struct {
 }
 
 type OutboundMessage struct {
-	Channel string `json:"channel"`
-	ChatID  string `json:"chat_id"`
-	Content string `json:"content"`
+	Channel string   `json:"channel"`
+	ChatID  string   `json:"chat_id"`
+	Content string   `json:"content"`
+	Voice   bool     `json:"voice,omitempty"` // speak the reply on channels that support voice messages
+	Media   []string `json:"media,omitempty"` // image files to send after the text
 }
 
 type MessageHandler func(InboundMessage) error
@@ -2,9 +2,19 @@ package channels
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
 type Channel interface {
@@ -16,12 +26,31 @@ type Channel interface {
 	IsAllowed(senderID string) bool
 }
 
+// VoiceSender is implemented by channels that can send voice messages. The
+// audio file is OGG/Opus.
+type VoiceSender interface {
+	SendVoice(ctx context.Context, chatID, audioPath string) error
+}
+
+// PhotoSender is implemented by channels that can send images, such as
+// charts. The image file is PNG or JPEG.
+type PhotoSender interface {
+	SendPhoto(ctx context.Context, chatID, imagePath string) error
+}
+
 type BaseChannel struct {
-	config    interface{}
-	bus       *bus.MessageBus
-	running   bool
-	name      string
-	allowList []string
+	config      interface{}
+	bus         *bus.MessageBus
+	running     bool
+	name        string
+	allowList   []string
+	transcriber voice.Transcriber
+
+	// lifeCtx is cancelled when the channel stops, aborting transcriptions
+	// still running on the receive path.
+	lifeMu     sync.Mutex
+	lifeCtx    context.Context
+	lifeCancel context.CancelFunc
 }
 
 func NewBaseChannel(name string, config interface{}, bus *bus.MessageBus, allowList []string) *BaseChannel {
@@ -86,6 +115,19 @@ func (c *BaseChannel) HandleMessage(senderID, chatID, content string, media []st
 		return
 	}
 
+	if hasAudio(media) {
+		// Lets the agent answer voice with voice. The caller's map is left
+		// as it was.
+		withVoice := make(map[string]string, len(metadata)+1)
+		for k, v := range metadata {
+			withVoice[k] = v
+		}
+		withVoice["voice"] = "true"
+		metadata = withVoice
+	}
+	content = c.transcribeMedia(content, media)
+	media = inlineImages(media)
+
 	msg := bus.InboundMessage{
 		Channel:  c.name,
 		SenderID: senderID,
@@ -98,6 +140,132 @@ func (c *BaseChannel) HandleMessage(senderID, chatID, content string, media []st
 	c.bus.PublishInbound(msg)
 }
 
+// maxInlineImageBytes caps the size of an image read into a message.
+const maxInlineImageBytes = 20 << 20
+
+// inlineImages replaces local image files in media with data URLs. Channels
+// delete downloaded files once HandleMessage returns, usually before the
+// agent gets to the message, so images are read while they still exist.
+func inlineImages(media []string) []string {
+	var out []string
+	for i, path := range media {
+		mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+		if strings.Contains(path, "://") || !strings.HasPrefix(mimeType, "image/") {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.Size() > maxInlineImageBytes {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if out == nil {
+			out = append([]string(nil), media...)
+		}
+		out[i] = "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	}
+	if out == nil {
+		return media
+	}
+	return out
+}
+
+// setTranscriber sets the speech-to-text backend used for audio media. The
+// Manager calls it on every channel.
+func (c *BaseChannel) setTranscriber(t voice.Transcriber) {
+	c.transcriber = t
+}
+
+func hasAudio(media []string) bool {
+	for _, path := range media {
+		if !strings.Contains(path, "://") && utils.IsAudioFile(path, "") {
+			return true
+		}
+	}
+	return false
+}
+
+// audioMarker matches the placeholders channels put in content for audio
+// attachments, such as "[voice]", "[audio]" or "[audio: memo.m4a]".
+var audioMarker = regexp.MustCompile(`\[(?:voice|audio)(?:: [^\]]*)?\]`)
+
+// transcribeMedia replaces the placeholder of each audio file in media with
+// its transcript. Placeholders are matched to files in order; a transcript
+// without a placeholder is appended. Channels call HandleMessage before
+// deleting downloaded files, so the files still exist here.
+//
+// Transcription runs on the channel's receive path, which keeps messages of
+// a chat in order but holds up the next update while it runs. Each file is
+// bounded by the backend's voice.transcription.timeout_seconds, and Stop
+// cancels a transcription in progress.
+func (c *BaseChannel) transcribeMedia(content string, media []string) string {
+	if c.transcriber == nil {
+		return content
+	}
+	ctx := c.lifetime()
+	markers := audioMarker.FindAllStringIndex(content, -1)
+	var out strings.Builder
+	last, n := 0, 0
+	var extra []string
+	for _, path := range media {
+		if strings.Contains(path, "://") || !utils.IsAudioFile(path, "") {
+			continue
+		}
+		result, err := c.transcriber.Transcribe(ctx, path)
+
+		text := ""
+		if err != nil {
+			logger.ErrorCF(c.name, "Voice transcription failed", map[string]interface{}{
+				"backend": c.transcriber.Name(),
+				"file":    filepath.Base(path),
+				"error":   err.Error(),
+			})
+			text = "[voice (transcription failed)]"
+		} else {
+			text = fmt.Sprintf("[voice transcription: %s]", result.Text)
+		}
+		if n < len(markers) {
+			out.WriteString(content[last:markers[n][0]])
+			out.WriteString(text)
+			last = markers[n][1]
+			n++
+		} else {
+			extra = append(extra, text)
+		}
+	}
+	out.WriteString(content[last:])
+	content = out.String()
+	for _, text := range extra {
+		if content != "" {
+			content += "\n"
+		}
+		content += text
+	}
+	return content
+}
+
 func (c *BaseChannel) setRunning(running bool) {
 	c.running = running
+
+	c.lifeMu.Lock()
+	defer c.lifeMu.Unlock()
+	if running {
+		if c.lifeCtx == nil || c.lifeCtx.Err() != nil {
+			c.lifeCtx, c.lifeCancel = context.WithCancel(context.Background())
+		}
+	} else if c.lifeCancel != nil {
+		c.lifeCancel()
+	}
+}
+
+// lifetime returns a context that is cancelled when the channel stops.
+func (c *BaseChannel) lifetime() context.Context {
+	c.lifeMu.Lock()
+	defer c.lifeMu.Unlock()
+	if c.lifeCtx == nil {
+		c.lifeCtx, c.lifeCancel = context.WithCancel(context.Background())
+	}
+	return c.lifeCtx
 }
@@ -1,6 +1,15 @@
 package channels
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/voice"
+)
 
 func TestBaseChannelIsAllowed(t *testing.T) {
 	tests := []struct {
@@ -50,3 +59,139 @@ func TestBaseChannelIsAllowed(t *testing.T) {
 		})
 	}
 }
+
+type fakeTranscriber struct {
+	files    []string
+	deadline bool
+}
+
+func (f *fakeTranscriber) Name() string { return "fake" }
+
+func (f *fakeTranscriber) Transcribe(ctx context.Context, path string) (*voice.TranscriptionResponse, error) {
+	f.files = append(f.files, path)
+	_, f.deadline = ctx.Deadline()
+	if strings.HasSuffix(path, ".amr") {
+		return nil, fmt.Errorf("unsupported format")
+	}
+	return &voice.TranscriptionResponse{Text: "hello there"}, nil
+}
+
+func TestBaseChannelHandleMessage_TranscribesAudio(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	ch := NewBaseChannel("test", nil, msgBus, nil)
+	tr := &fakeTranscriber{}
+
+	m := &Manager{channels: map[string]Channel{}, transcriber: tr}
+	m.RegisterChannel("test", &testChannel{BaseChannel: ch})
+
+	metadata := map[string]string{"message_id": "5"}
+	ch.HandleMessage("user", "chat", "[voice]", []string{"/tmp/a.ogg", "/tmp/photo.jpg", "/tmp/b.amr", "https://cdn/x.mp3"}, metadata)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	want := "[voice transcription: hello there]\n[voice (transcription failed)]"
+	if msg.Content != want {
+		t.Errorf("Content = %q, want %q", msg.Content, want)
+	}
+	if len(tr.files) != 2 {
+		t.Errorf("transcribed %v, want only the local audio files", tr.files)
+	}
+	if tr.deadline {
+		t.Error("transcription was given a deadline shorter than the backend's configured timeout")
+	}
+	if msg.Metadata["voice"] != "true" || msg.Metadata["message_id"] != "5" {
+		t.Errorf("Metadata = %v, want voice=true", msg.Metadata)
+	}
+	if _, ok := metadata["voice"]; ok {
+		t.Error("HandleMessage changed the caller's metadata map")
+	}
+}
+
+func TestBaseChannelTranscribeMedia_ReplacesMarkers(t *testing.T) {
+	ch := NewBaseChannel("telegram", nil, bus.NewMessageBus(), nil)
+	ch.setTranscriber(&fakeTranscriber{})
+
+	tests := []struct {
+		name    string
+		content string
+		media   []string
+		want    string
+	}{
+		{
+			"telegram voice note",
+			"[voice]",
+			[]string{"/tmp/voice.ogg"},
+			"[voice transcription: hello there]",
+		},
+		{
+			"telegram caption, photo and audio",
+			"look\n[image: photo]\n[voice]\n[audio]",
+			[]string{"/tmp/photo.jpg", "/tmp/voice.ogg", "/tmp/song.amr"},
+			"look\n[image: photo]\n[voice transcription: hello there]\n[voice (transcription failed)]",
+		},
+		{
+			"named attachment",
+			"listen\n[audio: memo.mp3]",
+			[]string{"/tmp/memo.mp3"},
+			"listen\n[voice transcription: hello there]",
+		},
+		{
+			"no marker",
+			"hi",
+			[]string{"/tmp/voice.ogg"},
+			"hi\n[voice transcription: hello there]",
+		},
+	}
+	for _, tt := range tests {
+		if got := ch.transcribeMedia(tt.content, tt.media); got != tt.want {
+			t.Errorf("%s: content = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// stallingTranscriber blocks until its context is cancelled.
+type stallingTranscriber struct {
+	started chan struct{}
+}
+
+func (s *stallingTranscriber) Name() string { return "stalling" }
+
+func (s *stallingTranscriber) Transcribe(ctx context.Context, path string) (*voice.TranscriptionResponse, error) {
+	close(s.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestBaseChannelTranscribeMedia_CancelledByStop(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	ch := NewBaseChannel("test", nil, msgBus, nil)
+	tr := &stallingTranscriber{started: make(chan struct{})}
+	ch.setTranscriber(tr)
+	ch.setRunning(true)
+
+	go ch.HandleMessage("user", "chat", "[voice]", []string{"/tmp/a.ogg"}, nil)
+	<-tr.started
+	ch.setRunning(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("stopping the channel did not end the transcription")
+	}
+	if msg.Content != "[voice (transcription failed)]" {
+		t.Errorf("Content = %q", msg.Content)
+	}
+}
+
+type testChannel struct {
+	*BaseChannel
+}
+
+func (c *testChannel) Start(ctx context.Context) error                         { return nil }
+func (c *testChannel) Stop(ctx context.Context) error                          { return nil }
+func (c *testChannel) Send(ctx context.Context, msg bus.OutboundMessage) error { return nil }
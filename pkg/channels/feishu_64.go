@@ -6,6 +6,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"sync"
 	"time"
 
@@ -147,6 +148,14 @@ func (c *FeishuChannel) handleMessageReceive(_ context.Context, event *larkim.P2
 	}
 
 	content := extractFeishuMessageContent(message)
+	var mediaPaths []string
+	if stringValue(message.MessageType) == "audio" {
+		content = "[voice]"
+		if localPath := c.downloadAudio(message); localPath != "" {
+			defer os.Remove(localPath)
+			mediaPaths = append(mediaPaths, localPath)
+		}
+	}
 	if content == "" {
 		content = "[empty message]"
 	}
@@ -171,10 +180,43 @@ func (c *FeishuChannel) handleMessageReceive(_ context.Context, event *larkim.P2
 		"preview":   utils.Truncate(content, 80),
 	})
 
-	c.HandleMessage(senderID, chatID, content, nil, metadata)
+	c.HandleMessage(senderID, chatID, content, mediaPaths, metadata)
 	return nil
 }
 
+// downloadAudio saves the voice clip of an audio message to a temp file.
+func (c *FeishuChannel) downloadAudio(message *larkim.EventMessage) string {
+	var payload struct {
+		FileKey string `json:"file_key"`
+	}
+	if err := json.Unmarshal([]byte(stringValue(message.Content)), &payload); err != nil || payload.FileKey == "" {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	req := larkim.NewGetMessageResourceReqBuilder().
+		MessageId(stringValue(message.MessageId)).
+		FileKey(payload.FileKey).
+		Type("file").
+		Build()
+	resp, err := c.client.Im.MessageResource.Get(ctx, req)
+	if err != nil {
+		logger.ErrorCF("feishu", "Failed to download audio", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return ""
+	}
+	if !resp.Success() {
+		logger.ErrorCF("feishu", "Failed to download audio", map[string]interface{}{
+			"code": resp.Code,
+			"msg":  resp.Msg,
+		})
+		return ""
+	}
+	return utils.SaveMediaFile(resp.File, "voice.opus", "feishu")
+}
+
 func extractFeishuSenderID(sender *larkim.EventSender) string {
 	if sender == nil || sender.SenderId == nil {
 		return ""
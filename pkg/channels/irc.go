@@ -0,0 +1,875 @@
+package channels
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	ircMaxLineBytes       = 512
+	ircMaxHostLen         = 63
+	ircDialTimeout        = 15 * time.Second
+	ircReadTimeout        = 5 * time.Minute
+	ircPingInterval       = 90 * time.Second
+	ircRejoinDelay        = 5 * time.Second
+	ircDefaultReconnect   = 15 * time.Second
+	ircMaxReconnect       = 5 * time.Minute
+	ircDefaultFloodBurst  = 4
+	ircDefaultFloodDelay  = 1500 * time.Millisecond
+	ircSendQueueSize      = 256
+	ircSASLChunkSize      = 400
+	ircDefaultNetworkName = "irc"
+)
+
+// IRCChannel bridges one or more IRC networks. Chat IDs have the form
+// "<network>/<target>" where target is a channel ("#ops") or a nick for queries.
+type IRCChannel struct {
+	*BaseChannel
+	config   config.IRCConfig
+	networks map[string]*ircNetwork
+	order    []string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// ircNetwork holds the connection state for a single IRC network.
+type ircNetwork struct {
+	channel   *IRCChannel
+	cfg       config.IRCNetworkConfig
+	name      string
+	conn      net.Conn
+	nick      string
+	connected bool
+	mu        sync.Mutex
+	writeMu   sync.Mutex
+	sendQueue chan string
+	limiter   *ircFloodLimiter
+}
+
+// ircMessage is a parsed IRC protocol line.
+type ircMessage struct {
+	Prefix  string
+	Command string
+	Params  []string
+}
+
+func NewIRCChannel(cfg config.IRCConfig, messageBus *bus.MessageBus) (*IRCChannel, error) {
+	if len(cfg.Networks) == 0 {
+		return nil, fmt.Errorf("irc: at least one network must be configured")
+	}
+
+	base := NewBaseChannel("irc", cfg, messageBus, cfg.AllowFrom)
+	c := &IRCChannel{
+		BaseChannel: base,
+		config:      cfg,
+		networks:    make(map[string]*ircNetwork),
+	}
+
+	for _, netCfg := range cfg.Networks {
+		name := strings.TrimSpace(netCfg.Name)
+		if name == "" {
+			if len(cfg.Networks) > 1 {
+				return nil, fmt.Errorf("irc: network name is required when multiple networks are configured")
+			}
+			name = ircDefaultNetworkName
+		}
+		if strings.Contains(name, "/") {
+			return nil, fmt.Errorf("irc: network name %q must not contain '/'", name)
+		}
+		if netCfg.Server == "" {
+			return nil, fmt.Errorf("irc: network %s has no server configured", name)
+		}
+		if netCfg.Nick == "" {
+			return nil, fmt.Errorf("irc: network %s has no nick configured", name)
+		}
+		if _, exists := c.networks[name]; exists {
+			return nil, fmt.Errorf("irc: duplicate network name %s", name)
+		}
+
+		burst := netCfg.FloodBurst
+		if burst <= 0 {
+			burst = ircDefaultFloodBurst
+		}
+		delay := time.Duration(netCfg.FloodDelayMS) * time.Millisecond
+		if delay <= 0 {
+			delay = ircDefaultFloodDelay
+		}
+
+		c.networks[name] = &ircNetwork{
+			channel:   c,
+			cfg:       netCfg,
+			name:      name,
+			nick:      netCfg.Nick,
+			sendQueue: make(chan string, ircSendQueueSize),
+			limiter:   newIRCFloodLimiter(burst, delay),
+		}
+		c.order = append(c.order, name)
+	}
+
+	return c, nil
+}
+
+func (c *IRCChannel) Start(ctx context.Context) error {
+	logger.InfoCF("irc", "Starting IRC channel", map[string]interface{}{
+		"networks": c.order,
+	})
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	for _, name := range c.order {
+		n := c.networks[name]
+		c.wg.Add(2)
+		go func() {
+			defer c.wg.Done()
+			n.run(c.ctx)
+		}()
+		go func() {
+			defer c.wg.Done()
+			n.writeLoop(c.ctx)
+		}()
+	}
+
+	c.setRunning(true)
+	logger.InfoC("irc", "IRC channel started")
+	return nil
+}
+
+func (c *IRCChannel) Stop(ctx context.Context) error {
+	logger.InfoC("irc", "Stopping IRC channel")
+	c.setRunning(false)
+
+	for _, name := range c.order {
+		c.networks[name].quit("Shutting down")
+	}
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	for _, name := range c.order {
+		c.networks[name].closeConn()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		logger.WarnC("irc", "Timed out waiting for IRC connections to close")
+	}
+
+	logger.InfoC("irc", "IRC channel stopped")
+	return nil
+}
+
+func (c *IRCChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("irc channel not running")
+	}
+
+	n, target, err := c.resolveChatID(msg.ChatID)
+	if err != nil {
+		return err
+	}
+
+	if !n.isConnected() {
+		return fmt.Errorf("irc network %s not connected", n.name)
+	}
+
+	for _, line := range splitIRCMessage(msg.Content, n.maxPayload(target)) {
+		select {
+		case n.sendQueue <- "PRIVMSG " + target + " :" + line:
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			return fmt.Errorf("irc send queue full for network %s", n.name)
+		}
+	}
+
+	return nil
+}
+
+// resolveChatID splits a "<network>/<target>" chat ID. A bare target is
+// accepted when only one network is configured.
+func (c *IRCChannel) resolveChatID(chatID string) (*ircNetwork, string, error) {
+	name, target, found := strings.Cut(chatID, "/")
+	if !found {
+		if len(c.order) != 1 {
+			return nil, "", fmt.Errorf("invalid irc chat ID %q: expected <network>/<target>", chatID)
+		}
+		name, target = c.order[0], chatID
+	}
+
+	n, ok := c.networks[name]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown irc network %q", name)
+	}
+	if target == "" || strings.ContainsAny(target, " \r\n") {
+		return nil, "", fmt.Errorf("invalid irc target %q", target)
+	}
+	return n, target, nil
+}
+
+func (n *ircNetwork) reconnectInterval() time.Duration {
+	if n.cfg.ReconnectInterval > 0 {
+		return time.Duration(n.cfg.ReconnectInterval) * time.Second
+	}
+	return ircDefaultReconnect
+}
+
+// run keeps the network connected until ctx is cancelled, reconnecting with
+// exponential backoff after failures.
+func (n *ircNetwork) run(ctx context.Context) {
+	backoff := n.reconnectInterval()
+
+	for {
+		registered, err := n.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if registered {
+			backoff = n.reconnectInterval()
+		}
+
+		logger.WarnCF("irc", "Connection lost, reconnecting", map[string]interface{}{
+			"network": n.name,
+			"error":   fmt.Sprintf("%v", err),
+			"retry":   backoff.String(),
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if !registered {
+			backoff *= 2
+			if backoff > ircMaxReconnect {
+				backoff = ircMaxReconnect
+			}
+		}
+	}
+}
+
+func (n *ircNetwork) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: ircDialTimeout}
+	if !n.cfg.TLS {
+		return dialer.Dial("tcp", n.cfg.Server)
+	}
+
+	host, _, err := net.SplitHostPort(n.cfg.Server)
+	if err != nil {
+		host = n.cfg.Server
+	}
+	return tls.DialWithDialer(dialer, "tcp", n.cfg.Server, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: n.cfg.TLSSkipVerify,
+	})
+}
+
+// session runs a single connection until it fails. It reports whether the
+// connection completed registration so the caller can reset its backoff.
+func (n *ircNetwork) session(ctx context.Context) (bool, error) {
+	conn, err := n.dial()
+	if err != nil {
+		return false, err
+	}
+
+	n.mu.Lock()
+	n.conn = conn
+	n.nick = n.cfg.Nick
+	n.connected = false
+	n.mu.Unlock()
+
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		n.mu.Lock()
+		if n.conn == conn {
+			n.conn = nil
+			n.connected = false
+		}
+		n.mu.Unlock()
+		conn.Close()
+	}()
+
+	go func() {
+		<-sessionCtx.Done()
+		conn.Close()
+	}()
+
+	logger.InfoCF("irc", "Connected, registering", map[string]interface{}{
+		"network": n.name,
+		"server":  n.cfg.Server,
+		"tls":     n.cfg.TLS,
+	})
+
+	if err := n.register(); err != nil {
+		return false, err
+	}
+
+	go n.pinger(sessionCtx)
+
+	registered := false
+	reader := bufio.NewReader(conn)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(ircReadTimeout))
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return registered, err
+		}
+
+		msg, ok := parseIRCLine(line)
+		if !ok {
+			continue
+		}
+
+		done, err := n.handle(sessionCtx, msg)
+		if err != nil {
+			return registered, err
+		}
+		if done {
+			registered = true
+		}
+	}
+}
+
+func (n *ircNetwork) register() error {
+	user := n.cfg.User
+	if user == "" {
+		user = n.cfg.Nick
+	}
+	realName := n.cfg.RealName
+	if realName == "" {
+		realName = "PicoClaw"
+	}
+
+	if n.saslEnabled() {
+		if err := n.writeRaw("CAP REQ :sasl"); err != nil {
+			return err
+		}
+	}
+	if n.cfg.Password != "" {
+		if err := n.writeRaw("PASS " + n.cfg.Password); err != nil {
+			return err
+		}
+	}
+	if err := n.writeRaw("NICK " + n.cfg.Nick); err != nil {
+		return err
+	}
+	return n.writeRaw("USER " + user + " 0 * :" + realName)
+}
+
+func (n *ircNetwork) saslEnabled() bool {
+	return n.cfg.SASLUser != "" && n.cfg.SASLPassword != ""
+}
+
+// handle processes one server message. It returns true once registration
+// has completed (RPL_WELCOME).
+func (n *ircNetwork) handle(ctx context.Context, msg ircMessage) (bool, error) {
+	switch msg.Command {
+	case "PING":
+		return false, n.writeRaw("PONG :" + msg.param(0))
+
+	case "CAP":
+		return false, n.handleCAP(msg)
+
+	case "AUTHENTICATE":
+		if msg.param(0) == "+" {
+			return false, n.sendSASLPlain()
+		}
+
+	case "903": // RPL_SASLSUCCESS
+		logger.InfoCF("irc", "SASL authentication succeeded", map[string]interface{}{
+			"network": n.name,
+		})
+		return false, n.writeRaw("CAP END")
+
+	case "902", "904", "905", "906", "908": // SASL failures
+		_ = n.writeRaw("CAP END")
+		return false, fmt.Errorf("SASL authentication failed: %s", msg.trailing())
+
+	case "001": // RPL_WELCOME
+		n.mu.Lock()
+		if nick := msg.param(0); nick != "" {
+			n.nick = nick
+		}
+		n.connected = true
+		n.mu.Unlock()
+		logger.InfoCF("irc", "Registered with network", map[string]interface{}{
+			"network": n.name,
+			"nick":    n.currentNick(),
+		})
+		n.joinChannels()
+		return true, nil
+
+	case "433": // ERR_NICKNAMEINUSE
+		if !n.isConnected() {
+			n.mu.Lock()
+			n.nick += "_"
+			nick := n.nick
+			n.mu.Unlock()
+			return false, n.writeRaw("NICK " + nick)
+		}
+
+	case "NICK":
+		if strings.EqualFold(msg.nick(), n.currentNick()) {
+			n.mu.Lock()
+			n.nick = msg.param(0)
+			n.mu.Unlock()
+		}
+
+	case "JOIN":
+		if strings.EqualFold(msg.nick(), n.currentNick()) {
+			logger.InfoCF("irc", "Joined channel", map[string]interface{}{
+				"network": n.name,
+				"channel": msg.param(0),
+			})
+		}
+
+	case "KICK":
+		if strings.EqualFold(msg.param(1), n.currentNick()) {
+			channel := msg.param(0)
+			logger.WarnCF("irc", "Kicked from channel, rejoining", map[string]interface{}{
+				"network": n.name,
+				"channel": channel,
+				"reason":  msg.trailing(),
+			})
+			go func() {
+				select {
+				case <-ctx.Done():
+				case <-time.After(ircRejoinDelay):
+					_ = n.writeRaw("JOIN " + channel)
+				}
+			}()
+		}
+
+	case "PRIVMSG":
+		n.handlePrivmsg(msg)
+
+	case "ERROR":
+		return false, fmt.Errorf("server error: %s", msg.trailing())
+	}
+
+	return false, nil
+}
+
+func (n *ircNetwork) handleCAP(msg ircMessage) error {
+	if len(msg.Params) < 3 {
+		return nil
+	}
+	sub := strings.ToUpper(msg.Params[1])
+	caps := strings.Fields(msg.trailing())
+
+	hasSASL := false
+	for _, capName := range caps {
+		if strings.EqualFold(capName, "sasl") {
+			hasSASL = true
+		}
+	}
+	if !hasSASL {
+		return nil
+	}
+
+	switch sub {
+	case "ACK":
+		return n.writeRaw("AUTHENTICATE PLAIN")
+	case "NAK":
+		_ = n.writeRaw("CAP END")
+		return fmt.Errorf("server does not support SASL")
+	}
+	return nil
+}
+
+// sendSASLPlain sends the PLAIN credentials, chunked to 400 bytes as required
+// by the IRCv3 SASL specification.
+func (n *ircNetwork) sendSASLPlain() error {
+	payload := n.cfg.SASLUser + "\x00" + n.cfg.SASLUser + "\x00" + n.cfg.SASLPassword
+	encoded := base64.StdEncoding.EncodeToString([]byte(payload))
+
+	for len(encoded) >= ircSASLChunkSize {
+		if err := n.writeRaw("AUTHENTICATE " + encoded[:ircSASLChunkSize]); err != nil {
+			return err
+		}
+		encoded = encoded[ircSASLChunkSize:]
+	}
+	if encoded == "" {
+		return n.writeRaw("AUTHENTICATE +")
+	}
+	return n.writeRaw("AUTHENTICATE " + encoded)
+}
+
+func (n *ircNetwork) joinChannels() {
+	for _, ch := range n.cfg.Channels {
+		ch = strings.TrimSpace(ch)
+		if ch == "" {
+			continue
+		}
+		if err := n.writeRaw("JOIN " + ch); err != nil {
+			logger.ErrorCF("irc", "Failed to join channel", map[string]interface{}{
+				"network": n.name,
+				"channel": ch,
+				"error":   err.Error(),
+			})
+		}
+	}
+}
+
+func (n *ircNetwork) handlePrivmsg(msg ircMessage) {
+	sender := msg.nick()
+	target := msg.param(0)
+	text := msg.trailing()
+	if sender == "" || target == "" || text == "" {
+		return
+	}
+
+	nick := n.currentNick()
+	if strings.EqualFold(sender, nick) {
+		return
+	}
+
+	// Ignore CTCP requests (VERSION, ACTION, ...)
+	if strings.HasPrefix(text, "\x01") {
+		return
+	}
+
+	isChannel := isIRCChannelName(target)
+	content := text
+	if isChannel {
+		stripped, mentioned := extractIRCMention(text, nick)
+		if !mentioned {
+			return
+		}
+		content = stripped
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return
+	}
+
+	chatID := n.name + "/" + sender
+	peerKind := "direct"
+	peerID := n.name + "/" + sender
+	if isChannel {
+		chatID = n.name + "/" + target
+		peerKind = "channel"
+		peerID = chatID
+	}
+
+	user, host := msg.userHost()
+	metadata := map[string]string{
+		"platform":  "irc",
+		"network":   n.name,
+		"nick":      sender,
+		"user":      user,
+		"host":      host,
+		"target":    target,
+		"peer_kind": peerKind,
+		"peer_id":   peerID,
+	}
+
+	logger.DebugCF("irc", "Received message", map[string]interface{}{
+		"network": n.name,
+		"sender":  sender,
+		"chat_id": chatID,
+		"preview": utils.Truncate(content, 50),
+	})
+
+	// Sender ID uses the "id|username" compound form so allow_from can list
+	// either "network/nick" or just "nick".
+	n.channel.HandleMessage(n.name+"/"+sender+"|"+sender, chatID, content, nil, metadata)
+}
+
+func (n *ircNetwork) pinger(ctx context.Context) {
+	ticker := time.NewTicker(ircPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := n.writeRaw("PING :picoclaw"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeLoop drains the outbound PRIVMSG queue, applying flood protection.
+func (n *ircNetwork) writeLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line := <-n.sendQueue:
+			if wait := n.limiter.reserve(time.Now()); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+			}
+			if err := n.writeRaw(line); err != nil {
+				logger.ErrorCF("irc", "Failed to send message", map[string]interface{}{
+					"network": n.name,
+					"error":   err.Error(),
+				})
+			}
+		}
+	}
+}
+
+// writeRaw writes a single protocol line, stripping characters that would
+// allow injecting additional commands.
+func (n *ircNetwork) writeRaw(line string) error {
+	line = strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == 0 {
+			return -1
+		}
+		return r
+	}, line)
+
+	n.mu.Lock()
+	conn := n.conn
+	n.mu.Unlock()
+	if conn == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	n.writeMu.Lock()
+	defer n.writeMu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err := conn.Write([]byte(line + "\r\n"))
+	return err
+}
+
+func (n *ircNetwork) quit(reason string) {
+	if n.isConnected() {
+		_ = n.writeRaw("QUIT :" + reason)
+	}
+}
+
+func (n *ircNetwork) closeConn() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn = nil
+	}
+	n.connected = false
+}
+
+func (n *ircNetwork) isConnected() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.connected
+}
+
+func (n *ircNetwork) currentNick() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.nick
+}
+
+// maxPayload returns how many bytes of text fit in one PRIVMSG to target once
+// the server has prepended our ":nick!user@host" prefix.
+func (n *ircNetwork) maxPayload(target string) int {
+	user := n.cfg.User
+	if user == "" {
+		user = n.cfg.Nick
+	}
+	overhead := len(":") + len(n.currentNick()) + len("!") + len(user) + len("@") + ircMaxHostLen +
+		len(" PRIVMSG ") + len(target) + len(" :") + len("\r\n")
+	return ircMaxLineBytes - overhead
+}
+
+// splitIRCMessage breaks content into single-line chunks of at most maxLen
+// bytes, since IRC messages cannot contain newlines.
+func splitIRCMessage(content string, maxLen int) []string {
+	if maxLen < 32 {
+		maxLen = 32
+	}
+
+	var lines []string
+	for _, chunk := range utils.SplitMessage(content, maxLen) {
+		for _, line := range strings.Split(chunk, "\n") {
+			line = strings.TrimRight(line, "\r \t")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseIRCLine parses a raw protocol line, discarding IRCv3 message tags.
+func parseIRCLine(line string) (ircMessage, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "@") {
+		_, rest, ok := strings.Cut(line, " ")
+		if !ok {
+			return ircMessage{}, false
+		}
+		line = rest
+	}
+
+	var msg ircMessage
+	if strings.HasPrefix(line, ":") {
+		prefix, rest, ok := strings.Cut(line[1:], " ")
+		if !ok {
+			return ircMessage{}, false
+		}
+		msg.Prefix = prefix
+		line = rest
+	}
+
+	line = strings.TrimLeft(line, " ")
+	for line != "" {
+		if strings.HasPrefix(line, ":") {
+			msg.Params = append(msg.Params, line[1:])
+			break
+		}
+		param, rest, _ := strings.Cut(line, " ")
+		if msg.Command == "" {
+			msg.Command = strings.ToUpper(param)
+		} else if param != "" {
+			msg.Params = append(msg.Params, param)
+		}
+		line = rest
+	}
+
+	return msg, msg.Command != ""
+}
+
+func (m ircMessage) param(i int) string {
+	if i < len(m.Params) {
+		return m.Params[i]
+	}
+	return ""
+}
+
+func (m ircMessage) trailing() string {
+	if len(m.Params) == 0 {
+		return ""
+	}
+	return m.Params[len(m.Params)-1]
+}
+
+func (m ircMessage) nick() string {
+	nick, _, _ := strings.Cut(m.Prefix, "!")
+	return nick
+}
+
+func (m ircMessage) userHost() (string, string) {
+	_, rest, ok := strings.Cut(m.Prefix, "!")
+	if !ok {
+		return "", ""
+	}
+	user, host, _ := strings.Cut(rest, "@")
+	return user, host
+}
+
+func isIRCChannelName(target string) bool {
+	return target != "" && strings.ContainsRune("#&+!", rune(target[0]))
+}
+
+// extractIRCMention reports whether a channel message addresses nick. A
+// leading "nick:" or "nick," address is stripped; a mention elsewhere in the
+// text leaves it unchanged.
+func extractIRCMention(text, nick string) (string, bool) {
+	if nick == "" {
+		return text, false
+	}
+
+	trimmed := strings.TrimSpace(text)
+	if len(trimmed) >= len(nick) && strings.EqualFold(trimmed[:len(nick)], nick) {
+		rest := trimmed[len(nick):]
+		if rest == "" {
+			return "", true
+		}
+		switch rest[0] {
+		case ':', ',':
+			return strings.TrimSpace(rest[1:]), true
+		case ' ':
+			return strings.TrimSpace(rest), true
+		}
+	}
+
+	lower := strings.ToLower(text)
+	lowerNick := strings.ToLower(nick)
+	for idx := 0; idx < len(lower); {
+		pos := strings.Index(lower[idx:], lowerNick)
+		if pos < 0 {
+			break
+		}
+		start := idx + pos
+		end := start + len(lowerNick)
+		if (start == 0 || !isIRCNickChar(lower[start-1])) && (end == len(lower) || !isIRCNickChar(lower[end])) {
+			return text, true
+		}
+		idx = start + 1
+	}
+
+	return text, false
+}
+
+func isIRCNickChar(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') ||
+		strings.IndexByte("-_[]\\`^{}|", b) >= 0
+}
+
+// ircFloodLimiter is a token bucket that allows a short burst of lines and
+// then paces the rest so the server does not disconnect us for flooding.
+type ircFloodLimiter struct {
+	burst    float64
+	interval time.Duration
+	tokens   float64
+	last     time.Time
+}
+
+func newIRCFloodLimiter(burst int, interval time.Duration) *ircFloodLimiter {
+	return &ircFloodLimiter{
+		burst:    float64(burst),
+		interval: interval,
+		tokens:   float64(burst),
+	}
+}
+
+// reserve takes one token and returns how long the caller must wait before
+// sending. It is only called from the network's write loop.
+func (l *ircFloodLimiter) reserve(now time.Time) time.Duration {
+	if !l.last.IsZero() {
+		l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens * float64(l.interval))
+}
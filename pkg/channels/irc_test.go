@@ -0,0 +1,414 @@
+package channels
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestParseIRCLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		prefix  string
+		command string
+		params  []string
+	}{
+		{
+			name:    "ping",
+			line:    "PING :irc.example.net\r\n",
+			command: "PING",
+			params:  []string{"irc.example.net"},
+		},
+		{
+			name:    "privmsg with prefix",
+			line:    ":alice!~a@host.example PRIVMSG #ops :picoclaw: status?",
+			prefix:  "alice!~a@host.example",
+			command: "PRIVMSG",
+			params:  []string{"#ops", "picoclaw: status?"},
+		},
+		{
+			name:    "numeric with tags",
+			line:    "@time=2026-01-01T00:00:00Z :srv 001 picoclaw :Welcome",
+			prefix:  "srv",
+			command: "001",
+			params:  []string{"picoclaw", "Welcome"},
+		},
+		{
+			name:    "cap ack",
+			line:    ":srv CAP * ACK :sasl",
+			prefix:  "srv",
+			command: "CAP",
+			params:  []string{"*", "ACK", "sasl"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, ok := parseIRCLine(tt.line)
+			if !ok {
+				t.Fatalf("parseIRCLine(%q) failed", tt.line)
+			}
+			if msg.Prefix != tt.prefix || msg.Command != tt.command {
+				t.Errorf("got prefix=%q command=%q, want %q %q", msg.Prefix, msg.Command, tt.prefix, tt.command)
+			}
+			if strings.Join(msg.Params, "|") != strings.Join(tt.params, "|") {
+				t.Errorf("params = %q, want %q", msg.Params, tt.params)
+			}
+		})
+	}
+}
+
+func TestExtractIRCMention(t *testing.T) {
+	tests := []struct {
+		text      string
+		want      string
+		mentioned bool
+	}{
+		{"picoclaw: how are you", "how are you", true},
+		{"PicoClaw, ping", "ping", true},
+		{"hey picoclaw what's up", "hey picoclaw what's up", true},
+		{"picoclaws are cute", "picoclaws are cute", false},
+		{"nothing to see", "nothing to see", false},
+		{"picoclaw", "", true},
+	}
+
+	for _, tt := range tests {
+		got, mentioned := extractIRCMention(tt.text, "picoclaw")
+		if got != tt.want || mentioned != tt.mentioned {
+			t.Errorf("extractIRCMention(%q) = (%q, %v), want (%q, %v)", tt.text, got, mentioned, tt.want, tt.mentioned)
+		}
+	}
+}
+
+func TestSplitIRCMessage(t *testing.T) {
+	content := "first line\n\n" + strings.Repeat("word ", 300) + "\nlast line"
+	lines := splitIRCMessage(content, 200)
+
+	if len(lines) < 3 {
+		t.Fatalf("expected message to be split, got %d lines", len(lines))
+	}
+	for _, line := range lines {
+		if len(line) > 200 {
+			t.Errorf("line exceeds limit: %d bytes", len(line))
+		}
+		if strings.ContainsAny(line, "\r\n") {
+			t.Errorf("line contains newline: %q", line)
+		}
+	}
+	if lines[0] != "first line" || lines[len(lines)-1] != "last line" {
+		t.Errorf("unexpected first/last lines: %q / %q", lines[0], lines[len(lines)-1])
+	}
+}
+
+func TestIRCFloodLimiter(t *testing.T) {
+	l := newIRCFloodLimiter(2, time.Second)
+	now := time.Unix(1000, 0)
+
+	if d := l.reserve(now); d != 0 {
+		t.Fatalf("first line delayed by %v", d)
+	}
+	if d := l.reserve(now); d != 0 {
+		t.Fatalf("second line delayed by %v", d)
+	}
+	if d := l.reserve(now); d != time.Second {
+		t.Fatalf("third line delay = %v, want 1s", d)
+	}
+	// After waiting out the delay and a full refill period, one more line is free.
+	if d := l.reserve(now.Add(2 * time.Second)); d != 0 {
+		t.Fatalf("line after refill delayed by %v", d)
+	}
+}
+
+// ircStubServer is a minimal IRC server speaking just enough of the protocol
+// to register clients, run SASL PLAIN and record what they send.
+type ircStubServer struct {
+	t        *testing.T
+	ln       net.Listener
+	lines    chan string
+	mu       sync.Mutex
+	conns    []net.Conn
+	saslAuth string
+}
+
+func newIRCStubServer(t *testing.T) *ircStubServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &ircStubServer{t: t, ln: ln, lines: make(chan string, 256)}
+	go s.accept()
+	t.Cleanup(func() {
+		ln.Close()
+		s.mu.Lock()
+		for _, c := range s.conns {
+			c.Close()
+		}
+		s.mu.Unlock()
+	})
+	return s
+}
+
+func (s *ircStubServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *ircStubServer) accept() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.serve(conn)
+	}
+}
+
+func (s *ircStubServer) serve(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	nick := ""
+	capPending := false
+	gotUser := false
+	welcomed := false
+
+	welcome := func() {
+		if !welcomed && gotUser && !capPending {
+			welcomed = true
+			s.writeTo(conn, ":stub 001 "+nick+" :Welcome")
+		}
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.lines <- line
+
+		msg, _ := parseIRCLine(line)
+		switch msg.Command {
+		case "CAP":
+			if msg.param(0) == "REQ" {
+				capPending = true
+				s.writeTo(conn, ":stub CAP * ACK :sasl")
+			} else if msg.param(0) == "END" {
+				capPending = false
+				welcome()
+			}
+		case "AUTHENTICATE":
+			if msg.param(0) == "PLAIN" {
+				s.writeTo(conn, "AUTHENTICATE +")
+			} else {
+				s.mu.Lock()
+				s.saslAuth = msg.param(0)
+				s.mu.Unlock()
+				s.writeTo(conn, ":stub 903 * :SASL authentication successful")
+			}
+		case "NICK":
+			nick = msg.param(0)
+		case "USER":
+			gotUser = true
+			welcome()
+		case "JOIN":
+			s.writeTo(conn, ":"+nick+"!bot@localhost JOIN "+msg.param(0))
+		}
+	}
+}
+
+func (s *ircStubServer) writeTo(conn net.Conn, line string) {
+	conn.Write([]byte(line + "\r\n"))
+}
+
+// broadcast sends a line to the most recently connected client.
+func (s *ircStubServer) broadcast(line string) {
+	s.mu.Lock()
+	conn := s.conns[len(s.conns)-1]
+	s.mu.Unlock()
+	s.writeTo(conn, line)
+}
+
+func (s *ircStubServer) dropClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+}
+
+func (s *ircStubServer) waitFor(prefix string) string {
+	s.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line := <-s.lines:
+			if strings.HasPrefix(line, prefix) {
+				return line
+			}
+		case <-timeout:
+			s.t.Fatalf("timed out waiting for %q", prefix)
+			return ""
+		}
+	}
+}
+
+func consumeInbound(t *testing.T, msgBus *bus.MessageBus) (bus.InboundMessage, bool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	return msgBus.ConsumeInbound(ctx)
+}
+
+func startTestIRCChannel(t *testing.T, netCfg config.IRCNetworkConfig) (*IRCChannel, *bus.MessageBus) {
+	t.Helper()
+	msgBus := bus.NewMessageBus()
+	ch, err := NewIRCChannel(config.IRCConfig{
+		Enabled:  true,
+		Networks: []config.IRCNetworkConfig{netCfg},
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewIRCChannel: %v", err)
+	}
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { ch.Stop(context.Background()) })
+	return ch, msgBus
+}
+
+func waitConnected(t *testing.T, n *ircNetwork) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !n.isConnected() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for registration")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestIRCChannelSASLJoinAndReceive(t *testing.T) {
+	srv := newIRCStubServer(t)
+	ch, msgBus := startTestIRCChannel(t, config.IRCNetworkConfig{
+		Name:         "stub",
+		Server:       srv.addr(),
+		Nick:         "picoclaw",
+		SASLUser:     "bot",
+		SASLPassword: "secret",
+		Channels:     []string{"#ops"},
+	})
+
+	srv.waitFor("CAP REQ :sasl")
+	srv.waitFor("CAP END")
+	srv.waitFor("JOIN #ops")
+	waitConnected(t, ch.networks["stub"])
+
+	srv.mu.Lock()
+	auth := srv.saslAuth
+	srv.mu.Unlock()
+	decoded, _ := base64.StdEncoding.DecodeString(auth)
+	if string(decoded) != "bot\x00bot\x00secret" {
+		t.Fatalf("unexpected SASL payload %q", decoded)
+	}
+
+	// Channel messages without a mention are ignored.
+	srv.broadcast(":alice!a@host PRIVMSG #ops :just chatting")
+	srv.broadcast(":alice!a@host PRIVMSG #ops :picoclaw: disk usage?")
+	msg, ok := consumeInbound(t, msgBus)
+	if !ok {
+		t.Fatal("expected inbound message for mention")
+	}
+	if msg.Content != "disk usage?" || msg.ChatID != "stub/#ops" || msg.Metadata["peer_kind"] != "channel" {
+		t.Fatalf("unexpected channel message: %+v", msg)
+	}
+
+	// Every query message is delivered.
+	srv.broadcast(":bob!b@host PRIVMSG picoclaw :hello there")
+	msg, ok = consumeInbound(t, msgBus)
+	if !ok {
+		t.Fatal("expected inbound message for query")
+	}
+	if msg.Content != "hello there" || msg.ChatID != "stub/bob" || msg.Metadata["peer_kind"] != "direct" {
+		t.Fatalf("unexpected query message: %+v", msg)
+	}
+}
+
+func TestIRCChannelSendSplitsLongMessages(t *testing.T) {
+	srv := newIRCStubServer(t)
+	ch, _ := startTestIRCChannel(t, config.IRCNetworkConfig{
+		Name:       "stub",
+		Server:     srv.addr(),
+		Nick:       "picoclaw",
+		FloodBurst: 100,
+	})
+	srv.waitFor("USER")
+	waitConnected(t, ch.networks["stub"])
+
+	content := strings.Repeat("lorem ipsum dolor sit amet ", 60)
+	if err := ch.Send(context.Background(), bus.OutboundMessage{Channel: "irc", ChatID: "stub/#ops", Content: content}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	total := 0
+	for total < len(strings.TrimSpace(content))-100 {
+		line := srv.waitFor("PRIVMSG #ops :")
+		// Leave room for the ":nick!user@host " prefix the server prepends.
+		if len(line)+2 > ircMaxLineBytes-ircMaxHostLen {
+			t.Fatalf("line too long: %d bytes", len(line))
+		}
+		total += len(strings.TrimPrefix(line, "PRIVMSG #ops :"))
+	}
+}
+
+func TestIRCChannelReconnectRejoins(t *testing.T) {
+	srv := newIRCStubServer(t)
+	ch, _ := startTestIRCChannel(t, config.IRCNetworkConfig{
+		Name:              "stub",
+		Server:            srv.addr(),
+		Nick:              "picoclaw",
+		Channels:          []string{"#ops", "#alerts"},
+		ReconnectInterval: 1,
+	})
+	srv.waitFor("JOIN #alerts")
+	waitConnected(t, ch.networks["stub"])
+
+	srv.dropClients()
+
+	srv.waitFor("NICK picoclaw")
+	srv.waitFor("JOIN #ops")
+	srv.waitFor("JOIN #alerts")
+}
+
+func TestIRCChannelResolveChatID(t *testing.T) {
+	ch, err := NewIRCChannel(config.IRCConfig{
+		Networks: []config.IRCNetworkConfig{
+			{Name: "libera", Server: "irc.libera.chat:6697", Nick: "bot"},
+			{Name: "oftc", Server: "irc.oftc.net:6697", Nick: "bot"},
+		},
+	}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatalf("NewIRCChannel: %v", err)
+	}
+
+	n, target, err := ch.resolveChatID("oftc/#debian")
+	if err != nil || n.name != "oftc" || target != "#debian" {
+		t.Fatalf("resolveChatID = (%v, %q, %v)", n, target, err)
+	}
+	if _, _, err := ch.resolveChatID("#debian"); err == nil {
+		t.Error("expected error for bare target with multiple networks")
+	}
+	if _, _, err := ch.resolveChatID("efnet/#x"); err == nil {
+		t.Error("expected error for unknown network")
+	}
+}
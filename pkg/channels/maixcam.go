@@ -2,28 +2,46 @@ package channels
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net"
 	"sync"
+	"time"
+
+	"github.com/google/uuid"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
+// personFrameTimeout bounds how long a person event waits for its frame.
+const personFrameTimeout = 5 * time.Second
+
 type MaixCamChannel struct {
 	*BaseChannel
 	config     config.MaixCamConfig
 	listener   net.Listener
 	clients    map[net.Conn]bool
 	clientsMux sync.RWMutex
+	lastClient net.Conn   // the device that most recently sent a message
+	writeMu    sync.Mutex // serialises writes to the devices
+
+	snapshotsMu sync.Mutex
+	snapshots   map[string]chan MaixCamMessage // pending snapshot requests by ID
 }
 
+// MaixCamMessage is one JSON message between picoclaw and a device. A
+// snapshot request is {"type": "snapshot", "request_id": "..."}; the device
+// answers with the same type and request_id and data.image holding a
+// base64 JPEG, or data.error. person_detected events may carry data.image
+// too.
 type MaixCamMessage struct {
 	Type      string                 `json:"type"`
 	Tips      string                 `json:"tips"`
 	Timestamp float64                `json:"timestamp"`
+	RequestID string                 `json:"request_id,omitempty"`
 	Data      map[string]interface{} `json:"data"`
 }
 
@@ -34,6 +52,7 @@ func NewMaixCamChannel(cfg config.MaixCamConfig, bus *bus.MessageBus) (*MaixCamC
 		BaseChannel: base,
 		config:      cfg,
 		clients:     make(map[net.Conn]bool),
+		snapshots:   make(map[string]chan MaixCamMessage),
 	}, nil
 }
 
@@ -98,6 +117,9 @@ func (c *MaixCamChannel) handleConnection(conn net.Conn, ctx context.Context) {
 		conn.Close()
 		c.clientsMux.Lock()
 		delete(c.clients, conn)
+		if c.lastClient == conn {
+			c.lastClient = nil
+		}
 		c.clientsMux.Unlock()
 		logger.DebugC("maixcam", "Connection closed")
 	}()
@@ -125,9 +147,17 @@ func (c *MaixCamChannel) handleConnection(conn net.Conn, ctx context.Context) {
 }
 
 func (c *MaixCamChannel) processMessage(msg MaixCamMessage, conn net.Conn) {
+	c.clientsMux.Lock()
+	c.lastClient = conn
+	c.clientsMux.Unlock()
+
 	switch msg.Type {
 	case "person_detected":
-		c.handlePersonDetection(msg)
+		// Fetching the frame needs this connection's reader, so the event is
+		// handled on its own goroutine.
+		go c.handlePersonDetection(msg, conn)
+	case "snapshot":
+		c.handleSnapshot(msg)
 	case "heartbeat":
 		logger.DebugC("maixcam", "Received heartbeat")
 	case "status":
@@ -139,7 +169,7 @@ func (c *MaixCamChannel) processMessage(msg MaixCamMessage, conn net.Conn) {
 	}
 }
 
-func (c *MaixCamChannel) handlePersonDetection(msg MaixCamMessage) {
+func (c *MaixCamChannel) handlePersonDetection(msg MaixCamMessage, conn net.Conn) {
 	logger.InfoCF("maixcam", "", map[string]interface{}{
 		"timestamp": msg.Timestamp,
 		"data":      msg.Data,
@@ -163,6 +193,7 @@ func (c *MaixCamChannel) handlePersonDetection(msg MaixCamMessage) {
 		classInfo, score*100, x, y, w, h)
 
 	metadata := map[string]string{
+		"event":     "person_detected",
 		"timestamp": fmt.Sprintf("%.0f", msg.Timestamp),
 		"class_id":  fmt.Sprintf("%.0f", msg.Data["class_id"]),
 		"score":     fmt.Sprintf("%.2f", score),
@@ -172,7 +203,126 @@ func (c *MaixCamChannel) handlePersonDetection(msg MaixCamMessage) {
 		"h":         fmt.Sprintf("%.0f", h),
 	}
 
-	c.HandleMessage(senderID, chatID, content, []string{}, metadata)
+	media := []string{}
+	if frame := c.personFrame(msg, conn); frame != "" {
+		media = append(media, frame)
+		content += "\nThe camera frame is attached."
+	}
+
+	c.HandleMessage(senderID, chatID, content, media, metadata)
+}
+
+// personFrame returns the frame of a person event as a data URL, from the
+// event itself or, with person_frame enabled, from a snapshot of the device
+// that sent it. Returns "" without a frame.
+func (c *MaixCamChannel) personFrame(msg MaixCamMessage, conn net.Conn) string {
+	image, err := decodeSnapshotImage(msg)
+	if err != nil && c.config.PersonFrame {
+		ctx, cancel := context.WithTimeout(context.Background(), personFrameTimeout)
+		defer cancel()
+		image, err = c.snapshotFrom(ctx, conn)
+	}
+	if err != nil {
+		if c.config.PersonFrame {
+			logger.WarnCF("maixcam", "No frame for person detection", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		return ""
+	}
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(image)
+}
+
+// Snapshot asks the most recently active device for a JPEG frame.
+func (c *MaixCamChannel) Snapshot(ctx context.Context) ([]byte, error) {
+	if !c.IsRunning() {
+		return nil, fmt.Errorf("maixcam channel not running")
+	}
+	c.clientsMux.RLock()
+	conn := c.lastClient
+	if conn == nil {
+		for client := range c.clients {
+			conn = client
+			break
+		}
+	}
+	c.clientsMux.RUnlock()
+	if conn == nil {
+		return nil, fmt.Errorf("no connected MaixCam devices")
+	}
+	return c.snapshotFrom(ctx, conn)
+}
+
+func (c *MaixCamChannel) snapshotFrom(ctx context.Context, conn net.Conn) ([]byte, error) {
+	id := uuid.New().String()[:8]
+	reply := make(chan MaixCamMessage, 1)
+	c.snapshotsMu.Lock()
+	c.snapshots[id] = reply
+	c.snapshotsMu.Unlock()
+	defer func() {
+		c.snapshotsMu.Lock()
+		delete(c.snapshots, id)
+		c.snapshotsMu.Unlock()
+	}()
+
+	request := map[string]interface{}{
+		"type":       "snapshot",
+		"timestamp":  float64(time.Now().Unix()),
+		"request_id": id,
+	}
+	if err := c.writeJSON(conn, request); err != nil {
+		return nil, fmt.Errorf("failed to request snapshot: %w", err)
+	}
+
+	select {
+	case msg := <-reply:
+		return decodeSnapshotImage(msg)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("MaixCam did not send a snapshot: %w (the device firmware must support the snapshot command)", ctx.Err())
+	}
+}
+
+func (c *MaixCamChannel) handleSnapshot(msg MaixCamMessage) {
+	c.snapshotsMu.Lock()
+	reply, ok := c.snapshots[msg.RequestID]
+	c.snapshotsMu.Unlock()
+	if !ok {
+		logger.DebugCF("maixcam", "Ignoring unrequested snapshot", map[string]interface{}{
+			"request_id": msg.RequestID,
+		})
+		return
+	}
+	select {
+	case reply <- msg:
+	default:
+	}
+}
+
+// decodeSnapshotImage returns the image in a snapshot reply or event.
+func decodeSnapshotImage(msg MaixCamMessage) ([]byte, error) {
+	if errMsg, ok := msg.Data["error"].(string); ok && errMsg != "" {
+		return nil, fmt.Errorf("MaixCam snapshot failed: %s", errMsg)
+	}
+	encoded, _ := msg.Data["image"].(string)
+	if encoded == "" {
+		return nil, fmt.Errorf("message has no image")
+	}
+	image, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot image: %w", err)
+	}
+	return image, nil
+}
+
+func (c *MaixCamChannel) writeJSON(conn net.Conn, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = conn.Write(data)
+	return err
 }
 
 func (c *MaixCamChannel) handleStatusUpdate(msg MaixCamMessage) {
@@ -221,14 +371,9 @@ func (c *MaixCamChannel) Send(ctx context.Context, msg bus.OutboundMessage) erro
 		"chat_id":   msg.ChatID,
 	}
 
-	data, err := json.Marshal(response)
-	if err != nil {
-		return fmt.Errorf("failed to marshal response: %w", err)
-	}
-
 	var sendErr error
 	for conn := range c.clients {
-		if _, err := conn.Write(data); err != nil {
+		if err := c.writeJSON(conn, response); err != nil {
 			logger.ErrorCF("maixcam", "Failed to send to client", map[string]interface{}{
 				"client": conn.RemoteAddr().String(),
 				"error":  err.Error(),
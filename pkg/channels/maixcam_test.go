@@ -0,0 +1,141 @@
+package channels
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+var testFrame = []byte{0xFF, 0xD8, 0xFF, 0xD9}
+
+// startMaixCam starts a channel and connects a fake device that answers
+// snapshot requests with testFrame.
+func startMaixCam(t *testing.T, cfg config.MaixCamConfig) (*MaixCamChannel, *bus.MessageBus, *json.Encoder) {
+	t.Helper()
+	cfg.Host = "127.0.0.1"
+	msgBus := bus.NewMessageBus()
+	c, err := NewMaixCamChannel(cfg, msgBus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := c.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		c.Stop(context.Background())
+	})
+
+	conn, err := net.Dial("tcp", c.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	enc := json.NewEncoder(conn)
+	go func() {
+		dec := json.NewDecoder(conn)
+		for {
+			var req MaixCamMessage
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			if req.Type == "snapshot" {
+				enc.Encode(MaixCamMessage{Type: "snapshot", RequestID: req.RequestID, Data: map[string]interface{}{
+					"image": base64.StdEncoding.EncodeToString(testFrame),
+				}})
+			}
+		}
+	}()
+
+	enc.Encode(MaixCamMessage{Type: "heartbeat"})
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c.clientsMux.RLock()
+		connected := c.lastClient != nil
+		c.clientsMux.RUnlock()
+		if connected {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("device did not connect")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return c, msgBus, enc
+}
+
+func TestMaixCamSnapshot(t *testing.T) {
+	c, _, _ := startMaixCam(t, config.MaixCamConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	image, err := c.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	if string(image) != string(testFrame) {
+		t.Errorf("Snapshot() = %x, want %x", image, testFrame)
+	}
+}
+
+func TestMaixCamPersonFrame(t *testing.T) {
+	_, msgBus, enc := startMaixCam(t, config.MaixCamConfig{PersonFrame: true})
+
+	enc.Encode(MaixCamMessage{Type: "person_detected", Timestamp: 1700000000, Data: map[string]interface{}{
+		"class_name": "person", "score": 0.9,
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	if msg.Metadata["event"] != "person_detected" || !strings.Contains(msg.Content, "frame is attached") {
+		t.Errorf("message = %+v", msg)
+	}
+	if len(msg.Media) != 1 {
+		t.Fatalf("media = %v, want the snapshot", msg.Media)
+	}
+	if want := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(testFrame); msg.Media[0] != want {
+		t.Errorf("frame = %q, want %q", msg.Media[0], want)
+	}
+}
+
+func TestMaixCamPersonEventImage(t *testing.T) {
+	_, msgBus, enc := startMaixCam(t, config.MaixCamConfig{})
+
+	enc.Encode(MaixCamMessage{Type: "person_detected", Data: map[string]interface{}{
+		"score": 0.8, "image": base64.StdEncoding.EncodeToString(testFrame),
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	if len(msg.Media) != 1 {
+		t.Fatalf("media = %v, want the event's image", msg.Media)
+	}
+}
+
+func TestDecodeSnapshotImage(t *testing.T) {
+	if _, err := decodeSnapshotImage(MaixCamMessage{Data: map[string]interface{}{"error": "camera busy"}}); err == nil || !strings.Contains(err.Error(), "camera busy") {
+		t.Errorf("error reply: %v", err)
+	}
+	if _, err := decodeSnapshotImage(MaixCamMessage{Data: map[string]interface{}{"image": "!!"}}); err == nil {
+		t.Error("invalid base64 accepted")
+	}
+	if _, err := decodeSnapshotImage(MaixCamMessage{}); err == nil {
+		t.Error("message without image accepted")
+	}
+}
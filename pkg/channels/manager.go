@@ -9,12 +9,18 @@ package channels
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
 type Manager struct {
@@ -22,7 +28,24 @@ type Manager struct {
 	bus          *bus.MessageBus
 	config       *config.Config
 	dispatchTask *asyncTask
+	transcriber  voice.Transcriber
+	synthesizer  voice.Synthesizer
 	mu           sync.RWMutex
+
+	// voiceQueues holds, per "channel:chatID", the messages waiting behind a
+	// voice reply that is being synthesized. A chat has an entry while its
+	// voice worker runs.
+	voiceQueues map[string][]bus.OutboundMessage
+	voiceMu     sync.Mutex
+}
+
+// defaultVoiceMaxChars is the longest reply, after Markdown is stripped,
+// that is spoken when voice.tts.max_chars is not set.
+const defaultVoiceMaxChars = 1000
+
+// transcribingChannel is implemented by every channel embedding BaseChannel.
+type transcribingChannel interface {
+	setTranscriber(t voice.Transcriber)
 }
 
 type asyncTask struct {
@@ -36,9 +59,36 @@ func NewManager(cfg *config.Config, messageBus *bus.MessageBus) (*Manager, error
 		config:   cfg,
 	}
 
+	transcriber, err := voice.NewTranscriber(cfg)
+	if err != nil {
+		logger.WarnCF("voice", "Voice transcription disabled", map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else if transcriber != nil {
+		m.transcriber = transcriber
+		logger.InfoCF("voice", "Voice transcription enabled", map[string]interface{}{
+			"backend": transcriber.Name(),
+		})
+	}
+
+	synthesizer, err := voice.NewSynthesizer(cfg)
+	if err != nil {
+		logger.WarnCF("voice", "Voice replies disabled", map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else if synthesizer != nil {
+		m.synthesizer = synthesizer
+		logger.InfoCF("voice", "Voice replies enabled", map[string]interface{}{
+			"backend": synthesizer.Name(),
+		})
+	}
+
 	if err := m.initChannels(); err != nil {
 		return nil, err
 	}
+	for _, channel := range m.channels {
+		m.attachTranscriber(channel)
+	}
 
 	return m, nil
 }
@@ -176,6 +226,19 @@ func (m *Manager) initChannels() error {
 		}
 	}
 
+	if m.config.Channels.IRC.Enabled && len(m.config.Channels.IRC.Networks) > 0 {
+		logger.DebugC("channels", "Attempting to initialize IRC channel")
+		irc, err := NewIRCChannel(m.config.Channels.IRC, m.bus)
+		if err != nil {
+			logger.ErrorCF("channels", "Failed to initialize IRC channel", map[string]interface{}{
+				"error": err.Error(),
+			})
+		} else {
+			m.channels["irc"] = irc
+			logger.InfoC("channels", "IRC channel enabled successfully")
+		}
+	}
+
 	logger.InfoCF("channels", "Channel initialization completed", map[string]interface{}{
 		"enabled_channels": len(m.channels),
 	})
@@ -272,12 +335,164 @@ func (m *Manager) dispatchOutbound(ctx context.Context) {
 				continue
 			}
 
-			if err := channel.Send(ctx, msg); err != nil {
-				logger.ErrorCF("channels", "Error sending message to channel", map[string]interface{}{
-					"channel": msg.Channel,
-					"error":   err.Error(),
-				})
+			if m.queueBehindVoice(ctx, channel, msg) {
+				continue
 			}
+			m.send(ctx, channel, msg)
+		}
+	}
+}
+
+// send delivers msg as text, with its images if it has any.
+func (m *Manager) send(ctx context.Context, channel Channel, msg bus.OutboundMessage) {
+	if len(msg.Media) > 0 {
+		m.sendMedia(ctx, channel, msg)
+		return
+	}
+	if err := channel.Send(ctx, msg); err != nil {
+		logger.ErrorCF("channels", "Error sending message to channel", map[string]interface{}{
+			"channel": msg.Channel,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// queueBehindVoice hands msg to a voice worker for its chat, so speech
+// synthesis does not hold up messages to other chats. It starts a worker
+// for a voice reply, and queues any message for a chat whose worker is
+// still running to keep the chat's messages in order. It returns false
+// when msg should be sent right away.
+func (m *Manager) queueBehindVoice(ctx context.Context, channel Channel, msg bus.OutboundMessage) bool {
+	key := msg.Channel + ":" + msg.ChatID
+	m.voiceMu.Lock()
+	defer m.voiceMu.Unlock()
+	if queue, busy := m.voiceQueues[key]; busy {
+		m.voiceQueues[key] = append(queue, msg)
+		return true
+	}
+	if !msg.Voice || len(msg.Media) > 0 || m.speakableText(channel, msg) == "" {
+		return false
+	}
+	if m.voiceQueues == nil {
+		m.voiceQueues = make(map[string][]bus.OutboundMessage)
+	}
+	m.voiceQueues[key] = nil
+	go m.runVoiceWorker(ctx, channel, key, msg)
+	return true
+}
+
+// runVoiceWorker sends msg and then the messages queued behind it for the
+// same chat, until the queue is empty.
+func (m *Manager) runVoiceWorker(ctx context.Context, channel Channel, key string, msg bus.OutboundMessage) {
+	for {
+		if !msg.Voice || len(msg.Media) > 0 || !m.sendVoice(ctx, channel, msg) {
+			m.send(ctx, channel, msg)
+		}
+
+		m.voiceMu.Lock()
+		queue := m.voiceQueues[key]
+		if len(queue) == 0 {
+			delete(m.voiceQueues, key)
+			m.voiceMu.Unlock()
+			return
+		}
+		msg = queue[0]
+		m.voiceQueues[key] = queue[1:]
+		m.voiceMu.Unlock()
+	}
+}
+
+// sendVoice speaks msg on channels that support voice messages. It returns
+// false when the reply should be sent as text instead: no TTS backend, a
+// channel without voice messages, a reply too long to listen to, or any
+// synthesis or sending error.
+func (m *Manager) sendVoice(ctx context.Context, channel Channel, msg bus.OutboundMessage) bool {
+	text := m.speakableText(channel, msg)
+	if text == "" {
+		return false
+	}
+	sender := channel.(VoiceSender)
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	speech, err := m.synthesizer.Synthesize(ctx, text)
+	if err != nil {
+		logger.ErrorCF("voice", "Speech synthesis failed, sending text", map[string]interface{}{
+			"backend": m.synthesizer.Name(),
+			"error":   err.Error(),
+		})
+		return false
+	}
+	path, err := voice.ToOggOpus(ctx, m.config.Voice.TTS.FFmpeg, speech)
+	if err != nil {
+		logger.ErrorCF("voice", "Speech conversion failed, sending text", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return false
+	}
+	defer os.Remove(path)
+
+	if err := sender.SendVoice(ctx, msg.ChatID, path); err != nil {
+		logger.ErrorCF("voice", "Voice reply failed, sending text", map[string]interface{}{
+			"channel": msg.Channel,
+			"error":   err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// speakableText returns the text to speak for msg, or "" when it should be
+// sent as text: no TTS backend, a channel without voice messages, or a reply
+// too long to listen to.
+func (m *Manager) speakableText(channel Channel, msg bus.OutboundMessage) string {
+	if _, ok := channel.(VoiceSender); !ok || m.synthesizer == nil {
+		return ""
+	}
+	text := voice.SpeakableText(msg.Content)
+	maxChars := m.config.Voice.TTS.MaxChars
+	if maxChars <= 0 {
+		maxChars = defaultVoiceMaxChars
+	}
+	if utf8.RuneCountInString(text) > maxChars {
+		return ""
+	}
+	return text
+}
+
+// sendMedia sends the text of msg followed by its images. Channels that
+// cannot send images get the file names appended to the text instead.
+func (m *Manager) sendMedia(ctx context.Context, channel Channel, msg bus.OutboundMessage) {
+	sender, ok := channel.(PhotoSender)
+	text := msg
+	text.Media = nil
+	if !ok {
+		var names []string
+		for _, path := range msg.Media {
+			names = append(names, filepath.Base(path))
+		}
+		text.Content = strings.TrimSpace(text.Content + "\n\n(images not supported here: " + strings.Join(names, ", ") + ")")
+	}
+
+	if text.Content != "" {
+		if err := channel.Send(ctx, text); err != nil {
+			logger.ErrorCF("channels", "Error sending message to channel", map[string]interface{}{
+				"channel": msg.Channel,
+				"error":   err.Error(),
+			})
+		}
+	}
+	if !ok {
+		return
+	}
+	for _, path := range msg.Media {
+		if err := sender.SendPhoto(ctx, msg.ChatID, path); err != nil {
+			logger.ErrorCF("channels", "Error sending image to channel", map[string]interface{}{
+				"channel": msg.Channel,
+				"path":    path,
+				"error":   err.Error(),
+			})
 		}
 	}
 }
@@ -317,9 +532,17 @@ func (m *Manager) GetEnabledChannels() []string {
 func (m *Manager) RegisterChannel(name string, channel Channel) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.attachTranscriber(channel)
 	m.channels[name] = channel
 }
 
+// attachTranscriber lets a channel transcribe the audio it receives.
+func (m *Manager) attachTranscriber(channel Channel) {
+	if tc, ok := channel.(transcribingChannel); ok && m.transcriber != nil {
+		tc.setTranscriber(m.transcriber)
+	}
+}
+
 func (m *Manager) UnregisterChannel(name string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
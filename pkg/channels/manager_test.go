@@ -0,0 +1,199 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/voice"
+)
+
+type fakeSynthesizer struct {
+	texts []string
+}
+
+func (f *fakeSynthesizer) Name() string { return "fake" }
+
+func (f *fakeSynthesizer) Synthesize(ctx context.Context, text string) (*voice.Speech, error) {
+	f.texts = append(f.texts, text)
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("picoclaw-test-%d.ogg", time.Now().UnixNano()))
+	if err := os.WriteFile(path, []byte("OggS"), 0600); err != nil {
+		return nil, err
+	}
+	return &voice.Speech{Path: path, Format: "ogg"}, nil
+}
+
+type voiceTestChannel struct {
+	testChannel
+	sent []string
+}
+
+func (c *voiceTestChannel) SendVoice(ctx context.Context, chatID, audioPath string) error {
+	if _, err := os.Stat(audioPath); err != nil {
+		return err
+	}
+	c.sent = append(c.sent, chatID)
+	return nil
+}
+
+func TestManagerSendVoice(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Voice.TTS.MaxChars = 20
+	synth := &fakeSynthesizer{}
+	m := &Manager{channels: map[string]Channel{}, config: cfg, synthesizer: synth}
+
+	ch := &voiceTestChannel{testChannel: testChannel{BaseChannel: NewBaseChannel("test", nil, nil, nil)}}
+	if !m.sendVoice(context.Background(), ch, bus.OutboundMessage{Channel: "test", ChatID: "42", Content: "**Hello** there"}) {
+		t.Fatal("sendVoice() = false, want a voice reply")
+	}
+	if len(ch.sent) != 1 || ch.sent[0] != "42" || synth.texts[0] != "Hello there" {
+		t.Errorf("sent %v, synthesized %q", ch.sent, synth.texts)
+	}
+
+	// Long replies and channels without voice support fall back to text.
+	if m.sendVoice(context.Background(), ch, bus.OutboundMessage{ChatID: "42", Content: strings.Repeat("long ", 10)}) {
+		t.Error("sendVoice() spoke a reply over max_chars")
+	}
+	if m.sendVoice(context.Background(), &testChannel{BaseChannel: ch.BaseChannel}, bus.OutboundMessage{ChatID: "42", Content: "hi"}) {
+		t.Error("sendVoice() on a channel without SendVoice")
+	}
+	if len(synth.texts) != 1 {
+		t.Errorf("synthesized %d times, want 1", len(synth.texts))
+	}
+}
+
+type photoTestChannel struct {
+	testChannel
+	texts  []string
+	photos []string
+}
+
+func (c *photoTestChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	c.texts = append(c.texts, msg.Content)
+	return nil
+}
+
+func (c *photoTestChannel) SendPhoto(ctx context.Context, chatID, imagePath string) error {
+	c.photos = append(c.photos, chatID+":"+imagePath)
+	return nil
+}
+
+type textTestChannel struct {
+	testChannel
+	texts []string
+}
+
+func (c *textTestChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	c.texts = append(c.texts, msg.Content)
+	return nil
+}
+
+func TestManagerSendMedia(t *testing.T) {
+	m := &Manager{channels: map[string]Channel{}, config: config.DefaultConfig()}
+	base := testChannel{BaseChannel: NewBaseChannel("test", nil, nil, nil)}
+
+	ch := &photoTestChannel{testChannel: base}
+	m.sendMedia(context.Background(), ch, bus.OutboundMessage{ChatID: "42", Content: "Last night", Media: []string{"/tmp/a.png"}})
+	if len(ch.texts) != 1 || ch.texts[0] != "Last night" || len(ch.photos) != 1 || ch.photos[0] != "42:/tmp/a.png" {
+		t.Errorf("texts %q, photos %q", ch.texts, ch.photos)
+	}
+
+	// Without text only the image is sent.
+	ch = &photoTestChannel{testChannel: base}
+	m.sendMedia(context.Background(), ch, bus.OutboundMessage{ChatID: "42", Media: []string{"/tmp/a.png"}})
+	if len(ch.texts) != 0 || len(ch.photos) != 1 {
+		t.Errorf("texts %q, photos %q", ch.texts, ch.photos)
+	}
+
+	// Channels without images get the file names.
+	text := &textTestChannel{testChannel: base}
+	m.sendMedia(context.Background(), text, bus.OutboundMessage{ChatID: "42", Content: "Chart:", Media: []string{"/tmp/a.png"}})
+	if len(text.texts) != 1 || !strings.Contains(text.texts[0], "a.png") {
+		t.Errorf("texts %q", text.texts)
+	}
+}
+
+// blockingSynthesizer holds synthesis until release is closed.
+type blockingSynthesizer struct {
+	fakeSynthesizer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingSynthesizer) Synthesize(ctx context.Context, text string) (*voice.Speech, error) {
+	close(b.started)
+	<-b.release
+	return b.fakeSynthesizer.Synthesize(ctx, text)
+}
+
+// orderTestChannel records text and voice messages in the order sent.
+type orderTestChannel struct {
+	testChannel
+	mu   sync.Mutex
+	sent []string
+	done chan string
+}
+
+func (c *orderTestChannel) record(event string) {
+	c.mu.Lock()
+	c.sent = append(c.sent, event)
+	c.mu.Unlock()
+	c.done <- event
+}
+
+func (c *orderTestChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	c.record(msg.ChatID + ":" + msg.Content)
+	return nil
+}
+
+func (c *orderTestChannel) SendVoice(ctx context.Context, chatID, audioPath string) error {
+	c.record(chatID + ":voice")
+	return nil
+}
+
+func TestManagerDispatch_VoiceDoesNotBlockOtherChats(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	synth := &blockingSynthesizer{started: make(chan struct{}), release: make(chan struct{})}
+	ch := &orderTestChannel{testChannel: testChannel{BaseChannel: NewBaseChannel("test", nil, nil, nil)}, done: make(chan string, 4)}
+	m := &Manager{channels: map[string]Channel{"test": ch}, bus: msgBus, config: config.DefaultConfig(), synthesizer: synth}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.dispatchOutbound(ctx)
+
+	msgBus.PublishOutbound(bus.OutboundMessage{Channel: "test", ChatID: "1", Content: "spoken", Voice: true})
+	<-synth.started
+	msgBus.PublishOutbound(bus.OutboundMessage{Channel: "test", ChatID: "1", Content: "after"})
+	msgBus.PublishOutbound(bus.OutboundMessage{Channel: "test", ChatID: "2", Content: "other chat"})
+
+	// The other chat is served while the voice reply is synthesized.
+	select {
+	case event := <-ch.done:
+		if event != "2:other chat" {
+			t.Fatalf("first message sent = %q, want the other chat's", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("other chat was blocked by speech synthesis")
+	}
+
+	close(synth.release)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ch.done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("voice chat messages were not sent")
+		}
+	}
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if got := strings.Join(ch.sent, ", "); got != "2:other chat, 1:voice, 1:after" {
+		t.Errorf("sent %s, want the voice chat's messages in order", got)
+	}
+}
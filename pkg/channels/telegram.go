@@ -21,7 +21,6 @@ import (
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/utils"
-	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
 type TelegramChannel struct {
@@ -30,7 +29,6 @@ type TelegramChannel struct {
 	commands     TelegramCommander
 	config       *config.Config
 	chatIDs      map[string]int64
-	transcriber  *voice.GroqTranscriber
 	placeholders sync.Map // chatID -> messageID
 	stopThinking sync.Map // chatID -> thinkingCancel
 }
@@ -74,16 +72,11 @@ func NewTelegramChannel(cfg *config.Config, bus *bus.MessageBus) (*TelegramChann
 		bot:          bot,
 		config:       cfg,
 		chatIDs:      make(map[string]int64),
-		transcriber:  nil,
 		placeholders: sync.Map{},
 		stopThinking: sync.Map{},
 	}, nil
 }
 
-func (c *TelegramChannel) SetTranscriber(transcriber *voice.GroqTranscriber) {
-	c.transcriber = transcriber
-}
-
 func (c *TelegramChannel) Start(ctx context.Context) error {
 	logger.InfoC("telegram", "Starting Telegram bot (polling mode)...")
 
@@ -186,6 +179,74 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	return nil
 }
 
+// SendVoice sends an OGG/Opus file as a voice message, replacing the
+// thinking placeholder.
+func (c *TelegramChannel) SendVoice(ctx context.Context, chatIDStr, audioPath string) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("telegram bot not running")
+	}
+
+	chatID, err := parseChatID(chatIDStr)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID: %w", err)
+	}
+
+	file, err := os.Open(audioPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := c.bot.SendVoice(ctx, tu.Voice(tu.ID(chatID), tu.File(file))); err != nil {
+		return fmt.Errorf("failed to send voice: %w", err)
+	}
+
+	c.removePlaceholder(ctx, chatIDStr, chatID)
+	return nil
+}
+
+// SendPhoto sends an image file as a photo.
+func (c *TelegramChannel) SendPhoto(ctx context.Context, chatIDStr, imagePath string) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("telegram bot not running")
+	}
+
+	chatID, err := parseChatID(chatIDStr)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID: %w", err)
+	}
+
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := c.bot.SendPhoto(ctx, tu.Photo(tu.ID(chatID), tu.File(file))); err != nil {
+		return fmt.Errorf("failed to send photo: %w", err)
+	}
+
+	c.removePlaceholder(ctx, chatIDStr, chatID)
+	return nil
+}
+
+// removePlaceholder stops the thinking animation and deletes its message,
+// for replies that are not text.
+func (c *TelegramChannel) removePlaceholder(ctx context.Context, chatIDStr string, chatID int64) {
+	if stop, ok := c.stopThinking.Load(chatIDStr); ok {
+		if cf, ok := stop.(*thinkingCancel); ok && cf != nil {
+			cf.Cancel()
+		}
+		c.stopThinking.Delete(chatIDStr)
+	}
+	if pID, ok := c.placeholders.Load(chatIDStr); ok {
+		c.placeholders.Delete(chatIDStr)
+		if err := c.bot.DeleteMessage(ctx, tu.Delete(tu.ID(chatID), pID.(int))); err != nil {
+			logger.DebugCF("telegram", "Failed to delete placeholder", map[string]interface{}{"error": err.Error()})
+		}
+	}
+}
+
 func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Message) error {
 	if message == nil {
 		return fmt.Errorf("message is nil")
@@ -258,32 +319,11 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 			localFiles = append(localFiles, voicePath)
 			mediaPaths = append(mediaPaths, voicePath)
 
-			transcribedText := ""
-			if c.transcriber != nil && c.transcriber.IsAvailable() {
-				ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
-				defer cancel()
-
-				result, err := c.transcriber.Transcribe(ctx, voicePath)
-				if err != nil {
-					logger.ErrorCF("telegram", "Voice transcription failed", map[string]interface{}{
-						"error": err.Error(),
-						"path":  voicePath,
-					})
-					transcribedText = "[voice (transcription failed)]"
-				} else {
-					transcribedText = fmt.Sprintf("[voice transcription: %s]", result.Text)
-					logger.InfoCF("telegram", "Voice transcribed successfully", map[string]interface{}{
-						"text": result.Text,
-					})
-				}
-			} else {
-				transcribedText = "[voice]"
-			}
-
+			// Transcribed by BaseChannel.HandleMessage when a backend is configured.
 			if content != "" {
 				content += "\n"
 			}
-			content += transcribedText
+			content += "[voice]"
 		}
 	}
 
@@ -40,6 +40,9 @@ func (c *cmd) Help(ctx context.Context, message telego.Message) error {
 /help - Show this help message
 /show [model|channel] - Show current configuration
 /list [models|channels] - List available options
+/cost [today|week|month|<days>] - Show LLM usage and costs
+/think [on|off] - Show or hide reasoning summaries
+/voice [auto|always|off] - Reply with voice messages
 	`
 	_, err := c.bot.SendMessage(ctx, &telego.SendMessageParams{
 		ChatID: telego.ChatID{ID: message.Chat.ID},
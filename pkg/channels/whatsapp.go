@@ -1,10 +1,14 @@
 package channels
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/store/sqlstore"
@@ -180,6 +184,85 @@ func (c *WhatsAppChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	return nil
 }
 
+// SendVoice uploads an OGG/Opus file and sends it as a voice note.
+func (c *WhatsAppChannel) SendVoice(ctx context.Context, chatID, audioPath string) error {
+	if c.client == nil {
+		return fmt.Errorf("whatsapp client not connected")
+	}
+
+	jid, err := types.ParseJID(chatID)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID: %w", err)
+	}
+
+	data, err := os.ReadFile(audioPath)
+	if err != nil {
+		return err
+	}
+	uploaded, err := c.client.Upload(ctx, data, whatsmeow.MediaAudio)
+	if err != nil {
+		return fmt.Errorf("failed to upload voice: %w", err)
+	}
+
+	_, err = c.client.SendMessage(ctx, jid, &waProto.Message{
+		AudioMessage: &waProto.AudioMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			Mimetype:      proto.String("audio/ogg; codecs=opus"),
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			PTT:           proto.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send voice: %w", err)
+	}
+
+	logger.InfoCF("whatsapp", "Sent voice message", map[string]interface{}{"chat_id": chatID})
+	return nil
+}
+
+// SendPhoto uploads an image file and sends it as an image message.
+func (c *WhatsAppChannel) SendPhoto(ctx context.Context, chatID, imagePath string) error {
+	if c.client == nil {
+		return fmt.Errorf("whatsapp client not connected")
+	}
+
+	jid, err := types.ParseJID(chatID)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID: %w", err)
+	}
+
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return err
+	}
+	uploaded, err := c.client.Upload(ctx, data, whatsmeow.MediaImage)
+	if err != nil {
+		return fmt.Errorf("failed to upload image: %w", err)
+	}
+
+	_, err = c.client.SendMessage(ctx, jid, &waProto.Message{
+		ImageMessage: &waProto.ImageMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			Mimetype:      proto.String(http.DetectContentType(data)),
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send image: %w", err)
+	}
+
+	logger.InfoCF("whatsapp", "Sent image", map[string]interface{}{"chat_id": chatID})
+	return nil
+}
+
 func (c *WhatsAppChannel) handleEvent(evt interface{}) {
 	switch v := evt.(type) {
 	case *events.Message:
@@ -207,6 +290,15 @@ func (c *WhatsAppChannel) handleMessage(evt *events.Message) {
 		content = *evt.Message.ExtendedTextMessage.Text
 	}
 
+	var mediaPaths []string
+	if audio := evt.Message.GetAudioMessage(); audio != nil {
+		if localPath := c.downloadAudio(audio, audio.GetMimetype()); localPath != "" {
+			defer os.Remove(localPath)
+			mediaPaths = append(mediaPaths, localPath)
+			content = appendContent(content, "[voice]")
+		}
+	}
+
 	// Ignore empty messages
 	if content == "" {
 		return
@@ -230,5 +322,28 @@ func (c *WhatsAppChannel) handleMessage(evt *events.Message) {
 		metadata["user_name"] = evt.Info.PushName
 	}
 
-	c.HandleMessage(senderID, chatID, content, nil, metadata)
+	c.HandleMessage(senderID, chatID, content, mediaPaths, metadata)
+}
+
+// downloadAudio saves a voice note or audio message to a temp file.
+func (c *WhatsAppChannel) downloadAudio(msg whatsmeow.DownloadableMessage, mimetype string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	data, err := c.client.Download(ctx, msg)
+	if err != nil {
+		logger.ErrorCF("whatsapp", "Failed to download audio", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return ""
+	}
+
+	ext := ".ogg"
+	switch {
+	case strings.HasPrefix(mimetype, "audio/mpeg"):
+		ext = ".mp3"
+	case strings.HasPrefix(mimetype, "audio/mp4"), strings.HasPrefix(mimetype, "audio/aac"):
+		ext = ".m4a"
+	}
+	return utils.SaveMediaFile(bytes.NewReader(data), "voice"+ext, "whatsapp")
 }
@@ -53,6 +53,8 @@ type Config struct {
 	Tools     ToolsConfig     `json:"tools"`
 	Heartbeat HeartbeatConfig `json:"heartbeat"`
 	Devices   DevicesConfig   `json:"devices"`
+	Costs     CostsConfig     `json:"costs"`
+	Voice     VoiceConfig     `json:"voice"`
 	mu        sync.RWMutex
 }
 
@@ -101,18 +103,69 @@ func (m AgentModelConfig) MarshalJSON() ([]byte, error) {
 }
 
 type AgentConfig struct {
-	ID        string            `json:"id"`
-	Default   bool              `json:"default,omitempty"`
-	Name      string            `json:"name,omitempty"`
-	Workspace string            `json:"workspace,omitempty"`
-	Model     *AgentModelConfig `json:"model,omitempty"`
-	Skills    []string          `json:"skills,omitempty"`
-	Subagents *SubagentsConfig  `json:"subagents,omitempty"`
+	ID         string            `json:"id"`
+	Default    bool              `json:"default,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Workspace  string            `json:"workspace,omitempty"`
+	Provider   string            `json:"provider,omitempty"` // overrides agents.defaults.provider
+	Model      *AgentModelConfig `json:"model,omitempty"`
+	Skills     []string          `json:"skills,omitempty"`
+	Subagents  *SubagentsConfig  `json:"subagents,omitempty"`
+	Routing    *RoutingConfig    `json:"routing,omitempty"`     // overrides agents.defaults.routing
+	Reasoning  *ReasoningConfig  `json:"reasoning,omitempty"`   // overrides agents.defaults.reasoning
+	VoiceReply string            `json:"voice_reply,omitempty"` // overrides voice.tts.reply
+	// HardwareDevices overrides agents.defaults.hardware_devices.
+	HardwareDevices []string `json:"hardware_devices,omitempty"`
+}
+
+// ReasoningConfig enables extended thinking on models that support it.
+// Effort maps to OpenAI-style reasoning_effort; BudgetTokens caps Anthropic
+// and Gemini thinking and, when unset, is derived from Effort.
+type ReasoningConfig struct {
+	Effort       string `json:"effort,omitempty"`        // "minimal", "low", "medium", "high" or "off"
+	BudgetTokens int    `json:"budget_tokens,omitempty"` // thinking tokens per call
+	Show         bool   `json:"show,omitempty"`          // show a reasoning summary in chat by default; toggled with /think
+}
+
+// RoutingConfig picks a model tier for each turn. Rules are checked in
+// order; when none matches, the classifier model (if set) chooses a tier,
+// otherwise the default tier is used. The agent's own model is always
+// available as the "default" tier.
+type RoutingConfig struct {
+	Enabled         bool                `json:"enabled"`
+	DefaultTier     string              `json:"default_tier,omitempty"`
+	ClassifierModel string              `json:"classifier_model,omitempty"` // cheap model asked to pick a tier
+	Tiers           []RoutingTierConfig `json:"tiers"`
+	Rules           []RoutingRule       `json:"rules,omitempty"`
+}
+
+type RoutingTierConfig struct {
+	Name        string   `json:"name"`
+	Model       string   `json:"model"`
+	Fallbacks   []string `json:"fallbacks,omitempty"`
+	Description string   `json:"description,omitempty"` // shown to the classifier model
+}
+
+// RoutingRule selects Tier when all of its conditions hold. Rules with
+// Tools are checked during the tool loop instead: once the model calls one
+// of the listed tools ("*" for any), the rest of the turn moves to Tier.
+type RoutingRule struct {
+	Tier     string   `json:"tier"`
+	MinChars int      `json:"min_chars,omitempty"`
+	MaxChars int      `json:"max_chars,omitempty"`
+	HasMedia *bool    `json:"has_media,omitempty"`
+	Keywords []string `json:"keywords,omitempty"` // any keyword, case-insensitive
+	Pattern  string   `json:"pattern,omitempty"`  // regular expression
+	Channels []string `json:"channels,omitempty"`
+	Tools    []string `json:"tools,omitempty"`
 }
 
 type SubagentsConfig struct {
-	AllowAgents []string          `json:"allow_agents,omitempty"`
-	Model       *AgentModelConfig `json:"model,omitempty"`
+	AllowAgents    []string          `json:"allow_agents,omitempty"`
+	Model          *AgentModelConfig `json:"model,omitempty"`
+	MaxConcurrent  int               `json:"max_concurrent,omitempty"`  // tasks running at once (default 4)
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"` // per task (default 600)
+	MaxIterations  int               `json:"max_iterations,omitempty"`  // tool iterations per task (default: the agent's)
 }
 
 type PeerMatch struct {
@@ -139,16 +192,21 @@ type SessionConfig struct {
 }
 
 type AgentDefaults struct {
-	Workspace           string   `json:"workspace" env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
-	RestrictToWorkspace bool     `json:"restrict_to_workspace" env:"PICOCLAW_AGENTS_DEFAULTS_RESTRICT_TO_WORKSPACE"`
-	Provider            string   `json:"provider" env:"PICOCLAW_AGENTS_DEFAULTS_PROVIDER"`
-	Model               string   `json:"model" env:"PICOCLAW_AGENTS_DEFAULTS_MODEL"`
-	ModelFallbacks      []string `json:"model_fallbacks,omitempty"`
-	ImageModel          string   `json:"image_model,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_IMAGE_MODEL"`
-	ImageModelFallbacks []string `json:"image_model_fallbacks,omitempty"`
-	MaxTokens           int      `json:"max_tokens" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
-	Temperature         *float64 `json:"temperature,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
-	MaxToolIterations   int      `json:"max_tool_iterations" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	Workspace           string           `json:"workspace" env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
+	RestrictToWorkspace bool             `json:"restrict_to_workspace" env:"PICOCLAW_AGENTS_DEFAULTS_RESTRICT_TO_WORKSPACE"`
+	Provider            string           `json:"provider" env:"PICOCLAW_AGENTS_DEFAULTS_PROVIDER"`
+	Model               string           `json:"model" env:"PICOCLAW_AGENTS_DEFAULTS_MODEL"`
+	ModelFallbacks      []string         `json:"model_fallbacks,omitempty"`
+	ImageModel          string           `json:"image_model,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_IMAGE_MODEL"`
+	ImageModelFallbacks []string         `json:"image_model_fallbacks,omitempty"`
+	MaxTokens           int              `json:"max_tokens" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
+	Temperature         *float64         `json:"temperature,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
+	MaxToolIterations   int              `json:"max_tool_iterations" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	Routing             *RoutingConfig   `json:"routing,omitempty"`
+	Reasoning           *ReasoningConfig `json:"reasoning,omitempty"`
+	// HardwareDevices limits the gpio, pwm and serial tools to device paths
+	// matching these glob patterns; empty allows every device.
+	HardwareDevices []string `json:"hardware_devices,omitempty"`
 }
 
 type ChannelsConfig struct {
@@ -162,6 +220,7 @@ type ChannelsConfig struct {
 	Slack    SlackConfig    `json:"slack"`
 	LINE     LINEConfig     `json:"line"`
 	OneBot   OneBotConfig   `json:"onebot"`
+	IRC      IRCConfig      `json:"irc"`
 }
 
 type WhatsAppConfig struct {
@@ -196,6 +255,9 @@ type MaixCamConfig struct {
 	Host      string              `json:"host" env:"PICOCLAW_CHANNELS_MAIXCAM_HOST"`
 	Port      int                 `json:"port" env:"PICOCLAW_CHANNELS_MAIXCAM_PORT"`
 	AllowFrom FlexibleStringSlice `json:"allow_from" env:"PICOCLAW_CHANNELS_MAIXCAM_ALLOW_FROM"`
+	// PersonFrame requests a snapshot for person_detected events that do not
+	// carry an image, so the agent sees who arrived.
+	PersonFrame bool `json:"person_frame,omitempty" env:"PICOCLAW_CHANNELS_MAIXCAM_PERSON_FRAME"`
 }
 
 type QQConfig struct {
@@ -238,14 +300,123 @@ type OneBotConfig struct {
 	AllowFrom          FlexibleStringSlice `json:"allow_from" env:"PICOCLAW_CHANNELS_ONEBOT_ALLOW_FROM"`
 }
 
+type IRCConfig struct {
+	Enabled   bool                `json:"enabled" env:"PICOCLAW_CHANNELS_IRC_ENABLED"`
+	Networks  []IRCNetworkConfig  `json:"networks"`
+	AllowFrom FlexibleStringSlice `json:"allow_from" env:"PICOCLAW_CHANNELS_IRC_ALLOW_FROM"`
+}
+
+// IRCNetworkConfig describes one IRC network connection.
+// Chat IDs for IRC take the form "<network>/<target>", e.g. "libera/#ops".
+type IRCNetworkConfig struct {
+	Name              string   `json:"name"`
+	Server            string   `json:"server"` // host:port
+	TLS               bool     `json:"tls"`
+	TLSSkipVerify     bool     `json:"tls_skip_verify,omitempty"`
+	Nick              string   `json:"nick"`
+	User              string   `json:"user,omitempty"`
+	RealName          string   `json:"realname,omitempty"`
+	Password          string   `json:"password,omitempty"` // server password (PASS)
+	SASLUser          string   `json:"sasl_user,omitempty"`
+	SASLPassword      string   `json:"sasl_password,omitempty"`
+	Channels          []string `json:"channels"`
+	ReconnectInterval int      `json:"reconnect_interval,omitempty"` // seconds
+	FloodBurst        int      `json:"flood_burst,omitempty"`        // lines sent before throttling
+	FloodDelayMS      int      `json:"flood_delay_ms,omitempty"`     // delay between throttled lines
+}
+
 type HeartbeatConfig struct {
-	Enabled  bool `json:"enabled" env:"PICOCLAW_HEARTBEAT_ENABLED"`
-	Interval int  `json:"interval" env:"PICOCLAW_HEARTBEAT_INTERVAL"` // minutes, min 5
+	Enabled      bool   `json:"enabled" env:"PICOCLAW_HEARTBEAT_ENABLED"`
+	Interval     int    `json:"interval" env:"PICOCLAW_HEARTBEAT_INTERVAL"`                 // minutes, min 5
+	QuietHours   string `json:"quiet_hours,omitempty" env:"PICOCLAW_HEARTBEAT_QUIET_HOURS"` // HH:MM-HH:MM, default for all tasks
+	DedupMinutes int    `json:"dedup_minutes" env:"PICOCLAW_HEARTBEAT_DEDUP_MINUTES"`       // suppress repeated alerts; 0 disables
+}
+
+// CostsConfig sets budgets and price overrides for LLM cost tracking.
+type CostsConfig struct {
+	DailyBudget   float64               `json:"daily_budget_usd,omitempty" env:"PICOCLAW_COSTS_DAILY_BUDGET_USD"`
+	MonthlyBudget float64               `json:"monthly_budget_usd,omitempty" env:"PICOCLAW_COSTS_MONTHLY_BUDGET_USD"`
+	AlertChannel  string                `json:"alert_channel,omitempty" env:"PICOCLAW_COSTS_ALERT_CHANNEL"` // channel:chat_id; defaults to the last active chat
+	Pricing       map[string]ModelPrice `json:"pricing,omitempty"`                                          // "model" or "provider/model" -> price
+}
+
+// ModelPrice is a model's price in USD per million tokens.
+type ModelPrice struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read,omitempty"`
+	CacheWrite float64 `json:"cache_write,omitempty"`
+}
+
+// VoiceConfig configures speech handling for voice messages.
+type VoiceConfig struct {
+	Transcription TranscriptionConfig `json:"transcription"`
+	TTS           TTSConfig           `json:"tts"`
+}
+
+// TranscriptionConfig selects the speech-to-text backend for audio received
+// on any channel. With no backend, Groq is used when providers.groq has an
+// API key.
+type TranscriptionConfig struct {
+	Backend        string   `json:"backend,omitempty" env:"PICOCLAW_VOICE_TRANSCRIPTION_BACKEND"`   // openai, groq, whisper_cpp, command or off
+	APIBase        string   `json:"api_base,omitempty" env:"PICOCLAW_VOICE_TRANSCRIPTION_API_BASE"` // defaults to the provider's api_base
+	APIKey         string   `json:"api_key,omitempty" env:"PICOCLAW_VOICE_TRANSCRIPTION_API_KEY"`   // defaults to the provider's api_key
+	Model          string   `json:"model,omitempty" env:"PICOCLAW_VOICE_TRANSCRIPTION_MODEL"`
+	Language       string   `json:"language,omitempty" env:"PICOCLAW_VOICE_TRANSCRIPTION_LANGUAGE"` // ISO-639-1 hint, e.g. "en"
+	Command        []string `json:"command,omitempty"`                                              // argv for the command backend; "{file}" is the audio path
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty" env:"PICOCLAW_VOICE_TRANSCRIPTION_TIMEOUT_SECONDS"`
+}
+
+// TTSConfig selects the text-to-speech backend for voice replies and when
+// replies are spoken.
+type TTSConfig struct {
+	Backend        string   `json:"backend,omitempty" env:"PICOCLAW_VOICE_TTS_BACKEND"`   // openai or command; empty disables voice replies
+	Reply          string   `json:"reply,omitempty" env:"PICOCLAW_VOICE_TTS_REPLY"`       // off, auto (answer voice with voice) or always; toggled per chat with /voice
+	APIBase        string   `json:"api_base,omitempty" env:"PICOCLAW_VOICE_TTS_API_BASE"` // defaults to providers.openai.api_base
+	APIKey         string   `json:"api_key,omitempty" env:"PICOCLAW_VOICE_TTS_API_KEY"`   // defaults to providers.openai.api_key
+	Model          string   `json:"model,omitempty" env:"PICOCLAW_VOICE_TTS_MODEL"`
+	Voice          string   `json:"voice,omitempty" env:"PICOCLAW_VOICE_TTS_VOICE"`
+	Command        []string `json:"command,omitempty"`                                      // argv for the command backend; see voice.NewCommandSynthesizer
+	FFmpeg         string   `json:"ffmpeg,omitempty" env:"PICOCLAW_VOICE_TTS_FFMPEG"`       // converts command output to OGG/Opus; default "ffmpeg"
+	MaxChars       int      `json:"max_chars,omitempty" env:"PICOCLAW_VOICE_TTS_MAX_CHARS"` // longer replies are sent as text
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty" env:"PICOCLAW_VOICE_TTS_TIMEOUT_SECONDS"`
 }
 
 type DevicesConfig struct {
-	Enabled    bool `json:"enabled" env:"PICOCLAW_DEVICES_ENABLED"`
-	MonitorUSB bool `json:"monitor_usb" env:"PICOCLAW_DEVICES_MONITOR_USB"`
+	Enabled          bool              `json:"enabled" env:"PICOCLAW_DEVICES_ENABLED"`
+	MonitorUSB       bool              `json:"monitor_usb" env:"PICOCLAW_DEVICES_MONITOR_USB"`
+	MonitorBlock     bool              `json:"monitor_block" env:"PICOCLAW_DEVICES_MONITOR_BLOCK"`         // SD cards and USB drives: attach, remove, mount
+	MonitorNetwork   bool              `json:"monitor_network" env:"PICOCLAW_DEVICES_MONITOR_NETWORK"`     // interface up/down and Wi-Fi association
+	MonitorBluetooth bool              `json:"monitor_bluetooth" env:"PICOCLAW_DEVICES_MONITOR_BLUETOOTH"` // BlueZ connect/disconnect (needs dbus-monitor)
+	GPIO             []GPIOWatchConfig `json:"gpio,omitempty"`                                             // input lines watched for edges
+
+	Agent           string             `json:"agent,omitempty" env:"PICOCLAW_DEVICES_AGENT"`       // agent for "agent" events; default agent when empty
+	Target          string             `json:"target,omitempty" env:"PICOCLAW_DEVICES_TARGET"`     // "channel:chat_id"; last active chat when empty
+	Handling        string             `json:"handling,omitempty" env:"PICOCLAW_DEVICES_HANDLING"` // notify (default), agent or ignore
+	DebounceSeconds int                `json:"debounce_seconds" env:"PICOCLAW_DEVICES_DEBOUNCE_SECONDS"`
+	Rules           []DeviceRuleConfig `json:"rules,omitempty"`
+}
+
+// DeviceRuleConfig picks the handling for matching device events. Empty
+// fields match anything; vendor, product and device match substrings.
+type DeviceRuleConfig struct {
+	Kind     string `json:"kind,omitempty"`   // usb, block, network, bluetooth, gpio
+	Action   string `json:"action,omitempty"` // add, remove or change
+	Vendor   string `json:"vendor,omitempty"`
+	Product  string `json:"product,omitempty"`
+	Device   string `json:"device,omitempty"`
+	Handling string `json:"handling"` // notify, agent or ignore
+	Prompt   string `json:"prompt,omitempty"`
+}
+
+// GPIOWatchConfig is a GPIO input line reported on each edge.
+type GPIOWatchConfig struct {
+	Chip       string `json:"chip,omitempty"` // default "gpiochip0"
+	Line       int    `json:"line"`
+	Name       string `json:"name,omitempty"`
+	Edge       string `json:"edge,omitempty"` // rising, falling or both (default)
+	Bias       string `json:"bias,omitempty"` // pull_up, pull_down or disable
+	DebounceMs int    `json:"debounce_ms,omitempty"`
 }
 
 type ProvidersConfig struct {
@@ -255,9 +426,9 @@ type ProvidersConfig struct {
 	Groq          ProviderConfig       `json:"groq"`
 	Zhipu         ProviderConfig       `json:"zhipu"`
 	VLLM          ProviderConfig       `json:"vllm"`
-	Gemini        ProviderConfig       `json:"gemini"`
+	Gemini        GeminiProviderConfig `json:"gemini"`
 	Nvidia        ProviderConfig       `json:"nvidia"`
-	Ollama        ProviderConfig       `json:"ollama"`
+	Ollama        OllamaProviderConfig `json:"ollama"`
 	Moonshot      ProviderConfig       `json:"moonshot"`
 	ShengSuanYun  ProviderConfig       `json:"shengsuanyun"`
 	DeepSeek      ProviderConfig       `json:"deepseek"`
@@ -277,9 +448,31 @@ type OpenAIProviderConfig struct {
 	WebSearch bool `json:"web_search" env:"PICOCLAW_PROVIDERS_OPENAI_WEB_SEARCH"`
 }
 
+type GeminiProviderConfig struct {
+	ProviderConfig
+	SafetySettings map[string]string `json:"safety_settings,omitempty"` // harm category -> threshold, e.g. {"harassment": "BLOCK_ONLY_HIGH"}
+}
+
+type OllamaProviderConfig struct {
+	ProviderConfig
+	KeepAlive string                 `json:"keep_alive,omitempty" env:"PICOCLAW_PROVIDERS_OLLAMA_KEEP_ALIVE"` // e.g. "5m", "-1" keeps the model loaded
+	NumCtx    int                    `json:"num_ctx,omitempty" env:"PICOCLAW_PROVIDERS_OLLAMA_NUM_CTX"`
+	Options   map[string]interface{} `json:"options,omitempty"` // passed through to Ollama, e.g. {"num_gpu": 0}
+}
+
 type GatewayConfig struct {
-	Host string `json:"host" env:"PICOCLAW_GATEWAY_HOST"`
-	Port int    `json:"port" env:"PICOCLAW_GATEWAY_PORT"`
+	Host          string              `json:"host" env:"PICOCLAW_GATEWAY_HOST"`
+	Port          int                 `json:"port" env:"PICOCLAW_GATEWAY_PORT"`
+	ProviderProbe ProviderProbeConfig `json:"provider_probe"`
+}
+
+// ProviderProbeConfig controls periodic health probes of the configured
+// LLM providers. Results open and close the providers' circuit breakers and
+// feed the /ready endpoint.
+type ProviderProbeConfig struct {
+	Enabled         bool `json:"enabled" env:"PICOCLAW_GATEWAY_PROVIDER_PROBE_ENABLED"`
+	IntervalSeconds int  `json:"interval_seconds" env:"PICOCLAW_GATEWAY_PROVIDER_PROBE_INTERVAL_SECONDS"`
+	TimeoutSeconds  int  `json:"timeout_seconds" env:"PICOCLAW_GATEWAY_PROVIDER_PROBE_TIMEOUT_SECONDS"`
 }
 
 type BraveConfig struct {
@@ -306,7 +499,9 @@ type WebToolsConfig struct {
 }
 
 type CronToolsConfig struct {
-	ExecTimeoutMinutes int `json:"exec_timeout_minutes" env:"PICOCLAW_TOOLS_CRON_EXEC_TIMEOUT_MINUTES"` // 0 means no timeout
+	ExecTimeoutMinutes int    `json:"exec_timeout_minutes" env:"PICOCLAW_TOOLS_CRON_EXEC_TIMEOUT_MINUTES"` // 0 means no timeout
+	MisfirePolicy      string `json:"misfire_policy" env:"PICOCLAW_TOOLS_CRON_MISFIRE_POLICY"`             // skip, run_once or run_all
+	HistoryLimit       int    `json:"history_limit" env:"PICOCLAW_TOOLS_CRON_HISTORY_LIMIT"`               // runs kept per job
 }
 
 type ExecConfig struct {
@@ -396,6 +591,11 @@ func DefaultConfig() *Config {
 				GroupTriggerPrefix: []string{},
 				AllowFrom:          FlexibleStringSlice{},
 			},
+			IRC: IRCConfig{
+				Enabled:   false,
+				Networks:  []IRCNetworkConfig{},
+				AllowFrom: FlexibleStringSlice{},
+			},
 		},
 		Providers: ProvidersConfig{
 			Anthropic:    ProviderConfig{},
@@ -404,7 +604,7 @@ func DefaultConfig() *Config {
 			Groq:         ProviderConfig{},
 			Zhipu:        ProviderConfig{},
 			VLLM:         ProviderConfig{},
-			Gemini:       ProviderConfig{},
+			Gemini:       GeminiProviderConfig{},
 			Nvidia:       ProviderConfig{},
 			Moonshot:     ProviderConfig{},
 			ShengSuanYun: ProviderConfig{},
@@ -412,6 +612,11 @@ func DefaultConfig() *Config {
 		Gateway: GatewayConfig{
 			Host: "0.0.0.0",
 			Port: 18790,
+			ProviderProbe: ProviderProbeConfig{
+				Enabled:         true,
+				IntervalSeconds: 300,
+				TimeoutSeconds:  15,
+			},
 		},
 		Tools: ToolsConfig{
 			Web: WebToolsConfig{
@@ -432,18 +637,22 @@ func DefaultConfig() *Config {
 			},
 			Cron: CronToolsConfig{
 				ExecTimeoutMinutes: 5, // default 5 minutes for LLM operations
+				MisfirePolicy:      "skip",
+				HistoryLimit:       20,
 			},
 			Exec: ExecConfig{
 				EnableDenyPatterns: true,
 			},
 		},
 		Heartbeat: HeartbeatConfig{
-			Enabled:  true,
-			Interval: 30, // default 30 minutes
+			Enabled:      true,
+			Interval:     30,   // default 30 minutes
+			DedupMinutes: 1440, // don't repeat the same alert within a day
 		},
 		Devices: DevicesConfig{
-			Enabled:    false,
-			MonitorUSB: true,
+			Enabled:         false,
+			MonitorUSB:      true,
+			DebounceSeconds: 3,
 		},
 	}
 }
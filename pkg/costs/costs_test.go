@@ -0,0 +1,167 @@
+package costs
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func approx(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestCatalogLookup(t *testing.T) {
+	c := NewCatalog(map[string]Price{
+		"my-finetune":         {Input: 1, Output: 2},
+		"openrouter/gpt-4o":   {Input: 9, Output: 9},
+		" Claude-Sonnet-4-5 ": {Input: 4, Output: 16},
+	})
+
+	tests := []struct {
+		provider, model string
+		want            Price
+		known           bool
+	}{
+		{"anthropic", "claude-sonnet-4-20250514", builtinPrices["claude-sonnet-4"], true},
+		{"", "anthropic/claude-3-5-haiku-latest", builtinPrices["claude-3-5-haiku"], true},
+		{"openai", "gpt-4o-mini-2024-07-18", builtinPrices["gpt-4o-mini"], true},
+		{"openrouter", "openai/gpt-4.1-mini", builtinPrices["gpt-4.1-mini"], true},
+		{"openrouter", "gpt-4o", Price{Input: 9, Output: 9}, true},
+		{"anthropic", "claude-sonnet-4-5", Price{Input: 4, Output: 16}, true},
+		{"", "my-finetune", Price{Input: 1, Output: 2}, true},
+		{"ollama", "llama3.2", Price{}, true},
+		{"openai", "mystery-model", Price{}, false},
+	}
+	for _, tt := range tests {
+		got, known := c.Lookup(tt.provider, tt.model)
+		if got != tt.want || known != tt.known {
+			t.Errorf("Lookup(%q, %q) = %+v, %v; want %+v, %v", tt.provider, tt.model, got, known, tt.want, tt.known)
+		}
+	}
+}
+
+func TestCost(t *testing.T) {
+	p := Price{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}
+	usage := &providers.UsageInfo{PromptTokens: 1_000_000, CompletionTokens: 100_000, CacheReadTokens: 500_000, CacheWriteTokens: 100_000}
+	// 400k uncached * 3 + 500k * 0.3 + 100k * 3.75 + 100k * 15
+	if got, want := Cost(p, usage), 1.2+0.15+0.375+1.5; !approx(got, want) {
+		t.Errorf("Cost() = %v, want %v", got, want)
+	}
+
+	// Cache tokens without their own price are charged at the input price.
+	if got := Cost(Price{Input: 2}, &providers.UsageInfo{PromptTokens: 1_000_000, CacheReadTokens: 1_000_000}); !approx(got, 2) {
+		t.Errorf("Cost() without cache price = %v, want 2", got)
+	}
+	if got := Cost(p, nil); got != 0 {
+		t.Errorf("Cost(nil) = %v", got)
+	}
+}
+
+func TestTrackerRecordAndReport(t *testing.T) {
+	workspace := t.TempDir()
+	tr := NewTracker(workspace, config.CostsConfig{})
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return day }
+
+	tr.Record(Entry{Agent: "main", Channel: "telegram", Provider: "openai", Model: "gpt-4o",
+		Usage: &providers.UsageInfo{PromptTokens: 1_000_000, CompletionTokens: 100_000}})
+	tr.Record(Entry{Agent: "main", Channel: "telegram", Provider: "openai", Model: "gpt-4o",
+		Usage: &providers.UsageInfo{PromptTokens: 1_000_000}})
+	tr.Record(Entry{Agent: "coder", Channel: "cli", Provider: "ollama", Model: "qwen3"})
+	tr.Record(Entry{Agent: "coder", Channel: "cli", Provider: "ollama", Model: "qwen3",
+		Usage: &providers.UsageInfo{PromptTokens: 500, CompletionTokens: 50}})
+	tr.now = func() time.Time { return day.AddDate(0, 0, 1) }
+	tr.Record(Entry{Agent: "main", Channel: "cli", Provider: "acme", Model: "unknown",
+		Usage: &providers.UsageInfo{PromptTokens: 10}})
+
+	r := tr.Report(day, day)
+	if r.Total.Calls != 3 || !approx(r.Total.CostUSD, 2.5+1+2.5) {
+		t.Errorf("day total = %+v", r.Total)
+	}
+	if len(r.ByModel) != 2 || r.ByModel[0].Key != "openai/gpt-4o" || r.ByModel[1].Key != "ollama/qwen3" {
+		t.Errorf("ByModel = %+v", r.ByModel)
+	}
+
+	// A second tracker, like the Web UI or `picoclaw status`, sees the same ledger.
+	all, err := LoadReport(workspace, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("LoadReport() error: %v", err)
+	}
+	if all.Total.Calls != 4 || all.Total.Unpriced != 1 || len(all.ByDay) != 2 {
+		t.Errorf("range total = %+v, days = %+v", all.Total, all.ByDay)
+	}
+	if out := Format(all, "Test", 1); !strings.Contains(out, "By day:") || !strings.Contains(out, "... 1 more") || !strings.Contains(out, "without a known price") {
+		t.Errorf("Format() = %q", out)
+	}
+}
+
+func TestTrackerBudgetAlerts(t *testing.T) {
+	tr := NewTracker(t.TempDir(), config.CostsConfig{DailyBudget: 10, MonthlyBudget: 100})
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return now }
+
+	var alerts []string
+	tr.SetAlertHandler(func(msg string) { alerts = append(alerts, msg) })
+
+	// Each call costs $3 at gpt-4.1-mini prices (1M input + 1.625M output).
+	call := Entry{Provider: "openai", Model: "gpt-4.1-mini",
+		Usage: &providers.UsageInfo{PromptTokens: 1_000_000, CompletionTokens: 1_625_000}}
+	for i := 0; i < 2; i++ {
+		tr.Record(call)
+	}
+	if len(alerts) != 0 {
+		t.Fatalf("alerts at $6 = %v", alerts)
+	}
+	tr.Record(call) // $9: 90% of the daily budget
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "90% of the $10.00 budget") {
+		t.Fatalf("alerts at $9 = %v", alerts)
+	}
+	tr.Record(call) // $12: over budget
+	tr.Record(call) // still over budget, already alerted
+	if len(alerts) != 2 || !strings.Contains(alerts[1], "over the $10.00 budget") {
+		t.Fatalf("alerts at $15 = %v", alerts)
+	}
+
+	// The next day starts a new daily period.
+	now = now.AddDate(0, 0, 1)
+	for i := 0; i < 3; i++ {
+		tr.Record(call)
+	}
+	if len(alerts) != 3 || !strings.Contains(alerts[2], "today") {
+		t.Errorf("alerts on day two = %v", alerts)
+	}
+}
+
+type stubProvider struct {
+	resp *providers.LLMResponse
+	err  error
+}
+
+func (s *stubProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, options map[string]interface{}) (*providers.LLMResponse, error) {
+	return s.resp, s.err
+}
+
+func (s *stubProvider) GetDefaultModel() string { return "stub" }
+
+func TestMeter(t *testing.T) {
+	tr := NewTracker(t.TempDir(), config.CostsConfig{})
+	stub := &stubProvider{resp: &providers.LLMResponse{Content: "ok", Usage: &providers.UsageInfo{PromptTokens: 1000, CompletionTokens: 10}}}
+	p := tr.Meter(stub, Entry{Agent: "main", Channel: "webui", Provider: "anthropic"})
+
+	if _, err := p.Chat(context.Background(), nil, nil, "claude-haiku-4-5", nil); err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+	now := time.Now()
+	r := tr.Report(now, now)
+	if r.Total.Calls != 1 || len(r.ByChannel) != 1 || r.ByChannel[0].Key != "webui" || r.ByModel[0].Key != "anthropic/claude-haiku-4-5" {
+		t.Errorf("report after metered call = %+v", r)
+	}
+
+	var nilTracker *Tracker
+	if nilTracker.Meter(stub, Entry{}) != providers.LLMProvider(stub) {
+		t.Error("nil tracker should return the provider unchanged")
+	}
+}
@@ -0,0 +1,149 @@
+package costs
+
+import (
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Price is a model's price in USD per million tokens.
+type Price = config.ModelPrice
+
+// builtinPrices are list prices in USD per million tokens. Keys are model
+// name prefixes; the longest matching prefix wins, so dated or suffixed
+// model IDs ("claude-sonnet-4-20250514") find their family. Prices change;
+// override them with costs.pricing in config.json.
+var builtinPrices = map[string]Price{
+	// Anthropic
+	"claude-opus-4":     {Input: 15, Output: 75, CacheRead: 1.5, CacheWrite: 18.75},
+	"claude-sonnet-4":   {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	"claude-3-7-sonnet": {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	"claude-3-5-sonnet": {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	"claude-haiku-4":    {Input: 1, Output: 5, CacheRead: 0.1, CacheWrite: 1.25},
+	"claude-3-5-haiku":  {Input: 0.8, Output: 4, CacheRead: 0.08, CacheWrite: 1},
+
+	// OpenAI
+	"gpt-5":        {Input: 1.25, Output: 10, CacheRead: 0.125},
+	"gpt-5-mini":   {Input: 0.25, Output: 2, CacheRead: 0.025},
+	"gpt-5-nano":   {Input: 0.05, Output: 0.4, CacheRead: 0.005},
+	"gpt-4.1":      {Input: 2, Output: 8, CacheRead: 0.5},
+	"gpt-4.1-mini": {Input: 0.4, Output: 1.6, CacheRead: 0.1},
+	"gpt-4.1-nano": {Input: 0.1, Output: 0.4, CacheRead: 0.025},
+	"gpt-4o":       {Input: 2.5, Output: 10, CacheRead: 1.25},
+	"gpt-4o-mini":  {Input: 0.15, Output: 0.6, CacheRead: 0.075},
+	"o3":           {Input: 2, Output: 8, CacheRead: 0.5},
+	"o3-mini":      {Input: 1.1, Output: 4.4, CacheRead: 0.55},
+	"o4-mini":      {Input: 1.1, Output: 4.4, CacheRead: 0.275},
+
+	// Google
+	"gemini-2.5-pro":        {Input: 1.25, Output: 10, CacheRead: 0.31},
+	"gemini-2.5-flash":      {Input: 0.3, Output: 2.5, CacheRead: 0.075},
+	"gemini-2.5-flash-lite": {Input: 0.1, Output: 0.4, CacheRead: 0.025},
+	"gemini-2.0-flash":      {Input: 0.1, Output: 0.4, CacheRead: 0.025},
+
+	// DeepSeek
+	"deepseek-chat":     {Input: 0.27, Output: 1.1, CacheRead: 0.07},
+	"deepseek-reasoner": {Input: 0.55, Output: 2.19, CacheRead: 0.14},
+
+	// Groq
+	"llama-3.3-70b-versatile": {Input: 0.59, Output: 0.79},
+	"llama-3.1-8b-instant":    {Input: 0.05, Output: 0.08},
+
+	// Moonshot
+	"kimi-k2": {Input: 0.6, Output: 2.5, CacheRead: 0.15},
+}
+
+// freeProviders run models locally; their calls cost nothing.
+var freeProviders = map[string]bool{
+	"ollama": true,
+	"vllm":   true,
+}
+
+// Catalog looks up model prices, preferring config overrides over the
+// built-in list.
+type Catalog struct {
+	overrides map[string]Price
+}
+
+// NewCatalog creates a catalog with the given overrides, keyed by model
+// name or "provider/model".
+func NewCatalog(overrides map[string]Price) *Catalog {
+	c := &Catalog{overrides: make(map[string]Price, len(overrides))}
+	for k, v := range overrides {
+		c.overrides[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+	return c
+}
+
+// Lookup returns the price for a model. provider may be empty, and model
+// may carry a "provider/" prefix. The bool is false when the model is unknown.
+func (c *Catalog) Lookup(provider, model string) (Price, bool) {
+	model = strings.ToLower(strings.TrimSpace(model))
+	provider = providers.NormalizeProvider(provider)
+	if provider == "" {
+		if ref := providers.ParseModelRef(model, ""); ref != nil && ref.Provider != "" {
+			provider, model = ref.Provider, ref.Model
+		}
+	}
+
+	if provider != "" {
+		if p, ok := c.overrides[provider+"/"+model]; ok {
+			return p, true
+		}
+	}
+	if p, ok := c.overrides[model]; ok {
+		return p, true
+	}
+	if freeProviders[provider] {
+		return Price{}, true
+	}
+
+	// Gateways such as OpenRouter use vendor-prefixed names ("anthropic/claude-sonnet-4").
+	name := model
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if p, ok := c.overrides[name]; ok {
+		return p, true
+	}
+	return longestPrefix(name)
+}
+
+func longestPrefix(model string) (Price, bool) {
+	best := ""
+	for prefix := range builtinPrices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return builtinPrices[best], true
+}
+
+// Cost returns the USD cost of one call. PromptTokens includes cached
+// tokens; cache reads and writes without their own price are charged at
+// the input price.
+func Cost(p Price, usage *providers.UsageInfo) float64 {
+	if usage == nil {
+		return 0
+	}
+	cacheRead := p.CacheRead
+	if cacheRead == 0 {
+		cacheRead = p.Input
+	}
+	cacheWrite := p.CacheWrite
+	if cacheWrite == 0 {
+		cacheWrite = p.Input
+	}
+	uncached := usage.PromptTokens - usage.CacheReadTokens - usage.CacheWriteTokens
+	if uncached < 0 {
+		uncached = 0
+	}
+	return (float64(uncached)*p.Input +
+		float64(usage.CacheReadTokens)*cacheRead +
+		float64(usage.CacheWriteTokens)*cacheWrite +
+		float64(usage.CompletionTokens)*p.Output) / 1e6
+}
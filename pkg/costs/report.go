@@ -0,0 +1,151 @@
+package costs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Line is one row of a report breakdown.
+type Line struct {
+	Key string `json:"key"`
+	Totals
+}
+
+// Report summarizes costs over a date range.
+type Report struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Total     Totals `json:"total"`
+	ByDay     []Line `json:"by_day"`
+	ByAgent   []Line `json:"by_agent"`
+	ByChannel []Line `json:"by_channel"`
+	ByModel   []Line `json:"by_model"`
+}
+
+func buildReport(buckets []*Bucket, from, to time.Time) *Report {
+	r := &Report{From: from.Format(dayLayout), To: to.Format(dayLayout)}
+	days := make(map[string]*Totals)
+	agents := make(map[string]*Totals)
+	channels := make(map[string]*Totals)
+	models := make(map[string]*Totals)
+	add := func(m map[string]*Totals, key string, totals Totals) {
+		if key == "" {
+			key = "-"
+		}
+		if m[key] == nil {
+			m[key] = &Totals{}
+		}
+		m[key].add(totals)
+	}
+
+	for _, b := range buckets {
+		if b.Day < r.From || b.Day > r.To {
+			continue
+		}
+		r.Total.add(b.Totals)
+		add(days, b.Day, b.Totals)
+		add(agents, b.Agent, b.Totals)
+		add(channels, b.Channel, b.Totals)
+		add(models, b.Model, b.Totals)
+	}
+
+	r.ByDay = lines(days)
+	sort.Slice(r.ByDay, func(i, j int) bool { return r.ByDay[i].Key < r.ByDay[j].Key })
+	r.ByAgent = byCost(lines(agents))
+	r.ByChannel = byCost(lines(channels))
+	r.ByModel = byCost(lines(models))
+	return r
+}
+
+func lines(m map[string]*Totals) []Line {
+	out := make([]Line, 0, len(m))
+	for k, v := range m {
+		out = append(out, Line{Key: k, Totals: *v})
+	}
+	return out
+}
+
+func byCost(l []Line) []Line {
+	sort.Slice(l, func(i, j int) bool {
+		if l[i].CostUSD != l[j].CostUSD {
+			return l[i].CostUSD > l[j].CostUSD
+		}
+		return l[i].Key < l[j].Key
+	})
+	return l
+}
+
+// Today returns the report range for the current day.
+func Today(now time.Time) (time.Time, time.Time) {
+	return now, now
+}
+
+// LastDays returns the report range for the last n days, including today.
+func LastDays(now time.Time, n int) (time.Time, time.Time) {
+	if n < 1 {
+		n = 1
+	}
+	return now.AddDate(0, 0, -(n - 1)), now
+}
+
+// MonthToDate returns the report range from the first of the month to today.
+func MonthToDate(now time.Time) (time.Time, time.Time) {
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), now
+}
+
+// Format renders a report as plain text for chat and the terminal. limit
+// caps the rows shown per breakdown.
+func Format(r *Report, title string, limit int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s (%s", title, r.From)
+	if r.To != r.From {
+		fmt.Fprintf(&sb, " to %s", r.To)
+	}
+	fmt.Fprintf(&sb, "): $%.4f, %d calls, %s in / %s out tokens\n",
+		r.Total.CostUSD, r.Total.Calls, formatTokens(r.Total.PromptTokens), formatTokens(r.Total.CompletionTokens))
+	if r.Total.CacheReadTokens > 0 || r.Total.CacheWriteTokens > 0 {
+		fmt.Fprintf(&sb, "  cache: %s read / %s written\n", formatTokens(r.Total.CacheReadTokens), formatTokens(r.Total.CacheWriteTokens))
+	}
+	if r.Total.ReasoningTokens > 0 {
+		fmt.Fprintf(&sb, "  reasoning: %s of the output tokens\n", formatTokens(r.Total.ReasoningTokens))
+	}
+	if r.Total.Calls == 0 {
+		return strings.TrimRight(sb.String(), "\n")
+	}
+
+	section := func(name string, rows []Line) {
+		if len(rows) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "%s:\n", name)
+		for i, row := range rows {
+			if limit > 0 && i >= limit {
+				fmt.Fprintf(&sb, "  ... %d more\n", len(rows)-limit)
+				break
+			}
+			fmt.Fprintf(&sb, "  %-28s $%.4f  (%d calls)\n", row.Key, row.CostUSD, row.Calls)
+		}
+	}
+	if len(r.ByDay) > 1 {
+		section("By day", r.ByDay)
+	}
+	section("By agent", r.ByAgent)
+	section("By channel", r.ByChannel)
+	section("By model", r.ByModel)
+	if r.Total.Unpriced > 0 {
+		fmt.Fprintf(&sb, "Note: %d calls used models without a known price; set costs.pricing in config.json.\n", r.Total.Unpriced)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func formatTokens(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	}
+	return fmt.Sprintf("%d", n)
+}
@@ -0,0 +1,341 @@
+package costs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	dayLayout     = "2006-01-02"
+	monthLayout   = "2006-01"
+	retentionDays = 400
+)
+
+// Budget alert levels, in percent of the budget.
+var alertLevels = []int{100, 80}
+
+// Entry is one LLM call to record.
+type Entry struct {
+	Agent    string
+	Channel  string
+	Provider string
+	Model    string
+	Usage    *providers.UsageInfo
+}
+
+// Totals accumulates token counts and cost.
+type Totals struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CacheReadTokens  int     `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int     `json:"cache_write_tokens,omitempty"`
+	ReasoningTokens  int     `json:"reasoning_tokens,omitempty"` // included in CompletionTokens
+	CostUSD          float64 `json:"cost_usd"`
+	Unpriced         int     `json:"unpriced,omitempty"` // calls to models without a known price
+}
+
+func (t *Totals) add(o Totals) {
+	t.Calls += o.Calls
+	t.PromptTokens += o.PromptTokens
+	t.CompletionTokens += o.CompletionTokens
+	t.CacheReadTokens += o.CacheReadTokens
+	t.CacheWriteTokens += o.CacheWriteTokens
+	t.ReasoningTokens += o.ReasoningTokens
+	t.CostUSD += o.CostUSD
+	t.Unpriced += o.Unpriced
+}
+
+// Bucket aggregates the calls of one day, agent, channel and model.
+type Bucket struct {
+	Day     string `json:"day"`
+	Agent   string `json:"agent"`
+	Channel string `json:"channel"`
+	Model   string `json:"model"`
+	Totals
+}
+
+type ledgerFile struct {
+	Buckets []*Bucket      `json:"buckets"`
+	Alerts  map[string]int `json:"alerts,omitempty"` // "day:2006-01-02" or "month:2006-01" -> highest level sent
+}
+
+func ledgerPath(workspace string) string {
+	return filepath.Join(workspace, "state", "costs.json")
+}
+
+func loadLedger(workspace string) (*ledgerFile, error) {
+	ledger := &ledgerFile{Alerts: make(map[string]int)}
+	data, err := os.ReadFile(ledgerPath(workspace))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ledger, nil
+		}
+		return ledger, err
+	}
+	if err := json.Unmarshal(data, ledger); err != nil {
+		return &ledgerFile{Alerts: make(map[string]int)}, fmt.Errorf("failed to parse cost ledger: %w", err)
+	}
+	if ledger.Alerts == nil {
+		ledger.Alerts = make(map[string]int)
+	}
+	return ledger, nil
+}
+
+func (l *ledgerFile) save(workspace string) error {
+	path := ledgerPath(workspace)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Tracker prices LLM calls and keeps per-day totals in
+// <workspace>/state/costs.json. A nil Tracker records nothing.
+type Tracker struct {
+	workspace     string
+	catalog       *Catalog
+	dailyBudget   float64
+	monthlyBudget float64
+
+	mu      sync.Mutex
+	ledger  *ledgerFile
+	index   map[string]*Bucket
+	modTime time.Time // ledger file time after our last load or save
+	onAlert func(message string)
+	now     func() time.Time
+}
+
+// NewTracker creates a tracker for workspace using the budgets and price
+// overrides in cfg.
+func NewTracker(workspace string, cfg config.CostsConfig) *Tracker {
+	t := &Tracker{
+		workspace:     workspace,
+		catalog:       NewCatalog(cfg.Pricing),
+		dailyBudget:   cfg.DailyBudget,
+		monthlyBudget: cfg.MonthlyBudget,
+		now:           time.Now,
+	}
+	t.reload()
+	return t
+}
+
+// reload reads the ledger from disk. The gateway, the agent CLI and the
+// Web UI may share a workspace, so the file is re-read whenever another
+// process has written it since our last save. Called with t.mu held.
+func (t *Tracker) reload() {
+	info, statErr := os.Stat(ledgerPath(t.workspace))
+	if t.ledger != nil && (statErr != nil || info.ModTime().Equal(t.modTime)) {
+		return
+	}
+
+	ledger, err := loadLedger(t.workspace)
+	if err != nil {
+		logger.WarnCF("costs", "Starting a new cost ledger", map[string]interface{}{"error": err.Error()})
+	}
+	t.ledger = ledger
+	t.index = make(map[string]*Bucket, len(ledger.Buckets))
+	for _, b := range ledger.Buckets {
+		t.index[bucketKey(b.Day, b.Agent, b.Channel, b.Model)] = b
+	}
+	if statErr == nil {
+		t.modTime = info.ModTime()
+	}
+}
+
+// SetAlertHandler sets the function that delivers budget alerts.
+func (t *Tracker) SetAlertHandler(fn func(message string)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onAlert = fn
+}
+
+func bucketKey(day, agent, channel, model string) string {
+	return day + "|" + agent + "|" + channel + "|" + model
+}
+
+// Record prices and stores one call, returning its cost in USD.
+func (t *Tracker) Record(e Entry) float64 {
+	if t == nil || e.Usage == nil {
+		return 0
+	}
+
+	price, known := t.catalog.Lookup(e.Provider, e.Model)
+	call := Totals{
+		Calls:            1,
+		PromptTokens:     e.Usage.PromptTokens,
+		CompletionTokens: e.Usage.CompletionTokens,
+		CacheReadTokens:  e.Usage.CacheReadTokens,
+		CacheWriteTokens: e.Usage.CacheWriteTokens,
+		ReasoningTokens:  e.Usage.ReasoningTokens,
+		CostUSD:          Cost(price, e.Usage),
+	}
+	if !known {
+		call.Unpriced = 1
+	}
+
+	model := e.Model
+	if e.Provider != "" && !strings.Contains(model, "/") {
+		model = providers.NormalizeProvider(e.Provider) + "/" + model
+	}
+
+	t.mu.Lock()
+	t.reload()
+	now := t.now()
+	day := now.Format(dayLayout)
+	key := bucketKey(day, e.Agent, e.Channel, model)
+	b, ok := t.index[key]
+	if !ok {
+		b = &Bucket{Day: day, Agent: e.Agent, Channel: e.Channel, Model: model}
+		t.index[key] = b
+		t.ledger.Buckets = append(t.ledger.Buckets, b)
+		t.prune(now)
+	}
+	b.add(call)
+
+	alerts := t.checkBudgets(now)
+	if err := t.ledger.save(t.workspace); err != nil {
+		logger.WarnCF("costs", "Failed to save cost ledger", map[string]interface{}{"error": err.Error()})
+	} else if info, err := os.Stat(ledgerPath(t.workspace)); err == nil {
+		t.modTime = info.ModTime()
+	}
+	onAlert := t.onAlert
+	t.mu.Unlock()
+
+	for _, msg := range alerts {
+		logger.WarnCF("costs", msg, nil)
+		if onAlert != nil {
+			onAlert(msg)
+		}
+	}
+	return call.CostUSD
+}
+
+// prune drops buckets older than the retention period.
+func (t *Tracker) prune(now time.Time) {
+	cutoff := now.AddDate(0, 0, -retentionDays).Format(dayLayout)
+	kept := t.ledger.Buckets[:0]
+	for _, b := range t.ledger.Buckets {
+		if b.Day >= cutoff {
+			kept = append(kept, b)
+		} else {
+			delete(t.index, bucketKey(b.Day, b.Agent, b.Channel, b.Model))
+		}
+	}
+	t.ledger.Buckets = kept
+	for key := range t.ledger.Alerts {
+		if _, period, _ := strings.Cut(key, ":"); len(period) <= len(cutoff) && period < cutoff[:len(period)] {
+			delete(t.ledger.Alerts, key)
+		}
+	}
+}
+
+// checkBudgets returns alert messages for budgets that crossed a new level.
+// Called with t.mu held.
+func (t *Tracker) checkBudgets(now time.Time) []string {
+	day := now.Format(dayLayout)
+	month := now.Format(monthLayout)
+	var daySpend, monthSpend float64
+	for _, b := range t.ledger.Buckets {
+		if b.Day == day {
+			daySpend += b.CostUSD
+		}
+		if strings.HasPrefix(b.Day, month) {
+			monthSpend += b.CostUSD
+		}
+	}
+
+	var alerts []string
+	check := func(period, key string, spent, budget float64) {
+		if budget <= 0 {
+			return
+		}
+		pct := int(spent / budget * 100)
+		for _, level := range alertLevels {
+			if pct < level || t.ledger.Alerts[key] >= level {
+				continue
+			}
+			t.ledger.Alerts[key] = level
+			if level >= 100 {
+				alerts = append(alerts, fmt.Sprintf("🚨 LLM spend %s is $%.2f, over the $%.2f budget.", period, spent, budget))
+			} else {
+				alerts = append(alerts, fmt.Sprintf("⚠️ LLM spend %s is $%.2f, %d%% of the $%.2f budget.", period, spent, pct, budget))
+			}
+			break
+		}
+	}
+	check("today", "day:"+day, daySpend, t.dailyBudget)
+	check("this month", "month:"+month, monthSpend, t.monthlyBudget)
+	return alerts
+}
+
+// Report summarizes recorded calls between from and to, inclusive.
+func (t *Tracker) Report(from, to time.Time) *Report {
+	if t == nil {
+		return buildReport(nil, from, to)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reload()
+	return buildReport(t.ledger.Buckets, from, to)
+}
+
+// LoadReport reads the ledger for workspace and summarizes it like Report.
+func LoadReport(workspace string, from, to time.Time) (*Report, error) {
+	ledger, err := loadLedger(workspace)
+	if err != nil {
+		return nil, err
+	}
+	return buildReport(ledger.Buckets, from, to), nil
+}
+
+// Meter wraps provider so that every successful call is recorded with the
+// agent, channel and provider of tmpl.
+func (t *Tracker) Meter(provider providers.LLMProvider, tmpl Entry) providers.LLMProvider {
+	if t == nil {
+		return provider
+	}
+	return &meteredProvider{LLMProvider: provider, tracker: t, tmpl: tmpl}
+}
+
+type meteredProvider struct {
+	providers.LLMProvider
+	tracker *Tracker
+	tmpl    Entry
+}
+
+func (m *meteredProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, options map[string]interface{}) (*providers.LLMResponse, error) {
+	resp, err := m.LLMProvider.Chat(ctx, messages, tools, model, options)
+	if err == nil && resp != nil {
+		e := m.tmpl
+		e.Model = model
+		e.Usage = resp.Usage
+		m.tracker.Record(e)
+	}
+	return resp, err
+}
@@ -1,6 +1,7 @@
 package cron
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
@@ -10,6 +11,7 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/adhocore/gronx"
 )
@@ -23,12 +25,36 @@ type CronSchedule struct {
 }
 
 type CronPayload struct {
-	Kind    string `json:"kind"`
-	Message string `json:"message"`
-	Command string `json:"command,omitempty"`
-	Deliver bool   `json:"deliver"`
-	Channel string `json:"channel,omitempty"`
-	To      string `json:"to,omitempty"`
+	Kind          string `json:"kind"`
+	Message       string `json:"message"`
+	Command       string `json:"command,omitempty"`
+	Deliver       bool   `json:"deliver"`
+	Channel       string `json:"channel,omitempty"`
+	To            string `json:"to,omitempty"`
+	Agent         string `json:"agent,omitempty"`         // agent ID from agents.list; empty uses routing
+	Session       string `json:"session,omitempty"`       // fresh, job or chat; empty keeps the job's own session
+	SessionKey    string `json:"sessionKey,omitempty"`    // chat session captured when the job was created
+	Model         string `json:"model,omitempty"`         // model override for agent runs
+	MaxIterations int    `json:"maxIterations,omitempty"` // tool iteration override for agent runs
+	// Schema is a JSON Schema the agent's reply must match.
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+// Session modes for agent-processed payloads.
+const (
+	SessionFresh = "fresh" // new, empty session on every run
+	SessionJob   = "job"   // one persistent session per job
+	SessionChat  = "chat"  // the target chat's existing session
+)
+
+// ValidSessionMode reports whether mode is a known session mode.
+// The empty string is valid and keeps the job's own session.
+func ValidSessionMode(mode string) bool {
+	switch mode {
+	case "", SessionFresh, SessionJob, SessionChat:
+		return true
+	}
+	return false
 }
 
 type CronJobState struct {
@@ -36,18 +62,79 @@ type CronJobState struct {
 	LastRunAtMS *int64 `json:"lastRunAtMs,omitempty"`
 	LastStatus  string `json:"lastStatus,omitempty"`
 	LastError   string `json:"lastError,omitempty"`
+	RetryCount  int    `json:"retryCount,omitempty"`  // retries already made for the current run
+	PendingRuns int    `json:"pendingRuns,omitempty"` // missed runs still to catch up (misfire "run_all")
+}
+
+// CronRetryPolicy controls how failed runs are retried. The delay before
+// retry n is BackoffMS * 2^(n-1), capped at MaxBackoffMS.
+type CronRetryPolicy struct {
+	MaxRetries   int   `json:"maxRetries"`
+	BackoffMS    int64 `json:"backoffMs,omitempty"`
+	MaxBackoffMS int64 `json:"maxBackoffMs,omitempty"`
 }
 
 type CronJob struct {
-	ID             string       `json:"id"`
-	Name           string       `json:"name"`
-	Enabled        bool         `json:"enabled"`
-	Schedule       CronSchedule `json:"schedule"`
-	Payload        CronPayload  `json:"payload"`
-	State          CronJobState `json:"state"`
-	CreatedAtMS    int64        `json:"createdAtMs"`
-	UpdatedAtMS    int64        `json:"updatedAtMs"`
-	DeleteAfterRun bool         `json:"deleteAfterRun"`
+	ID             string           `json:"id"`
+	Name           string           `json:"name"`
+	Enabled        bool             `json:"enabled"`
+	Schedule       CronSchedule     `json:"schedule"`
+	Payload        CronPayload      `json:"payload"`
+	State          CronJobState     `json:"state"`
+	Retry          *CronRetryPolicy `json:"retry,omitempty"`
+	TimeoutMS      int64            `json:"timeoutMs,omitempty"`
+	Misfire        string           `json:"misfire,omitempty"` // run_once, run_all or skip; empty uses the service default
+	CreatedAtMS    int64            `json:"createdAtMs"`
+	UpdatedAtMS    int64            `json:"updatedAtMs"`
+	DeleteAfterRun bool             `json:"deleteAfterRun"`
+}
+
+// Misfire policies decide what happens to runs that were due while the
+// service was not running.
+const (
+	MisfireSkip    = "skip"
+	MisfireRunOnce = "run_once"
+	MisfireRunAll  = "run_all"
+)
+
+// Run statuses recorded in job state and run history.
+const (
+	RunStatusOK      = "ok"
+	RunStatusError   = "error"
+	RunStatusTimeout = "timeout"
+)
+
+const (
+	defaultHistoryLimit   = 20
+	defaultRetryBackoffMS = 30_000
+	maxCatchUpRuns        = 100
+	maxOutputExcerpt      = 500
+)
+
+// CronRunRecord is one entry in a job's run history.
+type CronRunRecord struct {
+	StartedAtMS int64  `json:"startedAtMs"`
+	DurationMS  int64  `json:"durationMs"`
+	Status      string `json:"status"`
+	Attempt     int    `json:"attempt"`
+	Output      string `json:"output,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// CronHistory is persisted as history.json next to jobs.json.
+type CronHistory struct {
+	Version int                        `json:"version"`
+	Runs    map[string][]CronRunRecord `json:"runs"`
+}
+
+// ValidMisfirePolicy reports whether policy is a known misfire policy.
+// The empty string is valid and means "use the service default".
+func ValidMisfirePolicy(policy string) bool {
+	switch policy {
+	case "", MisfireSkip, MisfireRunOnce, MisfireRunAll:
+		return true
+	}
+	return false
 }
 
 type CronStore struct {
@@ -55,29 +142,59 @@ type CronStore struct {
 	Jobs    []CronJob `json:"jobs"`
 }
 
-type JobHandler func(job *CronJob) (string, error)
+// JobHandler runs a job. The context is cancelled when the job's timeout
+// expires. The returned string is kept as an excerpt in the run history.
+type JobHandler func(ctx context.Context, job *CronJob) (string, error)
 
 type CronService struct {
-	storePath string
-	store     *CronStore
-	onJob     JobHandler
-	mu        sync.RWMutex
-	running   bool
-	stopChan  chan struct{}
-	gronx     *gronx.Gronx
+	storePath      string
+	historyPath    string
+	store          *CronStore
+	history        *CronHistory
+	historyLimit   int
+	defaultMisfire string
+	onJob          JobHandler
+	mu             sync.RWMutex
+	running        bool
+	stopChan       chan struct{}
+	gronx          *gronx.Gronx
+	overrun        map[string]bool // jobs whose handler outlived its timeout and is still running
 }
 
 func NewCronService(storePath string, onJob JobHandler) *CronService {
 	cs := &CronService{
-		storePath: storePath,
-		onJob:     onJob,
-		gronx:     gronx.New(),
+		storePath:      storePath,
+		historyPath:    filepath.Join(filepath.Dir(storePath), "history.json"),
+		historyLimit:   defaultHistoryLimit,
+		defaultMisfire: MisfireSkip,
+		onJob:          onJob,
+		gronx:          gronx.New(),
+		overrun:        make(map[string]bool),
 	}
 	// Initialize and load store on creation
 	cs.loadStore()
+	cs.loadHistory()
 	return cs
 }
 
+// SetHistoryLimit sets how many runs are kept per job. Values <= 0 keep the default.
+func (cs *CronService) SetHistoryLimit(limit int) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if limit > 0 {
+		cs.historyLimit = limit
+	}
+}
+
+// SetDefaultMisfirePolicy sets the policy for jobs that don't specify one.
+func (cs *CronService) SetDefaultMisfirePolicy(policy string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if policy != "" && ValidMisfirePolicy(policy) {
+		cs.defaultMisfire = policy
+	}
+}
+
 func (cs *CronService) Start() error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
@@ -186,21 +303,52 @@ func (cs *CronService) executeJobByID(jobID string) {
 			break
 		}
 	}
+	handler := cs.onJob
+	overrun := cs.overrun[jobID]
 	cs.mu.RUnlock()
 
 	if callbackJob == nil {
 		return
 	}
+	if overrun {
+		cs.skipOverrunJob(jobID)
+		return
+	}
 
+	var output string
 	var err error
-	if cs.onJob != nil {
-		_, err = cs.onJob(callbackJob)
+	var finished <-chan struct{}
+	status := RunStatusOK
+	if handler != nil {
+		output, finished, err = runWithTimeout(handler, callbackJob)
+		if err == context.DeadlineExceeded {
+			status = RunStatusTimeout
+			err = fmt.Errorf("timed out after %s", time.Duration(callbackJob.TimeoutMS)*time.Millisecond)
+		} else if err != nil {
+			status = RunStatusError
+		}
 	}
 
 	// Now acquire lock to update state
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	// A handler that ignores its context keeps running after the timeout.
+	// Until it returns, the job is neither retried nor started again.
+	if status == RunStatusTimeout {
+		select {
+		case <-finished:
+		default:
+			cs.overrun[jobID] = true
+			go func() {
+				<-finished
+				cs.mu.Lock()
+				delete(cs.overrun, jobID)
+				cs.mu.Unlock()
+			}()
+		}
+	}
+
 	var job *CronJob
 	for i := range cs.store.Jobs {
 		if cs.store.Jobs[i].ID == jobID {
@@ -213,18 +361,54 @@ func (cs *CronService) executeJobByID(jobID string) {
 		return
 	}
 
+	now := time.Now().UnixMilli()
 	job.State.LastRunAtMS = &startTime
-	job.UpdatedAtMS = time.Now().UnixMilli()
-
+	job.UpdatedAtMS = now
+	job.State.LastStatus = status
+	job.State.LastError = ""
 	if err != nil {
-		job.State.LastStatus = "error"
 		job.State.LastError = err.Error()
+	}
+
+	record := CronRunRecord{
+		StartedAtMS: startTime,
+		DurationMS:  now - startTime,
+		Status:      status,
+		Attempt:     job.State.RetryCount + 1,
+		Output:      excerpt(output, maxOutputExcerpt),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	cs.appendHistoryUnsafe(job.ID, record)
+
+	if err != nil && job.Retry != nil && job.State.RetryCount < job.Retry.MaxRetries && !cs.overrun[jobID] {
+		job.State.RetryCount++
+		next := now + retryDelayMS(job.Retry, job.State.RetryCount)
+		job.State.NextRunAtMS = &next
+		log.Printf("[cron] job %s failed (%v), retry %d/%d scheduled", job.ID, err, job.State.RetryCount, job.Retry.MaxRetries)
 	} else {
-		job.State.LastStatus = "ok"
-		job.State.LastError = ""
+		job.State.RetryCount = 0
+		cs.scheduleAfterRunUnsafe(job, now)
+	}
+
+	if err := cs.saveStoreUnsafe(); err != nil {
+		log.Printf("[cron] failed to save store: %v", err)
+	}
+	if err := cs.saveHistoryUnsafe(); err != nil {
+		log.Printf("[cron] failed to save history: %v", err)
+	}
+}
+
+// scheduleAfterRunUnsafe computes the next run once a run has finished
+// (successfully or with retries exhausted).
+func (cs *CronService) scheduleAfterRunUnsafe(job *CronJob, nowMS int64) {
+	if job.State.PendingRuns > 0 {
+		job.State.PendingRuns--
+		job.State.NextRunAtMS = &nowMS
+		return
 	}
 
-	// Compute next run time
 	if job.Schedule.Kind == "at" {
 		if job.DeleteAfterRun {
 			cs.removeJobUnsafe(job.ID)
@@ -232,17 +416,104 @@ func (cs *CronService) executeJobByID(jobID string) {
 			job.Enabled = false
 			job.State.NextRunAtMS = nil
 		}
-	} else {
-		nextRun := cs.computeNextRun(&job.Schedule, time.Now().UnixMilli())
-		job.State.NextRunAtMS = nextRun
+		return
 	}
 
-	if err := cs.saveStoreUnsafe(); err != nil {
-		log.Printf("[cron] failed to save store: %v", err)
+	job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, nowMS)
+}
+
+// skipOverrunJob schedules the next run of a job whose previous run is
+// still going without starting it now.
+func (cs *CronService) skipOverrunJob(jobID string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for i := range cs.store.Jobs {
+		job := &cs.store.Jobs[i]
+		if job.ID != jobID {
+			continue
+		}
+		log.Printf("[cron] job %s skipped: previous run is still going after its timeout", jobID)
+		job.State.RetryCount = 0
+		cs.scheduleAfterRunUnsafe(job, time.Now().UnixMilli())
+		if err := cs.saveStoreUnsafe(); err != nil {
+			log.Printf("[cron] failed to save store: %v", err)
+		}
+		return
+	}
+}
+
+// runWithTimeout invokes the handler, giving up once the job's timeout
+// expires even if the handler ignores its context. finished is closed when
+// the handler has returned.
+func runWithTimeout(handler JobHandler, job *CronJob) (output string, finished <-chan struct{}, err error) {
+	done := make(chan struct{})
+	if job.TimeoutMS <= 0 {
+		defer close(done)
+		output, err = handler(context.Background(), job)
+		return output, done, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(job.TimeoutMS)*time.Millisecond)
+
+	type result struct {
+		output string
+		err    error
+	}
+	results := make(chan result, 1)
+	go func() {
+		defer close(done)
+		defer cancel()
+		output, err := handler(ctx, job)
+		results <- result{output, err}
+	}()
+
+	select {
+	case r := <-results:
+		if r.err != nil && ctx.Err() == context.DeadlineExceeded {
+			return r.output, done, context.DeadlineExceeded
+		}
+		return r.output, done, r.err
+	case <-ctx.Done():
+		return "", done, context.DeadlineExceeded
 	}
 }
 
+func retryDelayMS(policy *CronRetryPolicy, retry int) int64 {
+	delay := policy.BackoffMS
+	if delay <= 0 {
+		delay = defaultRetryBackoffMS
+	}
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if policy.MaxBackoffMS > 0 && delay >= policy.MaxBackoffMS {
+			return policy.MaxBackoffMS
+		}
+	}
+	if policy.MaxBackoffMS > 0 && delay > policy.MaxBackoffMS {
+		delay = policy.MaxBackoffMS
+	}
+	return delay
+}
+
+func excerpt(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	s = s[:maxLen]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s + "..."
+}
+
 func (cs *CronService) computeNextRun(schedule *CronSchedule, nowMS int64) *int64 {
+	return NextRun(schedule, nowMS)
+}
+
+// NextRun returns the first time after nowMS at which schedule fires, or nil
+// if it never fires again.
+func NextRun(schedule *CronSchedule, nowMS int64) *int64 {
 	if schedule.Kind == "at" {
 		if schedule.AtMS != nil && *schedule.AtMS > nowMS {
 			return schedule.AtMS
@@ -278,16 +549,61 @@ func (cs *CronService) computeNextRun(schedule *CronSchedule, nowMS int64) *int6
 	return nil
 }
 
+// recomputeNextRuns is called on startup. Jobs whose stored next run is
+// already in the past were missed while the service was down and are handled
+// according to their misfire policy; everything else is rescheduled from now.
 func (cs *CronService) recomputeNextRuns() {
 	now := time.Now().UnixMilli()
 	for i := range cs.store.Jobs {
 		job := &cs.store.Jobs[i]
-		if job.Enabled {
+		if !job.Enabled {
+			continue
+		}
+
+		missed := job.State.NextRunAtMS != nil && *job.State.NextRunAtMS < now
+		if !missed {
+			job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
+			continue
+		}
+
+		switch cs.misfirePolicy(job) {
+		case MisfireRunOnce:
+			job.State.NextRunAtMS = &now
+		case MisfireRunAll:
+			count := cs.countMissedRuns(&job.Schedule, *job.State.NextRunAtMS, now)
+			job.State.PendingRuns = count - 1
+			job.State.NextRunAtMS = &now
+		default:
+			job.State.RetryCount = 0
+			job.State.PendingRuns = 0
 			job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
 		}
 	}
 }
 
+func (cs *CronService) misfirePolicy(job *CronJob) string {
+	if job.Misfire != "" && ValidMisfirePolicy(job.Misfire) {
+		return job.Misfire
+	}
+	return cs.defaultMisfire
+}
+
+// countMissedRuns counts the occurrences of schedule in [fromMS, nowMS],
+// capped at maxCatchUpRuns. fromMS is itself a missed occurrence.
+func (cs *CronService) countMissedRuns(schedule *CronSchedule, fromMS, nowMS int64) int {
+	count := 1
+	next := fromMS
+	for count < maxCatchUpRuns {
+		n := cs.computeNextRun(schedule, next)
+		if n == nil || *n > nowMS || *n <= next {
+			break
+		}
+		next = *n
+		count++
+	}
+	return count
+}
+
 func (cs *CronService) getNextWakeMS() *int64 {
 	var nextWake *int64
 	for _, job := range cs.store.Jobs {
@@ -329,6 +645,82 @@ func (cs *CronService) loadStore() error {
 	return json.Unmarshal(data, cs.store)
 }
 
+func (cs *CronService) loadHistory() error {
+	cs.history = &CronHistory{
+		Version: 1,
+		Runs:    make(map[string][]CronRunRecord),
+	}
+
+	data, err := os.ReadFile(cs.historyPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if err := json.Unmarshal(data, cs.history); err != nil {
+		return err
+	}
+	if cs.history.Runs == nil {
+		cs.history.Runs = make(map[string][]CronRunRecord)
+	}
+	return nil
+}
+
+func (cs *CronService) saveHistoryUnsafe() error {
+	dir := filepath.Dir(cs.historyPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cs.history, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(cs.historyPath, data, 0600)
+}
+
+func (cs *CronService) appendHistoryUnsafe(jobID string, record CronRunRecord) {
+	runs := append(cs.history.Runs[jobID], record)
+	if len(runs) > cs.historyLimit {
+		runs = runs[len(runs)-cs.historyLimit:]
+	}
+	cs.history.Runs[jobID] = runs
+}
+
+// GetHistory returns the recorded runs of a job, most recent first.
+// A limit <= 0 returns everything that is kept.
+func (cs *CronService) GetHistory(jobID string, limit int) []CronRunRecord {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	runs := cs.history.Runs[jobID]
+	result := make([]CronRunRecord, 0, len(runs))
+	for i := len(runs) - 1; i >= 0; i-- {
+		result = append(result, runs[i])
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+	return result
+}
+
+// GetJob returns a copy of the job with the given ID.
+func (cs *CronService) GetJob(jobID string) (*CronJob, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	for i := range cs.store.Jobs {
+		if cs.store.Jobs[i].ID == jobID {
+			job := cs.store.Jobs[i]
+			return &job, true
+		}
+	}
+	return nil, false
+}
+
 func (cs *CronService) saveStoreUnsafe() error {
 	dir := filepath.Dir(cs.storePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -416,6 +808,12 @@ func (cs *CronService) removeJobUnsafe(jobID string) bool {
 		if err := cs.saveStoreUnsafe(); err != nil {
 			log.Printf("[cron] failed to save store after remove: %v", err)
 		}
+		if _, ok := cs.history.Runs[jobID]; ok {
+			delete(cs.history.Runs, jobID)
+			if err := cs.saveHistoryUnsafe(); err != nil {
+				log.Printf("[cron] failed to save history after remove: %v", err)
+			}
+		}
 	}
 
 	return removed
@@ -431,6 +829,8 @@ func (cs *CronService) EnableJob(jobID string, enabled bool) *CronJob {
 			job.Enabled = enabled
 			job.UpdatedAtMS = time.Now().UnixMilli()
 
+			job.State.RetryCount = 0
+			job.State.PendingRuns = 0
 			if enabled {
 				job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, time.Now().UnixMilli())
 			} else {
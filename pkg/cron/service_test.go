@@ -1,10 +1,14 @@
 package cron
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestSaveStore_FilePermissions(t *testing.T) {
@@ -33,6 +37,230 @@ func TestSaveStore_FilePermissions(t *testing.T) {
 	}
 }
 
+func TestExecuteJob_RetryWithBackoff(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "cron", "jobs.json")
+
+	calls := 0
+	cs := NewCronService(storePath, func(ctx context.Context, job *CronJob) (string, error) {
+		calls++
+		if calls < 3 {
+			return "", errors.New("boom")
+		}
+		return "done", nil
+	})
+
+	job, err := cs.AddJob("retry", CronSchedule{Kind: "every", EveryMS: int64Ptr(3600000)}, "hi", false, "cli", "direct")
+	if err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+	job.Retry = &CronRetryPolicy{MaxRetries: 2, BackoffMS: 1000, MaxBackoffMS: 1500}
+	if err := cs.UpdateJob(job); err != nil {
+		t.Fatalf("UpdateJob failed: %v", err)
+	}
+
+	before := time.Now().UnixMilli()
+	cs.executeJobByID(job.ID)
+	got, _ := cs.GetJob(job.ID)
+	if got.State.RetryCount != 1 || got.State.LastStatus != RunStatusError {
+		t.Fatalf("after first failure: retry=%d status=%q", got.State.RetryCount, got.State.LastStatus)
+	}
+	if next := *got.State.NextRunAtMS - before; next < 1000 || next > 2000 {
+		t.Errorf("first retry delay = %dms, want ~1000ms", next)
+	}
+
+	cs.executeJobByID(job.ID)
+	got, _ = cs.GetJob(job.ID)
+	if next := *got.State.NextRunAtMS - time.Now().UnixMilli(); next > 1500 {
+		t.Errorf("second retry delay = %dms, want capped at 1500ms", next)
+	}
+
+	cs.executeJobByID(job.ID)
+	got, _ = cs.GetJob(job.ID)
+	if got.State.RetryCount != 0 || got.State.LastStatus != RunStatusOK {
+		t.Errorf("after success: retry=%d status=%q", got.State.RetryCount, got.State.LastStatus)
+	}
+	if next := *got.State.NextRunAtMS - time.Now().UnixMilli(); next < 3000000 {
+		t.Errorf("next run after success = %dms, want regular interval", next)
+	}
+
+	runs := cs.GetHistory(job.ID, 0)
+	if len(runs) != 3 {
+		t.Fatalf("history has %d runs, want 3", len(runs))
+	}
+	if runs[0].Status != RunStatusOK || runs[0].Attempt != 3 || runs[0].Output != "done" {
+		t.Errorf("latest run = %+v", runs[0])
+	}
+	if runs[2].Error != "boom" || runs[2].Attempt != 1 {
+		t.Errorf("oldest run = %+v", runs[2])
+	}
+}
+
+func TestExecuteJob_Timeout(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "cron", "jobs.json")
+
+	cs := NewCronService(storePath, func(ctx context.Context, job *CronJob) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	job, _ := cs.AddJob("slow", CronSchedule{Kind: "every", EveryMS: int64Ptr(60000)}, "hi", false, "cli", "direct")
+	job.TimeoutMS = 50
+	cs.UpdateJob(job)
+
+	cs.executeJobByID(job.ID)
+
+	got, _ := cs.GetJob(job.ID)
+	if got.State.LastStatus != RunStatusTimeout {
+		t.Errorf("LastStatus = %q, want %q", got.State.LastStatus, RunStatusTimeout)
+	}
+	if got.State.NextRunAtMS == nil {
+		t.Error("job should be rescheduled after a timeout")
+	}
+}
+
+func TestExecuteJob_TimeoutIgnoredByHandler(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "cron", "jobs.json")
+
+	release := make(chan struct{})
+	var calls atomic.Int32
+	cs := NewCronService(storePath, func(ctx context.Context, job *CronJob) (string, error) {
+		calls.Add(1)
+		<-release
+		return "late", nil
+	})
+
+	job, _ := cs.AddJob("stuck", CronSchedule{Kind: "every", EveryMS: int64Ptr(60000)}, "hi", false, "cli", "direct")
+	job.TimeoutMS = 50
+	job.Retry = &CronRetryPolicy{MaxRetries: 3, BackoffMS: 10}
+	cs.UpdateJob(job)
+
+	cs.executeJobByID(job.ID)
+	got, _ := cs.GetJob(job.ID)
+	if got.State.LastStatus != RunStatusTimeout || got.State.RetryCount != 0 {
+		t.Errorf("state = %+v, want a timeout without a retry while the handler runs", got.State)
+	}
+
+	// The next run is skipped until the handler returns.
+	cs.executeJobByID(job.ID)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler called %d times while still running", n)
+	}
+
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		cs.mu.RLock()
+		overrun := cs.overrun[job.ID]
+		cs.mu.RUnlock()
+		if !overrun {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job still marked as running after its handler returned")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cs.executeJobByID(job.ID)
+	if n := calls.Load(); n != 2 {
+		t.Errorf("handler called %d times, want 2 once the stuck run ended", n)
+	}
+}
+
+func TestHistory_BoundedAndPersisted(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "cron", "jobs.json")
+	handler := func(ctx context.Context, job *CronJob) (string, error) { return "ok", nil }
+
+	cs := NewCronService(storePath, handler)
+	cs.SetHistoryLimit(3)
+	job, _ := cs.AddJob("h", CronSchedule{Kind: "every", EveryMS: int64Ptr(60000)}, "hi", false, "cli", "direct")
+	for i := 0; i < 5; i++ {
+		cs.executeJobByID(job.ID)
+	}
+
+	if runs := cs.GetHistory(job.ID, 0); len(runs) != 3 {
+		t.Errorf("history has %d runs, want 3", len(runs))
+	}
+	if runs := cs.GetHistory(job.ID, 2); len(runs) != 2 {
+		t.Errorf("limited history has %d runs, want 2", len(runs))
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(storePath), "history.json")); err != nil {
+		t.Fatalf("history.json not written: %v", err)
+	}
+
+	reloaded := NewCronService(storePath, handler)
+	if runs := reloaded.GetHistory(job.ID, 0); len(runs) != 3 {
+		t.Errorf("reloaded history has %d runs, want 3", len(runs))
+	}
+
+	reloaded.RemoveJob(job.ID)
+	if runs := reloaded.GetHistory(job.ID, 0); len(runs) != 0 {
+		t.Errorf("history kept %d runs after job removal", len(runs))
+	}
+}
+
+func TestStart_MisfirePolicies(t *testing.T) {
+	now := time.Now().UnixMilli()
+	missedAt := now - 5*60000 - 1000 // five one-minute intervals ago
+
+	tests := []struct {
+		policy      string
+		wantDueNow  bool
+		wantPending int
+	}{
+		{MisfireSkip, false, 0},
+		{MisfireRunOnce, true, 0},
+		{MisfireRunAll, true, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			storePath := filepath.Join(t.TempDir(), "cron", "jobs.json")
+			cs := NewCronService(storePath, nil)
+			job, _ := cs.AddJob("m", CronSchedule{Kind: "every", EveryMS: int64Ptr(60000)}, "hi", false, "cli", "direct")
+			job.Misfire = tt.policy
+			job.State.NextRunAtMS = &missedAt
+			cs.UpdateJob(job)
+
+			cs = NewCronService(storePath, nil)
+			if err := cs.Start(); err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+			cs.Stop()
+
+			got, _ := cs.GetJob(job.ID)
+			dueNow := *got.State.NextRunAtMS <= time.Now().UnixMilli()
+			if dueNow != tt.wantDueNow {
+				t.Errorf("due now = %v, want %v", dueNow, tt.wantDueNow)
+			}
+			if got.State.PendingRuns != tt.wantPending {
+				t.Errorf("PendingRuns = %d, want %d", got.State.PendingRuns, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestStart_MisfireDefaultPolicy(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "cron", "jobs.json")
+	cs := NewCronService(storePath, nil)
+	job, _ := cs.AddJob("m", CronSchedule{Kind: "every", EveryMS: int64Ptr(60000)}, "hi", false, "cli", "direct")
+	missedAt := time.Now().UnixMilli() - 120000
+	job.State.NextRunAtMS = &missedAt
+	cs.UpdateJob(job)
+
+	cs = NewCronService(storePath, nil)
+	cs.SetDefaultMisfirePolicy(MisfireRunOnce)
+	if err := cs.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	cs.Stop()
+
+	got, _ := cs.GetJob(job.ID)
+	if *got.State.NextRunAtMS > time.Now().UnixMilli() {
+		t.Error("missed job should be due immediately with run_once default")
+	}
+}
+
 func int64Ptr(v int64) *int64 {
 	return &v
 }
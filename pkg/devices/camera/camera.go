@@ -0,0 +1,144 @@
+// Package camera captures still images from Video4Linux2 devices
+// (/dev/videoN), such as USB webcams and board camera interfaces.
+package camera
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrUnsupported is returned on platforms without Video4Linux2.
+var ErrUnsupported = errors.New("V4L2 cameras are only supported on Linux")
+
+// DeviceInfo describes a video capture device.
+type DeviceInfo struct {
+	Path    string   `json:"path"`
+	Name    string   `json:"name"`
+	Driver  string   `json:"driver"`
+	Bus     string   `json:"bus,omitempty"`
+	Formats []string `json:"formats,omitempty"` // FourCC codes, e.g. "MJPG", "YUYV"
+}
+
+// Options configures a capture.
+type Options struct {
+	Width   int           // requested frame width; the driver picks the nearest size it supports
+	Height  int           // requested frame height
+	Skip    int           // frames to drop first while exposure settles
+	Timeout time.Duration // for the whole capture
+	Quality int           // JPEG quality when the camera does not produce JPEG
+}
+
+// Still is a captured JPEG image.
+type Still struct {
+	JPEG   []byte
+	Width  int
+	Height int
+	Format string // the camera's pixel format the still was made from
+}
+
+const (
+	defaultWidth   = 1280
+	defaultHeight  = 720
+	defaultSkip    = 5
+	defaultTimeout = 10 * time.Second
+	defaultQuality = 90
+)
+
+func (o Options) withDefaults() Options {
+	if o.Width <= 0 || o.Height <= 0 {
+		o.Width, o.Height = defaultWidth, defaultHeight
+	}
+	if o.Skip < 0 {
+		o.Skip = 0
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.Quality <= 0 || o.Quality > 100 {
+		o.Quality = defaultQuality
+	}
+	return o
+}
+
+var devicePattern = regexp.MustCompile(`^(?:/dev/)?(?:video)?(\d+)$`)
+
+// DevicePath resolves "0", "video0" or "/dev/video0" to "/dev/video0". An
+// empty device means /dev/video0.
+func DevicePath(device string) (string, error) {
+	if device == "" {
+		return "/dev/video0", nil
+	}
+	m := devicePattern.FindStringSubmatch(strings.TrimSpace(device))
+	if m == nil {
+		return "", fmt.Errorf("invalid camera device %q (e.g. \"0\" or \"/dev/video0\")", device)
+	}
+	return "/dev/video" + m[1], nil
+}
+
+// fourCC packs a four character pixel format code.
+func fourCC(s string) uint32 {
+	return uint32(s[0]) | uint32(s[1])<<8 | uint32(s[2])<<16 | uint32(s[3])<<24
+}
+
+func fourCCString(v uint32) string {
+	return strings.TrimRight(string([]byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)}), " \x00")
+}
+
+var (
+	formatMJPEG = fourCC("MJPG")
+	formatJPEG  = fourCC("JPEG")
+	formatYUYV  = fourCC("YUYV")
+)
+
+// yuyvImage converts a packed YUYV 4:2:2 frame to an image.
+func yuyvImage(data []byte, width, height, stride int) (*image.YCbCr, error) {
+	if stride < width*2 {
+		stride = width * 2
+	}
+	if width%2 != 0 || len(data) < stride*(height-1)+width*2 {
+		return nil, fmt.Errorf("YUYV frame is %d bytes, too short for %dx%d", len(data), width, height)
+	}
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio422)
+	for y := 0; y < height; y++ {
+		row := data[y*stride:]
+		yRow := img.Y[y*img.YStride:]
+		cbRow := img.Cb[y*img.CStride:]
+		crRow := img.Cr[y*img.CStride:]
+		for x := 0; x < width/2; x++ {
+			p := row[x*4 : x*4+4]
+			yRow[2*x] = p[0]
+			cbRow[x] = p[1]
+			yRow[2*x+1] = p[2]
+			crRow[x] = p[3]
+		}
+	}
+	return img, nil
+}
+
+// encodeFrame turns a raw frame into a JPEG still.
+func encodeFrame(data []byte, format uint32, width, height, stride, quality int) ([]byte, error) {
+	switch format {
+	case formatMJPEG, formatJPEG:
+		if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+			return nil, fmt.Errorf("camera returned an invalid JPEG frame")
+		}
+		return append([]byte(nil), data...), nil
+	case formatYUYV:
+		img, err := yuyvImage(data, width, height, stride)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unsupported pixel format %s", fourCCString(format))
+}
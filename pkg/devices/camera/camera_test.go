@@ -0,0 +1,74 @@
+package camera
+
+import (
+	"bytes"
+	"image/jpeg"
+	"testing"
+)
+
+func TestDevicePath(t *testing.T) {
+	for in, want := range map[string]string{
+		"":            "/dev/video0",
+		"2":           "/dev/video2",
+		"video1":      "/dev/video1",
+		"/dev/video3": "/dev/video3",
+	} {
+		if got, err := DevicePath(in); err != nil || got != want {
+			t.Errorf("DevicePath(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"../video0", "/dev/sda", "video"} {
+		if _, err := DevicePath(in); err == nil {
+			t.Errorf("DevicePath(%q) succeeded", in)
+		}
+	}
+}
+
+func TestYUYVImage(t *testing.T) {
+	// 4x2 frame with a 2-byte row pad: left half dark, right half bright.
+	stride := 10
+	data := make([]byte, stride*2)
+	for y := 0; y < 2; y++ {
+		copy(data[y*stride:], []byte{16, 100, 20, 110, 235, 120, 240, 130})
+	}
+	img, err := yuyvImage(data, 4, 2, stride)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.YCbCrAt(1, 1); got.Y != 20 || got.Cb != 100 || got.Cr != 110 {
+		t.Errorf("pixel (1,1) = %+v", got)
+	}
+	if got := img.YCbCrAt(2, 0); got.Y != 235 || got.Cb != 120 || got.Cr != 130 {
+		t.Errorf("pixel (2,0) = %+v", got)
+	}
+
+	if _, err := yuyvImage(data[:12], 4, 2, stride); err == nil {
+		t.Error("short frame accepted")
+	}
+}
+
+func TestEncodeFrame(t *testing.T) {
+	data := bytes.Repeat([]byte{128, 128, 128, 128}, 8*8/2)
+	out, err := encodeFrame(data, formatYUYV, 8, 8, 16, 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+	if err != nil || cfg.Width != 8 || cfg.Height != 8 {
+		t.Errorf("encoded JPEG = %+v, %v", cfg, err)
+	}
+
+	mjpeg := []byte{0xFF, 0xD8, 0xFF, 0xD9}
+	if out, err := encodeFrame(mjpeg, formatMJPEG, 8, 8, 0, 90); err != nil || !bytes.Equal(out, mjpeg) {
+		t.Errorf("MJPG frame = %x, %v", out, err)
+	}
+	if _, err := encodeFrame([]byte{0, 0}, formatMJPEG, 8, 8, 0, 90); err == nil {
+		t.Error("invalid MJPG frame accepted")
+	}
+	if _, err := encodeFrame(data, fourCC("NV12"), 8, 8, 8, 90); err == nil {
+		t.Error("unsupported format accepted")
+	}
+	if got := fourCCString(formatMJPEG); got != "MJPG" {
+		t.Errorf("fourCCString = %q", got)
+	}
+}
@@ -0,0 +1,301 @@
+//go:build linux
+
+package camera
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	bufTypeVideoCapture = 1
+	memoryMmap          = 1
+
+	capVideoCapture = 0x00000001
+	capStreaming    = 0x04000000
+	capDeviceCaps   = 0x80000000
+
+	captureBuffers = 2
+)
+
+// Structures from <linux/videodev2.h>.
+type v4l2Capability struct {
+	driver       [16]byte
+	card         [32]byte
+	busInfo      [32]byte
+	version      uint32
+	capabilities uint32
+	deviceCaps   uint32
+	reserved     [3]uint32
+}
+
+type v4l2FmtDesc struct {
+	index       uint32
+	typ         uint32
+	flags       uint32
+	description [32]byte
+	pixelFormat uint32
+	mbusCode    uint32
+	reserved    [3]uint32
+}
+
+type v4l2PixFormat struct {
+	width        uint32
+	height       uint32
+	pixelFormat  uint32
+	field        uint32
+	bytesPerLine uint32
+	sizeImage    uint32
+	colorspace   uint32
+	priv         uint32
+	flags        uint32
+	ycbcrEnc     uint32
+	quantization uint32
+	xferFunc     uint32
+}
+
+// v4l2Format is struct v4l2_format; the 200-byte union is pointer aligned.
+type v4l2Format struct {
+	typ uint32
+	fmt struct {
+		_   [0]uintptr
+		pix v4l2PixFormat
+		_   [200 - unsafe.Sizeof(v4l2PixFormat{})]byte
+	}
+}
+
+type v4l2RequestBuffers struct {
+	count        uint32
+	typ          uint32
+	memory       uint32
+	capabilities uint32
+	flags        uint8
+	reserved     [3]uint8
+}
+
+type v4l2Buffer struct {
+	index     uint32
+	typ       uint32
+	bytesUsed uint32
+	flags     uint32
+	field     uint32
+	timestamp syscall.Timeval
+	timecode  [16]byte
+	sequence  uint32
+	memory    uint32
+	m         uintptr // union; the mmap offset is its first four bytes
+	length    uint32
+	reserved2 uint32
+	requestFD uint32
+}
+
+func (b *v4l2Buffer) offset() uint32 {
+	return *(*uint32)(unsafe.Pointer(&b.m))
+}
+
+// Ioctl numbers, _IOR/_IOW/_IOWR('V', nr, size).
+var (
+	queryCapIoctl  = ioctlNumber(2, 0, unsafe.Sizeof(v4l2Capability{}))
+	enumFmtIoctl   = ioctlNumber(3, 2, unsafe.Sizeof(v4l2FmtDesc{}))
+	setFmtIoctl    = ioctlNumber(3, 5, unsafe.Sizeof(v4l2Format{}))
+	reqBufsIoctl   = ioctlNumber(3, 8, unsafe.Sizeof(v4l2RequestBuffers{}))
+	queryBufIoctl  = ioctlNumber(3, 9, unsafe.Sizeof(v4l2Buffer{}))
+	qBufIoctl      = ioctlNumber(3, 15, unsafe.Sizeof(v4l2Buffer{}))
+	dqBufIoctl     = ioctlNumber(3, 17, unsafe.Sizeof(v4l2Buffer{}))
+	streamOnIoctl  = ioctlNumber(1, 18, 4)
+	streamOffIoctl = ioctlNumber(1, 19, 4)
+)
+
+func ioctlNumber(dir, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | 'V'<<8 | nr
+}
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	for {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(arg))
+		switch errno {
+		case 0:
+			return nil
+		case syscall.EINTR:
+			continue
+		}
+		return errno
+	}
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+// Devices lists the video capture devices under /dev.
+func Devices() ([]DeviceInfo, error) {
+	paths, err := filepath.Glob("/dev/video*")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var devices []DeviceInfo
+	for _, p := range paths {
+		fd, err := syscall.Open(p, syscall.O_RDWR|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			continue
+		}
+		info, caps, err := queryDevice(fd, p)
+		if err == nil && caps&capVideoCapture != 0 {
+			info.Formats = formatNames(enumFormats(fd))
+			devices = append(devices, info)
+		}
+		syscall.Close(fd)
+	}
+	return devices, nil
+}
+
+// queryDevice returns the device's identity and the capabilities of this node.
+func queryDevice(fd int, path string) (DeviceInfo, uint32, error) {
+	var c v4l2Capability
+	if err := ioctl(fd, queryCapIoctl, unsafe.Pointer(&c)); err != nil {
+		return DeviceInfo{}, 0, fmt.Errorf("%s is not a V4L2 device: %w", path, err)
+	}
+	caps := c.capabilities
+	if caps&capDeviceCaps != 0 {
+		caps = c.deviceCaps
+	}
+	info := DeviceInfo{Path: path, Name: cString(c.card[:]), Driver: cString(c.driver[:]), Bus: cString(c.busInfo[:])}
+	return info, caps, nil
+}
+
+func enumFormats(fd int) []uint32 {
+	var formats []uint32
+	for i := uint32(0); i < 64; i++ {
+		d := v4l2FmtDesc{index: i, typ: bufTypeVideoCapture}
+		if ioctl(fd, enumFmtIoctl, unsafe.Pointer(&d)) != nil {
+			break
+		}
+		formats = append(formats, d.pixelFormat)
+	}
+	return formats
+}
+
+func formatNames(formats []uint32) []string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = fourCCString(f)
+	}
+	return names
+}
+
+// pickFormat prefers formats that are JPEG already.
+func pickFormat(formats []uint32) (uint32, bool) {
+	for _, want := range []uint32{formatMJPEG, formatJPEG, formatYUYV} {
+		for _, f := range formats {
+			if f == want {
+				return f, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Capture grabs one frame from the device at path and returns it as JPEG.
+func Capture(path string, opts Options) (*Still, error) {
+	opts = opts.withDefaults()
+	deadline := time.Now().Add(opts.Timeout)
+
+	fd, err := syscall.Open(path, syscall.O_RDWR|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer syscall.Close(fd)
+
+	_, caps, err := queryDevice(fd, path)
+	if err != nil {
+		return nil, err
+	}
+	if caps&capVideoCapture == 0 || caps&capStreaming == 0 {
+		return nil, fmt.Errorf("%s is not a streaming video capture device", path)
+	}
+	formats := enumFormats(fd)
+	pixelFormat, ok := pickFormat(formats)
+	if !ok {
+		return nil, fmt.Errorf("%s offers no supported pixel format (has %v; need MJPG, JPEG or YUYV)", path, formatNames(formats))
+	}
+
+	f := v4l2Format{typ: bufTypeVideoCapture}
+	f.fmt.pix = v4l2PixFormat{width: uint32(opts.Width), height: uint32(opts.Height), pixelFormat: pixelFormat}
+	if err := ioctl(fd, setFmtIoctl, unsafe.Pointer(&f)); err != nil {
+		return nil, fmt.Errorf("set format on %s: %w", path, err)
+	}
+	pix := f.fmt.pix
+
+	req := v4l2RequestBuffers{count: captureBuffers, typ: bufTypeVideoCapture, memory: memoryMmap}
+	if err := ioctl(fd, reqBufsIoctl, unsafe.Pointer(&req)); err != nil {
+		return nil, fmt.Errorf("request buffers on %s: %w", path, err)
+	}
+	defer func() {
+		free := v4l2RequestBuffers{typ: bufTypeVideoCapture, memory: memoryMmap}
+		ioctl(fd, reqBufsIoctl, unsafe.Pointer(&free))
+	}()
+
+	buffers := make([][]byte, 0, req.count)
+	defer func() {
+		for _, b := range buffers {
+			syscall.Munmap(b)
+		}
+	}()
+	for i := uint32(0); i < req.count; i++ {
+		b := v4l2Buffer{index: i, typ: bufTypeVideoCapture, memory: memoryMmap}
+		if err := ioctl(fd, queryBufIoctl, unsafe.Pointer(&b)); err != nil {
+			return nil, fmt.Errorf("query buffer: %w", err)
+		}
+		mem, err := syscall.Mmap(fd, int64(b.offset()), int(b.length), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+		if err != nil {
+			return nil, fmt.Errorf("map buffer: %w", err)
+		}
+		buffers = append(buffers, mem)
+		if err := ioctl(fd, qBufIoctl, unsafe.Pointer(&b)); err != nil {
+			return nil, fmt.Errorf("queue buffer: %w", err)
+		}
+	}
+
+	bufType := int32(bufTypeVideoCapture)
+	if err := ioctl(fd, streamOnIoctl, unsafe.Pointer(&bufType)); err != nil {
+		return nil, fmt.Errorf("start streaming on %s: %w", path, err)
+	}
+	defer ioctl(fd, streamOffIoctl, unsafe.Pointer(&bufType))
+
+	for frame := 0; ; {
+		b := v4l2Buffer{typ: bufTypeVideoCapture, memory: memoryMmap}
+		err := ioctl(fd, dqBufIoctl, unsafe.Pointer(&b))
+		if err == syscall.EAGAIN {
+			if time.Now().After(deadline) {
+				return nil, fmt.Errorf("no frame from %s within %v", path, opts.Timeout)
+			}
+			time.Sleep(5 * time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("dequeue frame: %w", err)
+		}
+		if frame >= opts.Skip && b.bytesUsed > 0 && int(b.index) < len(buffers) {
+			data := buffers[b.index][:b.bytesUsed]
+			jpegData, err := encodeFrame(data, pix.pixelFormat, int(pix.width), int(pix.height), int(pix.bytesPerLine), opts.Quality)
+			if err != nil {
+				return nil, err
+			}
+			return &Still{JPEG: jpegData, Width: int(pix.width), Height: int(pix.height), Format: fourCCString(pix.pixelFormat)}, nil
+		}
+		frame++
+		if err := ioctl(fd, qBufIoctl, unsafe.Pointer(&b)); err != nil {
+			return nil, fmt.Errorf("requeue buffer: %w", err)
+		}
+	}
+}
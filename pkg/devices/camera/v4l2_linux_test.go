@@ -0,0 +1,65 @@
+//go:build linux
+
+package camera
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestABILayout(t *testing.T) {
+	if size := unsafe.Sizeof(v4l2Capability{}); size != 104 {
+		t.Errorf("sizeof(v4l2_capability) = %d, want 104", size)
+	}
+	if size := unsafe.Sizeof(v4l2FmtDesc{}); size != 64 {
+		t.Errorf("sizeof(v4l2_fmtdesc) = %d, want 64", size)
+	}
+	if size := unsafe.Sizeof(v4l2RequestBuffers{}); size != 20 {
+		t.Errorf("sizeof(v4l2_requestbuffers) = %d, want 20", size)
+	}
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		if size := unsafe.Sizeof(v4l2Format{}); size != 204 {
+			t.Errorf("sizeof(v4l2_format) = %d, want 204", size)
+		}
+		if size := unsafe.Sizeof(v4l2Buffer{}); size != 68 {
+			t.Errorf("sizeof(v4l2_buffer) = %d, want 68", size)
+		}
+		return
+	}
+	// Sizes and ioctl numbers for 64-bit kernels.
+	if size := unsafe.Sizeof(v4l2Format{}); size != 208 {
+		t.Errorf("sizeof(v4l2_format) = %d, want 208", size)
+	}
+	if size := unsafe.Sizeof(v4l2Buffer{}); size != 88 {
+		t.Errorf("sizeof(v4l2_buffer) = %d, want 88", size)
+	}
+	if queryCapIoctl != 0x80685600 || enumFmtIoctl != 0xC0405602 || setFmtIoctl != 0xC0D05605 {
+		t.Errorf("format ioctls = %#x %#x %#x", queryCapIoctl, enumFmtIoctl, setFmtIoctl)
+	}
+	if reqBufsIoctl != 0xC0145608 || queryBufIoctl != 0xC0585609 || qBufIoctl != 0xC058560F || dqBufIoctl != 0xC0585611 {
+		t.Errorf("buffer ioctls = %#x %#x %#x %#x", reqBufsIoctl, queryBufIoctl, qBufIoctl, dqBufIoctl)
+	}
+	if streamOnIoctl != 0x40045612 || streamOffIoctl != 0x40045613 {
+		t.Errorf("stream ioctls = %#x %#x", streamOnIoctl, streamOffIoctl)
+	}
+}
+
+func TestBufferOffset(t *testing.T) {
+	b := v4l2Buffer{}
+	*(*uint32)(unsafe.Pointer(&b.m)) = 0x1000
+	if b.offset() != 0x1000 {
+		t.Errorf("offset() = %#x", b.offset())
+	}
+}
+
+func TestPickFormat(t *testing.T) {
+	if f, ok := pickFormat([]uint32{formatYUYV, formatMJPEG}); !ok || f != formatMJPEG {
+		t.Errorf("pickFormat = %s, %v", fourCCString(f), ok)
+	}
+	if f, ok := pickFormat([]uint32{fourCC("NV12"), formatYUYV}); !ok || f != formatYUYV {
+		t.Errorf("pickFormat = %s, %v", fourCCString(f), ok)
+	}
+	if _, ok := pickFormat([]uint32{fourCC("NV12")}); ok {
+		t.Error("pickFormat accepted NV12 only")
+	}
+}
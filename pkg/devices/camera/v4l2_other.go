@@ -0,0 +1,13 @@
+//go:build !linux
+
+package camera
+
+// Devices lists the video capture devices under /dev.
+func Devices() ([]DeviceInfo, error) {
+	return nil, ErrUnsupported
+}
+
+// Capture grabs one frame from the device at path and returns it as JPEG.
+func Capture(path string, opts Options) (*Still, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,106 @@
+package devices
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+)
+
+// debouncer holds events for a device until it has been quiet for the
+// window, then delivers the last one. A burst that ends where it started,
+// such as a drive that is removed and re-attached or a Wi-Fi link that
+// drops and comes back, is dropped entirely.
+type debouncer struct {
+	window  time.Duration
+	deliver func(*events.DeviceEvent)
+
+	mu      sync.Mutex
+	pending map[string]*burst
+}
+
+type burst struct {
+	first *events.DeviceEvent
+	last  *events.DeviceEvent
+	count int
+	timer *time.Timer
+}
+
+func newDebouncer(window time.Duration, deliver func(*events.DeviceEvent)) *debouncer {
+	return &debouncer{window: window, deliver: deliver, pending: make(map[string]*burst)}
+}
+
+func debounceKey(ev *events.DeviceEvent) string {
+	id := ev.DeviceID
+	if id == "" {
+		id = ev.Vendor + "/" + ev.Product
+	}
+	return string(ev.Kind) + ":" + id
+}
+
+func (d *debouncer) add(ev *events.DeviceEvent) {
+	if d.window <= 0 {
+		d.deliver(ev)
+		return
+	}
+
+	key := debounceKey(ev)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if b, ok := d.pending[key]; ok {
+		b.last = ev
+		b.count++
+		b.timer.Reset(d.window)
+		return
+	}
+	d.pending[key] = &burst{
+		first: ev,
+		last:  ev,
+		count: 1,
+		timer: time.AfterFunc(d.window, func() { d.flush(key) }),
+	}
+}
+
+func (d *debouncer) flush(key string) {
+	d.mu.Lock()
+	b, ok := d.pending[key]
+	delete(d.pending, key)
+	d.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if b.count > 1 && cancelsOut(b.first.Action, b.last.Action) {
+		return
+	}
+	ev := b.last
+	if b.count > 1 {
+		ev.Raw = withRaw(ev.Raw, "DEBOUNCED_EVENTS", strconv.Itoa(b.count))
+	}
+	d.deliver(ev)
+}
+
+// stop drops pending events.
+func (d *debouncer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, b := range d.pending {
+		b.timer.Stop()
+		delete(d.pending, key)
+	}
+}
+
+func cancelsOut(first, last events.Action) bool {
+	return (first == events.ActionAdd && last == events.ActionRemove) ||
+		(first == events.ActionRemove && last == events.ActionAdd)
+}
+
+func withRaw(raw map[string]string, key, value string) map[string]string {
+	out := make(map[string]string, len(raw)+1)
+	for k, v := range raw {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
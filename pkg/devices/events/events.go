@@ -1,6 +1,9 @@
 package events
 
-import "context"
+import (
+	"context"
+	"strings"
+)
 
 type EventSource interface {
 	Kind() Kind
@@ -22,6 +25,9 @@ const (
 	KindUSB       Kind = "usb"
 	KindBluetooth Kind = "bluetooth"
 	KindPCI       Kind = "pci"
+	KindBlock     Kind = "block"
+	KindNetwork   Kind = "network"
+	KindGPIO      Kind = "gpio"
 	KindGeneric   Kind = "generic"
 )
 
@@ -39,14 +45,17 @@ type DeviceEvent struct {
 func (e *DeviceEvent) FormatMessage() string {
 	actionEmoji := "🔌"
 	actionText := "Connected"
-	if e.Action == ActionRemove {
-		actionEmoji = "🔌"
+	switch e.Action {
+	case ActionRemove:
 		actionText = "Disconnected"
+	case ActionChange:
+		actionEmoji = "🔄"
+		actionText = "Changed"
 	}
 
 	msg := actionEmoji + " Device " + actionText + "\n\n"
 	msg += "Type: " + string(e.Kind) + "\n"
-	msg += "Device: " + e.Vendor + " " + e.Product + "\n"
+	msg += "Device: " + strings.TrimSpace(e.Vendor+" "+e.Product) + "\n"
 	if e.Capabilities != "" {
 		msg += "Capabilities: " + e.Capabilities + "\n"
 	}
@@ -0,0 +1,319 @@
+// Package gpio drives GPIO lines through the Linux GPIO character device
+// (/dev/gpiochipN, uAPI v2).
+package gpio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrUnsupported is returned on platforms without the GPIO character device.
+var ErrUnsupported = errors.New("GPIO is only supported on Linux")
+
+// LineConfig configures one line of a request.
+type LineConfig struct {
+	Offset     int
+	Output     bool
+	Value      int    // initial level of an output
+	Edge       string // input edge detection: "rising", "falling", "both" or "" for none
+	Bias       string // "pull_up", "pull_down", "disable" or "" to leave as is
+	DebounceMs int
+}
+
+// ChipInfo describes a GPIO chip.
+type ChipInfo struct {
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Lines int    `json:"lines"`
+}
+
+// LineInfo describes a line of a chip.
+type LineInfo struct {
+	Offset    int    `json:"offset"`
+	Name      string `json:"name,omitempty"`
+	Consumer  string `json:"consumer,omitempty"`
+	Used      bool   `json:"used"`
+	Direction string `json:"direction"` // "input" or "output"
+	ActiveLow bool   `json:"active_low,omitempty"`
+	Bias      string `json:"bias,omitempty"`
+	Edge      string `json:"edge,omitempty"`
+}
+
+// EdgeEvent is an edge detected on a requested line.
+type EdgeEvent struct {
+	Offset      int
+	Rising      bool
+	TimestampNs uint64
+	Seqno       uint32
+}
+
+// EdgeName returns "rising" or "falling".
+func (e EdgeEvent) EdgeName() string {
+	if e.Rising {
+		return "rising"
+	}
+	return "falling"
+}
+
+var chipPattern = regexp.MustCompile(`^(?:/dev/)?(?:gpiochip)?(\d+)$`)
+
+// ChipPath returns the device path for a chip given as "0", "gpiochip0" or
+// "/dev/gpiochip0". An empty chip means gpiochip0.
+func ChipPath(chip string) (string, error) {
+	if chip == "" {
+		return "/dev/gpiochip0", nil
+	}
+	m := chipPattern.FindStringSubmatch(chip)
+	if m == nil {
+		return "", fmt.Errorf("invalid GPIO chip %q (use a number or gpiochipN)", chip)
+	}
+	return "/dev/gpiochip" + m[1], nil
+}
+
+// ChipName returns the device name of a chip path, e.g. "gpiochip0".
+func ChipName(path string) string {
+	return filepath.Base(path)
+}
+
+// Line request flags and attribute IDs from <linux/gpio.h>.
+const (
+	maxLines    = 64
+	maxAttrs    = 10
+	maxNameSize = 32
+
+	flagUsed         = 1 << 0
+	flagActiveLow    = 1 << 1
+	flagInput        = 1 << 2
+	flagOutput       = 1 << 3
+	flagEdgeRising   = 1 << 4
+	flagEdgeFalling  = 1 << 5
+	flagBiasPullUp   = 1 << 8
+	flagBiasPullDown = 1 << 9
+	flagBiasDisabled = 1 << 10
+
+	attrIDFlags        = 1
+	attrIDOutputValues = 2
+	attrIDDebounce     = 3
+
+	eventRisingEdge  = 1
+	eventFallingEdge = 2
+
+	// EdgeEventSize is the size of struct gpio_v2_line_event.
+	EdgeEventSize = 48
+)
+
+type lineAttribute struct {
+	ID      uint32
+	Padding uint32
+	Value   uint64 // flags, output values or debounce period in µs
+}
+
+type lineConfigAttribute struct {
+	Attr lineAttribute
+	Mask uint64
+}
+
+type lineConfig struct {
+	Flags    uint64
+	NumAttrs uint32
+	Padding  [5]uint32
+	Attrs    [maxAttrs]lineConfigAttribute
+}
+
+type lineRequest struct {
+	Offsets         [maxLines]uint32
+	Consumer        [maxNameSize]byte
+	Config          lineConfig
+	NumLines        uint32
+	EventBufferSize uint32
+	Padding         [5]uint32
+	Fd              int32
+}
+
+type lineInfo struct {
+	Name     [maxNameSize]byte
+	Consumer [maxNameSize]byte
+	Offset   uint32
+	NumAttrs uint32
+	Flags    uint64
+	Attrs    [maxAttrs]lineAttribute
+	Padding  [4]uint32
+}
+
+type chipInfo struct {
+	Name  [maxNameSize]byte
+	Label [maxNameSize]byte
+	Lines uint32
+}
+
+type lineValues struct {
+	Bits uint64
+	Mask uint64
+}
+
+func lineFlags(l LineConfig) (uint64, error) {
+	var flags uint64
+	if l.Output {
+		flags = flagOutput
+		if l.Edge != "" {
+			return 0, fmt.Errorf("line %d: edge detection needs an input", l.Offset)
+		}
+	} else {
+		flags = flagInput
+	}
+	switch strings.ToLower(l.Edge) {
+	case "":
+	case "both":
+		flags |= flagEdgeRising | flagEdgeFalling
+	case "rising":
+		flags |= flagEdgeRising
+	case "falling":
+		flags |= flagEdgeFalling
+	default:
+		return 0, fmt.Errorf("line %d: unknown edge %q (use rising, falling or both)", l.Offset, l.Edge)
+	}
+	switch strings.ToLower(l.Bias) {
+	case "":
+	case "pull_up":
+		flags |= flagBiasPullUp
+	case "pull_down":
+		flags |= flagBiasPullDown
+	case "disable", "off":
+		flags |= flagBiasDisabled
+	default:
+		return 0, fmt.Errorf("line %d: unknown bias %q (use pull_up, pull_down or disable)", l.Offset, l.Bias)
+	}
+	return flags, nil
+}
+
+// buildRequest fills a line request. Lines whose flags differ from the
+// first line's, output levels and debounce periods become per-line
+// attributes.
+func buildRequest(consumer string, lines []LineConfig) (*lineRequest, error) {
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no lines requested")
+	}
+	if len(lines) > maxLines {
+		return nil, fmt.Errorf("at most %d lines per request", maxLines)
+	}
+	req := &lineRequest{NumLines: uint32(len(lines))}
+	copy(req.Consumer[:maxNameSize-1], consumer)
+
+	addAttr := func(id uint32, value uint64, bit int) error {
+		for i := uint32(0); i < req.Config.NumAttrs; i++ {
+			a := &req.Config.Attrs[i]
+			if a.Attr.ID == id && (id == attrIDOutputValues || a.Attr.Value == value) {
+				a.Attr.Value |= value
+				a.Mask |= 1 << bit
+				return nil
+			}
+		}
+		if req.Config.NumAttrs == maxAttrs {
+			return fmt.Errorf("too many distinct line settings in one request")
+		}
+		req.Config.Attrs[req.Config.NumAttrs] = lineConfigAttribute{
+			Attr: lineAttribute{ID: id, Value: value},
+			Mask: 1 << bit,
+		}
+		req.Config.NumAttrs++
+		return nil
+	}
+
+	for i, l := range lines {
+		if l.Offset < 0 {
+			return nil, fmt.Errorf("line %d: invalid offset", l.Offset)
+		}
+		req.Offsets[i] = uint32(l.Offset)
+		flags, err := lineFlags(l)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			req.Config.Flags = flags
+		} else if flags != req.Config.Flags {
+			if err := addAttr(attrIDFlags, flags, i); err != nil {
+				return nil, err
+			}
+		}
+		if l.Output {
+			var bits uint64
+			if l.Value != 0 {
+				bits = 1 << i
+			}
+			if err := addAttr(attrIDOutputValues, bits, i); err != nil {
+				return nil, err
+			}
+		}
+		if l.DebounceMs > 0 {
+			if err := addAttr(attrIDDebounce, uint64(l.DebounceMs)*1000, i); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return req, nil
+}
+
+// ParseEdgeEvents decodes the gpio_v2_line_event records in buf. A
+// trailing partial record is ignored.
+func ParseEdgeEvents(buf []byte) []EdgeEvent {
+	var evs []EdgeEvent
+	for off := 0; off+EdgeEventSize <= len(buf); off += EdgeEventSize {
+		rec := buf[off : off+EdgeEventSize]
+		id := binary.NativeEndian.Uint32(rec[8:12])
+		if id != eventRisingEdge && id != eventFallingEdge {
+			continue
+		}
+		evs = append(evs, EdgeEvent{
+			TimestampNs: binary.NativeEndian.Uint64(rec[0:8]),
+			Rising:      id == eventRisingEdge,
+			Offset:      int(binary.NativeEndian.Uint32(rec[12:16])),
+			Seqno:       binary.NativeEndian.Uint32(rec[16:20]),
+		})
+	}
+	return evs
+}
+
+func describeLine(info *lineInfo) LineInfo {
+	l := LineInfo{
+		Offset:    int(info.Offset),
+		Name:      cString(info.Name[:]),
+		Consumer:  cString(info.Consumer[:]),
+		Used:      info.Flags&flagUsed != 0,
+		Direction: "input",
+		ActiveLow: info.Flags&flagActiveLow != 0,
+	}
+	if info.Flags&flagOutput != 0 {
+		l.Direction = "output"
+	}
+	switch {
+	case info.Flags&flagBiasPullUp != 0:
+		l.Bias = "pull_up"
+	case info.Flags&flagBiasPullDown != 0:
+		l.Bias = "pull_down"
+	case info.Flags&flagBiasDisabled != 0:
+		l.Bias = "disable"
+	}
+	switch info.Flags & (flagEdgeRising | flagEdgeFalling) {
+	case flagEdgeRising | flagEdgeFalling:
+		l.Edge = "both"
+	case flagEdgeRising:
+		l.Edge = "rising"
+	case flagEdgeFalling:
+		l.Edge = "falling"
+	}
+	return l
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
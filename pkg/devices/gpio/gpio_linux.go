@@ -0,0 +1,178 @@
+//go:build linux
+
+package gpio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Ioctl numbers, _IOR/_IOWR(0xB4, nr, size) from <linux/gpio.h>.
+var (
+	getChipInfoIoctl   = ioctlNumber(2, 0x01, unsafe.Sizeof(chipInfo{}))
+	getLineInfoIoctl   = ioctlNumber(3, 0x05, unsafe.Sizeof(lineInfo{}))
+	getLineIoctl       = ioctlNumber(3, 0x07, unsafe.Sizeof(lineRequest{}))
+	lineGetValuesIoctl = ioctlNumber(3, 0x0E, unsafe.Sizeof(lineValues{}))
+	lineSetValuesIoctl = ioctlNumber(3, 0x0F, unsafe.Sizeof(lineValues{}))
+)
+
+func ioctlNumber(dir, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | 0xB4<<8 | nr
+}
+
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	sc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := sc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Chips lists the GPIO chips under /dev.
+func Chips() ([]ChipInfo, error) {
+	paths, err := filepath.Glob("/dev/gpiochip*")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var chips []ChipInfo
+	for _, p := range paths {
+		info, err := Chip(p)
+		if err != nil {
+			continue
+		}
+		chips = append(chips, info)
+	}
+	return chips, nil
+}
+
+// Chip returns information about the chip at path.
+func Chip(path string) (ChipInfo, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return ChipInfo{}, err
+	}
+	defer f.Close()
+	var info chipInfo
+	if err := ioctl(f, getChipInfoIoctl, unsafe.Pointer(&info)); err != nil {
+		return ChipInfo{}, fmt.Errorf("%s: chip info: %w", path, err)
+	}
+	return ChipInfo{
+		Path:  path,
+		Name:  cString(info.Name[:]),
+		Label: cString(info.Label[:]),
+		Lines: int(info.Lines),
+	}, nil
+}
+
+// Lines describes every line of the chip at path.
+func Lines(path string) ([]LineInfo, error) {
+	chip, err := Chip(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	lines := make([]LineInfo, 0, chip.Lines)
+	for i := 0; i < chip.Lines; i++ {
+		info := lineInfo{Offset: uint32(i)}
+		if err := ioctl(f, getLineInfoIoctl, unsafe.Pointer(&info)); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, i, err)
+		}
+		lines = append(lines, describeLine(&info))
+	}
+	return lines, nil
+}
+
+// Request holds lines requested from a chip. Reading it returns edge
+// events for lines requested with edge detection.
+type Request struct {
+	f     *os.File
+	lines []LineConfig
+}
+
+// RequestLines requests lines from the chip at path. The lines stay
+// claimed until the request is closed.
+func RequestLines(path, consumer string, lines []LineConfig) (*Request, error) {
+	req, err := buildRequest(consumer, lines)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := ioctl(f, getLineIoctl, unsafe.Pointer(req)); err != nil {
+		return nil, fmt.Errorf("line request: %w", err)
+	}
+
+	// Non-blocking so Close and read deadlines interrupt a pending Read.
+	if err := syscall.SetNonblock(int(req.Fd), true); err != nil {
+		syscall.Close(int(req.Fd))
+		return nil, err
+	}
+	return &Request{f: os.NewFile(uintptr(req.Fd), ChipName(path)+"-lines"), lines: lines}, nil
+}
+
+// Values returns the level of each requested line, in request order.
+func (r *Request) Values() ([]int, error) {
+	vals := lineValues{Mask: 1<<len(r.lines) - 1}
+	if err := ioctl(r.f, lineGetValuesIoctl, unsafe.Pointer(&vals)); err != nil {
+		return nil, fmt.Errorf("get values: %w", err)
+	}
+	out := make([]int, len(r.lines))
+	for i := range out {
+		out[i] = int(vals.Bits >> i & 1)
+	}
+	return out, nil
+}
+
+// SetValues drives the requested output lines, in request order.
+func (r *Request) SetValues(values []int) error {
+	if len(values) != len(r.lines) {
+		return fmt.Errorf("got %d values for %d lines", len(values), len(r.lines))
+	}
+	vals := lineValues{Mask: 1<<len(r.lines) - 1}
+	for i, v := range values {
+		if v != 0 {
+			vals.Bits |= 1 << i
+		}
+	}
+	if err := ioctl(r.f, lineSetValuesIoctl, unsafe.Pointer(&vals)); err != nil {
+		return fmt.Errorf("set values: %w", err)
+	}
+	return nil
+}
+
+// Read reads raw gpio_v2_line_event records; see ParseEdgeEvents.
+func (r *Request) Read(p []byte) (int, error) {
+	return r.f.Read(p)
+}
+
+// SetReadDeadline bounds the next Read.
+func (r *Request) SetReadDeadline(t time.Time) error {
+	return r.f.SetReadDeadline(t)
+}
+
+// Close releases the lines.
+func (r *Request) Close() error {
+	return r.f.Close()
+}
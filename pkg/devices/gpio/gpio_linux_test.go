@@ -0,0 +1,113 @@
+//go:build linux
+
+package gpio
+
+import (
+	"encoding/binary"
+	"testing"
+	"unsafe"
+)
+
+func TestABILayout(t *testing.T) {
+	// Sizes from <linux/gpio.h>; the ioctl numbers encode them.
+	if size := unsafe.Sizeof(lineRequest{}); size != 592 {
+		t.Errorf("sizeof(gpio_v2_line_request) = %d, want 592", size)
+	}
+	if size := unsafe.Sizeof(lineConfig{}); size != 272 {
+		t.Errorf("sizeof(gpio_v2_line_config) = %d, want 272", size)
+	}
+	if size := unsafe.Sizeof(lineInfo{}); size != 256 {
+		t.Errorf("sizeof(gpio_v2_line_info) = %d, want 256", size)
+	}
+	if getLineIoctl != 0xC250B407 || getLineInfoIoctl != 0xC100B405 || getChipInfoIoctl != 0x8044B401 {
+		t.Errorf("ioctls = %#x %#x %#x", getLineIoctl, getLineInfoIoctl, getChipInfoIoctl)
+	}
+	if lineGetValuesIoctl != 0xC010B40E || lineSetValuesIoctl != 0xC010B40F {
+		t.Errorf("value ioctls = %#x %#x", lineGetValuesIoctl, lineSetValuesIoctl)
+	}
+}
+
+func TestBuildRequest(t *testing.T) {
+	req, err := buildRequest("picoclaw", []LineConfig{
+		{Offset: 17, Edge: "both"},
+		{Offset: 27, Edge: "falling", Bias: "pull_up", DebounceMs: 20},
+		{Offset: 22, Edge: "both", DebounceMs: 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.NumLines != 3 || req.Offsets[1] != 27 || cString(req.Consumer[:]) != "picoclaw" {
+		t.Errorf("lines = %d %v", req.NumLines, req.Offsets[:3])
+	}
+	if req.Config.Flags != flagInput|flagEdgeRising|flagEdgeFalling {
+		t.Errorf("default flags = %#x", req.Config.Flags)
+	}
+	if req.Config.NumAttrs != 2 {
+		t.Fatalf("NumAttrs = %d, want 2", req.Config.NumAttrs)
+	}
+	flags, debounce := req.Config.Attrs[0], req.Config.Attrs[1]
+	if flags.Attr.ID != attrIDFlags || flags.Mask != 0b010 ||
+		flags.Attr.Value != flagInput|flagEdgeFalling|flagBiasPullUp {
+		t.Errorf("flags attribute = %+v", flags)
+	}
+	if debounce.Attr.ID != attrIDDebounce || debounce.Mask != 0b110 || debounce.Attr.Value != 20000 {
+		t.Errorf("debounce attribute = %+v", debounce)
+	}
+
+	if _, err := buildRequest("", []LineConfig{{Offset: 1, Edge: "sideways"}}); err == nil {
+		t.Error("expected an error for an unknown edge")
+	}
+	if _, err := buildRequest("", []LineConfig{{Offset: 1, Output: true, Edge: "rising"}}); err == nil {
+		t.Error("expected an error for edge detection on an output")
+	}
+}
+
+func TestBuildRequest_Outputs(t *testing.T) {
+	req, err := buildRequest("", []LineConfig{
+		{Offset: 5, Output: true, Value: 1},
+		{Offset: 6, Output: true},
+		{Offset: 7, Output: true, Value: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Config.Flags != flagOutput || req.Config.NumAttrs != 1 {
+		t.Fatalf("config = %+v", req.Config)
+	}
+	if a := req.Config.Attrs[0]; a.Attr.ID != attrIDOutputValues || a.Mask != 0b111 || a.Attr.Value != 0b101 {
+		t.Errorf("output values attribute = %+v", a)
+	}
+}
+
+func TestParseEdgeEvents(t *testing.T) {
+	buf := make([]byte, 2*EdgeEventSize+10)
+	binary.NativeEndian.PutUint64(buf[0:8], 1000)
+	binary.NativeEndian.PutUint32(buf[8:12], eventRisingEdge)
+	binary.NativeEndian.PutUint32(buf[12:16], 17)
+	binary.NativeEndian.PutUint32(buf[16:20], 1)
+	rec := buf[EdgeEventSize:]
+	binary.NativeEndian.PutUint32(rec[8:12], eventFallingEdge)
+	binary.NativeEndian.PutUint32(rec[12:16], 27)
+
+	evs := ParseEdgeEvents(buf)
+	if len(evs) != 2 {
+		t.Fatalf("got %d events, want 2", len(evs))
+	}
+	if evs[0] != (EdgeEvent{Offset: 17, Rising: true, TimestampNs: 1000, Seqno: 1}) {
+		t.Errorf("first edge = %+v", evs[0])
+	}
+	if evs[1].EdgeName() != "falling" || evs[1].Offset != 27 {
+		t.Errorf("second edge = %+v", evs[1])
+	}
+}
+
+func TestChipPath(t *testing.T) {
+	for in, want := range map[string]string{"": "/dev/gpiochip0", "2": "/dev/gpiochip2", "gpiochip1": "/dev/gpiochip1", "/dev/gpiochip3": "/dev/gpiochip3"} {
+		if got, err := ChipPath(in); err != nil || got != want {
+			t.Errorf("ChipPath(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ChipPath("../mem"); err == nil {
+		t.Error("ChipPath accepted a non-chip path")
+	}
+}
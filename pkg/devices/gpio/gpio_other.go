@@ -0,0 +1,34 @@
+//go:build !linux
+
+package gpio
+
+import "time"
+
+// Chips lists the GPIO chips under /dev.
+func Chips() ([]ChipInfo, error) {
+	return nil, ErrUnsupported
+}
+
+// Chip returns information about the chip at path.
+func Chip(path string) (ChipInfo, error) {
+	return ChipInfo{}, ErrUnsupported
+}
+
+// Lines describes every line of the chip at path.
+func Lines(path string) ([]LineInfo, error) {
+	return nil, ErrUnsupported
+}
+
+// Request holds lines requested from a chip.
+type Request struct{}
+
+// RequestLines requests lines from the chip at path.
+func RequestLines(path, consumer string, lines []LineConfig) (*Request, error) {
+	return nil, ErrUnsupported
+}
+
+func (r *Request) Values() ([]int, error)            { return nil, ErrUnsupported }
+func (r *Request) SetValues(values []int) error      { return ErrUnsupported }
+func (r *Request) Read(p []byte) (int, error)        { return 0, ErrUnsupported }
+func (r *Request) SetReadDeadline(t time.Time) error { return ErrUnsupported }
+func (r *Request) Close() error                      { return nil }
@@ -0,0 +1,156 @@
+package profiles
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	i2cSlave = 0x0703 // I2C_SLAVE
+
+	spiIocWrMode       = 0x40016B01 // _IOW('k', 1, __u8)
+	spiIocWrMaxSpeedHz = 0x40046B04 // _IOW('k', 4, __u32)
+	spiIocMessage1     = 0x40206B00 // _IOW('k', 0, struct spi_ioc_transfer)
+)
+
+// spiTransfer matches struct spi_ioc_transfer.
+type spiTransfer struct {
+	txBuf       uint64
+	rxBuf       uint64
+	length      uint32
+	speedHz     uint32
+	delayUsecs  uint16
+	bitsPerWord uint8
+	csChange    uint8
+	txNbits     uint8
+	rxNbits     uint8
+	wordDelay   uint8
+	pad         uint8
+}
+
+// I2CBus talks to one device on an I2C adapter.
+type I2CBus struct {
+	fd int
+}
+
+// OpenI2C opens the adapter at path (e.g. /dev/i2c-1) for the device at addr.
+func OpenI2C(path string, addr int) (*I2CBus, error) {
+	fd, err := syscall.Open(path, syscall.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), i2cSlave, uintptr(addr)); errno != 0 {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("select address 0x%02x on %s: %w", addr, path, errno)
+	}
+	return &I2CBus{fd: fd}, nil
+}
+
+func (b *I2CBus) Read(reg, n int) ([]byte, error) {
+	if reg >= 0 {
+		if err := b.Write([]byte{byte(reg)}); err != nil {
+			return nil, err
+		}
+	}
+	buf := make([]byte, n)
+	got, err := syscall.Read(b.fd, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:got], nil
+}
+
+func (b *I2CBus) Write(data []byte) error {
+	n, err := syscall.Write(b.fd, data)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("short write: %d of %d bytes", n, len(data))
+	}
+	return nil
+}
+
+func (b *I2CBus) Close() error {
+	return syscall.Close(b.fd)
+}
+
+// SPIBus talks to the device behind one spidev chip select.
+type SPIBus struct {
+	fd       int
+	speed    uint32
+	settings SPISettings
+}
+
+// OpenSPI opens the spidev device at path with the profile's SPI settings.
+func OpenSPI(path string, s *SPISettings) (*SPIBus, error) {
+	if s == nil {
+		return nil, fmt.Errorf("profile has no SPI settings")
+	}
+	fd, err := syscall.Open(path, syscall.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	mode := uint8(s.Mode)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), spiIocWrMode, uintptr(unsafe.Pointer(&mode))); errno != 0 {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("set SPI mode %d: %w", mode, errno)
+	}
+	speed := uint32(s.SpeedHz)
+	if speed == 0 {
+		speed = 1000000
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), spiIocWrMaxSpeedHz, uintptr(unsafe.Pointer(&speed))); errno != 0 {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("set SPI speed %d Hz: %w", speed, errno)
+	}
+	return &SPIBus{fd: fd, speed: speed, settings: *s}, nil
+}
+
+// Read sends the register with the read flag set and clocks out n bytes.
+func (b *SPIBus) Read(reg, n int) ([]byte, error) {
+	var tx []byte
+	if reg >= 0 {
+		tx = append(tx, byte(reg|b.settings.ReadFlag.Value))
+	}
+	skip := len(tx)
+	tx = append(tx, make([]byte, n)...)
+	rx, err := b.transfer(tx)
+	if err != nil {
+		return nil, err
+	}
+	return rx[skip:], nil
+}
+
+// Write sends data, applying the write mask to the register byte.
+func (b *SPIBus) Write(data []byte) error {
+	tx := append([]byte(nil), data...)
+	if b.settings.WriteMask != nil && len(tx) > 0 {
+		tx[0] &= byte(b.settings.WriteMask.Value)
+	}
+	_, err := b.transfer(tx)
+	return err
+}
+
+func (b *SPIBus) Close() error {
+	return syscall.Close(b.fd)
+}
+
+func (b *SPIBus) transfer(tx []byte) ([]byte, error) {
+	rx := make([]byte, len(tx))
+	if len(tx) == 0 {
+		return rx, nil
+	}
+	xfer := spiTransfer{
+		txBuf:       uint64(uintptr(unsafe.Pointer(&tx[0]))),
+		rxBuf:       uint64(uintptr(unsafe.Pointer(&rx[0]))),
+		length:      uint32(len(tx)),
+		speedHz:     b.speed,
+		bitsPerWord: 8,
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(b.fd), spiIocMessage1, uintptr(unsafe.Pointer(&xfer))); errno != 0 {
+		return nil, fmt.Errorf("SPI transfer: %w", errno)
+	}
+	return rx, nil
+}
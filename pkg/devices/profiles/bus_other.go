@@ -0,0 +1,27 @@
+//go:build !linux
+
+package profiles
+
+// I2CBus talks to one device on an I2C adapter.
+type I2CBus struct{}
+
+// OpenI2C opens the adapter at path (e.g. /dev/i2c-1) for the device at addr.
+func OpenI2C(path string, addr int) (*I2CBus, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *I2CBus) Read(reg, n int) ([]byte, error) { return nil, ErrUnsupported }
+func (b *I2CBus) Write(data []byte) error         { return ErrUnsupported }
+func (b *I2CBus) Close() error                    { return nil }
+
+// SPIBus talks to the device behind one spidev chip select.
+type SPIBus struct{}
+
+// OpenSPI opens the spidev device at path with the profile's SPI settings.
+func OpenSPI(path string, s *SPISettings) (*SPIBus, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *SPIBus) Read(reg, n int) ([]byte, error) { return nil, ErrUnsupported }
+func (b *SPIBus) Write(data []byte) error         { return ErrUnsupported }
+func (b *SPIBus) Close() error                    { return nil }
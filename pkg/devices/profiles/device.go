@@ -0,0 +1,220 @@
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Bus is a connection to one device.
+type Bus interface {
+	// Read reads n bytes starting at register reg, or n bytes without
+	// selecting a register when reg is negative.
+	Read(reg, n int) ([]byte, error)
+	// Write sends data; register writes start with the register.
+	Write(data []byte) error
+}
+
+// Reading is one calibrated quantity.
+type Reading struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
+// ErrUnsupported is returned when opening a bus on platforms other than Linux.
+var ErrUnsupported = errors.New("I2C and SPI buses are only supported on Linux")
+
+const defaultPollTimeout = time.Second
+
+// sleep is replaced in tests.
+var sleep = time.Sleep
+
+// Read runs the init steps, reads the blocks and returns the named
+// quantities, or all of them when names is empty. params override the
+// profile's parameter defaults.
+func (p *Profile) Read(bus Bus, params map[string]float64, names []string) ([]Reading, error) {
+	quantities := p.Quantities
+	if len(names) > 0 {
+		quantities = nil
+		for _, name := range names {
+			q := p.Quantity(name)
+			if q == nil {
+				return nil, fmt.Errorf("%s has no quantity %q (has: %s)", p.Name, name, strings.Join(p.quantityNames(), ", "))
+			}
+			quantities = append(quantities, *q)
+		}
+	}
+	if len(quantities) == 0 {
+		return nil, fmt.Errorf("%s has no quantities to read", p.Name)
+	}
+
+	if err := runSteps(bus, p.Init, nil); err != nil {
+		return nil, fmt.Errorf("init: %w", err)
+	}
+	blocks := make(map[string][]byte, len(p.Blocks))
+	for _, b := range p.Blocks {
+		reg := -1
+		if b.Register != nil {
+			reg = b.Register.Value
+		}
+		data, err := bus.Read(reg, b.Length)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", b.Name, err)
+		}
+		if len(data) < b.Length {
+			return nil, fmt.Errorf("read %s: got %d of %d bytes", b.Name, len(data), b.Length)
+		}
+		blocks[b.Name] = data
+	}
+
+	env := make(map[string]float64)
+	for name, v := range p.Params {
+		env[name] = v
+	}
+	for name, v := range params {
+		if _, ok := p.Params[name]; !ok {
+			return nil, fmt.Errorf("%s has no parameter %q", p.Name, name)
+		}
+		env[name] = v
+	}
+	for _, f := range p.Fields {
+		env[f.Name] = decodeField(f, blocks[f.Block])
+	}
+	for _, a := range p.derived {
+		v, err := a.expr.Eval(env)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", a.name, err)
+		}
+		env[a.name] = v
+	}
+
+	readings := make([]Reading, 0, len(quantities))
+	for _, q := range quantities {
+		v, err := q.expr.Eval(env)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", q.Name, err)
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("%s: formula gave %v; check wiring and the profile's calibration reads", q.Name, v)
+		}
+		readings = append(readings, Reading{Name: q.Name, Value: v, Unit: q.Unit})
+	}
+	return readings, nil
+}
+
+// Run runs a named action with its byte parameters.
+func (p *Profile) Run(bus Bus, action string, args map[string]int) error {
+	a := p.Action(action)
+	if a == nil {
+		return fmt.Errorf("%s has no action %q (has: %s)", p.Name, action, strings.Join(p.actionNames(), ", "))
+	}
+	for _, name := range a.Params {
+		v, ok := args[name]
+		if !ok {
+			return fmt.Errorf("action %s needs parameter %q", a.Name, name)
+		}
+		if v < 0 || v > 255 {
+			return fmt.Errorf("parameter %s must be 0-255", name)
+		}
+	}
+	return runSteps(bus, a.Steps, args)
+}
+
+// Detect reports whether the device's ID register holds an expected value.
+func (p *Profile) Detect(bus Bus) (bool, error) {
+	if p.Identify == nil {
+		return false, fmt.Errorf("%s has no identify register", p.Name)
+	}
+	data, err := bus.Read(p.Identify.Register.Value, 1)
+	if err != nil {
+		return false, err
+	}
+	for _, want := range p.Identify.Expect {
+		if len(data) > 0 && int(data[0]) == want.Value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p *Profile) quantityNames() []string {
+	names := make([]string, len(p.Quantities))
+	for i, q := range p.Quantities {
+		names[i] = q.Name
+	}
+	return names
+}
+
+func (p *Profile) actionNames() []string {
+	names := make([]string, len(p.Actions))
+	for i, a := range p.Actions {
+		names[i] = a.Name
+	}
+	return names
+}
+
+func runSteps(bus Bus, steps []Step, args map[string]int) error {
+	for i, s := range steps {
+		if len(s.Write) > 0 || s.Fill > 0 {
+			data := make([]byte, 0, len(s.Write)+s.Fill)
+			for _, b := range s.Write {
+				data = append(data, resolveByte(b, args))
+			}
+			fill := resolveByte(s.FillByte, args)
+			for j := 0; j < s.Fill; j++ {
+				data = append(data, fill)
+			}
+			if err := bus.Write(data); err != nil {
+				return fmt.Errorf("step %d: write: %w", i+1, err)
+			}
+		}
+		if s.DelayMs > 0 {
+			sleep(time.Duration(s.DelayMs) * time.Millisecond)
+		}
+		if s.Poll != nil {
+			if err := poll(bus, s.Poll); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func resolveByte(b Byte, args map[string]int) byte {
+	if b.Param != "" {
+		return byte(args[b.Param])
+	}
+	return byte(b.Value)
+}
+
+// poll reads the status byte until it settles or the timeout passes.
+func poll(bus Bus, p *Poll) error {
+	reg := -1
+	if p.Register != nil {
+		reg = p.Register.Value
+	}
+	timeout := defaultPollTimeout
+	if p.TimeoutMs > 0 {
+		timeout = time.Duration(p.TimeoutMs) * time.Millisecond
+	}
+	interval := 2 * time.Millisecond
+	for waited := time.Duration(0); ; waited += interval {
+		data, err := bus.Read(reg, 1)
+		if err != nil {
+			return fmt.Errorf("poll: %w", err)
+		}
+		if len(data) == 0 {
+			return fmt.Errorf("poll: empty status read")
+		}
+		if int(data[0])&p.Mask.Value == p.Value.Value {
+			return nil
+		}
+		if waited >= timeout {
+			return fmt.Errorf("device still busy after %v (status 0x%02x)", timeout, data[0])
+		}
+		sleep(interval)
+	}
+}
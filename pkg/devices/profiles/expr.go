@@ -0,0 +1,391 @@
+package profiles
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a compiled conversion formula. Formulas use float64 arithmetic
+// with C-like operators: + - * / %, the bitwise & | << >> (on integer
+// parts), comparisons and && || ! (1 for true, 0 for false), and the
+// functions listed in exprFuncs.
+type Expr struct {
+	src  string
+	eval func(env map[string]float64) (float64, error)
+	vars []string
+}
+
+// Vars returns the variable names the formula refers to.
+func (e *Expr) Vars() []string {
+	return e.vars
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the formula with variables from env.
+func (e *Expr) Eval(env map[string]float64) (float64, error) {
+	return e.eval(env)
+}
+
+type exprFunc struct {
+	args int // -1 for two or more
+	fn   func(a []float64) float64
+}
+
+var exprFuncs = map[string]exprFunc{
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10": {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min": {-1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	}},
+	"max": {-1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	}},
+	"clamp": {3, func(a []float64) float64 { return math.Max(a[1], math.Min(a[2], a[0])) }},
+	// if(cond, a, b) is a if cond is non-zero, else b.
+	"if": {3, func(a []float64) float64 {
+		if a[0] != 0 {
+			return a[1]
+		}
+		return a[2]
+	}},
+	// signed(x, bits) reads the low bits of x as a two's complement number.
+	"signed": {2, func(a []float64) float64 {
+		bits := uint(a[1])
+		v := int64(a[0]) & (1<<bits - 1)
+		if v&(1<<(bits-1)) != 0 {
+			v -= 1 << bits
+		}
+		return float64(v)
+	}},
+}
+
+type exprNode func(env map[string]float64) (float64, error)
+
+// ParseExpr compiles a formula.
+func ParseExpr(src string) (*Expr, error) {
+	p := &exprParser{src: src, seen: make(map[string]bool)}
+	p.next()
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("formula %q: %w", src, err)
+	}
+	if p.tok != "" {
+		return nil, fmt.Errorf("formula %q: unexpected %q", src, p.tok)
+	}
+	return &Expr{src: src, eval: node, vars: p.vars}, nil
+}
+
+type exprParser struct {
+	src  string
+	pos  int
+	tok  string
+	vars []string
+	seen map[string]bool
+}
+
+// next advances to the next token; tok is "" at the end.
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		p.tok = ""
+		return
+	}
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		if strings.HasPrefix(p.src[p.pos:], "0x") || strings.HasPrefix(p.src[p.pos:], "0b") {
+			p.pos += 2
+		}
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			if isIdentChar(c) || c == '.' {
+				p.pos++
+			} else if (c == '+' || c == '-') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E') && !strings.HasPrefix(p.src[start:], "0x") {
+				p.pos++
+			} else {
+				break
+			}
+		}
+	case isIdentChar(c):
+		for p.pos < len(p.src) && isIdentChar(p.src[p.pos]) {
+			p.pos++
+		}
+	default:
+		p.pos++
+		if p.pos < len(p.src) {
+			two := p.src[start : p.pos+1]
+			switch two {
+			case "<<", ">>", "<=", ">=", "==", "!=", "&&", "||":
+				p.pos++
+			}
+		}
+	}
+	p.tok = p.src[start:p.pos]
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *exprParser) expect(tok string) error {
+	if p.tok != tok {
+		if p.tok == "" {
+			return fmt.Errorf("expected %q at end", tok)
+		}
+		return fmt.Errorf("expected %q, got %q", tok, p.tok)
+	}
+	p.next()
+	return nil
+}
+
+// parseBinary parses a left-associative chain of ops.
+func (p *exprParser) parseBinary(ops []string, operand func() (exprNode, error)) (exprNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range ops {
+			if p.tok == o {
+				op = o
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode(op, left, right)
+	}
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	return p.parseBinary([]string{"||"}, p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	return p.parseBinary([]string{"&&"}, p.parseCompare)
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	return p.parseBinary([]string{"==", "!=", "<", "<=", ">", ">="}, p.parseBitOr)
+}
+
+func (p *exprParser) parseBitOr() (exprNode, error) {
+	return p.parseBinary([]string{"|"}, p.parseBitAnd)
+}
+
+func (p *exprParser) parseBitAnd() (exprNode, error) {
+	return p.parseBinary([]string{"&"}, p.parseShift)
+}
+
+func (p *exprParser) parseShift() (exprNode, error) {
+	return p.parseBinary([]string{"<<", ">>"}, p.parseAdd)
+}
+
+func (p *exprParser) parseAdd() (exprNode, error) {
+	return p.parseBinary([]string{"+", "-"}, p.parseMul)
+}
+
+func (p *exprParser) parseMul() (exprNode, error) {
+	return p.parseBinary([]string{"*", "/", "%"}, p.parseUnary)
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	switch p.tok {
+	case "-", "+", "!":
+		op := p.tok
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env map[string]float64) (float64, error) {
+			v, err := operand(env)
+			switch op {
+			case "-":
+				v = -v
+			case "!":
+				v = boolValue(v == 0)
+			}
+			return v, err
+		}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end")
+	case tok == "(":
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(")")
+	case tok[0] >= '0' && tok[0] <= '9' || tok[0] == '.':
+		v, err := parseNumber(tok)
+		if err != nil {
+			return nil, err
+		}
+		p.next()
+		return func(map[string]float64) (float64, error) { return v, nil }, nil
+	case isIdentChar(tok[0]):
+		p.next()
+		if p.tok == "(" {
+			return p.parseCall(tok)
+		}
+		if !p.seen[tok] {
+			p.seen[tok] = true
+			p.vars = append(p.vars, tok)
+		}
+		return func(env map[string]float64) (float64, error) {
+			v, ok := env[tok]
+			if !ok {
+				return 0, fmt.Errorf("unknown variable %q", tok)
+			}
+			return v, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", tok)
+}
+
+func (p *exprParser) parseCall(name string) (exprNode, error) {
+	f, ok := exprFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	p.next() // (
+	var args []exprNode
+	for p.tok != ")" {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.tok != "," {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if f.args >= 0 && len(args) != f.args || f.args < 0 && len(args) < 2 {
+		return nil, fmt.Errorf("%s() takes %d argument(s), got %d", name, max(f.args, 2), len(args))
+	}
+	return func(env map[string]float64) (float64, error) {
+		vals := make([]float64, len(args))
+		for i, a := range args {
+			v, err := a(env)
+			if err != nil {
+				return 0, err
+			}
+			vals[i] = v
+		}
+		return f.fn(vals), nil
+	}, nil
+}
+
+func parseNumber(tok string) (float64, error) {
+	if strings.HasPrefix(tok, "0x") || strings.HasPrefix(tok, "0b") {
+		v, err := strconv.ParseInt(tok, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", tok)
+		}
+		return float64(v), nil
+	}
+	v, err := strconv.ParseFloat(tok, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", tok)
+	}
+	return v, nil
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func binaryNode(op string, left, right exprNode) exprNode {
+	return func(env map[string]float64) (float64, error) {
+		a, err := left(env)
+		if err != nil {
+			return 0, err
+		}
+		b, err := right(env)
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "+":
+			return a + b, nil
+		case "-":
+			return a - b, nil
+		case "*":
+			return a * b, nil
+		case "/":
+			return a / b, nil
+		case "%":
+			return math.Mod(a, b), nil
+		case "&":
+			return float64(int64(a) & int64(b)), nil
+		case "|":
+			return float64(int64(a) | int64(b)), nil
+		case "<<":
+			return float64(int64(a) << uint(b)), nil
+		case ">>":
+			return float64(int64(a) >> uint(b)), nil
+		case "==":
+			return boolValue(a == b), nil
+		case "!=":
+			return boolValue(a != b), nil
+		case "<":
+			return boolValue(a < b), nil
+		case "<=":
+			return boolValue(a <= b), nil
+		case ">":
+			return boolValue(a > b), nil
+		case ">=":
+			return boolValue(a >= b), nil
+		case "&&":
+			return boolValue(a != 0 && b != 0), nil
+		case "||":
+			return boolValue(a != 0 || b != 0), nil
+		}
+		return 0, fmt.Errorf("unknown operator %q", op)
+	}
+}
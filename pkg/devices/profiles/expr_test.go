@@ -0,0 +1,82 @@
+package profiles
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestExprEval(t *testing.T) {
+	env := map[string]float64{"x": 10, "raw": 0x7EED0}
+	tests := []struct {
+		src  string
+		want float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"-x / 4", -2.5},
+		{"10 % 4", 2},
+		{"0x10 | 0b11", 19},
+		{"raw >> 4", 0x7EED},
+		{"1 << 4 + 1", 32},
+		{"x & 0x0F", 10},
+		{"2.5e2 + 1e-1", 250.1},
+		{"x > 5 && x < 20", 1},
+		{"x == 5 || !x", 0},
+		{"if(x >= 10, 1, 2)", 1},
+		{"clamp(x * 20, 0, 100)", 100},
+		{"min(3, x, -1)", -1},
+		{"max(3, x)", 10},
+		{"pow(2, 10)", 1024},
+		{"signed(0xFF, 8)", -1},
+		{"signed(0x7F, 8)", 127},
+		{"signed(0x8000, 16)", -32768},
+		{"round(sqrt(x) * 100) / 100", 3.16},
+	}
+	for _, tt := range tests {
+		e, err := ParseExpr(tt.src)
+		if err != nil {
+			t.Errorf("ParseExpr(%q): %v", tt.src, err)
+			continue
+		}
+		got, err := e.Eval(env)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.src, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestExprVars(t *testing.T) {
+	e, err := ParseExpr("a * b + pow(a, c)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(e.Vars(), ","); got != "a,b,c" {
+		t.Errorf("Vars() = %s, want a,b,c", got)
+	}
+	if _, err := e.Eval(map[string]float64{"a": 1}); err == nil || !strings.Contains(err.Error(), `"b"`) {
+		t.Errorf("Eval with missing variable: %v", err)
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"1 2",
+		"nope(1)",
+		"pow(1)",
+		"max(1)",
+		"0xZZ",
+		"1 $ 2",
+	} {
+		if _, err := ParseExpr(src); err == nil {
+			t.Errorf("ParseExpr(%q) succeeded", src)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+// Package profiles describes I2C and SPI peripherals declaratively: the
+// registers to read, the steps that configure or trigger the device, and
+// the formulas that turn raw readings into calibrated quantities. Profiles
+// are YAML or JSON; common sensors ship built in.
+package profiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Byte is a byte in a profile. It is written as a number (118), a hex or
+// binary string ("0x76", "0b0101") or, in action steps, a "$param"
+// placeholder filled in when the action runs.
+type Byte struct {
+	Value int
+	Param string
+}
+
+func parseByte(s string) (Byte, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "$") && len(s) > 1 {
+		return Byte{Param: s[1:]}, nil
+	}
+	v, err := strconv.ParseInt(s, 0, 64)
+	if err != nil || v < 0 || v > 255 {
+		return Byte{}, fmt.Errorf("invalid byte %q", s)
+	}
+	return Byte{Value: int(v)}, nil
+}
+
+func (b *Byte) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := parseByte(s)
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
+
+func (b *Byte) UnmarshalYAML(node *yaml.Node) error {
+	v, err := parseByte(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*b = v
+	return nil
+}
+
+func (b Byte) MarshalJSON() ([]byte, error) {
+	if b.Param != "" {
+		return json.Marshal("$" + b.Param)
+	}
+	return json.Marshal(fmt.Sprintf("0x%02x", b.Value))
+}
+
+// Profile describes one device model.
+type Profile struct {
+	Name        string             `json:"name" yaml:"name"`
+	Description string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Interfaces  []string           `json:"interfaces,omitempty" yaml:"interfaces,omitempty"` // "i2c" and/or "spi"; default i2c
+	Addresses   []Byte             `json:"addresses,omitempty" yaml:"addresses,omitempty"`   // I2C addresses, the first is the default
+	SPI         *SPISettings       `json:"spi,omitempty" yaml:"spi,omitempty"`
+	Identify    *Identify          `json:"identify,omitempty" yaml:"identify,omitempty"`
+	Params      map[string]float64 `json:"params,omitempty" yaml:"params,omitempty"`   // defaults for formula parameters, e.g. a shunt resistance
+	Init        []Step             `json:"init,omitempty" yaml:"init,omitempty"`       // run before every read
+	Blocks      []Block            `json:"blocks,omitempty" yaml:"blocks,omitempty"`   // raw reads
+	Fields      []Field            `json:"fields,omitempty" yaml:"fields,omitempty"`   // values decoded from the reads
+	Derived     []string           `json:"derived,omitempty" yaml:"derived,omitempty"` // "name = formula", evaluated in order
+	Quantities  []Quantity         `json:"quantities,omitempty" yaml:"quantities,omitempty"`
+	Actions     []Action           `json:"actions,omitempty" yaml:"actions,omitempty"`
+
+	Source string `json:"-" yaml:"-"` // "builtin" or the file it was loaded from
+
+	derived []assignment
+}
+
+// SPISettings configures SPI access. Register reads send the register
+// OR'd with ReadFlag; writes send the first byte AND'd with WriteMask.
+type SPISettings struct {
+	Mode      int   `json:"mode" yaml:"mode"`
+	SpeedHz   int   `json:"speed_hz,omitempty" yaml:"speed_hz,omitempty"`
+	ReadFlag  Byte  `json:"read_flag" yaml:"read_flag"`
+	WriteMask *Byte `json:"write_mask,omitempty" yaml:"write_mask,omitempty"`
+}
+
+// Identify recognises the device by an ID register.
+type Identify struct {
+	Register Byte   `json:"register" yaml:"register"`
+	Expect   []Byte `json:"expect" yaml:"expect"` // any of these values
+}
+
+// Step is one operation of an init sequence or action: a write, a delay
+// or a poll until a status bit settles.
+type Step struct {
+	Write    []Byte `json:"write,omitempty" yaml:"write,omitempty"` // bytes sent; register writes start with the register
+	Fill     int    `json:"fill,omitempty" yaml:"fill,omitempty"`   // append this many copies of FillByte to Write
+	FillByte Byte   `json:"fill_byte,omitempty" yaml:"fill_byte,omitempty"`
+	DelayMs  int    `json:"delay_ms,omitempty" yaml:"delay_ms,omitempty"`
+	Poll     *Poll  `json:"poll,omitempty" yaml:"poll,omitempty"`
+}
+
+// Poll reads a status byte until (status & Mask) == Value.
+type Poll struct {
+	Register  *Byte `json:"register,omitempty" yaml:"register,omitempty"` // omitted for devices read without a register
+	Mask      Byte  `json:"mask" yaml:"mask"`
+	Value     Byte  `json:"value" yaml:"value"`
+	TimeoutMs int   `json:"timeout_ms,omitempty" yaml:"timeout_ms,omitempty"`
+}
+
+// Block is a burst read of Length bytes.
+type Block struct {
+	Name     string `json:"name" yaml:"name"`
+	Register *Byte  `json:"register,omitempty" yaml:"register,omitempty"` // omitted for devices read without a register
+	Length   int    `json:"length" yaml:"length"`
+}
+
+// Field decodes a value from a block.
+type Field struct {
+	Name   string `json:"name" yaml:"name"`
+	Block  string `json:"block" yaml:"block"`
+	Offset int    `json:"offset,omitempty" yaml:"offset,omitempty"`
+	Type   string `json:"type" yaml:"type"` // u8, s8, u16le, s16le, u16be, s16be, u24le, u24be, u32le, s32le, u32be, s32be
+}
+
+// Quantity is a named, calibrated reading.
+type Quantity struct {
+	Name        string `json:"name" yaml:"name"`
+	Unit        string `json:"unit,omitempty" yaml:"unit,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Formula     string `json:"formula" yaml:"formula"`
+
+	expr *Expr
+}
+
+// Action is a named step sequence, such as clearing a display.
+type Action struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Params      []string `json:"params,omitempty" yaml:"params,omitempty"` // byte parameters used as $name in writes
+	Steps       []Step   `json:"steps" yaml:"steps"`
+}
+
+type assignment struct {
+	name string
+	expr *Expr
+}
+
+var fieldSizes = map[string]int{
+	"u8": 1, "s8": 1,
+	"u16le": 2, "s16le": 2, "u16be": 2, "s16be": 2,
+	"u24le": 3, "u24be": 3,
+	"u32le": 4, "s32le": 4, "u32be": 4, "s32be": 4,
+}
+
+var (
+	namePattern       = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	identPattern      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	assignmentPattern = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*=([^=].*)$`)
+)
+
+// Parse reads a profile from YAML or JSON; format is "yaml" or "json".
+func Parse(data []byte, format string) (*Profile, error) {
+	var p Profile
+	switch format {
+	case "json":
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, err
+		}
+	case "yaml":
+		if err := yaml.Unmarshal(data, &p); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown profile format %q", format)
+	}
+	if err := p.compile(); err != nil {
+		if p.Name != "" {
+			return nil, fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// formatOf returns the profile format for a file name, or "".
+func formatOf(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".json":
+		return "json"
+	}
+	return ""
+}
+
+// compile validates the profile and compiles its formulas.
+func (p *Profile) compile() error {
+	p.Name = strings.ToLower(strings.TrimSpace(p.Name))
+	if !namePattern.MatchString(p.Name) {
+		return fmt.Errorf("invalid or missing name %q", p.Name)
+	}
+	if len(p.Interfaces) == 0 {
+		p.Interfaces = []string{"i2c"}
+	}
+	for _, iface := range p.Interfaces {
+		if iface != "i2c" && iface != "spi" {
+			return fmt.Errorf("unknown interface %q", iface)
+		}
+	}
+	if p.Supports("spi") && p.SPI == nil {
+		p.SPI = &SPISettings{ReadFlag: Byte{Value: 0x80}}
+	}
+	for _, a := range p.Addresses {
+		if a.Param != "" || a.Value < 0x03 || a.Value > 0x77 {
+			return fmt.Errorf("address %#x is outside the 7-bit range", a.Value)
+		}
+	}
+
+	// Names known so far, for checking formulas refer only to earlier values.
+	known := make(map[string]bool)
+	for name := range p.Params {
+		known[name] = true
+	}
+	if err := checkSteps(p.Init, nil); err != nil {
+		return fmt.Errorf("init: %w", err)
+	}
+
+	blocks := make(map[string]int)
+	for _, b := range p.Blocks {
+		if b.Name == "" || b.Length < 1 || b.Length > 256 {
+			return fmt.Errorf("block %q needs a name and a length of 1-256", b.Name)
+		}
+		blocks[b.Name] = b.Length
+	}
+	for _, f := range p.Fields {
+		if !identPattern.MatchString(f.Name) {
+			return fmt.Errorf("invalid field name %q", f.Name)
+		}
+		size, ok := fieldSizes[f.Type]
+		if !ok {
+			return fmt.Errorf("field %s: unknown type %q", f.Name, f.Type)
+		}
+		length, ok := blocks[f.Block]
+		if !ok {
+			return fmt.Errorf("field %s: unknown block %q", f.Name, f.Block)
+		}
+		if f.Offset < 0 || f.Offset+size > length {
+			return fmt.Errorf("field %s: offset %d+%d is outside block %s (%d bytes)", f.Name, f.Offset, size, f.Block, length)
+		}
+		known[f.Name] = true
+	}
+
+	p.derived = nil
+	for _, line := range p.Derived {
+		m := assignmentPattern.FindStringSubmatch(line)
+		if m == nil {
+			return fmt.Errorf("derived %q: expected \"name = formula\"", line)
+		}
+		expr, err := p.parseFormula(m[2], known)
+		if err != nil {
+			return err
+		}
+		p.derived = append(p.derived, assignment{name: m[1], expr: expr})
+		known[m[1]] = true
+	}
+
+	seen := make(map[string]bool)
+	for i := range p.Quantities {
+		q := &p.Quantities[i]
+		if !identPattern.MatchString(q.Name) || seen[q.Name] {
+			return fmt.Errorf("invalid or duplicate quantity name %q", q.Name)
+		}
+		seen[q.Name] = true
+		expr, err := p.parseFormula(q.Formula, known)
+		if err != nil {
+			return fmt.Errorf("quantity %s: %w", q.Name, err)
+		}
+		q.expr = expr
+	}
+
+	for _, a := range p.Actions {
+		if !namePattern.MatchString(a.Name) || len(a.Steps) == 0 {
+			return fmt.Errorf("action %q needs a name and steps", a.Name)
+		}
+		if err := checkSteps(a.Steps, a.Params); err != nil {
+			return fmt.Errorf("action %s: %w", a.Name, err)
+		}
+	}
+	if len(p.Quantities) == 0 && len(p.Actions) == 0 {
+		return fmt.Errorf("profile has neither quantities nor actions")
+	}
+	return nil
+}
+
+func (p *Profile) parseFormula(src string, known map[string]bool) (*Expr, error) {
+	expr, err := ParseExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range expr.Vars() {
+		if !known[v] {
+			return nil, fmt.Errorf("formula %q: %q is not a field, parameter or earlier derived value", strings.TrimSpace(src), v)
+		}
+	}
+	return expr, nil
+}
+
+func checkSteps(steps []Step, params []string) error {
+	allowed := make(map[string]bool)
+	for _, name := range params {
+		allowed[name] = true
+	}
+	for i, s := range steps {
+		if len(s.Write) == 0 && s.DelayMs == 0 && s.Poll == nil {
+			return fmt.Errorf("step %d does nothing", i+1)
+		}
+		if s.Fill < 0 || s.Fill > 8192 {
+			return fmt.Errorf("step %d: fill must be 0-8192", i+1)
+		}
+		for _, b := range append(s.Write, s.FillByte) {
+			if b.Param != "" && !allowed[b.Param] {
+				return fmt.Errorf("step %d: unknown parameter $%s", i+1, b.Param)
+			}
+		}
+	}
+	return nil
+}
+
+// Supports reports whether the device can be reached over iface.
+func (p *Profile) Supports(iface string) bool {
+	for _, i := range p.Interfaces {
+		if i == iface {
+			return true
+		}
+	}
+	return false
+}
+
+// Quantity returns the named quantity, or nil.
+func (p *Profile) Quantity(name string) *Quantity {
+	for i := range p.Quantities {
+		if strings.EqualFold(p.Quantities[i].Name, name) {
+			return &p.Quantities[i]
+		}
+	}
+	return nil
+}
+
+// Action returns the named action, or nil.
+func (p *Profile) Action(name string) *Action {
+	for i := range p.Actions {
+		if strings.EqualFold(p.Actions[i].Name, name) {
+			return &p.Actions[i]
+		}
+	}
+	return nil
+}
+
+// decodeField reads a field's raw value from its block.
+func decodeField(f Field, block []byte) float64 {
+	b := block[f.Offset:]
+	switch f.Type {
+	case "u8":
+		return float64(b[0])
+	case "s8":
+		return float64(int8(b[0]))
+	case "u16le":
+		return float64(uint16(b[0]) | uint16(b[1])<<8)
+	case "s16le":
+		return float64(int16(uint16(b[0]) | uint16(b[1])<<8))
+	case "u16be":
+		return float64(uint16(b[1]) | uint16(b[0])<<8)
+	case "s16be":
+		return float64(int16(uint16(b[1]) | uint16(b[0])<<8))
+	case "u24le":
+		return float64(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16)
+	case "u24be":
+		return float64(uint32(b[2]) | uint32(b[1])<<8 | uint32(b[0])<<16)
+	case "u32le":
+		return float64(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24)
+	case "s32le":
+		return float64(int32(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24))
+	case "u32be":
+		return float64(uint32(b[3]) | uint32(b[2])<<8 | uint32(b[1])<<16 | uint32(b[0])<<24)
+	case "s32be":
+		return float64(int32(uint32(b[3]) | uint32(b[2])<<8 | uint32(b[1])<<16 | uint32(b[0])<<24))
+	}
+	return math.NaN()
+}
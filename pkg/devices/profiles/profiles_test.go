@@ -0,0 +1,361 @@
+package profiles
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeI2C is a device with auto-incrementing byte registers, or wider
+// registers set in words. Reads without a register return the next queued
+// response.
+type fakeI2C struct {
+	regs      [256]byte
+	words     map[int][]byte
+	responses [][]byte
+	writes    [][]byte
+}
+
+func (f *fakeI2C) Read(reg, n int) ([]byte, error) {
+	if reg < 0 {
+		if len(f.responses) == 0 {
+			return make([]byte, n), nil
+		}
+		data := f.responses[0]
+		if len(f.responses) > 1 {
+			f.responses = f.responses[1:]
+		}
+		return data[:n], nil
+	}
+	if w, ok := f.words[reg]; ok {
+		return w[:n], nil
+	}
+	return append([]byte(nil), f.regs[reg:reg+n]...), nil
+}
+
+func (f *fakeI2C) Write(data []byte) error {
+	f.writes = append(f.writes, append([]byte(nil), data...))
+	if len(data) > 1 {
+		copy(f.regs[data[0]:], data[1:])
+	}
+	return nil
+}
+
+func (f *fakeI2C) set(reg int, data ...byte) {
+	copy(f.regs[reg:], data)
+}
+
+func le16(v int) []byte {
+	return []byte{byte(v), byte(v >> 8)}
+}
+
+func noSleep(t *testing.T) {
+	orig := sleep
+	sleep = func(time.Duration) {}
+	t.Cleanup(func() { sleep = orig })
+}
+
+func builtin(t *testing.T, name string) *Profile {
+	t.Helper()
+	p, ok := NewRegistry().Get(name)
+	if !ok {
+		t.Fatalf("no builtin profile %s", name)
+	}
+	return p
+}
+
+func readingsByName(readings []Reading) map[string]float64 {
+	m := make(map[string]float64)
+	for _, r := range readings {
+		m[r.Name] = r.Value
+	}
+	return m
+}
+
+func assertNear(t *testing.T, name string, got, want, tolerance float64) {
+	t.Helper()
+	if math.Abs(got-want) > tolerance {
+		t.Errorf("%s = %v, want %v ± %v", name, got, want, tolerance)
+	}
+}
+
+// bmx280 loads the calibration and readings from the datasheet's worked
+// example (adc_T = 519888, adc_P = 415148).
+func bmx280(chipID byte) *fakeI2C {
+	f := &fakeI2C{}
+	f.set(0xD0, chipID)
+	var calib []byte
+	for _, v := range []int{27504, 26435, -1000, 36477, -10685, 3024, 2855, 140, -7, 15500, -14600, 6000} {
+		calib = append(calib, le16(v)...)
+	}
+	f.set(0x88, calib...)
+	f.set(0xF7, 0x65, 0x5A, 0xC0, 0x7E, 0xED, 0x00)
+	return f
+}
+
+// bme280Humidity is the datasheet's double-precision humidity compensation.
+func bme280Humidity(adcH, tFine, h1, h2, h3, h4, h5, h6 float64) float64 {
+	h := tFine - 76800.0
+	h = (adcH - (h4*64.0 + h5/16384.0*h)) * (h2 / 65536.0 * (1.0 + h6/67108864.0*h*(1.0+h3/67108864.0*h)))
+	h = h * (1.0 - h1*h/524288.0)
+	return math.Max(0, math.Min(100, h))
+}
+
+func TestBME280(t *testing.T) {
+	noSleep(t)
+	f := bmx280(0x60)
+	f.set(0xA1, 75)
+	// dig_H2 = 362, dig_H3 = 0, dig_H4 = 313, dig_H5 = 50, dig_H6 = 30
+	f.set(0xE1, 0x6A, 0x01, 0x00, 0x13, 0x29, 0x03, 30)
+	f.set(0xFD, 0x75, 0x30) // adc_H = 30000
+
+	p := builtin(t, "bme280")
+	readings, err := p.Read(f, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readingsByName(readings)
+	assertNear(t, "temperature", got["temperature"], 25.08, 0.01)
+	assertNear(t, "pressure", got["pressure"], 1006.5327, 0.01)
+	tFine := got["temperature"] * 5120.0
+	assertNear(t, "humidity", got["humidity"], bme280Humidity(30000, tFine, 75, 362, 0, 313, 50, 30), 1e-9)
+
+	// Forced mode: each read triggers a measurement.
+	if len(f.writes) != 3 || !bytes.Equal(f.writes[2], []byte{0xF4, 0x25}) {
+		t.Errorf("init writes = %x", f.writes)
+	}
+	for _, r := range readings {
+		if r.Unit == "" {
+			t.Errorf("%s has no unit", r.Name)
+		}
+	}
+}
+
+func TestBMP280SelectedQuantity(t *testing.T) {
+	noSleep(t)
+	p := builtin(t, "bmp280")
+	readings, err := p.Read(bmx280(0x58), nil, []string{"Temperature"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readings) != 1 || readings[0].Name != "temperature" || readings[0].Unit != "°C" {
+		t.Fatalf("readings = %+v", readings)
+	}
+	assertNear(t, "temperature", readings[0].Value, 25.08, 0.01)
+
+	if _, err := p.Read(bmx280(0x58), nil, []string{"humidity"}); err == nil || !strings.Contains(err.Error(), "temperature, pressure") {
+		t.Errorf("unknown quantity error = %v", err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	p := builtin(t, "bme280")
+	if ok, err := p.Detect(bmx280(0x60)); err != nil || !ok {
+		t.Errorf("Detect(bme280) = %v, %v", ok, err)
+	}
+	if ok, err := p.Detect(bmx280(0x58)); err != nil || ok {
+		t.Errorf("Detect(bmp280 chip) = %v, %v", ok, err)
+	}
+	if _, err := builtin(t, "sht31").Detect(&fakeI2C{}); err == nil {
+		t.Error("Detect without identify register succeeded")
+	}
+}
+
+func TestAHT20(t *testing.T) {
+	noSleep(t)
+	// Busy once, then idle with humidity 0x80000 (50%) and temperature 0x60000 (25 °C).
+	f := &fakeI2C{responses: [][]byte{
+		{0x98},
+		{0x18, 0x80, 0x00, 0x06, 0x00, 0x00, 0x00},
+	}}
+	readings, err := builtin(t, "aht20").Read(f, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readingsByName(readings)
+	assertNear(t, "temperature", got["temperature"], 25, 1e-9)
+	assertNear(t, "humidity", got["humidity"], 50, 1e-9)
+	if len(f.writes) != 1 || !bytes.Equal(f.writes[0], []byte{0xAC, 0x33, 0x00}) {
+		t.Errorf("writes = %x", f.writes)
+	}
+}
+
+func TestPollTimeout(t *testing.T) {
+	noSleep(t)
+	f := &fakeI2C{responses: [][]byte{{0x80}}}
+	_, err := builtin(t, "aht20").Read(f, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "still busy") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestSHT31(t *testing.T) {
+	noSleep(t)
+	f := &fakeI2C{responses: [][]byte{{0x66, 0x66, 0x00, 0x80, 0x00, 0x00}}}
+	readings, err := builtin(t, "sht31").Read(f, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readingsByName(readings)
+	assertNear(t, "temperature", got["temperature"], -45+175*26214/65535.0, 1e-9)
+	assertNear(t, "humidity", got["humidity"], 100*32768/65535.0, 1e-9)
+}
+
+func TestINA219Params(t *testing.T) {
+	f := &fakeI2C{words: map[int][]byte{
+		0x01: {0x03, 0xE8}, // 1000 * 10 µV = 10 mV
+		0x02: {0x5D, 0xC0}, // 3000 << 3: 3000 * 4 mV = 12 V
+	}}
+
+	p := builtin(t, "ina219")
+	readings, err := p.Read(f, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readingsByName(readings)
+	assertNear(t, "bus_voltage", got["bus_voltage"], 12, 1e-9)
+	assertNear(t, "shunt_voltage", got["shunt_voltage"], 10, 1e-9)
+	assertNear(t, "current", got["current"], 100, 1e-9)
+	assertNear(t, "power", got["power"], 1200, 1e-6)
+
+	readings, err = p.Read(f, map[string]float64{"shunt_ohms": 0.01}, []string{"current"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNear(t, "current", readings[0].Value, 1000, 1e-9)
+
+	if _, err := p.Read(f, map[string]float64{"gain": 2}, nil); err == nil {
+		t.Error("unknown parameter accepted")
+	}
+}
+
+func TestSSD1306Actions(t *testing.T) {
+	p := builtin(t, "ssd1306")
+	f := &fakeI2C{}
+	if err := p.Run(f, "contrast", map[string]int{"value": 0x40}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run(f, "clear", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.writes) != 3 || !bytes.Equal(f.writes[0], []byte{0x00, 0x81, 0x40}) {
+		t.Fatalf("writes = %x", f.writes[:1])
+	}
+	if data := f.writes[2]; len(data) != 1025 || data[0] != 0x40 || data[1024] != 0x00 {
+		t.Errorf("clear wrote %d bytes starting %#x", len(data), data[0])
+	}
+
+	if err := p.Run(f, "contrast", nil); err == nil {
+		t.Error("missing action parameter accepted")
+	}
+	if err := p.Run(f, "contrast", map[string]int{"value": 300}); err == nil {
+		t.Error("out-of-range action parameter accepted")
+	}
+	if err := p.Run(f, "scroll", nil); err == nil {
+		t.Error("unknown action accepted")
+	}
+	if _, err := p.Read(f, nil, nil); err == nil {
+		t.Error("read of an action-only profile succeeded")
+	}
+}
+
+func TestBuiltinProfiles(t *testing.T) {
+	list := NewRegistry().List()
+	if len(list) < 8 {
+		t.Fatalf("got %d builtin profiles", len(list))
+	}
+	for _, p := range list {
+		if p.Source != "builtin" || p.Description == "" {
+			t.Errorf("%s: source %q, description %q", p.Name, p.Source, p.Description)
+		}
+		if p.Supports("i2c") && len(p.Addresses) == 0 {
+			t.Errorf("%s has no I2C addresses", p.Name)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	p, err := Parse([]byte(`{
+		"name": "TMP102",
+		"addresses": ["0x48", 73],
+		"blocks": [{"name": "temp", "register": 0, "length": 2}],
+		"fields": [{"name": "raw", "block": "temp", "type": "s16be"}],
+		"quantities": [{"name": "temperature", "unit": "°C", "formula": "(raw >> 4) * 0.0625"}]
+	}`), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "tmp102" || p.Addresses[1].Value != 0x49 || !p.Supports("i2c") || p.Supports("spi") {
+		t.Errorf("profile = %+v", p)
+	}
+	f := &fakeI2C{}
+	f.set(0, 0x19, 0x00)
+	readings, err := p.Read(f, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNear(t, "temperature", readings[0].Value, 25, 1e-9)
+}
+
+func TestParseErrors(t *testing.T) {
+	base := "name: test\nblocks: [{name: d, register: 0, length: 2}]\nfields: [{name: raw, block: d, type: u16be}]\n"
+	tests := map[string]string{
+		"missing name":      "quantities: [{name: t, formula: '1'}]",
+		"bad interface":     base + "interfaces: [uart]\nquantities: [{name: t, formula: raw}]",
+		"bad address":       base + "addresses: [0x80]\nquantities: [{name: t, formula: raw}]",
+		"bad byte":          base + "addresses: [0x1FF]\nquantities: [{name: t, formula: raw}]",
+		"field overflow":    "name: test\nblocks: [{name: d, length: 1}]\nfields: [{name: raw, block: d, type: u16be}]\nquantities: [{name: t, formula: raw}]",
+		"unknown block":     "name: test\nblocks: [{name: d, length: 2}]\nfields: [{name: raw, block: x, type: u8}]\nquantities: [{name: t, formula: raw}]",
+		"unknown type":      "name: test\nblocks: [{name: d, length: 2}]\nfields: [{name: raw, block: d, type: u12}]\nquantities: [{name: t, formula: raw}]",
+		"unknown variable":  base + "quantities: [{name: t, formula: 'raw * k'}]",
+		"forward reference": base + "derived: ['a = b', 'b = raw']\nquantities: [{name: t, formula: a}]",
+		"bad derived":       base + "derived: ['a == raw']\nquantities: [{name: t, formula: a}]",
+		"bad formula":       base + "quantities: [{name: t, formula: 'raw +'}]",
+		"duplicate":         base + "quantities: [{name: t, formula: raw}, {name: t, formula: raw}]",
+		"empty":             base,
+		"unknown param":     "name: test\nactions: [{name: go, steps: [{write: [0x00, $v]}]}]",
+		"empty step":        "name: test\nactions: [{name: go, steps: [{}]}]",
+	}
+	for name, src := range tests {
+		if _, err := Parse([]byte(src), "yaml"); err == nil {
+			t.Errorf("%s: Parse succeeded", name)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"sht31.yaml": "name: sht31\naddresses: [0x44]\nblocks: [{name: d, length: 2}]\nfields: [{name: raw, block: d, type: u16be}]\nquantities: [{name: temperature, formula: raw}]\n",
+		"relay.json": `{"name": "relay", "actions": [{"name": "on", "steps": [{"write": [1]}]}]}`,
+		"broken.yml": "name: broken\n",
+		"notes.txt":  "ignored",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewRegistry()
+	errs := r.LoadDir(dir)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "broken.yml") {
+		t.Errorf("errs = %v", errs)
+	}
+	if p, ok := r.Get("SHT31"); !ok || p.Source != filepath.Join(dir, "sht31.yaml") || len(p.Quantities) != 1 {
+		t.Errorf("sht31 was not overridden: %+v", p)
+	}
+	if _, ok := r.Get("relay"); !ok {
+		t.Error("relay not loaded")
+	}
+	if _, ok := r.Get("bme280"); !ok {
+		t.Error("builtin bme280 missing after LoadDir")
+	}
+	if errs := r.LoadDir(filepath.Join(dir, "missing")); errs != nil {
+		t.Errorf("missing dir: %v", errs)
+	}
+}
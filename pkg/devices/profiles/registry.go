@@ -0,0 +1,87 @@
+package profiles
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//go:embed builtin/*.yaml
+var builtinFS embed.FS
+
+// Registry holds profiles by name.
+type Registry struct {
+	profiles map[string]*Profile
+}
+
+// NewRegistry returns a registry with the built-in profiles.
+func NewRegistry() *Registry {
+	r := &Registry{profiles: make(map[string]*Profile)}
+	entries, _ := builtinFS.ReadDir("builtin")
+	for _, e := range entries {
+		data, err := builtinFS.ReadFile("builtin/" + e.Name())
+		if err != nil {
+			panic(err)
+		}
+		p, err := Parse(data, "yaml")
+		if err != nil {
+			panic(fmt.Sprintf("builtin profile %s: %v", e.Name(), err))
+		}
+		p.Source = "builtin"
+		r.profiles[p.Name] = p
+	}
+	return r
+}
+
+// LoadDir adds the .yaml, .yml and .json profiles in dir, replacing
+// built-in profiles of the same name. A missing dir is not an error; the
+// errors of invalid files are returned after the valid ones are loaded.
+func (r *Registry) LoadDir(dir string) []error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range entries {
+		format := formatOf(e.Name())
+		if e.IsDir() || format == "" {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		p, err := Parse(data, format)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		p.Source = path
+		r.profiles[p.Name] = p
+	}
+	return errs
+}
+
+// Get returns the named profile.
+func (r *Registry) Get(name string) (*Profile, bool) {
+	p, ok := r.profiles[strings.ToLower(strings.TrimSpace(name))]
+	return p, ok
+}
+
+// List returns the profiles sorted by name.
+func (r *Registry) List() []*Profile {
+	list := make([]*Profile, 0, len(r.profiles))
+	for _, p := range r.profiles {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,65 @@
+package devices
+
+import (
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+)
+
+// Handling says what happens to a device event.
+type Handling string
+
+const (
+	HandleNotify Handling = "notify" // send the formatted event to the chat
+	HandleAgent  Handling = "agent"  // let the agent decide what to do
+	HandleIgnore Handling = "ignore" // drop it
+)
+
+// ParseHandling returns the handling named by s, or false if s names none.
+func ParseHandling(s string) (Handling, bool) {
+	switch h := Handling(strings.ToLower(strings.TrimSpace(s))); h {
+	case HandleNotify, HandleAgent, HandleIgnore:
+		return h, true
+	}
+	return "", false
+}
+
+// Rule picks the handling for matching events. Empty fields match
+// anything; Vendor, Product and DeviceID match case-insensitive
+// substrings.
+type Rule struct {
+	Kind     string
+	Action   string
+	Vendor   string
+	Product  string
+	DeviceID string
+	Handle   Handling
+	Prompt   string // extra instructions for the agent when Handle is agent
+}
+
+func (r *Rule) matches(ev *events.DeviceEvent) bool {
+	if r.Kind != "" && !strings.EqualFold(r.Kind, string(ev.Kind)) {
+		return false
+	}
+	if r.Action != "" && !strings.EqualFold(r.Action, string(ev.Action)) {
+		return false
+	}
+	return containsFold(ev.Vendor, r.Vendor) &&
+		containsFold(ev.Product, r.Product) &&
+		containsFold(ev.DeviceID, r.DeviceID)
+}
+
+func containsFold(s, sub string) bool {
+	return sub == "" || strings.Contains(strings.ToLower(s), strings.ToLower(sub))
+}
+
+// route returns the handling for ev and the rule that chose it, if any.
+// The first matching rule wins.
+func route(rules []Rule, fallback Handling, ev *events.DeviceEvent) (Handling, *Rule) {
+	for i := range rules {
+		if rules[i].matches(ev) {
+			return rules[i].Handle, &rules[i]
+		}
+	}
+	return fallback, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/constants"
@@ -14,31 +15,71 @@ import (
 )
 
 type Service struct {
-	bus     *bus.MessageBus
-	state   *state.Manager
-	sources []events.EventSource
-	enabled bool
-	ctx     context.Context
-	cancel  context.CancelFunc
-	mu      sync.RWMutex
+	bus       *bus.MessageBus
+	state     *state.Manager
+	sources   []events.EventSource
+	listeners []func(*events.DeviceEvent)
+	enabled   bool
+	agentID   string
+	target    string
+	handling  Handling
+	rules     []Rule
+	debounce  *debouncer
+	ctx       context.Context
+	cancel    context.CancelFunc
+	mu        sync.RWMutex
 }
 
+// Config selects the event sources. All sources report events on Linux
+// only.
 type Config struct {
-	Enabled    bool
-	MonitorUSB bool // When true, monitor USB hotplug (Linux only)
-	// Future: MonitorBluetooth, MonitorPCI, etc.
+	Enabled          bool
+	MonitorUSB       bool // USB hotplug
+	MonitorBlock     bool // block devices attached, removed, mounted or unmounted
+	MonitorNetwork   bool // network interfaces up/down and Wi-Fi association
+	MonitorBluetooth bool // BlueZ devices connecting and disconnecting
+	GPIO             []sources.GPIOLine
+
+	Agent           string        // agent that handles events; the default agent when empty
+	Target          string        // "channel:chat_id" to report to; the last active chat when empty
+	DefaultHandling Handling      // for events no rule matches; notify when empty
+	Rules           []Rule        // first match wins
+	Debounce        time.Duration // quiet period before a device's events are delivered; 0 delivers at once
 }
 
 func NewService(cfg Config, stateMgr *state.Manager) *Service {
 	s := &Service{
-		state:   stateMgr,
-		enabled: cfg.Enabled,
-		sources: make([]EventSource, 0),
+		state:    stateMgr,
+		enabled:  cfg.Enabled,
+		sources:  make([]EventSource, 0),
+		agentID:  cfg.Agent,
+		target:   cfg.Target,
+		handling: cfg.DefaultHandling,
+		rules:    cfg.Rules,
+	}
+	if s.handling == "" {
+		s.handling = HandleNotify
 	}
+	s.debounce = newDebouncer(cfg.Debounce, s.deliver)
 
-	if cfg.Enabled && cfg.MonitorUSB {
+	if !cfg.Enabled {
+		return s
+	}
+	if cfg.MonitorUSB {
 		s.sources = append(s.sources, sources.NewUSBMonitor())
 	}
+	if cfg.MonitorBlock {
+		s.sources = append(s.sources, sources.NewBlockMonitor())
+	}
+	if cfg.MonitorNetwork {
+		s.sources = append(s.sources, sources.NewNetworkMonitor())
+	}
+	if cfg.MonitorBluetooth {
+		s.sources = append(s.sources, sources.NewBluetoothMonitor())
+	}
+	if len(cfg.GPIO) > 0 {
+		s.sources = append(s.sources, sources.NewGPIOMonitor(cfg.GPIO))
+	}
 
 	return s
 }
@@ -49,6 +90,14 @@ func (s *Service) SetBus(msgBus *bus.MessageBus) {
 	s.bus = msgBus
 }
 
+// AddListener registers fn to receive every device event in addition to
+// the chat notification.
+func (s *Service) AddListener(fn func(*events.DeviceEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
 func (s *Service) Start(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -91,6 +140,7 @@ func (s *Service) Stop() {
 	for _, src := range s.sources {
 		src.Stop()
 	}
+	s.debounce.stop()
 
 	logger.InfoC("devices", "Device event service stopped")
 }
@@ -100,10 +150,52 @@ func (s *Service) handleEvents(kind events.Kind, eventCh <-chan *events.DeviceEv
 		if ev == nil {
 			continue
 		}
+		s.debounce.add(ev)
+	}
+}
+
+// deliver passes a debounced event to the listeners, then reports it as
+// the matching rule says.
+func (s *Service) deliver(ev *events.DeviceEvent) {
+	s.mu.RLock()
+	listeners := s.listeners
+	s.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(ev)
+	}
+
+	handling, rule := route(s.rules, s.handling, ev)
+	switch handling {
+	case HandleIgnore:
+		logger.DebugCF("devices", "Device event ignored", map[string]interface{}{
+			"kind":   ev.Kind,
+			"action": ev.Action,
+			"device": ev.DeviceID,
+		})
+	case HandleAgent:
+		prompt := ""
+		if rule != nil {
+			prompt = rule.Prompt
+		}
+		s.sendToAgent(ev, prompt)
+	default:
 		s.sendNotification(ev)
 	}
 }
 
+// reportTarget returns the channel and chat events are reported to.
+func (s *Service) reportTarget() (platform, userID string) {
+	target := s.target
+	if target == "" && s.state != nil {
+		target = s.state.GetLastChannel()
+	}
+	platform, userID = parseLastChannel(target)
+	if constants.IsInternalChannel(platform) {
+		return "", ""
+	}
+	return platform, userID
+}
+
 func (s *Service) sendNotification(ev *events.DeviceEvent) {
 	s.mu.RLock()
 	msgBus := s.bus
@@ -113,19 +205,14 @@ func (s *Service) sendNotification(ev *events.DeviceEvent) {
 		return
 	}
 
-	lastChannel := s.state.GetLastChannel()
-	if lastChannel == "" {
-		logger.DebugCF("devices", "No last channel, skipping notification", map[string]interface{}{
+	platform, userID := s.reportTarget()
+	if platform == "" || userID == "" {
+		logger.DebugCF("devices", "No target channel, skipping notification", map[string]interface{}{
 			"event": ev.FormatMessage(),
 		})
 		return
 	}
 
-	platform, userID := parseLastChannel(lastChannel)
-	if platform == "" || userID == "" || constants.IsInternalChannel(platform) {
-		return
-	}
-
 	msg := ev.FormatMessage()
 	msgBus.PublishOutbound(bus.OutboundMessage{
 		Channel: platform,
@@ -140,6 +227,60 @@ func (s *Service) sendNotification(ev *events.DeviceEvent) {
 	})
 }
 
+// sendToAgent hands ev to the agent as a system message. The agent's reply
+// goes to the report target.
+func (s *Service) sendToAgent(ev *events.DeviceEvent, prompt string) {
+	s.mu.RLock()
+	msgBus := s.bus
+	s.mu.RUnlock()
+
+	if msgBus == nil {
+		return
+	}
+
+	platform, userID := s.reportTarget()
+	if platform == "" || userID == "" {
+		logger.DebugCF("devices", "No target channel, skipping agent handling", map[string]interface{}{
+			"event": ev.FormatMessage(),
+		})
+		return
+	}
+
+	content := strings.TrimSpace(ev.FormatMessage()) +
+		"\n\nDecide whether this needs anything from you, such as telling the user or offering to use the device."
+	if prompt != "" {
+		content += "\n\n" + prompt
+	}
+	metadata := map[string]string{
+		"source":        "device",
+		"device_kind":   string(ev.Kind),
+		"device_action": string(ev.Action),
+		"device_id":     ev.DeviceID,
+		"vendor":        ev.Vendor,
+		"product":       ev.Product,
+		"serial":        ev.Serial,
+		"capabilities":  ev.Capabilities,
+	}
+	if s.agentID != "" {
+		metadata["agent_id"] = s.agentID
+	}
+
+	msgBus.PublishInbound(bus.InboundMessage{
+		Channel:  "system",
+		SenderID: "device:" + string(ev.Kind),
+		ChatID:   platform + ":" + userID,
+		Content:  content,
+		Metadata: metadata,
+	})
+
+	logger.InfoCF("devices", "Device event sent to agent", map[string]interface{}{
+		"kind":   ev.Kind,
+		"action": ev.Action,
+		"agent":  s.agentID,
+		"to":     platform,
+	})
+}
+
 func parseLastChannel(lastChannel string) (platform, userID string) {
 	if lastChannel == "" {
 		return "", ""
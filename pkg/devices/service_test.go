@@ -0,0 +1,142 @@
+package devices
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+)
+
+func usbEvent(action events.Action, id, vendor, product string) *events.DeviceEvent {
+	return &events.DeviceEvent{Action: action, Kind: events.KindUSB, DeviceID: id, Vendor: vendor, Product: product}
+}
+
+func TestRoute(t *testing.T) {
+	rules := []Rule{
+		{Kind: "usb", Product: "cp210x", Handle: HandleAgent, Prompt: "Offer to open the serial console."},
+		{Kind: "usb", Action: "remove", Handle: HandleIgnore},
+		{Kind: "network", Handle: HandleIgnore},
+	}
+	tests := []struct {
+		ev   *events.DeviceEvent
+		want Handling
+	}{
+		{usbEvent(events.ActionAdd, "1:4", "Silicon Labs", "CP210x UART Bridge"), HandleAgent},
+		{usbEvent(events.ActionRemove, "1:4", "Silicon Labs", "CP210x UART Bridge"), HandleAgent},
+		{usbEvent(events.ActionRemove, "1:5", "Logitech", "Mouse"), HandleIgnore},
+		{usbEvent(events.ActionAdd, "1:5", "Logitech", "Mouse"), HandleNotify},
+		{&events.DeviceEvent{Action: events.ActionAdd, Kind: events.KindNetwork, DeviceID: "eth0"}, HandleIgnore},
+	}
+	for _, tt := range tests {
+		got, rule := route(rules, HandleNotify, tt.ev)
+		if got != tt.want {
+			t.Errorf("route(%s %s %s) = %q, want %q", tt.ev.Kind, tt.ev.Action, tt.ev.Product, got, tt.want)
+		}
+		if got == HandleAgent && (rule == nil || rule.Prompt == "") {
+			t.Errorf("agent rule not returned for %s", tt.ev.Product)
+		}
+	}
+
+	if _, ok := ParseHandling("Agent"); !ok {
+		t.Error("ParseHandling(Agent) failed")
+	}
+	if _, ok := ParseHandling("shout"); ok {
+		t.Error("ParseHandling accepted an unknown handling")
+	}
+}
+
+func TestDebouncer(t *testing.T) {
+	var mu sync.Mutex
+	var delivered []*events.DeviceEvent
+	d := newDebouncer(30*time.Millisecond, func(ev *events.DeviceEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, ev)
+	})
+
+	// A drive that flaps out and back in is not reported at all.
+	d.add(usbEvent(events.ActionRemove, "1:4", "", "Drive"))
+	d.add(usbEvent(events.ActionAdd, "1:4", "", "Drive"))
+	// A device that settles into a new state is reported once.
+	d.add(usbEvent(events.ActionAdd, "1:5", "", "Camera"))
+	d.add(usbEvent(events.ActionRemove, "1:5", "", "Camera"))
+	d.add(usbEvent(events.ActionAdd, "1:5", "", "Camera"))
+	// Other devices are independent.
+	d.add(usbEvent(events.ActionAdd, "1:6", "", "Keyboard"))
+
+	time.Sleep(150 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(delivered) != 2 {
+		t.Fatalf("delivered %d events, want 2", len(delivered))
+	}
+	for _, ev := range delivered {
+		switch ev.Product {
+		case "Camera":
+			if ev.Action != events.ActionAdd || ev.Raw["DEBOUNCED_EVENTS"] != "3" {
+				t.Errorf("camera event = %+v", ev)
+			}
+		case "Keyboard":
+			if ev.Raw["DEBOUNCED_EVENTS"] != "" {
+				t.Errorf("single event marked as debounced: %+v", ev.Raw)
+			}
+		default:
+			t.Errorf("unexpected event for %s", ev.Product)
+		}
+	}
+}
+
+func TestServiceDeliver(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	s := NewService(Config{
+		Enabled: true,
+		Agent:   "ops",
+		Target:  "telegram:42",
+		Rules: []Rule{
+			{Kind: "usb", Product: "uart", Handle: HandleAgent, Prompt: "Offer to open the serial console."},
+			{Kind: "usb", Product: "mouse", Handle: HandleIgnore},
+		},
+	}, nil)
+	s.SetBus(msgBus)
+	var heard []string
+	s.AddListener(func(ev *events.DeviceEvent) { heard = append(heard, ev.Product) })
+
+	s.deliver(usbEvent(events.ActionAdd, "1:4", "Silicon Labs", "CP210x UART Bridge"))
+	s.deliver(usbEvent(events.ActionAdd, "1:5", "Logitech", "Mouse"))
+	s.deliver(usbEvent(events.ActionAdd, "1:6", "SanDisk", "Ultra"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	in, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no message for the agent")
+	}
+	if in.Channel != "system" || in.ChatID != "telegram:42" || in.SenderID != "device:usb" {
+		t.Errorf("agent message routing = %s %s %s", in.Channel, in.ChatID, in.SenderID)
+	}
+	if in.Metadata["agent_id"] != "ops" || in.Metadata["source"] != "device" ||
+		in.Metadata["device_action"] != "add" || in.Metadata["product"] != "CP210x UART Bridge" {
+		t.Errorf("agent message metadata = %v", in.Metadata)
+	}
+	if !strings.Contains(in.Content, "serial console") {
+		t.Errorf("agent message content = %q", in.Content)
+	}
+
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok {
+		t.Fatal("no notification")
+	}
+	if out.Channel != "telegram" || out.ChatID != "42" || !strings.Contains(out.Content, "SanDisk Ultra") {
+		t.Errorf("notification = %+v", out)
+	}
+
+	// Ignored events still reach listeners such as automations.
+	if len(heard) != 3 {
+		t.Errorf("listeners heard %v", heard)
+	}
+}
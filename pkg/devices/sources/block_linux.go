@@ -0,0 +1,276 @@
+//go:build linux
+
+package sources
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const defaultMountPollInterval = 2 * time.Second
+
+// BlockMonitor reports block devices such as SD cards and USB drives being
+// attached, removed or having their media changed (from kernel uevents),
+// and their partitions being mounted or unmounted (from the mount table).
+type BlockMonitor struct {
+	openUevents  streamOpener
+	readMounts   func() (map[string]mountEntry, error)
+	pollInterval time.Duration
+	sysfsRoot    string
+
+	stream io.Closer
+	cancel context.CancelFunc
+	mu     sync.Mutex
+}
+
+// mountEntry is one line of the mount table.
+type mountEntry struct {
+	MountPoint string
+	FSType     string
+}
+
+func NewBlockMonitor() *BlockMonitor {
+	return &BlockMonitor{
+		openUevents: func(ctx context.Context) (io.ReadCloser, error) {
+			return openNetlink(syscall.NETLINK_KOBJECT_UEVENT, ueventKernelGroup)
+		},
+		readMounts:   readProcMounts,
+		pollInterval: defaultMountPollInterval,
+		sysfsRoot:    "/sys",
+	}
+}
+
+func (m *BlockMonitor) Kind() events.Kind {
+	return events.KindBlock
+}
+
+func (m *BlockMonitor) Start(ctx context.Context) (<-chan *events.DeviceEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream, err := m.openUevents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("block uevents: %w", err)
+	}
+	mounts, err := m.readMounts()
+	if err != nil {
+		stream.Close()
+		return nil, fmt.Errorf("read mounts: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	m.stream, m.cancel = stream, cancel
+	context.AfterFunc(ctx, func() { stream.Close() })
+
+	eventCh := make(chan *events.DeviceEvent, 16)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer cancel() // stop polling mounts when the uevent stream ends
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := stream.Read(buf)
+			if err != nil {
+				if ctx.Err() == nil && err != io.EOF {
+					logger.ErrorCF("devices", "Block uevent read error", map[string]interface{}{"error": err.Error()})
+				}
+				return
+			}
+			if ev := m.parseBlockEvent(parseUevent(buf[:n])); ev != nil {
+				if !emit(ctx, eventCh, ev) {
+					return
+				}
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		m.pollMounts(ctx, mounts, eventCh)
+	}()
+	go func() {
+		wg.Wait()
+		close(eventCh)
+	}()
+
+	return eventCh, nil
+}
+
+func (m *BlockMonitor) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	if m.stream != nil {
+		m.stream.Close()
+		m.stream = nil
+	}
+	return nil
+}
+
+// ignoredBlockDevice reports virtual block devices that come and go on
+// their own (loop devices for snaps, RAM disks, device-mapper).
+func ignoredBlockDevice(name string) bool {
+	for _, prefix := range []string{"loop", "ram", "zram", "dm-", "nbd"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *BlockMonitor) parseBlockEvent(props map[string]string) *events.DeviceEvent {
+	if props == nil || props["SUBSYSTEM"] != "block" {
+		return nil
+	}
+	name := props["DEVNAME"]
+	if name == "" || ignoredBlockDevice(name) {
+		return nil
+	}
+
+	ev := &events.DeviceEvent{
+		Kind:     events.KindBlock,
+		DeviceID: "/dev/" + strings.TrimPrefix(name, "/dev/"),
+		Raw:      props,
+	}
+	switch props["ACTION"] {
+	case "add":
+		ev.Action = events.ActionAdd
+		ev.Capabilities = "Storage attached"
+	case "remove":
+		ev.Action = events.ActionRemove
+		ev.Capabilities = "Storage removed"
+	case "change":
+		// Card readers report inserted and ejected media as a change on
+		// the disk.
+		if props["DISK_MEDIA_CHANGE"] != "1" {
+			return nil
+		}
+		ev.Action = events.ActionChange
+		ev.Capabilities = "Media changed"
+	default:
+		return nil
+	}
+
+	// Vendor and model live on the disk's device; a partition's DEVPATH
+	// is one level below its disk.
+	devpath := props["DEVPATH"]
+	for _, dir := range []string{devpath, devpath + "/.."} {
+		if ev.Vendor == "" {
+			ev.Vendor = readSysfs(m.sysfsRoot, dir, "device", "vendor")
+		}
+		if ev.Product == "" {
+			ev.Product = readSysfs(m.sysfsRoot, dir, "device", "model")
+		}
+	}
+	if ev.Product == "" {
+		ev.Product = ev.DeviceID
+	}
+	if props["DEVTYPE"] == "partition" {
+		ev.Capabilities += ", partition " + props["PARTN"]
+	}
+	if sectors, err := strconv.ParseInt(readSysfs(m.sysfsRoot, devpath, "size"), 10, 64); err == nil && sectors > 0 {
+		ev.Capabilities += fmt.Sprintf(", %.1f GB", float64(sectors)*512/1e9)
+	}
+	return ev
+}
+
+// pollMounts compares the mount table with the last one every
+// pollInterval and reports block devices that were mounted or unmounted.
+func (m *BlockMonitor) pollMounts(ctx context.Context, last map[string]mountEntry, eventCh chan<- *events.DeviceEvent) {
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current, err := m.readMounts()
+		if err != nil {
+			continue
+		}
+		for _, ev := range diffMounts(last, current) {
+			if !emit(ctx, eventCh, ev) {
+				return
+			}
+		}
+		last = current
+	}
+}
+
+func diffMounts(before, after map[string]mountEntry) []*events.DeviceEvent {
+	var evs []*events.DeviceEvent
+	mountEvent := func(dev string, e mountEntry, mounted bool) *events.DeviceEvent {
+		what := "Mounted at "
+		if !mounted {
+			what = "Unmounted from "
+		}
+		return &events.DeviceEvent{
+			Action:       events.ActionChange,
+			Kind:         events.KindBlock,
+			DeviceID:     dev,
+			Product:      dev,
+			Capabilities: what + e.MountPoint + " (" + e.FSType + ")",
+			Raw: map[string]string{
+				"DEVNAME":    dev,
+				"MOUNTPOINT": e.MountPoint,
+				"FSTYPE":     e.FSType,
+				"MOUNTED":    strconv.FormatBool(mounted),
+			},
+		}
+	}
+	for dev, e := range after {
+		if prev, ok := before[dev]; !ok || prev.MountPoint != e.MountPoint {
+			evs = append(evs, mountEvent(dev, e, true))
+		}
+	}
+	for dev, e := range before {
+		if _, ok := after[dev]; !ok {
+			evs = append(evs, mountEvent(dev, e, false))
+		}
+	}
+	return evs
+}
+
+// readProcMounts returns the mounted block devices from /proc/self/mounts,
+// keyed by device path. A device mounted twice keeps its first mount point.
+func readProcMounts() (map[string]mountEntry, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMounts(f), nil
+}
+
+var mountEscapes = strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+
+func parseMounts(r io.Reader) map[string]mountEntry {
+	mounts := make(map[string]mountEntry)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || !strings.HasPrefix(fields[0], "/dev/") || ignoredBlockDevice(strings.TrimPrefix(fields[0], "/dev/")) {
+			continue
+		}
+		if _, seen := mounts[fields[0]]; seen {
+			continue
+		}
+		mounts[fields[0]] = mountEntry{MountPoint: mountEscapes.Replace(fields[1]), FSType: fields[2]}
+	}
+	return mounts
+}
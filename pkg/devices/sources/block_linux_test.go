@@ -0,0 +1,99 @@
+//go:build linux
+
+package sources
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+)
+
+func uevent(action, devpath string, props ...string) []byte {
+	return []byte(action + "@" + devpath + "\x00ACTION=" + action + "\x00DEVPATH=" + devpath + "\x00" + strings.Join(props, "\x00") + "\x00")
+}
+
+func TestBlockMonitor_Uevents(t *testing.T) {
+	sysfs := t.TempDir()
+	disk := filepath.Join(sysfs, "devices/usb1/block/sdb")
+	os.MkdirAll(filepath.Join(disk, "device"), 0755)
+	os.MkdirAll(filepath.Join(disk, "sdb1"), 0755)
+	os.WriteFile(filepath.Join(disk, "device", "vendor"), []byte("SanDisk \n"), 0644)
+	os.WriteFile(filepath.Join(disk, "device", "model"), []byte("Ultra\n"), 0644)
+	os.WriteFile(filepath.Join(disk, "sdb1", "size"), []byte("62521344\n"), 0644)
+
+	stream := &datagrams{msgs: [][]byte{
+		uevent("add", "/devices/usb1/block/sdb/sdb1", "SUBSYSTEM=block", "DEVNAME=sdb1", "DEVTYPE=partition", "PARTN=1"),
+		uevent("add", "/devices/virtual/block/loop3", "SUBSYSTEM=block", "DEVNAME=loop3", "DEVTYPE=disk"),
+		uevent("change", "/devices/usb1/block/sdb", "SUBSYSTEM=block", "DEVNAME=sdb", "DEVTYPE=disk"),
+		uevent("change", "/devices/mmc0/block/mmcblk0", "SUBSYSTEM=block", "DEVNAME=mmcblk0", "DISK_MEDIA_CHANGE=1"),
+		uevent("add", "/devices/usb1/tty/ttyUSB0", "SUBSYSTEM=tty", "DEVNAME=ttyUSB0"),
+		uevent("remove", "/devices/usb1/block/sdb/sdb1", "SUBSYSTEM=block", "DEVNAME=sdb1", "DEVTYPE=partition", "PARTN=1"),
+	}}
+	m := NewBlockMonitor()
+	m.openUevents = fakeStream(stream)
+	m.readMounts = func() (map[string]mountEntry, error) { return nil, nil }
+	m.pollInterval = time.Hour
+	m.sysfsRoot = sysfs
+
+	evs := collect(t, m)
+	if len(evs) != 3 {
+		t.Fatalf("got %d events, want 3: %+v", len(evs), evs)
+	}
+	add := evs[0]
+	if add.Action != events.ActionAdd || add.Kind != events.KindBlock || add.DeviceID != "/dev/sdb1" {
+		t.Errorf("add event = %+v", add)
+	}
+	if add.Vendor != "SanDisk" || add.Product != "Ultra" || add.Capabilities != "Storage attached, partition 1, 32.0 GB" {
+		t.Errorf("add event details = %q %q %q", add.Vendor, add.Product, add.Capabilities)
+	}
+	if evs[1].Action != events.ActionChange || evs[1].DeviceID != "/dev/mmcblk0" {
+		t.Errorf("media change event = %+v", evs[1])
+	}
+	if evs[2].Action != events.ActionRemove {
+		t.Errorf("remove event = %+v", evs[2])
+	}
+}
+
+func TestBlockMonitor_Mounts(t *testing.T) {
+	tables := []string{
+		"/dev/mmcblk0p2 / ext4 rw 0 0\nproc /proc proc rw 0 0\n",
+		"/dev/mmcblk0p2 / ext4 rw 0 0\n/dev/sda1 /media/My\\040Stick vfat rw 0 0\n/dev/loop0 /snap/core squashfs ro 0 0\n",
+		"/dev/mmcblk0p2 / ext4 rw 0 0\n",
+	}
+	calls := 0
+	uevents, w := io.Pipe() // no uevents, but the stream stays open
+	defer w.Close()
+	m := NewBlockMonitor()
+	m.openUevents = fakeStream(uevents)
+	m.pollInterval = time.Millisecond
+	m.readMounts = func() (map[string]mountEntry, error) {
+		table := tables[min(calls, len(tables)-1)]
+		calls++
+		return parseMounts(strings.NewReader(table)), nil
+	}
+
+	ch, err := m.Start(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case ev := <-ch:
+			got = append(got, ev.Raw["DEVNAME"]+" "+ev.Capabilities)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %v", got)
+		}
+	}
+	want := []string{"/dev/sda1 Mounted at /media/My Stick (vfat)", "/dev/sda1 Unmounted from /media/My Stick (vfat)"}
+	if got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("mount events = %q, want %q", got, want)
+	}
+}
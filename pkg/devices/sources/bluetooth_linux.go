@@ -0,0 +1,180 @@
+//go:build linux
+
+package sources
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// bluezMatch selects the BlueZ property changes that carry Connected.
+const bluezMatch = "type='signal',sender='org.bluez',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged'"
+
+// BluetoothMonitor reports Bluetooth devices connecting and disconnecting
+// by following BlueZ's PropertiesChanged signals on the system D-Bus with
+// dbus-monitor.
+type BluetoothMonitor struct {
+	openSignals streamOpener
+
+	stream io.Closer
+	cancel context.CancelFunc
+	mu     sync.Mutex
+}
+
+func NewBluetoothMonitor() *BluetoothMonitor {
+	return &BluetoothMonitor{
+		openSignals: func(ctx context.Context) (io.ReadCloser, error) {
+			return startCommandStream(ctx, "dbus-monitor", "--system", bluezMatch)
+		},
+	}
+}
+
+func (m *BluetoothMonitor) Kind() events.Kind {
+	return events.KindBluetooth
+}
+
+func (m *BluetoothMonitor) Start(ctx context.Context) (<-chan *events.DeviceEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream, err := m.openSignals(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("bluez signals: %w", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	m.stream, m.cancel = stream, cancel
+	context.AfterFunc(ctx, func() { stream.Close() })
+
+	eventCh := make(chan *events.DeviceEvent, 16)
+	go func() {
+		defer close(eventCh)
+		p := &bluezParser{names: make(map[string]string)}
+		scanner := bufio.NewScanner(stream)
+		for scanner.Scan() {
+			if ev := p.parseLine(scanner.Text()); ev != nil {
+				if !emit(ctx, eventCh, ev) {
+					return
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			logger.ErrorCF("devices", "dbus-monitor scan error", map[string]interface{}{"error": err.Error()})
+		}
+	}()
+
+	return eventCh, nil
+}
+
+func (m *BluetoothMonitor) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	if m.stream != nil {
+		m.stream.Close()
+		m.stream = nil
+	}
+	return nil
+}
+
+// bluezParser follows dbus-monitor's text output, which prints a
+// "signal ... path=...;" header line followed by the indented arguments:
+//
+//	string "org.bluez.Device1"
+//	array [
+//	   dict entry(
+//	      string "Connected"
+//	      variant             boolean true
+//	   )
+//	]
+type bluezParser struct {
+	path  string
+	iface string
+	key   string
+	names map[string]string // device path -> last seen Alias or Name
+}
+
+func (p *bluezParser) parseLine(line string) *events.DeviceEvent {
+	if strings.HasPrefix(line, "signal ") {
+		p.path, p.iface, p.key = "", "", ""
+		if _, rest, ok := strings.Cut(line, " path="); ok {
+			p.path, _, _ = strings.Cut(rest, ";")
+		}
+		return nil
+	}
+	if p.path == "" {
+		return nil
+	}
+
+	text := strings.TrimSpace(line)
+	if value, ok := strings.CutPrefix(text, "string "); ok {
+		value = strings.Trim(value, `"`)
+		if p.iface == "" {
+			p.iface = value
+		} else {
+			p.key = value
+		}
+		return nil
+	}
+	if p.iface != "org.bluez.Device1" || !strings.HasPrefix(text, "variant ") {
+		return nil
+	}
+	key := p.key
+	p.key = ""
+	value := strings.TrimSpace(strings.TrimPrefix(text, "variant "))
+	switch key {
+	case "Alias", "Name":
+		if s, ok := strings.CutPrefix(value, "string "); ok {
+			p.names[p.path] = strings.Trim(s, `"`)
+		}
+		return nil
+	case "Connected":
+	default:
+		return nil
+	}
+
+	connected := strings.HasSuffix(value, "true")
+	address, adapter := bluezAddress(p.path)
+	ev := &events.DeviceEvent{
+		Kind:         events.KindBluetooth,
+		DeviceID:     address,
+		Product:      p.names[p.path],
+		Capabilities: "Bluetooth device",
+		Raw: map[string]string{
+			"PATH":    p.path,
+			"ADDRESS": address,
+			"ADAPTER": adapter,
+		},
+	}
+	if ev.Product == "" {
+		ev.Product = address
+	}
+	if connected {
+		ev.Action = events.ActionAdd
+	} else {
+		ev.Action = events.ActionRemove
+	}
+	return ev
+}
+
+// bluezAddress turns "/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF" into the
+// device address "AA:BB:CC:DD:EE:FF" and adapter "hci0".
+func bluezAddress(path string) (address, adapter string) {
+	parts := strings.Split(strings.TrimPrefix(path, "/org/bluez/"), "/")
+	if len(parts) > 0 {
+		adapter = parts[0]
+	}
+	if len(parts) > 1 {
+		address = strings.ReplaceAll(strings.TrimPrefix(parts[1], "dev_"), "_", ":")
+	}
+	return address, adapter
+}
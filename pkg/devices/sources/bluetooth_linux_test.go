@@ -0,0 +1,71 @@
+//go:build linux
+
+package sources
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+)
+
+const dbusMonitorOutput = `signal time=1700000000.000001 sender=org.freedesktop.DBus -> destination=:1.42 serial=2 path=/org/freedesktop/DBus; interface=org.freedesktop.DBus; member=NameAcquired
+   string ":1.42"
+signal time=1700000001.000002 sender=:1.5 -> destination=(null destination) serial=120 path=/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF; interface=org.freedesktop.DBus.Properties; member=PropertiesChanged
+   string "org.bluez.Device1"
+   array [
+      dict entry(
+         string "Alias"
+         variant             string "Pixel Buds"
+      )
+      dict entry(
+         string "Connected"
+         variant             boolean true
+      )
+   ]
+   array [
+   ]
+signal time=1700000002.000003 sender=:1.5 -> destination=(null destination) serial=121 path=/org/bluez/hci0; interface=org.freedesktop.DBus.Properties; member=PropertiesChanged
+   string "org.bluez.Adapter1"
+   array [
+      dict entry(
+         string "Discovering"
+         variant             boolean true
+      )
+   ]
+   array [
+   ]
+signal time=1700000003.000004 sender=:1.5 -> destination=(null destination) serial=122 path=/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF; interface=org.freedesktop.DBus.Properties; member=PropertiesChanged
+   string "org.bluez.Device1"
+   array [
+      dict entry(
+         string "ServicesResolved"
+         variant             boolean false
+      )
+      dict entry(
+         string "Connected"
+         variant             boolean false
+      )
+   ]
+   array [
+   ]
+`
+
+func TestBluetoothMonitor_Signals(t *testing.T) {
+	m := NewBluetoothMonitor()
+	m.openSignals = fakeStream(io.NopCloser(strings.NewReader(dbusMonitorOutput)))
+
+	evs := collect(t, m)
+	if len(evs) != 2 {
+		t.Fatalf("got %d events, want 2: %+v", len(evs), evs)
+	}
+	connected, disconnected := evs[0], evs[1]
+	if connected.Action != events.ActionAdd || connected.Kind != events.KindBluetooth ||
+		connected.DeviceID != "AA:BB:CC:DD:EE:FF" || connected.Product != "Pixel Buds" || connected.Raw["ADAPTER"] != "hci0" {
+		t.Errorf("connect event = %+v", connected)
+	}
+	if disconnected.Action != events.ActionRemove || disconnected.Product != "Pixel Buds" {
+		t.Errorf("disconnect event = %+v", disconnected)
+	}
+}
@@ -0,0 +1,192 @@
+//go:build linux
+
+package sources
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+	"github.com/sipeed/picoclaw/pkg/devices/gpio"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// GPIOLine is an input line watched for edges.
+type GPIOLine struct {
+	Chip       string // "gpiochip0" or a path such as "/dev/gpiochip0"
+	Offset     int
+	Name       string // shown in events; defaults to "<chip> line <offset>"
+	Edge       string // "rising", "falling" or "both" (default)
+	Bias       string // "pull_up", "pull_down", "disable" or "" to leave as is
+	DebounceMs int
+}
+
+func (l GPIOLine) label() string {
+	if l.Name != "" {
+		return l.Name
+	}
+	return fmt.Sprintf("%s line %d", chipName(l.Chip), l.Offset)
+}
+
+// GPIOMonitor reports edges on GPIO input lines through the GPIO
+// character device, one line request per chip.
+type GPIOMonitor struct {
+	lines    []GPIOLine
+	openChip func(chip string, lines []GPIOLine) (io.ReadCloser, error)
+
+	streams []io.Closer
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+}
+
+func NewGPIOMonitor(lines []GPIOLine) *GPIOMonitor {
+	return &GPIOMonitor{lines: lines, openChip: requestGPIOLines}
+}
+
+func (m *GPIOMonitor) Kind() events.Kind {
+	return events.KindGPIO
+}
+
+func (m *GPIOMonitor) Start(ctx context.Context) (<-chan *events.DeviceEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var chips []string
+	byChip := make(map[string][]GPIOLine)
+	for _, l := range m.lines {
+		chip := chipName(l.Chip)
+		if _, ok := byChip[chip]; !ok {
+			chips = append(chips, chip)
+		}
+		byChip[chip] = append(byChip[chip], l)
+	}
+	if len(chips) == 0 {
+		return nil, fmt.Errorf("no GPIO lines configured")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	m.cancel = cancel
+	eventCh := make(chan *events.DeviceEvent, 16)
+	var wg sync.WaitGroup
+	for _, chip := range chips {
+		stream, err := m.openChip(chip, byChip[chip])
+		if err != nil {
+			cancel()
+			for _, s := range m.streams {
+				s.Close()
+			}
+			m.streams = nil
+			return nil, fmt.Errorf("gpio %s: %w", chip, err)
+		}
+		m.streams = append(m.streams, stream)
+		context.AfterFunc(ctx, func() { stream.Close() })
+
+		wg.Add(1)
+		go func(chip string, lines []GPIOLine) {
+			defer wg.Done()
+			m.readEdges(ctx, chip, lines, stream, eventCh)
+		}(chip, byChip[chip])
+	}
+	go func() {
+		wg.Wait()
+		close(eventCh)
+	}()
+
+	return eventCh, nil
+}
+
+func (m *GPIOMonitor) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	for _, s := range m.streams {
+		s.Close()
+	}
+	m.streams = nil
+	return nil
+}
+
+// readEdges decodes gpio_v2_line_event records from stream.
+func (m *GPIOMonitor) readEdges(ctx context.Context, chip string, lines []GPIOLine, stream io.Reader, eventCh chan<- *events.DeviceEvent) {
+	byOffset := make(map[int]GPIOLine, len(lines))
+	for _, l := range lines {
+		byOffset[l.Offset] = l
+	}
+	buf := make([]byte, 16*gpio.EdgeEventSize)
+	for {
+		n, err := io.ReadAtLeast(stream, buf, gpio.EdgeEventSize)
+		if rem := n % gpio.EdgeEventSize; err == nil && rem != 0 {
+			// Complete the last record.
+			var k int
+			k, err = io.ReadFull(stream, buf[n:n+gpio.EdgeEventSize-rem])
+			n += k
+		}
+		if err != nil {
+			if ctx.Err() == nil && err != io.EOF {
+				logger.ErrorCF("devices", "GPIO read error", map[string]interface{}{"chip": chip, "error": err.Error()})
+			}
+			return
+		}
+		for _, edge := range gpio.ParseEdgeEvents(buf[:n]) {
+			if !emit(ctx, eventCh, gpioEdgeEvent(chip, byOffset, edge)) {
+				return
+			}
+		}
+	}
+}
+
+func gpioEdgeEvent(chip string, lines map[int]GPIOLine, e gpio.EdgeEvent) *events.DeviceEvent {
+	edge := e.EdgeName()
+	line, ok := lines[e.Offset]
+	if !ok {
+		line = GPIOLine{Chip: chip, Offset: e.Offset}
+	}
+	return &events.DeviceEvent{
+		Action:       events.ActionChange,
+		Kind:         events.KindGPIO,
+		DeviceID:     chip + ":" + strconv.Itoa(e.Offset),
+		Product:      line.label(),
+		Capabilities: strings.ToUpper(edge[:1]) + edge[1:] + " edge",
+		Raw: map[string]string{
+			"CHIP":         chip,
+			"LINE":         strconv.Itoa(e.Offset),
+			"EDGE":         edge,
+			"TIMESTAMP_NS": strconv.FormatUint(e.TimestampNs, 10),
+			"SEQNO":        strconv.FormatUint(uint64(e.Seqno), 10),
+		},
+	}
+}
+
+// chipName returns the device name of a chip given as a name or path.
+func chipName(chip string) string {
+	if chip == "" {
+		return "gpiochip0"
+	}
+	return strings.TrimPrefix(chip, "/dev/")
+}
+
+// lineConfigs turns watched lines into edge-detecting input requests.
+func lineConfigs(lines []GPIOLine) []gpio.LineConfig {
+	configs := make([]gpio.LineConfig, len(lines))
+	for i, l := range lines {
+		edge := l.Edge
+		if edge == "" {
+			edge = "both"
+		}
+		configs[i] = gpio.LineConfig{Offset: l.Offset, Edge: edge, Bias: l.Bias, DebounceMs: l.DebounceMs}
+	}
+	return configs
+}
+
+// requestGPIOLines requests lines as edge-detecting inputs and returns the
+// line request, from which edge events are read.
+func requestGPIOLines(chip string, lines []GPIOLine) (io.ReadCloser, error) {
+	return gpio.RequestLines("/dev/"+chip, "picoclaw", lineConfigs(lines))
+}
@@ -0,0 +1,63 @@
+//go:build linux
+
+package sources
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+	"github.com/sipeed/picoclaw/pkg/devices/gpio"
+)
+
+func TestLineConfigs(t *testing.T) {
+	configs := lineConfigs([]GPIOLine{{Offset: 17}, {Offset: 27, Edge: "falling", Bias: "pull_up", DebounceMs: 20}})
+	if configs[0].Edge != "both" || configs[0].Output {
+		t.Errorf("default line = %+v", configs[0])
+	}
+	if c := configs[1]; c.Edge != "falling" || c.Bias != "pull_up" || c.DebounceMs != 20 {
+		t.Errorf("configured line = %+v", c)
+	}
+}
+
+// lineEvent encodes a gpio_v2_line_event; id 1 is a rising edge, 2 falling.
+func lineEvent(id, offset, seqno uint32, timestamp uint64) []byte {
+	rec := make([]byte, gpio.EdgeEventSize)
+	binary.NativeEndian.PutUint64(rec[0:8], timestamp)
+	binary.NativeEndian.PutUint32(rec[8:12], id)
+	binary.NativeEndian.PutUint32(rec[12:16], offset)
+	binary.NativeEndian.PutUint32(rec[16:20], seqno)
+	return rec
+}
+
+func TestGPIOMonitor_Edges(t *testing.T) {
+	var requested []GPIOLine
+	m := NewGPIOMonitor([]GPIOLine{{Chip: "/dev/gpiochip0", Offset: 17, Name: "doorbell"}, {Offset: 27}})
+	m.openChip = func(chip string, lines []GPIOLine) (io.ReadCloser, error) {
+		if chip != "gpiochip0" {
+			t.Errorf("chip = %q", chip)
+		}
+		requested = lines
+		var buf bytes.Buffer
+		buf.Write(lineEvent(1, 17, 1, 1000))
+		buf.Write(lineEvent(2, 27, 2, 2000))
+		return io.NopCloser(&buf), nil
+	}
+
+	evs := collect(t, m)
+	if len(requested) != 2 {
+		t.Errorf("requested %d lines on one chip, want 2", len(requested))
+	}
+	if len(evs) != 2 {
+		t.Fatalf("got %d events, want 2", len(evs))
+	}
+	if ev := evs[0]; ev.Kind != events.KindGPIO || ev.Action != events.ActionChange || ev.DeviceID != "gpiochip0:17" ||
+		ev.Product != "doorbell" || ev.Capabilities != "Rising edge" || ev.Raw["TIMESTAMP_NS"] != "1000" {
+		t.Errorf("first edge = %+v", ev)
+	}
+	if ev := evs[1]; ev.Product != "gpiochip0 line 27" || ev.Raw["EDGE"] != "falling" {
+		t.Errorf("second edge = %+v", ev)
+	}
+}
@@ -0,0 +1,215 @@
+//go:build linux
+
+package sources
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ifOperUp is IF_OPER_UP from RFC 2863 as reported in IFLA_OPERSTATE.
+const ifOperUp = 6
+
+// NetworkMonitor reports network interfaces going up or down, including
+// Wi-Fi interfaces associating with or leaving an access point, from
+// rtnetlink link messages.
+type NetworkMonitor struct {
+	openLinks  streamOpener
+	initial    func() map[int]linkState
+	isWireless func(name string) bool
+
+	stream io.Closer
+	cancel context.CancelFunc
+	mu     sync.Mutex
+}
+
+// linkState is what the monitor tracks per interface index.
+type linkState struct {
+	Name string
+	Up   bool
+}
+
+func NewNetworkMonitor() *NetworkMonitor {
+	return &NetworkMonitor{
+		openLinks: func(ctx context.Context) (io.ReadCloser, error) {
+			return openNetlink(syscall.NETLINK_ROUTE, rtnlLinkGroup)
+		},
+		initial: currentLinks,
+		isWireless: func(name string) bool {
+			_, err := os.Stat(filepath.Join("/sys/class/net", name, "wireless"))
+			return err == nil
+		},
+	}
+}
+
+func (m *NetworkMonitor) Kind() events.Kind {
+	return events.KindNetwork
+}
+
+func (m *NetworkMonitor) Start(ctx context.Context) (<-chan *events.DeviceEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream, err := m.openLinks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("rtnetlink: %w", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	m.stream, m.cancel = stream, cancel
+	context.AfterFunc(ctx, func() { stream.Close() })
+
+	links := m.initial()
+	eventCh := make(chan *events.DeviceEvent, 16)
+	go func() {
+		defer close(eventCh)
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := stream.Read(buf)
+			if err != nil {
+				if ctx.Err() == nil && err != io.EOF {
+					logger.ErrorCF("devices", "rtnetlink read error", map[string]interface{}{"error": err.Error()})
+				}
+				return
+			}
+			msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			for i := range msgs {
+				if ev := m.linkEvent(links, &msgs[i]); ev != nil {
+					if !emit(ctx, eventCh, ev) {
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return eventCh, nil
+}
+
+func (m *NetworkMonitor) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	if m.stream != nil {
+		m.stream.Close()
+		m.stream = nil
+	}
+	return nil
+}
+
+// linkEvent updates links from an RTM_NEWLINK or RTM_DELLINK message and
+// returns an event when an interface went up or down.
+func (m *NetworkMonitor) linkEvent(links map[int]linkState, msg *syscall.NetlinkMessage) *events.DeviceEvent {
+	if msg.Header.Type != syscall.RTM_NEWLINK && msg.Header.Type != syscall.RTM_DELLINK {
+		return nil
+	}
+	if len(msg.Data) < syscall.SizeofIfInfomsg {
+		return nil
+	}
+	info := (*syscall.IfInfomsg)(unsafe.Pointer(&msg.Data[0]))
+	if info.Flags&syscall.IFF_LOOPBACK != 0 {
+		return nil
+	}
+	attrs, err := syscall.ParseNetlinkRouteAttr(msg)
+	if err != nil {
+		return nil
+	}
+
+	index := int(info.Index)
+	state := linkState{Name: links[index].Name}
+	operState := -1
+	for _, a := range attrs {
+		switch a.Attr.Type {
+		case syscall.IFLA_IFNAME:
+			state.Name = string(trimNul(a.Value))
+		case syscall.IFLA_OPERSTATE:
+			if len(a.Value) > 0 {
+				operState = int(a.Value[0])
+			}
+		}
+	}
+	if state.Name == "" {
+		return nil
+	}
+	if operState >= 0 {
+		state.Up = operState == ifOperUp
+	} else {
+		state.Up = info.Flags&syscall.IFF_RUNNING != 0
+	}
+	if msg.Header.Type == syscall.RTM_DELLINK {
+		state.Up = false
+	}
+
+	prev, known := links[index]
+	if msg.Header.Type == syscall.RTM_DELLINK {
+		delete(links, index)
+	} else {
+		links[index] = state
+	}
+	if (known && prev.Up == state.Up) || (!known && !state.Up) {
+		return nil
+	}
+
+	wireless := m.isWireless(state.Name)
+	ev := &events.DeviceEvent{
+		Kind:     events.KindNetwork,
+		DeviceID: state.Name,
+		Product:  state.Name,
+		Raw: map[string]string{
+			"IFNAME":   state.Name,
+			"IFINDEX":  strconv.Itoa(index),
+			"UP":       strconv.FormatBool(state.Up),
+			"WIRELESS": strconv.FormatBool(wireless),
+		},
+	}
+	switch {
+	case state.Up && wireless:
+		ev.Action, ev.Capabilities = events.ActionAdd, "Wi-Fi associated"
+	case state.Up:
+		ev.Action, ev.Capabilities = events.ActionAdd, "Network link up"
+	case wireless:
+		ev.Action, ev.Capabilities = events.ActionRemove, "Wi-Fi disassociated"
+	default:
+		ev.Action, ev.Capabilities = events.ActionRemove, "Network link down"
+	}
+	return ev
+}
+
+// currentLinks returns the state of every interface when the monitor
+// starts, so only later changes are reported.
+func currentLinks() map[int]linkState {
+	links := make(map[int]linkState)
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return links
+	}
+	for _, iface := range ifaces {
+		links[iface.Index] = linkState{Name: iface.Name, Up: iface.Flags&net.FlagRunning != 0}
+	}
+	return links
+}
+
+func trimNul(b []byte) []byte {
+	for i, c := range b {
+		if c == 0 {
+			return b[:i]
+		}
+	}
+	return b
+}
@@ -0,0 +1,81 @@
+//go:build linux
+
+package sources
+
+import (
+	"encoding/binary"
+	"syscall"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+)
+
+// linkMessage builds an rtnetlink link message with IFLA_IFNAME and
+// IFLA_OPERSTATE attributes.
+func linkMessage(msgType uint16, index int32, name string, operState byte) []byte {
+	attr := func(typ uint16, value []byte) []byte {
+		b := make([]byte, 4, 4+len(value)+3)
+		binary.NativeEndian.PutUint16(b[0:2], uint16(4+len(value)))
+		binary.NativeEndian.PutUint16(b[2:4], typ)
+		b = append(b, value...)
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		return b
+	}
+
+	body := make([]byte, syscall.SizeofIfInfomsg)
+	binary.NativeEndian.PutUint32(body[4:8], uint32(index))
+	flags := uint32(syscall.IFF_UP)
+	if operState == ifOperUp {
+		flags |= syscall.IFF_RUNNING
+	}
+	binary.NativeEndian.PutUint32(body[8:12], flags)
+	body = append(body, attr(syscall.IFLA_IFNAME, append([]byte(name), 0))...)
+	body = append(body, attr(syscall.IFLA_OPERSTATE, []byte{operState})...)
+
+	msg := make([]byte, syscall.NLMSG_HDRLEN, syscall.NLMSG_HDRLEN+len(body))
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(syscall.NLMSG_HDRLEN+len(body)))
+	binary.NativeEndian.PutUint16(msg[4:6], msgType)
+	return append(msg, body...)
+}
+
+func TestNetworkMonitor_LinkChanges(t *testing.T) {
+	const down = 2 // IF_OPER_DOWN
+	stream := &datagrams{msgs: [][]byte{
+		linkMessage(syscall.RTM_NEWLINK, 3, "wlan0", ifOperUp), // associated
+		linkMessage(syscall.RTM_NEWLINK, 3, "wlan0", ifOperUp), // no change
+		linkMessage(syscall.RTM_NEWLINK, 2, "eth0", down),      // already down
+		linkMessage(syscall.RTM_NEWLINK, 2, "eth0", ifOperUp),  // cable plugged in
+		linkMessage(syscall.RTM_NEWLINK, 3, "wlan0", down),     // left the access point
+		linkMessage(syscall.RTM_DELLINK, 2, "eth0", down),      // USB adapter removed
+	}}
+	m := NewNetworkMonitor()
+	m.openLinks = fakeStream(stream)
+	m.initial = func() map[int]linkState {
+		return map[int]linkState{2: {Name: "eth0"}, 3: {Name: "wlan0"}}
+	}
+	m.isWireless = func(name string) bool { return name == "wlan0" }
+
+	var got []string
+	for _, ev := range collect(t, m) {
+		if ev.Kind != events.KindNetwork {
+			t.Errorf("Kind = %q", ev.Kind)
+		}
+		got = append(got, string(ev.Action)+" "+ev.DeviceID+" "+ev.Capabilities)
+	}
+	want := []string{
+		"add wlan0 Wi-Fi associated",
+		"add eth0 Network link up",
+		"remove wlan0 Wi-Fi disassociated",
+		"remove eth0 Network link down",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
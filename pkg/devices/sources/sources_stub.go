@@ -0,0 +1,59 @@
+//go:build !linux
+
+package sources
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+)
+
+// GPIOLine is an input line watched for edges.
+type GPIOLine struct {
+	Chip       string
+	Offset     int
+	Name       string
+	Edge       string
+	Bias       string
+	DebounceMs int
+}
+
+type BlockMonitor struct{ noEvents }
+type NetworkMonitor struct{ noEvents }
+type BluetoothMonitor struct{ noEvents }
+type GPIOMonitor struct{ noEvents }
+
+func NewBlockMonitor() *BlockMonitor {
+	return &BlockMonitor{noEvents{events.KindBlock}}
+}
+
+func NewNetworkMonitor() *NetworkMonitor {
+	return &NetworkMonitor{noEvents{events.KindNetwork}}
+}
+
+func NewBluetoothMonitor() *BluetoothMonitor {
+	return &BluetoothMonitor{noEvents{events.KindBluetooth}}
+}
+
+func NewGPIOMonitor(lines []GPIOLine) *GPIOMonitor {
+	return &GPIOMonitor{noEvents{events.KindGPIO}}
+}
+
+// noEvents is a source that never reports anything.
+type noEvents struct {
+	kind events.Kind
+}
+
+func (n noEvents) Kind() events.Kind {
+	return n.kind
+}
+
+func (n noEvents) Start(ctx context.Context) (<-chan *events.DeviceEvent, error) {
+	ch := make(chan *events.DeviceEvent)
+	close(ch) // Immediately close, no events
+	return ch, nil
+}
+
+func (n noEvents) Stop() error {
+	return nil
+}
@@ -0,0 +1,107 @@
+//go:build linux
+
+package sources
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+)
+
+// Netlink multicast groups.
+const (
+	ueventKernelGroup = 1 // NETLINK_KOBJECT_UEVENT: events straight from the kernel
+	rtnlLinkGroup     = 1 // RTMGRP_LINK: interface changes
+)
+
+// streamOpener opens the raw stream a source reads events from. Sources
+// keep it in a field so tests can inject a fake stream.
+type streamOpener func(ctx context.Context) (io.ReadCloser, error)
+
+// openNetlink opens a netlink socket subscribed to groups. Each Read
+// returns one datagram. The socket is non-blocking so Close interrupts a
+// pending Read.
+func openNetlink(protocol int, groups uint32) (io.ReadCloser, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, protocol)
+	if err != nil {
+		return nil, fmt.Errorf("netlink socket: %w", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("netlink bind: %w", err)
+	}
+	return os.NewFile(uintptr(fd), "netlink"), nil
+}
+
+// commandStream runs a command and streams its standard output. Closing
+// the stream kills the command.
+type commandStream struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func startCommandStream(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("%s stdout pipe: %w", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%s start: %w (is %s installed?)", name, err, name)
+	}
+	return &commandStream{ReadCloser: stdout, cmd: cmd}, nil
+}
+
+func (s *commandStream) Close() error {
+	if s.cmd.Process != nil {
+		s.cmd.Process.Kill()
+	}
+	s.ReadCloser.Close()
+	return s.cmd.Wait()
+}
+
+// emit sends ev on ch unless ctx is done first.
+func emit(ctx context.Context, ch chan<- *events.DeviceEvent, ev *events.DeviceEvent) bool {
+	select {
+	case ch <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// parseUevent splits a kernel uevent datagram ("action@devpath" followed
+// by NUL-separated KEY=value pairs) into its properties. Messages
+// re-broadcast by udev start with "libudev" and are ignored.
+func parseUevent(msg []byte) map[string]string {
+	fields := strings.Split(strings.TrimRight(string(msg), "\x00"), "\x00")
+	if len(fields) < 2 || !strings.Contains(fields[0], "@") {
+		return nil
+	}
+	props := make(map[string]string, len(fields)-1)
+	for _, f := range fields[1:] {
+		if k, v, ok := strings.Cut(f, "="); ok {
+			props[k] = v
+		}
+	}
+	if props["ACTION"] == "" {
+		return nil
+	}
+	return props
+}
+
+// readSysfs returns the trimmed content of a sysfs attribute, or "".
+func readSysfs(parts ...string) string {
+	data, err := os.ReadFile(filepath.Join(parts...))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,66 @@
+//go:build linux
+
+package sources
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+)
+
+// datagrams is a fake netlink stream returning one message per Read.
+type datagrams struct {
+	msgs [][]byte
+}
+
+func (d *datagrams) Read(p []byte) (int, error) {
+	if len(d.msgs) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, d.msgs[0])
+	d.msgs = d.msgs[1:]
+	return n, nil
+}
+
+func (d *datagrams) Close() error { return nil }
+
+func fakeStream(r io.ReadCloser) streamOpener {
+	return func(ctx context.Context) (io.ReadCloser, error) { return r, nil }
+}
+
+// collect reads events until the source closes its channel.
+func collect(t *testing.T, src events.EventSource) []*events.DeviceEvent {
+	t.Helper()
+	ch, err := src.Start(context.Background())
+	if err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer src.Stop()
+
+	var evs []*events.DeviceEvent
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return evs
+			}
+			evs = append(evs, ev)
+		case <-timeout:
+			t.Fatalf("source did not finish; got %d events", len(evs))
+		}
+	}
+}
+
+func TestParseUevent(t *testing.T) {
+	props := parseUevent([]byte("add@/devices/x/block/sdb\x00ACTION=add\x00SUBSYSTEM=block\x00DEVNAME=sdb\x00"))
+	if props["ACTION"] != "add" || props["DEVNAME"] != "sdb" {
+		t.Errorf("props = %v", props)
+	}
+	if parseUevent([]byte("libudev\x00\xfe\xed")) != nil {
+		t.Error("udev broadcast should be ignored")
+	}
+}
@@ -0,0 +1,112 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Outcome statuses recorded in the heartbeat history.
+const (
+	StatusOK        = "ok"        // nothing needed attention
+	StatusAlert     = "alert"     // a result was delivered
+	StatusDuplicate = "duplicate" // same result as the last alert, not re-sent
+	StatusError     = "error"
+	StatusAsync     = "async" // work handed off to a background task
+	StatusQuiet     = "quiet" // due during quiet hours, skipped
+)
+
+const defaultHistoryLimit = 100
+
+// Outcome is one recorded heartbeat task run.
+type Outcome struct {
+	Task    string `json:"task"`
+	AtMS    int64  `json:"atMs"`
+	Status  string `json:"status"`
+	Summary string `json:"summary,omitempty"`
+	Channel string `json:"channel,omitempty"` // channel:chat the result was sent to
+}
+
+// Time returns when the outcome was recorded.
+func (o Outcome) Time() time.Time {
+	return time.UnixMilli(o.AtMS)
+}
+
+// taskState is what the service remembers about a task between runs.
+type taskState struct {
+	LastRunMS   int64  `json:"lastRunMs,omitempty"`
+	LastAlertMS int64  `json:"lastAlertMs,omitempty"`
+	LastAlert   string `json:"lastAlertHash,omitempty"`
+	QuietLogged bool   `json:"quietLogged,omitempty"` // a quiet skip was recorded for the current window
+}
+
+type storeFile struct {
+	Tasks   map[string]*taskState `json:"tasks"`
+	History []Outcome             `json:"history"`
+}
+
+func storePath(workspace string) string {
+	return filepath.Join(workspace, "state", "heartbeat.json")
+}
+
+func loadStore(workspace string) (*storeFile, error) {
+	store := &storeFile{Tasks: make(map[string]*taskState)}
+	data, err := os.ReadFile(storePath(workspace))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return store, err
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return &storeFile{Tasks: make(map[string]*taskState)}, fmt.Errorf("failed to parse heartbeat state: %w", err)
+	}
+	if store.Tasks == nil {
+		store.Tasks = make(map[string]*taskState)
+	}
+	return store, nil
+}
+
+func (s *storeFile) save(workspace string) error {
+	path := storePath(workspace)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// LoadHistory returns up to limit of the most recent heartbeat outcomes for
+// the workspace, newest first. limit <= 0 returns all recorded outcomes.
+func LoadHistory(workspace string, limit int) ([]Outcome, error) {
+	store, err := loadStore(workspace)
+	if err != nil {
+		return nil, err
+	}
+	return newestFirst(store.History, limit), nil
+}
+
+func newestFirst(history []Outcome, limit int) []Outcome {
+	n := len(history)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	out := make([]Outcome, 0, n)
+	for i := len(history) - 1; i >= 0 && len(out) < n; i-- {
+		out = append(out, history[i])
+	}
+	return out
+}
@@ -7,6 +7,8 @@
 package heartbeat
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -19,28 +21,55 @@ import (
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/state"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 const (
 	minIntervalMinutes     = 5
 	defaultIntervalMinutes = 30
+	defaultDedupWindow     = 24 * time.Hour
+	checkInterval          = time.Minute
 )
 
+// OKToken is the reply that tells the heartbeat there is nothing to report.
+const OKToken = "HEARTBEAT_OK"
+
+// IsOK reports whether a heartbeat reply means nothing needs attention.
+// Models often wrap the token in prose or formatting, so it may appear
+// anywhere in the reply; an empty reply also has nothing to report.
+func IsOK(response string) bool {
+	response = strings.TrimSpace(response)
+	return response == "" || strings.Contains(response, OKToken)
+}
+
 // HeartbeatHandler is the function type for handling heartbeat.
 // It returns a ToolResult that can indicate async operations.
 // channel and chatID are derived from the last active user channel.
 type HeartbeatHandler func(prompt, channel, chatID string) *tools.ToolResult
 
+// HeartbeatTaskHandler handles a single task from HEARTBEAT.md. channel and
+// chatID are the task's target, falling back to the last active user channel.
+// When set it takes precedence over the HeartbeatHandler.
+type HeartbeatTaskHandler func(task *Task, prompt, channel, chatID string) *tools.ToolResult
+
 // HeartbeatService manages periodic heartbeat checks
 type HeartbeatService struct {
-	workspace string
-	bus       *bus.MessageBus
-	state     *state.Manager
-	handler   HeartbeatHandler
-	interval  time.Duration
-	enabled   bool
-	mu        sync.RWMutex
-	stopChan  chan struct{}
+	workspace   string
+	bus         *bus.MessageBus
+	state       *state.Manager
+	handler     HeartbeatHandler
+	taskHandler HeartbeatTaskHandler
+	interval    time.Duration
+	dedup       time.Duration
+	quiet       *QuietHours
+	enabled     bool
+	mu          sync.RWMutex
+	stopChan    chan struct{}
+
+	runMu        sync.Mutex // serializes heartbeat runs; guards store
+	store        *storeFile
+	historyLimit int
+	now          func() time.Time
 }
 
 // NewHeartbeatService creates a new heartbeat service
@@ -54,12 +83,23 @@ func NewHeartbeatService(workspace string, intervalMinutes int, enabled bool) *H
 		intervalMinutes = defaultIntervalMinutes
 	}
 
-	return &HeartbeatService{
-		workspace: workspace,
-		interval:  time.Duration(intervalMinutes) * time.Minute,
-		enabled:   enabled,
-		state:     state.NewManager(workspace),
+	hs := &HeartbeatService{
+		workspace:    workspace,
+		interval:     time.Duration(intervalMinutes) * time.Minute,
+		dedup:        defaultDedupWindow,
+		enabled:      enabled,
+		state:        state.NewManager(workspace),
+		historyLimit: defaultHistoryLimit,
+		now:          time.Now,
 	}
+
+	store, err := loadStore(workspace)
+	if err != nil {
+		hs.logError("Error loading heartbeat state: %v", err)
+	}
+	hs.store = store
+
+	return hs
 }
 
 // SetBus sets the message bus for delivering heartbeat results.
@@ -76,6 +116,29 @@ func (hs *HeartbeatService) SetHandler(handler HeartbeatHandler) {
 	hs.handler = handler
 }
 
+// SetTaskHandler sets the per-task heartbeat handler.
+func (hs *HeartbeatService) SetTaskHandler(handler HeartbeatTaskHandler) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.taskHandler = handler
+}
+
+// SetDedupWindow sets how long an identical alert from the same task is
+// suppressed. Zero or negative disables deduplication.
+func (hs *HeartbeatService) SetDedupWindow(d time.Duration) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.dedup = d
+}
+
+// SetQuietHours sets the quiet window for tasks that don't declare their own.
+// nil disables it.
+func (hs *HeartbeatService) SetQuietHours(q *QuietHours) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.quiet = q
+}
+
 // Start begins the heartbeat service
 func (hs *HeartbeatService) Start() error {
 	hs.mu.Lock()
@@ -122,9 +185,9 @@ func (hs *HeartbeatService) IsRunning() bool {
 	return hs.stopChan != nil
 }
 
-// runLoop runs the heartbeat ticker
+// runLoop checks for due tasks every minute. Each task keeps its own interval.
 func (hs *HeartbeatService) runLoop(stopChan chan struct{}) {
-	ticker := time.NewTicker(hs.interval)
+	ticker := time.NewTicker(checkInterval)
 	defer ticker.Stop()
 
 	// Run first heartbeat after initial delay
@@ -142,42 +205,101 @@ func (hs *HeartbeatService) runLoop(stopChan chan struct{}) {
 	}
 }
 
-// executeHeartbeat performs a single heartbeat check
+// executeHeartbeat runs every task from HEARTBEAT.md that is due
 func (hs *HeartbeatService) executeHeartbeat() {
 	hs.mu.RLock()
-	enabled := hs.enabled
-	handler := hs.handler
 	if !hs.enabled || hs.stopChan == nil {
 		hs.mu.RUnlock()
 		return
 	}
+	hasHandler := hs.handler != nil || hs.taskHandler != nil
 	hs.mu.RUnlock()
 
-	if !enabled {
-		return
-	}
-
-	logger.DebugC("heartbeat", "Executing heartbeat")
+	hs.runMu.Lock()
+	defer hs.runMu.Unlock()
 
-	prompt := hs.buildPrompt()
-	if prompt == "" {
-		logger.InfoC("heartbeat", "No heartbeat prompt (HEARTBEAT.md empty or missing)")
+	tasks := hs.loadTasks()
+	if len(tasks) == 0 {
+		logger.DebugC("heartbeat", "No heartbeat tasks (HEARTBEAT.md empty or missing)")
 		return
 	}
 
-	if handler == nil {
+	if !hasHandler {
 		hs.logError("Heartbeat handler not configured")
 		return
 	}
 
-	// Get last channel info for context
-	lastChannel := hs.state.GetLastChannel()
-	channel, chatID := hs.parseLastChannel(lastChannel)
+	now := hs.now()
+	ran := false
+	for _, task := range tasks {
+		if !hs.isDue(task, now) {
+			continue
+		}
+		hs.runTask(task, now)
+		ran = true
+	}
+
+	if ran {
+		if err := hs.store.save(hs.workspace); err != nil {
+			hs.logError("Error saving heartbeat state: %v", err)
+		}
+	}
+}
 
-	// Debug log for channel resolution
-	hs.logInfo("Resolved channel: %s, chatID: %s (from lastChannel: %s)", channel, chatID, lastChannel)
+// isDue reports whether task should run at now. A task that comes due during
+// its quiet hours records a single "quiet" outcome and runs once they end.
+func (hs *HeartbeatService) isDue(task *Task, now time.Time) bool {
+	st := hs.taskState(task.Name)
+	if st.LastRunMS > 0 && now.Sub(time.UnixMilli(st.LastRunMS)) < task.Interval {
+		return false
+	}
+
+	hs.mu.RLock()
+	quiet := hs.quiet
+	hs.mu.RUnlock()
+	if task.Quiet != nil {
+		quiet = task.Quiet
+	}
+
+	if quiet != nil && quiet.Contains(now) {
+		if !st.QuietLogged {
+			st.QuietLogged = true
+			hs.record(Outcome{Task: task.Name, AtMS: now.UnixMilli(), Status: StatusQuiet, Summary: "quiet hours " + quiet.String()})
+			if err := hs.store.save(hs.workspace); err != nil {
+				hs.logError("Error saving heartbeat state: %v", err)
+			}
+		}
+		return false
+	}
+	st.QuietLogged = false
+	return true
+}
+
+// runTask runs one due task and records its outcome.
+func (hs *HeartbeatService) runTask(task *Task, now time.Time) {
+	hs.mu.RLock()
+	handler := hs.handler
+	taskHandler := hs.taskHandler
+	dedup := hs.dedup
+	hs.mu.RUnlock()
+
+	logger.DebugCF("heartbeat", "Executing heartbeat task", map[string]any{"task": task.Name})
+
+	channel, chatID := hs.resolveTarget(task)
+	hs.logInfo("Task %s: resolved channel: %s, chatID: %s", task.Name, channel, chatID)
+
+	st := hs.taskState(task.Name)
+	st.LastRunMS = now.UnixMilli()
 
-	result := handler(prompt, channel, chatID)
+	prompt := hs.buildPrompt(task, now)
+	var result *tools.ToolResult
+	if taskHandler != nil {
+		result = taskHandler(task, prompt, channel, chatID)
+	} else {
+		result = handler(prompt, channel, chatID)
+	}
+
+	outcome := Outcome{Task: task.Name, AtMS: now.UnixMilli()}
 
 	if result == nil {
 		hs.logInfo("Heartbeat handler returned nil result")
@@ -187,6 +309,9 @@ func (hs *HeartbeatService) executeHeartbeat() {
 	// Handle different result types
 	if result.IsError {
 		hs.logError("Heartbeat error: %s", result.ForLLM)
+		outcome.Status = StatusError
+		outcome.Summary = result.ForLLM
+		hs.record(outcome)
 		return
 	}
 
@@ -194,64 +319,109 @@ func (hs *HeartbeatService) executeHeartbeat() {
 		hs.logInfo("Async task started: %s", result.ForLLM)
 		logger.InfoCF("heartbeat", "Async heartbeat task started",
 			map[string]interface{}{
+				"task":    task.Name,
 				"message": result.ForLLM,
 			})
+		outcome.Status = StatusAsync
+		outcome.Summary = result.ForLLM
+		hs.record(outcome)
 		return
 	}
 
 	// Check if silent
 	if result.Silent {
 		hs.logInfo("Heartbeat OK - silent")
+		outcome.Status = StatusOK
+		outcome.Summary = result.ForLLM
+		hs.record(outcome)
+		return
+	}
+
+	response := result.ForUser
+	if response == "" {
+		response = result.ForLLM
+	}
+	outcome.Summary = response
+
+	if task.Dedup != 0 {
+		dedup = task.Dedup
+	}
+	hash := alertHash(response)
+	if dedup > 0 && st.LastAlert == hash && now.Sub(time.UnixMilli(st.LastAlertMS)) < dedup {
+		hs.logInfo("Task %s: duplicate alert suppressed", task.Name)
+		outcome.Status = StatusDuplicate
+		hs.record(outcome)
 		return
 	}
 
-	// Send result to user
-	if result.ForUser != "" {
-		hs.sendResponse(result.ForUser)
-	} else if result.ForLLM != "" {
-		hs.sendResponse(result.ForLLM)
+	if response != "" && hs.sendResponse(channel, chatID, response) {
+		outcome.Channel = channel + ":" + chatID
 	}
+	st.LastAlert = hash
+	st.LastAlertMS = now.UnixMilli()
+	outcome.Status = StatusAlert
+	hs.record(outcome)
 
 	hs.logInfo("Heartbeat completed: %s", result.ForLLM)
 }
 
-// buildPrompt builds the heartbeat prompt from HEARTBEAT.md
-func (hs *HeartbeatService) buildPrompt() string {
-	heartbeatPath := filepath.Join(hs.workspace, "HEARTBEAT.md")
-
-	data, err := os.ReadFile(heartbeatPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			hs.createDefaultHeartbeatTemplate()
-			return ""
-		}
-		hs.logError("Error reading HEARTBEAT.md: %v", err)
-		return ""
+// resolveTarget returns the task's channel and chat, filling in whatever the
+// task leaves out from the last active user channel.
+func (hs *HeartbeatService) resolveTarget(task *Task) (string, string) {
+	if task.Channel != "" && task.ChatID != "" {
+		return task.Channel, task.ChatID
 	}
 
-	content := string(data)
-	if len(content) == 0 {
-		return ""
+	lastChannel := hs.state.GetLastChannel()
+	channel, chatID := hs.parseLastChannel(lastChannel)
+	switch {
+	case task.Channel == "" && task.ChatID == "":
+		return channel, chatID
+	case task.Channel == "":
+		return channel, task.ChatID
+	case task.Channel == channel:
+		return channel, chatID
 	}
+	return task.Channel, ""
+}
 
-	now := time.Now().Format("2006-01-02 15:04:05")
-	return fmt.Sprintf(`# Heartbeat Check
+func (hs *HeartbeatService) taskState(name string) *taskState {
+	st, ok := hs.store.Tasks[name]
+	if !ok {
+		st = &taskState{}
+		hs.store.Tasks[name] = st
+	}
+	return st
+}
 
-Current time: %s
+// record appends an outcome to the bounded history.
+func (hs *HeartbeatService) record(o Outcome) {
+	o.Summary = utils.Truncate(o.Summary, 200)
+	hs.store.History = append(hs.store.History, o)
+	if over := len(hs.store.History) - hs.historyLimit; over > 0 {
+		hs.store.History = append([]Outcome(nil), hs.store.History[over:]...)
+	}
+}
 
-You are a proactive AI assistant. This is a scheduled heartbeat check.
-Review the following tasks and execute any necessary actions using available skills.
-If there is nothing that requires attention, respond ONLY with: HEARTBEAT_OK
+// History returns up to limit recent outcomes, newest first.
+func (hs *HeartbeatService) History(limit int) []Outcome {
+	hs.runMu.Lock()
+	defer hs.runMu.Unlock()
 
-%s
-`, now, content)
+	return newestFirst(hs.store.History, limit)
 }
 
-// createDefaultHeartbeatTemplate creates the default HEARTBEAT.md file
-func (hs *HeartbeatService) createDefaultHeartbeatTemplate() {
-	heartbeatPath := filepath.Join(hs.workspace, "HEARTBEAT.md")
+// alertHash identifies an alert's content, ignoring whitespace and case
+// differences that don't change its meaning.
+func alertHash(s string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(s), " "))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:8])
+}
 
-	defaultContent := `# Heartbeat Check List
+// defaultHeartbeatTemplate is written to new workspaces. Left unchanged, it
+// has no tasks.
+const defaultHeartbeatTemplate = `# Heartbeat Check List
 
 This file contains tasks for the heartbeat service to check periodically.
 
@@ -261,6 +431,26 @@ This file contains tasks for the heartbeat service to check periodically.
 - Review upcoming calendar events
 - Check device status (e.g., MaixCam)
 
+## Tasks
+
+Without any "## Task:" section, this whole file is one task that runs on the
+configured heartbeat interval and reports to the last active chat.
+
+To run checks on their own schedule, add sections like:
+
+    ## Task: disk space
+    - every: 2h
+    - channel: telegram
+    - chat: 123456
+    - agent: main
+    - quiet: 22:00-07:00
+    - dedup: 12h
+
+    Check free disk space and warn me if any volume is above 90%.
+
+All option lines are optional. Text above the first task is shared by every task.
+The same alert from a task is not re-sent until its dedup window (default 24h) passes.
+
 ## Instructions
 
 - Execute ALL tasks listed below. Do NOT skip any task.
@@ -275,45 +465,81 @@ This file contains tasks for the heartbeat service to check periodically.
 Add your heartbeat tasks below this line:
 `
 
-	if err := os.WriteFile(heartbeatPath, []byte(defaultContent), 0644); err != nil {
+// loadTasks reads HEARTBEAT.md and splits it into tasks
+func (hs *HeartbeatService) loadTasks() []*Task {
+	heartbeatPath := filepath.Join(hs.workspace, "HEARTBEAT.md")
+
+	data, err := os.ReadFile(heartbeatPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			hs.createDefaultHeartbeatTemplate()
+			return nil
+		}
+		hs.logError("Error reading HEARTBEAT.md: %v", err)
+		return nil
+	}
+
+	if strings.TrimSpace(string(data)) == strings.TrimSpace(defaultHeartbeatTemplate) {
+		return nil
+	}
+	return parseTasks(string(data), hs.interval, hs.logError)
+}
+
+// buildPrompt builds the heartbeat prompt for a task
+func (hs *HeartbeatService) buildPrompt(task *Task, now time.Time) string {
+	header := fmt.Sprintf("Current time: %s", now.Format("2006-01-02 15:04:05"))
+	if task.Name != defaultTaskName {
+		header += fmt.Sprintf("\nTask: %s", task.Name)
+	}
+
+	return fmt.Sprintf(`# Heartbeat Check
+
+%s
+
+You are a proactive AI assistant. This is a scheduled heartbeat check.
+Review the following tasks and execute any necessary actions using available skills.
+If there is nothing that requires attention, respond ONLY with: HEARTBEAT_OK
+
+%s
+`, header, task.Body)
+}
+
+// createDefaultHeartbeatTemplate creates the default HEARTBEAT.md file
+func (hs *HeartbeatService) createDefaultHeartbeatTemplate() {
+	heartbeatPath := filepath.Join(hs.workspace, "HEARTBEAT.md")
+
+	if err := os.WriteFile(heartbeatPath, []byte(defaultHeartbeatTemplate), 0644); err != nil {
 		hs.logError("Failed to create default HEARTBEAT.md: %v", err)
 	} else {
 		hs.logInfo("Created default HEARTBEAT.md template")
 	}
 }
 
-// sendResponse sends the heartbeat response to the last channel
-func (hs *HeartbeatService) sendResponse(response string) {
+// sendResponse delivers a heartbeat result and reports whether it was sent
+func (hs *HeartbeatService) sendResponse(channel, chatID, response string) bool {
 	hs.mu.RLock()
 	msgBus := hs.bus
 	hs.mu.RUnlock()
 
 	if msgBus == nil {
 		hs.logInfo("No message bus configured, heartbeat result not sent")
-		return
+		return false
 	}
 
-	// Get last channel from state
-	lastChannel := hs.state.GetLastChannel()
-	if lastChannel == "" {
-		hs.logInfo("No last channel recorded, heartbeat result not sent")
-		return
-	}
-
-	platform, userID := hs.parseLastChannel(lastChannel)
-
 	// Skip internal channels that can't receive messages
-	if platform == "" || userID == "" {
-		return
+	if channel == "" || chatID == "" || constants.IsInternalChannel(channel) {
+		hs.logInfo("No target channel, heartbeat result not sent")
+		return false
 	}
 
 	msgBus.PublishOutbound(bus.OutboundMessage{
-		Channel: platform,
-		ChatID:  userID,
+		Channel: channel,
+		ChatID:  chatID,
 		Content: response,
 	})
 
-	hs.logInfo("Heartbeat result sent to %s", platform)
+	hs.logInfo("Heartbeat result sent to %s", channel)
+	return true
 }
 
 // parseLastChannel parses the last channel string into platform and userID.
@@ -1,11 +1,14 @@
 package heartbeat
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
@@ -211,7 +214,7 @@ func TestHeartbeatFilePath(t *testing.T) {
 	hs := NewHeartbeatService(tmpDir, 30, true)
 
 	// Trigger default template creation
-	hs.buildPrompt()
+	hs.loadTasks()
 
 	// Verify HEARTBEAT.md exists at workspace root
 	expectedPath := filepath.Join(tmpDir, "HEARTBEAT.md")
@@ -219,3 +222,230 @@ func TestHeartbeatFilePath(t *testing.T) {
 		t.Errorf("Expected HEARTBEAT.md at %s, but it doesn't exist", expectedPath)
 	}
 }
+
+func TestParseTasks(t *testing.T) {
+	content := `# Checks
+
+Be brief.
+
+## Task: disk
+- every: 2h
+- channel: telegram
+- chat: 42
+- agent: ops
+- quiet: 22:00-07:00
+- dedup: off
+
+- Check free disk space
+
+## Task: mail
+- every: 1
+
+Check unread mail.
+`
+	tasks := parseTasks(content, 30*time.Minute, nil)
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(tasks))
+	}
+
+	disk := tasks[0]
+	if disk.Name != "disk" || disk.Interval != 2*time.Hour || disk.Channel != "telegram" ||
+		disk.ChatID != "42" || disk.Agent != "ops" || disk.Dedup >= 0 {
+		t.Errorf("unexpected disk task: %+v", disk)
+	}
+	if disk.Quiet == nil || disk.Quiet.String() != "22:00-07:00" {
+		t.Errorf("unexpected quiet hours: %v", disk.Quiet)
+	}
+	if !strings.HasPrefix(disk.Body, "# Checks") || !strings.Contains(disk.Body, "- Check free disk space") {
+		t.Errorf("expected shared preamble and body, got %q", disk.Body)
+	}
+
+	if tasks[1].Interval != minIntervalMinutes*time.Minute {
+		t.Errorf("expected interval clamped to minimum, got %v", tasks[1].Interval)
+	}
+}
+
+func TestParseTasks_NoSections(t *testing.T) {
+	tasks := parseTasks("- Check the weather", 30*time.Minute, nil)
+	if len(tasks) != 1 || tasks[0].Name != defaultTaskName || tasks[0].Interval != 30*time.Minute {
+		t.Fatalf("expected a single default task, got %+v", tasks)
+	}
+	if parseTasks("  \n", 30*time.Minute, nil) != nil {
+		t.Error("expected no tasks for empty content")
+	}
+}
+
+func TestLoadTasks_DefaultTemplate(t *testing.T) {
+	hs := NewHeartbeatService(t.TempDir(), 30, true)
+
+	// The first load writes the template; loading it again finds no tasks.
+	hs.loadTasks()
+	if tasks := hs.loadTasks(); len(tasks) != 0 {
+		t.Fatalf("expected no tasks in the default template, got %+v", tasks)
+	}
+
+	// The indented example is not a task once the user adds their own text.
+	for _, task := range parseTasks(defaultHeartbeatTemplate+"- Check the weather\n", 30*time.Minute, nil) {
+		if task.Name != defaultTaskName || task.ChatID != "" {
+			t.Errorf("example parsed as a task: %+v", task)
+		}
+	}
+}
+
+func TestIsOK(t *testing.T) {
+	for _, response := range []string{"HEARTBEAT_OK", "  HEARTBEAT_OK\n", "**HEARTBEAT_OK**", "All tasks done. HEARTBEAT_OK", ""} {
+		if !IsOK(response) {
+			t.Errorf("IsOK(%q) = false", response)
+		}
+	}
+	if IsOK("Disk /data is 95% full") {
+		t.Error("an alert was treated as OK")
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	q, err := ParseQuietHours("22:00-07:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(h, m int) time.Time { return time.Date(2026, 1, 1, h, m, 0, 0, time.Local) }
+	if !q.Contains(at(23, 0)) || !q.Contains(at(6, 59)) || q.Contains(at(7, 0)) || q.Contains(at(12, 0)) {
+		t.Error("unexpected result for window crossing midnight")
+	}
+	if _, err := ParseQuietHours("22:00"); err == nil {
+		t.Error("expected error for missing end")
+	}
+}
+
+func newTaskTestService(t *testing.T, content string) (*HeartbeatService, *time.Time, *bus.MessageBus) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "HEARTBEAT.md"), []byte(content), 0644)
+
+	hs := NewHeartbeatService(tmpDir, 30, true)
+	hs.stopChan = make(chan struct{}) // Enable for testing
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+	hs.now = func() time.Time { return now }
+	msgBus := bus.NewMessageBus()
+	hs.SetBus(msgBus)
+	return hs, &now, msgBus
+}
+
+func TestExecuteHeartbeat_TaskIntervalsAndTargets(t *testing.T) {
+	hs, now, _ := newTaskTestService(t, `## Task: fast
+- every: 10m
+- channel: telegram
+- chat: 1
+
+Fast check.
+
+## Task: slow
+- every: 1h
+
+Slow check.
+`)
+
+	calls := map[string]int{}
+	hs.SetTaskHandler(func(task *Task, prompt, channel, chatID string) *tools.ToolResult {
+		calls[task.Name]++
+		if task.Name == "fast" && (channel != "telegram" || chatID != "1") {
+			t.Errorf("unexpected target %s:%s", channel, chatID)
+		}
+		if !strings.Contains(prompt, "Task: "+task.Name) {
+			t.Errorf("prompt missing task name: %q", prompt)
+		}
+		return tools.SilentResult("HEARTBEAT_OK")
+	})
+
+	hs.executeHeartbeat()
+	*now = now.Add(15 * time.Minute)
+	hs.executeHeartbeat()
+	*now = now.Add(time.Minute)
+	hs.executeHeartbeat()
+
+	if calls["fast"] != 2 || calls["slow"] != 1 {
+		t.Errorf("unexpected call counts: %v", calls)
+	}
+}
+
+func TestExecuteHeartbeat_QuietHours(t *testing.T) {
+	hs, now, _ := newTaskTestService(t, `## Task: night
+- quiet: 11:00-13:00
+
+Check.
+`)
+	calls := 0
+	hs.SetTaskHandler(func(task *Task, prompt, channel, chatID string) *tools.ToolResult {
+		calls++
+		return tools.SilentResult("HEARTBEAT_OK")
+	})
+
+	hs.executeHeartbeat()
+	hs.executeHeartbeat()
+	if calls != 0 {
+		t.Fatalf("expected no runs during quiet hours, got %d", calls)
+	}
+	*now = now.Add(time.Hour)
+	hs.executeHeartbeat()
+	if calls != 1 {
+		t.Fatalf("expected a run after quiet hours, got %d", calls)
+	}
+
+	history := hs.History(0)
+	if len(history) != 2 || history[0].Status != StatusOK || history[1].Status != StatusQuiet {
+		t.Errorf("unexpected history: %+v", history)
+	}
+}
+
+func TestExecuteHeartbeat_Dedup(t *testing.T) {
+	hs, now, msgBus := newTaskTestService(t, `## Task: disk
+- every: 5m
+- channel: telegram
+- chat: 1
+
+Check disk.
+`)
+	alert := "Disk almost full"
+	hs.SetTaskHandler(func(task *Task, prompt, channel, chatID string) *tools.ToolResult {
+		return tools.UserResult(alert)
+	})
+
+	hs.executeHeartbeat()
+	*now = now.Add(10 * time.Minute)
+	hs.executeHeartbeat()
+	alert = "Disk full"
+	*now = now.Add(10 * time.Minute)
+	hs.executeHeartbeat()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var sent []string
+	for {
+		msg, ok := msgBus.SubscribeOutbound(ctx)
+		if !ok {
+			break
+		}
+		sent = append(sent, msg.Content)
+	}
+	if len(sent) != 2 || sent[0] != "Disk almost full" || sent[1] != "Disk full" {
+		t.Errorf("unexpected messages sent: %v", sent)
+	}
+
+	// History is persisted and readable without a running service.
+	history, err := LoadHistory(hs.workspace, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{StatusAlert, StatusDuplicate, StatusAlert}
+	if len(history) != len(want) {
+		t.Fatalf("expected %d outcomes, got %+v", len(want), history)
+	}
+	for i, o := range history {
+		if o.Status != want[len(want)-1-i] {
+			t.Errorf("outcome %d: expected %s, got %s", i, want[len(want)-1-i], o.Status)
+		}
+	}
+	if history[0].Channel != "telegram:1" {
+		t.Errorf("expected delivery target recorded, got %q", history[0].Channel)
+	}
+}
@@ -0,0 +1,237 @@
+package heartbeat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTaskName is used when HEARTBEAT.md has no "## Task:" sections.
+const defaultTaskName = "default"
+
+// Task is one periodic check declared in HEARTBEAT.md.
+//
+// Tasks are declared as level-two headings followed by optional option lines:
+//
+//	## Task: disk space
+//	- every: 2h
+//	- channel: telegram
+//	- chat: 123456
+//	- agent: ops
+//	- quiet: 22:00-07:00
+//	- dedup: 12h
+//
+//	Check free disk space and warn me if any volume is above 90%.
+//
+// Text before the first task is shared and prepended to every task's prompt.
+// A file without task headings is treated as a single task on the global interval.
+type Task struct {
+	Name     string
+	Interval time.Duration
+	Channel  string // empty means the last active channel
+	ChatID   string
+	Agent    string // empty means the default agent
+	Quiet    *QuietHours
+	Dedup    time.Duration // zero means the service default, negative disables it
+	Body     string
+}
+
+// QuietHours is a daily window, in minutes since midnight, during which a task
+// does not run. Start may be after End for windows that cross midnight.
+type QuietHours struct {
+	Start int
+	End   int
+}
+
+// ParseQuietHours parses "HH:MM-HH:MM".
+func ParseQuietHours(s string) (*QuietHours, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), "-", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid quiet hours %q, want HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	return &QuietHours{Start: start, End: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains reports whether now falls inside the quiet window.
+func (q *QuietHours) Contains(now time.Time) bool {
+	minute := now.Hour()*60 + now.Minute()
+	if q.Start <= q.End {
+		return minute >= q.Start && minute < q.End
+	}
+	return minute >= q.Start || minute < q.End
+}
+
+func (q *QuietHours) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", q.Start/60, q.Start%60, q.End/60, q.End%60)
+}
+
+// parseDuration accepts Go durations ("90m", "2h") and bare minute counts ("45").
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Minute, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// parseTasks splits HEARTBEAT.md into tasks. Invalid option values are
+// reported through warn and otherwise ignored so one typo does not disable
+// every check.
+func parseTasks(content string, defaultInterval time.Duration, warn func(format string, args ...any)) []*Task {
+	if warn == nil {
+		warn = func(string, ...any) {}
+	}
+
+	var preamble []string
+	var tasks []*Task
+	var current *Task
+	var body []string
+	inOptions := false
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Body = strings.TrimSpace(strings.Join(body, "\n"))
+		tasks = append(tasks, current)
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		// Headings count only at column 0, so indented examples stay text.
+		if name, ok := taskHeading(strings.TrimRight(line, " \t\r")); ok {
+			flush()
+			current = &Task{Name: name, Interval: defaultInterval}
+			body = nil
+			inOptions = true
+			continue
+		}
+		if current == nil {
+			preamble = append(preamble, line)
+			continue
+		}
+		if inOptions {
+			if trimmed == "" && len(body) == 0 {
+				continue
+			}
+			if key, value, ok := optionLine(trimmed); ok {
+				if err := current.setOption(key, value); err != nil {
+					warn("Task %q: %v", current.Name, err)
+				}
+				continue
+			}
+			inOptions = false
+		}
+		body = append(body, line)
+	}
+	flush()
+
+	shared := strings.TrimSpace(strings.Join(preamble, "\n"))
+	if len(tasks) == 0 {
+		if shared == "" {
+			return nil
+		}
+		return []*Task{{Name: defaultTaskName, Interval: defaultInterval, Body: shared}}
+	}
+	if shared != "" {
+		for _, t := range tasks {
+			t.Body = strings.TrimSpace(shared + "\n\n" + t.Body)
+		}
+	}
+	return tasks
+}
+
+func taskHeading(line string) (string, bool) {
+	if !strings.HasPrefix(line, "## ") {
+		return "", false
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(line, "## "))
+	lower := strings.ToLower(rest)
+	if !strings.HasPrefix(lower, "task:") {
+		return "", false
+	}
+	name := strings.TrimSpace(rest[len("task:"):])
+	if name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+var taskOptionKeys = map[string]bool{
+	"every": true, "interval": true, "channel": true, "chat": true, "to": true,
+	"agent": true, "quiet": true, "dedup": true,
+}
+
+// optionLine recognizes "- key: value" lines with a known key.
+func optionLine(line string) (key, value string, ok bool) {
+	if !strings.HasPrefix(line, "- ") {
+		return "", "", false
+	}
+	k, v, found := strings.Cut(strings.TrimPrefix(line, "- "), ":")
+	if !found {
+		return "", "", false
+	}
+	k = strings.ToLower(strings.TrimSpace(k))
+	if !taskOptionKeys[k] {
+		return "", "", false
+	}
+	return k, strings.TrimSpace(v), true
+}
+
+func (t *Task) setOption(key, value string) error {
+	switch key {
+	case "every", "interval":
+		d, err := parseDuration(value)
+		if err != nil {
+			return err
+		}
+		if d < minIntervalMinutes*time.Minute {
+			d = minIntervalMinutes * time.Minute
+		}
+		t.Interval = d
+	case "channel":
+		t.Channel = value
+	case "chat", "to":
+		t.ChatID = value
+	case "agent":
+		t.Agent = value
+	case "quiet":
+		q, err := ParseQuietHours(value)
+		if err != nil {
+			return err
+		}
+		t.Quiet = q
+	case "dedup":
+		if strings.EqualFold(value, "off") {
+			t.Dedup = -1
+			return nil
+		}
+		d, err := parseDuration(value)
+		if err != nil {
+			return err
+		}
+		t.Dedup = d
+	}
+	return nil
+}
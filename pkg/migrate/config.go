@@ -121,7 +121,7 @@ func ConvertConfig(data map[string]interface{}) (*config.Config, []string, error
 			case "vllm":
 				cfg.Providers.VLLM = pc
 			case "gemini":
-				cfg.Providers.Gemini = pc
+				cfg.Providers.Gemini = config.GeminiProviderConfig{ProviderConfig: pc}
 			}
 		}
 	}
@@ -2,9 +2,12 @@ package anthropicprovider
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/anthropics/anthropic-sdk-go"
@@ -19,9 +22,18 @@ type UsageInfo = protocoltypes.UsageInfo
 type Message = protocoltypes.Message
 type ToolDefinition = protocoltypes.ToolDefinition
 type ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
+type ThinkingBlock = protocoltypes.ThinkingBlock
+type ResponseFormat = protocoltypes.ResponseFormat
 
 const defaultBaseURL = "https://api.anthropic.com"
 
+// minThinkingBudget is the smallest thinking budget the API accepts.
+const minThinkingBudget = 1024
+
+// structuredTool is the tool Claude calls to return structured output. Its
+// input schema is the requested response schema, so the API enforces it.
+const structuredTool = "structured_output"
+
 type Provider struct {
 	client      *anthropic.Client
 	tokenSource func() (string, error)
@@ -81,7 +93,28 @@ func (p *Provider) Chat(ctx context.Context, messages []Message, tools []ToolDef
 		return nil, fmt.Errorf("claude API call: %w", err)
 	}
 
-	return parseResponse(resp), nil
+	result := parseResponse(resp)
+	if rf, ok := options["response_format"].(*ResponseFormat); ok && rf != nil {
+		takeStructuredOutput(result, rf)
+	}
+	return result, nil
+}
+
+// Probe looks up the model, which checks reachability and credentials
+// without generating any tokens.
+func (p *Provider) Probe(ctx context.Context, model string) error {
+	var opts []option.RequestOption
+	if p.tokenSource != nil {
+		tok, err := p.tokenSource()
+		if err != nil {
+			return fmt.Errorf("refreshing token: %w", err)
+		}
+		opts = append(opts, option.WithAuthToken(tok))
+	}
+	if _, err := p.client.Models.Get(ctx, model, anthropic.ModelGetParams{}, opts...); err != nil {
+		return fmt.Errorf("claude API call: %w", err)
+	}
+	return nil
 }
 
 func (p *Provider) GetDefaultModel() string {
@@ -105,6 +138,8 @@ func buildParams(messages []Message, tools []ToolDefinition, model string, optio
 				anthropicMessages = append(anthropicMessages,
 					anthropic.NewUserMessage(anthropic.NewToolResultBlock(msg.ToolCallID, msg.Content, false)),
 				)
+			} else if len(msg.Media) > 0 {
+				anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(userBlocks(msg)...))
 			} else {
 				anthropicMessages = append(anthropicMessages,
 					anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Content)),
@@ -112,12 +147,21 @@ func buildParams(messages []Message, tools []ToolDefinition, model string, optio
 			}
 		case "assistant":
 			if len(msg.ToolCalls) > 0 {
+				// Thinking blocks must come first, unchanged, for the tool loop to continue.
 				var blocks []anthropic.ContentBlockParamUnion
+				for _, tb := range msg.Thinking {
+					switch {
+					case tb.Redacted != "":
+						blocks = append(blocks, anthropic.NewRedactedThinkingBlock(tb.Redacted))
+					case tb.Thinking != "":
+						blocks = append(blocks, anthropic.NewThinkingBlock(tb.Signature, tb.Thinking))
+					}
+				}
 				if msg.Content != "" {
 					blocks = append(blocks, anthropic.NewTextBlock(msg.Content))
 				}
 				for _, tc := range msg.ToolCalls {
-					blocks = append(blocks, anthropic.NewToolUseBlock(tc.ID, tc.Arguments, tc.Name))
+					blocks = append(blocks, anthropic.NewToolUseBlock(tc.ID, toolCallInput(tc), toolCallName(tc)))
 				}
 				anthropicMessages = append(anthropicMessages, anthropic.NewAssistantMessage(blocks...))
 			} else {
@@ -147,17 +191,187 @@ func buildParams(messages []Message, tools []ToolDefinition, model string, optio
 		params.System = system
 	}
 
-	if temp, ok := options["temperature"].(float64); ok {
+	// Extended thinking counts toward max_tokens and does not allow a
+	// custom temperature.
+	if budget, ok := options["thinking_budget"].(int); ok && budget > 0 {
+		if budget < minThinkingBudget {
+			budget = minThinkingBudget
+		}
+		params.Thinking = anthropic.ThinkingConfigParamOfEnabled(int64(budget))
+		params.MaxTokens += int64(budget)
+	} else if temp, ok := options["temperature"].(float64); ok {
 		params.Temperature = anthropic.Float(temp)
 	}
 
+	rf, _ := options["response_format"].(*ResponseFormat)
+	if rf != nil {
+		tools = append(tools[:len(tools):len(tools)], structuredToolDef(rf))
+	}
 	if len(tools) > 0 {
 		params.Tools = translateTools(tools)
 	}
+	// Require a tool call so the turn ends with structured_output rather than
+	// text. Forced tool use is not allowed together with extended thinking.
+	if rf != nil && params.Thinking.OfEnabled == nil {
+		params.ToolChoice = anthropic.ToolChoiceUnionParam{OfAny: &anthropic.ToolChoiceAnyParam{}}
+	}
+
+	if cache, ok := options["prompt_cache"].(bool); !ok || cache {
+		addCacheBreakpoints(&params)
+	}
 
 	return params, nil
 }
 
+// structuredToolDef wraps the response schema as a tool. Tool inputs must be
+// objects, so other schemas are wrapped in a "value" property.
+func structuredToolDef(rf *ResponseFormat) ToolDefinition {
+	params := rf.Schema
+	if !objectSchema(rf) {
+		params = map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"value": rf.Schema},
+			"required":   []interface{}{"value"},
+		}
+	}
+	return ToolDefinition{
+		Type: "function",
+		Function: ToolFunctionDefinition{
+			Name:        structuredTool,
+			Description: "Return the final answer. Call this instead of replying with text once you are done.",
+			Parameters:  params,
+		},
+	}
+}
+
+func objectSchema(rf *ResponseFormat) bool {
+	t, _ := rf.Schema["type"].(string)
+	return t == "object"
+}
+
+// takeStructuredOutput turns a structured_output call into the reply content.
+func takeStructuredOutput(resp *LLMResponse, rf *ResponseFormat) {
+	for _, tc := range resp.ToolCalls {
+		if tc.Name != structuredTool {
+			continue
+		}
+		var value interface{} = tc.Arguments
+		if !objectSchema(rf) {
+			value = tc.Arguments["value"]
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			log.Printf("anthropic: failed to encode structured output: %v", err)
+			return
+		}
+		resp.Content = string(data)
+		resp.ToolCalls = nil
+		resp.FinishReason = "stop"
+		return
+	}
+}
+
+// userBlocks returns the text of a user message followed by its images.
+func userBlocks(msg Message) []anthropic.ContentBlockParamUnion {
+	var blocks []anthropic.ContentBlockParamUnion
+	if msg.Content != "" {
+		blocks = append(blocks, anthropic.NewTextBlock(msg.Content))
+	}
+	for _, ref := range msg.Media {
+		if block, ok := imageBlock(ref); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	if len(blocks) == 0 {
+		blocks = append(blocks, anthropic.NewTextBlock(msg.Content))
+	}
+	return blocks
+}
+
+// imageTypes are the image formats the Messages API accepts.
+var imageTypes = map[string]string{
+	".jpg": "image/jpeg", ".jpeg": "image/jpeg", ".png": "image/png", ".gif": "image/gif", ".webp": "image/webp",
+}
+
+// imageBlock turns a data URL, http(s) URL or local image file into an
+// image block.
+func imageBlock(ref string) (anthropic.ContentBlockParamUnion, bool) {
+	if strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") {
+		return anthropic.NewImageBlock(anthropic.URLImageSourceParam{URL: ref}), true
+	}
+	if strings.HasPrefix(ref, "data:") {
+		mediaType, payload, ok := strings.Cut(strings.TrimPrefix(ref, "data:"), ";base64,")
+		if !ok || imageTypes[strings.Replace(mediaType, "image/", ".", 1)] == "" {
+			return anthropic.ContentBlockParamUnion{}, false
+		}
+		return anthropic.NewImageBlockBase64(mediaType, payload), true
+	}
+	mediaType, ok := imageTypes[strings.ToLower(filepath.Ext(ref))]
+	if !ok {
+		return anthropic.ContentBlockParamUnion{}, false
+	}
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		log.Printf("anthropic: skipping image %q: %v", ref, err)
+		return anthropic.ContentBlockParamUnion{}, false
+	}
+	return anthropic.NewImageBlockBase64(mediaType, base64.StdEncoding.EncodeToString(data)), true
+}
+
+// addCacheBreakpoints marks the prefix that repeats between calls so the API
+// can serve it from the prompt cache. The request is cached in the order
+// tools, system, messages, and at most four breakpoints are allowed:
+//   - the last tool definition
+//   - the first system block, the stable prompt from the context builder
+//     (later blocks carry per-turn context such as the current time)
+//   - the last two messages, so each tool-loop iteration and each new turn
+//     reads the history written by the call before it
+//
+// Prefixes shorter than the model's minimum cacheable length are ignored by
+// the API, so short one-off calls cost nothing extra.
+func addCacheBreakpoints(params *anthropic.MessageNewParams) {
+	ephemeral := anthropic.NewCacheControlEphemeralParam()
+
+	if n := len(params.Tools); n > 0 {
+		if cc := params.Tools[n-1].GetCacheControl(); cc != nil {
+			*cc = ephemeral
+		}
+	}
+	if len(params.System) > 0 {
+		params.System[0].CacheControl = ephemeral
+	}
+	for i := len(params.Messages) - 1; i >= 0 && i >= len(params.Messages)-2; i-- {
+		content := params.Messages[i].Content
+		if n := len(content); n > 0 {
+			if cc := content[n-1].GetCacheControl(); cc != nil {
+				*cc = ephemeral
+			}
+		}
+	}
+}
+
+// toolCallInput returns the arguments of a tool call as an object. Calls
+// saved by the agent loop carry them as a JSON string in Function.
+func toolCallInput(tc ToolCall) map[string]interface{} {
+	if tc.Arguments != nil {
+		return tc.Arguments
+	}
+	args := map[string]interface{}{}
+	if tc.Function != nil && tc.Function.Arguments != "" {
+		if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+			log.Printf("anthropic: failed to decode saved tool call arguments for %q: %v", toolCallName(tc), err)
+		}
+	}
+	return args
+}
+
+func toolCallName(tc ToolCall) string {
+	if tc.Name == "" && tc.Function != nil {
+		return tc.Function.Name
+	}
+	return tc.Name
+}
+
 func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 	result := make([]anthropic.ToolUnionParam, 0, len(tools))
 	for _, t := range tools {
@@ -185,7 +399,8 @@ func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 }
 
 func parseResponse(resp *anthropic.Message) *LLMResponse {
-	var content string
+	var content, reasoning string
+	var thinking []ThinkingBlock
 	var toolCalls []ToolCall
 
 	for _, block := range resp.Content {
@@ -193,6 +408,12 @@ func parseResponse(resp *anthropic.Message) *LLMResponse {
 		case "text":
 			tb := block.AsText()
 			content += tb.Text
+		case "thinking":
+			tb := block.AsThinking()
+			reasoning += tb.Thinking
+			thinking = append(thinking, ThinkingBlock{Thinking: tb.Thinking, Signature: tb.Signature})
+		case "redacted_thinking":
+			thinking = append(thinking, ThinkingBlock{Redacted: block.AsRedactedThinking().Data})
 		case "tool_use":
 			tu := block.AsToolUse()
 			var args map[string]interface{}
@@ -220,13 +441,24 @@ func parseResponse(resp *anthropic.Message) *LLMResponse {
 
 	return &LLMResponse{
 		Content:      content,
+		Reasoning:    reasoning,
+		Thinking:     thinking,
 		ToolCalls:    toolCalls,
 		FinishReason: finishReason,
-		Usage: &UsageInfo{
-			PromptTokens:     int(resp.Usage.InputTokens),
-			CompletionTokens: int(resp.Usage.OutputTokens),
-			TotalTokens:      int(resp.Usage.InputTokens + resp.Usage.OutputTokens),
-		},
+		Usage:        parseUsage(resp.Usage),
+	}
+}
+
+// parseUsage converts API usage. Anthropic reports cached prompt tokens
+// separately from input_tokens; PromptTokens includes them.
+func parseUsage(u anthropic.Usage) *UsageInfo {
+	prompt := int(u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens)
+	return &UsageInfo{
+		PromptTokens:     prompt,
+		CompletionTokens: int(u.OutputTokens),
+		TotalTokens:      prompt + int(u.OutputTokens),
+		CacheReadTokens:  int(u.CacheReadInputTokens),
+		CacheWriteTokens: int(u.CacheCreationInputTokens),
 	}
 }
 
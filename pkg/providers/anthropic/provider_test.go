@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
 
@@ -32,6 +34,38 @@ func TestBuildParams_BasicMessage(t *testing.T) {
 	}
 }
 
+func TestBuildParams_ImageAttachments(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "still.jpg")
+	os.WriteFile(image, []byte{0xFF, 0xD8, 0xFF, 0xD9}, 0644)
+
+	messages := []Message{
+		{Role: "user", Content: "What is this?", Media: []string{
+			image,
+			"data:image/png;base64,iVBORw0KGgo=",
+			"https://example.com/a.webp",
+			"notes.txt",
+			"data:audio/ogg;base64,T2dn",
+		}},
+	}
+	params, err := buildParams(messages, nil, "claude-sonnet-4-5-20250929", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	blocks := params.Messages[0].Content
+	if len(blocks) != 4 || blocks[0].OfText == nil || blocks[0].OfText.Text != "What is this?" {
+		t.Fatalf("content blocks = %+v", blocks)
+	}
+	if img := blocks[1].OfImage; img == nil || img.Source.OfBase64 == nil || img.Source.OfBase64.MediaType != "image/jpeg" || img.Source.OfBase64.Data != "/9j/2Q==" {
+		t.Errorf("file image block = %+v", blocks[1])
+	}
+	if img := blocks[2].OfImage; img == nil || img.Source.OfBase64 == nil || img.Source.OfBase64.MediaType != "image/png" {
+		t.Errorf("data URL image block = %+v", blocks[2])
+	}
+	if img := blocks[3].OfImage; img == nil || img.Source.OfURL == nil || img.Source.OfURL.URL != "https://example.com/a.webp" {
+		t.Errorf("URL image block = %+v", blocks[3])
+	}
+}
+
 func TestBuildParams_SystemMessage(t *testing.T) {
 	messages := []Message{
 		{Role: "system", Content: "You are helpful"},
@@ -143,6 +177,61 @@ func TestParseResponse_StopReasons(t *testing.T) {
 	}
 }
 
+func TestBuildParams_CacheBreakpoints(t *testing.T) {
+	messages := []Message{
+		{Role: "system", Content: "stable prompt"},
+		{Role: "system", Content: "Current time: 12:00"},
+		{Role: "user", Content: "list files"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Name: "list_dir", Arguments: map[string]interface{}{}}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "a.txt"},
+	}
+	tools := []ToolDefinition{
+		{Type: "function", Function: ToolFunctionDefinition{Name: "read_file", Parameters: map[string]interface{}{}}},
+		{Type: "function", Function: ToolFunctionDefinition{Name: "list_dir", Parameters: map[string]interface{}{}}},
+	}
+	params, err := buildParams(messages, tools, "claude-sonnet-4-5-20250929", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+
+	cached := func(cc *anthropic.CacheControlEphemeralParam) bool { return cc != nil && cc.Type == "ephemeral" }
+	if cached(params.Tools[0].GetCacheControl()) || !cached(params.Tools[1].GetCacheControl()) {
+		t.Error("only the last tool should carry a breakpoint")
+	}
+	if !cached(&params.System[0].CacheControl) || cached(&params.System[1].CacheControl) {
+		t.Error("only the first system block should carry a breakpoint")
+	}
+	var marked []int
+	for i, m := range params.Messages {
+		if cached(m.Content[len(m.Content)-1].GetCacheControl()) {
+			marked = append(marked, i)
+		}
+	}
+	if len(marked) != 2 || marked[0] != 1 || marked[1] != 2 {
+		t.Errorf("messages with breakpoints = %v, want [1 2]", marked)
+	}
+
+	params, _ = buildParams(messages, tools, "claude-sonnet-4-5-20250929", map[string]interface{}{"prompt_cache": false})
+	if cached(params.Tools[1].GetCacheControl()) || cached(&params.System[0].CacheControl) {
+		t.Error("prompt_cache=false should not add breakpoints")
+	}
+}
+
+func TestParseResponse_CacheUsage(t *testing.T) {
+	resp := &anthropic.Message{
+		Usage: anthropic.Usage{
+			InputTokens:              50,
+			CacheReadInputTokens:     3000,
+			CacheCreationInputTokens: 200,
+			OutputTokens:             40,
+		},
+	}
+	u := parseResponse(resp).Usage
+	if u.PromptTokens != 3250 || u.CacheReadTokens != 3000 || u.CacheWriteTokens != 200 || u.TotalTokens != 3290 {
+		t.Errorf("Usage = %+v", u)
+	}
+}
+
 func TestProvider_ChatRoundTrip(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/v1/messages" {
@@ -263,3 +352,119 @@ func createAnthropicTestClient(baseURL, token string) *anthropic.Client {
 	)
 	return &c
 }
+
+func TestBuildParams_Thinking(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "what's in /tmp?"},
+		{
+			Role:      "assistant",
+			Thinking:  []ThinkingBlock{{Thinking: "I should list it.", Signature: "sig-1"}, {Redacted: "opaque"}},
+			ToolCalls: []ToolCall{{ID: "call_1", Name: "list_dir", Arguments: map[string]interface{}{"path": "/tmp"}}},
+		},
+		{Role: "tool", ToolCallID: "call_1", Content: "a.txt"},
+	}
+	params, err := buildParams(messages, nil, "claude-sonnet-4-5", map[string]interface{}{
+		"max_tokens":      4096,
+		"temperature":     0.7,
+		"thinking_budget": 500,
+	})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if params.Thinking.OfEnabled == nil || params.Thinking.OfEnabled.BudgetTokens != minThinkingBudget {
+		t.Errorf("Thinking = %+v, want enabled with budget %d", params.Thinking, minThinkingBudget)
+	}
+	if params.MaxTokens != 4096+minThinkingBudget {
+		t.Errorf("MaxTokens = %d, want %d", params.MaxTokens, 4096+minThinkingBudget)
+	}
+	if params.Temperature.Valid() {
+		t.Error("temperature should not be sent with thinking enabled")
+	}
+
+	blocks := params.Messages[1].Content
+	if len(blocks) != 3 {
+		t.Fatalf("assistant blocks = %d, want 3", len(blocks))
+	}
+	if blocks[0].OfThinking == nil || blocks[0].OfThinking.Signature != "sig-1" {
+		t.Errorf("first block = %+v, want signed thinking", blocks[0])
+	}
+	if blocks[1].OfRedactedThinking == nil || blocks[1].OfRedactedThinking.Data != "opaque" {
+		t.Errorf("second block = %+v, want redacted thinking", blocks[1])
+	}
+	if blocks[2].OfToolUse == nil {
+		t.Errorf("third block = %+v, want tool_use", blocks[2])
+	}
+}
+
+func TestParseResponse_Thinking(t *testing.T) {
+	var resp anthropic.Message
+	raw := `{"content":[
+		{"type":"thinking","thinking":"Let me check.","signature":"sig-1"},
+		{"type":"redacted_thinking","data":"opaque"},
+		{"type":"tool_use","id":"call_1","name":"list_dir","input":{"path":"/tmp"}}
+	],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":30}}`
+	if err := json.Unmarshal([]byte(raw), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	result := parseResponse(&resp)
+	if result.Reasoning != "Let me check." {
+		t.Errorf("Reasoning = %q", result.Reasoning)
+	}
+	if len(result.Thinking) != 2 || result.Thinking[0].Signature != "sig-1" || result.Thinking[1].Redacted != "opaque" {
+		t.Errorf("Thinking = %+v", result.Thinking)
+	}
+	if len(result.ToolCalls) != 1 {
+		t.Errorf("ToolCalls = %+v", result.ToolCalls)
+	}
+}
+
+func TestBuildParams_ResponseFormat(t *testing.T) {
+	rf := &ResponseFormat{Name: "answer", Schema: map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"answer": map[string]interface{}{"type": "integer"}},
+		"required":   []interface{}{"answer"},
+	}}
+	tools := []ToolDefinition{{Type: "function", Function: ToolFunctionDefinition{Name: "read_file", Parameters: map[string]interface{}{}}}}
+	params, err := buildParams([]Message{{Role: "user", Content: "6*7?"}}, tools, "claude-sonnet-4-5", map[string]interface{}{"response_format": rf})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if len(params.Tools) != 2 || *params.Tools[1].GetName() != structuredTool || len(tools) != 1 {
+		t.Fatalf("tools = %d, want read_file and %s", len(params.Tools), structuredTool)
+	}
+	if params.ToolChoice.OfAny == nil {
+		t.Error("tool use should be required")
+	}
+
+	params, _ = buildParams([]Message{{Role: "user", Content: "6*7?"}}, nil, "claude-sonnet-4-5", map[string]interface{}{"response_format": rf, "thinking_budget": 2048})
+	if params.ToolChoice.OfAny != nil {
+		t.Error("tool use cannot be forced with thinking enabled")
+	}
+}
+
+func TestTakeStructuredOutput(t *testing.T) {
+	object := &ResponseFormat{Schema: map[string]interface{}{"type": "object"}}
+	resp := &LLMResponse{
+		ToolCalls:    []ToolCall{{ID: "t1", Name: structuredTool, Arguments: map[string]interface{}{"answer": float64(42)}}},
+		FinishReason: "tool_calls",
+	}
+	takeStructuredOutput(resp, object)
+	if resp.Content != `{"answer":42}` || resp.ToolCalls != nil || resp.FinishReason != "stop" {
+		t.Errorf("object output = %+v", resp)
+	}
+
+	// Non-object schemas are wrapped in a "value" property.
+	array := &ResponseFormat{Schema: map[string]interface{}{"type": "array"}}
+	resp = &LLMResponse{ToolCalls: []ToolCall{{Name: structuredTool, Arguments: map[string]interface{}{"value": []interface{}{"a", "b"}}}}}
+	takeStructuredOutput(resp, array)
+	if resp.Content != `["a","b"]` {
+		t.Errorf("array output = %q", resp.Content)
+	}
+
+	// Other tool calls are left for the agent loop.
+	resp = &LLMResponse{ToolCalls: []ToolCall{{Name: "read_file"}}}
+	takeStructuredOutput(resp, object)
+	if len(resp.ToolCalls) != 1 || resp.Content != "" {
+		t.Errorf("regular tool call = %+v", resp)
+	}
+}
@@ -47,6 +47,10 @@ func (p *ClaudeProvider) Chat(ctx context.Context, messages []Message, tools []T
 	return resp, nil
 }
 
+func (p *ClaudeProvider) Probe(ctx context.Context, model string) error {
+	return p.delegate.Probe(ctx, model)
+}
+
 func (p *ClaudeProvider) GetDefaultModel() string {
 	return p.delegate.GetDefaultModel()
 }
@@ -191,7 +191,10 @@ func buildCodexParams(messages []Message, tools []ToolDefinition, model string,
 	for _, msg := range messages {
 		switch msg.Role {
 		case "system":
-			instructions = msg.Content
+			if instructions != "" {
+				instructions += "\n\n"
+			}
+			instructions += msg.Content
 		case "user":
 			if msg.ToolCallID != "" {
 				inputItems = append(inputItems, responses.ResponseInputItemUnionParam{
@@ -272,6 +275,24 @@ func buildCodexParams(messages []Message, tools []ToolDefinition, model string,
 		params.Tools = translateToolsForCodex(tools, enableWebSearch)
 	}
 
+	if effort, _ := options["reasoning_effort"].(string); effort != "" {
+		params.Reasoning = openai.ReasoningParam{
+			Effort:  openai.ReasoningEffort(effort),
+			Summary: openai.ReasoningSummaryAuto,
+		}
+	}
+
+	if rf, ok := options["response_format"].(*ResponseFormat); ok && rf != nil {
+		params.Text = responses.ResponseTextConfigParam{
+			Format: responses.ResponseFormatTextConfigUnionParam{
+				OfJSONSchema: &responses.ResponseFormatTextJSONSchemaConfigParam{
+					Name:   rf.Name,
+					Schema: rf.Schema,
+				},
+			},
+		}
+	}
+
 	return params
 }
 
@@ -329,11 +350,18 @@ func translateToolsForCodex(tools []ToolDefinition, enableWebSearch bool) []resp
 }
 
 func parseCodexResponse(resp *responses.Response) *LLMResponse {
-	var content strings.Builder
+	var content, reasoning strings.Builder
 	var toolCalls []ToolCall
 
 	for _, item := range resp.Output {
 		switch item.Type {
+		case "reasoning":
+			for _, s := range item.Summary {
+				if reasoning.Len() > 0 {
+					reasoning.WriteString("\n\n")
+				}
+				reasoning.WriteString(s.Text)
+			}
 		case "message":
 			for _, c := range item.Content {
 				if c.Type == "output_text" {
@@ -367,11 +395,14 @@ func parseCodexResponse(resp *responses.Response) *LLMResponse {
 			PromptTokens:     int(resp.Usage.InputTokens),
 			CompletionTokens: int(resp.Usage.OutputTokens),
 			TotalTokens:      int(resp.Usage.TotalTokens),
+			CacheReadTokens:  int(resp.Usage.InputTokensDetails.CachedTokens),
+			ReasoningTokens:  int(resp.Usage.OutputTokensDetails.ReasoningTokens),
 		}
 	}
 
 	return &LLMResponse{
 		Content:      content.String(),
+		Reasoning:    reasoning.String(),
 		ToolCalls:    toolCalls,
 		FinishReason: finishReason,
 		Usage:        usage,
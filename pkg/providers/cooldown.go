@@ -26,6 +26,7 @@ type cooldownEntry struct {
 	DisabledUntil  time.Time      // billing-specific disable expiry
 	DisabledReason FailoverReason // reason for disable (billing)
 	LastFailure    time.Time
+	ProbedOK       bool // a health probe succeeded since the last failure
 }
 
 // NewCooldownTracker creates a tracker with default 24h failure window.
@@ -55,6 +56,7 @@ func (ct *CooldownTracker) MarkFailure(provider string, reason FailoverReason) {
 	entry.ErrorCount++
 	entry.FailureCounts[reason]++
 	entry.LastFailure = now
+	entry.ProbedOK = false
 
 	if reason == FailoverBilling {
 		billingCount := entry.FailureCounts[FailoverBilling]
@@ -82,6 +84,25 @@ func (ct *CooldownTracker) MarkSuccess(provider string) {
 	entry.DisabledReason = ""
 }
 
+// MarkProbeSuccess closes a half-open circuit after a successful health
+// probe. Probes such as listing models do not prove that completions work
+// again, so the error counts are kept and the next failure still gets a
+// longer cooldown. An open circuit stays open.
+func (ct *CooldownTracker) MarkProbeSuccess(provider string) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	entry := ct.entries[provider]
+	if entry == nil {
+		return
+	}
+	now := ct.nowFunc()
+	if now.Before(entry.DisabledUntil) || now.Before(entry.CooldownEnd) {
+		return
+	}
+	entry.ProbedOK = true
+}
+
 // IsAvailable returns true if the provider is not in cooldown or disabled.
 func (ct *CooldownTracker) IsAvailable(provider string) bool {
 	ct.mu.RLock()
@@ -107,6 +128,29 @@ func (ct *CooldownTracker) IsAvailable(provider string) bool {
 	return true
 }
 
+// CircuitState is the circuit-breaker view of a provider's cooldown entry.
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // no recent failures; requests flow
+	CircuitOpen     CircuitState = "open"      // in cooldown; the fallback chain skips it
+	CircuitHalfOpen CircuitState = "half-open" // cooldown expired; the next request or probe decides
+)
+
+// State returns the circuit state of a provider. A success in the half-open
+// state closes the circuit; a failure reopens it with a longer cooldown.
+func (ct *CooldownTracker) State(provider string) CircuitState {
+	if !ct.IsAvailable(provider) {
+		return CircuitOpen
+	}
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+	if entry := ct.entries[provider]; entry != nil && entry.ErrorCount > 0 && !entry.ProbedOK {
+		return CircuitHalfOpen
+	}
+	return CircuitClosed
+}
+
 // CooldownRemaining returns how long until the provider becomes available.
 // Returns 0 if already available.
 func (ct *CooldownTracker) CooldownRemaining(provider string) time.Duration {
@@ -267,3 +267,31 @@ func TestCooldown_MultipleProviders(t *testing.T) {
 		t.Error("groq should be available")
 	}
 }
+
+func TestCooldown_CircuitState(t *testing.T) {
+	now := time.Now()
+	ct, current := newTestTracker(now)
+
+	if got := ct.State("openai"); got != CircuitClosed {
+		t.Errorf("new provider state = %s, want closed", got)
+	}
+	ct.MarkFailure("openai", FailoverTimeout)
+	if got := ct.State("openai"); got != CircuitOpen {
+		t.Errorf("state after failure = %s, want open", got)
+	}
+	*current = now.Add(61 * time.Second)
+	if got := ct.State("openai"); got != CircuitHalfOpen {
+		t.Errorf("state after cooldown = %s, want half-open", got)
+	}
+
+	// A failure in half-open reopens with a longer cooldown.
+	ct.MarkFailure("openai", FailoverTimeout)
+	if got := ct.State("openai"); got != CircuitOpen || ct.CooldownRemaining("openai") != 5*time.Minute {
+		t.Errorf("state = %s, remaining = %s; want open for 5m", got, ct.CooldownRemaining("openai"))
+	}
+	*current = current.Add(5*time.Minute + time.Second)
+	ct.MarkSuccess("openai")
+	if got := ct.State("openai"); got != CircuitClosed {
+		t.Errorf("state after success = %s, want closed", got)
+	}
+}
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"regexp"
 	"strings"
 )
@@ -116,6 +117,19 @@ func ClassifyError(err error, provider, model string) *FailoverError {
 		}
 	}
 
+	// Providers that map API errors themselves return a FailoverError.
+	var fe *FailoverError
+	if errors.As(err, &fe) {
+		out := *fe
+		if out.Provider == "" {
+			out.Provider = provider
+		}
+		if out.Model == "" {
+			out.Model = model
+		}
+		return &out
+	}
+
 	msg := strings.ToLower(err.Error())
 
 	// Image dimension/size errors: non-retriable, non-fallback.
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers/gemini"
 )
 
 func TestClassifyError_Nil(t *testing.T) {
@@ -335,3 +337,45 @@ func TestIsImageSizeError(t *testing.T) {
 		t.Error("should not match normal error")
 	}
 }
+
+func TestClassifyError_PassesThroughFailoverError(t *testing.T) {
+	err := fmt.Errorf("chat: %w", &FailoverError{Reason: FailoverBilling, Status: 400, Wrapped: errors.New("billing not enabled")})
+	result := ClassifyError(err, "gemini", "gemini-2.5-flash")
+	if result == nil {
+		t.Fatal("expected non-nil result")
+	}
+	if result.Reason != FailoverBilling || result.Status != 400 {
+		t.Errorf("reason=%q status=%d, want billing/400", result.Reason, result.Status)
+	}
+	if result.Provider != "gemini" || result.Model != "gemini-2.5-flash" {
+		t.Errorf("provider/model not filled in: %+v", result)
+	}
+}
+
+func TestGeminiFailover(t *testing.T) {
+	tests := []struct {
+		err  *gemini.APIError
+		want FailoverReason
+	}{
+		{&gemini.APIError{StatusCode: 429, Status: "RESOURCE_EXHAUSTED", Message: "Quota exceeded"}, FailoverRateLimit},
+		{&gemini.APIError{StatusCode: 503, Status: "UNAVAILABLE", Message: "The model is overloaded"}, FailoverRateLimit},
+		{&gemini.APIError{StatusCode: 504, Status: "DEADLINE_EXCEEDED"}, FailoverTimeout},
+		{&gemini.APIError{StatusCode: 403, Status: "PERMISSION_DENIED"}, FailoverAuth},
+		{&gemini.APIError{StatusCode: 400, Status: "INVALID_ARGUMENT", Message: "API key not valid. Please pass a valid API key."}, FailoverAuth},
+		{&gemini.APIError{StatusCode: 400, Status: "INVALID_ARGUMENT", Message: "Invalid JSON payload"}, FailoverFormat},
+		{&gemini.APIError{StatusCode: 400, Status: "FAILED_PRECONDITION", Message: "Billing is not enabled"}, FailoverBilling},
+		{&gemini.APIError{StatusCode: 404, Status: "NOT_FOUND", Message: "models/nope is not found"}, FailoverUnknown},
+		{&gemini.APIError{StatusCode: 502}, FailoverTimeout},
+	}
+	for _, tt := range tests {
+		result := ClassifyError(geminiFailover(tt.err, "gemini-2.5-flash"), "gemini", "gemini-2.5-flash")
+		if result == nil || result.Reason != tt.want || result.Status != tt.err.StatusCode {
+			t.Errorf("%+v: got %+v, want reason %q", tt.err, result, tt.want)
+		}
+	}
+
+	plain := errors.New("failed to send request: connection refused")
+	if got := geminiFailover(plain, "gemini-2.5-flash"); got != plain {
+		t.Errorf("non-API errors should pass through unchanged, got %v", got)
+	}
+}
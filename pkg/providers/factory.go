@@ -6,6 +6,8 @@ import (
 
 	"github.com/sipeed/picoclaw/pkg/auth"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers/gemini"
+	"github.com/sipeed/picoclaw/pkg/providers/ollama"
 )
 
 const defaultAnthropicAPIBase = "https://api.anthropic.com/v1"
@@ -22,6 +24,8 @@ const (
 	providerTypeClaudeCLI
 	providerTypeCodexCLI
 	providerTypeGitHubCopilot
+	providerTypeOllama
+	providerTypeGemini
 )
 
 type providerSelection struct {
@@ -33,6 +37,8 @@ type providerSelection struct {
 	workspace       string
 	connectMode     string
 	enableWebSearch bool
+	ollamaOptions   ollama.Options
+	geminiSafety    map[string]string
 }
 
 func createClaudeAuthProvider(apiBase string) (LLMProvider, error) {
@@ -62,153 +68,194 @@ func createCodexAuthProvider(enableWebSearch bool) (LLMProvider, error) {
 	return p, nil
 }
 
-func resolveProviderSelection(cfg *config.Config) (providerSelection, error) {
-	model := cfg.Agents.Defaults.Model
-	providerName := strings.ToLower(cfg.Agents.Defaults.Provider)
-	lowerModel := strings.ToLower(model)
+// selectOllama selects the native Ollama API. The server needs no API key.
+func selectOllama(cfg *config.Config, sel *providerSelection) {
+	sel.providerType = providerTypeOllama
+	sel.apiKey = cfg.Providers.Ollama.APIKey
+	sel.apiBase = ollama.NormalizeBaseURL(cfg.Providers.Ollama.APIBase)
+	sel.proxy = cfg.Providers.Ollama.Proxy
+	sel.ollamaOptions = ollama.Options{
+		KeepAlive: cfg.Providers.Ollama.KeepAlive,
+		NumCtx:    cfg.Providers.Ollama.NumCtx,
+		Extra:     cfg.Providers.Ollama.Options,
+	}
+}
 
-	sel := providerSelection{
-		providerType: providerTypeHTTPCompat,
-		model:        model,
+// selectGemini selects the native Gemini generateContent API.
+func selectGemini(cfg *config.Config, sel *providerSelection) {
+	sel.providerType = providerTypeGemini
+	sel.apiKey = cfg.Providers.Gemini.APIKey
+	sel.apiBase = cfg.Providers.Gemini.APIBase
+	sel.proxy = cfg.Providers.Gemini.Proxy
+	if sel.apiBase == "" {
+		sel.apiBase = gemini.DefaultBaseURL
 	}
+	sel.geminiSafety = cfg.Providers.Gemini.SafetySettings
+}
 
-	// First, prefer explicit provider configuration.
-	if providerName != "" {
-		switch providerName {
-		case "groq":
-			if cfg.Providers.Groq.APIKey != "" {
-				sel.apiKey = cfg.Providers.Groq.APIKey
-				sel.apiBase = cfg.Providers.Groq.APIBase
-				sel.proxy = cfg.Providers.Groq.Proxy
-				if sel.apiBase == "" {
-					sel.apiBase = "https://api.groq.com/openai/v1"
-				}
+// explicitProviderSelection fills sel from the configuration of the named
+// provider. It returns true when the selection is complete and needs no
+// further validation (auth- and CLI-based providers).
+func explicitProviderSelection(cfg *config.Config, providerName, model string, sel *providerSelection) bool {
+	switch providerName {
+	case "groq":
+		if cfg.Providers.Groq.APIKey != "" {
+			sel.apiKey = cfg.Providers.Groq.APIKey
+			sel.apiBase = cfg.Providers.Groq.APIBase
+			sel.proxy = cfg.Providers.Groq.Proxy
+			if sel.apiBase == "" {
+				sel.apiBase = "https://api.groq.com/openai/v1"
 			}
-		case "openai", "gpt":
-			if cfg.Providers.OpenAI.APIKey != "" || cfg.Providers.OpenAI.AuthMethod != "" {
-				sel.enableWebSearch = cfg.Providers.OpenAI.WebSearch
-				if cfg.Providers.OpenAI.AuthMethod == "codex-cli" {
-					sel.providerType = providerTypeCodexCLIToken
-					return sel, nil
-				}
-				if cfg.Providers.OpenAI.AuthMethod == "oauth" || cfg.Providers.OpenAI.AuthMethod == "token" {
-					sel.providerType = providerTypeCodexAuth
-					return sel, nil
-				}
-				sel.apiKey = cfg.Providers.OpenAI.APIKey
-				sel.apiBase = cfg.Providers.OpenAI.APIBase
-				sel.proxy = cfg.Providers.OpenAI.Proxy
-				if sel.apiBase == "" {
-					sel.apiBase = "https://api.openai.com/v1"
-				}
+		}
+	case "openai", "gpt":
+		if cfg.Providers.OpenAI.APIKey != "" || cfg.Providers.OpenAI.AuthMethod != "" {
+			sel.enableWebSearch = cfg.Providers.OpenAI.WebSearch
+			if cfg.Providers.OpenAI.AuthMethod == "codex-cli" {
+				sel.providerType = providerTypeCodexCLIToken
+				return true
 			}
-		case "anthropic", "claude":
-			if cfg.Providers.Anthropic.APIKey != "" || cfg.Providers.Anthropic.AuthMethod != "" {
-				if cfg.Providers.Anthropic.AuthMethod == "oauth" || cfg.Providers.Anthropic.AuthMethod == "token" {
-					sel.apiBase = cfg.Providers.Anthropic.APIBase
-					if sel.apiBase == "" {
-						sel.apiBase = defaultAnthropicAPIBase
-					}
-					sel.providerType = providerTypeClaudeAuth
-					return sel, nil
-				}
-				sel.apiKey = cfg.Providers.Anthropic.APIKey
-				sel.apiBase = cfg.Providers.Anthropic.APIBase
-				sel.proxy = cfg.Providers.Anthropic.Proxy
-				if sel.apiBase == "" {
-					sel.apiBase = defaultAnthropicAPIBase
-				}
+			if cfg.Providers.OpenAI.AuthMethod == "oauth" || cfg.Providers.OpenAI.AuthMethod == "token" {
+				sel.providerType = providerTypeCodexAuth
+				return true
 			}
-		case "openrouter":
-			if cfg.Providers.OpenRouter.APIKey != "" {
-				sel.apiKey = cfg.Providers.OpenRouter.APIKey
-				sel.proxy = cfg.Providers.OpenRouter.Proxy
-				if cfg.Providers.OpenRouter.APIBase != "" {
-					sel.apiBase = cfg.Providers.OpenRouter.APIBase
-				} else {
-					sel.apiBase = "https://openrouter.ai/api/v1"
-				}
+			sel.apiKey = cfg.Providers.OpenAI.APIKey
+			sel.apiBase = cfg.Providers.OpenAI.APIBase
+			sel.proxy = cfg.Providers.OpenAI.Proxy
+			if sel.apiBase == "" {
+				sel.apiBase = "https://api.openai.com/v1"
 			}
-		case "zhipu", "glm":
-			if cfg.Providers.Zhipu.APIKey != "" {
-				sel.apiKey = cfg.Providers.Zhipu.APIKey
-				sel.apiBase = cfg.Providers.Zhipu.APIBase
-				sel.proxy = cfg.Providers.Zhipu.Proxy
+		}
+	case "anthropic", "claude":
+		if cfg.Providers.Anthropic.APIKey != "" || cfg.Providers.Anthropic.AuthMethod != "" {
+			if cfg.Providers.Anthropic.AuthMethod == "oauth" || cfg.Providers.Anthropic.AuthMethod == "token" {
+				sel.apiBase = cfg.Providers.Anthropic.APIBase
 				if sel.apiBase == "" {
-					sel.apiBase = "https://open.bigmodel.cn/api/paas/v4"
+					sel.apiBase = defaultAnthropicAPIBase
 				}
+				sel.providerType = providerTypeClaudeAuth
+				return true
 			}
-		case "gemini", "google":
-			if cfg.Providers.Gemini.APIKey != "" {
-				sel.apiKey = cfg.Providers.Gemini.APIKey
-				sel.apiBase = cfg.Providers.Gemini.APIBase
-				sel.proxy = cfg.Providers.Gemini.Proxy
-				if sel.apiBase == "" {
-					sel.apiBase = "https://generativelanguage.googleapis.com/v1beta"
-				}
+			sel.apiKey = cfg.Providers.Anthropic.APIKey
+			sel.apiBase = cfg.Providers.Anthropic.APIBase
+			sel.proxy = cfg.Providers.Anthropic.Proxy
+			if sel.apiBase == "" {
+				sel.apiBase = defaultAnthropicAPIBase
 			}
-		case "vllm":
-			if cfg.Providers.VLLM.APIBase != "" {
-				sel.apiKey = cfg.Providers.VLLM.APIKey
-				sel.apiBase = cfg.Providers.VLLM.APIBase
-				sel.proxy = cfg.Providers.VLLM.Proxy
+		}
+	case "openrouter":
+		if cfg.Providers.OpenRouter.APIKey != "" {
+			sel.apiKey = cfg.Providers.OpenRouter.APIKey
+			sel.proxy = cfg.Providers.OpenRouter.Proxy
+			if cfg.Providers.OpenRouter.APIBase != "" {
+				sel.apiBase = cfg.Providers.OpenRouter.APIBase
+			} else {
+				sel.apiBase = "https://openrouter.ai/api/v1"
 			}
-		case "shengsuanyun":
-			if cfg.Providers.ShengSuanYun.APIKey != "" {
-				sel.apiKey = cfg.Providers.ShengSuanYun.APIKey
-				sel.apiBase = cfg.Providers.ShengSuanYun.APIBase
-				sel.proxy = cfg.Providers.ShengSuanYun.Proxy
-				if sel.apiBase == "" {
-					sel.apiBase = "https://router.shengsuanyun.com/api/v1"
-				}
+		}
+	case "zhipu", "glm":
+		if cfg.Providers.Zhipu.APIKey != "" {
+			sel.apiKey = cfg.Providers.Zhipu.APIKey
+			sel.apiBase = cfg.Providers.Zhipu.APIBase
+			sel.proxy = cfg.Providers.Zhipu.Proxy
+			if sel.apiBase == "" {
+				sel.apiBase = "https://open.bigmodel.cn/api/paas/v4"
 			}
-		case "nvidia":
-			if cfg.Providers.Nvidia.APIKey != "" {
-				sel.apiKey = cfg.Providers.Nvidia.APIKey
-				sel.apiBase = cfg.Providers.Nvidia.APIBase
-				sel.proxy = cfg.Providers.Nvidia.Proxy
-				if sel.apiBase == "" {
-					sel.apiBase = "https://integrate.api.nvidia.com/v1"
-				}
+		}
+	case "gemini", "google":
+		if cfg.Providers.Gemini.APIKey != "" {
+			selectGemini(cfg, sel)
+			return true
+		}
+	case "vllm":
+		if cfg.Providers.VLLM.APIBase != "" {
+			sel.apiKey = cfg.Providers.VLLM.APIKey
+			sel.apiBase = cfg.Providers.VLLM.APIBase
+			sel.proxy = cfg.Providers.VLLM.Proxy
+		}
+	case "shengsuanyun":
+		if cfg.Providers.ShengSuanYun.APIKey != "" {
+			sel.apiKey = cfg.Providers.ShengSuanYun.APIKey
+			sel.apiBase = cfg.Providers.ShengSuanYun.APIBase
+			sel.proxy = cfg.Providers.ShengSuanYun.Proxy
+			if sel.apiBase == "" {
+				sel.apiBase = "https://router.shengsuanyun.com/api/v1"
 			}
-		case "claude-cli", "claude-code", "claudecode":
-			workspace := cfg.WorkspacePath()
-			if workspace == "" {
-				workspace = "."
+		}
+	case "nvidia":
+		if cfg.Providers.Nvidia.APIKey != "" {
+			sel.apiKey = cfg.Providers.Nvidia.APIKey
+			sel.apiBase = cfg.Providers.Nvidia.APIBase
+			sel.proxy = cfg.Providers.Nvidia.Proxy
+			if sel.apiBase == "" {
+				sel.apiBase = "https://integrate.api.nvidia.com/v1"
 			}
-			sel.providerType = providerTypeClaudeCLI
-			sel.workspace = workspace
-			return sel, nil
-		case "codex-cli", "codex-code":
-			workspace := cfg.WorkspacePath()
-			if workspace == "" {
-				workspace = "."
+		}
+	case "moonshot", "kimi":
+		if cfg.Providers.Moonshot.APIKey != "" {
+			sel.apiKey = cfg.Providers.Moonshot.APIKey
+			sel.apiBase = cfg.Providers.Moonshot.APIBase
+			sel.proxy = cfg.Providers.Moonshot.Proxy
+			if sel.apiBase == "" {
+				sel.apiBase = "https://api.moonshot.cn/v1"
 			}
-			sel.providerType = providerTypeCodexCLI
-			sel.workspace = workspace
-			return sel, nil
-		case "deepseek":
-			if cfg.Providers.DeepSeek.APIKey != "" {
-				sel.apiKey = cfg.Providers.DeepSeek.APIKey
-				sel.apiBase = cfg.Providers.DeepSeek.APIBase
-				sel.proxy = cfg.Providers.DeepSeek.Proxy
-				if sel.apiBase == "" {
-					sel.apiBase = "https://api.deepseek.com/v1"
-				}
-				if model != "deepseek-chat" && model != "deepseek-reasoner" {
-					sel.model = "deepseek-chat"
-				}
+		}
+	case "ollama":
+		selectOllama(cfg, sel)
+		return true
+	case "claude-cli", "claude-code", "claudecode":
+		workspace := cfg.WorkspacePath()
+		if workspace == "" {
+			workspace = "."
+		}
+		sel.providerType = providerTypeClaudeCLI
+		sel.workspace = workspace
+		return true
+	case "codex-cli", "codex-code":
+		workspace := cfg.WorkspacePath()
+		if workspace == "" {
+			workspace = "."
+		}
+		sel.providerType = providerTypeCodexCLI
+		sel.workspace = workspace
+		return true
+	case "deepseek":
+		if cfg.Providers.DeepSeek.APIKey != "" {
+			sel.apiKey = cfg.Providers.DeepSeek.APIKey
+			sel.apiBase = cfg.Providers.DeepSeek.APIBase
+			sel.proxy = cfg.Providers.DeepSeek.Proxy
+			if sel.apiBase == "" {
+				sel.apiBase = "https://api.deepseek.com/v1"
 			}
-		case "github_copilot", "copilot":
-			sel.providerType = providerTypeGitHubCopilot
-			if cfg.Providers.GitHubCopilot.APIBase != "" {
-				sel.apiBase = cfg.Providers.GitHubCopilot.APIBase
-			} else {
-				sel.apiBase = "localhost:4321"
+			if model != "deepseek-chat" && model != "deepseek-reasoner" {
+				sel.model = "deepseek-chat"
 			}
-			sel.connectMode = cfg.Providers.GitHubCopilot.ConnectMode
-			return sel, nil
 		}
+	case "github_copilot", "copilot":
+		sel.providerType = providerTypeGitHubCopilot
+		if cfg.Providers.GitHubCopilot.APIBase != "" {
+			sel.apiBase = cfg.Providers.GitHubCopilot.APIBase
+		} else {
+			sel.apiBase = "localhost:4321"
+		}
+		sel.connectMode = cfg.Providers.GitHubCopilot.ConnectMode
+		return true
+	}
+	return false
+}
+
+func resolveProviderSelection(cfg *config.Config) (providerSelection, error) {
+	model := cfg.Agents.Defaults.Model
+	providerName := strings.ToLower(cfg.Agents.Defaults.Provider)
+	lowerModel := strings.ToLower(model)
+
+	sel := providerSelection{
+		providerType: providerTypeHTTPCompat,
+		model:        model,
+	}
+
+	// First, prefer explicit provider configuration.
+	if providerName != "" && explicitProviderSelection(cfg, providerName, model, &sel) {
+		return sel, nil
 	}
 
 	// Fallback: infer provider from model and configured keys.
@@ -268,12 +315,8 @@ func resolveProviderSelection(cfg *config.Config) (providerSelection, error) {
 				sel.apiBase = "https://api.openai.com/v1"
 			}
 		case (strings.Contains(lowerModel, "gemini") || strings.HasPrefix(model, "google/")) && cfg.Providers.Gemini.APIKey != "":
-			sel.apiKey = cfg.Providers.Gemini.APIKey
-			sel.apiBase = cfg.Providers.Gemini.APIBase
-			sel.proxy = cfg.Providers.Gemini.Proxy
-			if sel.apiBase == "" {
-				sel.apiBase = "https://generativelanguage.googleapis.com/v1beta"
-			}
+			selectGemini(cfg, &sel)
+			return sel, nil
 		case (strings.Contains(lowerModel, "glm") || strings.Contains(lowerModel, "zhipu") || strings.Contains(lowerModel, "zai")) && cfg.Providers.Zhipu.APIKey != "":
 			sel.apiKey = cfg.Providers.Zhipu.APIKey
 			sel.apiBase = cfg.Providers.Zhipu.APIBase
@@ -295,13 +338,10 @@ func resolveProviderSelection(cfg *config.Config) (providerSelection, error) {
 			if sel.apiBase == "" {
 				sel.apiBase = "https://integrate.api.nvidia.com/v1"
 			}
-		case (strings.Contains(lowerModel, "ollama") || strings.HasPrefix(model, "ollama/")) && cfg.Providers.Ollama.APIKey != "":
-			sel.apiKey = cfg.Providers.Ollama.APIKey
-			sel.apiBase = cfg.Providers.Ollama.APIBase
-			sel.proxy = cfg.Providers.Ollama.Proxy
-			if sel.apiBase == "" {
-				sel.apiBase = "http://localhost:11434/v1"
-			}
+		case (strings.Contains(lowerModel, "ollama") || strings.HasPrefix(model, "ollama/")) &&
+			(cfg.Providers.Ollama.APIKey != "" || cfg.Providers.Ollama.APIBase != ""):
+			selectOllama(cfg, &sel)
+			return sel, nil
 		case cfg.Providers.VLLM.APIBase != "":
 			sel.apiKey = cfg.Providers.VLLM.APIKey
 			sel.apiBase = cfg.Providers.VLLM.APIBase
@@ -338,7 +378,10 @@ func CreateProvider(cfg *config.Config) (LLMProvider, error) {
 	if err != nil {
 		return nil, err
 	}
+	return createFromSelection(sel)
+}
 
+func createFromSelection(sel providerSelection) (LLMProvider, error) {
 	switch sel.providerType {
 	case providerTypeClaudeAuth:
 		return createClaudeAuthProvider(sel.apiBase)
@@ -354,6 +397,10 @@ func CreateProvider(cfg *config.Config) (LLMProvider, error) {
 		return NewCodexCliProvider(sel.workspace), nil
 	case providerTypeGitHubCopilot:
 		return NewGitHubCopilotProvider(sel.apiBase, sel.connectMode, sel.model)
+	case providerTypeOllama:
+		return NewOllamaProvider(sel.apiBase, sel.apiKey, sel.proxy, sel.ollamaOptions), nil
+	case providerTypeGemini:
+		return NewGeminiProvider(sel.apiKey, sel.apiBase, sel.proxy, sel.geminiSafety), nil
 	default:
 		return NewHTTPProvider(sel.apiKey, sel.apiBase, sel.proxy), nil
 	}
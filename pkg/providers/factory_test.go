@@ -127,13 +127,44 @@ func TestResolveProviderSelection(t *testing.T) {
 			wantAPIBase: "https://api.groq.com/openai/v1",
 		},
 		{
-			name: "ollama model uses ollama base default",
+			name: "ollama model uses native ollama api",
 			setup: func(cfg *config.Config) {
 				cfg.Agents.Defaults.Model = "ollama/qwen2.5:14b"
 				cfg.Providers.Ollama.APIKey = "ollama-key"
 			},
-			wantType:    providerTypeHTTPCompat,
-			wantAPIBase: "http://localhost:11434/v1",
+			wantType:    providerTypeOllama,
+			wantAPIBase: "http://localhost:11434",
+		},
+		{
+			name: "explicit ollama provider strips v1 suffix",
+			setup: func(cfg *config.Config) {
+				cfg.Agents.Defaults.Provider = "ollama"
+				cfg.Agents.Defaults.Model = "llama3.2"
+				cfg.Providers.Ollama.APIBase = "http://10.0.0.5:11434/v1"
+			},
+			wantType:    providerTypeOllama,
+			wantAPIBase: "http://10.0.0.5:11434",
+		},
+		{
+			name: "gemini model uses native gemini api",
+			setup: func(cfg *config.Config) {
+				cfg.Agents.Defaults.Model = "gemini-2.5-flash"
+				cfg.Providers.Gemini.APIKey = "gemini-key"
+			},
+			wantType:    providerTypeGemini,
+			wantAPIBase: "https://generativelanguage.googleapis.com/v1beta",
+		},
+		{
+			name: "explicit google provider keeps proxy",
+			setup: func(cfg *config.Config) {
+				cfg.Agents.Defaults.Provider = "google"
+				cfg.Agents.Defaults.Model = "gemini-2.5-pro"
+				cfg.Providers.Gemini.APIKey = "gemini-key"
+				cfg.Providers.Gemini.Proxy = "http://127.0.0.1:7890"
+			},
+			wantType:    providerTypeGemini,
+			wantAPIBase: "https://generativelanguage.googleapis.com/v1beta",
+			wantProxy:   "http://127.0.0.1:7890",
 		},
 		{
 			name: "moonshot model keeps proxy and default base",
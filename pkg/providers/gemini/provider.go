@@ -0,0 +1,543 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+)
+
+type ToolCall = protocoltypes.ToolCall
+type FunctionCall = protocoltypes.FunctionCall
+type LLMResponse = protocoltypes.LLMResponse
+type UsageInfo = protocoltypes.UsageInfo
+type Message = protocoltypes.Message
+type ToolDefinition = protocoltypes.ToolDefinition
+type ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
+type ThinkingBlock = protocoltypes.ThinkingBlock
+type ResponseFormat = protocoltypes.ResponseFormat
+
+const DefaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// APIError is an error returned by the Gemini API.
+type APIError struct {
+	StatusCode int    // HTTP status
+	Status     string // Google RPC status, e.g. RESOURCE_EXHAUSTED
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gemini API error: status %d %s: %s", e.StatusCode, e.Status, e.Message)
+}
+
+type Provider struct {
+	apiKey         string
+	baseURL        string
+	safetySettings []SafetySetting
+	httpClient     *http.Client
+}
+
+// SafetySetting sets the block threshold for one harm category.
+type SafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// NewProvider creates a Gemini client. safety maps harm categories
+// ("harassment" or "HARM_CATEGORY_HARASSMENT") to thresholds
+// ("block_none", "BLOCK_ONLY_HIGH", ...).
+func NewProvider(apiKey, apiBase, proxy string, safety map[string]string) *Provider {
+	client := &http.Client{
+		Timeout: 120 * time.Second,
+	}
+
+	if proxy != "" {
+		parsed, err := url.Parse(proxy)
+		if err == nil {
+			client.Transport = &http.Transport{
+				Proxy: http.ProxyURL(parsed),
+			}
+		} else {
+			log.Printf("gemini: invalid proxy URL %q: %v", proxy, err)
+		}
+	}
+
+	base := strings.TrimRight(apiBase, "/")
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	// The OpenAI-compatible endpoint lives under /openai; the native API is its parent.
+	base = strings.TrimSuffix(base, "/openai")
+
+	return &Provider{
+		apiKey:         apiKey,
+		baseURL:        base,
+		safetySettings: normalizeSafety(safety),
+		httpClient:     client,
+	}
+}
+
+func normalizeSafety(safety map[string]string) []SafetySetting {
+	settings := make([]SafetySetting, 0, len(safety))
+	for category, threshold := range safety {
+		category = strings.ToUpper(strings.TrimSpace(category))
+		if !strings.HasPrefix(category, "HARM_CATEGORY_") {
+			category = "HARM_CATEGORY_" + category
+		}
+		threshold = strings.ToUpper(strings.TrimSpace(threshold))
+		settings = append(settings, SafetySetting{Category: category, Threshold: threshold})
+	}
+	return settings
+}
+
+type part struct {
+	Text             string            `json:"text,omitempty"`
+	Thought          bool              `json:"thought,omitempty"`          // Text is a thought summary
+	ThoughtSignature string            `json:"thoughtSignature,omitempty"` // returned with function calls when thinking
+	InlineData       *inlineData       `json:"inlineData,omitempty"`
+	FunctionCall     *functionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *functionResponse `json:"functionResponse,omitempty"`
+}
+
+type inlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type functionCall struct {
+	ID   string                 `json:"id,omitempty"`
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args"`
+}
+
+type functionResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type functionDeclaration struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type tool struct {
+	FunctionDeclarations []functionDeclaration `json:"functionDeclarations"`
+}
+
+type generateRequest struct {
+	SystemInstruction *content               `json:"systemInstruction,omitempty"`
+	Contents          []content              `json:"contents"`
+	Tools             []tool                 `json:"tools,omitempty"`
+	SafetySettings    []SafetySetting        `json:"safetySettings,omitempty"`
+	GenerationConfig  map[string]interface{} `json:"generationConfig,omitempty"`
+}
+
+type generateResponse struct {
+	Candidates []struct {
+		Content      content `json:"content"`
+		FinishReason string  `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata *struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		CandidatesTokenCount    int `json:"candidatesTokenCount"`
+		TotalTokenCount         int `json:"totalTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"` // implicit cache hits, included in promptTokenCount
+		ThoughtsTokenCount      int `json:"thoughtsTokenCount"`      // billed as output, not included in candidatesTokenCount
+	} `json:"usageMetadata"`
+}
+
+func (p *Provider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	if p.apiKey == "" {
+		return nil, fmt.Errorf("gemini API key not configured")
+	}
+
+	reqBody := p.buildRequest(messages, tools, options)
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", p.baseURL, url.PathEscape(normalizeModel(model)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", p.apiKey)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseAPIError(resp.StatusCode, body)
+	}
+
+	var out generateResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return toLLMResponse(&out), nil
+}
+
+// Probe checks that the API is reachable, the key is accepted and the model
+// exists, without generating any tokens.
+func (p *Provider) Probe(ctx context.Context, model string) error {
+	if p.apiKey == "" {
+		return fmt.Errorf("gemini API key not configured")
+	}
+	endpoint := fmt.Sprintf("%s/models/%s", p.baseURL, url.PathEscape(normalizeModel(model)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("x-goog-api-key", p.apiKey)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return parseAPIError(resp.StatusCode, body)
+	}
+	return nil
+}
+
+func parseAPIError(statusCode int, body []byte) error {
+	var apiErr struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err != nil || apiErr.Error.Message == "" {
+		return &APIError{StatusCode: statusCode, Message: strings.TrimSpace(string(body))}
+	}
+	return &APIError{StatusCode: statusCode, Status: apiErr.Error.Status, Message: apiErr.Error.Message}
+}
+
+func (p *Provider) buildRequest(messages []Message, tools []ToolDefinition, options map[string]interface{}) generateRequest {
+	req := generateRequest{SafetySettings: p.safetySettings}
+
+	var system []string
+	toolNames := make(map[string]string)
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+			continue
+		case "assistant":
+			var parts []part
+			if m.Content != "" {
+				parts = append(parts, part{Text: m.Content})
+			}
+			for i, tc := range m.ToolCalls {
+				name, args := toolCallNameArgs(tc)
+				toolNames[tc.ID] = name
+				fc := part{FunctionCall: &functionCall{Name: name, Args: args}}
+				// The thought signature goes back on the first function call.
+				if i == 0 {
+					fc.ThoughtSignature = thoughtSignature(m.Thinking)
+				}
+				parts = append(parts, fc)
+			}
+			req.Contents = appendContent(req.Contents, "model", parts)
+		case "tool":
+			name := toolNames[m.ToolCallID]
+			resp := map[string]interface{}{"content": m.Content}
+			req.Contents = appendContent(req.Contents, "user", []part{{FunctionResponse: &functionResponse{Name: name, Response: resp}}})
+		default:
+			var parts []part
+			if m.Content != "" {
+				parts = append(parts, part{Text: m.Content})
+			}
+			for _, media := range m.Media {
+				if data, ok := loadInlineData(media); ok {
+					parts = append(parts, part{InlineData: data})
+				}
+			}
+			req.Contents = appendContent(req.Contents, "user", parts)
+		}
+	}
+
+	if len(system) > 0 {
+		req.SystemInstruction = &content{Parts: []part{{Text: strings.Join(system, "\n\n")}}}
+	}
+
+	if len(tools) > 0 {
+		decls := make([]functionDeclaration, 0, len(tools))
+		for _, t := range tools {
+			decl := functionDeclaration{Name: t.Function.Name, Description: t.Function.Description}
+			if props, ok := t.Function.Parameters["properties"].(map[string]interface{}); ok && len(props) > 0 {
+				decl.Parameters = sanitizeSchema(t.Function.Parameters)
+			}
+			decls = append(decls, decl)
+		}
+		req.Tools = []tool{{FunctionDeclarations: decls}}
+	}
+
+	genCfg := make(map[string]interface{})
+	if v, ok := options["max_tokens"]; ok {
+		genCfg["maxOutputTokens"] = v
+	}
+	if v, ok := options["temperature"]; ok {
+		genCfg["temperature"] = v
+	}
+	if budget, ok := options["thinking_budget"].(int); ok && budget > 0 {
+		genCfg["thinkingConfig"] = map[string]interface{}{
+			"thinkingBudget":  budget,
+			"includeThoughts": true,
+		}
+	}
+	// JSON mode cannot be combined with function calling, so requests with
+	// tools rely on the agent validating the reply instead.
+	if rf, ok := options["response_format"].(*ResponseFormat); ok && rf != nil && len(tools) == 0 {
+		genCfg["responseMimeType"] = "application/json"
+		genCfg["responseJsonSchema"] = rf.Schema
+	}
+	if len(genCfg) > 0 {
+		req.GenerationConfig = genCfg
+	}
+	return req
+}
+
+// thoughtSignature returns the Gemini signature stored with an assistant
+// message. Gemini blocks carry only a signature; blocks with thinking text
+// come from other providers.
+func thoughtSignature(blocks []ThinkingBlock) string {
+	for _, b := range blocks {
+		if b.Signature != "" && b.Thinking == "" && b.Redacted == "" {
+			return b.Signature
+		}
+	}
+	return ""
+}
+
+// appendContent adds parts to the conversation, merging with the previous
+// turn when it has the same role; Gemini expects user and model turns to
+// alternate, and parallel tool results belong in a single turn.
+func appendContent(contents []content, role string, parts []part) []content {
+	if len(parts) == 0 {
+		return contents
+	}
+	if n := len(contents); n > 0 && contents[n-1].Role == role {
+		contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+		return contents
+	}
+	return append(contents, content{Role: role, Parts: parts})
+}
+
+func toolCallNameArgs(tc ToolCall) (string, map[string]interface{}) {
+	name := tc.Name
+	args := tc.Arguments
+	if tc.Function != nil {
+		if name == "" {
+			name = tc.Function.Name
+		}
+		if args == nil && tc.Function.Arguments != "" {
+			parsed := make(map[string]interface{})
+			if err := json.Unmarshal([]byte(tc.Function.Arguments), &parsed); err == nil {
+				args = parsed
+			}
+		}
+	}
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	return name, args
+}
+
+// unsupportedSchemaKeys are JSON Schema keywords the Gemini API rejects.
+var unsupportedSchemaKeys = map[string]bool{
+	"$schema":              true,
+	"additionalProperties": true,
+	"default":              true,
+	"examples":             true,
+}
+
+func sanitizeSchema(schema map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		if unsupportedSchemaKeys[k] {
+			continue
+		}
+		switch val := v.(type) {
+		case map[string]interface{}:
+			if k == "properties" {
+				props := make(map[string]interface{}, len(val))
+				for name, prop := range val {
+					if pm, ok := prop.(map[string]interface{}); ok {
+						props[name] = sanitizeSchema(pm)
+					} else {
+						props[name] = prop
+					}
+				}
+				out[k] = props
+			} else {
+				out[k] = sanitizeSchema(val)
+			}
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+var extraMimeTypes = map[string]string{
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".mp3":  "audio/mp3",
+	".wav":  "audio/wav",
+	".m4a":  "audio/aac",
+	".aac":  "audio/aac",
+	".flac": "audio/flac",
+	".webp": "image/webp",
+	".heic": "image/heic",
+}
+
+// loadInlineData reads an image or audio attachment from a data URL or local file.
+func loadInlineData(ref string) (*inlineData, bool) {
+	if strings.HasPrefix(ref, "data:") {
+		header, payload, ok := strings.Cut(strings.TrimPrefix(ref, "data:"), ";base64,")
+		if !ok {
+			return nil, false
+		}
+		return &inlineData{MimeType: header, Data: payload}, true
+	}
+
+	ext := strings.ToLower(filepath.Ext(ref))
+	mimeType := extraMimeTypes[ext]
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(ext)
+	}
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	if !strings.HasPrefix(mimeType, "image/") && !strings.HasPrefix(mimeType, "audio/") {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		log.Printf("gemini: skipping attachment %q: %v", ref, err)
+		return nil, false
+	}
+	return &inlineData{MimeType: mimeType, Data: base64.StdEncoding.EncodeToString(data)}, true
+}
+
+// finishReasons maps Gemini finish reasons to the OpenAI-style values used
+// in LLMResponse.FinishReason.
+var finishReasons = map[string]string{
+	"STOP":                    "stop",
+	"MAX_TOKENS":              "length",
+	"SAFETY":                  "content_filter",
+	"RECITATION":              "content_filter",
+	"BLOCKLIST":               "content_filter",
+	"PROHIBITED_CONTENT":      "content_filter",
+	"SPII":                    "content_filter",
+	"IMAGE_SAFETY":            "content_filter",
+	"MALFORMED_FUNCTION_CALL": "error",
+}
+
+func toLLMResponse(out *generateResponse) *LLMResponse {
+	resp := &LLMResponse{FinishReason: "stop"}
+
+	if out.UsageMetadata != nil {
+		u := out.UsageMetadata
+		resp.Usage = &UsageInfo{
+			PromptTokens:     u.PromptTokenCount,
+			CompletionTokens: u.CandidatesTokenCount + u.ThoughtsTokenCount,
+			TotalTokens:      u.TotalTokenCount,
+			CacheReadTokens:  u.CachedContentTokenCount,
+			ReasoningTokens:  u.ThoughtsTokenCount,
+		}
+	}
+
+	if len(out.Candidates) == 0 {
+		if out.PromptFeedback != nil && out.PromptFeedback.BlockReason != "" {
+			resp.FinishReason = "content_filter"
+			resp.Content = fmt.Sprintf("The request was blocked by Gemini safety filters (%s).", out.PromptFeedback.BlockReason)
+		}
+		return resp
+	}
+
+	cand := out.Candidates[0]
+	var text, thoughts strings.Builder
+	for i, p := range cand.Content.Parts {
+		if p.ThoughtSignature != "" && len(resp.Thinking) == 0 {
+			resp.Thinking = []ThinkingBlock{{Signature: p.ThoughtSignature}}
+		}
+		if p.Thought {
+			thoughts.WriteString(p.Text)
+			continue
+		}
+		if p.Text != "" {
+			text.WriteString(p.Text)
+		}
+		if p.FunctionCall != nil {
+			id := p.FunctionCall.ID
+			if id == "" {
+				id = fmt.Sprintf("call_%d", i+1)
+			}
+			args := p.FunctionCall.Args
+			if args == nil {
+				args = map[string]interface{}{}
+			}
+			resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: id, Name: p.FunctionCall.Name, Arguments: args})
+		}
+	}
+	resp.Content = text.String()
+	resp.Reasoning = thoughts.String()
+
+	if reason, ok := finishReasons[cand.FinishReason]; ok {
+		resp.FinishReason = reason
+	}
+	if len(resp.ToolCalls) > 0 {
+		resp.FinishReason = "tool_calls"
+	}
+	if resp.FinishReason == "content_filter" && resp.Content == "" {
+		resp.Content = fmt.Sprintf("The response was blocked by Gemini safety filters (%s).", cand.FinishReason)
+	}
+	return resp
+}
+
+// normalizeModel strips provider prefixes from model references.
+func normalizeModel(model string) string {
+	for _, prefix := range []string{"gemini/", "google/", "models/"} {
+		model = strings.TrimPrefix(model, prefix)
+	}
+	return model
+}
@@ -0,0 +1,281 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChat_FunctionCallingAndRequest(t *testing.T) {
+	var got generateRequest
+	var path, key string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		key = r.Header.Get("x-goog-api-key")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		fmt.Fprint(w, `{
+			"candidates": [{
+				"content": {"role": "model", "parts": [
+					{"text": "Let me read it."},
+					{"functionCall": {"name": "read_file", "args": {"path": "a.txt"}}}
+				]},
+				"finishReason": "STOP"
+			}],
+			"usageMetadata": {"promptTokenCount": 20, "candidatesTokenCount": 7, "totalTokenCount": 27}
+		}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider("test-key", server.URL, "", map[string]string{"harassment": "block_none"})
+	messages := []Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "list files"},
+		{Role: "assistant", ToolCalls: []ToolCall{
+			{ID: "call_1", Function: &FunctionCall{Name: "list_dir", Arguments: `{"path":"."}`}},
+			{ID: "call_2", Name: "list_dir", Arguments: map[string]interface{}{"path": "docs"}},
+		}},
+		{Role: "tool", Content: "a.txt", ToolCallID: "call_1"},
+		{Role: "tool", Content: "b.md", ToolCallID: "call_2"},
+		{Role: "user", Content: "read a.txt"},
+	}
+	tools := []ToolDefinition{
+		{Type: "function", Function: ToolFunctionDefinition{
+			Name:        "read_file",
+			Description: "Read a file",
+			Parameters: map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{"type": "string", "default": "."},
+				},
+				"required": []interface{}{"path"},
+			},
+		}},
+		{Type: "function", Function: ToolFunctionDefinition{
+			Name:       "list_sessions",
+			Parameters: map[string]interface{}{"type": "object", "properties": map[string]interface{}{}},
+		}},
+	}
+
+	resp, err := p.Chat(context.Background(), messages, tools, "gemini/gemini-2.5-flash", map[string]interface{}{"max_tokens": 512, "temperature": 0.3})
+	if err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+
+	if path != "/models/gemini-2.5-flash:generateContent" || key != "test-key" {
+		t.Errorf("path=%q key=%q", path, key)
+	}
+	if got.SystemInstruction == nil || got.SystemInstruction.Parts[0].Text != "sys" {
+		t.Errorf("system instruction = %+v", got.SystemInstruction)
+	}
+	// Tool results and the following user text share one user turn so
+	// that roles alternate.
+	if len(got.Contents) != 3 {
+		t.Fatalf("contents = %+v, want user/model/user", got.Contents)
+	}
+	model := got.Contents[1]
+	if model.Role != "model" || len(model.Parts) != 2 || model.Parts[0].FunctionCall.Args["path"] != "." {
+		t.Errorf("model turn = %+v", model)
+	}
+	results := got.Contents[2]
+	if results.Role != "user" || len(results.Parts) != 3 ||
+		results.Parts[0].FunctionResponse.Name != "list_dir" || results.Parts[1].FunctionResponse.Response["content"] != "b.md" {
+		t.Errorf("tool results turn = %+v", results)
+	}
+	if results.Parts[2].Text != "read a.txt" {
+		t.Errorf("user text after tool results = %+v", results.Parts[2])
+	}
+
+	decls := got.Tools[0].FunctionDeclarations
+	if len(decls) != 2 || decls[1].Parameters != nil {
+		t.Fatalf("function declarations = %+v", decls)
+	}
+	if _, ok := decls[0].Parameters["additionalProperties"]; ok {
+		t.Error("additionalProperties should be stripped")
+	}
+	if prop := decls[0].Parameters["properties"].(map[string]interface{})["path"].(map[string]interface{}); prop["default"] != nil {
+		t.Errorf("nested default should be stripped: %v", prop)
+	}
+	if len(got.SafetySettings) != 1 || got.SafetySettings[0].Category != "HARM_CATEGORY_HARASSMENT" || got.SafetySettings[0].Threshold != "BLOCK_NONE" {
+		t.Errorf("safety settings = %+v", got.SafetySettings)
+	}
+	if got.GenerationConfig["maxOutputTokens"] != float64(512) || got.GenerationConfig["temperature"] != 0.3 {
+		t.Errorf("generation config = %v", got.GenerationConfig)
+	}
+
+	if resp.FinishReason != "tool_calls" || resp.Content != "Let me read it." || len(resp.ToolCalls) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if tc := resp.ToolCalls[0]; tc.ID == "" || tc.Name != "read_file" || tc.Arguments["path"] != "a.txt" {
+		t.Errorf("unexpected tool call: %+v", tc)
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens != 20 || resp.Usage.CompletionTokens != 7 || resp.Usage.TotalTokens != 27 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestChat_InlineMedia(t *testing.T) {
+	var got generateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"a cat meowing"}]},"finishReason":"STOP"}]}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	img := filepath.Join(dir, "cat.png")
+	voice := filepath.Join(dir, "voice.ogg")
+	os.WriteFile(img, []byte("png-bytes"), 0644)
+	os.WriteFile(voice, []byte("ogg"), 0644)
+
+	p := NewProvider("k", server.URL, "", nil)
+	resp, err := p.Chat(context.Background(), []Message{
+		{Role: "user", Content: "what is this?", Media: []string{img, voice, "data:image/jpeg;base64,QUJD", filepath.Join(dir, "notes.txt")}},
+	}, nil, "gemini-2.5-flash", nil)
+	if err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+	if resp.Content != "a cat meowing" || resp.FinishReason != "stop" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	parts := got.Contents[0].Parts
+	if len(parts) != 4 {
+		t.Fatalf("parts = %+v, want text + 3 inline parts", parts)
+	}
+	want := []inlineData{
+		{MimeType: "image/png", Data: "cG5nLWJ5dGVz"},
+		{MimeType: "audio/ogg", Data: "b2dn"},
+		{MimeType: "image/jpeg", Data: "QUJD"},
+	}
+	for i, w := range want {
+		if d := parts[i+1].InlineData; d == nil || *d != w {
+			t.Errorf("part %d = %+v, want %+v", i+1, d, w)
+		}
+	}
+}
+
+func TestChat_FinishReasons(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantReason  string
+		wantContent bool
+	}{
+		{"max tokens", `{"candidates":[{"content":{"parts":[{"text":"partial"}]},"finishReason":"MAX_TOKENS"}]}`, "length", true},
+		{"safety", `{"candidates":[{"content":{"parts":[]},"finishReason":"SAFETY"}]}`, "content_filter", true},
+		{"prompt blocked", `{"promptFeedback":{"blockReason":"PROHIBITED_CONTENT"}}`, "content_filter", true},
+		{"malformed call", `{"candidates":[{"content":{"parts":[]},"finishReason":"MALFORMED_FUNCTION_CALL"}]}`, "error", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			resp, err := NewProvider("k", server.URL, "", nil).Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "gemini-2.5-flash", nil)
+			if err != nil {
+				t.Fatalf("Chat() error: %v", err)
+			}
+			if resp.FinishReason != tt.wantReason {
+				t.Errorf("FinishReason = %q, want %q", resp.FinishReason, tt.wantReason)
+			}
+			if (resp.Content != "") != tt.wantContent {
+				t.Errorf("Content = %q", resp.Content)
+			}
+		})
+	}
+}
+
+func TestChat_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"code":429,"message":"Quota exceeded for metric","status":"RESOURCE_EXHAUSTED"}}`)
+	}))
+	defer server.Close()
+
+	_, err := NewProvider("k", server.URL, "", nil).Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "gemini-2.5-flash", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %v", err)
+	}
+	if apiErr.StatusCode != 429 || apiErr.Status != "RESOURCE_EXHAUSTED" || apiErr.Message != "Quota exceeded for metric" {
+		t.Errorf("unexpected error: %+v", apiErr)
+	}
+}
+
+func TestNewProvider_BaseURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"": DefaultBaseURL,
+		"https://generativelanguage.googleapis.com/v1beta/openai/": DefaultBaseURL,
+		"http://proxy.local/v1beta":                                "http://proxy.local/v1beta",
+	} {
+		if got := NewProvider("k", in, "", nil).baseURL; got != want {
+			t.Errorf("NewProvider(%q).baseURL = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestChat_Thinking(t *testing.T) {
+	var got generateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = generateRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		fmt.Fprint(w, `{
+			"candidates": [{
+				"content": {"role": "model", "parts": [
+					{"text": "The user wants a file.", "thought": true},
+					{"functionCall": {"name": "read_file", "args": {"path": "a.txt"}}, "thoughtSignature": "sig-1"}
+				]},
+				"finishReason": "STOP"
+			}],
+			"usageMetadata": {"promptTokenCount": 20, "candidatesTokenCount": 7, "thoughtsTokenCount": 100, "totalTokenCount": 127}
+		}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider("test-key", server.URL, "", nil)
+	messages := []Message{{Role: "user", Content: "read a.txt"}}
+	resp, err := p.Chat(t.Context(), messages, nil, "gemini-2.5-flash", map[string]interface{}{"thinking_budget": 2048})
+	if err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+
+	thinking, _ := got.GenerationConfig["thinkingConfig"].(map[string]interface{})
+	if thinking["thinkingBudget"] != float64(2048) || thinking["includeThoughts"] != true {
+		t.Errorf("thinkingConfig = %v", got.GenerationConfig["thinkingConfig"])
+	}
+	if resp.Reasoning != "The user wants a file." || resp.Content != "" {
+		t.Errorf("Reasoning = %q, Content = %q", resp.Reasoning, resp.Content)
+	}
+	if resp.Usage.CompletionTokens != 107 || resp.Usage.ReasoningTokens != 100 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+	if len(resp.Thinking) != 1 || resp.Thinking[0].Signature != "sig-1" {
+		t.Fatalf("Thinking = %+v", resp.Thinking)
+	}
+
+	// The signature is sent back with the function call on the next turn.
+	messages = append(messages,
+		Message{Role: "assistant", ToolCalls: resp.ToolCalls, Thinking: resp.Thinking},
+		Message{Role: "tool", Content: "hello", ToolCallID: resp.ToolCalls[0].ID},
+	)
+	if _, err := p.Chat(t.Context(), messages, nil, "gemini-2.5-flash", nil); err != nil {
+		t.Fatalf("second Chat() error: %v", err)
+	}
+	model := got.Contents[1]
+	if model.Role != "model" || model.Parts[0].FunctionCall == nil || model.Parts[0].ThoughtSignature != "sig-1" {
+		t.Errorf("model turn = %+v", model)
+	}
+}
@@ -0,0 +1,88 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/providers/gemini"
+)
+
+type GeminiProvider struct {
+	delegate *gemini.Provider
+}
+
+func NewGeminiProvider(apiKey, apiBase, proxy string, safety map[string]string) *GeminiProvider {
+	return &GeminiProvider{
+		delegate: gemini.NewProvider(apiKey, apiBase, proxy, safety),
+	}
+}
+
+func (p *GeminiProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	resp, err := p.delegate.Chat(ctx, messages, tools, model, options)
+	if err != nil {
+		return nil, geminiFailover(err, model)
+	}
+	return resp, nil
+}
+
+func (p *GeminiProvider) Probe(ctx context.Context, model string) error {
+	if err := p.delegate.Probe(ctx, model); err != nil {
+		return geminiFailover(err, model)
+	}
+	return nil
+}
+
+func (p *GeminiProvider) GetDefaultModel() string {
+	return ""
+}
+
+// geminiFailover converts Gemini API errors into FailoverErrors so the
+// fallback chain and cooldown tracker see the reason reported by the API
+// instead of guessing from the message text.
+func geminiFailover(err error, model string) error {
+	var apiErr *gemini.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	return &FailoverError{
+		Reason:   geminiFailoverReason(apiErr),
+		Provider: "gemini",
+		Model:    model,
+		Status:   apiErr.StatusCode,
+		Wrapped:  err,
+	}
+}
+
+func geminiFailoverReason(e *gemini.APIError) FailoverReason {
+	msg := strings.ToLower(e.Message)
+	switch e.Status {
+	case "RESOURCE_EXHAUSTED", "UNAVAILABLE":
+		// Overloaded is treated as rate_limit, as in classifyByMessage.
+		return FailoverRateLimit
+	case "DEADLINE_EXCEEDED", "INTERNAL":
+		return FailoverTimeout
+	case "UNAUTHENTICATED", "PERMISSION_DENIED":
+		return FailoverAuth
+	case "FAILED_PRECONDITION":
+		// e.g. billing not enabled, or the API is unavailable in the user's region.
+		if strings.Contains(msg, "billing") {
+			return FailoverBilling
+		}
+		return FailoverAuth
+	case "INVALID_ARGUMENT":
+		// An invalid key is reported as INVALID_ARGUMENT with reason API_KEY_INVALID.
+		if strings.Contains(msg, "api key") {
+			return FailoverAuth
+		}
+		return FailoverFormat
+	case "NOT_FOUND":
+		// Unknown model: another candidate may still work.
+		return FailoverUnknown
+	}
+
+	if reason := classifyByStatus(e.StatusCode); reason != "" {
+		return reason
+	}
+	return FailoverUnknown
+}
@@ -26,6 +26,10 @@ func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []Too
 	return p.delegate.Chat(ctx, messages, tools, model, options)
 }
 
+func (p *HTTPProvider) Probe(ctx context.Context, model string) error {
+	return p.delegate.Probe(ctx, model)
+}
+
 func (p *HTTPProvider) GetDefaultModel() string {
 	return ""
 }
@@ -0,0 +1,189 @@
+package ollama
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ModelInfo describes a locally installed model.
+type ModelInfo struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	Digest     string    `json:"digest"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Details    struct {
+		Family            string `json:"family"`
+		ParameterSize     string `json:"parameter_size"`
+		QuantizationLevel string `json:"quantization_level"`
+	} `json:"details"`
+}
+
+// PullProgress is one status update while pulling a model.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ShowResponse is the subset of /api/show used by picoclaw.
+type ShowResponse struct {
+	Parameters string                 `json:"parameters"`
+	ModelInfo  map[string]interface{} `json:"model_info"`
+}
+
+// ListModels returns the models installed on the server (/api/tags).
+func (p *Provider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	resp, err := p.do(ctx, http.MethodGet, "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var out struct {
+		Models []ModelInfo `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode model list: %w", err)
+	}
+	return out.Models, nil
+}
+
+// PullModel downloads a model (/api/pull), reporting progress to fn if not nil.
+func (p *Provider) PullModel(ctx context.Context, name string, fn func(PullProgress)) error {
+	resp, err := p.post(ctx, "/api/pull", map[string]interface{}{"model": name, "stream": true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var progress PullProgress
+		if err := json.Unmarshal([]byte(line), &progress); err != nil {
+			return fmt.Errorf("failed to decode pull progress: %w", err)
+		}
+		if progress.Error != "" {
+			return fmt.Errorf("pull %s: %s", name, progress.Error)
+		}
+		if fn != nil {
+			fn(progress)
+		}
+	}
+	return scanner.Err()
+}
+
+// DeleteModel removes a local model (/api/delete).
+func (p *Provider) DeleteModel(ctx context.Context, name string) error {
+	resp, err := p.do(ctx, http.MethodDelete, "/api/delete", map[string]string{"model": name})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("model %q not found", name)
+	}
+	return checkStatus(resp)
+}
+
+// ShowModel returns model details (/api/show).
+func (p *Provider) ShowModel(ctx context.Context, name string) (*ShowResponse, error) {
+	resp, err := p.post(ctx, "/api/show", map[string]string{"model": normalizeModel(name)})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var out ShowResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode model details: %w", err)
+	}
+	return &out, nil
+}
+
+// Probe checks that the server is up and the model has been pulled.
+func (p *Provider) Probe(ctx context.Context, model string) error {
+	_, err := p.ShowModel(ctx, model)
+	return err
+}
+
+// ContextWindow reports the context length, in tokens, that requests for
+// model will actually get: the configured num_ctx or the model's own
+// num_ctx parameter, capped by the architecture's trained context length.
+func (p *Provider) ContextWindow(ctx context.Context, model string) (int, error) {
+	info, err := p.ShowModel(ctx, model)
+	if err != nil {
+		return 0, err
+	}
+
+	trained := 0
+	for key, v := range info.ModelInfo {
+		if strings.HasSuffix(key, ".context_length") {
+			if n, ok := v.(float64); ok {
+				trained = int(n)
+			}
+		}
+	}
+
+	requested := p.opts.NumCtx
+	if requested == 0 {
+		requested = parameterInt(info.Parameters, "num_ctx")
+	}
+
+	switch {
+	case requested > 0 && (trained == 0 || requested < trained):
+		return requested, nil
+	case trained > 0:
+		return trained, nil
+	}
+	return 0, fmt.Errorf("model %q does not report a context length", model)
+}
+
+// parameterInt reads an integer from the Modelfile-style parameter list
+// returned by /api/show ("num_ctx 8192\nstop ...").
+func parameterInt(params, name string) int {
+	for _, line := range strings.Split(params, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == name {
+			if n, err := strconv.Atoi(fields[1]); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+		return fmt.Errorf("ollama: %s (status %d)", apiErr.Error, resp.StatusCode)
+	}
+	return fmt.Errorf("ollama: request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
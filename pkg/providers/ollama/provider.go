@@ -0,0 +1,335 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+)
+
+type ToolCall = protocoltypes.ToolCall
+type FunctionCall = protocoltypes.FunctionCall
+type LLMResponse = protocoltypes.LLMResponse
+type UsageInfo = protocoltypes.UsageInfo
+type Message = protocoltypes.Message
+type ToolDefinition = protocoltypes.ToolDefinition
+type ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
+type ResponseFormat = protocoltypes.ResponseFormat
+
+const DefaultBaseURL = "http://localhost:11434"
+
+// Options configures request defaults sent with every chat request.
+type Options struct {
+	KeepAlive string                 // how long the model stays loaded, e.g. "5m", "-1" (forever), "0" (unload)
+	NumCtx    int                    // context length to request; 0 uses the model default
+	Extra     map[string]interface{} // passed through as Ollama "options" (num_gpu, top_k, ...)
+}
+
+// Provider talks to the native Ollama API (/api/chat and friends).
+type Provider struct {
+	baseURL    string
+	apiKey     string
+	opts       Options
+	httpClient *http.Client
+}
+
+// NewProvider creates an Ollama client. apiBase may include the "/v1"
+// suffix used for the OpenAI-compatible endpoint; it is stripped.
+func NewProvider(apiBase, apiKey, proxy string, opts Options) *Provider {
+	client := &http.Client{
+		// Generous timeout: local models can be slow to load and generate.
+		Timeout: 10 * time.Minute,
+	}
+
+	if proxy != "" {
+		parsed, err := url.Parse(proxy)
+		if err == nil {
+			client.Transport = &http.Transport{
+				Proxy: http.ProxyURL(parsed),
+			}
+		} else {
+			log.Printf("ollama: invalid proxy URL %q: %v", proxy, err)
+		}
+	}
+
+	return &Provider{
+		baseURL:    NormalizeBaseURL(apiBase),
+		apiKey:     apiKey,
+		opts:       opts,
+		httpClient: client,
+	}
+}
+
+// NormalizeBaseURL returns the native API root for apiBase.
+func NormalizeBaseURL(apiBase string) string {
+	base := strings.TrimRight(strings.TrimSpace(apiBase), "/")
+	if base == "" {
+		return DefaultBaseURL
+	}
+	base = strings.TrimSuffix(base, "/v1")
+	base = strings.TrimSuffix(base, "/api")
+	return base
+}
+
+type chatMessage struct {
+	Role      string         `json:"role"`
+	Content   string         `json:"content"`
+	Thinking  string         `json:"thinking,omitempty"`
+	Images    []string       `json:"images,omitempty"`
+	ToolCalls []chatToolCall `json:"tool_calls,omitempty"`
+	ToolName  string         `json:"tool_name,omitempty"`
+}
+
+type chatToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Function struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatRequest struct {
+	Model     string                 `json:"model"`
+	Messages  []chatMessage          `json:"messages"`
+	Tools     []ToolDefinition       `json:"tools,omitempty"`
+	Stream    bool                   `json:"stream"`
+	Think     bool                   `json:"think,omitempty"`
+	Format    interface{}            `json:"format,omitempty"` // JSON Schema for structured output
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+}
+
+type chatResponse struct {
+	Message         chatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	DoneReason      string      `json:"done_reason"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+	Error           string      `json:"error"`
+}
+
+func (p *Provider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	reqBody := p.buildRequest(messages, tools, model, options)
+
+	resp, err := p.post(ctx, "/api/chat", reqBody)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
+	}
+
+	var out chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if out.Error != "" {
+		return nil, fmt.Errorf("ollama: %s", out.Error)
+	}
+	return toLLMResponse(out.Message.Content, out.Message.Thinking, out.Message.ToolCalls, out), nil
+}
+
+func (p *Provider) buildRequest(messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) chatRequest {
+	req := chatRequest{
+		Model:     normalizeModel(model),
+		Messages:  convertMessages(messages),
+		Tools:     tools,
+		KeepAlive: p.opts.KeepAlive,
+	}
+
+	opts := make(map[string]interface{}, len(p.opts.Extra)+3)
+	for k, v := range p.opts.Extra {
+		opts[k] = v
+	}
+	if p.opts.NumCtx > 0 {
+		opts["num_ctx"] = p.opts.NumCtx
+	}
+	if v, ok := options["max_tokens"]; ok {
+		opts["num_predict"] = v
+	}
+	if v, ok := options["temperature"]; ok {
+		opts["temperature"] = v
+	}
+	if v, ok := options["num_ctx"]; ok {
+		opts["num_ctx"] = v
+	}
+	if v, ok := options["keep_alive"].(string); ok {
+		req.KeepAlive = v
+	}
+	if effort, _ := options["reasoning_effort"].(string); effort != "" {
+		req.Think = true
+	}
+	if rf, ok := options["response_format"].(*ResponseFormat); ok && rf != nil {
+		req.Format = rf.Schema
+	}
+	if len(opts) > 0 {
+		req.Options = opts
+	}
+	return req
+}
+
+// convertMessages maps the shared message format onto Ollama's: tool call
+// arguments are objects rather than JSON strings, tool results carry the
+// tool name, and attached image files are sent base64-encoded.
+func convertMessages(messages []Message) []chatMessage {
+	toolNames := make(map[string]string)
+	out := make([]chatMessage, 0, len(messages))
+
+	for _, m := range messages {
+		cm := chatMessage{Role: m.Role, Content: m.Content}
+
+		for _, tc := range m.ToolCalls {
+			var call chatToolCall
+			call.ID = tc.ID
+			call.Function.Name = tc.Name
+			call.Function.Arguments = tc.Arguments
+			if tc.Function != nil {
+				if call.Function.Name == "" {
+					call.Function.Name = tc.Function.Name
+				}
+				if call.Function.Arguments == nil && tc.Function.Arguments != "" {
+					args := make(map[string]interface{})
+					if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err == nil {
+						call.Function.Arguments = args
+					}
+				}
+			}
+			if call.Function.Arguments == nil {
+				call.Function.Arguments = map[string]interface{}{}
+			}
+			if tc.ID != "" {
+				toolNames[tc.ID] = call.Function.Name
+			}
+			cm.ToolCalls = append(cm.ToolCalls, call)
+		}
+
+		if m.Role == "tool" {
+			cm.ToolName = toolNames[m.ToolCallID]
+		}
+
+		for _, media := range m.Media {
+			if img, ok := loadImage(media); ok {
+				cm.Images = append(cm.Images, img)
+			}
+		}
+
+		out = append(out, cm)
+	}
+	return out
+}
+
+var imageExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".bmp": true}
+
+// loadImage returns the base64 payload for a data URL or local image file.
+func loadImage(ref string) (string, bool) {
+	if strings.HasPrefix(ref, "data:image/") {
+		if idx := strings.Index(ref, ";base64,"); idx > 0 {
+			return ref[idx+len(";base64,"):], true
+		}
+		return "", false
+	}
+	if !imageExts[strings.ToLower(filepath.Ext(ref))] {
+		return "", false
+	}
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		log.Printf("ollama: skipping image %q: %v", ref, err)
+		return "", false
+	}
+	return base64.StdEncoding.EncodeToString(data), true
+}
+
+func toLLMResponse(content, thinking string, calls []chatToolCall, final chatResponse) *LLMResponse {
+	toolCalls := make([]ToolCall, 0, len(calls))
+	for i, tc := range calls {
+		id := tc.ID
+		if id == "" {
+			// Ollama does not always assign IDs; tool results are matched by name.
+			id = fmt.Sprintf("call_%d", i+1)
+		}
+		args := tc.Function.Arguments
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		toolCalls = append(toolCalls, ToolCall{
+			ID:        id,
+			Name:      tc.Function.Name,
+			Arguments: args,
+		})
+	}
+
+	finishReason := final.DoneReason
+	if len(toolCalls) > 0 {
+		finishReason = "tool_calls"
+	} else if finishReason == "" {
+		finishReason = "stop"
+	}
+
+	var usage *UsageInfo
+	if final.PromptEvalCount > 0 || final.EvalCount > 0 {
+		usage = &UsageInfo{
+			PromptTokens:     final.PromptEvalCount,
+			CompletionTokens: final.EvalCount,
+			TotalTokens:      final.PromptEvalCount + final.EvalCount,
+		}
+	}
+
+	return &LLMResponse{
+		Content:      content,
+		Reasoning:    thinking,
+		ToolCalls:    toolCalls,
+		FinishReason: finishReason,
+		Usage:        usage,
+	}
+}
+
+// normalizeModel strips the "ollama/" prefix used in model references.
+func normalizeModel(model string) string {
+	return strings.TrimPrefix(model, "ollama/")
+}
+
+func (p *Provider) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	return p.do(ctx, http.MethodPost, path, body)
+}
+
+func (p *Provider) do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,199 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChat_ToolCallsAndOptions(t *testing.T) {
+	var got chatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": map[string]interface{}{
+				"role":    "assistant",
+				"content": "",
+				"tool_calls": []map[string]interface{}{
+					{"function": map[string]interface{}{"name": "read_file", "arguments": map[string]interface{}{"path": "a.txt"}}},
+				},
+			},
+			"done":              true,
+			"done_reason":       "stop",
+			"prompt_eval_count": 12,
+			"eval_count":        5,
+		})
+	}))
+	defer server.Close()
+
+	p := NewProvider(server.URL+"/v1", "", "", Options{KeepAlive: "10m", NumCtx: 8192, Extra: map[string]interface{}{"top_k": 20}})
+	messages := []Message{
+		{Role: "system", Content: "sys"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Function: &FunctionCall{Name: "list_dir", Arguments: `{"path":"."}`}}}},
+		{Role: "tool", Content: "a.txt", ToolCallID: "call_1"},
+		{Role: "user", Content: "read it"},
+	}
+	tools := []ToolDefinition{{Type: "function", Function: ToolFunctionDefinition{Name: "read_file"}}}
+
+	resp, err := p.Chat(context.Background(), messages, tools, "ollama/llama3.2", map[string]interface{}{"max_tokens": 256, "temperature": 0.2})
+	if err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+
+	if got.Model != "llama3.2" || got.Stream || got.KeepAlive != "10m" {
+		t.Errorf("unexpected request: model=%q stream=%v keep_alive=%q", got.Model, got.Stream, got.KeepAlive)
+	}
+	if got.Options["num_ctx"] != float64(8192) || got.Options["num_predict"] != float64(256) ||
+		got.Options["temperature"] != 0.2 || got.Options["top_k"] != float64(20) {
+		t.Errorf("unexpected options: %v", got.Options)
+	}
+	if len(got.Tools) != 1 {
+		t.Errorf("tools not sent: %+v", got.Tools)
+	}
+	if args := got.Messages[1].ToolCalls[0].Function.Arguments; args["path"] != "." {
+		t.Errorf("assistant tool call arguments = %v", args)
+	}
+	if got.Messages[2].ToolName != "list_dir" {
+		t.Errorf("tool result name = %q, want list_dir", got.Messages[2].ToolName)
+	}
+
+	if resp.FinishReason != "tool_calls" || len(resp.ToolCalls) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if tc := resp.ToolCalls[0]; tc.ID == "" || tc.Name != "read_file" || tc.Arguments["path"] != "a.txt" {
+		t.Errorf("unexpected tool call: %+v", tc)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 17 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestChat_Images(t *testing.T) {
+	var got chatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"a cat"},"done":true}`)
+	}))
+	defer server.Close()
+
+	img := filepath.Join(t.TempDir(), "cat.png")
+	os.WriteFile(img, []byte("png-bytes"), 0644)
+
+	p := NewProvider(server.URL, "", "", Options{})
+	_, err := p.Chat(context.Background(), []Message{
+		{Role: "user", Content: "what is this?", Media: []string{img, "data:image/jpeg;base64,QUJD", "/tmp/notes.txt"}},
+	}, nil, "llava", nil)
+	if err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+
+	images := got.Messages[0].Images
+	if len(images) != 2 || images[0] != "cG5nLWJ5dGVz" || images[1] != "QUJD" {
+		t.Errorf("images = %v", images)
+	}
+}
+
+func TestChat_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model \"nope\" not found, try pulling it first"}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider(server.URL, "", "", Options{})
+	_, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "nope", nil)
+	if err == nil || !strings.Contains(err.Error(), "Status: 404") {
+		t.Errorf("expected status error, got %v", err)
+	}
+}
+
+func TestModelManagement(t *testing.T) {
+	deleted := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/tags" && r.Method == http.MethodGet:
+			fmt.Fprint(w, `{"models":[{"name":"llama3.2:latest","size":2019393189,"details":{"parameter_size":"3.2B","quantization_level":"Q4_K_M"}}]}`)
+		case r.URL.Path == "/api/pull":
+			fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+			fmt.Fprintln(w, `{"status":"downloading","digest":"sha256:1","total":100,"completed":50}`)
+			fmt.Fprintln(w, `{"status":"success"}`)
+		case r.URL.Path == "/api/delete" && r.Method == http.MethodDelete:
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["model"] == "missing" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			deleted = body["model"]
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := NewProvider(server.URL, "", "", Options{})
+	ctx := context.Background()
+
+	models, err := p.ListModels(ctx)
+	if err != nil || len(models) != 1 || models[0].Name != "llama3.2:latest" || models[0].Details.ParameterSize != "3.2B" {
+		t.Fatalf("ListModels() = %+v, %v", models, err)
+	}
+
+	var statuses []string
+	if err := p.PullModel(ctx, "llama3.2", func(pp PullProgress) { statuses = append(statuses, pp.Status) }); err != nil {
+		t.Fatalf("PullModel() error: %v", err)
+	}
+	if strings.Join(statuses, ",") != "pulling manifest,downloading,success" {
+		t.Errorf("pull statuses = %v", statuses)
+	}
+
+	if err := p.DeleteModel(ctx, "llama3.2"); err != nil || deleted != "llama3.2" {
+		t.Errorf("DeleteModel() = %v, deleted %q", err, deleted)
+	}
+	if err := p.DeleteModel(ctx, "missing"); err == nil {
+		t.Error("expected error deleting missing model")
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"parameters":"num_ctx 16384\nstop \"<|eot|>\"","model_info":{"general.architecture":"llama","llama.context_length":131072}}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	if n, err := NewProvider(server.URL, "", "", Options{}).ContextWindow(ctx, "llama3.2"); err != nil || n != 16384 {
+		t.Errorf("ContextWindow() with model num_ctx = %d, %v; want 16384", n, err)
+	}
+	if n, err := NewProvider(server.URL, "", "", Options{NumCtx: 4096}).ContextWindow(ctx, "llama3.2"); err != nil || n != 4096 {
+		t.Errorf("ContextWindow() with configured num_ctx = %d, %v; want 4096", n, err)
+	}
+	if n, err := NewProvider(server.URL, "", "", Options{NumCtx: 1 << 20}).ContextWindow(ctx, "llama3.2"); err != nil || n != 131072 {
+		t.Errorf("ContextWindow() capped = %d, %v; want 131072", n, err)
+	}
+}
+
+func TestNormalizeBaseURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                          DefaultBaseURL,
+		"http://localhost:11434/v1": "http://localhost:11434",
+		"http://gpu:11434/":         "http://gpu:11434",
+		"http://gpu:11434/api":      "http://gpu:11434",
+	} {
+		if got := NormalizeBaseURL(in); got != want {
+			t.Errorf("NormalizeBaseURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package providers
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/providers/ollama"
+)
+
+type OllamaProvider struct {
+	delegate *ollama.Provider
+}
+
+func NewOllamaProvider(apiBase, apiKey, proxy string, opts ollama.Options) *OllamaProvider {
+	return &OllamaProvider{
+		delegate: ollama.NewProvider(apiBase, apiKey, proxy, opts),
+	}
+}
+
+func (p *OllamaProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	return p.delegate.Chat(ctx, messages, tools, model, options)
+}
+
+func (p *OllamaProvider) ContextWindow(ctx context.Context, model string) (int, error) {
+	return p.delegate.ContextWindow(ctx, model)
+}
+
+func (p *OllamaProvider) Probe(ctx context.Context, model string) error {
+	return p.delegate.Probe(ctx, model)
+}
+
+func (p *OllamaProvider) GetDefaultModel() string {
+	return ""
+}
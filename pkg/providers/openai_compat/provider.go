@@ -3,12 +3,16 @@ package openai_compat
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -22,6 +26,7 @@ type UsageInfo = protocoltypes.UsageInfo
 type Message = protocoltypes.Message
 type ToolDefinition = protocoltypes.ToolDefinition
 type ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
+type ResponseFormat = protocoltypes.ResponseFormat
 
 type Provider struct {
 	apiKey     string
@@ -61,7 +66,7 @@ func (p *Provider) Chat(ctx context.Context, messages []Message, tools []ToolDef
 
 	requestBody := map[string]interface{}{
 		"model":    model,
-		"messages": messages,
+		"messages": withImages(mergeLeadingSystem(messages)),
 	}
 
 	if len(tools) > 0 {
@@ -78,7 +83,15 @@ func (p *Provider) Chat(ctx context.Context, messages []Message, tools []ToolDef
 		}
 	}
 
-	if temperature, ok := asFloat(options["temperature"]); ok {
+	// Reasoning models reject custom temperatures, so effort replaces it.
+	effort, _ := options["reasoning_effort"].(string)
+	if effort != "" {
+		if strings.Contains(strings.ToLower(p.apiBase), "openrouter.ai") {
+			requestBody["reasoning"] = map[string]interface{}{"effort": effort}
+		} else {
+			requestBody["reasoning_effort"] = effort
+		}
+	} else if temperature, ok := asFloat(options["temperature"]); ok {
 		lowerModel := strings.ToLower(model)
 		// Kimi k2 models only support temperature=1.
 		if strings.Contains(lowerModel, "kimi") && strings.Contains(lowerModel, "k2") {
@@ -88,14 +101,48 @@ func (p *Provider) Chat(ctx context.Context, messages []Message, tools []ToolDef
 		}
 	}
 
+	if rf, ok := options["response_format"].(*ResponseFormat); ok && rf != nil {
+		requestBody["response_format"] = responseFormat(rf, p.apiBase)
+	}
+
+	// OpenAI routes requests with the same key to the same cache shard.
+	if key, ok := options["prompt_cache_key"].(string); ok && key != "" && strings.Contains(p.apiBase, "api.openai.com") {
+		requestBody["prompt_cache_key"] = key
+	}
+
+	body, status, err := p.post(ctx, requestBody)
+	if err != nil {
+		return nil, err
+	}
+	// Not every OpenAI-compatible server supports response_format. Those
+	// reject it with a 400; the request is repeated without it and the
+	// agent validates the reply against the schema instead.
+	if status == http.StatusBadRequest && requestBody["response_format"] != nil {
+		delete(requestBody, "response_format")
+		body, status, err = p.post(ctx, requestBody)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", status, string(body))
+	}
+
+	return parseResponse(body)
+}
+
+// post sends a chat completion request and returns the response body and
+// status code.
+func (p *Provider) post(ctx context.Context, requestBody map[string]interface{}) ([]byte, int, error) {
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, 0, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", p.apiBase+"/chat/completions", bytes.NewReader(jsonData))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -105,28 +152,189 @@ func (p *Provider) Chat(ctx context.Context, messages []Message, tools []ToolDef
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, 0, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, 0, fmt.Errorf("failed to read response: %w", err)
 	}
+	return body, resp.StatusCode, nil
+}
+
+// Probe lists the available models, which checks reachability and the API
+// key without generating any tokens. Model lists are incomplete on some
+// gateways, so the model itself is not checked.
+func (p *Provider) Probe(ctx context.Context, model string) error {
+	if p.apiBase == "" {
+		return fmt.Errorf("API base not configured")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", p.apiBase+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
 	}
+	return nil
+}
 
-	return parseResponse(body)
+// responseFormat maps a schema to the response_format parameter. DeepSeek
+// and Moonshot only offer JSON mode without a schema; the agent validates
+// those replies itself.
+func responseFormat(rf *ResponseFormat, apiBase string) map[string]interface{} {
+	base := strings.ToLower(apiBase)
+	if strings.Contains(base, "deepseek.com") || strings.Contains(base, "moonshot") {
+		return map[string]interface{}{"type": "json_object"}
+	}
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   rf.Name,
+			"schema": rf.Schema,
+		},
+	}
+}
+
+// mergeLeadingSystem joins the system messages at the start of a request.
+// The agent sends its stable prompt and the per-turn context as separate
+// system messages; joined, the stable part is a byte-identical prefix that
+// automatic prefix caching can reuse, and servers whose chat templates accept
+// only one system message keep working.
+func mergeLeadingSystem(messages []Message) []Message {
+	n := 0
+	for n < len(messages) && messages[n].Role == "system" {
+		n++
+	}
+	if n < 2 {
+		return messages
+	}
+	parts := make([]string, 0, n)
+	for _, m := range messages[:n] {
+		if m.Content != "" {
+			parts = append(parts, m.Content)
+		}
+	}
+	merged := make([]Message, 0, len(messages)-n+1)
+	merged = append(merged, Message{Role: "system", Content: strings.Join(parts, "\n\n")})
+	return append(merged, messages[n:]...)
+}
+
+// withImages gives user messages with image attachments multi-part
+// content: the text, then an image_url part per image. Without attachments
+// the messages are sent unchanged.
+func withImages(messages []Message) interface{} {
+	hasMedia := false
+	for _, m := range messages {
+		if m.Role == "user" && len(m.Media) > 0 {
+			hasMedia = true
+			break
+		}
+	}
+	if !hasMedia {
+		return messages
+	}
+	out := make([]interface{}, len(messages))
+	for i, m := range messages {
+		if m.Role != "user" || len(m.Media) == 0 {
+			out[i] = m
+			continue
+		}
+		var parts []map[string]interface{}
+		if m.Content != "" {
+			parts = append(parts, map[string]interface{}{"type": "text", "text": m.Content})
+		}
+		for _, ref := range m.Media {
+			if u, ok := imageURL(ref); ok {
+				parts = append(parts, map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": u}})
+			}
+		}
+		out[i] = map[string]interface{}{"role": m.Role, "content": parts}
+	}
+	return out
+}
+
+// imageURL returns a URL for an image attachment: data and http(s) URLs as
+// they are, local image files as data URLs.
+func imageURL(ref string) (string, bool) {
+	if strings.HasPrefix(ref, "data:image/") || strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") {
+		return ref, true
+	}
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(ref)))
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", false
+	}
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		log.Printf("openai_compat: skipping image %q: %v", ref, err)
+		return "", false
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), true
+}
+
+// apiUsage covers the ways compatible APIs report cached prompt tokens:
+// prompt_tokens_details.cached_tokens (OpenAI, Groq, xAI), a top-level
+// cached_tokens (Moonshot) or prompt_cache_hit_tokens (DeepSeek).
+type apiUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	CachedTokens        int `json:"cached_tokens"`
+	PromptCacheHit      int `json:"prompt_cache_hit_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	CompletionTokensDetails *struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+func (u *apiUsage) toUsageInfo() *UsageInfo {
+	if u == nil {
+		return nil
+	}
+	cached := u.CachedTokens
+	if u.PromptTokensDetails != nil && u.PromptTokensDetails.CachedTokens > 0 {
+		cached = u.PromptTokensDetails.CachedTokens
+	}
+	if u.PromptCacheHit > 0 {
+		cached = u.PromptCacheHit
+	}
+	usage := &UsageInfo{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		CacheReadTokens:  cached,
+	}
+	if u.CompletionTokensDetails != nil {
+		usage.ReasoningTokens = u.CompletionTokensDetails.ReasoningTokens
+	}
+	return usage
 }
 
 func parseResponse(body []byte) (*LLMResponse, error) {
 	var apiResponse struct {
 		Choices []struct {
 			Message struct {
-				Content   string `json:"content"`
-				ToolCalls []struct {
+				Content          string `json:"content"`
+				ReasoningContent string `json:"reasoning_content"` // DeepSeek, Qwen, vLLM
+				Reasoning        string `json:"reasoning"`         // OpenRouter, Groq, Ollama
+				ToolCalls        []struct {
 					ID       string `json:"id"`
 					Type     string `json:"type"`
 					Function *struct {
@@ -137,7 +345,7 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 			} `json:"message"`
 			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
-		Usage *UsageInfo `json:"usage"`
+		Usage *apiUsage `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &apiResponse); err != nil {
@@ -174,14 +382,38 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 		})
 	}
 
+	content := choice.Message.Content
+	reasoning := choice.Message.ReasoningContent
+	if reasoning == "" {
+		reasoning = choice.Message.Reasoning
+	}
+	if reasoning == "" {
+		reasoning, content = splitThinkTag(content)
+	}
+
 	return &LLMResponse{
-		Content:      choice.Message.Content,
+		Content:      content,
+		Reasoning:    reasoning,
 		ToolCalls:    toolCalls,
 		FinishReason: choice.FinishReason,
-		Usage:        apiResponse.Usage,
+		Usage:        apiResponse.Usage.toUsageInfo(),
 	}, nil
 }
 
+// splitThinkTag separates the <think>...</think> block that some open
+// reasoning models put at the start of their content.
+func splitThinkTag(content string) (reasoning, rest string) {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "<think>") {
+		return "", content
+	}
+	end := strings.Index(trimmed, "</think>")
+	if end < 0 {
+		return "", content
+	}
+	return strings.TrimSpace(trimmed[len("<think>"):end]), strings.TrimSpace(trimmed[end+len("</think>"):])
+}
+
 func normalizeModel(model, apiBase string) string {
 	idx := strings.Index(model, "/")
 	if idx == -1 {
@@ -5,6 +5,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -275,3 +278,264 @@ func TestNormalizeModel_UsesAPIBase(t *testing.T) {
 		t.Fatalf("normalizeModel(openrouter) = %q, want %q", got, "openrouter/auto")
 	}
 }
+
+func TestProviderChat_MergesLeadingSystemMessages(t *testing.T) {
+	var requestBody struct {
+		Messages       []Message `json:"messages"`
+		PromptCacheKey string    `json:"prompt_cache_key"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	messages := []Message{
+		{Role: "system", Content: "stable prompt"},
+		{Role: "system", Content: "## Current Time\n12:00"},
+		{Role: "user", Content: "hi"},
+	}
+	if _, err := p.Chat(t.Context(), messages, nil, "gpt-4o", map[string]interface{}{"prompt_cache_key": "main"}); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if len(requestBody.Messages) != 2 {
+		t.Fatalf("len(messages) = %d, want 2", len(requestBody.Messages))
+	}
+	if got := requestBody.Messages[0].Content; got != "stable prompt\n\n## Current Time\n12:00" {
+		t.Errorf("system content = %q", got)
+	}
+	if requestBody.PromptCacheKey != "" {
+		t.Errorf("prompt_cache_key sent to a non-OpenAI endpoint: %q", requestBody.PromptCacheKey)
+	}
+}
+
+func TestProviderChat_SendsImageParts(t *testing.T) {
+	var requestBody struct {
+		Messages []json.RawMessage `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"content":"a cat"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	image := filepath.Join(t.TempDir(), "still.jpg")
+	os.WriteFile(image, []byte{0xFF, 0xD8, 0xFF, 0xD9}, 0644)
+
+	p := NewProvider("key", server.URL, "")
+	messages := []Message{
+		{Role: "system", Content: "prompt"},
+		{Role: "user", Content: "what is this?", Media: []string{image, "notes.txt", "https://example.com/a.png"}},
+	}
+	if _, err := p.Chat(t.Context(), messages, nil, "gpt-4o", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if len(requestBody.Messages) != 2 {
+		t.Fatalf("len(messages) = %d, want 2", len(requestBody.Messages))
+	}
+
+	var system Message
+	json.Unmarshal(requestBody.Messages[0], &system)
+	if system.Content != "prompt" {
+		t.Errorf("system message = %s", requestBody.Messages[0])
+	}
+	var user struct {
+		Role    string `json:"role"`
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			ImageURL struct {
+				URL string `json:"url"`
+			} `json:"image_url"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(requestBody.Messages[1], &user); err != nil {
+		t.Fatalf("user message = %s: %v", requestBody.Messages[1], err)
+	}
+	if len(user.Content) != 3 || user.Content[0].Text != "what is this?" {
+		t.Fatalf("user content = %s", requestBody.Messages[1])
+	}
+	if got := user.Content[1].ImageURL.URL; got != "data:image/jpeg;base64,/9j/2Q==" {
+		t.Errorf("local image URL = %q", got)
+	}
+	if got := user.Content[2].ImageURL.URL; got != "https://example.com/a.png" {
+		t.Errorf("remote image URL = %q", got)
+	}
+}
+
+func TestParseResponse_CachedTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		usage string
+		want  int
+	}{
+		{"openai", `{"prompt_tokens":2000,"completion_tokens":10,"total_tokens":2010,"prompt_tokens_details":{"cached_tokens":1536}}`, 1536},
+		{"deepseek", `{"prompt_tokens":2000,"completion_tokens":10,"total_tokens":2010,"prompt_cache_hit_tokens":1800,"prompt_cache_miss_tokens":200}`, 1800},
+		{"moonshot", `{"prompt_tokens":2000,"completion_tokens":10,"total_tokens":2010,"cached_tokens":1024}`, 1024},
+		{"none", `{"prompt_tokens":2000,"completion_tokens":10,"total_tokens":2010}`, 0},
+	}
+	for _, tt := range tests {
+		body := `{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}],"usage":` + tt.usage + `}`
+		resp, err := parseResponse([]byte(body))
+		if err != nil {
+			t.Fatalf("%s: parseResponse() error = %v", tt.name, err)
+		}
+		if resp.Usage.PromptTokens != 2000 || resp.Usage.CacheReadTokens != tt.want {
+			t.Errorf("%s: usage = %+v, want %d cached", tt.name, resp.Usage, tt.want)
+		}
+	}
+}
+
+func TestParseResponse_Reasoning(t *testing.T) {
+	tests := []struct {
+		name, message, wantReasoning, wantContent string
+	}{
+		{"reasoning_content", `{"content":"42","reasoning_content":"6 times 7"}`, "6 times 7", "42"},
+		{"reasoning", `{"content":"42","reasoning":"6 times 7"}`, "6 times 7", "42"},
+		{"think tag", `{"content":"<think>\n6 times 7\n</think>\n\n42"}`, "6 times 7", "42"},
+		{"unclosed think tag", `{"content":"<think>6 times"}`, "", "<think>6 times"},
+		{"plain", `{"content":"42"}`, "", "42"},
+	}
+	for _, tt := range tests {
+		body := `{"choices":[{"message":` + tt.message + `,"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":10,"completion_tokens":300,"total_tokens":310,"completion_tokens_details":{"reasoning_tokens":256}}}`
+		resp, err := parseResponse([]byte(body))
+		if err != nil {
+			t.Fatalf("%s: parseResponse() error = %v", tt.name, err)
+		}
+		if resp.Reasoning != tt.wantReasoning || resp.Content != tt.wantContent {
+			t.Errorf("%s: reasoning = %q, content = %q", tt.name, resp.Reasoning, resp.Content)
+		}
+		if resp.Usage.ReasoningTokens != 256 {
+			t.Errorf("%s: ReasoningTokens = %d, want 256", tt.name, resp.Usage.ReasoningTokens)
+		}
+	}
+}
+
+func TestProviderChat_ReasoningEffort(t *testing.T) {
+	var requestBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestBody = nil
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	options := map[string]interface{}{"temperature": 0.7, "reasoning_effort": "high"}
+	p := NewProvider("key", server.URL, "")
+	if _, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "o4-mini", options); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if requestBody["reasoning_effort"] != "high" {
+		t.Errorf("reasoning_effort = %v, want high", requestBody["reasoning_effort"])
+	}
+	if _, ok := requestBody["temperature"]; ok {
+		t.Error("temperature should not be sent with a reasoning effort")
+	}
+
+	// OpenRouter takes a unified reasoning object instead.
+	p = NewProvider("key", server.URL+"/openrouter.ai/api/v1", "")
+	if _, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "openai/o4-mini", options); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	reasoning, _ := requestBody["reasoning"].(map[string]interface{})
+	if reasoning["effort"] != "high" || requestBody["reasoning_effort"] != nil {
+		t.Errorf("openrouter request = %v", requestBody)
+	}
+}
+
+func TestProviderChat_ResponseFormat(t *testing.T) {
+	var requestBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestBody = nil
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"content":"{}"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	rf := &ResponseFormat{Name: "answer", Schema: map[string]interface{}{"type": "object"}}
+	options := map[string]interface{}{"response_format": rf}
+
+	p := NewProvider("key", server.URL, "")
+	if _, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", options); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	format, _ := requestBody["response_format"].(map[string]interface{})
+	schema, _ := format["json_schema"].(map[string]interface{})
+	if format["type"] != "json_schema" || schema["name"] != "answer" || schema["schema"] == nil {
+		t.Errorf("response_format = %v", requestBody["response_format"])
+	}
+
+	// DeepSeek only has JSON mode.
+	p = NewProvider("key", server.URL+"/api.deepseek.com", "")
+	if _, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "deepseek-chat", options); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	format, _ = requestBody["response_format"].(map[string]interface{})
+	if format["type"] != "json_object" || format["json_schema"] != nil {
+		t.Errorf("deepseek response_format = %v", requestBody["response_format"])
+	}
+}
+
+func TestProviderChat_ResponseFormatRejected(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		if body["response_format"] != nil {
+			http.Error(w, `{"error":"response_format is not supported"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"content":"{}"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	rf := &ResponseFormat{Name: "answer", Schema: map[string]interface{}{"type": "object"}}
+	p := NewProvider("key", server.URL, "")
+	out, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "local-model", map[string]interface{}{"response_format": rf})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if out.Content != "{}" || len(bodies) != 2 || bodies[1]["response_format"] != nil {
+		t.Errorf("content = %q after %d request(s), want a retry without response_format", out.Content, len(bodies))
+	}
+}
+
+func TestProviderProbe(t *testing.T) {
+	var path, auth string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	if err := p.Probe(t.Context(), "gpt-4o"); err != nil {
+		t.Fatalf("Probe() error = %v", err)
+	}
+	if path != "/models" || auth != "Bearer key" {
+		t.Errorf("request = %s with %q", path, auth)
+	}
+
+	status = http.StatusUnauthorized
+	if err := p.Probe(t.Context(), "gpt-4o"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Probe() error = %v, want status 401", err)
+	}
+}
@@ -0,0 +1,277 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probeWindow is the number of recent probes the error rate is computed over.
+const probeWindow = 20
+
+// ProbeTarget is one provider/model pair to probe.
+type ProbeTarget struct {
+	Provider string // cooldown key, as used by the fallback chain
+	Model    string
+	Client   LLMProvider
+}
+
+// ProbeStatus is the health of one provider/model pair.
+type ProbeStatus struct {
+	Provider        string       `json:"provider"`
+	Model           string       `json:"model"`
+	State           CircuitState `json:"state"`
+	Probes          int          `json:"probes"`
+	Failures        int          `json:"failures"`
+	ErrorRate       float64      `json:"error_rate"` // over the last probeWindow probes
+	LastLatencyMs   int64        `json:"last_latency_ms"`
+	AvgLatencyMs    int64        `json:"avg_latency_ms"` // successful probes only
+	LastError       string       `json:"last_error,omitempty"`
+	LastProbe       time.Time    `json:"last_probe"`
+	CooldownSeconds int          `json:"cooldown_seconds,omitempty"`
+}
+
+type probeStats struct {
+	ProbeStatus
+	recent    []bool // true for failures, oldest first
+	okLatency time.Duration
+	okCount   int
+}
+
+// Prober periodically checks each configured provider/model and feeds the
+// results into the CooldownTracker, so the fallback chain learns about an
+// outage before a user request hits it and a recovered provider is closed
+// again without waiting for traffic.
+//
+// Providers implementing ProbeProvider are checked without generating
+// tokens; the others get a one-token chat request. Providers whose circuit
+// is open are not probed until their cooldown expires.
+type Prober struct {
+	cooldown *CooldownTracker
+	targets  []ProbeTarget
+	timeout  time.Duration
+
+	mu       sync.Mutex
+	stats    map[string]*probeStats
+	onUpdate func([]ProbeStatus)
+	nowFunc  func() time.Time
+}
+
+// NewProber creates a prober for targets. Duplicate provider/model pairs are
+// probed once.
+func NewProber(cooldown *CooldownTracker, targets []ProbeTarget, timeout time.Duration) *Prober {
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	p := &Prober{
+		cooldown: cooldown,
+		timeout:  timeout,
+		stats:    make(map[string]*probeStats),
+		nowFunc:  time.Now,
+	}
+	for _, t := range targets {
+		key := ModelKey(t.Provider, t.Model)
+		if _, ok := p.stats[key]; ok || t.Client == nil {
+			continue
+		}
+		p.targets = append(p.targets, t)
+		p.stats[key] = &probeStats{ProbeStatus: ProbeStatus{Provider: t.Provider, Model: t.Model, State: CircuitClosed}}
+	}
+	return p
+}
+
+// OnUpdate sets a function called with the statuses after each probe round.
+func (p *Prober) OnUpdate(fn func([]ProbeStatus)) {
+	p.mu.Lock()
+	p.onUpdate = fn
+	p.mu.Unlock()
+}
+
+// Run probes every interval until ctx is done, starting immediately.
+func (p *Prober) Run(ctx context.Context, interval time.Duration) {
+	if len(p.targets) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.ProbeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProbeAll probes every target once, concurrently, and returns the statuses.
+func (p *Prober) ProbeAll(ctx context.Context) []ProbeStatus {
+	var wg sync.WaitGroup
+	for _, t := range p.targets {
+		if p.cooldown.State(t.Provider) == CircuitOpen {
+			continue
+		}
+		wg.Add(1)
+		go func(t ProbeTarget) {
+			defer wg.Done()
+			p.probe(ctx, t)
+		}(t)
+	}
+	wg.Wait()
+
+	statuses := p.Status()
+	p.mu.Lock()
+	fn := p.onUpdate
+	p.mu.Unlock()
+	if fn != nil {
+		fn(statuses)
+	}
+	return statuses
+}
+
+func (p *Prober) probe(ctx context.Context, t ProbeTarget) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	start := p.nowFunc()
+	var err error
+	prober, light := t.Client.(ProbeProvider)
+	if light {
+		err = prober.Probe(ctx, t.Model)
+	} else {
+		_, err = t.Client.Chat(ctx, []Message{{Role: "user", Content: "ping"}}, nil, t.Model,
+			map[string]interface{}{"max_tokens": 1, "prompt_cache": false})
+	}
+	latency := p.nowFunc().Sub(start)
+
+	// Only a completion resets the backoff; a lighter probe such as listing
+	// models may succeed while completions are still rate limited.
+	if err == nil && light {
+		p.cooldown.MarkProbeSuccess(t.Provider)
+	} else if err == nil {
+		p.cooldown.MarkSuccess(t.Provider)
+	} else {
+		if ctx.Err() == context.Canceled {
+			return // shutting down, not a provider failure
+		}
+		reason := FailoverUnknown
+		if fe := ClassifyError(err, t.Provider, t.Model); fe != nil {
+			reason = fe.Reason
+		}
+		p.cooldown.MarkFailure(t.Provider, reason)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stats[ModelKey(t.Provider, t.Model)]
+	s.Probes++
+	s.LastProbe = start
+	s.LastLatencyMs = latency.Milliseconds()
+	s.recent = append(s.recent, err != nil)
+	if len(s.recent) > probeWindow {
+		s.recent = s.recent[len(s.recent)-probeWindow:]
+	}
+	if err != nil {
+		s.Failures++
+		s.LastError = err.Error()
+		return
+	}
+	s.LastError = ""
+	s.okCount++
+	s.okLatency += latency
+	s.AvgLatencyMs = (s.okLatency / time.Duration(s.okCount)).Milliseconds()
+}
+
+// Status returns the current status of every target, sorted by provider and
+// model, with circuit state and cooldown taken from the CooldownTracker.
+func (p *Prober) Status() []ProbeStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make([]ProbeStatus, 0, len(p.stats))
+	for _, s := range p.stats {
+		st := s.ProbeStatus
+		st.State = p.cooldown.State(st.Provider)
+		st.CooldownSeconds = int(p.cooldown.CooldownRemaining(st.Provider).Round(time.Second).Seconds())
+		if n := len(s.recent); n > 0 {
+			failed := 0
+			for _, f := range s.recent {
+				if f {
+					failed++
+				}
+			}
+			st.ErrorRate = float64(failed) / float64(n)
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+// ProbeReadiness reports whether any probed provider can take requests. It
+// fails only when every provider's circuit is open, since the fallback chain
+// routes around single outages.
+func ProbeReadiness(statuses []ProbeStatus) (bool, string) {
+	if len(statuses) == 0 {
+		return true, "no providers probed"
+	}
+	var open []string
+	for _, s := range statuses {
+		if s.State == CircuitOpen {
+			open = append(open, fmt.Sprintf("%s (%ds)", ModelKey(s.Provider, s.Model), s.CooldownSeconds))
+		}
+	}
+	if len(open) == len(statuses) {
+		return false, "all providers unavailable: " + strings.Join(open, ", ")
+	}
+	if len(open) > 0 {
+		return true, "open circuits: " + strings.Join(open, ", ")
+	}
+	return true, fmt.Sprintf("%d providers healthy", len(statuses))
+}
+
+// ProbeReport is the probe state saved for `picoclaw providers status`.
+type ProbeReport struct {
+	Updated  time.Time     `json:"updated"`
+	Statuses []ProbeStatus `json:"statuses"`
+}
+
+// SaveProbeReport writes statuses to path atomically.
+func SaveProbeReport(path string, statuses []ProbeStatus) error {
+	data, err := json.MarshalIndent(ProbeReport{Updated: time.Now(), Statuses: statuses}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadProbeReport reads a report written by SaveProbeReport.
+func LoadProbeReport(path string) (*ProbeReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r ProbeReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &r, nil
+}
@@ -0,0 +1,120 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type probeStub struct {
+	err   error
+	calls int
+}
+
+func (s *probeStub) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	return nil, errors.New("chat should not be used by probes")
+}
+
+func (s *probeStub) GetDefaultModel() string { return "" }
+
+func (s *probeStub) Probe(ctx context.Context, model string) error {
+	s.calls++
+	return s.err
+}
+
+type chatOnlyStub struct {
+	options map[string]interface{}
+}
+
+func (s *chatOnlyStub) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	s.options = options
+	return &LLMResponse{Content: "p"}, nil
+}
+
+func (s *chatOnlyStub) GetDefaultModel() string { return "" }
+
+func TestProber_OpensAndClosesCircuit(t *testing.T) {
+	now := time.Now()
+	ct, current := newTestTracker(now)
+	openai := &probeStub{err: errors.New("status: 503 service unavailable")}
+	cli := &chatOnlyStub{}
+	p := NewProber(ct, []ProbeTarget{
+		{Provider: "openai", Model: "gpt-4o", Client: openai},
+		{Provider: "openai", Model: "gpt-4o", Client: openai}, // duplicate
+		{Provider: "claude-cli", Model: "sonnet", Client: cli},
+	}, time.Second)
+
+	var updates [][]ProbeStatus
+	p.OnUpdate(func(s []ProbeStatus) { updates = append(updates, s) })
+
+	statuses := p.ProbeAll(context.Background())
+	if len(statuses) != 2 || len(updates) != 1 {
+		t.Fatalf("statuses = %+v, updates = %d", statuses, len(updates))
+	}
+	if s := statuses[1]; s.Provider != "openai" || s.State != CircuitOpen || s.ErrorRate != 1 || s.CooldownSeconds != 60 || !strings.Contains(s.LastError, "503") {
+		t.Errorf("openai status = %+v", s)
+	}
+	if s := statuses[0]; s.State != CircuitClosed || s.Probes != 1 || cli.options["max_tokens"] != 1 {
+		t.Errorf("claude-cli status = %+v, options = %v", s, cli.options)
+	}
+
+	// An open circuit is not probed until the cooldown expires.
+	p.ProbeAll(context.Background())
+	if openai.calls != 1 {
+		t.Errorf("probes while open = %d, want 1", openai.calls)
+	}
+
+	*current = now.Add(61 * time.Second)
+	if got := ct.State("openai"); got != CircuitHalfOpen {
+		t.Fatalf("state after cooldown = %s", got)
+	}
+	openai.err = nil
+	statuses = p.ProbeAll(context.Background())
+	if s := statuses[1]; s.State != CircuitClosed || s.Probes != 2 || s.Failures != 1 || s.ErrorRate != 0.5 || s.LastError != "" {
+		t.Errorf("recovered status = %+v", s)
+	}
+
+	// A successful probe keeps the backoff, so the next failure escalates.
+	if ct.ErrorCount("openai") != 1 {
+		t.Errorf("error count after probe = %d, want 1", ct.ErrorCount("openai"))
+	}
+	ct.MarkFailure("openai", FailoverRateLimit)
+	if got := ct.CooldownRemaining("openai"); got != 5*time.Minute {
+		t.Errorf("cooldown after next failure = %v, want 5m", got)
+	}
+}
+
+func TestProbeReadiness(t *testing.T) {
+	if ok, _ := ProbeReadiness(nil); !ok {
+		t.Error("no providers should be ready")
+	}
+	statuses := []ProbeStatus{
+		{Provider: "openai", Model: "gpt-4o", State: CircuitOpen, CooldownSeconds: 60},
+		{Provider: "anthropic", Model: "claude-sonnet-4", State: CircuitHalfOpen},
+	}
+	if ok, msg := ProbeReadiness(statuses); !ok || !strings.Contains(msg, "openai/gpt-4o (60s)") {
+		t.Errorf("one open = %v, %q", ok, msg)
+	}
+	statuses[1].State = CircuitOpen
+	if ok, msg := ProbeReadiness(statuses); ok || !strings.Contains(msg, "all providers unavailable") {
+		t.Errorf("all open = %v, %q", ok, msg)
+	}
+}
+
+func TestProbeReport_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "providers.json")
+	in := []ProbeStatus{{Provider: "ollama", Model: "qwen3", State: CircuitClosed, Probes: 3, AvgLatencyMs: 12}}
+	if err := SaveProbeReport(path, in); err != nil {
+		t.Fatalf("SaveProbeReport() error: %v", err)
+	}
+	out, err := LoadProbeReport(path)
+	if err != nil {
+		t.Fatalf("LoadProbeReport() error: %v", err)
+	}
+	if len(out.Statuses) != 1 || out.Statuses[0] != in[0] || time.Since(out.Updated) > time.Minute {
+		t.Errorf("report = %+v", out)
+	}
+}
@@ -14,23 +14,51 @@ type FunctionCall struct {
 }
 
 type LLMResponse struct {
-	Content      string     `json:"content"`
-	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
-	FinishReason string     `json:"finish_reason"`
-	Usage        *UsageInfo `json:"usage,omitempty"`
+	Content      string          `json:"content"`
+	Reasoning    string          `json:"reasoning,omitempty"` // thinking text or summary from reasoning models
+	Thinking     []ThinkingBlock `json:"thinking,omitempty"`  // blocks to send back with the assistant message in tool loops
+	ToolCalls    []ToolCall      `json:"tool_calls,omitempty"`
+	FinishReason string          `json:"finish_reason"`
+	Usage        *UsageInfo      `json:"usage,omitempty"`
+}
+
+// ThinkingBlock is a signed reasoning block. Anthropic requires the blocks of
+// the last assistant message to be sent back unchanged while a tool loop runs.
+type ThinkingBlock struct {
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Redacted  string `json:"redacted,omitempty"` // opaque data of a redacted_thinking block
 }
 
 type UsageInfo struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`  // prompt tokens served from the provider's cache
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"` // prompt tokens written to the provider's cache
+	ReasoningTokens  int `json:"reasoning_tokens,omitempty"`   // completion tokens spent thinking, included in CompletionTokens
+}
+
+// Add adds the token counts of o to u.
+func (u *UsageInfo) Add(o *UsageInfo) {
+	if o == nil {
+		return
+	}
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.TotalTokens += o.TotalTokens
+	u.CacheReadTokens += o.CacheReadTokens
+	u.CacheWriteTokens += o.CacheWriteTokens
+	u.ReasoningTokens += o.ReasoningTokens
 }
 
 type Message struct {
-	Role       string     `json:"role"`
-	Content    string     `json:"content"`
-	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
-	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Role       string          `json:"role"`
+	Content    string          `json:"content"`
+	ToolCalls  []ToolCall      `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	Media      []string        `json:"-"` // local file paths or data URLs attached to this turn; not persisted
+	Thinking   []ThinkingBlock `json:"-"` // reasoning blocks of an assistant tool-call message; not persisted
 }
 
 type ToolDefinition struct {
@@ -43,3 +71,11 @@ type ToolFunctionDefinition struct {
 	Description string                 `json:"description"`
 	Parameters  map[string]interface{} `json:"parameters"`
 }
+
+// ResponseFormat asks for a reply that is a single JSON value matching
+// Schema. It is passed to Chat as options["response_format"]; providers with
+// native structured output enforce it, the agent validates it for the rest.
+type ResponseFormat struct {
+	Name   string                 `json:"name"`   // schema identifier, [a-zA-Z0-9_-] only
+	Schema map[string]interface{} `json:"schema"` // JSON Schema (draft-07 or 2020-12)
+}
@@ -0,0 +1,31 @@
+package providers
+
+import "strings"
+
+// reasoningModels are name fragments of model families that accept
+// reasoning_effort or a thinking budget.
+var reasoningModels = []string{
+	"o1", "o3", "o4-mini", "gpt-5", "gpt-oss",
+	"claude-3-7", "claude-sonnet-4", "claude-opus-4", "claude-haiku-4", "claude-4",
+	"gemini-2.5", "gemini-3",
+	"deepseek-r1", "deepseek-reasoner",
+	"qwq", "qwen3",
+	"grok-3-mini", "grok-4",
+	"magistral", "thinking",
+}
+
+// SupportsReasoning reports whether model is known to accept reasoning
+// options. Provider prefixes such as "openrouter/openai/" are ignored.
+func SupportsReasoning(model string) bool {
+	name := strings.ToLower(model)
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	for _, fragment := range reasoningModels {
+		if name == fragment || strings.HasPrefix(name, fragment+"-") || strings.HasPrefix(name, fragment+".") ||
+			strings.HasPrefix(name, fragment+":") || strings.Contains(name, "-"+fragment) {
+			return true
+		}
+	}
+	return false
+}
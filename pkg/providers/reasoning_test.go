@@ -0,0 +1,32 @@
+package providers
+
+import "testing"
+
+func TestSupportsReasoning(t *testing.T) {
+	tests := []struct {
+		model string
+		want  bool
+	}{
+		{"o3-mini", true},
+		{"o1", true},
+		{"openai/gpt-5", true},
+		{"claude-sonnet-4-5", true},
+		{"anthropic/claude-3-7-sonnet-latest", true},
+		{"gemini-2.5-flash", true},
+		{"deepseek-r1:14b", true},
+		{"deepseek/deepseek-reasoner", true},
+		{"qwen3:8b", true},
+		{"moonshotai/kimi-k2-thinking", true},
+		{"gpt-4o", false},
+		{"gpt-4o-mini", false},
+		{"llama-3.3-70b-versatile", false},
+		{"claude-3-5-haiku-latest", false},
+		{"gemini-2.0-flash", false},
+		{"mistral-large", false},
+	}
+	for _, tt := range tests {
+		if got := SupportsReasoning(tt.model); got != tt.want {
+			t.Errorf("SupportsReasoning(%q) = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// ProviderRegistry hands out one LLMProvider per configured provider,
+// creating each client on first use. It lets fallback candidates and
+// agents talk to different vendors within the same process.
+type ProviderRegistry struct {
+	cfg     *config.Config
+	mu      sync.Mutex
+	clients map[string]LLMProvider
+	errs    map[string]error
+	wrapper ClientWrapper
+}
+
+// NewProviderRegistry creates a registry for cfg. defaultProvider, if not
+// nil, is registered under the configured default provider name so it is
+// reused instead of creating a second client for the same vendor. If it is
+// a ClientWrapper, created clients are passed through it.
+func NewProviderRegistry(cfg *config.Config, defaultProvider LLMProvider) *ProviderRegistry {
+	r := &ProviderRegistry{
+		cfg:     cfg,
+		clients: make(map[string]LLMProvider),
+		errs:    make(map[string]error),
+	}
+	if w, ok := defaultProvider.(ClientWrapper); ok {
+		r.wrapper = w
+	}
+	if defaultProvider != nil && cfg != nil {
+		if name := NormalizeProvider(cfg.Agents.Defaults.Provider); name != "" {
+			r.clients[name] = defaultProvider
+		}
+	}
+	return r
+}
+
+// Register sets the client used for a provider name, replacing any
+// previously created one.
+func (r *ProviderRegistry) Register(name string, provider LLMProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name = NormalizeProvider(name)
+	r.clients[name] = provider
+	delete(r.errs, name)
+}
+
+// Get returns the client for the named provider, creating it from the
+// provider's section of the config on first use. Creation failures are
+// remembered so a misconfigured provider is not retried on every call.
+func (r *ProviderRegistry) Get(name string) (LLMProvider, error) {
+	name = NormalizeProvider(name)
+	if name == "" {
+		return nil, fmt.Errorf("provider name is empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p, ok := r.clients[name]; ok {
+		return p, nil
+	}
+	if err, ok := r.errs[name]; ok {
+		return nil, err
+	}
+
+	p, err := r.create(name)
+	if err != nil {
+		r.errs[name] = err
+		return nil, err
+	}
+	if r.wrapper != nil {
+		p = r.wrapper.WrapClient(name, p)
+	}
+	r.clients[name] = p
+	return p, nil
+}
+
+func (r *ProviderRegistry) create(name string) (LLMProvider, error) {
+	if r.cfg == nil {
+		return nil, fmt.Errorf("provider %q is not configured", name)
+	}
+
+	sel := providerSelection{providerType: providerTypeHTTPCompat}
+	if !explicitProviderSelection(r.cfg, strings.ToLower(name), "", &sel) {
+		if sel.apiBase == "" {
+			return nil, fmt.Errorf("provider %q is not configured", name)
+		}
+		if sel.apiKey == "" && name != "vllm" {
+			return nil, fmt.Errorf("no API key configured for provider %q", name)
+		}
+	}
+	return createFromSelection(sel)
+}
@@ -0,0 +1,71 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestProviderRegistry_GetCreatesOncePerProvider(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Providers.Groq.APIKey = "groq-key"
+
+	r := NewProviderRegistry(cfg, nil)
+	p1, err := r.Get("groq")
+	if err != nil {
+		t.Fatalf("Get(groq) error: %v", err)
+	}
+	p2, err := r.Get("GROQ")
+	if err != nil {
+		t.Fatalf("Get(GROQ) error: %v", err)
+	}
+	if p1 != p2 {
+		t.Error("expected the same client for repeated lookups")
+	}
+	if _, ok := p1.(*HTTPProvider); !ok {
+		t.Errorf("provider type = %T, want *HTTPProvider", p1)
+	}
+}
+
+func TestProviderRegistry_Unconfigured(t *testing.T) {
+	cfg := config.DefaultConfig()
+	r := NewProviderRegistry(cfg, nil)
+
+	if _, err := r.Get("groq"); err == nil {
+		t.Error("expected error for provider without API key")
+	}
+	if _, err := r.Get("no-such-provider"); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+	if _, err := r.Get(""); err == nil {
+		t.Error("expected error for empty name")
+	}
+}
+
+func TestProviderRegistry_DefaultAndRegister(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Provider = "claude"
+	def := &HTTPProvider{}
+
+	r := NewProviderRegistry(cfg, def)
+	got, err := r.Get("anthropic")
+	if err != nil || got != LLMProvider(def) {
+		t.Errorf("Get(anthropic) = %v, %v; want default provider", got, err)
+	}
+
+	other := &HTTPProvider{}
+	r.Register("groq", other)
+	if got, err := r.Get("groq"); err != nil || got != LLMProvider(other) {
+		t.Errorf("Get(groq) = %v, %v; want registered provider", got, err)
+	}
+}
+
+func TestProviderRegistry_OllamaWithoutKey(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Providers.Ollama.APIBase = "http://localhost:11434/v1"
+
+	r := NewProviderRegistry(cfg, nil)
+	if _, err := r.Get("ollama"); err != nil {
+		t.Errorf("Get(ollama) error: %v", err)
+	}
+}
@@ -0,0 +1,459 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cassetteVersion is the format version written to cassette files.
+const cassetteVersion = 1
+
+// Placeholders substituted into recorded requests.
+const (
+	redactedPlaceholder = "<redacted>"
+	timePlaceholder     = "<time>"
+)
+
+var (
+	// secretPatterns match credentials that may end up in prompts or tool
+	// output even when they are not in the config.
+	secretPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`sk-ant-[A-Za-z0-9_\-]{16,}`),
+		regexp.MustCompile(`sk-[A-Za-z0-9_\-]{16,}`),
+		regexp.MustCompile(`AIza[0-9A-Za-z_\-]{30,}`),
+		regexp.MustCompile(`gsk_[A-Za-z0-9]{16,}`),
+		regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._\-]{16,}`),
+	}
+	// timePatterns match the timestamps the context builder and tools put
+	// into messages, which differ between the recording and the replay.
+	timePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:?\d{2})?( \([A-Za-z]+\))?`),
+		regexp.MustCompile(`\d{4}-\d{2}-\d{2}`),
+		regexp.MustCompile(`\b\d{1,2}:\d{2}(:\d{2})?\b`),
+	}
+)
+
+// CassetteOptions controls how requests are normalized before they are
+// written to or matched against a cassette.
+type CassetteOptions struct {
+	Secrets []string          // literal values replaced with <redacted>, such as API keys
+	Paths   map[string]string // path prefixes replaced with placeholders, such as workspace -> <workspace>
+}
+
+// CassetteRequest is the normalized part of a Chat call that replay matches
+// on. System messages are left out: they carry the date, memory and skill
+// listings, which change without changing what the agent was asked.
+type CassetteRequest struct {
+	Model          string    `json:"model"`
+	Messages       []Message `json:"messages"`
+	Tools          []string  `json:"tools,omitempty"`
+	ResponseFormat string    `json:"response_format,omitempty"`
+}
+
+// CassetteInteraction is one recorded Chat call.
+type CassetteInteraction struct {
+	Provider string          `json:"provider,omitempty"`
+	Request  CassetteRequest `json:"request"`
+	Response *LLMResponse    `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+type cassetteFile struct {
+	Version      int                   `json:"version"`
+	Recorded     time.Time             `json:"recorded"`
+	Interactions []CassetteInteraction `json:"interactions"`
+}
+
+// Cassette holds the LLM traffic of one agent run. It is written by
+// RecordProvider and served by ReplayProvider, so agent behaviour can be
+// tested deterministically without network access or API keys.
+type Cassette struct {
+	path     string
+	opts     CassetteOptions
+	mu       sync.Mutex
+	file     cassetteFile
+	used     []bool
+	replaced []string // Paths keys, longest first
+}
+
+// NewCassette creates an empty cassette that is saved to path as calls are
+// recorded.
+func NewCassette(path string, opts CassetteOptions) *Cassette {
+	c := &Cassette{path: path, opts: opts}
+	c.file = cassetteFile{Version: cassetteVersion, Recorded: time.Now()}
+	c.init()
+	return c
+}
+
+// LoadCassette reads a cassette written by a recording run.
+func LoadCassette(path string, opts CassetteOptions) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	c := &Cassette{path: path, opts: opts}
+	if err := json.Unmarshal(data, &c.file); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	if c.file.Version != cassetteVersion {
+		return nil, fmt.Errorf("cassette %s has version %d, want %d", path, c.file.Version, cassetteVersion)
+	}
+	c.used = make([]bool, len(c.file.Interactions))
+	c.init()
+	return c, nil
+}
+
+func (c *Cassette) init() {
+	for prefix := range c.opts.Paths {
+		if prefix != "" {
+			c.replaced = append(c.replaced, prefix)
+		}
+	}
+	sort.Slice(c.replaced, func(i, j int) bool { return len(c.replaced[i]) > len(c.replaced[j]) })
+}
+
+// Len returns the number of recorded interactions.
+func (c *Cassette) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.file.Interactions)
+}
+
+// Unused returns the number of recorded interactions a replay has not
+// served yet. A non-zero count after a run means the agent made fewer calls
+// than when it was recorded.
+func (c *Cassette) Unused() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, u := range c.used {
+		if !u {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Cassette) record(provider string, req CassetteRequest, resp *LLMResponse, callErr error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	in := CassetteInteraction{Provider: provider, Request: req}
+	if callErr != nil {
+		in.Error = c.scrub(callErr.Error())
+	} else if resp != nil {
+		r := *resp
+		r.Content = c.generalize(c.redact(r.Content))
+		r.Reasoning = c.generalize(c.redact(r.Reasoning))
+		r.ToolCalls = c.scrubToolCalls(r.ToolCalls, func(s string) string { return c.generalize(c.redact(s)) })
+		in.Response = &r
+	}
+	c.file.Interactions = append(c.file.Interactions, in)
+	return c.save()
+}
+
+func (c *Cassette) save() error {
+	data, err := json.MarshalIndent(c.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// play returns the response recorded for req. Interactions are served in
+// order; a request may also match a later unused interaction so that
+// concurrent calls, such as parallel subagents, replay in any order.
+func (c *Cassette) play(req CassetteRequest) (*LLMResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	next := -1
+	for i, in := range c.file.Interactions {
+		if c.used[i] {
+			continue
+		}
+		if next < 0 {
+			next = i
+		}
+		if reflect.DeepEqual(normalizeJSON(in.Request), normalizeJSON(req)) {
+			c.used[i] = true
+			if in.Error != "" {
+				return nil, fmt.Errorf("%s", in.Error)
+			}
+			if in.Response == nil {
+				return nil, fmt.Errorf("replay: interaction %d has no response", i+1)
+			}
+			resp := *in.Response
+			resp.Content = c.localize(resp.Content)
+			resp.Reasoning = c.localize(resp.Reasoning)
+			resp.ToolCalls = c.scrubToolCalls(resp.ToolCalls, c.localize)
+			return &resp, nil
+		}
+	}
+	if next < 0 {
+		return nil, &ReplayMismatchError{Index: len(c.file.Interactions), Detail: "cassette has no more recorded interactions"}
+	}
+	return nil, &ReplayMismatchError{Index: next, Detail: describeMismatch(c.file.Interactions[next].Request, req)}
+}
+
+// request normalizes a Chat call for recording and matching.
+func (c *Cassette) request(messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) CassetteRequest {
+	req := CassetteRequest{Model: bareModel(model)}
+	for _, m := range messages {
+		if m.Role == "system" {
+			continue
+		}
+		req.Messages = append(req.Messages, Message{
+			Role:       m.Role,
+			Content:    c.scrub(m.Content),
+			ToolCalls:  c.scrubToolCalls(m.ToolCalls, c.scrub),
+			ToolCallID: m.ToolCallID,
+		})
+	}
+	for _, t := range tools {
+		req.Tools = append(req.Tools, t.Function.Name)
+	}
+	sort.Strings(req.Tools)
+	if rf, ok := options["response_format"].(*ResponseFormat); ok && rf != nil {
+		req.ResponseFormat = rf.Name
+	}
+	return req
+}
+
+func (c *Cassette) scrubToolCalls(calls []ToolCall, fn func(string) string) []ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]ToolCall, len(calls))
+	for i, tc := range calls {
+		out[i] = tc
+		if tc.Function != nil {
+			f := *tc.Function
+			f.Arguments = fn(f.Arguments)
+			out[i].Function = &f
+		}
+		if tc.Arguments != nil {
+			// Keep placeholders such as <workspace> unescaped.
+			var buf strings.Builder
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			var args map[string]interface{}
+			if enc.Encode(tc.Arguments) == nil && json.Unmarshal([]byte(fn(buf.String())), &args) == nil {
+				out[i].Arguments = args
+			}
+		}
+	}
+	return out
+}
+
+// redact removes secrets from s.
+func (c *Cassette) redact(s string) string {
+	for _, secret := range c.opts.Secrets {
+		if len(secret) >= 8 {
+			s = strings.ReplaceAll(s, secret, redactedPlaceholder)
+		}
+	}
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllStringFunc(s, func(m string) string {
+			if sub := re.FindStringSubmatch(m); len(sub) > 1 {
+				return sub[1] + redactedPlaceholder
+			}
+			return redactedPlaceholder
+		})
+	}
+	return s
+}
+
+// generalize replaces local paths with their placeholders.
+func (c *Cassette) generalize(s string) string {
+	for _, prefix := range c.replaced {
+		s = strings.ReplaceAll(s, prefix, c.opts.Paths[prefix])
+	}
+	return s
+}
+
+// localize expands path placeholders in a recorded response to this
+// machine's paths, so replayed tool calls touch the local workspace.
+func (c *Cassette) localize(s string) string {
+	for _, prefix := range c.replaced {
+		s = strings.ReplaceAll(s, c.opts.Paths[prefix], prefix)
+	}
+	return s
+}
+
+// scrub redacts s and replaces timestamps and local paths with placeholders.
+func (c *Cassette) scrub(s string) string {
+	s = c.generalize(c.redact(s))
+	for _, re := range timePatterns {
+		s = re.ReplaceAllString(s, timePlaceholder)
+	}
+	return s
+}
+
+// ReplayMismatchError is returned when a replayed agent makes a request that
+// is not in the cassette.
+type ReplayMismatchError struct {
+	Index  int // index of the recorded interaction that was expected next
+	Detail string
+}
+
+func (e *ReplayMismatchError) Error() string {
+	return fmt.Sprintf("replay mismatch at interaction %d: %s", e.Index+1, e.Detail)
+}
+
+// describeMismatch reports the first difference between a recorded request
+// and the actual one.
+func describeMismatch(want, got CassetteRequest) string {
+	if want.Model != got.Model {
+		return fmt.Sprintf("model: recorded %q, got %q", want.Model, got.Model)
+	}
+	if !reflect.DeepEqual(want.Tools, got.Tools) {
+		return fmt.Sprintf("tools: recorded %v, got %v", want.Tools, got.Tools)
+	}
+	if want.ResponseFormat != got.ResponseFormat {
+		return fmt.Sprintf("response format: recorded %q, got %q", want.ResponseFormat, got.ResponseFormat)
+	}
+	for i := 0; i < len(want.Messages) || i < len(got.Messages); i++ {
+		if i >= len(want.Messages) {
+			return fmt.Sprintf("message %d: not recorded, got %s", i+1, describeMessage(got.Messages[i]))
+		}
+		if i >= len(got.Messages) {
+			return fmt.Sprintf("message %d: recorded %s, got none", i+1, describeMessage(want.Messages[i]))
+		}
+		w, _ := json.Marshal(want.Messages[i])
+		g, _ := json.Marshal(got.Messages[i])
+		if string(w) != string(g) {
+			return fmt.Sprintf("message %d: recorded %s, got %s", i+1, describeMessage(want.Messages[i]), describeMessage(got.Messages[i]))
+		}
+	}
+	return "requests differ"
+}
+
+func describeMessage(m Message) string {
+	content := m.Content
+	if len([]rune(content)) > 120 {
+		content = string([]rune(content)[:120]) + "..."
+	}
+	s := fmt.Sprintf("%s %q", m.Role, content)
+	for _, tc := range m.ToolCalls {
+		name, args := tc.Name, ""
+		if tc.Function != nil {
+			name, args = tc.Function.Name, tc.Function.Arguments
+		} else if tc.Arguments != nil {
+			data, _ := json.Marshal(tc.Arguments)
+			args = string(data)
+		}
+		s += " +call " + name + args
+	}
+	return s
+}
+
+// normalizeJSON round-trips v through JSON so that values read from a
+// cassette compare equal to freshly built ones.
+func normalizeJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out interface{}
+	_ = json.Unmarshal(data, &out)
+	return out
+}
+
+// bareModel strips provider prefixes, which depend on which providers were
+// configured, from a model name.
+func bareModel(model string) string {
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		return model[i+1:]
+	}
+	return model
+}
+
+// ClientWrapper is implemented by providers that intercept the traffic of
+// other providers. A ProviderRegistry whose default provider is a
+// ClientWrapper passes every client it creates through WrapClient, so the
+// calls of per-agent providers and fallback candidates are intercepted too.
+type ClientWrapper interface {
+	WrapClient(name string, p LLMProvider) LLMProvider
+}
+
+// RecordProvider passes calls to a real provider and records each request
+// and response in a cassette.
+type RecordProvider struct {
+	inner    LLMProvider
+	name     string
+	cassette *Cassette
+}
+
+// NewRecordProvider records the calls made to inner in cassette.
+func NewRecordProvider(inner LLMProvider, name string, cassette *Cassette) *RecordProvider {
+	return &RecordProvider{inner: inner, name: name, cassette: cassette}
+}
+
+func (p *RecordProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	req := p.cassette.request(messages, tools, model, options)
+	resp, err := p.inner.Chat(ctx, messages, tools, model, options)
+	if ctx.Err() != nil {
+		return resp, err // cancelled calls are not part of the conversation
+	}
+	if saveErr := p.cassette.record(p.name, req, resp, err); saveErr != nil {
+		return nil, fmt.Errorf("failed to save cassette: %w", saveErr)
+	}
+	return resp, err
+}
+
+func (p *RecordProvider) GetDefaultModel() string {
+	return p.inner.GetDefaultModel()
+}
+
+// WrapClient records the calls made to another provider in the same cassette.
+func (p *RecordProvider) WrapClient(name string, inner LLMProvider) LLMProvider {
+	return NewRecordProvider(inner, name, p.cassette)
+}
+
+// ReplayProvider serves the responses recorded in a cassette without
+// contacting any provider. A request that does not match a recorded one
+// fails with a ReplayMismatchError.
+type ReplayProvider struct {
+	cassette *Cassette
+	model    string
+}
+
+// NewReplayProvider serves responses from cassette. model is returned by
+// GetDefaultModel.
+func NewReplayProvider(cassette *Cassette, model string) *ReplayProvider {
+	return &ReplayProvider{cassette: cassette, model: model}
+}
+
+func (p *ReplayProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.cassette.play(p.cassette.request(messages, tools, model, options))
+}
+
+func (p *ReplayProvider) GetDefaultModel() string {
+	return p.model
+}
+
+// WrapClient replaces every other provider with the replay, so no request
+// leaves the process.
+func (p *ReplayProvider) WrapClient(name string, inner LLMProvider) LLMProvider {
+	return p
+}
@@ -0,0 +1,292 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// DefaultTier is the routing tier that uses the agent's own model and fallbacks.
+const DefaultTier = "default"
+
+const (
+	classifierTimeout  = 15 * time.Second
+	classifierMaxChars = 2000
+)
+
+// RouteTier is a named model with its fallback candidates.
+type RouteTier struct {
+	Name        string
+	Model       string
+	Description string
+	Candidates  []FallbackCandidate
+}
+
+// RouteRequest describes the turn being routed.
+type RouteRequest struct {
+	Content  string
+	HasMedia bool
+	Channel  string
+}
+
+// RouteDecision records which tier a turn was routed to and why. Model and
+// Candidates are empty for DefaultTier, meaning the agent's own model.
+type RouteDecision struct {
+	Tier       string
+	Model      string
+	Candidates []FallbackCandidate
+	Reason     string
+	Escalated  bool
+	At         time.Time
+}
+
+// ClassifyFunc sends a single system+user prompt to model and returns the reply text.
+type ClassifyFunc func(ctx context.Context, model, system, user string) (string, error)
+
+type routeRule struct {
+	config.RoutingRule
+	index   int
+	pattern *regexp.Regexp
+}
+
+// ModelRouter picks a model tier for each turn before the fallback chain
+// runs: small or local models for chit-chat, larger ones for coding or
+// tool-heavy work.
+type ModelRouter struct {
+	tiers       map[string]*RouteTier
+	order       []string
+	defaultTier string
+	classifier  string
+	rules       []routeRule
+	toolRules   []routeRule
+
+	mu   sync.Mutex
+	last *RouteDecision
+}
+
+// NewModelRouter builds a router from cfg. Model references without a
+// provider prefix use defaultProvider.
+func NewModelRouter(cfg *config.RoutingConfig, defaultProvider string) (*ModelRouter, error) {
+	r := &ModelRouter{
+		tiers:       make(map[string]*RouteTier),
+		defaultTier: DefaultTier,
+		classifier:  strings.TrimSpace(cfg.ClassifierModel),
+	}
+
+	for _, t := range cfg.Tiers {
+		name := strings.ToLower(strings.TrimSpace(t.Name))
+		if name == "" || name == DefaultTier {
+			return nil, fmt.Errorf("routing tier name %q is empty or reserved", t.Name)
+		}
+		if _, dup := r.tiers[name]; dup {
+			return nil, fmt.Errorf("duplicate routing tier %q", name)
+		}
+		model := strings.TrimSpace(t.Model)
+		if model == "" {
+			return nil, fmt.Errorf("routing tier %q has no model", name)
+		}
+		r.tiers[name] = &RouteTier{
+			Name:        name,
+			Model:       model,
+			Description: t.Description,
+			Candidates:  ResolveCandidates(ModelConfig{Primary: model, Fallbacks: t.Fallbacks}, defaultProvider),
+		}
+		r.order = append(r.order, name)
+	}
+
+	if cfg.DefaultTier != "" {
+		name := strings.ToLower(strings.TrimSpace(cfg.DefaultTier))
+		if !r.hasTier(name) {
+			return nil, fmt.Errorf("default routing tier %q is not defined", cfg.DefaultTier)
+		}
+		r.defaultTier = name
+	}
+
+	for i, rule := range cfg.Rules {
+		rule.Tier = strings.ToLower(strings.TrimSpace(rule.Tier))
+		if !r.hasTier(rule.Tier) {
+			return nil, fmt.Errorf("routing rule %d: tier %q is not defined", i+1, rule.Tier)
+		}
+		rr := routeRule{RoutingRule: rule, index: i + 1}
+		if rule.Pattern != "" {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("routing rule %d: invalid pattern: %w", i+1, err)
+			}
+			rr.pattern = re
+		}
+		if len(rule.Tools) > 0 {
+			r.toolRules = append(r.toolRules, rr)
+		} else {
+			r.rules = append(r.rules, rr)
+		}
+	}
+
+	return r, nil
+}
+
+func (r *ModelRouter) hasTier(name string) bool {
+	_, ok := r.tiers[name]
+	return ok || name == DefaultTier
+}
+
+// Tiers returns the configured tiers in order, excluding DefaultTier.
+func (r *ModelRouter) Tiers() []RouteTier {
+	out := make([]RouteTier, 0, len(r.order))
+	for _, name := range r.order {
+		out = append(out, *r.tiers[name])
+	}
+	return out
+}
+
+// Last returns the most recent routing decision.
+func (r *ModelRouter) Last() (RouteDecision, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.last == nil {
+		return RouteDecision{}, false
+	}
+	return *r.last, true
+}
+
+// Route picks the tier for a turn. classify may be nil, in which case the
+// classifier model is not consulted.
+func (r *ModelRouter) Route(ctx context.Context, req RouteRequest, classify ClassifyFunc) RouteDecision {
+	for _, rule := range r.rules {
+		if rule.matches(req) {
+			return r.decide(rule.Tier, fmt.Sprintf("rule %d", rule.index), false)
+		}
+	}
+
+	if r.classifier != "" && classify != nil {
+		tier, err := r.classify(ctx, req, classify)
+		if err == nil {
+			return r.decide(tier, "classifier", false)
+		}
+		return r.decide(r.defaultTier, "classifier failed: "+err.Error(), false)
+	}
+
+	return r.decide(r.defaultTier, "no rule matched", false)
+}
+
+// Escalate moves a turn to another tier once the model calls a tool named
+// by a tool rule. A turn is escalated at most once.
+func (r *ModelRouter) Escalate(current RouteDecision, toolNames []string) (RouteDecision, bool) {
+	if current.Escalated {
+		return current, false
+	}
+	for _, rule := range r.toolRules {
+		if rule.Tier == current.Tier {
+			continue
+		}
+		for _, name := range toolNames {
+			for _, want := range rule.Tools {
+				if want == "*" || want == name {
+					return r.decide(rule.Tier, fmt.Sprintf("rule %d (tool %s)", rule.index, name), true), true
+				}
+			}
+		}
+	}
+	return current, false
+}
+
+func (r *ModelRouter) decide(tier, reason string, escalated bool) RouteDecision {
+	d := RouteDecision{Tier: tier, Reason: reason, Escalated: escalated, At: time.Now()}
+	if t, ok := r.tiers[tier]; ok {
+		d.Model = t.Model
+		d.Candidates = t.Candidates
+	}
+	r.mu.Lock()
+	r.last = &d
+	r.mu.Unlock()
+	return d
+}
+
+func (r *ModelRouter) classify(ctx context.Context, req RouteRequest, classify ClassifyFunc) (string, error) {
+	var system strings.Builder
+	system.WriteString("You route chat messages to the model tier best suited to answer them. ")
+	system.WriteString("Reply with the tier name only.\n\nTiers:\n")
+	for _, name := range r.order {
+		fmt.Fprintf(&system, "- %s: %s\n", name, r.tiers[name].Description)
+	}
+	fmt.Fprintf(&system, "- %s: anything else\n", DefaultTier)
+
+	user := req.Content
+	if len(user) > classifierMaxChars {
+		user = user[:classifierMaxChars] + "..."
+	}
+	if req.HasMedia {
+		user += "\n[message has attachments]"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, classifierTimeout)
+	defer cancel()
+	reply, err := classify(ctx, r.classifier, system.String(), user)
+	if err != nil {
+		return "", err
+	}
+	return r.parseTier(reply)
+}
+
+// parseTier finds the tier named in a classifier reply.
+func (r *ModelRouter) parseTier(reply string) (string, error) {
+	reply = strings.ToLower(strings.Trim(strings.TrimSpace(reply), ".\"'`*"))
+	if r.hasTier(reply) {
+		return reply, nil
+	}
+	for _, word := range strings.FieldsFunc(reply, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_')
+	}) {
+		if r.hasTier(word) {
+			return word, nil
+		}
+	}
+	return "", fmt.Errorf("unrecognized tier %q", reply)
+}
+
+func (rule routeRule) matches(req RouteRequest) bool {
+	n := len([]rune(req.Content))
+	if rule.MinChars > 0 && n < rule.MinChars {
+		return false
+	}
+	if rule.MaxChars > 0 && n > rule.MaxChars {
+		return false
+	}
+	if rule.HasMedia != nil && *rule.HasMedia != req.HasMedia {
+		return false
+	}
+	if len(rule.Channels) > 0 && !containsFold(rule.Channels, req.Channel) {
+		return false
+	}
+	if len(rule.Keywords) > 0 {
+		lower := strings.ToLower(req.Content)
+		found := false
+		for _, kw := range rule.Keywords {
+			if kw != "" && strings.Contains(lower, strings.ToLower(kw)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if rule.pattern != nil && !rule.pattern.MatchString(req.Content) {
+		return false
+	}
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
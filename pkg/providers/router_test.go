@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func testRoutingConfig() *config.RoutingConfig {
+	noMedia := false
+	return &config.RoutingConfig{
+		Enabled: true,
+		Tiers: []config.RoutingTierConfig{
+			{Name: "small", Model: "ollama/llama3.2", Description: "greetings and chit-chat"},
+			{Name: "code", Model: "anthropic/claude-sonnet", Fallbacks: []string{"openai/gpt-4o"}, Description: "coding and debugging"},
+			{Name: "vision", Model: "gemini/gemini-2.5-flash"},
+		},
+		Rules: []config.RoutingRule{
+			{Tier: "vision", HasMedia: boolPtr(true)},
+			{Tier: "code", Keywords: []string{"stack trace", "golang"}},
+			{Tier: "code", Pattern: "```"},
+			{Tier: "small", MaxChars: 20, HasMedia: &noMedia},
+			{Tier: "code", Tools: []string{"exec", "write_file"}},
+		},
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestModelRouter_Rules(t *testing.T) {
+	r, err := NewModelRouter(testRoutingConfig(), "openai")
+	if err != nil {
+		t.Fatalf("NewModelRouter() error: %v", err)
+	}
+
+	tests := []struct {
+		req      RouteRequest
+		wantTier string
+		reason   string
+	}{
+		{RouteRequest{Content: "what's in this photo?", HasMedia: true}, "vision", "rule 1"},
+		{RouteRequest{Content: "Here is a Golang panic, help me fix it please"}, "code", "rule 2"},
+		{RouteRequest{Content: "why does this fail?\n```\nx := 1\n```"}, "code", "rule 3"},
+		{RouteRequest{Content: "hi there"}, "small", "rule 4"},
+		{RouteRequest{Content: "Tell me about the history of the Roman empire"}, DefaultTier, "no rule matched"},
+	}
+	for _, tt := range tests {
+		d := r.Route(context.Background(), tt.req, nil)
+		if d.Tier != tt.wantTier || d.Reason != tt.reason {
+			t.Errorf("Route(%q) = %s (%s), want %s (%s)", tt.req.Content, d.Tier, d.Reason, tt.wantTier, tt.reason)
+		}
+	}
+
+	code := r.Route(context.Background(), RouteRequest{Content: "golang question"}, nil)
+	if code.Model != "anthropic/claude-sonnet" || len(code.Candidates) != 2 || code.Candidates[1].Provider != "openai" {
+		t.Errorf("code decision = %+v", code)
+	}
+	if d := r.Route(context.Background(), RouteRequest{Content: "a long question about nothing in particular"}, nil); d.Model != "" || d.Candidates != nil {
+		t.Errorf("default tier should leave model to the agent, got %+v", d)
+	}
+	if last, ok := r.Last(); !ok || last.Tier != DefaultTier {
+		t.Errorf("Last() = %+v, %v", last, ok)
+	}
+}
+
+func TestModelRouter_Classifier(t *testing.T) {
+	cfg := testRoutingConfig()
+	cfg.Rules = nil
+	cfg.ClassifierModel = "groq/llama-3.1-8b"
+	r, err := NewModelRouter(cfg, "openai")
+	if err != nil {
+		t.Fatalf("NewModelRouter() error: %v", err)
+	}
+
+	var gotModel, gotSystem string
+	d := r.Route(context.Background(), RouteRequest{Content: "refactor this function"}, func(ctx context.Context, model, system, user string) (string, error) {
+		gotModel, gotSystem = model, system
+		return "Tier: **code**.", nil
+	})
+	if d.Tier != "code" || d.Reason != "classifier" {
+		t.Errorf("decision = %+v, want code via classifier", d)
+	}
+	if gotModel != "groq/llama-3.1-8b" || !strings.Contains(gotSystem, "- small: greetings and chit-chat") {
+		t.Errorf("classifier called with model=%q system=%q", gotModel, gotSystem)
+	}
+
+	d = r.Route(context.Background(), RouteRequest{Content: "hello"}, func(ctx context.Context, model, system, user string) (string, error) {
+		return "", errors.New("connection refused")
+	})
+	if d.Tier != DefaultTier || !strings.HasPrefix(d.Reason, "classifier failed") {
+		t.Errorf("decision after classifier error = %+v", d)
+	}
+
+	d = r.Route(context.Background(), RouteRequest{Content: "hello"}, func(ctx context.Context, model, system, user string) (string, error) {
+		return "I am not sure", nil
+	})
+	if d.Tier != DefaultTier {
+		t.Errorf("decision for unrecognized reply = %+v", d)
+	}
+}
+
+func TestModelRouter_Escalate(t *testing.T) {
+	r, err := NewModelRouter(testRoutingConfig(), "openai")
+	if err != nil {
+		t.Fatalf("NewModelRouter() error: %v", err)
+	}
+
+	small := r.Route(context.Background(), RouteRequest{Content: "hi"}, nil)
+	if _, ok := r.Escalate(small, []string{"read_file"}); ok {
+		t.Error("read_file should not escalate")
+	}
+	next, ok := r.Escalate(small, []string{"read_file", "exec"})
+	if !ok || next.Tier != "code" || !next.Escalated || next.Reason != "rule 5 (tool exec)" {
+		t.Fatalf("Escalate() = %+v, %v", next, ok)
+	}
+	if _, ok := r.Escalate(next, []string{"exec"}); ok {
+		t.Error("a turn should escalate at most once")
+	}
+}
+
+func TestNewModelRouter_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.RoutingConfig
+		want string
+	}{
+		{"reserved tier", config.RoutingConfig{Tiers: []config.RoutingTierConfig{{Name: "default", Model: "x"}}}, "reserved"},
+		{"missing model", config.RoutingConfig{Tiers: []config.RoutingTierConfig{{Name: "small"}}}, "no model"},
+		{"unknown rule tier", config.RoutingConfig{Rules: []config.RoutingRule{{Tier: "huge"}}}, "not defined"},
+		{"bad pattern", config.RoutingConfig{Rules: []config.RoutingRule{{Tier: "default", Pattern: "("}}}, "invalid pattern"},
+		{"unknown default", config.RoutingConfig{DefaultTier: "tiny"}, "not defined"},
+	}
+	for _, tt := range tests {
+		if _, err := NewModelRouter(&tt.cfg, ""); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+var schemaNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// NewResponseFormat builds a ResponseFormat from a JSON Schema document. The
+// name comes from the schema's title, else from fallbackName.
+func NewResponseFormat(data []byte, fallbackName string) (*ResponseFormat, error) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+	if _, err := resolveSchema(schema); err != nil {
+		return nil, err
+	}
+
+	name, _ := schema["title"].(string)
+	if name == "" {
+		name = fallbackName
+	}
+	name = strings.Trim(schemaNameInvalid.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		name = "response"
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return &ResponseFormat{Name: name, Schema: schema}, nil
+}
+
+// LoadResponseFormat reads a JSON Schema file.
+func LoadResponseFormat(path string) (*ResponseFormat, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	rf, err := NewResponseFormat(data, strings.TrimSuffix(base, ".schema"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rf, nil
+}
+
+// ValidateStructured extracts the JSON value from a model reply and checks it
+// against the schema. It returns the JSON text without surrounding prose or
+// code fences.
+func ValidateStructured(rf *ResponseFormat, content string) (string, error) {
+	text := extractJSON(content)
+	if text == "" {
+		return "", fmt.Errorf("reply contains no JSON value")
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return "", fmt.Errorf("reply is not valid JSON: %w", err)
+	}
+	resolved, err := resolveSchema(rf.Schema)
+	if err != nil {
+		return "", err
+	}
+	if err := resolved.Validate(value); err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// StructuredInstruction tells models without native structured output what
+// shape the reply must have.
+func StructuredInstruction(rf *ResponseFormat) string {
+	schema, _ := json.Marshal(rf.Schema)
+	return "Reply with only a JSON value that matches this JSON Schema, with no other text or code fences:\n" + string(schema)
+}
+
+func resolveSchema(schema map[string]interface{}) (*jsonschema.Resolved, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+	var s jsonschema.Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+	resolved, err := s.Resolve(nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+	return resolved, nil
+}
+
+// extractJSON returns the JSON value in a reply: the whole reply, the body of
+// a ```json fence, or the span from the first '{' or '[' to the last
+// matching closer.
+func extractJSON(content string) string {
+	text := strings.TrimSpace(content)
+	if json.Valid([]byte(text)) {
+		return text
+	}
+	if start := strings.Index(text, "```"); start >= 0 {
+		body := text[start+3:]
+		if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+			body = body[nl+1:]
+		}
+		if end := strings.Index(body, "```"); end >= 0 {
+			if fenced := strings.TrimSpace(body[:end]); json.Valid([]byte(fenced)) {
+				return fenced
+			}
+		}
+	}
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return ""
+	}
+	closer := "}"
+	if text[start] == '[' {
+		closer = "]"
+	}
+	end := strings.LastIndex(text, closer)
+	if end < start {
+		return ""
+	}
+	return text[start : end+1]
+}
@@ -0,0 +1,90 @@
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const weatherSchema = `{
+	"title": "Weather report",
+	"type": "object",
+	"properties": {
+		"city": {"type": "string"},
+		"temp_c": {"type": "number"}
+	},
+	"required": ["city", "temp_c"],
+	"additionalProperties": false
+}`
+
+func TestNewResponseFormat(t *testing.T) {
+	rf, err := NewResponseFormat([]byte(weatherSchema), "ignored")
+	if err != nil {
+		t.Fatalf("NewResponseFormat() error: %v", err)
+	}
+	if rf.Name != "Weather_report" || rf.Schema["type"] != "object" {
+		t.Errorf("ResponseFormat = %+v", rf)
+	}
+
+	rf, err = NewResponseFormat([]byte(`{"type": "array", "items": {"type": "string"}}`), "")
+	if err != nil || rf.Name != "response" {
+		t.Errorf("untitled schema: %+v, %v", rf, err)
+	}
+
+	if _, err := NewResponseFormat([]byte(`{"type": 5}`), "x"); err == nil {
+		t.Error("expected an error for an invalid schema")
+	}
+	if _, err := NewResponseFormat([]byte(`not json`), "x"); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestLoadResponseFormat_NameFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo-list.schema.json")
+	if err := os.WriteFile(path, []byte(`{"type": "object"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rf, err := LoadResponseFormat(path)
+	if err != nil {
+		t.Fatalf("LoadResponseFormat() error: %v", err)
+	}
+	if rf.Name != "todo-list" {
+		t.Errorf("Name = %q, want todo-list", rf.Name)
+	}
+}
+
+func TestValidateStructured(t *testing.T) {
+	rf, err := NewResponseFormat([]byte(weatherSchema), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		errPart string
+	}{
+		{"plain", `{"city": "Paris", "temp_c": 18.5}`, `{"city": "Paris", "temp_c": 18.5}`, ""},
+		{"fenced", "Here you go:\n```json\n{\"city\": \"Oslo\", \"temp_c\": -3}\n```", `{"city": "Oslo", "temp_c": -3}`, ""},
+		{"prose around", `Sure! {"city": "Rome", "temp_c": 25} Hope that helps.`, `{"city": "Rome", "temp_c": 25}`, ""},
+		{"missing field", `{"city": "Paris"}`, "", "temp_c"},
+		{"wrong type", `{"city": "Paris", "temp_c": "warm"}`, "", "temp_c"},
+		{"extra field", `{"city": "Paris", "temp_c": 1, "wind": 3}`, "", "wind"},
+		{"no json", `It is sunny in Paris.`, "", "no JSON"},
+		{"broken json", `{"city": "Paris", "temp_c": }`, "", "not valid JSON"},
+	}
+	for _, tt := range tests {
+		got, err := ValidateStructured(rf, tt.content)
+		if tt.errPart != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+				t.Errorf("%s: error = %v, want it to mention %q", tt.name, err, tt.errPart)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: ValidateStructured() = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
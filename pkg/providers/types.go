@@ -14,12 +14,26 @@ type UsageInfo = protocoltypes.UsageInfo
 type Message = protocoltypes.Message
 type ToolDefinition = protocoltypes.ToolDefinition
 type ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
+type ThinkingBlock = protocoltypes.ThinkingBlock
+type ResponseFormat = protocoltypes.ResponseFormat
 
 type LLMProvider interface {
 	Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error)
 	GetDefaultModel() string
 }
 
+// ContextWindowProvider is implemented by providers that can report the
+// context window, in tokens, available to a model.
+type ContextWindowProvider interface {
+	ContextWindow(ctx context.Context, model string) (int, error)
+}
+
+// ProbeProvider is implemented by providers that can check a model's
+// availability without generating tokens.
+type ProbeProvider interface {
+	Probe(ctx context.Context, model string) error
+}
+
 // FailoverReason classifies why an LLM request failed for fallback decisions.
 type FailoverReason string
 
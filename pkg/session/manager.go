@@ -265,6 +265,26 @@ func (sm *SessionManager) loadSessions() error {
 	return nil
 }
 
+// Delete removes a session and its file.
+func (sm *SessionManager) Delete(key string) error {
+	sm.mu.Lock()
+	delete(sm.sessions, key)
+	sm.mu.Unlock()
+
+	if sm.storage == "" {
+		return nil
+	}
+	filename := sanitizeFilename(key)
+	if filename == "." || !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
+		return os.ErrInvalid
+	}
+	err := os.Remove(filepath.Join(sm.storage, filename+".json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
 // SetHistory updates the messages of a session.
 func (sm *SessionManager) SetHistory(key string, history []providers.Message) {
 	sm.mu.Lock()
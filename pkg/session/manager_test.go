@@ -72,3 +72,26 @@ func TestSave_RejectsPathTraversal(t *testing.T) {
 		}
 	}
 }
+
+func TestDelete(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewSessionManager(tmpDir)
+
+	key := "agent:main:subagent:subagent-1"
+	sm.AddMessage(key, "user", "hello")
+	if err := sm.Save(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Delete(key); err != nil {
+		t.Fatalf("Delete(%q) failed: %v", key, err)
+	}
+	if history := sm.GetHistory(key); len(history) != 0 {
+		t.Errorf("expected no history after delete, got %d messages", len(history))
+	}
+	if history := NewSessionManager(tmpDir).GetHistory(key); len(history) != 0 {
+		t.Errorf("expected the session file to be removed, got %d messages", len(history))
+	}
+	if err := sm.Delete("never-saved"); err != nil {
+		t.Errorf("Delete of an unsaved session failed: %v", err)
+	}
+}
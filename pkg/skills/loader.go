@@ -58,6 +58,7 @@ type SkillsLoader struct {
 	workspaceSkills string // workspace skills (项目级别)
 	globalSkills    string // 全局 skills (~/.picoclaw/skills)
 	builtinSkills   string // 内置 skills
+	filter          []string
 }
 
 func NewSkillsLoader(workspace string, globalSkills string, builtinSkills string) *SkillsLoader {
@@ -69,7 +70,30 @@ func NewSkillsLoader(workspace string, globalSkills string, builtinSkills string
 	}
 }
 
+// SetFilter limits the skills listed to the given names. An empty filter
+// lists every skill.
+func (sl *SkillsLoader) SetFilter(names []string) {
+	sl.filter = names
+}
+
 func (sl *SkillsLoader) ListSkills() []SkillInfo {
+	skills := sl.listAllSkills()
+	if len(sl.filter) == 0 {
+		return skills
+	}
+	filtered := make([]SkillInfo, 0, len(skills))
+	for _, s := range skills {
+		for _, name := range sl.filter {
+			if s.Name == name {
+				filtered = append(filtered, s)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func (sl *SkillsLoader) listAllSkills() []SkillInfo {
 	skills := make([]SkillInfo, 0)
 
 	if sl.workspaceSkills != "" {
@@ -1,6 +1,8 @@
 package skills
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -177,3 +179,24 @@ func TestStripFrontmatter(t *testing.T) {
 		})
 	}
 }
+
+func TestListSkillsFilter(t *testing.T) {
+	workspace := t.TempDir()
+	for _, name := range []string{"weather", "github", "tmux"} {
+		dir := filepath.Join(workspace, "skills", name)
+		assert.NoError(t, os.MkdirAll(dir, 0755))
+		content := "---\nname: " + name + "\ndescription: The " + name + " skill\n---\n\n# " + name + "\n"
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte(content), 0644))
+	}
+
+	sl := NewSkillsLoader(workspace, "", "")
+	assert.Len(t, sl.ListSkills(), 3)
+
+	sl.SetFilter([]string{"tmux", "weather", "missing"})
+	var names []string
+	for _, s := range sl.ListSkills() {
+		names = append(names, s.Name)
+	}
+	assert.ElementsMatch(t, []string{"tmux", "weather"}, names)
+	assert.NotContains(t, sl.BuildSkillsSummary(), "github")
+}
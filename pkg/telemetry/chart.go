@@ -0,0 +1,205 @@
+package telemetry
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	chartWidth  = 800
+	chartHeight = 400
+	chartLeft   = 80
+	chartRight  = 24
+	chartTop    = 40
+	chartBottom = 36
+	textScale   = 2
+)
+
+var (
+	colorBackground = color.RGBA{255, 255, 255, 255}
+	colorText       = color.RGBA{40, 40, 40, 255}
+	colorGrid       = color.RGBA{225, 225, 225, 255}
+	colorAxis       = color.RGBA{120, 120, 120, 255}
+	colorBand       = color.RGBA{200, 220, 245, 255}
+	colorLine       = color.RGBA{30, 100, 200, 255}
+)
+
+// chartSteps are the bucket sizes AutoStep picks from.
+var chartSteps = []time.Duration{
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+// AutoStep returns a bucket size giving a chart about 200 points across
+// [from, to).
+func AutoStep(from, to time.Time) time.Duration {
+	want := to.Sub(from) / 200
+	for _, step := range chartSteps {
+		if step >= want {
+			return step
+		}
+	}
+	return chartSteps[len(chartSteps)-1]
+}
+
+// Chart renders the buckets of series as a PNG line chart of the average
+// with a band from minimum to maximum.
+func Chart(series *Series) ([]byte, error) {
+	if len(series.Buckets) == 0 {
+		return nil, fmt.Errorf("no values of %s in the range", series.Metric)
+	}
+	if series.Step <= 0 {
+		return nil, fmt.Errorf("chart needs a step")
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	fillRect(img, 0, 0, chartWidth, chartHeight, colorBackground)
+	plotW := chartWidth - chartLeft - chartRight
+	plotH := chartHeight - chartTop - chartBottom
+
+	lo, hi := series.Total.Min, series.Total.Max
+	if lo == hi {
+		lo, hi = lo-1, hi+1
+	}
+	tick := niceStep((hi - lo) / 5)
+	lo = math.Floor(lo/tick) * tick
+	hi = math.Ceil(hi/tick) * tick
+	decimals := 0
+	if tick < 1 {
+		decimals = int(math.Ceil(-math.Log10(tick)))
+	}
+	yOf := func(v float64) int {
+		return chartTop + plotH - int(math.Round((v-lo)/(hi-lo)*float64(plotH)))
+	}
+	span := series.To.Sub(series.From)
+	xOf := func(t time.Time) int {
+		return chartLeft + int(math.Round(float64(t.Sub(series.From))/float64(span)*float64(plotW)))
+	}
+
+	// Value grid and labels.
+	for v := lo; v <= hi+tick/2; v += tick {
+		y := yOf(v)
+		fillRect(img, chartLeft, y, plotW, 1, colorGrid)
+		label := strconv.FormatFloat(v, 'f', decimals, 64)
+		drawText(img, chartLeft-8-textWidth(label, textScale), y-5*textScale/2, label, textScale, colorText)
+	}
+
+	// Time labels.
+	layout := "15:04"
+	if span > 36*time.Hour {
+		layout = "01-02"
+		if span <= 7*24*time.Hour {
+			layout = "01-02 15:04"
+		}
+	}
+	for i := 0; i <= 4; i++ {
+		t := series.From.Add(span * time.Duration(i) / 4)
+		x := xOf(t)
+		fillRect(img, x, chartTop+plotH, 1, 4, colorAxis)
+		label := t.Format(layout)
+		lx := x - textWidth(label, textScale)/2
+		lx = max(0, min(lx, chartWidth-textWidth(label, textScale)))
+		drawText(img, lx, chartTop+plotH+10, label, textScale, colorText)
+	}
+
+	// Min-max band, then the average line, broken where buckets are missing.
+	for _, b := range series.Buckets {
+		x0 := xOf(b.Start)
+		x1 := max(xOf(b.Start.Add(series.Step)), x0+1)
+		top, bottom := yOf(b.Max), yOf(b.Min)
+		fillRect(img, x0, top, x1-x0, bottom-top+1, colorBand)
+	}
+	for i, b := range series.Buckets {
+		x, y := xOf(b.Start.Add(series.Step/2)), yOf(b.Avg)
+		if i > 0 && b.Start.Sub(series.Buckets[i-1].Start) == series.Step {
+			prev := series.Buckets[i-1]
+			drawLine(img, xOf(prev.Start.Add(series.Step/2)), yOf(prev.Avg), x, y, colorLine)
+		} else {
+			fillRect(img, x-1, y-1, 3, 3, colorLine)
+		}
+	}
+
+	// Axes and title.
+	fillRect(img, chartLeft, chartTop, 1, plotH+1, colorAxis)
+	fillRect(img, chartLeft, chartTop+plotH, plotW+1, 1, colorAxis)
+	title := series.Metric
+	if series.Unit != "" {
+		title += " (" + series.Unit + ")"
+	}
+	total := series.Total
+	title += fmt.Sprintf("   min %s  avg %s  max %s",
+		strconv.FormatFloat(total.Min, 'f', decimals+1, 64),
+		strconv.FormatFloat(total.Avg, 'f', decimals+1, 64),
+		strconv.FormatFloat(total.Max, 'f', decimals+1, 64))
+	drawText(img, chartLeft, 12, title, textScale, colorText)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// niceStep rounds raw up to 1, 2 or 5 times a power of ten.
+func niceStep(raw float64) float64 {
+	if raw <= 0 {
+		return 1
+	}
+	pow := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if raw <= m*pow {
+			return m * pow
+		}
+	}
+	return 10 * pow
+}
+
+func fillRect(img *image.RGBA, x, y, w, h int, c color.RGBA) {
+	r := image.Rect(x, y, x+w, y+h).Intersect(img.Bounds())
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			img.SetRGBA(px, py, c)
+		}
+	}
+}
+
+// drawLine draws a 2 pixel wide line from (x0, y0) to (x1, y1).
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		fillRect(img, x0, y0, 2, 2, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
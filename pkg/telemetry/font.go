@@ -0,0 +1,54 @@
+package telemetry
+
+import (
+	"image"
+	"image/color"
+	"strings"
+)
+
+// glyphs is a 3x5 pixel font for chart labels. Each row is 3 bits, most
+// significant bit on the left. Letters are drawn upper case and unknown
+// characters as "?".
+var glyphs = map[rune][5]uint8{
+	'0': {7, 5, 5, 5, 7}, '1': {2, 6, 2, 2, 7}, '2': {7, 1, 7, 4, 7}, '3': {7, 1, 7, 1, 7},
+	'4': {5, 5, 7, 1, 1}, '5': {7, 4, 7, 1, 7}, '6': {7, 4, 7, 5, 7}, '7': {7, 1, 1, 2, 2},
+	'8': {7, 5, 7, 5, 7}, '9': {7, 5, 7, 1, 7},
+	'A': {2, 5, 7, 5, 5}, 'B': {6, 5, 6, 5, 6}, 'C': {3, 4, 4, 4, 3}, 'D': {6, 5, 5, 5, 6},
+	'E': {7, 4, 6, 4, 7}, 'F': {7, 4, 6, 4, 4}, 'G': {3, 4, 5, 5, 3}, 'H': {5, 5, 7, 5, 5},
+	'I': {7, 2, 2, 2, 7}, 'J': {1, 1, 1, 5, 2}, 'K': {5, 5, 6, 5, 5}, 'L': {4, 4, 4, 4, 7},
+	'M': {5, 7, 7, 5, 5}, 'N': {6, 5, 5, 5, 5}, 'O': {2, 5, 5, 5, 2}, 'P': {6, 5, 6, 4, 4},
+	'Q': {2, 5, 5, 6, 3}, 'R': {6, 5, 6, 5, 5}, 'S': {3, 4, 2, 1, 6}, 'T': {7, 2, 2, 2, 2},
+	'U': {5, 5, 5, 5, 7}, 'V': {5, 5, 5, 5, 2}, 'W': {5, 5, 7, 7, 5}, 'X': {5, 5, 2, 5, 5},
+	'Y': {5, 5, 2, 2, 2}, 'Z': {7, 1, 2, 4, 7},
+	' ': {0, 0, 0, 0, 0}, '.': {0, 0, 0, 0, 2}, ',': {0, 0, 0, 2, 4}, ':': {0, 2, 0, 2, 0},
+	'-': {0, 0, 7, 0, 0}, '+': {0, 2, 7, 2, 0}, '=': {0, 7, 0, 7, 0}, '/': {1, 1, 2, 4, 4},
+	'_': {0, 0, 0, 0, 7}, '%': {5, 1, 2, 4, 5}, '(': {1, 2, 2, 2, 1}, ')': {4, 2, 2, 2, 4},
+	'°': {2, 5, 2, 0, 0}, '?': {7, 1, 2, 0, 2},
+}
+
+// textWidth returns the width in pixels of s drawn at scale.
+func textWidth(s string, scale int) int {
+	n := len([]rune(s))
+	if n == 0 {
+		return 0
+	}
+	return (n*4 - 1) * scale
+}
+
+// drawText draws s with its top left corner at (x, y).
+func drawText(img *image.RGBA, x, y int, s string, scale int, c color.RGBA) {
+	for _, r := range strings.ToUpper(s) {
+		g, ok := glyphs[r]
+		if !ok {
+			g = glyphs['?']
+		}
+		for row, bits := range g {
+			for col := 0; col < 3; col++ {
+				if bits&(4>>col) != 0 {
+					fillRect(img, x+col*scale, y+row*scale, scale, scale, c)
+				}
+			}
+		}
+		x += 4 * scale
+	}
+}
@@ -0,0 +1,237 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// MaxBuckets limits the number of buckets a query can return.
+const MaxBuckets = 1000
+
+// Aggregations are the names accepted by Stats.Value.
+var Aggregations = []string{"avg", "min", "max", "sum", "count", "first", "last"}
+
+// Stats summarizes the values of a metric over a period.
+type Stats struct {
+	Start   time.Time `json:"start"`
+	Count   int       `json:"count"`
+	Min     float64   `json:"min"`
+	MinAt   time.Time `json:"min_at"`
+	Max     float64   `json:"max"`
+	MaxAt   time.Time `json:"max_at"`
+	Avg     float64   `json:"avg"`
+	Sum     float64   `json:"sum"`
+	First   float64   `json:"first"`
+	FirstAt time.Time `json:"first_at"`
+	Last    float64   `json:"last"`
+	LastAt  time.Time `json:"last_at"`
+}
+
+// Value returns the aggregate named agg, one of Aggregations.
+func (st Stats) Value(agg string) (float64, error) {
+	switch agg {
+	case "avg", "mean", "":
+		return st.Avg, nil
+	case "min":
+		return st.Min, nil
+	case "max":
+		return st.Max, nil
+	case "sum":
+		return st.Sum, nil
+	case "count":
+		return float64(st.Count), nil
+	case "first":
+		return st.First, nil
+	case "last":
+		return st.Last, nil
+	}
+	return 0, fmt.Errorf("unknown aggregation %q (valid: avg, min, max, sum, count, first, last)", agg)
+}
+
+func (r *rollup) stats(start time.Time) Stats {
+	return Stats{
+		Start:   start,
+		Count:   r.Count,
+		Min:     r.Min,
+		MinAt:   time.UnixMilli(r.MinT),
+		Max:     r.Max,
+		MaxAt:   time.UnixMilli(r.MaxT),
+		Avg:     r.Sum / float64(r.Count),
+		Sum:     r.Sum,
+		First:   r.First,
+		FirstAt: time.UnixMilli(r.FirstT),
+		Last:    r.Last,
+		LastAt:  time.UnixMilli(r.LastT),
+	}
+}
+
+// Query selects the values of a metric in [From, To).
+type Query struct {
+	Metric string
+	From   time.Time
+	To     time.Time
+	Step   time.Duration // bucket size starting at From; 0 returns only the total
+}
+
+// Series is the result of a query.
+type Series struct {
+	Metric string        `json:"metric"`
+	Unit   string        `json:"unit,omitempty"`
+	From   time.Time     `json:"from"`
+	To     time.Time     `json:"to"`
+	Step   time.Duration `json:"-"`
+	// Hourly is set when part of the range was answered from hourly
+	// summaries, so bucket values are accurate to the hour.
+	Hourly  bool    `json:"hourly,omitempty"`
+	Total   Stats   `json:"total"`
+	Buckets []Stats `json:"buckets,omitempty"` // buckets with values, oldest first
+}
+
+// Query returns the statistics of q.Metric over the range, in total and per
+// step.
+func (s *Store) Query(q Query) (*Series, error) {
+	if !ValidMetric(q.Metric) {
+		return nil, fmt.Errorf("invalid metric name %q", q.Metric)
+	}
+	if !q.To.After(q.From) {
+		return nil, fmt.Errorf("the range end must be after its start")
+	}
+	if q.Step < 0 || (q.Step > 0 && q.To.Sub(q.From)/q.Step >= MaxBuckets) {
+		return nil, fmt.Errorf("step must give at most %d buckets over the range", MaxBuckets)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series := &Series{Metric: q.Metric, From: q.From, To: q.To, Step: q.Step}
+	var total *rollup
+	buckets := make(map[int]*rollup)
+	from, to := q.From.UnixMilli(), q.To.UnixMilli()
+	add := func(r rollup) bool {
+		if r.Metric != q.Metric || r.T < from || r.T >= to || r.Count == 0 {
+			return false
+		}
+		if r.Unit != "" {
+			series.Unit = r.Unit
+		}
+		if total == nil {
+			t := r
+			total = &t
+		} else {
+			total.merge(r)
+		}
+		if q.Step > 0 {
+			i := int(time.Duration(r.T-from) * time.Millisecond / q.Step)
+			if b, ok := buckets[i]; ok {
+				b.merge(r)
+			} else {
+				b := r
+				buckets[i] = &b
+			}
+		}
+		return true
+	}
+
+	for day := q.From.UTC().Truncate(24 * time.Hour); day.Before(q.To); day = day.Add(24 * time.Hour) {
+		name := day.Format(dayLayout)
+		err := readLines(s.hourlyPath(name), func(data []byte) {
+			var r rollup
+			if json.Unmarshal(data, &r) == nil && add(r) {
+				series.Hourly = true
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = readLines(s.rawPath(name), func(data []byte) {
+			var p rawPoint
+			if json.Unmarshal(data, &p) == nil {
+				add(p.rollup())
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if total != nil {
+		series.Total = total.stats(q.From)
+	}
+	indexes := make([]int, 0, len(buckets))
+	for i := range buckets {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		series.Buckets = append(series.Buckets, buckets[i].stats(q.From.Add(time.Duration(i)*q.Step)))
+	}
+	return series, nil
+}
+
+// MetricInfo describes a recorded metric.
+type MetricInfo struct {
+	Name   string    `json:"name"`
+	Unit   string    `json:"unit,omitempty"`
+	Since  time.Time `json:"since"`
+	Last   float64   `json:"last"`
+	LastAt time.Time `json:"last_at"`
+}
+
+// Metrics lists the recorded metrics by name.
+func (s *Store) Metrics() ([]MetricInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metrics := make(map[string]*MetricInfo)
+	add := func(name, unit string, firstT int64, last float64, lastT int64) {
+		m, ok := metrics[name]
+		if !ok {
+			m = &MetricInfo{Name: name, Since: time.UnixMilli(firstT), LastAt: time.UnixMilli(lastT), Last: last}
+			metrics[name] = m
+		}
+		if unit != "" {
+			m.Unit = unit
+		}
+		if t := time.UnixMilli(firstT); t.Before(m.Since) {
+			m.Since = t
+		}
+		if t := time.UnixMilli(lastT); !t.Before(m.LastAt) {
+			m.Last, m.LastAt = last, t
+		}
+	}
+
+	for _, kind := range []string{"hourly", "raw"} {
+		days, err := segmentDays(filepath.Join(s.dir, kind))
+		if err != nil {
+			return nil, err
+		}
+		for _, day := range days {
+			err := readLines(filepath.Join(s.dir, kind, day+".jsonl"), func(data []byte) {
+				if kind == "hourly" {
+					var r rollup
+					if json.Unmarshal(data, &r) == nil && r.Count > 0 {
+						add(r.Metric, r.Unit, r.FirstT, r.Last, r.LastT)
+					}
+					return
+				}
+				var p rawPoint
+				if json.Unmarshal(data, &p) == nil && p.Metric != "" {
+					add(p.Metric, p.Unit, p.T, p.Value, p.T)
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	list := make([]MetricInfo, 0, len(metrics))
+	for _, m := range metrics {
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
@@ -0,0 +1,309 @@
+// Package telemetry stores metric values, such as sensor readings, in the
+// workspace and answers range queries over them.
+//
+// Values are appended to one JSON-lines segment per UTC day under
+// telemetry/raw. Segments older than RawRetention are downsampled into
+// hourly summaries under telemetry/hourly, which are kept for
+// RollupRetention.
+package telemetry
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	// RawRetention is how long every recorded value is kept.
+	RawRetention = 7 * 24 * time.Hour
+	// RollupRetention is how long hourly summaries are kept.
+	RollupRetention = 2 * 365 * 24 * time.Hour
+
+	dayLayout = "2006-01-02"
+)
+
+var metricName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.:-]{0,63}$`)
+
+// ValidMetric reports whether name can be used as a metric name: up to 64
+// letters, digits and "_.:-", not starting with punctuation other than "_".
+func ValidMetric(name string) bool {
+	return metricName.MatchString(name)
+}
+
+// rawPoint is one line of a raw segment.
+type rawPoint struct {
+	T      int64   `json:"t"` // Unix milliseconds
+	Metric string  `json:"metric"`
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit,omitempty"`
+}
+
+// rollup summarizes the single value p.
+func (p rawPoint) rollup() rollup {
+	return rollup{
+		T: p.T, Metric: p.Metric, Unit: p.Unit, Count: 1, Sum: p.Value,
+		Min: p.Value, MinT: p.T, Max: p.Value, MaxT: p.T,
+		First: p.Value, FirstT: p.T, Last: p.Value, LastT: p.T,
+	}
+}
+
+// rollup is one line of an hourly segment: the summary of one metric over
+// the hour starting at T.
+type rollup struct {
+	T      int64   `json:"t"`
+	Metric string  `json:"metric"`
+	Unit   string  `json:"unit,omitempty"`
+	Count  int     `json:"count"`
+	Sum    float64 `json:"sum"`
+	Min    float64 `json:"min"`
+	MinT   int64   `json:"min_t"`
+	Max    float64 `json:"max"`
+	MaxT   int64   `json:"max_t"`
+	First  float64 `json:"first"`
+	FirstT int64   `json:"first_t"`
+	Last   float64 `json:"last"`
+	LastT  int64   `json:"last_t"`
+}
+
+// Store records and queries metrics in <workspace>/telemetry.
+type Store struct {
+	dir string
+
+	mu        sync.Mutex
+	compacted string // UTC day of the last compaction
+	now       func() time.Time
+}
+
+// NewStore creates a store for workspace.
+func NewStore(workspace string) *Store {
+	return &Store{dir: filepath.Join(workspace, "telemetry"), now: time.Now}
+}
+
+func (s *Store) rawPath(day string) string {
+	return filepath.Join(s.dir, "raw", day+".jsonl")
+}
+
+func (s *Store) hourlyPath(day string) string {
+	return filepath.Join(s.dir, "hourly", day+".jsonl")
+}
+
+// Record appends a value of metric taken at the given time. A zero time
+// means now.
+func (s *Store) Record(metric string, value float64, unit string, at time.Time) error {
+	if !ValidMetric(metric) {
+		return fmt.Errorf("invalid metric name %q: use up to 64 letters, digits and _.:-", metric)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("value must be a finite number")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if at.IsZero() {
+		at = now
+	}
+	if at.After(now.Add(time.Hour)) {
+		return fmt.Errorf("time %s is in the future", at.Format(time.RFC3339))
+	}
+	if at.Before(now.Add(-RollupRetention)) {
+		return fmt.Errorf("time %s is older than the retention period", at.Format(time.RFC3339))
+	}
+
+	line, err := json.Marshal(rawPoint{T: at.UnixMilli(), Metric: metric, Value: value, Unit: unit})
+	if err != nil {
+		return err
+	}
+	path := s.rawPath(at.UTC().Format(dayLayout))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if day := now.UTC().Format(dayLayout); s.compacted != day {
+		if err := s.compact(now); err != nil {
+			logger.WarnCF("telemetry", "Failed to downsample telemetry", map[string]interface{}{"error": err.Error()})
+		} else {
+			s.compacted = day
+		}
+	}
+	return nil
+}
+
+// segmentDays lists the days of the segments in dir, oldest first.
+func segmentDays(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var days []string
+	for _, e := range entries {
+		day, ok := strings.CutSuffix(e.Name(), ".jsonl")
+		if !ok || e.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(dayLayout, day); err == nil {
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// compact downsamples raw segments older than RawRetention into hourly
+// summaries and drops summaries older than RollupRetention. Called with
+// s.mu held.
+func (s *Store) compact(now time.Time) error {
+	rawCutoff := now.Add(-RawRetention).UTC().Format(dayLayout)
+	days, err := segmentDays(filepath.Join(s.dir, "raw"))
+	if err != nil {
+		return err
+	}
+	for _, day := range days {
+		if day >= rawCutoff {
+			break
+		}
+		if err := s.compactDay(day); err != nil {
+			return fmt.Errorf("segment %s: %w", day, err)
+		}
+	}
+
+	rollupCutoff := now.Add(-RollupRetention).UTC().Format(dayLayout)
+	days, err = segmentDays(filepath.Join(s.dir, "hourly"))
+	if err != nil {
+		return err
+	}
+	for _, day := range days {
+		if day >= rollupCutoff {
+			break
+		}
+		if err := os.Remove(s.hourlyPath(day)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compactDay merges the raw segment of day into its hourly segment and
+// removes the raw segment.
+func (s *Store) compactDay(day string) error {
+	rollups := make(map[string]*rollup)
+	var order []string
+	add := func(r rollup) {
+		key := fmt.Sprintf("%s|%d", r.Metric, r.T)
+		if cur, ok := rollups[key]; ok {
+			cur.merge(r)
+			return
+		}
+		rollups[key] = &r
+		order = append(order, key)
+	}
+
+	err := readLines(s.hourlyPath(day), func(data []byte) {
+		var r rollup
+		if json.Unmarshal(data, &r) == nil && r.Count > 0 {
+			add(r)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	err = readLines(s.rawPath(day), func(data []byte) {
+		var p rawPoint
+		if json.Unmarshal(data, &p) == nil && p.Metric != "" {
+			r := p.rollup()
+			r.T = time.UnixMilli(p.T).Truncate(time.Hour).UnixMilli()
+			add(r)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf strings.Builder
+	for _, key := range order {
+		line, err := json.Marshal(rollups[key])
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	path := s.hourlyPath(day)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(buf.String()), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(s.rawPath(day))
+}
+
+func (r *rollup) merge(o rollup) {
+	r.Count += o.Count
+	r.Sum += o.Sum
+	if o.Min < r.Min {
+		r.Min, r.MinT = o.Min, o.MinT
+	}
+	if o.Max > r.Max {
+		r.Max, r.MaxT = o.Max, o.MaxT
+	}
+	if o.FirstT < r.FirstT {
+		r.First, r.FirstT = o.First, o.FirstT
+	}
+	if o.LastT >= r.LastT {
+		r.Last, r.LastT = o.Last, o.LastT
+	}
+	if o.Unit != "" {
+		r.Unit = o.Unit
+	}
+}
+
+// readLines calls fn for each line of the file at path. A missing file has
+// no lines.
+func readLines(path string, fn func([]byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fn(scanner.Bytes())
+	}
+	return scanner.Err()
+}
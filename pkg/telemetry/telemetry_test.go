@@ -0,0 +1,188 @@
+package telemetry
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T, now time.Time) *Store {
+	s := NewStore(t.TempDir())
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestRecordAndQuery(t *testing.T) {
+	start := time.Date(2026, 3, 10, 20, 0, 0, 0, time.UTC)
+	s := newTestStore(t, start.Add(12*time.Hour))
+
+	// A temperature every 5 minutes overnight, peaking at 01:00.
+	for i := 0; i < 12*12; i++ {
+		at := start.Add(time.Duration(i) * 5 * time.Minute)
+		v := 20 - float64(abs(i-60))*0.05
+		if err := s.Record("temperature", v, "°C", at); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if err := s.Record("humidity", 40, "%", start); err != nil {
+		t.Fatal(err)
+	}
+
+	series, err := s.Query(Query{Metric: "temperature", From: start, To: start.Add(12 * time.Hour), Step: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := series.Total
+	if total.Count != 144 || total.Max != 20 || !total.MaxAt.Equal(start.Add(5*time.Hour)) {
+		t.Errorf("total = %+v", total)
+	}
+	if series.Unit != "°C" || series.Hourly {
+		t.Errorf("unit = %q, hourly = %v", series.Unit, series.Hourly)
+	}
+	if len(series.Buckets) != 12 || series.Buckets[0].Count != 12 || !series.Buckets[5].Start.Equal(start.Add(5*time.Hour)) {
+		t.Fatalf("buckets = %+v", series.Buckets)
+	}
+	if max, _ := series.Buckets[5].Value("max"); max != 20 {
+		t.Errorf("max of bucket 5 = %v", max)
+	}
+	if _, err := series.Total.Value("median"); err == nil {
+		t.Error("expected an error for an unknown aggregation")
+	}
+
+	// The range end is exclusive.
+	series, _ = s.Query(Query{Metric: "temperature", From: start, To: start.Add(10 * time.Minute)})
+	if series.Total.Count != 2 || series.Buckets != nil {
+		t.Errorf("10 minute query = %+v", series)
+	}
+
+	metrics, err := s.Metrics()
+	if err != nil || len(metrics) != 2 || metrics[0].Name != "humidity" || metrics[1].Unit != "°C" {
+		t.Errorf("Metrics() = %+v, %v", metrics, err)
+	}
+}
+
+func TestRecordValidation(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s := newTestStore(t, now)
+	for _, metric := range []string{"", "../x", "a b", "-x"} {
+		if err := s.Record(metric, 1, "", time.Time{}); err == nil {
+			t.Errorf("Record(%q) accepted", metric)
+		}
+	}
+	if err := s.Record("x", 1, "", now.Add(2*time.Hour)); err == nil {
+		t.Error("Record accepted a time in the future")
+	}
+	if err := s.Record("sensor.bme280:temp_c", 1, "", time.Time{}); err != nil {
+		t.Errorf("Record: %v", err)
+	}
+}
+
+func TestDownsampling(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestStore(t, day)
+	for i := 0; i < 4; i++ {
+		s.Record("power", float64(i), "W", day.Add(time.Duration(i)*20*time.Minute))
+	}
+
+	// The next record a week later downsamples the old segment.
+	s.now = func() time.Time { return day.Add(RawRetention + 24*time.Hour) }
+	if err := s.Record("power", 10, "W", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(s.rawPath("2026-03-01")); !os.IsNotExist(err) {
+		t.Errorf("raw segment still exists: %v", err)
+	}
+
+	// A late value for the downsampled day is merged into its summary.
+	s.Record("power", 7, "W", day.Add(30*time.Minute))
+	s.compacted = ""
+	s.Record("power", 11, "W", time.Time{})
+
+	series, err := s.Query(Query{Metric: "power", From: day, To: day.Add(24 * time.Hour), Step: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !series.Hourly || len(series.Buckets) != 2 {
+		t.Fatalf("series = %+v", series)
+	}
+	first := series.Buckets[0]
+	if first.Count != 4 || first.Sum != 0+1+2+7 || first.Max != 7 || first.Last != 2 || first.First != 0 {
+		t.Errorf("first hour = %+v", first)
+	}
+	if series.Buckets[1].Count != 1 || series.Buckets[1].Avg != 3 {
+		t.Errorf("second hour = %+v", series.Buckets[1])
+	}
+
+	// Summaries past their retention are dropped.
+	s.now = func() time.Time { return day.Add(RollupRetention + 48*time.Hour) }
+	s.compacted = ""
+	s.Record("power", 1, "W", time.Time{})
+	if _, err := os.Stat(filepath.Join(s.dir, "hourly", "2026-03-01.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("old summary still exists: %v", err)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"now", now},
+		{"-12h", now.Add(-12 * time.Hour)},
+		{"3d ago", now.Add(-72 * time.Hour)},
+		{"1h30m", now.Add(-90 * time.Minute)},
+		{"2026-03-09 22:00", time.Date(2026, 3, 9, 22, 0, 0, 0, time.UTC)},
+		{"2026-03-09", time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{"2026-03-09T22:00:00+01:00", time.Date(2026, 3, 9, 21, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "yesterday", "5x", "h"} {
+		if _, err := ParseTime(in, now); err == nil {
+			t.Errorf("ParseTime(%q) succeeded", in)
+		}
+	}
+	if d, err := ParseDuration("2w"); err != nil || d != 14*24*time.Hour {
+		t.Errorf("ParseDuration(2w) = %v, %v", d, err)
+	}
+}
+
+func TestChart(t *testing.T) {
+	start := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	s := newTestStore(t, start.Add(24*time.Hour))
+	for i := 0; i < 24; i++ {
+		if i == 10 {
+			continue // a gap in the line
+		}
+		s.Record("temperature", 18+float64(i%6), "°C", start.Add(time.Duration(i)*time.Hour))
+	}
+	to := start.Add(24 * time.Hour)
+	series, err := s.Query(Query{Metric: "temperature", From: start, To: to, Step: AutoStep(start, to)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Chart(series)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("chart is not a PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != chartWidth || b.Dy() != chartHeight {
+		t.Errorf("chart size = %v", b)
+	}
+
+	empty, _ := s.Query(Query{Metric: "missing", From: start, To: to, Step: time.Hour})
+	if _, err := Chart(empty); err == nil {
+		t.Error("expected an error charting no values")
+	}
+}
@@ -0,0 +1,375 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/automation"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// AutomationTool manages event-triggered automation rules and runs their actions
+type AutomationTool struct {
+	service  *automation.Service
+	executor JobExecutor
+	msgBus   *bus.MessageBus
+	execTool *ExecTool
+	channel  string
+	chatID   string
+	mu       sync.RWMutex
+}
+
+// NewAutomationTool creates a new AutomationTool
+// execTimeout: 0 means no timeout, >0 sets the timeout duration
+func NewAutomationTool(service *automation.Service, executor JobExecutor, msgBus *bus.MessageBus, workspace string, restrict bool, execTimeout time.Duration, config *config.Config) *AutomationTool {
+	execTool := NewExecToolWithConfig(workspace, restrict, config)
+	execTool.SetTimeout(execTimeout)
+	return &AutomationTool{
+		service:  service,
+		executor: executor,
+		msgBus:   msgBus,
+		execTool: execTool,
+	}
+}
+
+// Name returns the tool name
+func (t *AutomationTool) Name() string {
+	return "automation"
+}
+
+// Description returns the tool description
+func (t *AutomationTool) Description() string {
+	return "Create rules that react to events: a schedule ('time'), a USB/device being plugged or unplugged ('device'), the camera detecting a person ('person'), a workspace file changing ('file'), or an incoming message matching a regular expression ('message'). A rule can be limited to a daily time window and rate limited, and either runs an agent prompt, sends a message, or runs a shell command. Use {{event}} in the message to include what happened. For plain reminders prefer the cron tool."
+}
+
+// Parameters returns the tool parameters schema
+func (t *AutomationTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"add", "list", "remove", "enable", "disable"},
+				"description": "Action to perform.",
+			},
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "Short name for the rule (for add)",
+			},
+			"trigger": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"time", "device", "person", "file", "message"},
+				"description": "What starts the rule (for add)",
+			},
+			"every_seconds": map[string]interface{}{
+				"type":        "integer",
+				"description": "time trigger: interval in seconds",
+			},
+			"cron_expr": map[string]interface{}{
+				"type":        "string",
+				"description": "time trigger: cron expression (e.g. '0 9 * * *')",
+			},
+			"device": map[string]interface{}{
+				"type":        "string",
+				"description": "device trigger: only this device type (e.g. 'usb')",
+			},
+			"on": map[string]interface{}{
+				"type":        "string",
+				"description": "device trigger: 'add' or 'remove'; file trigger: 'created', 'modified' or 'deleted'. Empty matches all",
+			},
+			"match": map[string]interface{}{
+				"type":        "string",
+				"description": "device trigger: text contained in vendor/product name; message trigger: regular expression the message must match",
+			},
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "file trigger: file, directory or glob pattern relative to the workspace (e.g. 'inbox/*.csv')",
+			},
+			"from_channel": map[string]interface{}{
+				"type":        "string",
+				"description": "message trigger: only messages from this channel (e.g. 'telegram')",
+			},
+			"min_score": map[string]interface{}{
+				"type":        "number",
+				"description": "person trigger: minimum detection confidence between 0 and 1",
+			},
+			"window_start": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: only fire after this time of day (HH:MM)",
+			},
+			"window_end": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: only fire before this time of day (HH:MM)",
+			},
+			"days": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: comma separated days for the time window (e.g. 'mon,tue,wed,thu,fri')",
+			},
+			"max_fires": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional rate limit: fire at most this many times per period_seconds",
+			},
+			"period_seconds": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional rate limit period in seconds",
+			},
+			"do": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"agent", "message", "command"},
+				"description": "What to do when the rule fires: run 'message' as an agent prompt, send 'message' as is, or run 'command'",
+			},
+			"message": map[string]interface{}{
+				"type":        "string",
+				"description": "Agent prompt or message text. {{event}} is replaced with a description of the event",
+			},
+			"command": map[string]interface{}{
+				"type":        "string",
+				"description": "Shell command for the 'command' action",
+			},
+			"agent": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: agent ID that handles 'agent' actions",
+			},
+			"rule_id": map[string]interface{}{
+				"type":        "string",
+				"description": "Rule ID (for remove/enable/disable)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+// SetContext sets the current session context for rule creation
+func (t *AutomationTool) SetContext(channel, chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.channel = channel
+	t.chatID = chatID
+}
+
+// Clone returns a copy sharing the automation service, without a chat set.
+func (t *AutomationTool) Clone() Tool {
+	return &AutomationTool{
+		service:  t.service,
+		executor: t.executor,
+		msgBus:   t.msgBus,
+		execTool: t.execTool,
+	}
+}
+
+// Execute runs the tool with the given arguments
+func (t *AutomationTool) Execute(ctx context.Context, args map[string]interface{}) *ToolResult {
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+
+	switch action {
+	case "add":
+		return t.addRule(args)
+	case "list":
+		return t.listRules()
+	case "remove":
+		return t.removeRule(args)
+	case "enable":
+		return t.enableRule(args, true)
+	case "disable":
+		return t.enableRule(args, false)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s", action))
+	}
+}
+
+func (t *AutomationTool) addRule(args map[string]interface{}) *ToolResult {
+	t.mu.RLock()
+	channel := t.channel
+	chatID := t.chatID
+	t.mu.RUnlock()
+
+	rule := automation.Rule{}
+	rule.Name, _ = args["name"].(string)
+	rule.Trigger.Kind, _ = args["trigger"].(string)
+
+	if rule.Trigger.Kind == automation.TriggerTime {
+		if every, ok := args["every_seconds"].(float64); ok && every > 0 {
+			everyMS := int64(every) * 1000
+			rule.Trigger.Schedule = &cron.CronSchedule{Kind: "every", EveryMS: &everyMS}
+		} else if expr, ok := args["cron_expr"].(string); ok && expr != "" {
+			rule.Trigger.Schedule = &cron.CronSchedule{Kind: "cron", Expr: expr}
+		}
+	}
+	rule.Trigger.Device, _ = args["device"].(string)
+	rule.Trigger.On, _ = args["on"].(string)
+	rule.Trigger.Match, _ = args["match"].(string)
+	rule.Trigger.Path, _ = args["path"].(string)
+	rule.Trigger.Channel, _ = args["from_channel"].(string)
+	rule.Trigger.MinScore, _ = args["min_score"].(float64)
+
+	start, _ := args["window_start"].(string)
+	end, _ := args["window_end"].(string)
+	if start != "" || end != "" {
+		rule.Conditions.TimeWindow = &automation.TimeWindow{Start: start, End: end}
+		if days, ok := args["days"].(string); ok && days != "" {
+			rule.Conditions.TimeWindow.Days = splitList(days)
+		}
+	}
+	if maxFires, ok := args["max_fires"].(float64); ok && maxFires > 0 {
+		period, _ := args["period_seconds"].(float64)
+		rule.Conditions.RateLimit = &automation.RateLimit{Max: int(maxFires), PeriodSec: int64(period)}
+	}
+
+	rule.Action.Kind, _ = args["do"].(string)
+	rule.Action.Message, _ = args["message"].(string)
+	rule.Action.Command, _ = args["command"].(string)
+	rule.Action.Agent, _ = args["agent"].(string)
+	// Results go back to the conversation the rule was created in, except for
+	// message triggers which answer in the chat the message came from
+	if rule.Trigger.Kind != automation.TriggerMessage {
+		rule.Action.Channel = channel
+		rule.Action.To = chatID
+	}
+
+	if rule.Name == "" {
+		rule.Name = utils.Truncate(rule.Trigger.Describe(), 30)
+	}
+
+	added, err := t.service.AddRule(rule)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("Error adding rule: %v", err))
+	}
+
+	return SilentResult(fmt.Sprintf("Automation added: %s (id: %s, on %s)", added.Name, added.ID, added.Trigger.Describe()))
+}
+
+func (t *AutomationTool) listRules() *ToolResult {
+	rules := t.service.ListRules(true)
+
+	if len(rules) == 0 {
+		return SilentResult("No automation rules")
+	}
+
+	result := "Automation rules:\n"
+	for _, r := range rules {
+		status := "enabled"
+		if !r.Enabled {
+			status = "disabled"
+		}
+		result += fmt.Sprintf("- %s (id: %s, on %s, do %s, %s, fired %d times)\n",
+			r.Name, r.ID, r.Trigger.Describe(), r.Action.Kind, status, r.State.FireCount)
+	}
+
+	return SilentResult(result)
+}
+
+func (t *AutomationTool) removeRule(args map[string]interface{}) *ToolResult {
+	ruleID, ok := args["rule_id"].(string)
+	if !ok || ruleID == "" {
+		return ErrorResult("rule_id is required for remove")
+	}
+
+	if t.service.RemoveRule(ruleID) {
+		return SilentResult(fmt.Sprintf("Automation removed: %s", ruleID))
+	}
+	return ErrorResult(fmt.Sprintf("Rule %s not found", ruleID))
+}
+
+func (t *AutomationTool) enableRule(args map[string]interface{}, enable bool) *ToolResult {
+	ruleID, ok := args["rule_id"].(string)
+	if !ok || ruleID == "" {
+		return ErrorResult("rule_id is required for enable/disable")
+	}
+
+	rule := t.service.EnableRule(ruleID, enable)
+	if rule == nil {
+		return ErrorResult(fmt.Sprintf("Rule %s not found", ruleID))
+	}
+
+	status := "enabled"
+	if !enable {
+		status = "disabled"
+	}
+	return SilentResult(fmt.Sprintf("Automation '%s' %s", rule.Name, status))
+}
+
+// ExecuteRule performs a fired rule's action
+func (t *AutomationTool) ExecuteRule(ctx context.Context, rule *automation.Rule, ev automation.Event) (string, error) {
+	channel := rule.Action.Channel
+	chatID := rule.Action.To
+
+	switch rule.Action.Kind {
+	case automation.ActionCommand:
+		result := t.execTool.Execute(ctx, map[string]interface{}{
+			"command": rule.Action.Command,
+		})
+		var output string
+		if result.IsError {
+			output = fmt.Sprintf("Automation '%s' command failed: %s", rule.Name, result.ForLLM)
+		} else {
+			output = fmt.Sprintf("Automation '%s' ran '%s':\n%s", rule.Name, rule.Action.Command, result.ForLLM)
+		}
+		t.publish(channel, chatID, output)
+		if result.IsError {
+			return result.ForLLM, fmt.Errorf("command failed: %s", utils.Truncate(result.ForLLM, 200))
+		}
+		return result.ForLLM, nil
+
+	case automation.ActionMessage:
+		content := automation.ExpandEvent(rule.Action.Message, ev)
+		if channel == "" || chatID == "" {
+			return "", fmt.Errorf("no target channel for message")
+		}
+		t.publish(channel, chatID, content)
+		return content, nil
+
+	case automation.ActionAgent:
+		prompt := rule.Action.Message
+		if strings.Contains(prompt, "{{event}}") {
+			prompt = automation.ExpandEvent(prompt, ev)
+		} else if ev.Summary != "" {
+			prompt = fmt.Sprintf("%s\n\n[Automation '%s' triggered by: %s]", prompt, rule.Name, ev.Summary)
+		}
+		if channel == "" || chatID == "" {
+			channel, chatID = "cli", "direct"
+		}
+
+		response, err := t.executor.ProcessDirectWithOptions(ctx, prompt, channel, chatID, DirectRunOptions{
+			AgentID:    rule.Action.Agent,
+			SessionKey: fmt.Sprintf("automation:%s", rule.ID),
+		})
+		if err != nil {
+			return "", err
+		}
+		t.publish(channel, chatID, response)
+		return response, nil
+	}
+
+	return "", fmt.Errorf("unknown action kind %q", rule.Action.Kind)
+}
+
+func (t *AutomationTool) publish(channel, chatID, content string) {
+	if channel == "" || chatID == "" || content == "" || constants.IsInternalChannel(channel) {
+		return
+	}
+	t.msgBus.PublishOutbound(bus.OutboundMessage{
+		Channel: channel,
+		ChatID:  chatID,
+		Content: content,
+	})
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -17,6 +17,22 @@ type ContextualTool interface {
 	SetContext(channel, chatID string)
 }
 
+// SessionAwareTool is an optional interface for tools that need the
+// session key of the conversation they are called from.
+type SessionAwareTool interface {
+	Tool
+	SetSessionKey(sessionKey string)
+}
+
+// CloneableTool is an optional interface for tools that keep per-conversation
+// state such as the current chat. Clone returns a copy with the same
+// configuration, so a run on another goroutine can set its own context
+// without repointing the original.
+type CloneableTool interface {
+	Tool
+	Clone() Tool
+}
+
 // AsyncCallback is a function type that async tools use to notify completion.
 // When an async tool finishes its work, it calls this callback with the result.
 //
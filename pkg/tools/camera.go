@@ -0,0 +1,217 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/devices/camera"
+)
+
+const (
+	cameraDefaultTimeoutMs = 10000
+	cameraMaxTimeoutMs     = 60000
+	cameraMaxSkipFrames    = 60
+)
+
+// SnapshotSource provides stills from a networked camera, such as a MaixCam
+// connected to the maixcam channel.
+type SnapshotSource interface {
+	Snapshot(ctx context.Context) ([]byte, error)
+}
+
+// CameraTool captures stills from V4L2 devices or a MaixCam, saves them in
+// the workspace and attaches them to the next LLM request.
+type CameraTool struct {
+	workspace string
+	allowed   DeviceAllowList
+	capture   func(path string, opts camera.Options) (*camera.Still, error)
+
+	mu      sync.RWMutex
+	maixcam SnapshotSource
+}
+
+func NewCameraTool(workspace string, allowed []string) *CameraTool {
+	return &CameraTool{workspace: workspace, allowed: allowed, capture: camera.Capture}
+}
+
+// SetMaixCam sets the source for source "maixcam".
+func (t *CameraTool) SetMaixCam(src SnapshotSource) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.maixcam = src
+}
+
+func (t *CameraTool) Name() string {
+	return "camera"
+}
+
+func (t *CameraTool) Description() string {
+	return "Take a photo with a camera. The photo is saved in the workspace under camera/ and attached to your next turn so you can see it. Sources: v4l2 (USB webcams and board cameras at /dev/videoN, Linux only) and maixcam (a connected MaixCam device). Actions: list (available cameras), capture (take a photo)."
+}
+
+func (t *CameraTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"list", "capture"},
+				"description": "Action to perform: list (find cameras), capture (take a photo)",
+			},
+			"source": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"v4l2", "maixcam"},
+				"description": "Camera to use. Default: v4l2.",
+			},
+			"device": map[string]interface{}{
+				"type":        "string",
+				"description": "V4L2 device (e.g. \"0\" or \"/dev/video0\"). Default: /dev/video0.",
+			},
+			"width": map[string]interface{}{
+				"type":        "integer",
+				"description": "Requested width for v4l2; the camera picks the nearest size it supports. Default: 1280.",
+			},
+			"height": map[string]interface{}{
+				"type":        "integer",
+				"description": "Requested height for v4l2. Default: 720.",
+			},
+			"skip_frames": map[string]interface{}{
+				"type":        "integer",
+				"description": "Frames to discard first while exposure settles (0-60, v4l2 only). Default: 5.",
+			},
+			"timeout_ms": map[string]interface{}{
+				"type":        "integer",
+				"description": "How long to wait for the photo (100-60000). Default: 10000.",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *CameraTool) Execute(ctx context.Context, args map[string]interface{}) *ToolResult {
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+
+	switch action {
+	case "list":
+		return t.list()
+	case "capture":
+		return t.captureStill(ctx, args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s (valid: list, capture)", action))
+	}
+}
+
+// list reports the V4L2 capture devices and whether a MaixCam is set up.
+func (t *CameraTool) list() *ToolResult {
+	t.mu.RLock()
+	hasMaixCam := t.maixcam != nil
+	t.mu.RUnlock()
+
+	out := map[string]interface{}{}
+	devices, err := camera.Devices()
+	if err != nil {
+		out["v4l2_error"] = err.Error()
+	} else {
+		var visible []camera.DeviceInfo
+		for _, d := range devices {
+			if t.allowed.Allows(d.Path) {
+				visible = append(visible, d)
+			}
+		}
+		out["v4l2"] = visible
+	}
+	if hasMaixCam {
+		out["maixcam"] = "enabled (capture with source maixcam; needs a connected device)"
+	} else {
+		out["maixcam"] = "not enabled (channels.maixcam)"
+	}
+	result, _ := json.MarshalIndent(out, "", "  ")
+	return SilentResult(string(result))
+}
+
+// captureStill takes a photo and saves it under camera/ in the workspace.
+func (t *CameraTool) captureStill(ctx context.Context, args map[string]interface{}) *ToolResult {
+	timeoutMs, res := parseMillis(args, "timeout_ms", cameraDefaultTimeoutMs, 100, cameraMaxTimeoutMs)
+	if res != nil {
+		return res
+	}
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+
+	source, _ := args["source"].(string)
+	var (
+		image    []byte
+		from     string
+		describe string
+	)
+	switch source {
+	case "", "v4l2":
+		device, _ := args["device"].(string)
+		path, err := camera.DevicePath(device)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		if res := t.allowed.check(path); res != nil {
+			return res
+		}
+		opts := camera.Options{Skip: 5, Timeout: timeout}
+		if w, ok := args["width"].(float64); ok {
+			opts.Width = int(w)
+		}
+		if h, ok := args["height"].(float64); ok {
+			opts.Height = int(h)
+		}
+		if s, ok := args["skip_frames"].(float64); ok {
+			if s < 0 || s > cameraMaxSkipFrames {
+				return ErrorResult(fmt.Sprintf("skip_frames must be between 0 and %d", cameraMaxSkipFrames))
+			}
+			opts.Skip = int(s)
+		}
+		if opts.Width < 0 || opts.Width > 7680 || opts.Height < 0 || opts.Height > 4320 {
+			return ErrorResult("width and height must be at most 7680x4320")
+		}
+		still, err := t.capture(path, opts)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to capture from %s: %v", path, err))
+		}
+		image, from = still.JPEG, filepath.Base(path)
+		describe = fmt.Sprintf("a %dx%d photo from %s", still.Width, still.Height, path)
+	case "maixcam":
+		t.mu.RLock()
+		src := t.maixcam
+		t.mu.RUnlock()
+		if src == nil {
+			return ErrorResult("MaixCam is not available: enable channels.maixcam and run the gateway")
+		}
+		snapCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		data, err := src.Snapshot(snapCtx)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to capture from MaixCam: %v", err))
+		}
+		image, from, describe = data, "maixcam", "a photo from the MaixCam"
+	default:
+		return ErrorResult(fmt.Sprintf("unknown source: %s (valid: v4l2, maixcam)", source))
+	}
+
+	dir := filepath.Join(t.workspace, "camera")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create %s: %v", dir, err))
+	}
+	stamp := strings.Replace(time.Now().Format("20060102-150405.000"), ".", "-", 1)
+	path := filepath.Join(dir, stamp+"-"+from+".jpg")
+	if err := os.WriteFile(path, image, 0644); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to save photo: %v", err))
+	}
+
+	rel, _ := filepath.Rel(t.workspace, path)
+	return SilentResult(fmt.Sprintf("Captured %s (%d bytes), saved to %s. The image is attached to your next turn.", describe, len(image), rel)).WithMedia(path)
+}
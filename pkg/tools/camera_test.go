@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/devices/camera"
+)
+
+type fakeSnapshotSource struct {
+	image []byte
+	err   error
+}
+
+func (s *fakeSnapshotSource) Snapshot(ctx context.Context) ([]byte, error) {
+	return s.image, s.err
+}
+
+func TestCameraTool_CaptureV4L2(t *testing.T) {
+	workspace := t.TempDir()
+	tool := NewCameraTool(workspace, nil)
+	var gotPath string
+	var gotOpts camera.Options
+	tool.capture = func(path string, opts camera.Options) (*camera.Still, error) {
+		gotPath, gotOpts = path, opts
+		return &camera.Still{JPEG: []byte("jpeg"), Width: 640, Height: 480, Format: "MJPG"}, nil
+	}
+
+	result := tool.Execute(context.Background(), map[string]interface{}{
+		"action":      "capture",
+		"device":      "1",
+		"width":       float64(640),
+		"height":      float64(480),
+		"skip_frames": float64(2),
+	})
+	if result.IsError {
+		t.Fatalf("capture failed: %s", result.ForLLM)
+	}
+	if gotPath != "/dev/video1" {
+		t.Errorf("device = %q, want /dev/video1", gotPath)
+	}
+	if gotOpts.Width != 640 || gotOpts.Height != 480 || gotOpts.Skip != 2 {
+		t.Errorf("options = %+v", gotOpts)
+	}
+	if len(result.Media) != 1 {
+		t.Fatalf("media = %v, want one photo", result.Media)
+	}
+	if dir := filepath.Dir(result.Media[0]); dir != filepath.Join(workspace, "camera") {
+		t.Errorf("photo saved in %s", dir)
+	}
+	if !strings.HasSuffix(result.Media[0], "-video1.jpg") {
+		t.Errorf("photo name = %s", result.Media[0])
+	}
+	data, err := os.ReadFile(result.Media[0])
+	if err != nil || string(data) != "jpeg" {
+		t.Errorf("saved photo = %q, %v", data, err)
+	}
+}
+
+func TestCameraTool_AllowList(t *testing.T) {
+	tool := NewCameraTool(t.TempDir(), []string{"/dev/video0"})
+	tool.capture = func(path string, opts camera.Options) (*camera.Still, error) {
+		t.Fatalf("captured from %s", path)
+		return nil, nil
+	}
+
+	result := tool.Execute(context.Background(), map[string]interface{}{
+		"action": "capture",
+		"device": "/dev/video2",
+	})
+	if !result.IsError {
+		t.Fatal("expected a device outside the allow-list to be refused")
+	}
+}
+
+func TestCameraTool_CaptureMaixCam(t *testing.T) {
+	workspace := t.TempDir()
+	tool := NewCameraTool(workspace, nil)
+	args := map[string]interface{}{"action": "capture", "source": "maixcam"}
+
+	if result := tool.Execute(context.Background(), args); !result.IsError {
+		t.Fatal("expected an error without a MaixCam")
+	}
+
+	tool.SetMaixCam(&fakeSnapshotSource{err: errors.New("no connected MaixCam devices")})
+	result := tool.Execute(context.Background(), args)
+	if !result.IsError || !strings.Contains(result.ForLLM, "no connected MaixCam devices") {
+		t.Fatalf("result = %+v", result)
+	}
+
+	tool.SetMaixCam(&fakeSnapshotSource{image: []byte("frame")})
+	result = tool.Execute(context.Background(), args)
+	if result.IsError {
+		t.Fatalf("capture failed: %s", result.ForLLM)
+	}
+	if len(result.Media) != 1 || !strings.HasSuffix(result.Media[0], "-maixcam.jpg") {
+		t.Fatalf("media = %v", result.Media)
+	}
+	if !strings.Contains(result.ForLLM, "camera/") {
+		t.Errorf("result does not name the saved file: %s", result.ForLLM)
+	}
+}
+
+func TestCameraTool_InvalidArgs(t *testing.T) {
+	tool := NewCameraTool(t.TempDir(), nil)
+	for _, args := range []map[string]interface{}{
+		{"action": "capture", "source": "ip"},
+		{"action": "capture", "skip_frames": float64(100)},
+		{"action": "capture", "timeout_ms": float64(10)},
+		{"action": "capture", "device": "../etc/passwd"},
+		{"action": "record"},
+	} {
+		if result := tool.Execute(context.Background(), args); !result.IsError {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -9,12 +10,26 @@ import (
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
+// DirectRunOptions selects the agent, session and overrides for a job run.
+type DirectRunOptions struct {
+	AgentID       string // empty uses routing for the channel
+	SessionKey    string // empty uses the routed session
+	ChatSession   bool   // with no SessionKey, use the session of the channel/chatID conversation
+	NoHistory     bool   // start from an empty session
+	SendResponse  bool   // also deliver the final reply to channel/chatID
+	Model         string // model override
+	MaxIterations int    // tool iteration override
+
+	ResponseFormat *providers.ResponseFormat // reply with JSON matching this schema
+}
+
 // JobExecutor is the interface for executing cron jobs through the agent
 type JobExecutor interface {
-	ProcessDirectWithChannel(ctx context.Context, content, sessionKey, channel, chatID string) (string, error)
+	ProcessDirectWithOptions(ctx context.Context, content, channel, chatID string, opts DirectRunOptions) (string, error)
 }
 
 // CronTool provides scheduling capabilities for the agent
@@ -25,6 +40,7 @@ type CronTool struct {
 	execTool    *ExecTool
 	channel     string
 	chatID      string
+	sessionKey  string
 	mu          sync.RWMutex
 }
 
@@ -58,8 +74,8 @@ func (t *CronTool) Parameters() map[string]interface{} {
 		"properties": map[string]interface{}{
 			"action": map[string]interface{}{
 				"type":        "string",
-				"enum":        []string{"add", "list", "remove", "enable", "disable"},
-				"description": "Action to perform. Use 'add' when user wants to schedule a reminder or task.",
+				"enum":        []string{"add", "list", "remove", "enable", "disable", "history"},
+				"description": "Action to perform. Use 'add' when user wants to schedule a reminder or task. Use 'history' to see recent runs of a job.",
 			},
 			"message": map[string]interface{}{
 				"type":        "string",
@@ -83,7 +99,49 @@ func (t *CronTool) Parameters() map[string]interface{} {
 			},
 			"job_id": map[string]interface{}{
 				"type":        "string",
-				"description": "Job ID (for remove/enable/disable)",
+				"description": "Job ID (for remove/enable/disable/history)",
+			},
+			"max_retries": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional: how many times to retry a failed run before giving up. Default: 0",
+			},
+			"retry_backoff_seconds": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional: delay before the first retry; doubles on each further retry. Default: 30",
+			},
+			"timeout_seconds": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional: abort a run that takes longer than this many seconds",
+			},
+			"misfire_policy": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"skip", "run_once", "run_all"},
+				"description": "Optional: what to do with runs missed while the gateway was down. Default comes from config",
+			},
+			"agent": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: ID of the agent (from agents.list) that should handle the job. Default: the agent routed for the channel",
+			},
+			"session_mode": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"fresh", "job", "chat"},
+				"description": "Optional: 'fresh' starts an empty session every run, 'job' keeps one session for this job, 'chat' continues the current conversation and posts the reply there. Default: job",
+			},
+			"model": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: model to use for this job instead of the agent's model",
+			},
+			"max_iterations": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional: maximum tool iterations per run for this job",
+			},
+			"schema": map[string]interface{}{
+				"type":        "object",
+				"description": "Optional: JSON Schema the agent's reply must match on each run",
+			},
+			"limit": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional: number of runs to show for history. Default: 10",
 			},
 			"deliver": map[string]interface{}{
 				"type":        "boolean",
@@ -102,6 +160,25 @@ func (t *CronTool) SetContext(channel, chatID string) {
 	t.chatID = chatID
 }
 
+// SetSessionKey records the session of the current conversation so jobs can
+// be injected back into it
+func (t *CronTool) SetSessionKey(sessionKey string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sessionKey = sessionKey
+}
+
+// Clone returns a copy sharing the cron service, without a chat or
+// session set.
+func (t *CronTool) Clone() Tool {
+	return &CronTool{
+		cronService: t.cronService,
+		executor:    t.executor,
+		msgBus:      t.msgBus,
+		execTool:    t.execTool,
+	}
+}
+
 // Execute runs the tool with the given arguments
 func (t *CronTool) Execute(ctx context.Context, args map[string]interface{}) *ToolResult {
 	action, ok := args["action"].(string)
@@ -120,6 +197,8 @@ func (t *CronTool) Execute(ctx context.Context, args map[string]interface{}) *To
 		return t.enableJob(args, true)
 	case "disable":
 		return t.enableJob(args, false)
+	case "history":
+		return t.jobHistory(args)
 	default:
 		return ErrorResult(fmt.Sprintf("unknown action: %s", action))
 	}
@@ -129,6 +208,7 @@ func (t *CronTool) addJob(args map[string]interface{}) *ToolResult {
 	t.mu.RLock()
 	channel := t.channel
 	chatID := t.chatID
+	sessionKey := t.sessionKey
 	t.mu.RUnlock()
 
 	if channel == "" || chatID == "" {
@@ -184,6 +264,24 @@ func (t *CronTool) addJob(args map[string]interface{}) *ToolResult {
 		deliver = false
 	}
 
+	misfire, _ := args["misfire_policy"].(string)
+	if !cron.ValidMisfirePolicy(misfire) {
+		return ErrorResult(fmt.Sprintf("invalid misfire_policy: %s", misfire))
+	}
+
+	sessionMode, _ := args["session_mode"].(string)
+	if !cron.ValidSessionMode(sessionMode) {
+		return ErrorResult(fmt.Sprintf("invalid session_mode: %s", sessionMode))
+	}
+
+	var schema json.RawMessage
+	if raw, ok := args["schema"].(map[string]interface{}); ok && len(raw) > 0 {
+		schema, _ = json.Marshal(raw)
+		if _, err := providers.NewResponseFormat(schema, "cron"); err != nil {
+			return ErrorResult(fmt.Sprintf("invalid schema: %v", err))
+		}
+	}
+
 	// Truncate message for job name (max 30 chars)
 	messagePreview := utils.Truncate(message, 30)
 
@@ -199,9 +297,52 @@ func (t *CronTool) addJob(args map[string]interface{}) *ToolResult {
 		return ErrorResult(fmt.Sprintf("Error adding job: %v", err))
 	}
 
+	updated := false
 	if command != "" {
 		job.Payload.Command = command
-		// Need to save the updated payload
+		updated = true
+	}
+	if retries, ok := args["max_retries"].(float64); ok && retries > 0 {
+		job.Retry = &cron.CronRetryPolicy{MaxRetries: int(retries)}
+		if backoff, ok := args["retry_backoff_seconds"].(float64); ok && backoff > 0 {
+			job.Retry.BackoffMS = int64(backoff) * 1000
+		}
+		updated = true
+	}
+	if timeout, ok := args["timeout_seconds"].(float64); ok && timeout > 0 {
+		job.TimeoutMS = int64(timeout) * 1000
+		updated = true
+	}
+	if misfire != "" {
+		job.Misfire = misfire
+		updated = true
+	}
+	if agentID, ok := args["agent"].(string); ok && agentID != "" {
+		job.Payload.Agent = agentID
+		updated = true
+	}
+	if sessionMode != "" {
+		job.Payload.Session = sessionMode
+		updated = true
+	}
+	if sessionMode == cron.SessionChat && sessionKey != "" {
+		job.Payload.SessionKey = sessionKey
+		updated = true
+	}
+	if model, ok := args["model"].(string); ok && model != "" {
+		job.Payload.Model = model
+		updated = true
+	}
+	if maxIter, ok := args["max_iterations"].(float64); ok && maxIter > 0 {
+		job.Payload.MaxIterations = int(maxIter)
+		updated = true
+	}
+	if schema != nil {
+		job.Payload.Schema = schema
+		updated = true
+	}
+	if updated {
+		// Need to save the updated job
 		t.cronService.UpdateJob(job)
 	}
 
@@ -263,8 +404,43 @@ func (t *CronTool) enableJob(args map[string]interface{}, enable bool) *ToolResu
 	return SilentResult(fmt.Sprintf("Cron job '%s' %s", job.Name, status))
 }
 
-// ExecuteJob executes a cron job through the agent
-func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
+func (t *CronTool) jobHistory(args map[string]interface{}) *ToolResult {
+	jobID, ok := args["job_id"].(string)
+	if !ok || jobID == "" {
+		return ErrorResult("job_id is required for history")
+	}
+
+	job, ok := t.cronService.GetJob(jobID)
+	if !ok {
+		return ErrorResult(fmt.Sprintf("Job %s not found", jobID))
+	}
+
+	limit := 10
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+
+	runs := t.cronService.GetHistory(jobID, limit)
+	if len(runs) == 0 {
+		return SilentResult(fmt.Sprintf("No runs recorded for '%s' yet", job.Name))
+	}
+
+	result := fmt.Sprintf("Recent runs of '%s':\n", job.Name)
+	for _, r := range runs {
+		started := time.UnixMilli(r.StartedAtMS).Format("2006-01-02 15:04:05")
+		result += fmt.Sprintf("- %s %s (attempt %d, %dms)", started, r.Status, r.Attempt, r.DurationMS)
+		if r.Error != "" {
+			result += ": " + r.Error
+		}
+		result += "\n"
+	}
+
+	return SilentResult(result)
+}
+
+// ExecuteJob executes a cron job through the agent. The returned error is
+// recorded in the job's run history and triggers the job's retry policy.
+func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) (string, error) {
 	// Get channel/chatID from job payload
 	channel := job.Payload.Channel
 	chatID := job.Payload.To
@@ -296,7 +472,10 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 			ChatID:  chatID,
 			Content: output,
 		})
-		return "ok"
+		if result.IsError {
+			return result.ForLLM, fmt.Errorf("command failed: %s", utils.Truncate(result.ForLLM, 200))
+		}
+		return result.ForLLM, nil
 	}
 
 	// If deliver=true, send message directly without agent processing
@@ -306,26 +485,54 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 			ChatID:  chatID,
 			Content: job.Payload.Message,
 		})
-		return "ok"
+		return "ok", nil
 	}
 
 	// For deliver=false, process through agent (for complex tasks)
-	sessionKey := fmt.Sprintf("cron-%s", job.ID)
+	opts := DirectRunOptions{
+		AgentID:       job.Payload.Agent,
+		Model:         job.Payload.Model,
+		MaxIterations: job.Payload.MaxIterations,
+	}
+	switch job.Payload.Session {
+	case cron.SessionFresh:
+		opts.SessionKey = fmt.Sprintf("cron:%s:run", job.ID)
+		opts.NoHistory = true
+	case cron.SessionJob:
+		opts.SessionKey = fmt.Sprintf("cron:%s", job.ID)
+	case cron.SessionChat:
+		// The run continues the chat's conversation, so its reply goes back
+		// to that chat.
+		opts.SessionKey = job.Payload.SessionKey
+		opts.ChatSession = true
+		opts.SendResponse = true
+	default:
+		// Jobs without a session mode get a per-job session, which the
+		// executor scopes to the agent as agent:<agent>:cron-<id>.
+		opts.SessionKey = fmt.Sprintf("cron-%s", job.ID)
+	}
+	if len(job.Payload.Schema) > 0 {
+		rf, err := providers.NewResponseFormat(job.Payload.Schema, "cron_"+job.ID)
+		if err != nil {
+			return "", fmt.Errorf("job schema: %w", err)
+		}
+		opts.ResponseFormat = rf
+	}
 
 	// Call agent with job's message
-	response, err := t.executor.ProcessDirectWithChannel(
+	response, err := t.executor.ProcessDirectWithOptions(
 		ctx,
 		job.Payload.Message,
-		sessionKey,
 		channel,
 		chatID,
+		opts,
 	)
 
 	if err != nil {
-		return fmt.Sprintf("Error: %v", err)
+		return "", err
 	}
 
-	// Response is automatically sent via MessageBus by AgentLoop
-	_ = response // Will be sent by AgentLoop
-	return "ok"
+	// Outside chat sessions the reply is only recorded in the run history;
+	// the agent reaches the user through the message tool.
+	return response, nil
 }
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/cron"
+)
+
+type recordingExecutor struct {
+	opts DirectRunOptions
+}
+
+func (e *recordingExecutor) ProcessDirectWithOptions(ctx context.Context, content, channel, chatID string, opts DirectRunOptions) (string, error) {
+	e.opts = opts
+	return "done", nil
+}
+
+func TestCronTool_ExecuteJobSession(t *testing.T) {
+	executor := &recordingExecutor{}
+	tool := &CronTool{executor: executor, msgBus: bus.NewMessageBus()}
+
+	tests := []struct {
+		session, sessionKey string
+		want                DirectRunOptions
+	}{
+		{"", "", DirectRunOptions{SessionKey: "cron-job1"}},
+		{cron.SessionJob, "", DirectRunOptions{SessionKey: "cron:job1"}},
+		{cron.SessionFresh, "", DirectRunOptions{SessionKey: "cron:job1:run", NoHistory: true}},
+		{cron.SessionChat, "agent:main:telegram:group:42", DirectRunOptions{SessionKey: "agent:main:telegram:group:42", ChatSession: true, SendResponse: true}},
+		{cron.SessionChat, "", DirectRunOptions{ChatSession: true, SendResponse: true}},
+	}
+	for _, tt := range tests {
+		job := &cron.CronJob{ID: "job1", Payload: cron.CronPayload{
+			Message: "report", Channel: "telegram", To: "42", Session: tt.session, SessionKey: tt.sessionKey,
+		}}
+		if _, err := tool.ExecuteJob(context.Background(), job); err != nil {
+			t.Fatalf("ExecuteJob failed: %v", err)
+		}
+		if executor.opts != tt.want {
+			t.Errorf("session %q: options = %+v, want %+v", tt.session, executor.opts, tt.want)
+		}
+	}
+}
+
+func TestCronTool_ExecuteJobSchema(t *testing.T) {
+	executor := &recordingExecutor{}
+	tool := &CronTool{executor: executor, msgBus: bus.NewMessageBus()}
+
+	job := &cron.CronJob{ID: "job1", Payload: cron.CronPayload{
+		Message: "report", Channel: "telegram", To: "42",
+		Schema: []byte(`{"title":"reading","type":"object","properties":{"celsius":{"type":"number"}}}`),
+	}}
+	if _, err := tool.ExecuteJob(context.Background(), job); err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+	rf := executor.opts.ResponseFormat
+	if rf == nil || rf.Name != "reading" || rf.Schema["type"] != "object" {
+		t.Errorf("response format = %+v", rf)
+	}
+
+	job.Payload.Schema = []byte(`{"type":`)
+	if _, err := tool.ExecuteJob(context.Background(), job); err == nil {
+		t.Error("ExecuteJob accepted an invalid schema")
+	}
+}
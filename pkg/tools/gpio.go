@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	"github.com/sipeed/picoclaw/pkg/devices/gpio"
+)
+
+const (
+	gpioDefaultWaitMs = 10000
+	gpioMaxWaitMs     = 60000
+	gpioMaxHoldMs     = 60000
+)
+
+// GPIOTool reads and drives GPIO lines through the Linux GPIO character device.
+type GPIOTool struct {
+	allowed DeviceAllowList
+}
+
+func NewGPIOTool(allowed []string) *GPIOTool {
+	return &GPIOTool{allowed: allowed}
+}
+
+func (t *GPIOTool) Name() string {
+	return "gpio"
+}
+
+func (t *GPIOTool) Description() string {
+	return "Read and drive GPIO pins through /dev/gpiochipN. Actions: list (chips, or the lines of one chip), read (line level), write (set an output level), wait (block until an edge or timeout). Linux only."
+}
+
+func (t *GPIOTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"list", "read", "write", "wait"},
+				"description": "Action to perform: list (list chips; with chip, list its lines), read (read a line), write (drive a line as output), wait (wait for an edge on a line)",
+			},
+			"chip": map[string]interface{}{
+				"type":        "string",
+				"description": "GPIO chip number or name (e.g. \"0\" or \"gpiochip0\"). Default: gpiochip0.",
+			},
+			"line": map[string]interface{}{
+				"type":        "integer",
+				"description": "Line offset on the chip. Required for read/write/wait.",
+			},
+			"value": map[string]interface{}{
+				"type":        "integer",
+				"enum":        []int{0, 1},
+				"description": "Level to drive (0 or 1). Required for write.",
+			},
+			"hold_ms": map[string]interface{}{
+				"type":        "integer",
+				"description": "Keep driving the line for this many milliseconds before releasing it (0-60000). Default: 0. Most boards keep the level after release.",
+			},
+			"bias": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"pull_up", "pull_down", "disable"},
+				"description": "Input bias for read/wait. Default: leave as configured.",
+			},
+			"edge": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"rising", "falling", "both"},
+				"description": "Edge to wait for. Default: both.",
+			},
+			"timeout_ms": map[string]interface{}{
+				"type":        "integer",
+				"description": "How long wait blocks (1-60000). Default: 10000.",
+			},
+			"confirm": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Must be true for write. Safety guard to prevent accidentally driving a pin.",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *GPIOTool) Execute(ctx context.Context, args map[string]interface{}) *ToolResult {
+	if runtime.GOOS != "linux" {
+		return ErrorResult("GPIO is only supported on Linux. This tool requires /dev/gpiochip* device files.")
+	}
+
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+
+	switch action {
+	case "list":
+		return t.list(args)
+	case "read":
+		return t.readLine(args)
+	case "write":
+		return t.writeLine(args)
+	case "wait":
+		return t.waitEdge(ctx, args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s (valid: list, read, write, wait)", action))
+	}
+}
+
+// parseGPIOChip resolves the chip argument to an allowed device path.
+func (t *GPIOTool) parseGPIOChip(args map[string]interface{}) (string, *ToolResult) {
+	chip, _ := args["chip"].(string)
+	path, err := gpio.ChipPath(chip)
+	if err != nil {
+		return "", ErrorResult(err.Error())
+	}
+	if res := t.allowed.check(path); res != nil {
+		return "", res
+	}
+	return path, nil
+}
+
+// parseGPIOLine extracts and validates a line offset from args.
+func parseGPIOLine(args map[string]interface{}) (int, *ToolResult) {
+	line, ok := args["line"].(float64)
+	if !ok {
+		return 0, ErrorResult("line is required (the line offset on the chip, e.g. 17)")
+	}
+	if line < 0 || line != float64(int(line)) {
+		return 0, ErrorResult("line must be a non-negative integer")
+	}
+	return int(line), nil
+}
+
+// parseMillis reads an optional millisecond argument bounded by max.
+func parseMillis(args map[string]interface{}, key string, def, min, max int) (int, *ToolResult) {
+	v, ok := args[key].(float64)
+	if !ok {
+		return def, nil
+	}
+	ms := int(v)
+	if ms < min || ms > max {
+		return 0, ErrorResult(fmt.Sprintf("%s must be between %d and %d", key, min, max))
+	}
+	return ms, nil
+}
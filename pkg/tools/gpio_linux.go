@@ -0,0 +1,168 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/devices/gpio"
+)
+
+const gpioConsumer = "picoclaw"
+
+// list lists allowed chips, or the lines of one chip.
+func (t *GPIOTool) list(args map[string]interface{}) *ToolResult {
+	if chip, _ := args["chip"].(string); chip != "" {
+		path, errResult := t.parseGPIOChip(args)
+		if errResult != nil {
+			return errResult
+		}
+		lines, err := gpio.Lines(path)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to list lines of %s: %v", path, err))
+		}
+		result, _ := json.MarshalIndent(lines, "", "  ")
+		return SilentResult(fmt.Sprintf("%s has %d line(s):\n%s", gpio.ChipName(path), len(lines), string(result)))
+	}
+
+	chips, err := gpio.Chips()
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to scan for GPIO chips: %v", err))
+	}
+	allowed := chips[:0]
+	for _, c := range chips {
+		if t.allowed.Allows(c.Path) {
+			allowed = append(allowed, c)
+		}
+	}
+	if len(allowed) == 0 {
+		return SilentResult("No GPIO chips found. Check that /dev/gpiochip* exists and that this agent's hardware_devices allows it.")
+	}
+	result, _ := json.MarshalIndent(allowed, "", "  ")
+	return SilentResult(fmt.Sprintf("Found %d GPIO chip(s):\n%s", len(allowed), string(result)))
+}
+
+// readLine requests the line as an input and reads its level.
+func (t *GPIOTool) readLine(args map[string]interface{}) *ToolResult {
+	path, errResult := t.parseGPIOChip(args)
+	if errResult != nil {
+		return errResult
+	}
+	line, errResult := parseGPIOLine(args)
+	if errResult != nil {
+		return errResult
+	}
+	bias, _ := args["bias"].(string)
+
+	req, err := gpio.RequestLines(path, gpioConsumer, []gpio.LineConfig{{Offset: line, Bias: bias}})
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to request %s line %d: %v", gpio.ChipName(path), line, err))
+	}
+	defer req.Close()
+	values, err := req.Values()
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read %s line %d: %v", gpio.ChipName(path), line, err))
+	}
+
+	result, _ := json.MarshalIndent(map[string]interface{}{
+		"chip":  gpio.ChipName(path),
+		"line":  line,
+		"value": values[0],
+	}, "", "  ")
+	return SilentResult(string(result))
+}
+
+// writeLine drives the line as an output, optionally holding it before release.
+func (t *GPIOTool) writeLine(args map[string]interface{}) *ToolResult {
+	confirm, _ := args["confirm"].(bool)
+	if !confirm {
+		return ErrorResult("write operations require confirm: true. Please confirm with the user before driving GPIO pins, as this can switch connected hardware.")
+	}
+	path, errResult := t.parseGPIOChip(args)
+	if errResult != nil {
+		return errResult
+	}
+	line, errResult := parseGPIOLine(args)
+	if errResult != nil {
+		return errResult
+	}
+	value, ok := args["value"].(float64)
+	if !ok || (value != 0 && value != 1) {
+		return ErrorResult("value is required and must be 0 or 1")
+	}
+	holdMs, errResult := parseMillis(args, "hold_ms", 0, 0, gpioMaxHoldMs)
+	if errResult != nil {
+		return errResult
+	}
+
+	req, err := gpio.RequestLines(path, gpioConsumer, []gpio.LineConfig{{Offset: line, Output: true, Value: int(value)}})
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to request %s line %d as output: %v", gpio.ChipName(path), line, err))
+	}
+	defer req.Close()
+	if holdMs > 0 {
+		time.Sleep(time.Duration(holdMs) * time.Millisecond)
+	}
+
+	return SilentResult(fmt.Sprintf("Set %s line %d to %d", gpio.ChipName(path), line, int(value)))
+}
+
+// waitEdge blocks until an edge on the line, the timeout or cancellation.
+func (t *GPIOTool) waitEdge(ctx context.Context, args map[string]interface{}) *ToolResult {
+	path, errResult := t.parseGPIOChip(args)
+	if errResult != nil {
+		return errResult
+	}
+	line, errResult := parseGPIOLine(args)
+	if errResult != nil {
+		return errResult
+	}
+	timeoutMs, errResult := parseMillis(args, "timeout_ms", gpioDefaultWaitMs, 1, gpioMaxWaitMs)
+	if errResult != nil {
+		return errResult
+	}
+	edge, _ := args["edge"].(string)
+	if edge == "" {
+		edge = "both"
+	}
+	bias, _ := args["bias"].(string)
+
+	req, err := gpio.RequestLines(path, gpioConsumer, []gpio.LineConfig{{Offset: line, Edge: edge, Bias: bias}})
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to request %s line %d: %v", gpio.ChipName(path), line, err))
+	}
+	defer req.Close()
+	stop := context.AfterFunc(ctx, func() { req.SetReadDeadline(time.Now()) })
+	defer stop()
+	if err := req.SetReadDeadline(time.Now().Add(time.Duration(timeoutMs) * time.Millisecond)); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to set timeout: %v", err))
+	}
+
+	buf := make([]byte, gpio.EdgeEventSize)
+	for {
+		n, err := req.Read(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if ctx.Err() != nil {
+				return ErrorResult("wait cancelled")
+			}
+			return SilentResult(fmt.Sprintf("No %s edge on %s line %d within %dms", edge, gpio.ChipName(path), line, timeoutMs))
+		}
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to read edge events: %v", err))
+		}
+		evs := gpio.ParseEdgeEvents(buf[:n])
+		if len(evs) == 0 {
+			continue
+		}
+		result, _ := json.MarshalIndent(map[string]interface{}{
+			"chip":         gpio.ChipName(path),
+			"line":         evs[0].Offset,
+			"edge":         evs[0].EdgeName(),
+			"timestamp_ns": evs[0].TimestampNs,
+		}, "", "  ")
+		return SilentResult(string(result))
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package tools
+
+import "context"
+
+// list is a stub for non-Linux platforms.
+func (t *GPIOTool) list(args map[string]interface{}) *ToolResult {
+	return ErrorResult("GPIO is only supported on Linux")
+}
+
+// readLine is a stub for non-Linux platforms.
+func (t *GPIOTool) readLine(args map[string]interface{}) *ToolResult {
+	return ErrorResult("GPIO is only supported on Linux")
+}
+
+// writeLine is a stub for non-Linux platforms.
+func (t *GPIOTool) writeLine(args map[string]interface{}) *ToolResult {
+	return ErrorResult("GPIO is only supported on Linux")
+}
+
+// waitEdge is a stub for non-Linux platforms.
+func (t *GPIOTool) waitEdge(ctx context.Context, args map[string]interface{}) *ToolResult {
+	return ErrorResult("GPIO is only supported on Linux")
+}
@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DeviceAllowList limits the hardware tools to device paths matching its
+// glob patterns (e.g. "/dev/ttyUSB*", "/dev/gpiochip0",
+// "/sys/class/pwm/pwmchip0", "/dev/i2c-1"). An empty list allows every
+// device.
+type DeviceAllowList []string
+
+// Allows reports whether the device at path is allowed. Symlinks are
+// resolved first, so a link only grants access if its target matches a
+// pattern or is the target of an entry without wildcards, such as a
+// "/dev/serial/by-id/..." name.
+func (a DeviceAllowList) Allows(path string) bool {
+	if len(a) == 0 {
+		return true
+	}
+	resolved := resolveDevice(path)
+	for _, pattern := range a {
+		pattern = strings.TrimSpace(pattern)
+		if ok, _ := filepath.Match(pattern, resolved); ok {
+			return true
+		}
+		if !strings.ContainsAny(pattern, "*?[") && resolveDevice(pattern) == resolved {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDevice returns the cleaned path with symlinks resolved, or just the
+// cleaned path if it cannot be resolved.
+func resolveDevice(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
+
+// check returns an error result when path is not allowed.
+func (a DeviceAllowList) check(path string) *ToolResult {
+	if a.Allows(path) {
+		return nil
+	}
+	return ErrorResult(fmt.Sprintf("access to %s is not allowed for this agent (see hardware_devices in config)", path))
+}
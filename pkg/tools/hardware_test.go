@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDeviceAllowList(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "ttyUSB0")
+	link := filepath.Join(dir, "usb-FTDI_FT232R-if00")
+	other := filepath.Join(dir, "mem")
+	spoof := filepath.Join(dir, "ttyUSB9")
+	sensor := filepath.Join(dir, "spidev0.0")
+	named := filepath.Join(dir, "by-id-sensor")
+	os.WriteFile(target, nil, 0644)
+	os.WriteFile(other, nil, 0644)
+	os.WriteFile(sensor, nil, 0644)
+	os.Symlink(target, link)
+	os.Symlink(other, spoof)
+	os.Symlink(sensor, named)
+
+	var empty DeviceAllowList
+	if !empty.Allows("/dev/ttyS0") {
+		t.Error("empty allow-list should allow every device")
+	}
+
+	allowed := DeviceAllowList{filepath.Join(dir, "ttyUSB*"), "/dev/gpiochip0", named}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{target, true},
+		{link, true},   // resolved through the symlink
+		{spoof, false}, // named like an allowed device, points elsewhere
+		{named, true},  // listed by its own name
+		{sensor, true}, // the target of a listed name
+		{other, false},
+		{"/dev/gpiochip0", true},
+		{"/dev/gpiochip1", false},
+		{"/dev/ttyS0", false},
+	}
+	for _, tt := range tests {
+		if got := allowed.Allows(tt.path); got != tt.want {
+			t.Errorf("Allows(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestGPIOTool_RejectsDisallowedChip(t *testing.T) {
+	tool := NewGPIOTool([]string{"/dev/gpiochip0"})
+	result := tool.Execute(context.Background(), map[string]interface{}{
+		"action": "read", "chip": "1", "line": float64(4),
+	})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not allowed") {
+		t.Errorf("expected allow-list error, got: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]interface{}{
+		"action": "write", "chip": "0", "line": float64(4), "value": float64(1),
+	})
+	if !result.IsError || !strings.Contains(result.ForLLM, "confirm") {
+		t.Errorf("expected confirm error, got: %s", result.ForLLM)
+	}
+}
+
+func TestI2CAndSPITools_RejectDisallowedDevice(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("I2C and SPI are Linux only")
+	}
+	allowed := []string{"/dev/i2c-1", "/dev/spidev0.0"}
+
+	result := NewI2CTool(allowed).Execute(context.Background(), map[string]interface{}{
+		"action": "read", "bus": "3", "address": float64(0x38),
+	})
+	if !result.IsError || !strings.Contains(result.ForLLM, "/dev/i2c-3 is not allowed") {
+		t.Errorf("i2c: expected allow-list error, got: %s", result.ForLLM)
+	}
+
+	result = NewSPITool(allowed).Execute(context.Background(), map[string]interface{}{
+		"action": "read", "device": "1.0", "length": float64(2),
+	})
+	if !result.IsError || !strings.Contains(result.ForLLM, "/dev/spidev1.0 is not allowed") {
+		t.Errorf("spi: expected allow-list error, got: %s", result.ForLLM)
+	}
+}
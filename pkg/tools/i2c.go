@@ -10,10 +10,12 @@ import (
 )
 
 // I2CTool provides I2C bus interaction for reading sensors and controlling peripherals.
-type I2CTool struct{}
+type I2CTool struct {
+	allowed DeviceAllowList
+}
 
-func NewI2CTool() *I2CTool {
-	return &I2CTool{}
+func NewI2CTool(allowed []string) *I2CTool {
+	return &I2CTool{allowed: allowed}
 }
 
 func (t *I2CTool) Name() string {
@@ -106,7 +108,7 @@ func (t *I2CTool) detect() *ToolResult {
 	buses := make([]busInfo, 0, len(matches))
 	re := regexp.MustCompile(`/dev/i2c-(\d+)`)
 	for _, m := range matches {
-		if sub := re.FindStringSubmatch(m); sub != nil {
+		if sub := re.FindStringSubmatch(m); sub != nil && t.allowed.Allows(m) {
 			buses = append(buses, busInfo{Path: m, Bus: sub[1]})
 		}
 	}
@@ -134,8 +136,9 @@ func parseI2CAddress(args map[string]interface{}) (int, *ToolResult) {
 	return addr, nil
 }
 
-// parseI2CBus extracts and validates an I2C bus from args
-func parseI2CBus(args map[string]interface{}) (string, *ToolResult) {
+// parseI2CBus extracts and validates an I2C bus from args and checks that
+// the agent may use it
+func (t *I2CTool) parseI2CBus(args map[string]interface{}) (string, *ToolResult) {
 	bus, ok := args["bus"].(string)
 	if !ok || bus == "" {
 		return "", ErrorResult("bus is required (e.g. \"1\" for /dev/i2c-1)")
@@ -143,5 +146,8 @@ func parseI2CBus(args map[string]interface{}) (string, *ToolResult) {
 	if !isValidBusID(bus) {
 		return "", ErrorResult("invalid bus identifier: must be a number (e.g. \"1\")")
 	}
+	if res := t.allowed.check("/dev/i2c-" + bus); res != nil {
+		return "", res
+	}
 	return bus, nil
 }
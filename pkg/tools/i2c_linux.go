@@ -75,7 +75,7 @@ func smbusProbe(fd int, addr int, hasQuick bool) bool {
 // Uses the same hybrid probe strategy as i2cdetect's MODE_AUTO:
 // SMBus Quick Write for most addresses, SMBus Read Byte for EEPROM ranges.
 func (t *I2CTool) scan(args map[string]interface{}) *ToolResult {
-	bus, errResult := parseI2CBus(args)
+	bus, errResult := t.parseI2CBus(args)
 	if errResult != nil {
 		return errResult
 	}
@@ -143,7 +143,7 @@ func (t *I2CTool) scan(args map[string]interface{}) *ToolResult {
 
 // readDevice reads bytes from an I2C device, optionally at a specific register
 func (t *I2CTool) readDevice(args map[string]interface{}) *ToolResult {
-	bus, errResult := parseI2CBus(args)
+	bus, errResult := t.parseI2CBus(args)
 	if errResult != nil {
 		return errResult
 	}
@@ -218,7 +218,7 @@ func (t *I2CTool) writeDevice(args map[string]interface{}) *ToolResult {
 		return ErrorResult("write operations require confirm: true. Please confirm with the user before writing to I2C devices, as incorrect writes can misconfigure hardware.")
 	}
 
-	bus, errResult := parseI2CBus(args)
+	bus, errResult := t.parseI2CBus(args)
 	if errResult != nil {
 		return errResult
 	}
@@ -53,6 +53,11 @@ func (t *MessageTool) SetContext(channel, chatID string) {
 	t.sentInRound = false // Reset send tracking for new processing round
 }
 
+// Clone returns a copy that sends through the same callback.
+func (t *MessageTool) Clone() Tool {
+	return &MessageTool{sendCallback: t.sendCallback}
+}
+
 // HasSentInRound returns true if the message tool sent a message during the current round.
 func (t *MessageTool) HasSentInRound() bool {
 	return t.sentInRound
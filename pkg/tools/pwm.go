@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+)
+
+// PWMTool controls PWM outputs through the Linux sysfs interface (/sys/class/pwm).
+type PWMTool struct {
+	allowed DeviceAllowList
+	root    string
+}
+
+func NewPWMTool(allowed []string) *PWMTool {
+	return &PWMTool{allowed: allowed, root: "/sys/class/pwm"}
+}
+
+func (t *PWMTool) Name() string {
+	return "pwm"
+}
+
+func (t *PWMTool) Description() string {
+	return "Control PWM outputs (LED dimming, servos, fans, buzzers) through /sys/class/pwm. Actions: list (chips and channel state), set (configure frequency/period and duty cycle, then enable), disable (stop a channel). Linux only."
+}
+
+func (t *PWMTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"list", "set", "disable"},
+				"description": "Action to perform: list (list PWM chips and exported channels), set (configure and enable a channel), disable (disable a channel)",
+			},
+			"chip": map[string]interface{}{
+				"type":        "string",
+				"description": "PWM chip number (e.g. \"0\" for /sys/class/pwm/pwmchip0). Required for set/disable.",
+			},
+			"channel": map[string]interface{}{
+				"type":        "integer",
+				"description": "Channel on the chip. Default: 0.",
+			},
+			"frequency_hz": map[string]interface{}{
+				"type":        "number",
+				"description": "Output frequency in Hz (e.g. 50 for servos). Use this or period_ns for set.",
+			},
+			"period_ns": map[string]interface{}{
+				"type":        "integer",
+				"description": "Period in nanoseconds. Use this or frequency_hz for set.",
+			},
+			"duty_percent": map[string]interface{}{
+				"type":        "number",
+				"description": "Duty cycle as a percentage of the period (0-100). Use this or duty_ns for set.",
+			},
+			"duty_ns": map[string]interface{}{
+				"type":        "integer",
+				"description": "High time in nanoseconds (e.g. 1500000 for a centred servo). Use this or duty_percent for set.",
+			},
+			"polarity": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"normal", "inversed"},
+				"description": "Output polarity. Default: leave as is. Not every controller supports changing it.",
+			},
+			"confirm": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Must be true for set/disable. Safety guard to prevent accidentally driving motors or heaters.",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *PWMTool) Execute(ctx context.Context, args map[string]interface{}) *ToolResult {
+	if runtime.GOOS != "linux" {
+		return ErrorResult("PWM is only supported on Linux. This tool requires /sys/class/pwm.")
+	}
+
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+
+	switch action {
+	case "list":
+		return t.list()
+	case "set":
+		return t.set(args)
+	case "disable":
+		return t.disable(args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s (valid: list, set, disable)", action))
+	}
+}
+
+var pwmChipPattern = regexp.MustCompile(`^(?:pwmchip)?(\d+)$`)
+
+// parsePWMChannel resolves chip and channel to the allowed chip directory.
+func (t *PWMTool) parsePWMChannel(args map[string]interface{}) (string, int, *ToolResult) {
+	chip, _ := args["chip"].(string)
+	m := pwmChipPattern.FindStringSubmatch(chip)
+	if m == nil {
+		return "", 0, ErrorResult("chip is required and must be a number (e.g. \"0\" for pwmchip0)")
+	}
+	chipDir := filepath.Join(t.root, "pwmchip"+m[1])
+	if res := t.allowed.check(chipDir); res != nil {
+		return "", 0, res
+	}
+
+	channel := 0
+	if v, ok := args["channel"].(float64); ok {
+		if v < 0 || v != float64(int(v)) {
+			return "", 0, ErrorResult("channel must be a non-negative integer")
+		}
+		channel = int(v)
+	}
+	return chipDir, channel, nil
+}
+
+// pwmTiming computes the period and duty cycle in nanoseconds from args.
+func pwmTiming(args map[string]interface{}) (period, duty int64, errResult *ToolResult) {
+	if ns, ok := args["period_ns"].(float64); ok {
+		period = int64(ns)
+	} else if hz, ok := args["frequency_hz"].(float64); ok && hz > 0 {
+		period = int64(1e9/hz + 0.5)
+	}
+	if period <= 0 {
+		return 0, 0, ErrorResult("frequency_hz or period_ns is required and must be positive")
+	}
+
+	if ns, ok := args["duty_ns"].(float64); ok {
+		duty = int64(ns)
+	} else if pct, ok := args["duty_percent"].(float64); ok {
+		if pct < 0 || pct > 100 {
+			return 0, 0, ErrorResult("duty_percent must be between 0 and 100")
+		}
+		duty = int64(float64(period)*pct/100 + 0.5)
+	} else {
+		return 0, 0, ErrorResult("duty_percent or duty_ns is required")
+	}
+	if duty < 0 || duty > period {
+		return 0, 0, ErrorResult(fmt.Sprintf("duty cycle %dns must be between 0 and the period (%dns)", duty, period))
+	}
+	return period, duty, nil
+}
@@ -0,0 +1,199 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pwmChannelInfo is the state of an exported PWM channel.
+type pwmChannelInfo struct {
+	Channel     int     `json:"channel"`
+	Enabled     bool    `json:"enabled"`
+	PeriodNs    int64   `json:"period_ns"`
+	DutyNs      int64   `json:"duty_ns"`
+	FrequencyHz float64 `json:"frequency_hz,omitempty"`
+	DutyPercent float64 `json:"duty_percent,omitempty"`
+	Polarity    string  `json:"polarity,omitempty"`
+}
+
+type pwmChipInfo struct {
+	Chip     string           `json:"chip"`
+	Path     string           `json:"path"`
+	Channels int              `json:"channels"`
+	Exported []pwmChannelInfo `json:"exported,omitempty"`
+}
+
+// list lists allowed PWM chips and the state of their exported channels.
+func (t *PWMTool) list() *ToolResult {
+	matches, err := filepath.Glob(filepath.Join(t.root, "pwmchip*"))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to scan for PWM chips: %v", err))
+	}
+	sort.Strings(matches)
+
+	var chips []pwmChipInfo
+	for _, dir := range matches {
+		if !t.allowed.Allows(dir) {
+			continue
+		}
+		info := pwmChipInfo{Chip: strings.TrimPrefix(filepath.Base(dir), "pwmchip"), Path: dir}
+		info.Channels, _ = readSysfsInt(filepath.Join(dir, "npwm"))
+		for ch := 0; ch < info.Channels; ch++ {
+			chDir := filepath.Join(dir, "pwm"+strconv.Itoa(ch))
+			if _, err := os.Stat(chDir); err == nil {
+				info.Exported = append(info.Exported, readPWMChannel(chDir, ch))
+			}
+		}
+		chips = append(chips, info)
+	}
+
+	if len(chips) == 0 {
+		return SilentResult("No PWM chips found. You may need to enable a PWM overlay in the device tree (e.g. dtoverlay=pwm on Raspberry Pi) and check this agent's hardware_devices.")
+	}
+	result, _ := json.MarshalIndent(chips, "", "  ")
+	return SilentResult(fmt.Sprintf("Found %d PWM chip(s):\n%s", len(chips), string(result)))
+}
+
+// set configures a channel's period, duty cycle and polarity and enables it.
+func (t *PWMTool) set(args map[string]interface{}) *ToolResult {
+	confirm, _ := args["confirm"].(bool)
+	if !confirm {
+		return ErrorResult("set requires confirm: true. Please confirm with the user before driving PWM outputs, as they may power motors, heaters or buzzers.")
+	}
+	chipDir, channel, errResult := t.parsePWMChannel(args)
+	if errResult != nil {
+		return errResult
+	}
+	period, duty, errResult := pwmTiming(args)
+	if errResult != nil {
+		return errResult
+	}
+	polarity, _ := args["polarity"].(string)
+	if polarity != "" && polarity != "normal" && polarity != "inversed" {
+		return ErrorResult("polarity must be normal or inversed")
+	}
+
+	chDir, err := exportPWMChannel(chipDir, channel)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	// The kernel rejects a duty cycle longer than the period, so shrink the
+	// duty cycle before shortening the period.
+	if cur, err := readSysfsInt64(filepath.Join(chDir, "duty_cycle")); err == nil && cur > period {
+		if err := writeSysfs(chDir, "duty_cycle", "0"); err != nil {
+			return ErrorResult(err.Error())
+		}
+	}
+	if err := writeSysfs(chDir, "period", strconv.FormatInt(period, 10)); err != nil {
+		return ErrorResult(err.Error())
+	}
+	if err := writeSysfs(chDir, "duty_cycle", strconv.FormatInt(duty, 10)); err != nil {
+		return ErrorResult(err.Error())
+	}
+	if polarity != "" {
+		// Polarity can only change while the channel is disabled.
+		if err := writeSysfs(chDir, "enable", "0"); err != nil {
+			return ErrorResult(err.Error())
+		}
+		if err := writeSysfs(chDir, "polarity", polarity); err != nil {
+			return ErrorResult(err.Error())
+		}
+	}
+	if err := writeSysfs(chDir, "enable", "1"); err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	info := readPWMChannel(chDir, channel)
+	return SilentResult(fmt.Sprintf("Enabled %s channel %d: %.6g Hz, %.4g%% duty (period %dns, duty %dns)",
+		filepath.Base(chipDir), channel, info.FrequencyHz, info.DutyPercent, info.PeriodNs, info.DutyNs))
+}
+
+// disable stops a channel.
+func (t *PWMTool) disable(args map[string]interface{}) *ToolResult {
+	confirm, _ := args["confirm"].(bool)
+	if !confirm {
+		return ErrorResult("disable requires confirm: true. Please confirm with the user before changing PWM outputs.")
+	}
+	chipDir, channel, errResult := t.parsePWMChannel(args)
+	if errResult != nil {
+		return errResult
+	}
+	chDir := filepath.Join(chipDir, "pwm"+strconv.Itoa(channel))
+	if _, err := os.Stat(chDir); err != nil {
+		return SilentResult(fmt.Sprintf("%s channel %d is not exported; nothing to disable", filepath.Base(chipDir), channel))
+	}
+	if err := writeSysfs(chDir, "enable", "0"); err != nil {
+		return ErrorResult(err.Error())
+	}
+	return SilentResult(fmt.Sprintf("Disabled %s channel %d", filepath.Base(chipDir), channel))
+}
+
+// exportPWMChannel exports the channel if needed and returns its directory.
+func exportPWMChannel(chipDir string, channel int) (string, error) {
+	chDir := filepath.Join(chipDir, "pwm"+strconv.Itoa(channel))
+	if _, err := os.Stat(chDir); err == nil {
+		return chDir, nil
+	}
+	if n, err := readSysfsInt(filepath.Join(chipDir, "npwm")); err != nil {
+		return "", fmt.Errorf("PWM chip %s not found: %v", chipDir, err)
+	} else if channel >= n {
+		return "", fmt.Errorf("%s has %d channel(s); channel %d does not exist", filepath.Base(chipDir), n, channel)
+	}
+	if err := writeSysfs(chipDir, "export", strconv.Itoa(channel)); err != nil {
+		return "", err
+	}
+	// udev may still be fixing up permissions on the new directory.
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(chDir, "enable")); err == nil {
+			return chDir, nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("exported %s channel %d but %s did not appear", filepath.Base(chipDir), channel, chDir)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func readPWMChannel(chDir string, channel int) pwmChannelInfo {
+	info := pwmChannelInfo{Channel: channel}
+	enable, _ := readSysfsInt(filepath.Join(chDir, "enable"))
+	info.Enabled = enable == 1
+	info.PeriodNs, _ = readSysfsInt64(filepath.Join(chDir, "period"))
+	info.DutyNs, _ = readSysfsInt64(filepath.Join(chDir, "duty_cycle"))
+	if data, err := os.ReadFile(filepath.Join(chDir, "polarity")); err == nil {
+		info.Polarity = strings.TrimSpace(string(data))
+	}
+	if info.PeriodNs > 0 {
+		info.FrequencyHz = 1e9 / float64(info.PeriodNs)
+		info.DutyPercent = 100 * float64(info.DutyNs) / float64(info.PeriodNs)
+	}
+	return info
+}
+
+func writeSysfs(dir, attr, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, attr), []byte(value), 0); err != nil {
+		return fmt.Errorf("failed to write %s to %s: %v", value, filepath.Join(dir, attr), err)
+	}
+	return nil
+}
+
+func readSysfsInt(path string) (int, error) {
+	n, err := readSysfsInt64(path)
+	return int(n), err
+}
+
+func readSysfsInt64(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
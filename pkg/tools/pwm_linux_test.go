@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakePWMChip creates a sysfs-like pwmchip with channel 0 already exported.
+func fakePWMChip(t *testing.T) (root, ch0 string) {
+	t.Helper()
+	root = t.TempDir()
+	chip := filepath.Join(root, "pwmchip0")
+	ch0 = filepath.Join(chip, "pwm0")
+	os.MkdirAll(ch0, 0755)
+	files := map[string]string{
+		filepath.Join(chip, "npwm"):      "2\n",
+		filepath.Join(chip, "export"):    "",
+		filepath.Join(ch0, "period"):     "1000000\n",
+		filepath.Join(ch0, "duty_cycle"): "900000\n",
+		filepath.Join(ch0, "enable"):     "0\n",
+		filepath.Join(ch0, "polarity"):   "normal\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, ch0
+}
+
+func readAttr(t *testing.T, dir, attr string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, attr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestPWMTool_Set(t *testing.T) {
+	root, ch0 := fakePWMChip(t)
+	tool := NewPWMTool(nil)
+	tool.root = root
+
+	// A 50 Hz servo signal centred at 1.5 ms.
+	result := tool.Execute(context.Background(), map[string]interface{}{
+		"action": "set", "chip": "0", "frequency_hz": float64(50), "duty_ns": float64(1500000), "confirm": true,
+	})
+	if result.IsError {
+		t.Fatalf("set failed: %s", result.ForLLM)
+	}
+	if p, d, e := readAttr(t, ch0, "period"), readAttr(t, ch0, "duty_cycle"), readAttr(t, ch0, "enable"); p != "20000000" || d != "1500000" || e != "1" {
+		t.Errorf("period=%s duty=%s enable=%s", p, d, e)
+	}
+	if !strings.Contains(result.ForLLM, "50 Hz") || !strings.Contains(result.ForLLM, "7.5% duty") {
+		t.Errorf("set result = %s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]interface{}{
+		"action": "set", "chip": "0", "period_ns": float64(1000), "duty_percent": float64(25), "confirm": true,
+	})
+	if result.IsError || readAttr(t, ch0, "duty_cycle") != "250" {
+		t.Errorf("percent duty: %s, duty=%s", result.ForLLM, readAttr(t, ch0, "duty_cycle"))
+	}
+
+	result = tool.Execute(context.Background(), map[string]interface{}{"action": "list"})
+	if result.IsError || !strings.Contains(result.ForLLM, `"enabled": true`) {
+		t.Errorf("list = %s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]interface{}{"action": "disable", "chip": "0", "confirm": true})
+	if result.IsError || readAttr(t, ch0, "enable") != "0" {
+		t.Errorf("disable: %s", result.ForLLM)
+	}
+}
+
+func TestPWMTool_Guards(t *testing.T) {
+	root, _ := fakePWMChip(t)
+	tool := NewPWMTool([]string{"/sys/class/pwm/pwmchip1"})
+	tool.root = root
+
+	tests := []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"action": "set", "chip": "0", "frequency_hz": float64(1000), "duty_percent": float64(50)}, "confirm"},
+		{map[string]interface{}{"action": "set", "chip": "0", "frequency_hz": float64(1000), "duty_percent": float64(50), "confirm": true}, "not allowed"},
+	}
+	for _, tt := range tests {
+		result := tool.Execute(context.Background(), tt.args)
+		if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("%v: got %s, want error containing %q", tt.args, result.ForLLM, tt.want)
+		}
+	}
+
+	if _, _, res := pwmTiming(map[string]interface{}{"period_ns": float64(1000), "duty_ns": float64(2000)}); res == nil {
+		t.Error("accepted a duty cycle longer than the period")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package tools
+
+// list is a stub for non-Linux platforms.
+func (t *PWMTool) list() *ToolResult {
+	return ErrorResult("PWM is only supported on Linux")
+}
+
+// set is a stub for non-Linux platforms.
+func (t *PWMTool) set(args map[string]interface{}) *ToolResult {
+	return ErrorResult("PWM is only supported on Linux")
+}
+
+// disable is a stub for non-Linux platforms.
+func (t *PWMTool) disable(args map[string]interface{}) *ToolResult {
+	return ErrorResult("PWM is only supported on Linux")
+}
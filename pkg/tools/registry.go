@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -27,6 +28,33 @@ func (r *ToolRegistry) Register(tool Tool) {
 	r.tools[tool.Name()] = tool
 }
 
+// sortedNames returns tool names in a stable order, so tool lists and the
+// system prompt are identical between calls and provider prompt caches hit.
+// Called with r.mu held.
+func (r *ToolRegistry) sortedNames() []string {
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Clone returns a registry with the same tools, where each CloneableTool is
+// replaced by its own copy.
+func (r *ToolRegistry) Clone() *ToolRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	clone := NewToolRegistry()
+	for name, tool := range r.tools {
+		if ct, ok := tool.(CloneableTool); ok {
+			tool = ct.Clone()
+		}
+		clone.tools[name] = tool
+	}
+	return clone
+}
+
 func (r *ToolRegistry) Get(name string) (Tool, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -106,8 +134,8 @@ func (r *ToolRegistry) GetDefinitions() []map[string]interface{} {
 	defer r.mu.RUnlock()
 
 	definitions := make([]map[string]interface{}, 0, len(r.tools))
-	for _, tool := range r.tools {
-		definitions = append(definitions, ToolToSchema(tool))
+	for _, name := range r.sortedNames() {
+		definitions = append(definitions, ToolToSchema(r.tools[name]))
 	}
 	return definitions
 }
@@ -119,8 +147,8 @@ func (r *ToolRegistry) ToProviderDefs() []providers.ToolDefinition {
 	defer r.mu.RUnlock()
 
 	definitions := make([]providers.ToolDefinition, 0, len(r.tools))
-	for _, tool := range r.tools {
-		schema := ToolToSchema(tool)
+	for _, name := range r.sortedNames() {
+		schema := ToolToSchema(r.tools[name])
 
 		// Safely extract nested values with type checks
 		fn, ok := schema["function"].(map[string]interface{})
@@ -149,11 +177,7 @@ func (r *ToolRegistry) List() []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	names := make([]string, 0, len(r.tools))
-	for name := range r.tools {
-		names = append(names, name)
-	}
-	return names
+	return r.sortedNames()
 }
 
 // Count returns the number of registered tools.
@@ -170,7 +194,8 @@ func (r *ToolRegistry) GetSummaries() []string {
 	defer r.mu.RUnlock()
 
 	summaries := make([]string, 0, len(r.tools))
-	for _, tool := range r.tools {
+	for _, name := range r.sortedNames() {
+		tool := r.tools[name]
 		summaries = append(summaries, fmt.Sprintf("- `%s` - %s", tool.Name(), tool.Description()))
 	}
 	return summaries
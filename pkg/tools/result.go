@@ -27,6 +27,11 @@ type ToolResult struct {
 	// When true, the tool will complete later and notify via callback.
 	Async bool `json:"async"`
 
+	// Media lists local image files the tool produced, such as a camera
+	// still. The agent loop attaches them to the next LLM request so vision
+	// models can see them.
+	Media []string `json:"media,omitempty"`
+
 	// Err is the underlying error (not JSON serialized).
 	// Used for internal error handling and logging.
 	Err error `json:"-"`
@@ -141,3 +146,13 @@ func (tr *ToolResult) WithError(err error) *ToolResult {
 	tr.Err = err
 	return tr
 }
+
+// WithMedia attaches image files to the result and returns it for chaining.
+//
+// Example:
+//
+//	result := SilentResult("Captured camera/front.jpg").WithMedia(path)
+func (tr *ToolResult) WithMedia(paths ...string) *ToolResult {
+	tr.Media = append(tr.Media, paths...)
+	return tr
+}
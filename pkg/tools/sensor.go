@@ -0,0 +1,418 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/devices/profiles"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// SensorTool reads calibrated quantities from I2C and SPI peripherals
+// described by device profiles: the built-in ones plus any .yaml/.json
+// profiles in the workspace's device_profiles directory.
+type SensorTool struct {
+	profileDir string
+	allowed    DeviceAllowList
+	open       func(p *profiles.Profile, target sensorTarget) (sensorBus, error)
+}
+
+// sensorTarget is where a device is attached.
+type sensorTarget struct {
+	Interface string // "i2c" or "spi"
+	Path      string
+	Address   int // I2C only
+}
+
+type sensorBus interface {
+	profiles.Bus
+	Close() error
+}
+
+var spiDevicePattern = regexp.MustCompile(`^\d+\.\d+$`)
+
+func NewSensorTool(workspace string, allowed []string) *SensorTool {
+	t := &SensorTool{allowed: allowed, open: openSensorBus}
+	if workspace != "" {
+		t.profileDir = filepath.Join(workspace, "device_profiles")
+	}
+	return t
+}
+
+func (t *SensorTool) Name() string {
+	return "sensor"
+}
+
+func (t *SensorTool) Description() string {
+	return "Read calibrated values from named I2C/SPI sensors using device profiles (e.g. temperature from a bme280 on bus 1 at 0x76). Actions: list (profiles and their quantities), read (quantities), run (a profile action such as clearing a display), detect (find profiled devices by their ID register). Custom profiles go in device_profiles/ in the workspace. Linux only for bus access."
+}
+
+func (t *SensorTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"list", "read", "run", "detect"},
+				"description": "Action to perform: list (all profiles; with profile, its details), read (read quantities), run (run a profile action), detect (probe a bus for profiled devices)",
+			},
+			"profile": map[string]interface{}{
+				"type":        "string",
+				"description": "Device profile name (e.g. \"bme280\", \"sht31\"). Required for read/run; optional for detect.",
+			},
+			"bus": map[string]interface{}{
+				"type":        "string",
+				"description": "I2C bus number (e.g. \"1\" for /dev/i2c-1). Use bus or device.",
+			},
+			"address": map[string]interface{}{
+				"type":        "integer",
+				"description": "7-bit I2C address (e.g. 0x76). Default: the profile's first address.",
+			},
+			"device": map[string]interface{}{
+				"type":        "string",
+				"description": "SPI device (e.g. \"0.0\" for /dev/spidev0.0), for profiles that support SPI. Use bus or device.",
+			},
+			"quantities": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Quantities to read (e.g. [\"temperature\"]). Default: all of the profile's quantities.",
+			},
+			"params": map[string]interface{}{
+				"type":        "object",
+				"description": "Overrides for the profile's numeric parameters (e.g. {\"shunt_ohms\": 0.01}).",
+			},
+			"command": map[string]interface{}{
+				"type":        "string",
+				"description": "Profile action to run (e.g. \"clear\"). Required for run.",
+			},
+			"args": map[string]interface{}{
+				"type":        "object",
+				"description": "Byte arguments (0-255) for the profile action (e.g. {\"value\": 128}).",
+			},
+			"confirm": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Must be true for run. Safety guard to prevent accidental writes to a device.",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *SensorTool) Execute(ctx context.Context, args map[string]interface{}) *ToolResult {
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+
+	registry := profiles.NewRegistry()
+	var loadErrs []error
+	if t.profileDir != "" {
+		loadErrs = registry.LoadDir(t.profileDir)
+		for _, err := range loadErrs {
+			logger.WarnCF("tool", "Skipping invalid device profile", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	switch action {
+	case "list":
+		return t.list(registry, loadErrs, args)
+	case "read":
+		return t.read(registry, args)
+	case "run":
+		return t.run(registry, args)
+	case "detect":
+		return t.detect(registry, args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s (valid: list, read, run, detect)", action))
+	}
+}
+
+// list summarises every profile, or describes one in full.
+func (t *SensorTool) list(registry *profiles.Registry, loadErrs []error, args map[string]interface{}) *ToolResult {
+	if name, _ := args["profile"].(string); name != "" {
+		p, res := getProfile(registry, name)
+		if res != nil {
+			return res
+		}
+		result, _ := json.MarshalIndent(p, "", "  ")
+		return SilentResult(string(result))
+	}
+
+	type summary struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description,omitempty"`
+		Interfaces  []string `json:"interfaces"`
+		Quantities  []string `json:"quantities,omitempty"`
+		Actions     []string `json:"actions,omitempty"`
+		Source      string   `json:"source"`
+	}
+	list := registry.List()
+	summaries := make([]summary, 0, len(list))
+	for _, p := range list {
+		s := summary{Name: p.Name, Description: p.Description, Interfaces: p.Interfaces, Source: p.Source}
+		for _, q := range p.Quantities {
+			s.Quantities = append(s.Quantities, q.Name)
+		}
+		for _, a := range p.Actions {
+			s.Actions = append(s.Actions, a.Name)
+		}
+		summaries = append(summaries, s)
+	}
+	result, _ := json.MarshalIndent(summaries, "", "  ")
+	out := fmt.Sprintf("%d device profile(s):\n%s", len(summaries), string(result))
+	for _, err := range loadErrs {
+		out += "\nWarning: " + err.Error()
+	}
+	return SilentResult(out)
+}
+
+// read reads quantities from a device.
+func (t *SensorTool) read(registry *profiles.Registry, args map[string]interface{}) *ToolResult {
+	p, res := requireProfile(registry, args)
+	if res != nil {
+		return res
+	}
+	target, res := t.parseSensorTarget(args, p)
+	if res != nil {
+		return res
+	}
+
+	var names []string
+	if list, ok := args["quantities"].([]interface{}); ok {
+		for _, v := range list {
+			if s, ok := v.(string); ok && s != "" {
+				names = append(names, s)
+			}
+		}
+	} else if s, ok := args["quantities"].(string); ok && s != "" {
+		names = strings.Split(s, ",")
+		for i := range names {
+			names[i] = strings.TrimSpace(names[i])
+		}
+	}
+	params := make(map[string]float64)
+	if m, ok := args["params"].(map[string]interface{}); ok {
+		for k, v := range m {
+			f, ok := v.(float64)
+			if !ok {
+				return ErrorResult(fmt.Sprintf("params.%s must be a number", k))
+			}
+			params[k] = f
+		}
+	}
+
+	bus, err := t.open(p, target)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to open %s: %v", target, err))
+	}
+	defer bus.Close()
+
+	readings, err := p.Read(bus, params, names)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("%s at %s: %v", p.Name, target, err))
+	}
+	result, _ := json.MarshalIndent(map[string]interface{}{
+		"profile":  p.Name,
+		"device":   target.String(),
+		"readings": readings,
+	}, "", "  ")
+	return SilentResult(string(result))
+}
+
+// run runs a profile action, such as clearing a display.
+func (t *SensorTool) run(registry *profiles.Registry, args map[string]interface{}) *ToolResult {
+	confirm, _ := args["confirm"].(bool)
+	if !confirm {
+		return ErrorResult("run writes to the device. Set confirm: true to proceed.")
+	}
+	p, res := requireProfile(registry, args)
+	if res != nil {
+		return res
+	}
+	command, _ := args["command"].(string)
+	if command == "" {
+		return ErrorResult("command is required (the profile action to run)")
+	}
+	target, res := t.parseSensorTarget(args, p)
+	if res != nil {
+		return res
+	}
+	actionArgs := make(map[string]int)
+	if m, ok := args["args"].(map[string]interface{}); ok {
+		for k, v := range m {
+			f, ok := v.(float64)
+			if !ok || f != float64(int(f)) {
+				return ErrorResult(fmt.Sprintf("args.%s must be an integer", k))
+			}
+			actionArgs[k] = int(f)
+		}
+	}
+
+	bus, err := t.open(p, target)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to open %s: %v", target, err))
+	}
+	defer bus.Close()
+
+	if err := p.Run(bus, command, actionArgs); err != nil {
+		return ErrorResult(fmt.Sprintf("%s at %s: %v", p.Name, target, err))
+	}
+	return SilentResult(fmt.Sprintf("Ran %s on %s at %s", command, p.Name, target))
+}
+
+// detect checks the ID register of each candidate profile and address.
+func (t *SensorTool) detect(registry *profiles.Registry, args map[string]interface{}) *ToolResult {
+	iface, path, res := t.parseSensorBus(args)
+	if res != nil {
+		return res
+	}
+	candidates := registry.List()
+	if name, _ := args["profile"].(string); name != "" {
+		p, res := getProfile(registry, name)
+		if res != nil {
+			return res
+		}
+		candidates = []*profiles.Profile{p}
+	}
+	var address int
+	if _, ok := args["address"]; ok && iface == "i2c" {
+		addr, res := parseI2CAddress(args)
+		if res != nil {
+			return res
+		}
+		address = addr
+	}
+
+	type match struct {
+		Profile string `json:"profile"`
+		Device  string `json:"device"`
+	}
+	matches := []match{}
+	for _, p := range candidates {
+		if p.Identify == nil || !p.Supports(iface) {
+			continue
+		}
+		var targets []sensorTarget
+		if iface == "spi" {
+			targets = append(targets, sensorTarget{Interface: iface, Path: path})
+		}
+		for _, a := range p.Addresses {
+			if iface == "i2c" && (address == 0 || a.Value == address) {
+				targets = append(targets, sensorTarget{Interface: iface, Path: path, Address: a.Value})
+			}
+		}
+		for _, target := range targets {
+			bus, err := t.open(p, target)
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("failed to open %s: %v", target, err))
+			}
+			ok, err := p.Detect(bus)
+			bus.Close()
+			if err == nil && ok {
+				matches = append(matches, match{Profile: p.Name, Device: target.String()})
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return SilentResult(fmt.Sprintf("No profiled devices identified on %s. Only profiles with an identify register can be detected; try i2c scan for the rest.", path))
+	}
+	result, _ := json.MarshalIndent(matches, "", "  ")
+	return SilentResult(fmt.Sprintf("Identified %d device(s):\n%s", len(matches), string(result)))
+}
+
+func (s sensorTarget) String() string {
+	if s.Interface == "i2c" {
+		return fmt.Sprintf("%s address 0x%02x", s.Path, s.Address)
+	}
+	return s.Path
+}
+
+func requireProfile(registry *profiles.Registry, args map[string]interface{}) (*profiles.Profile, *ToolResult) {
+	name, _ := args["profile"].(string)
+	if name == "" {
+		return nil, ErrorResult("profile is required (use action list to see the available profiles)")
+	}
+	return getProfile(registry, name)
+}
+
+func getProfile(registry *profiles.Registry, name string) (*profiles.Profile, *ToolResult) {
+	p, ok := registry.Get(name)
+	if !ok {
+		var names []string
+		for _, p := range registry.List() {
+			names = append(names, p.Name)
+		}
+		return nil, ErrorResult(fmt.Sprintf("unknown device profile %q (available: %s)", name, strings.Join(names, ", ")))
+	}
+	return p, nil
+}
+
+// parseSensorBus resolves the bus or device argument to an allowed path.
+func (t *SensorTool) parseSensorBus(args map[string]interface{}) (iface, path string, res *ToolResult) {
+	bus, _ := args["bus"].(string)
+	device, _ := args["device"].(string)
+	switch {
+	case bus != "" && device != "":
+		return "", "", ErrorResult("use either bus (I2C) or device (SPI), not both")
+	case device != "":
+		if !spiDevicePattern.MatchString(device) {
+			return "", "", ErrorResult("invalid device identifier: must be in format \"X.Y\" (e.g. \"0.0\")")
+		}
+		iface, path = "spi", "/dev/spidev"+device
+	case bus != "":
+		if !isValidBusID(bus) {
+			return "", "", ErrorResult("invalid bus identifier: must be a number (e.g. \"1\")")
+		}
+		iface, path = "i2c", "/dev/i2c-"+bus
+	default:
+		return "", "", ErrorResult("bus (I2C, e.g. \"1\") or device (SPI, e.g. \"0.0\") is required")
+	}
+	if res := t.allowed.check(path); res != nil {
+		return "", "", res
+	}
+	return iface, path, nil
+}
+
+// parseSensorTarget resolves where the profiled device is attached.
+func (t *SensorTool) parseSensorTarget(args map[string]interface{}, p *profiles.Profile) (sensorTarget, *ToolResult) {
+	iface, path, res := t.parseSensorBus(args)
+	if res != nil {
+		return sensorTarget{}, res
+	}
+	if !p.Supports(iface) {
+		return sensorTarget{}, ErrorResult(fmt.Sprintf("%s does not support %s (supports: %s)", p.Name, strings.ToUpper(iface), strings.Join(p.Interfaces, ", ")))
+	}
+	target := sensorTarget{Interface: iface, Path: path}
+	if iface != "i2c" {
+		return target, nil
+	}
+	if _, ok := args["address"]; ok {
+		addr, res := parseI2CAddress(args)
+		if res != nil {
+			return sensorTarget{}, res
+		}
+		target.Address = addr
+	} else if len(p.Addresses) > 0 {
+		target.Address = p.Addresses[0].Value
+	} else {
+		return sensorTarget{}, ErrorResult(fmt.Sprintf("address is required: %s has no default address", p.Name))
+	}
+	return target, nil
+}
+
+func openSensorBus(p *profiles.Profile, target sensorTarget) (sensorBus, error) {
+	if runtime.GOOS != "linux" {
+		return nil, profiles.ErrUnsupported
+	}
+	if target.Interface == "spi" {
+		return profiles.OpenSPI(target.Path, p.SPI)
+	}
+	return profiles.OpenI2C(target.Path, target.Address)
+}
@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/devices/profiles"
+)
+
+// fakeSensorBus serves reads from a register map and records writes.
+type fakeSensorBus struct {
+	regs   map[int][]byte
+	writes [][]byte
+	closed bool
+}
+
+func (b *fakeSensorBus) Read(reg, n int) ([]byte, error) {
+	data := make([]byte, n)
+	copy(data, b.regs[reg])
+	return data, nil
+}
+
+func (b *fakeSensorBus) Write(data []byte) error {
+	b.writes = append(b.writes, append([]byte(nil), data...))
+	return nil
+}
+
+func (b *fakeSensorBus) Close() error {
+	b.closed = true
+	return nil
+}
+
+func newTestSensorTool(t *testing.T, allowed []string, devices map[string]*fakeSensorBus) (*SensorTool, string) {
+	workspace := t.TempDir()
+	tool := NewSensorTool(workspace, allowed)
+	tool.open = func(p *profiles.Profile, target sensorTarget) (sensorBus, error) {
+		if bus, ok := devices[target.String()]; ok {
+			return bus, nil
+		}
+		return &fakeSensorBus{}, nil
+	}
+	return tool, workspace
+}
+
+func TestSensorTool_ReadCustomProfile(t *testing.T) {
+	bus := &fakeSensorBus{regs: map[int][]byte{0x00: {0x19, 0x00}}}
+	tool, workspace := newTestSensorTool(t, nil, map[string]*fakeSensorBus{
+		"/dev/i2c-1 address 0x48": bus,
+	})
+	dir := filepath.Join(workspace, "device_profiles")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "tmp102.yaml"), []byte(`name: tmp102
+addresses: [0x48]
+blocks: [{name: temp, register: 0x00, length: 2}]
+fields: [{name: raw, block: temp, type: s16be}]
+quantities: [{name: temperature, unit: "°C", formula: "(raw >> 4) * 0.0625"}]
+`), 0644)
+
+	result := tool.Execute(context.Background(), map[string]interface{}{
+		"action": "read", "profile": "tmp102", "bus": "1",
+	})
+	if result.IsError {
+		t.Fatalf("read failed: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, `"value": 25`) || !strings.Contains(result.ForLLM, "°C") {
+		t.Errorf("unexpected result: %s", result.ForLLM)
+	}
+	if !bus.closed {
+		t.Error("bus was not closed")
+	}
+
+	result = tool.Execute(context.Background(), map[string]interface{}{"action": "list"})
+	if result.IsError || !strings.Contains(result.ForLLM, "tmp102") || !strings.Contains(result.ForLLM, "bme280") {
+		t.Errorf("list = %s", result.ForLLM)
+	}
+}
+
+func TestSensorTool_Detect(t *testing.T) {
+	tool, _ := newTestSensorTool(t, nil, map[string]*fakeSensorBus{
+		"/dev/i2c-1 address 0x77": {regs: map[int][]byte{0xD0: {0x60}}},
+		"/dev/i2c-1 address 0x68": {regs: map[int][]byte{0x75: {0x68}}},
+	})
+	result := tool.Execute(context.Background(), map[string]interface{}{"action": "detect", "bus": "1"})
+	if result.IsError {
+		t.Fatalf("detect failed: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "Identified 2") || !strings.Contains(result.ForLLM, `"bme280"`) || !strings.Contains(result.ForLLM, `"mpu6050"`) {
+		t.Errorf("detect = %s", result.ForLLM)
+	}
+}
+
+func TestSensorTool_RunNeedsConfirm(t *testing.T) {
+	bus := &fakeSensorBus{}
+	tool, _ := newTestSensorTool(t, nil, map[string]*fakeSensorBus{"/dev/i2c-0 address 0x3c": bus})
+	args := map[string]interface{}{
+		"action": "run", "profile": "ssd1306", "bus": "0", "command": "contrast",
+		"args": map[string]interface{}{"value": float64(200)},
+	}
+	if result := tool.Execute(context.Background(), args); !result.IsError || !strings.Contains(result.ForLLM, "confirm") {
+		t.Fatalf("expected confirm error, got: %s", result.ForLLM)
+	}
+	args["confirm"] = true
+	if result := tool.Execute(context.Background(), args); result.IsError {
+		t.Fatalf("run failed: %s", result.ForLLM)
+	}
+	if len(bus.writes) != 1 || bus.writes[0][2] != 200 {
+		t.Errorf("writes = %x", bus.writes)
+	}
+}
+
+func TestSensorTool_Errors(t *testing.T) {
+	tool, _ := newTestSensorTool(t, []string{"/dev/i2c-1"}, nil)
+	tests := []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"action": "read", "profile": "bme280", "bus": "2"}, "not allowed"},
+		{map[string]interface{}{"action": "read", "profile": "nope", "bus": "1"}, "unknown device profile"},
+		{map[string]interface{}{"action": "read", "profile": "bme280"}, "bus (I2C"},
+		{map[string]interface{}{"action": "read", "profile": "sht31", "device": "0.0"}, "not allowed"},
+		{map[string]interface{}{"action": "read", "profile": "bme280", "bus": "1", "address": float64(0x80)}, "7-bit"},
+		{map[string]interface{}{"action": "read", "profile": "bme280", "bus": "1", "quantities": []interface{}{"lux"}}, "no quantity"},
+	}
+	for _, tt := range tests {
+		result := tool.Execute(context.Background(), tt.args)
+		if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("%v: expected %q error, got: %s", tt.args, tt.want, result.ForLLM)
+		}
+	}
+
+	tool, _ = newTestSensorTool(t, nil, nil)
+	result := tool.Execute(context.Background(), map[string]interface{}{"action": "read", "profile": "sht31", "device": "0.0"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "does not support SPI") {
+		t.Errorf("expected interface error, got: %s", result.ForLLM)
+	}
+}
@@ -0,0 +1,441 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	serialDefaultBaud      = 115200
+	serialDefaultTimeoutMs = 1000
+	serialMaxTimeoutMs     = 30000
+	serialDefaultMaxBytes  = 4096
+	serialMaxBytes         = 65536
+
+	// serialIdleGap ends a read once data has arrived and the port has
+	// been quiet this long, so a burst of output comes back together.
+	serialIdleGap = 100 * time.Millisecond
+)
+
+// serialConfig is the line setting of a port.
+type serialConfig struct {
+	Baud     int    `json:"baud"`
+	DataBits int    `json:"data_bits"`
+	Parity   string `json:"parity"` // "none", "even" or "odd"
+	StopBits int    `json:"stop_bits"`
+}
+
+// serialPort is an open port. Reads must honour the read deadline.
+type serialPort interface {
+	io.ReadWriteCloser
+	SetReadDeadline(t time.Time) error
+}
+
+// serialSession is an open port and the bytes received but not yet returned.
+type serialSession struct {
+	path    string
+	config  serialConfig
+	port    serialPort
+	pending []byte
+}
+
+// SerialTool talks to serial ports (USB-serial adapters, UART consoles,
+// microcontrollers). Ports stay open between calls so a conversation can
+// hold an interactive session with a device.
+type SerialTool struct {
+	allowed  DeviceAllowList
+	openPort func(path string, config serialConfig) (serialPort, error)
+
+	mu       sync.Mutex
+	sessions map[string]*serialSession
+}
+
+func NewSerialTool(allowed []string) *SerialTool {
+	return &SerialTool{
+		allowed:  allowed,
+		openPort: openSerialPort,
+		sessions: make(map[string]*serialSession),
+	}
+}
+
+func (t *SerialTool) Name() string {
+	return "serial"
+}
+
+func (t *SerialTool) Description() string {
+	return "Talk to serial ports (USB-serial adapters, UART consoles, microcontrollers). Actions: list (available ports), open (open a port with baud/parity settings; it stays open), write (send text or bytes), read (wait for output with a timeout, optionally until a marker or complete lines), close. Linux only."
+}
+
+func (t *SerialTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"list", "open", "write", "read", "close"},
+				"description": "Action to perform: list (list serial ports), open (open a port), write (send data), read (receive data), close (close a port)",
+			},
+			"port": map[string]interface{}{
+				"type":        "string",
+				"description": "Serial port device (e.g. \"/dev/ttyUSB0\" or \"ttyACM0\"). Required for open/write/read/close.",
+			},
+			"baud": map[string]interface{}{
+				"type":        "integer",
+				"description": "Baud rate for open. Default: 115200.",
+			},
+			"data_bits": map[string]interface{}{
+				"type":        "integer",
+				"enum":        []int{5, 6, 7, 8},
+				"description": "Data bits for open. Default: 8.",
+			},
+			"parity": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"none", "even", "odd"},
+				"description": "Parity for open. Default: none.",
+			},
+			"stop_bits": map[string]interface{}{
+				"type":        "integer",
+				"enum":        []int{1, 2},
+				"description": "Stop bits for open. Default: 1.",
+			},
+			"data": map[string]interface{}{
+				"type":        "string",
+				"description": "Text to send with write.",
+			},
+			"bytes": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "integer"},
+				"description": "Raw bytes to send with write (0-255 each), instead of data.",
+			},
+			"eol": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"", "\n", "\r", "\r\n"},
+				"description": "Line ending appended to data on write. Default: none.",
+			},
+			"timeout_ms": map[string]interface{}{
+				"type":        "integer",
+				"description": "Maximum time read waits, in milliseconds (1-30000). Default: 1000.",
+			},
+			"until": map[string]interface{}{
+				"type":        "string",
+				"description": "For read: return as soon as this text arrives (e.g. a shell prompt \"$ \" or \"OK\").",
+			},
+			"lines": map[string]interface{}{
+				"type":        "boolean",
+				"description": "For read: return only complete lines; a partial line is kept for the next read.",
+			},
+			"max_bytes": map[string]interface{}{
+				"type":        "integer",
+				"description": "For read: maximum bytes to return (1-65536). Default: 4096.",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *SerialTool) Execute(ctx context.Context, args map[string]interface{}) *ToolResult {
+	if runtime.GOOS != "linux" {
+		return ErrorResult("Serial ports are only supported on Linux by this tool.")
+	}
+
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+
+	switch action {
+	case "list":
+		return t.list()
+	case "open":
+		return t.open(args)
+	case "write":
+		return t.write(args)
+	case "read":
+		return t.read(ctx, args)
+	case "close":
+		return t.close(args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s (valid: list, open, write, read, close)", action))
+	}
+}
+
+// Close closes every open port.
+func (t *SerialTool) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for path, s := range t.sessions {
+		s.port.Close()
+		delete(t.sessions, path)
+	}
+}
+
+// parseSerialPort resolves the port argument to an allowed path under /dev.
+func (t *SerialTool) parseSerialPort(args map[string]interface{}) (string, *ToolResult) {
+	port, _ := args["port"].(string)
+	if port == "" {
+		return "", ErrorResult("port is required (e.g. \"/dev/ttyUSB0\")")
+	}
+	if !strings.HasPrefix(port, "/") {
+		port = "/dev/" + port
+	}
+	path := filepath.Clean(port)
+	if !strings.HasPrefix(path, "/dev/") {
+		return "", ErrorResult("port must be a device under /dev")
+	}
+	if res := t.allowed.check(path); res != nil {
+		return "", res
+	}
+	return path, nil
+}
+
+func parseSerialConfig(args map[string]interface{}) (serialConfig, *ToolResult) {
+	config := serialConfig{Baud: serialDefaultBaud, DataBits: 8, Parity: "none", StopBits: 1}
+	if v, ok := args["baud"].(float64); ok {
+		config.Baud = int(v)
+	}
+	if v, ok := args["data_bits"].(float64); ok {
+		config.DataBits = int(v)
+	}
+	if v, ok := args["parity"].(string); ok && v != "" {
+		config.Parity = strings.ToLower(v)
+	}
+	if v, ok := args["stop_bits"].(float64); ok {
+		config.StopBits = int(v)
+	}
+
+	if config.DataBits < 5 || config.DataBits > 8 {
+		return config, ErrorResult("data_bits must be 5, 6, 7 or 8")
+	}
+	if config.Parity != "none" && config.Parity != "even" && config.Parity != "odd" {
+		return config, ErrorResult("parity must be none, even or odd")
+	}
+	if config.StopBits != 1 && config.StopBits != 2 {
+		return config, ErrorResult("stop_bits must be 1 or 2")
+	}
+	return config, nil
+}
+
+// session returns the open session for the port argument.
+func (t *SerialTool) session(args map[string]interface{}) (*serialSession, *ToolResult) {
+	path, errResult := t.parseSerialPort(args)
+	if errResult != nil {
+		return nil, errResult
+	}
+	s, ok := t.sessions[path]
+	if !ok {
+		return nil, ErrorResult(fmt.Sprintf("%s is not open; use action open first", path))
+	}
+	return s, nil
+}
+
+// open opens a port, replacing an existing session on the same port.
+func (t *SerialTool) open(args map[string]interface{}) *ToolResult {
+	path, errResult := t.parseSerialPort(args)
+	if errResult != nil {
+		return errResult
+	}
+	config, errResult := parseSerialConfig(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if old, ok := t.sessions[path]; ok {
+		old.port.Close()
+		delete(t.sessions, path)
+	}
+	port, err := t.openPort(path, config)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to open %s: %v", path, err))
+	}
+	t.sessions[path] = &serialSession{path: path, config: config, port: port}
+
+	return SilentResult(fmt.Sprintf("Opened %s at %d baud, %d%s%d. Use read to receive output; the port stays open until close.",
+		path, config.Baud, config.DataBits, strings.ToUpper(config.Parity[:1]), config.StopBits))
+}
+
+func (t *SerialTool) write(args map[string]interface{}) *ToolResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, errResult := t.session(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	var data []byte
+	if raw, ok := args["bytes"].([]interface{}); ok && len(raw) > 0 {
+		for i, v := range raw {
+			b, ok := v.(float64)
+			if !ok || b < 0 || b > 255 || b != float64(int(b)) {
+				return ErrorResult(fmt.Sprintf("bytes[%d] must be an integer 0-255", i))
+			}
+			data = append(data, byte(b))
+		}
+	} else if text, ok := args["data"].(string); ok {
+		data = []byte(text)
+	}
+	if eol, ok := args["eol"].(string); ok {
+		data = append(data, eol...)
+	}
+	if len(data) == 0 {
+		return ErrorResult("data or bytes is required for write")
+	}
+
+	n, err := s.port.Write(data)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to write to %s after %d byte(s): %v", s.path, n, err))
+	}
+	return SilentResult(fmt.Sprintf("Wrote %d byte(s) to %s", n, s.path))
+}
+
+// read collects output until the until marker, complete lines or raw
+// data followed by an idle gap, bounded by timeout_ms and max_bytes.
+func (t *SerialTool) read(ctx context.Context, args map[string]interface{}) *ToolResult {
+	timeoutMs, errResult := parseMillis(args, "timeout_ms", serialDefaultTimeoutMs, 1, serialMaxTimeoutMs)
+	if errResult != nil {
+		return errResult
+	}
+	maxBytes := serialDefaultMaxBytes
+	if v, ok := args["max_bytes"].(float64); ok {
+		if v < 1 || v > serialMaxBytes {
+			return ErrorResult(fmt.Sprintf("max_bytes must be between 1 and %d", serialMaxBytes))
+		}
+		maxBytes = int(v)
+	}
+	until, _ := args["until"].(string)
+	lines, _ := args["lines"].(bool)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, errResult := t.session(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	stop := context.AfterFunc(ctx, func() { s.port.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	// complete reports how many pending bytes form a finished result.
+	complete := func() int {
+		switch {
+		case until != "":
+			if i := bytes.Index(s.pending, []byte(until)); i >= 0 {
+				return i + len(until)
+			}
+		case lines:
+			return bytes.LastIndexByte(s.pending, '\n') + 1
+		default:
+			return len(s.pending)
+		}
+		return 0
+	}
+
+	deadline := time.Now().Add(time.Duration(timeoutMs) * time.Millisecond)
+	buf := make([]byte, 1024)
+	var readErr error
+	for len(s.pending) < maxBytes {
+		n := complete()
+		if until != "" && n > 0 {
+			break
+		}
+		wait := time.Until(deadline)
+		if n > 0 && serialIdleGap < wait {
+			wait = serialIdleGap
+		}
+		if wait <= 0 {
+			break
+		}
+		s.port.SetReadDeadline(time.Now().Add(wait))
+		k, err := s.port.Read(buf)
+		s.pending = append(s.pending, buf[:k]...)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if ctx.Err() != nil {
+				return ErrorResult("read cancelled")
+			}
+			if k == 0 && n > 0 {
+				break // idle after data
+			}
+			continue
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+
+	// Without a finished result, return what arrived when until never
+	// matched or a line overflows max_bytes; a partial line waits.
+	n := complete()
+	if n == 0 && (until != "" || len(s.pending) >= maxBytes) {
+		n = len(s.pending)
+	}
+	if n > maxBytes {
+		n = maxBytes
+	}
+	out := s.pending[:n]
+	s.pending = append([]byte(nil), s.pending[n:]...)
+
+	result := map[string]interface{}{
+		"port":  s.path,
+		"bytes": len(out),
+	}
+	if utf8.Valid(out) {
+		result["data"] = string(out)
+	} else {
+		result["hex"] = hex.EncodeToString(out)
+	}
+	if lines {
+		var ls []string
+		for _, l := range strings.SplitAfter(string(out), "\n") {
+			if l != "" {
+				ls = append(ls, strings.TrimRight(l, "\r\n"))
+			}
+		}
+		result["lines"] = ls
+	}
+	if len(s.pending) > 0 {
+		result["pending_bytes"] = len(s.pending)
+	}
+	if until != "" && !bytes.Contains(out, []byte(until)) {
+		result["until_found"] = false
+	}
+	if len(out) == 0 && readErr == nil {
+		result["timed_out"] = true
+	}
+	if readErr != nil {
+		s.port.Close()
+		delete(t.sessions, s.path)
+		result["error"] = fmt.Sprintf("port closed: %v", readErr)
+	}
+
+	encoded, _ := json.MarshalIndent(result, "", "  ")
+	return SilentResult(string(encoded))
+}
+
+func (t *SerialTool) close(args map[string]interface{}) *ToolResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, errResult := t.session(args)
+	if errResult != nil {
+		return errResult
+	}
+	err := s.port.Close()
+	delete(t.sessions, s.path)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("closed %s with error: %v", s.path, err))
+	}
+	return SilentResult(fmt.Sprintf("Closed %s", s.path))
+}
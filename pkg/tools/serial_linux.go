@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// termios c_cflag bits missing from package syscall (asm-generic values).
+const (
+	termiosCBAUD   = 0x100f
+	termiosCRTSCTS = 0x80000000
+)
+
+var serialBaudRates = map[int]uint32{
+	50: syscall.B50, 75: syscall.B75, 110: syscall.B110, 134: syscall.B134,
+	150: syscall.B150, 200: syscall.B200, 300: syscall.B300, 600: syscall.B600,
+	1200: syscall.B1200, 1800: syscall.B1800, 2400: syscall.B2400, 4800: syscall.B4800,
+	9600: syscall.B9600, 19200: syscall.B19200, 38400: syscall.B38400, 57600: syscall.B57600,
+	115200: syscall.B115200, 230400: syscall.B230400, 460800: syscall.B460800,
+	500000: syscall.B500000, 576000: syscall.B576000, 921600: syscall.B921600,
+	1000000: syscall.B1000000, 1152000: syscall.B1152000, 1500000: syscall.B1500000,
+	2000000: syscall.B2000000, 2500000: syscall.B2500000, 3000000: syscall.B3000000,
+	3500000: syscall.B3500000, 4000000: syscall.B4000000,
+}
+
+// setRawTermios puts tio into raw mode with the given line settings. The
+// speed is carried in the CBAUD bits, which TCSETS reads.
+func setRawTermios(tio *syscall.Termios, config serialConfig) error {
+	speed, ok := serialBaudRates[config.Baud]
+	if !ok {
+		return fmt.Errorf("unsupported baud rate %d", config.Baud)
+	}
+	sizes := map[int]uint32{5: syscall.CS5, 6: syscall.CS6, 7: syscall.CS7, 8: syscall.CS8}
+
+	tio.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON | syscall.IXOFF | syscall.IXANY
+	tio.Oflag &^= syscall.OPOST
+	tio.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	tio.Cflag &^= termiosCBAUD | syscall.CSIZE | syscall.PARENB | syscall.PARODD | syscall.CSTOPB | termiosCRTSCTS
+	tio.Cflag |= speed | sizes[config.DataBits] | syscall.CREAD | syscall.CLOCAL
+	switch config.Parity {
+	case "even":
+		tio.Cflag |= syscall.PARENB
+	case "odd":
+		tio.Cflag |= syscall.PARENB | syscall.PARODD
+	}
+	if config.StopBits == 2 {
+		tio.Cflag |= syscall.CSTOPB
+	}
+	tio.Cc[syscall.VMIN] = 1
+	tio.Cc[syscall.VTIME] = 0
+	return nil
+}
+
+// openSerialPort opens path in raw mode. The descriptor is non-blocking
+// so read deadlines apply.
+func openSerialPort(path string, config serialConfig) (serialPort, error) {
+	fd, err := syscall.Open(path, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	var tio syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(&tio))); errno != 0 {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("not a serial port: %v", errno)
+	}
+	if err := setRawTermios(&tio, config); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(&tio))); errno != 0 {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to configure port: %v", errno)
+	}
+	return os.NewFile(uintptr(fd), path), nil
+}
+
+type serialPortInfo struct {
+	Path   string        `json:"path"`
+	ByID   string        `json:"by_id,omitempty"`
+	Driver string        `json:"driver,omitempty"`
+	Open   *serialConfig `json:"open,omitempty"`
+}
+
+// list lists allowed serial ports, skipping unused legacy ttyS ports.
+func (t *SerialTool) list() *ToolResult {
+	var paths []string
+	for _, pattern := range []string{"/dev/ttyUSB*", "/dev/ttyACM*", "/dev/ttyAMA*", "/dev/ttyS*", "/dev/ttyGS*"} {
+		matches, _ := filepath.Glob(pattern)
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	byID := make(map[string]string)
+	links, _ := filepath.Glob("/dev/serial/by-id/*")
+	for _, link := range links {
+		if target, err := filepath.EvalSymlinks(link); err == nil {
+			byID[target] = link
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var ports []serialPortInfo
+	for _, path := range paths {
+		name := filepath.Base(path)
+		sysDir := filepath.Join("/sys/class/tty", name)
+		if strings.HasPrefix(name, "ttyS") {
+			// 8250 reserves ports that have no hardware behind them; they report type 0.
+			if data, err := os.ReadFile(filepath.Join(sysDir, "type")); err == nil && strings.TrimSpace(string(data)) == "0" {
+				continue
+			}
+		}
+		if !t.allowed.Allows(path) {
+			continue
+		}
+		info := serialPortInfo{Path: path, ByID: byID[path]}
+		if driver, err := filepath.EvalSymlinks(filepath.Join(sysDir, "device", "driver")); err == nil {
+			info.Driver = filepath.Base(driver)
+		}
+		if s, ok := t.sessions[path]; ok {
+			config := s.config
+			info.Open = &config
+		}
+		ports = append(ports, info)
+	}
+
+	if len(ports) == 0 {
+		return SilentResult("No serial ports found. Plug in a USB-serial adapter or enable the UART in the device tree, and check this agent's hardware_devices.")
+	}
+	result, _ := json.MarshalIndent(ports, "", "  ")
+	return SilentResult(fmt.Sprintf("Found %d serial port(s):\n%s", len(ports), string(result)))
+}
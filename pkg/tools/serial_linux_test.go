@@ -0,0 +1,28 @@
+package tools
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestSetRawTermios(t *testing.T) {
+	tio := syscall.Termios{
+		Iflag: syscall.ICRNL | syscall.IXON,
+		Lflag: syscall.ICANON | syscall.ECHO,
+		Cflag: syscall.B38400 | syscall.CS7 | termiosCRTSCTS,
+	}
+	if err := setRawTermios(&tio, serialConfig{Baud: 9600, DataBits: 8, Parity: "odd", StopBits: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if tio.Iflag != 0 || tio.Lflag != 0 {
+		t.Errorf("not raw: iflag=%#x lflag=%#x", tio.Iflag, tio.Lflag)
+	}
+	want := uint32(syscall.B9600 | syscall.CS8 | syscall.PARENB | syscall.PARODD | syscall.CSTOPB | syscall.CREAD | syscall.CLOCAL)
+	if tio.Cflag != want {
+		t.Errorf("cflag = %#x, want %#x", tio.Cflag, want)
+	}
+
+	if err := setRawTermios(&tio, serialConfig{Baud: 12345, DataBits: 8, Parity: "none", StopBits: 1}); err == nil {
+		t.Error("expected an error for an unsupported baud rate")
+	}
+}
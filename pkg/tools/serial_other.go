@@ -0,0 +1,15 @@
+//go:build !linux
+
+package tools
+
+import "errors"
+
+// openSerialPort is a stub for non-Linux platforms.
+func openSerialPort(path string, config serialConfig) (serialPort, error) {
+	return nil, errors.New("serial ports are only supported on Linux")
+}
+
+// list is a stub for non-Linux platforms.
+func (t *SerialTool) list() *ToolResult {
+	return ErrorResult("Serial ports are only supported on Linux")
+}
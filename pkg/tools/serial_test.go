@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPipeSerialTool returns a serial tool whose ports are in-memory pipes;
+// device is the far end, standing in for the attached hardware.
+func newPipeSerialTool(t *testing.T) (*SerialTool, net.Conn) {
+	t.Helper()
+	host, device := net.Pipe()
+	t.Cleanup(func() { host.Close(); device.Close() })
+	tool := NewSerialTool([]string{"/dev/ttyUSB*"})
+	tool.openPort = func(path string, config serialConfig) (serialPort, error) {
+		if config.Baud != 9600 || config.Parity != "even" {
+			t.Errorf("open config = %+v", config)
+		}
+		return host, nil
+	}
+	result := tool.Execute(context.Background(), map[string]interface{}{
+		"action": "open", "port": "ttyUSB0", "baud": float64(9600), "parity": "even",
+	})
+	if result.IsError {
+		t.Fatalf("open failed: %s", result.ForLLM)
+	}
+	return tool, device
+}
+
+func serialRead(t *testing.T, tool *SerialTool, args map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	args["action"] = "read"
+	args["port"] = "/dev/ttyUSB0"
+	result := tool.Execute(context.Background(), args)
+	if result.IsError {
+		t.Fatalf("read failed: %s", result.ForLLM)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal([]byte(result.ForLLM), &out); err != nil {
+		t.Fatalf("read result %q: %v", result.ForLLM, err)
+	}
+	return out
+}
+
+func TestSerialTool_Session(t *testing.T) {
+	tool, device := newPipeSerialTool(t)
+
+	// The device answers a command with two lines and a partial prompt.
+	go func() {
+		buf := make([]byte, 64)
+		n, _ := device.Read(buf)
+		if got := string(buf[:n]); got != "AT+GMR\r\n" {
+			t.Errorf("device received %q", got)
+		}
+		device.Write([]byte("v1.2\r\nOK\r\n> "))
+	}()
+	result := tool.Execute(context.Background(), map[string]interface{}{
+		"action": "write", "port": "/dev/ttyUSB0", "data": "AT+GMR", "eol": "\r\n",
+	})
+	if result.IsError {
+		t.Fatalf("write failed: %s", result.ForLLM)
+	}
+
+	out := serialRead(t, tool, map[string]interface{}{"lines": true, "timeout_ms": float64(2000)})
+	lines, _ := out["lines"].([]interface{})
+	if len(lines) != 2 || lines[0] != "v1.2" || lines[1] != "OK" {
+		t.Errorf("lines = %v", out["lines"])
+	}
+	if out["pending_bytes"] != float64(2) {
+		t.Errorf("partial prompt not kept: %v", out)
+	}
+
+	// The kept prompt satisfies the next read without waiting.
+	out = serialRead(t, tool, map[string]interface{}{"until": "> ", "timeout_ms": float64(50)})
+	if out["data"] != "> " {
+		t.Errorf("until read = %v", out)
+	}
+
+	out = serialRead(t, tool, map[string]interface{}{"timeout_ms": float64(20)})
+	if out["timed_out"] != true || out["bytes"] != float64(0) {
+		t.Errorf("idle read = %v", out)
+	}
+
+	result = tool.Execute(context.Background(), map[string]interface{}{"action": "close", "port": "ttyUSB0"})
+	if result.IsError {
+		t.Fatalf("close failed: %s", result.ForLLM)
+	}
+	result = tool.Execute(context.Background(), map[string]interface{}{"action": "read", "port": "ttyUSB0"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not open") {
+		t.Errorf("read after close = %s", result.ForLLM)
+	}
+}
+
+func TestSerialTool_RawReadAndBinary(t *testing.T) {
+	tool, device := newPipeSerialTool(t)
+	go func() {
+		device.Write([]byte{0x01, 0xff})
+		time.Sleep(20 * time.Millisecond)
+		device.Write([]byte{0x7e})
+	}()
+	// Data split across writes comes back together after the idle gap.
+	out := serialRead(t, tool, map[string]interface{}{"timeout_ms": float64(2000)})
+	if out["hex"] != "01ff7e" || out["bytes"] != float64(3) {
+		t.Errorf("raw read = %v", out)
+	}
+
+	// The device going away ends the session.
+	device.Close()
+	out = serialRead(t, tool, map[string]interface{}{"timeout_ms": float64(100)})
+	if _, ok := out["error"]; !ok {
+		t.Errorf("expected an error after disconnect: %v", out)
+	}
+	if _, ok := tool.sessions["/dev/ttyUSB0"]; ok {
+		t.Error("session kept after disconnect")
+	}
+}
+
+func TestSerialTool_Validation(t *testing.T) {
+	tool := NewSerialTool([]string{"/dev/ttyUSB*"})
+	tests := []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"action": "open", "port": "/dev/ttyS0"}, "not allowed"},
+		{map[string]interface{}{"action": "open", "port": "../etc/passwd"}, "under /dev"},
+		{map[string]interface{}{"action": "open", "port": "ttyUSB0", "parity": "mark"}, "parity"},
+		{map[string]interface{}{"action": "write", "port": "ttyUSB0", "data": "x"}, "not open"},
+	}
+	for _, tt := range tests {
+		result := tool.Execute(context.Background(), tt.args)
+		if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("%v: got %s, want error containing %q", tt.args, result.ForLLM, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 type SpawnTool struct {
@@ -31,7 +32,7 @@ func (t *SpawnTool) Name() string {
 }
 
 func (t *SpawnTool) Description() string {
-	return "Spawn a subagent to handle a task in the background. Use this for complex or time-consuming tasks that can run independently. The subagent will complete the task and report back when done."
+	return "Spawn a subagent to handle a task in the background. Use this for complex or time-consuming tasks that can run independently. The subagent will complete the task and report back when done; check on it with subagent_status."
 }
 
 func (t *SpawnTool) Parameters() map[string]interface{} {
@@ -50,6 +51,14 @@ func (t *SpawnTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "Optional target agent ID to delegate the task to",
 			},
+			"timeout_seconds": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional time limit for the task, up to the configured limit",
+			},
+			"max_iterations": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional limit on tool iterations for the task, up to the configured limit",
+			},
 		},
 		"required": []string{"task"},
 	}
@@ -60,6 +69,13 @@ func (t *SpawnTool) SetContext(channel, chatID string) {
 	t.originChatID = chatID
 }
 
+// Clone returns a copy using the same manager and allowlist.
+func (t *SpawnTool) Clone() Tool {
+	clone := NewSpawnTool(t.manager)
+	clone.allowlistCheck = t.allowlistCheck
+	return clone
+}
+
 func (t *SpawnTool) SetAllowlistChecker(check func(targetAgentID string) bool) {
 	t.allowlistCheck = check
 }
@@ -84,8 +100,16 @@ func (t *SpawnTool) Execute(ctx context.Context, args map[string]interface{}) *T
 		return ErrorResult("Subagent manager not configured")
 	}
 
+	var limits SubagentLimits
+	if timeout, ok := args["timeout_seconds"].(float64); ok && timeout > 0 {
+		limits.Timeout = time.Duration(timeout * float64(time.Second))
+	}
+	if maxIter, ok := args["max_iterations"].(float64); ok && maxIter > 0 {
+		limits.MaxIterations = int(maxIter)
+	}
+
 	// Pass callback to manager for async completion notification
-	result, err := t.manager.Spawn(ctx, task, label, agentID, t.originChannel, t.originChatID, t.callback)
+	result, err := t.manager.Spawn(ctx, task, label, agentID, t.originChannel, t.originChatID, limits, t.callback)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("failed to spawn subagent: %v", err))
 	}
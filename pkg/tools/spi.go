@@ -10,10 +10,12 @@ import (
 )
 
 // SPITool provides SPI bus interaction for high-speed peripheral communication.
-type SPITool struct{}
+type SPITool struct {
+	allowed DeviceAllowList
+}
 
-func NewSPITool() *SPITool {
-	return &SPITool{}
+func NewSPITool(allowed []string) *SPITool {
+	return &SPITool{allowed: allowed}
 }
 
 func (t *SPITool) Name() string {
@@ -108,7 +110,7 @@ func (t *SPITool) list() *ToolResult {
 	devices := make([]devInfo, 0, len(matches))
 	re := regexp.MustCompile(`/dev/spidev(\d+\.\d+)`)
 	for _, m := range matches {
-		if sub := re.FindStringSubmatch(m); sub != nil {
+		if sub := re.FindStringSubmatch(m); sub != nil && t.allowed.Allows(m) {
 			devices = append(devices, devInfo{Path: m, Device: sub[1]})
 		}
 	}
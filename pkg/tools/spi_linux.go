@@ -99,6 +99,9 @@ func (t *SPITool) transfer(args map[string]interface{}) *ToolResult {
 	}
 
 	devPath := fmt.Sprintf("/dev/spidev%s", dev)
+	if res := t.allowed.check(devPath); res != nil {
+		return res
+	}
 	fd, errResult := configureSPI(devPath, mode, bits, speed)
 	if errResult != nil {
 		return errResult
@@ -155,6 +158,9 @@ func (t *SPITool) readDevice(args map[string]interface{}) *ToolResult {
 	}
 
 	devPath := fmt.Sprintf("/dev/spidev%s", dev)
+	if res := t.allowed.check(devPath); res != nil {
+		return res
+	}
 	fd, errResult := configureSPI(devPath, mode, bits, speed)
 	if errResult != nil {
 		return errResult
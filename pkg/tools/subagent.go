@@ -2,28 +2,87 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
+// Subagent task statuses.
+const (
+	SubagentRunning     = "running"
+	SubagentCompleted   = "completed"
+	SubagentFailed      = "failed"
+	SubagentCancelled   = "cancelled"
+	SubagentTimedOut    = "timed_out"
+	SubagentInterrupted = "interrupted" // the process stopped while the task ran
+)
+
 type SubagentTask struct {
-	ID            string
-	Task          string
-	Label         string
-	AgentID       string
-	OriginChannel string
-	OriginChatID  string
-	Status        string
-	Result        string
-	Created       int64
+	ID            string              `json:"id"`
+	Task          string              `json:"task"`
+	Label         string              `json:"label,omitempty"`
+	AgentID       string              `json:"agent_id,omitempty"`        // agent the task runs as, empty for the spawning agent
+	ParentAgentID string              `json:"parent_agent_id,omitempty"` // agent that spawned the task
+	OriginChannel string              `json:"origin_channel"`
+	OriginChatID  string              `json:"origin_chat_id"`
+	Status        string              `json:"status"`
+	Result        string              `json:"result,omitempty"`
+	Created       int64               `json:"created"`                   // unix ms
+	Finished      int64               `json:"finished,omitempty"`        // unix ms
+	Timeout       int                 `json:"timeout_seconds,omitempty"` // 0 for none
+	MaxIterations int                 `json:"max_iterations,omitempty"`  // 0 for the agent's limit
+	Iterations    int                 `json:"iterations,omitempty"`
+	Usage         providers.UsageInfo `json:"usage"`
+	Transcript    []providers.Message `json:"transcript,omitempty"`
+}
+
+// Default limits for subagent tasks.
+const (
+	DefaultMaxConcurrentSubagents = 4
+	DefaultSubagentTimeout        = 10 * time.Minute
+)
+
+// SubagentLimits bound the tasks an agent spawns. A zero MaxConcurrent or
+// Timeout uses the default; a zero MaxIterations keeps the limit of the
+// agent running the task.
+type SubagentLimits struct {
+	MaxConcurrent int
+	Timeout       time.Duration
+	MaxIterations int
+}
+
+// within returns l lowered to the non-zero limits of task.
+func (l SubagentLimits) within(task SubagentLimits) SubagentLimits {
+	if l.MaxConcurrent <= 0 {
+		l.MaxConcurrent = DefaultMaxConcurrentSubagents
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultSubagentTimeout
+	}
+	if task.Timeout > 0 && task.Timeout < l.Timeout {
+		l.Timeout = task.Timeout
+	}
+	if task.MaxIterations > 0 && (l.MaxIterations == 0 || task.MaxIterations < l.MaxIterations) {
+		l.MaxIterations = task.MaxIterations
+	}
+	return l
 }
 
+// SubagentRunner runs a subagent task as the agent task.AgentID, or as the
+// spawning agent when that is empty, and returns its final answer. The
+// result may be returned along with an error to keep a partial transcript.
+// progress receives the partial result after each iteration.
+type SubagentRunner func(ctx context.Context, task *SubagentTask, progress func(*ToolLoopResult)) (*ToolLoopResult, error)
+
 type SubagentManager struct {
 	tasks          map[string]*SubagentTask
+	cancels        map[string]context.CancelFunc
 	mu             sync.RWMutex
 	provider       providers.LLMProvider
 	defaultModel   string
@@ -36,11 +95,16 @@ type SubagentManager struct {
 	hasMaxTokens   bool
 	hasTemperature bool
 	nextID         int
+	runner         SubagentRunner
+	agentID        string
+	store          *SubagentStore
+	limits         SubagentLimits
 }
 
 func NewSubagentManager(provider providers.LLMProvider, defaultModel, workspace string, bus *bus.MessageBus) *SubagentManager {
 	return &SubagentManager{
 		tasks:         make(map[string]*SubagentTask),
+		cancels:       make(map[string]context.CancelFunc),
 		provider:      provider,
 		defaultModel:  defaultModel,
 		bus:           bus,
@@ -69,45 +133,176 @@ func (sm *SubagentManager) SetTools(tools *ToolRegistry) {
 	sm.tools = tools
 }
 
-// RegisterTool registers a tool for subagent execution.
-func (sm *SubagentManager) RegisterTool(tool Tool) {
+// SetRunner sets the runner for subagent tasks. Without one, tasks run with
+// the manager's provider, model and tools and a generic system prompt.
+func (sm *SubagentManager) SetRunner(runner SubagentRunner) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	sm.tools.Register(tool)
+	sm.runner = runner
+}
+
+// SetAgentID sets the agent spawning the tasks. Completed tasks are
+// announced to it.
+func (sm *SubagentManager) SetAgentID(agentID string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.agentID = agentID
 }
 
-func (sm *SubagentManager) Spawn(ctx context.Context, task, label, agentID, originChannel, originChatID string, callback AsyncCallback) (string, error) {
+// SetStore persists tasks in store. Without a store, tasks are only kept in
+// memory.
+func (sm *SubagentManager) SetStore(store *SubagentStore) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	sm.store = store
+}
+
+// SetLimits sets the concurrency limit, timeout and iteration budget of
+// spawned tasks.
+func (sm *SubagentManager) SetLimits(limits SubagentLimits) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.limits = limits
+}
+
+// RegisterTool registers a tool for subagent execution.
+func (sm *SubagentManager) RegisterTool(tool Tool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.tools.Register(tool)
+}
 
-	taskID := fmt.Sprintf("subagent-%d", sm.nextID)
+// newTaskID returns the next task ID. sm.mu must be held.
+func (sm *SubagentManager) newTaskID() string {
+	if sm.store != nil {
+		return sm.store.NextID()
+	}
+	id := fmt.Sprintf("subagent-%d", sm.nextID)
 	sm.nextID++
+	return id
+}
+
+// Spawn starts task in the background. limits can lower the manager's
+// timeout and iteration budget for this task.
+func (sm *SubagentManager) Spawn(ctx context.Context, task, label, agentID, originChannel, originChatID string, limits SubagentLimits, callback AsyncCallback) (string, error) {
+	sm.mu.Lock()
+	limits = sm.limits.within(limits)
+	if err := sm.checkConcurrencyUnsafe(limits); err != nil {
+		sm.mu.Unlock()
+		return "", err
+	}
 
+	taskID := sm.newTaskID()
 	subagentTask := &SubagentTask{
 		ID:            taskID,
 		Task:          task,
 		Label:         label,
 		AgentID:       agentID,
+		ParentAgentID: sm.agentID,
 		OriginChannel: originChannel,
 		OriginChatID:  originChatID,
-		Status:        "running",
+		Status:        SubagentRunning,
 		Created:       time.Now().UnixMilli(),
+		Timeout:       int(limits.Timeout / time.Second),
+		MaxIterations: limits.MaxIterations,
 	}
 	sm.tasks[taskID] = subagentTask
+	taskCtx, cancel := context.WithTimeout(ctx, limits.Timeout)
+	sm.cancels[taskID] = cancel
+	sm.mu.Unlock()
+	sm.persist(subagentTask)
 
 	// Start task in background with context cancellation support
-	go sm.runTask(ctx, subagentTask, callback)
+	go sm.runTask(taskCtx, subagentTask, callback)
 
 	if label != "" {
-		return fmt.Sprintf("Spawned subagent '%s' for task: %s", label, task), nil
+		return fmt.Sprintf("Spawned subagent '%s' (%s) for task: %s", label, taskID, task), nil
 	}
-	return fmt.Sprintf("Spawned subagent for task: %s", task), nil
+	return fmt.Sprintf("Spawned subagent %s for task: %s", taskID, task), nil
 }
 
-func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask, callback AsyncCallback) {
-	task.Status = "running"
-	task.Created = time.Now().UnixMilli()
+// checkConcurrencyUnsafe returns an error if limits.MaxConcurrent tasks are
+// already running. The caller must hold sm.mu.
+func (sm *SubagentManager) checkConcurrencyUnsafe(limits SubagentLimits) error {
+	running := 0
+	for _, t := range sm.tasks {
+		if t.Status == SubagentRunning {
+			running++
+		}
+	}
+	if running >= limits.MaxConcurrent {
+		return fmt.Errorf("%d subagents are already running, the limit for this agent; wait for one to finish or cancel one", running)
+	}
+	return nil
+}
+
+// runSync runs a task to completion on the caller's goroutine. While it runs
+// the task counts against MaxConcurrent and can be cancelled like a spawned
+// one; it is not kept once it returns.
+func (sm *SubagentManager) runSync(ctx context.Context, task, label, originChannel, originChatID string, messages []providers.Message) (*ToolLoopResult, error) {
+	sm.mu.Lock()
+	limits := sm.limits.within(SubagentLimits{})
+	if err := sm.checkConcurrencyUnsafe(limits); err != nil {
+		sm.mu.Unlock()
+		return nil, err
+	}
+	subagentTask := &SubagentTask{
+		ID:            sm.newTaskID(),
+		Task:          task,
+		Label:         label,
+		ParentAgentID: sm.agentID,
+		OriginChannel: originChannel,
+		OriginChatID:  originChatID,
+		Status:        SubagentRunning,
+		Created:       time.Now().UnixMilli(),
+		Timeout:       int(limits.Timeout / time.Second),
+		MaxIterations: limits.MaxIterations,
+	}
+	runCtx, cancel := context.WithTimeout(ctx, limits.Timeout)
+	sm.tasks[subagentTask.ID] = subagentTask
+	sm.cancels[subagentTask.ID] = cancel
+	store := sm.store
+	sm.mu.Unlock()
+
+	defer func() {
+		cancel()
+		sm.mu.Lock()
+		delete(sm.tasks, subagentTask.ID)
+		delete(sm.cancels, subagentTask.ID)
+		sm.mu.Unlock()
+		if store != nil {
+			// Synchronous tasks are not kept; free the reserved ID.
+			store.Delete(subagentTask.ID)
+		}
+	}()
 
+	if runner := sm.getRunner(); runner != nil {
+		return runner(runCtx, subagentTask, func(*ToolLoopResult) {})
+	}
+	config := sm.toolLoopConfig()
+	if limits.MaxIterations > 0 {
+		config.MaxIterations = limits.MaxIterations
+	}
+	return RunToolLoop(runCtx, config, messages, originChannel, originChatID)
+}
+
+// Cancel stops a running task spawned through this manager.
+func (sm *SubagentManager) Cancel(taskID string) error {
+	taskID = NormalizeTaskID(taskID)
+	sm.mu.RLock()
+	cancel, ok := sm.cancels[taskID]
+	sm.mu.RUnlock()
+	if !ok {
+		if _, found := sm.GetTask(taskID); found {
+			return fmt.Errorf("task %s is not running", taskID)
+		}
+		return fmt.Errorf("task %s not found", taskID)
+	}
+	cancel()
+	return nil
+}
+
+func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask, callback AsyncCallback) {
 	// Build system prompt for subagent
 	systemPrompt := `You are a subagent. Complete the given task independently and report the result.
 You have access to tools - use them as needed to complete your task.
@@ -124,62 +319,39 @@ After completing the task, provide a clear summary of what was done.`
 		},
 	}
 
-	// Check if context is already cancelled before starting
-	select {
-	case <-ctx.Done():
-		sm.mu.Lock()
-		task.Status = "cancelled"
-		task.Result = "Task cancelled before execution"
-		sm.mu.Unlock()
-		return
-	default:
-	}
-
-	// Run tool loop with access to tools
-	sm.mu.RLock()
-	tools := sm.tools
-	maxIter := sm.maxIterations
-	maxTokens := sm.maxTokens
-	temperature := sm.temperature
-	hasMaxTokens := sm.hasMaxTokens
-	hasTemperature := sm.hasTemperature
-	sm.mu.RUnlock()
-
-	var llmOptions map[string]any
-	if hasMaxTokens || hasTemperature {
-		llmOptions = map[string]any{}
-		if hasMaxTokens {
-			llmOptions["max_tokens"] = maxTokens
-		}
-		if hasTemperature {
-			llmOptions["temperature"] = temperature
+	// Run tool loop with access to tools, unless cancelled before starting
+	var loopResult *ToolLoopResult
+	var err error
+	if err = ctx.Err(); err == nil {
+		if runner := sm.getRunner(); runner != nil {
+			loopResult, err = runner(ctx, task, sm.progress(task))
+		} else {
+			config := sm.toolLoopConfig()
+			if task.MaxIterations > 0 {
+				config.MaxIterations = task.MaxIterations
+			}
+			config.OnIteration = sm.progress(task)
+			loopResult, err = RunToolLoop(ctx, config, messages, task.OriginChannel, task.OriginChatID)
 		}
 	}
 
-	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
-		Provider:      sm.provider,
-		Model:         sm.defaultModel,
-		Tools:         tools,
-		MaxIterations: maxIter,
-		LLMOptions:    llmOptions,
-	}, messages, task.OriginChannel, task.OriginChatID)
-
 	sm.mu.Lock()
+	task.Finished = time.Now().UnixMilli()
+	if loopResult != nil {
+		task.Iterations = loopResult.Iterations
+		task.Usage = loopResult.Usage
+		task.Transcript = loopResult.Transcript
+	}
 	var result *ToolResult
-	defer func() {
-		sm.mu.Unlock()
-		// Call callback if provided and result is set
-		if callback != nil && result != nil {
-			callback(ctx, result)
-		}
-	}()
-
 	if err != nil {
-		task.Status = "failed"
+		task.Status = SubagentFailed
 		task.Result = fmt.Sprintf("Error: %v", err)
-		// Check if it was cancelled
-		if ctx.Err() != nil {
-			task.Status = "cancelled"
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			task.Status = SubagentTimedOut
+			task.Result = fmt.Sprintf("Task timed out after %s", time.Duration(task.Timeout)*time.Second)
+		case ctx.Err() != nil:
+			task.Status = SubagentCancelled
 			task.Result = "Task cancelled during execution"
 		}
 		result = &ToolResult{
@@ -191,7 +363,7 @@ After completing the task, provide a clear summary of what was done.`
 			Err:     err,
 		}
 	} else {
-		task.Status = "completed"
+		task.Status = SubagentCompleted
 		task.Result = loopResult.Content
 		result = &ToolResult{
 			ForLLM:  fmt.Sprintf("Subagent '%s' completed (iterations: %d): %s", task.Label, loopResult.Iterations, loopResult.Content),
@@ -201,35 +373,154 @@ After completing the task, provide a clear summary of what was done.`
 			Async:   false,
 		}
 	}
+	if cancel, ok := sm.cancels[task.ID]; ok {
+		cancel()
+		delete(sm.cancels, task.ID)
+	}
+	sm.mu.Unlock()
+
+	sm.persist(task)
+	if sm.store != nil {
+		// Finished tasks are read back from the store.
+		sm.mu.Lock()
+		delete(sm.tasks, task.ID)
+		sm.mu.Unlock()
+	}
+
+	// Call callback if provided
+	if callback != nil {
+		callback(ctx, result)
+	}
 
-	// Send announce message back to main agent
+	// Send announce message back to the spawning agent
 	if sm.bus != nil {
-		announceContent := fmt.Sprintf("Task '%s' completed.\n\nResult:\n%s", task.Label, task.Result)
-		sm.bus.PublishInbound(bus.InboundMessage{
+		name := task.Label
+		if name == "" {
+			name = task.ID
+		}
+		outcome := "completed"
+		if task.Status != SubagentCompleted {
+			outcome = task.Status
+		}
+		announceContent := fmt.Sprintf("Task '%s' %s.\n\nResult:\n%s", name, outcome, task.Result)
+		msg := bus.InboundMessage{
 			Channel:  "system",
 			SenderID: fmt.Sprintf("subagent:%s", task.ID),
 			// Format: "original_channel:original_chat_id" for routing back
 			ChatID:  fmt.Sprintf("%s:%s", task.OriginChannel, task.OriginChatID),
 			Content: announceContent,
-		})
+		}
+		if task.ParentAgentID != "" {
+			msg.Metadata = map[string]string{"agent_id": task.ParentAgentID}
+		}
+		sm.bus.PublishInbound(msg)
 	}
 }
 
-func (sm *SubagentManager) GetTask(taskID string) (*SubagentTask, bool) {
+// progress returns a function that records the partial result of a running
+// task, so /task shows how far it has got.
+func (sm *SubagentManager) progress(task *SubagentTask) func(*ToolLoopResult) {
+	return func(r *ToolLoopResult) {
+		sm.mu.Lock()
+		task.Iterations = r.Iterations
+		task.Usage = r.Usage
+		task.Transcript = r.Transcript
+		sm.mu.Unlock()
+		sm.persist(task)
+	}
+}
+
+// persist saves a snapshot of task to the store, if there is one.
+func (sm *SubagentManager) persist(task *SubagentTask) {
+	sm.mu.RLock()
+	store := sm.store
+	snapshot := *task
+	sm.mu.RUnlock()
+	if store == nil {
+		return
+	}
+	if err := store.Save(&snapshot); err != nil {
+		logger.WarnCF("subagent", "Failed to save task record",
+			map[string]interface{}{"task_id": task.ID, "error": err.Error()})
+	}
+}
+
+func (sm *SubagentManager) getRunner() SubagentRunner {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
-	task, ok := sm.tasks[taskID]
-	return task, ok
+	return sm.runner
 }
 
-func (sm *SubagentManager) ListTasks() []*SubagentTask {
+// toolLoopConfig returns the tool loop configuration for tasks run without
+// a runner.
+func (sm *SubagentManager) toolLoopConfig() ToolLoopConfig {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
+	var llmOptions map[string]any
+	if sm.hasMaxTokens || sm.hasTemperature {
+		llmOptions = map[string]any{}
+		if sm.hasMaxTokens {
+			llmOptions["max_tokens"] = sm.maxTokens
+		}
+		if sm.hasTemperature {
+			llmOptions["temperature"] = sm.temperature
+		}
+	}
+	return ToolLoopConfig{
+		Provider:      sm.provider,
+		Model:         sm.defaultModel,
+		Tools:         sm.tools,
+		MaxIterations: sm.maxIterations,
+		LLMOptions:    llmOptions,
+	}
+}
+
+// GetTask returns a snapshot of a task, running or, with a store, finished.
+func (sm *SubagentManager) GetTask(taskID string) (*SubagentTask, bool) {
+	taskID = NormalizeTaskID(taskID)
+	sm.mu.RLock()
+	task, ok := sm.tasks[taskID]
+	var snapshot SubagentTask
+	if ok {
+		snapshot = *task
+	}
+	store, agentID := sm.store, sm.agentID
+	sm.mu.RUnlock()
+	if ok {
+		return &snapshot, true
+	}
+	if store != nil {
+		if stored, err := store.Get(taskID); err == nil && stored.ParentAgentID == agentID {
+			return stored, true
+		}
+	}
+	return nil, false
+}
+
+// ListTasks returns snapshots of the tasks spawned through this manager,
+// newest first. With a store, this includes tasks from earlier runs.
+func (sm *SubagentManager) ListTasks() []*SubagentTask {
+	sm.mu.RLock()
 	tasks := make([]*SubagentTask, 0, len(sm.tasks))
+	seen := make(map[string]bool, len(sm.tasks))
 	for _, task := range sm.tasks {
-		tasks = append(tasks, task)
+		snapshot := *task
+		tasks = append(tasks, &snapshot)
+		seen[task.ID] = true
+	}
+	store, agentID := sm.store, sm.agentID
+	sm.mu.RUnlock()
+
+	if store != nil {
+		stored, _ := store.List()
+		for _, task := range stored {
+			if task.ParentAgentID == agentID && !seen[task.ID] {
+				tasks = append(tasks, task)
+			}
+		}
 	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Created > tasks[j].Created })
 	return tasks
 }
 
@@ -280,6 +571,11 @@ func (t *SubagentTool) SetContext(channel, chatID string) {
 	t.originChatID = chatID
 }
 
+// Clone returns a copy using the same manager.
+func (t *SubagentTool) Clone() Tool {
+	return NewSubagentTool(t.manager)
+}
+
 func (t *SubagentTool) Execute(ctx context.Context, args map[string]interface{}) *ToolResult {
 	task, ok := args["task"].(string)
 	if !ok {
@@ -305,34 +601,7 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]interface{})
 	}
 
 	// Use RunToolLoop to execute with tools (same as async SpawnTool)
-	sm := t.manager
-	sm.mu.RLock()
-	tools := sm.tools
-	maxIter := sm.maxIterations
-	maxTokens := sm.maxTokens
-	temperature := sm.temperature
-	hasMaxTokens := sm.hasMaxTokens
-	hasTemperature := sm.hasTemperature
-	sm.mu.RUnlock()
-
-	var llmOptions map[string]any
-	if hasMaxTokens || hasTemperature {
-		llmOptions = map[string]any{}
-		if hasMaxTokens {
-			llmOptions["max_tokens"] = maxTokens
-		}
-		if hasTemperature {
-			llmOptions["temperature"] = temperature
-		}
-	}
-
-	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
-		Provider:      sm.provider,
-		Model:         sm.defaultModel,
-		Tools:         tools,
-		MaxIterations: maxIter,
-		LLMOptions:    llmOptions,
-	}, messages, t.originChannel, t.originChatID)
+	loopResult, err := t.manager.runSync(ctx, task, label, t.originChannel, t.originChatID, messages)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("Subagent execution failed: %v", err)).WithError(err)
 	}
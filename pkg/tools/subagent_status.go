@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// SubagentStatusTool lets an agent check on the tasks it spawned instead of
+// waiting for their announcements.
+type SubagentStatusTool struct {
+	manager *SubagentManager
+}
+
+func NewSubagentStatusTool(manager *SubagentManager) *SubagentStatusTool {
+	return &SubagentStatusTool{manager: manager}
+}
+
+func (t *SubagentStatusTool) Name() string {
+	return "subagent_status"
+}
+
+func (t *SubagentStatusTool) Description() string {
+	return "Check on subagent tasks you spawned. Without task_id, lists recent tasks with their status. With task_id, shows status, run time, iterations, token usage and the result, and the transcript when asked."
+}
+
+func (t *SubagentStatusTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"task_id": map[string]interface{}{
+				"type":        "string",
+				"description": "Task ID returned by spawn, e.g. subagent-3",
+			},
+			"transcript": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Include the task's messages and tool calls (with task_id)",
+			},
+		},
+	}
+}
+
+func (t *SubagentStatusTool) Execute(ctx context.Context, args map[string]interface{}) *ToolResult {
+	if t.manager == nil {
+		return ErrorResult("Subagent manager not configured")
+	}
+
+	taskID, _ := args["task_id"].(string)
+	if taskID == "" {
+		return SilentResult(FormatSubagentTasks(t.manager.ListTasks(), 20))
+	}
+	task, ok := t.manager.GetTask(taskID)
+	if !ok {
+		return ErrorResult(fmt.Sprintf("task %s not found", taskID))
+	}
+	transcript, _ := args["transcript"].(bool)
+	return SilentResult(FormatSubagentTask(task, transcript))
+}
+
+// FormatSubagentTasks lists up to limit tasks, one per line.
+func FormatSubagentTasks(tasks []*SubagentTask, limit int) string {
+	if len(tasks) == 0 {
+		return "No subagent tasks."
+	}
+	var sb strings.Builder
+	for i, task := range tasks {
+		if i == limit {
+			fmt.Fprintf(&sb, "... and %d older tasks\n", len(tasks)-limit)
+			break
+		}
+		name := task.Label
+		if name == "" {
+			name = utils.Truncate(strings.Join(strings.Fields(task.Task), " "), 60)
+		}
+		agent := task.AgentID
+		if agent == "" {
+			agent = task.ParentAgentID
+		}
+		fmt.Fprintf(&sb, "%s  %-11s %-10s %8s  %s\n", task.ID, task.Status, agent, taskRunTime(task), name)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// FormatSubagentTask describes a task and its result, and with transcript
+// its messages.
+func FormatSubagentTask(task *SubagentTask, transcript bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %s\n", task.ID, task.Status)
+	if task.Label != "" {
+		fmt.Fprintf(&sb, "Label: %s\n", task.Label)
+	}
+	switch {
+	case task.AgentID != "" && task.ParentAgentID != "":
+		fmt.Fprintf(&sb, "Agent: %s (spawned by %s)\n", task.AgentID, task.ParentAgentID)
+	case task.AgentID != "":
+		fmt.Fprintf(&sb, "Agent: %s\n", task.AgentID)
+	case task.ParentAgentID != "":
+		fmt.Fprintf(&sb, "Agent: %s\n", task.ParentAgentID)
+	}
+	fmt.Fprintf(&sb, "Chat: %s:%s\n", task.OriginChannel, task.OriginChatID)
+	ran := "ran for"
+	if task.Finished == 0 {
+		ran = "running for"
+	}
+	fmt.Fprintf(&sb, "Started: %s, %s %s\n", time.UnixMilli(task.Created).Format("2006-01-02 15:04:05"), ran, taskRunTime(task))
+
+	var limits []string
+	if task.Timeout > 0 {
+		limits = append(limits, fmt.Sprintf("timeout %s", time.Duration(task.Timeout)*time.Second))
+	}
+	if task.MaxIterations > 0 {
+		limits = append(limits, fmt.Sprintf("max %d iterations", task.MaxIterations))
+	}
+	if len(limits) > 0 {
+		fmt.Fprintf(&sb, "Limits: %s\n", strings.Join(limits, ", "))
+	}
+	if task.Finished > 0 {
+		u := task.Usage
+		fmt.Fprintf(&sb, "Iterations: %d\n", task.Iterations)
+		fmt.Fprintf(&sb, "Tokens: %d (%d prompt, %d completion)\n", u.PromptTokens+u.CompletionTokens, u.PromptTokens, u.CompletionTokens)
+	}
+	fmt.Fprintf(&sb, "Task: %s\n", task.Task)
+	if task.Result != "" {
+		fmt.Fprintf(&sb, "\nResult:\n%s\n", task.Result)
+	}
+
+	if transcript {
+		sb.WriteString("\nTranscript:\n")
+		if len(task.Transcript) == 0 {
+			sb.WriteString("(none recorded)\n")
+		}
+		for _, msg := range task.Transcript {
+			line := strings.Join(strings.Fields(msg.Content), " ")
+			var calls []string
+			for _, tc := range msg.ToolCalls {
+				if tc.Function != nil {
+					calls = append(calls, tc.Function.Name+"("+utils.Truncate(tc.Function.Arguments, 120)+")")
+				}
+			}
+			if len(calls) > 0 {
+				line = strings.TrimSpace(line + " -> " + strings.Join(calls, ", "))
+			}
+			fmt.Fprintf(&sb, "[%s] %s\n", msg.Role, utils.Truncate(line, 300))
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// taskRunTime is how long a task ran, or has been running.
+func taskRunTime(task *SubagentTask) time.Duration {
+	end := task.Finished
+	if end == 0 {
+		end = time.Now().UnixMilli()
+	}
+	return (time.Duration(end-task.Created) * time.Millisecond).Round(time.Second)
+}
@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SubagentRetention is how long records of finished subagent tasks are kept.
+const SubagentRetention = 30 * 24 * time.Hour
+
+// SubagentStore keeps a JSON record of every subagent task in
+// <workspace>/subagents, so tasks can be inspected after they finish and
+// after a restart. One store is shared by all agents, which keeps task IDs
+// unique.
+type SubagentStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewSubagentStore(workspace string) *SubagentStore {
+	return &SubagentStore{dir: filepath.Join(workspace, "subagents")}
+}
+
+// NormalizeTaskID accepts "12" as well as "subagent-12".
+func NormalizeTaskID(id string) string {
+	id = strings.TrimSpace(id)
+	if _, err := strconv.Atoi(id); err == nil {
+		return "subagent-" + id
+	}
+	return id
+}
+
+// NextID returns a task ID not used by any stored task. The directory is
+// scanned on every call and the ID is reserved by creating its record file,
+// so processes sharing the workspace do not hand out the same ID.
+func (s *SubagentStore) NextID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := 1
+	entries, _ := os.ReadDir(s.dir)
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".json")
+		if n, err := strconv.Atoi(strings.TrimPrefix(name, "subagent-")); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Sprintf("subagent-%d", next)
+	}
+	for ; ; next++ {
+		id := fmt.Sprintf("subagent-%d", next)
+		f, err := os.OpenFile(filepath.Join(s.dir, id+".json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			return id
+		}
+		if !os.IsExist(err) {
+			return id
+		}
+	}
+}
+
+func (s *SubagentStore) path(id string) (string, error) {
+	if id == "" || !filepath.IsLocal(id) || strings.ContainsAny(id, `/\:`) {
+		return "", fmt.Errorf("invalid task ID %q", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// Save writes the record of task.
+func (s *SubagentStore) Save(task *SubagentTask) error {
+	path, err := s.path(task.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(task, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Delete removes the record of a task.
+func (s *SubagentStore) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Get reads the record of a task.
+func (s *SubagentStore) Get(id string) (*SubagentTask, error) {
+	path, err := s.path(NormalizeTaskID(id))
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("task %s not found", id)
+		}
+		return nil, err
+	}
+	var task SubagentTask
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, fmt.Errorf("failed to parse task %s: %w", id, err)
+	}
+	return &task, nil
+}
+
+// List returns every stored task, newest first.
+func (s *SubagentStore) List() ([]*SubagentTask, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var tasks []*SubagentTask
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		task, err := s.Get(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Created > tasks[j].Created })
+	return tasks, nil
+}
+
+// Recover marks tasks left running by a previous process as interrupted and
+// removes records finished more than SubagentRetention ago.
+func (s *SubagentStore) Recover() error {
+	tasks, err := s.List()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	cutoff := now.Add(-SubagentRetention).UnixMilli()
+	for _, task := range tasks {
+		switch {
+		case task.Status == SubagentRunning:
+			task.Status = SubagentInterrupted
+			task.Result = "Interrupted by a restart"
+			task.Finished = now.UnixMilli()
+			if err := s.Save(task); err != nil {
+				return err
+			}
+		case task.Finished > 0 && task.Finished < cutoff:
+			path, _ := s.path(task.ID)
+			os.Remove(path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// blockingRunner reports one iteration, then runs until its task is
+// cancelled or times out.
+func blockingRunner(started chan<- string) SubagentRunner {
+	return func(ctx context.Context, task *SubagentTask, progress func(*ToolLoopResult)) (*ToolLoopResult, error) {
+		progress(&ToolLoopResult{
+			Iterations: 1,
+			Usage:      providers.UsageInfo{TotalTokens: 50},
+			Transcript: []providers.Message{{Role: "user", Content: task.Task}},
+		})
+		started <- task.ID
+		<-ctx.Done()
+		return &ToolLoopResult{
+			Iterations: 1,
+			Transcript: []providers.Message{{Role: "user", Content: task.Task}},
+		}, ctx.Err()
+	}
+}
+
+func newStoreTestManager(t *testing.T, msgBus *bus.MessageBus) (*SubagentManager, *SubagentStore) {
+	t.Helper()
+	store := NewSubagentStore(t.TempDir())
+	manager := NewSubagentManager(&MockLLMProvider{}, "test-model", "/tmp/test", msgBus)
+	manager.SetAgentID("main")
+	manager.SetStore(store)
+	return manager, store
+}
+
+func waitAnnounce(t *testing.T, msgBus *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("task was not announced")
+	}
+	return msg
+}
+
+func TestSubagentManager_PersistsCompletedTask(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	manager, store := newStoreTestManager(t, msgBus)
+	manager.SetRunner(func(ctx context.Context, task *SubagentTask, progress func(*ToolLoopResult)) (*ToolLoopResult, error) {
+		return &ToolLoopResult{
+			Content:    "42 files",
+			Iterations: 2,
+			Usage:      providers.UsageInfo{PromptTokens: 100, CompletionTokens: 20},
+			Transcript: []providers.Message{{Role: "user", Content: task.Task}, {Role: "assistant", Content: "42 files"}},
+		}, nil
+	})
+
+	out, err := manager.Spawn(context.Background(), "count the files", "count", "", "telegram", "42", SubagentLimits{}, nil)
+	if err != nil || !strings.Contains(out, "subagent-1") {
+		t.Fatalf("Spawn = %q, %v", out, err)
+	}
+	msg := waitAnnounce(t, msgBus)
+	if msg.Metadata["agent_id"] != "main" || !strings.Contains(msg.Content, "Task 'count' completed") {
+		t.Errorf("announcement = %+v", msg)
+	}
+
+	task, err := store.Get("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != SubagentCompleted || task.Result != "42 files" || task.Iterations != 2 ||
+		task.Usage.PromptTokens != 100 || len(task.Transcript) != 2 || task.Finished == 0 {
+		t.Errorf("stored task = %+v", task)
+	}
+	if task.Timeout != int(DefaultSubagentTimeout/time.Second) {
+		t.Errorf("timeout = %d", task.Timeout)
+	}
+
+	// Finished tasks are read back from the store.
+	if got, ok := manager.GetTask("subagent-1"); !ok || got.Status != SubagentCompleted {
+		t.Errorf("GetTask = %+v, %v", got, ok)
+	}
+	if tasks := manager.ListTasks(); len(tasks) != 1 {
+		t.Errorf("ListTasks = %d tasks", len(tasks))
+	}
+
+	status := NewSubagentStatusTool(manager).Execute(context.Background(), map[string]interface{}{
+		"task_id":    "subagent-1",
+		"transcript": true,
+	})
+	if status.IsError || !strings.Contains(status.ForLLM, "Tokens: 120") || !strings.Contains(status.ForLLM, "[assistant] 42 files") {
+		t.Errorf("subagent_status = %s", status.ForLLM)
+	}
+}
+
+func TestSubagentManager_CancelAndLimits(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	manager, store := newStoreTestManager(t, msgBus)
+	started := make(chan string, 2)
+	manager.SetRunner(blockingRunner(started))
+	manager.SetLimits(SubagentLimits{MaxConcurrent: 1, MaxIterations: 8})
+
+	ctx := context.Background()
+	if _, err := manager.Spawn(ctx, "watch the logs", "", "", "telegram", "42", SubagentLimits{MaxIterations: 20}, nil); err != nil {
+		t.Fatal(err)
+	}
+	id := <-started
+	if _, err := manager.Spawn(ctx, "second", "", "", "telegram", "42", SubagentLimits{}, nil); err == nil {
+		t.Error("expected the concurrency limit to refuse a second task")
+	}
+
+	running, _ := manager.GetTask(id)
+	if running.Status != SubagentRunning || running.MaxIterations != 8 {
+		t.Errorf("running task = %+v", running)
+	}
+	list := NewSubagentStatusTool(manager).Execute(ctx, map[string]interface{}{})
+	if !strings.Contains(list.ForLLM, id+"  running") {
+		t.Errorf("subagent_status list = %s", list.ForLLM)
+	}
+	// The record of a running task shows its progress.
+	if stored, err := store.Get(id); err != nil || stored.Status != SubagentRunning ||
+		stored.Iterations != 1 || stored.Usage.TotalTokens != 50 || len(stored.Transcript) != 1 {
+		t.Errorf("stored running task = %+v, %v", stored, err)
+	}
+
+	if err := manager.Cancel(id); err != nil {
+		t.Fatal(err)
+	}
+	waitAnnounce(t, msgBus)
+	task, err := store.Get(id)
+	if err != nil || task.Status != SubagentCancelled || len(task.Transcript) != 1 {
+		t.Errorf("cancelled task = %+v, %v", task, err)
+	}
+	if err := manager.Cancel(id); err == nil || !strings.Contains(err.Error(), "not running") {
+		t.Errorf("Cancel of a finished task = %v", err)
+	}
+
+	// A task may ask for a shorter timeout than the configured one.
+	if _, err := manager.Spawn(ctx, "slow", "", "", "telegram", "42", SubagentLimits{Timeout: 50 * time.Millisecond}, nil); err != nil {
+		t.Fatal(err)
+	}
+	id = <-started
+	waitAnnounce(t, msgBus)
+	if task, _ := store.Get(id); task == nil || task.Status != SubagentTimedOut {
+		t.Errorf("timed out task = %+v", task)
+	}
+}
+
+func TestSubagentStore_Recover(t *testing.T) {
+	workspace := t.TempDir()
+	store := NewSubagentStore(workspace)
+	now := time.Now()
+	old := now.Add(-SubagentRetention - time.Hour).UnixMilli()
+	for _, task := range []*SubagentTask{
+		{ID: "subagent-3", Status: SubagentRunning, Created: now.UnixMilli()},
+		{ID: "subagent-7", Status: SubagentCompleted, Created: old, Finished: old},
+		{ID: "subagent-5", Status: SubagentFailed, Created: now.UnixMilli(), Finished: now.UnixMilli()},
+	} {
+		if err := store.Save(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A new process continues after the highest ID on disk.
+	store = NewSubagentStore(workspace)
+	if id := store.NextID(); id != "subagent-8" {
+		t.Errorf("NextID = %s, want subagent-8", id)
+	}
+
+	if err := store.Recover(); err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := store.List()
+	if err != nil || len(tasks) != 2 {
+		t.Fatalf("List = %d tasks, %v", len(tasks), err)
+	}
+	if task, _ := store.Get("subagent-3"); task.Status != SubagentInterrupted {
+		t.Errorf("running task after restart = %s", task.Status)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "subagents", "subagent-7.json")); !os.IsNotExist(err) {
+		t.Errorf("expired task still stored: %v", err)
+	}
+	if _, err := store.Get("../state"); err == nil {
+		t.Error("expected an error for an invalid task ID")
+	}
+}
+
+func TestSubagentStore_NextIDSharedWorkspace(t *testing.T) {
+	workspace := t.TempDir()
+	a, b := NewSubagentStore(workspace), NewSubagentStore(workspace)
+
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		for _, store := range []*SubagentStore{a, b} {
+			id := store.NextID()
+			if seen[id] {
+				t.Fatalf("NextID handed out %s twice", id)
+			}
+			seen[id] = true
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/providers"
@@ -342,3 +343,38 @@ func TestSubagentTool_ForUserTruncation(t *testing.T) {
 		t.Error("ForLLM should contain reference to original task")
 	}
 }
+
+func TestSubagentTool_Execute_CountsAgainstLimits(t *testing.T) {
+	manager := NewSubagentManager(&MockLLMProvider{}, "test-model", t.TempDir(), bus.NewMessageBus())
+	manager.SetLimits(SubagentLimits{MaxConcurrent: 1})
+	started := make(chan string)
+	manager.SetRunner(func(ctx context.Context, task *SubagentTask, progress func(*ToolLoopResult)) (*ToolLoopResult, error) {
+		started <- task.ID
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	done := make(chan *ToolResult)
+	go func() {
+		done <- NewSubagentTool(manager).Execute(context.Background(), map[string]interface{}{"task": "wait"})
+	}()
+	id := <-started
+
+	if _, err := manager.Spawn(context.Background(), "other", "", "", "cli", "direct", SubagentLimits{}, nil); err == nil {
+		t.Error("Spawn ignored the running synchronous task")
+	}
+	if err := manager.Cancel(id); err != nil {
+		t.Fatalf("Cancel(%s) failed: %v", id, err)
+	}
+	select {
+	case result := <-done:
+		if !result.IsError {
+			t.Errorf("cancelled run = %+v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cancelling did not stop the synchronous task")
+	}
+	if tasks := manager.ListTasks(); len(tasks) != 0 {
+		t.Errorf("finished synchronous task kept: %+v", tasks)
+	}
+}
@@ -0,0 +1,305 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// TelemetryTool records metrics such as sensor readings in the workspace,
+// queries them over time ranges and charts them.
+type TelemetryTool struct {
+	store     *telemetry.Store
+	workspace string
+	msgBus    *bus.MessageBus
+	now       func() time.Time
+
+	mu      sync.RWMutex
+	channel string
+	chatID  string
+}
+
+func NewTelemetryTool(workspace string, msgBus *bus.MessageBus) *TelemetryTool {
+	return &TelemetryTool{
+		store:     telemetry.NewStore(workspace),
+		workspace: workspace,
+		msgBus:    msgBus,
+		now:       time.Now,
+	}
+}
+
+// SetContext sets the chat that charts are sent to.
+func (t *TelemetryTool) SetContext(channel, chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.channel = channel
+	t.chatID = chatID
+}
+
+// Clone returns a copy sharing the store, without a chat set.
+func (t *TelemetryTool) Clone() Tool {
+	return &TelemetryTool{
+		store:     t.store,
+		workspace: t.workspace,
+		msgBus:    t.msgBus,
+		now:       t.now,
+	}
+}
+
+func (t *TelemetryTool) Name() string {
+	return "telemetry"
+}
+
+func (t *TelemetryTool) Description() string {
+	return "Record metrics such as sensor readings over time and answer questions about them. Actions: record (store a value of a metric, e.g. temperature), query (min/max/avg and when they happened over a time range, optionally per step), chart (draw a PNG chart and send it to the chat), list (recorded metrics and their latest values). Every value is kept for 7 days, then hourly summaries for 2 years. Times are local: now, -12h, 3d ago, 2026-03-10 22:00 or RFC 3339."
+}
+
+func (t *TelemetryTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"record", "query", "chart", "list"},
+				"description": "Action to perform",
+			},
+			"metric": map[string]interface{}{
+				"type":        "string",
+				"description": "Metric name, e.g. \"temperature\" or \"greenhouse.humidity\" (letters, digits and _.:-)",
+			},
+			"value": map[string]interface{}{
+				"type":        "number",
+				"description": "Value to record",
+			},
+			"unit": map[string]interface{}{
+				"type":        "string",
+				"description": "Unit of the value, e.g. \"°C\" (record)",
+			},
+			"time": map[string]interface{}{
+				"type":        "string",
+				"description": "When the value was measured (record). Default: now.",
+			},
+			"from": map[string]interface{}{
+				"type":        "string",
+				"description": "Range start (query, chart). Default: -24h.",
+			},
+			"to": map[string]interface{}{
+				"type":        "string",
+				"description": "Range end, exclusive (query, chart). Default: now.",
+			},
+			"step": map[string]interface{}{
+				"type":        "string",
+				"description": "Bucket size such as 15m, 1h or 1d. For query, returns one value per bucket; for chart, defaults to about 200 points.",
+			},
+			"agg": map[string]interface{}{
+				"type":        "string",
+				"enum":        telemetry.Aggregations,
+				"description": "Aggregation of each bucket for query with step. Default: avg.",
+			},
+			"send": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Send the chart to the current chat (chart). Default: true.",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *TelemetryTool) Execute(ctx context.Context, args map[string]interface{}) *ToolResult {
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+
+	switch action {
+	case "record":
+		return t.record(args)
+	case "query":
+		return t.query(args)
+	case "chart":
+		return t.chart(args)
+	case "list":
+		return t.list()
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s (valid: record, query, chart, list)", action))
+	}
+}
+
+func (t *TelemetryTool) record(args map[string]interface{}) *ToolResult {
+	metric, _ := args["metric"].(string)
+	value, ok := args["value"].(float64)
+	if metric == "" || !ok {
+		return ErrorResult("metric and value are required")
+	}
+	unit, _ := args["unit"].(string)
+
+	var at time.Time
+	if s, _ := args["time"].(string); s != "" {
+		var err error
+		if at, err = telemetry.ParseTime(s, t.now()); err != nil {
+			return ErrorResult(err.Error())
+		}
+	}
+	if err := t.store.Record(metric, value, unit, at); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to record %s: %v", metric, err))
+	}
+	return SilentResult(fmt.Sprintf("Recorded %s = %g%s", metric, value, unit))
+}
+
+// parseRange reads metric, from, to and step from args.
+func (t *TelemetryTool) parseRange(args map[string]interface{}) (telemetry.Query, *ToolResult) {
+	q := telemetry.Query{}
+	q.Metric, _ = args["metric"].(string)
+	if q.Metric == "" {
+		return q, ErrorResult("metric is required")
+	}
+
+	now := t.now()
+	var err error
+	from, _ := args["from"].(string)
+	if from == "" {
+		from = "-24h"
+	}
+	if q.From, err = telemetry.ParseTime(from, now); err != nil {
+		return q, ErrorResult(err.Error())
+	}
+	q.To = now
+	if to, _ := args["to"].(string); to != "" {
+		if q.To, err = telemetry.ParseTime(to, now); err != nil {
+			return q, ErrorResult(err.Error())
+		}
+	}
+	if step, _ := args["step"].(string); step != "" {
+		if q.Step, err = telemetry.ParseDuration(step); err != nil {
+			return q, ErrorResult(err.Error())
+		}
+	}
+	return q, nil
+}
+
+func (t *TelemetryTool) query(args map[string]interface{}) *ToolResult {
+	q, res := t.parseRange(args)
+	if res != nil {
+		return res
+	}
+	agg, _ := args["agg"].(string)
+	if _, err := (telemetry.Stats{}).Value(agg); err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	series, err := t.store.Query(q)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("query failed: %v", err))
+	}
+	if series.Total.Count == 0 {
+		return SilentResult(fmt.Sprintf("No values of %s between %s and %s.",
+			q.Metric, q.From.Format(time.RFC3339), q.To.Format(time.RFC3339)))
+	}
+
+	total := series.Total
+	out := map[string]interface{}{
+		"metric":  series.Metric,
+		"from":    series.From.Format(time.RFC3339),
+		"to":      series.To.Format(time.RFC3339),
+		"count":   total.Count,
+		"min":     total.Min,
+		"min_at":  total.MinAt.Format(time.RFC3339),
+		"max":     total.Max,
+		"max_at":  total.MaxAt.Format(time.RFC3339),
+		"avg":     total.Avg,
+		"first":   total.First,
+		"last":    total.Last,
+		"last_at": total.LastAt.Format(time.RFC3339),
+	}
+	if series.Unit != "" {
+		out["unit"] = series.Unit
+	}
+	if series.Hourly {
+		out["note"] = "older values come from hourly summaries, so steps under an hour are approximate"
+	}
+	if q.Step > 0 {
+		if agg == "" {
+			agg = "avg"
+		}
+		points := make([]map[string]interface{}, 0, len(series.Buckets))
+		for _, b := range series.Buckets {
+			v, _ := b.Value(agg)
+			points = append(points, map[string]interface{}{"time": b.Start.Format(time.RFC3339), agg: v})
+		}
+		out["step"] = q.Step.String()
+		out["points"] = points
+	}
+
+	result, _ := json.MarshalIndent(out, "", "  ")
+	return SilentResult(string(result))
+}
+
+func (t *TelemetryTool) chart(args map[string]interface{}) *ToolResult {
+	q, res := t.parseRange(args)
+	if res != nil {
+		return res
+	}
+	if q.Step == 0 && q.To.After(q.From) {
+		q.Step = telemetry.AutoStep(q.From, q.To)
+	}
+
+	series, err := t.store.Query(q)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("query failed: %v", err))
+	}
+	image, err := telemetry.Chart(series)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to draw chart: %v", err))
+	}
+
+	dir := filepath.Join(t.workspace, "telemetry", "charts")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create %s: %v", dir, err))
+	}
+	name := fmt.Sprintf("%s-%s.png", strings.ReplaceAll(q.Metric, ":", "_"), t.now().Format("20060102-150405"))
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, image, 0644); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to save chart: %v", err))
+	}
+	rel, _ := filepath.Rel(t.workspace, path)
+	summary := fmt.Sprintf("Chart of %s (%d values, min %g, avg %.4g, max %g%s) saved to %s",
+		q.Metric, series.Total.Count, series.Total.Min, series.Total.Avg, series.Total.Max, series.Unit, rel)
+
+	send, ok := args["send"].(bool)
+	if !ok {
+		send = true
+	}
+	t.mu.RLock()
+	channel, chatID := t.channel, t.chatID
+	t.mu.RUnlock()
+	if !send || t.msgBus == nil || channel == "" || chatID == "" || constants.IsInternalChannel(channel) {
+		return SilentResult(summary + ".")
+	}
+	t.msgBus.PublishOutbound(bus.OutboundMessage{
+		Channel: channel,
+		ChatID:  chatID,
+		Media:   []string{path},
+	})
+	return SilentResult(summary + " and sent to the chat.")
+}
+
+func (t *TelemetryTool) list() *ToolResult {
+	metrics, err := t.store.Metrics()
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to list metrics: %v", err))
+	}
+	if len(metrics) == 0 {
+		return SilentResult("No metrics recorded yet.")
+	}
+	result, _ := json.MarshalIndent(metrics, "", "  ")
+	return SilentResult(string(result))
+}
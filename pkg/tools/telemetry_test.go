@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestTelemetryTool_RecordAndQuery(t *testing.T) {
+	tool := NewTelemetryTool(t.TempDir(), nil)
+	now := time.Date(2026, 3, 11, 8, 0, 0, 0, time.Local)
+	tool.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i, v := range []float64{19.5, 17.25, 18, 21} {
+		result := tool.Execute(ctx, map[string]interface{}{
+			"action": "record",
+			"metric": "temperature",
+			"value":  v,
+			"unit":   "°C",
+			"time":   now.Add(time.Duration(i-4) * 3 * time.Hour).Format(time.RFC3339),
+		})
+		if result.IsError {
+			t.Fatalf("record failed: %s", result.ForLLM)
+		}
+	}
+
+	result := tool.Execute(ctx, map[string]interface{}{
+		"action": "query",
+		"metric": "temperature",
+		"from":   "2026-03-10 22:00",
+		"to":     "now",
+		"step":   "3h",
+		"agg":    "min",
+	})
+	if result.IsError {
+		t.Fatalf("query failed: %s", result.ForLLM)
+	}
+	var out struct {
+		Count  int                      `json:"count"`
+		Min    float64                  `json:"min"`
+		MinAt  string                   `json:"min_at"`
+		Max    float64                  `json:"max"`
+		Unit   string                   `json:"unit"`
+		Points []map[string]interface{} `json:"points"`
+	}
+	if err := json.Unmarshal([]byte(result.ForLLM), &out); err != nil {
+		t.Fatalf("query result is not JSON: %v\n%s", err, result.ForLLM)
+	}
+	// 20:00 is before the range; 23:00, 02:00 and 05:00 are in it.
+	if out.Count != 3 || out.Min != 17.25 || out.Max != 21 || out.Unit != "°C" {
+		t.Errorf("query = %+v", out)
+	}
+	if out.MinAt != now.Add(-9*time.Hour).Format(time.RFC3339) {
+		t.Errorf("min_at = %s", out.MinAt)
+	}
+	if len(out.Points) != 3 || out.Points[0]["min"] != 17.25 {
+		t.Errorf("points = %v", out.Points)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"action": "record", "metric": "temperature"},
+		{"action": "record", "metric": "a/b", "value": float64(1)},
+		{"action": "query", "metric": "temperature", "from": "last week"},
+		{"action": "query", "metric": "temperature", "agg": "median"},
+		{"action": "query", "metric": "temperature", "step": "1s"},
+		{"action": "chart", "metric": "missing"},
+	} {
+		if result := tool.Execute(ctx, args); !result.IsError {
+			t.Errorf("%v: expected an error, got %s", args, result.ForLLM)
+		}
+	}
+}
+
+func TestTelemetryTool_ChartSendsImage(t *testing.T) {
+	workspace := t.TempDir()
+	msgBus := bus.NewMessageBus()
+	tool := NewTelemetryTool(workspace, msgBus)
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		tool.store.Record("power", float64(i), "W", time.Now().Add(-time.Duration(i)*time.Minute))
+	}
+
+	tool.SetContext("telegram", "42")
+	result := tool.Execute(ctx, map[string]interface{}{"action": "chart", "metric": "power", "from": "-1h"})
+	if result.IsError {
+		t.Fatalf("chart failed: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "sent to the chat") {
+		t.Errorf("result = %s", result.ForLLM)
+	}
+
+	subCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	msg, ok := msgBus.SubscribeOutbound(subCtx)
+	if !ok || msg.Channel != "telegram" || msg.ChatID != "42" || len(msg.Media) != 1 {
+		t.Fatalf("outbound = %+v, %v", msg, ok)
+	}
+	data, err := os.ReadFile(msg.Media[0])
+	if err != nil || !strings.HasPrefix(string(data), "\x89PNG") {
+		t.Errorf("chart file: %v", err)
+	}
+
+	// Charts from the CLI are only saved.
+	tool.SetContext("cli", "direct")
+	result = tool.Execute(ctx, map[string]interface{}{"action": "chart", "metric": "power"})
+	if result.IsError || strings.Contains(result.ForLLM, "sent") {
+		t.Errorf("cli chart = %s", result.ForLLM)
+	}
+}
@@ -23,12 +23,18 @@ type ToolLoopConfig struct {
 	Tools         *ToolRegistry
 	MaxIterations int
 	LLMOptions    map[string]any
+
+	// OnIteration, when set, receives the partial result after the tool
+	// calls of each iteration have run.
+	OnIteration func(*ToolLoopResult)
 }
 
 // ToolLoopResult contains the result of running the tool loop.
 type ToolLoopResult struct {
 	Content    string
 	Iterations int
+	Usage      providers.UsageInfo // Tokens used by all LLM calls
+	Transcript []providers.Message // Messages after the system prompt, ending with the answer
 }
 
 // RunToolLoop executes the LLM + tool call iteration loop.
@@ -36,6 +42,7 @@ type ToolLoopResult struct {
 func RunToolLoop(ctx context.Context, config ToolLoopConfig, messages []providers.Message, channel, chatID string) (*ToolLoopResult, error) {
 	iteration := 0
 	var finalContent string
+	var usage providers.UsageInfo
 
 	for iteration < config.MaxIterations {
 		iteration++
@@ -67,6 +74,7 @@ func RunToolLoop(ctx context.Context, config ToolLoopConfig, messages []provider
 				})
 			return nil, fmt.Errorf("LLM call failed: %w", err)
 		}
+		usage.Add(response.Usage)
 
 		// 4. If no tool calls, we're done
 		if len(response.ToolCalls) == 0 {
@@ -142,10 +150,35 @@ func RunToolLoop(ctx context.Context, config ToolLoopConfig, messages []provider
 			}
 			messages = append(messages, toolResultMsg)
 		}
+
+		if config.OnIteration != nil {
+			config.OnIteration(&ToolLoopResult{
+				Iterations: iteration,
+				Usage:      usage,
+				Transcript: transcript(messages, ""),
+			})
+		}
 	}
 
 	return &ToolLoopResult{
 		Content:    finalContent,
 		Iterations: iteration,
+		Usage:      usage,
+		Transcript: transcript(messages, finalContent),
 	}, nil
 }
+
+// transcript returns messages without the system prompt, followed by the
+// final answer if there is one.
+func transcript(messages []providers.Message, finalContent string) []providers.Message {
+	var out []providers.Message
+	for _, msg := range messages {
+		if msg.Role != "system" {
+			out = append(out, msg)
+		}
+	}
+	if finalContent != "" {
+		out = append(out, providers.Message{Role: "assistant", Content: finalContent})
+	}
+	return out
+}
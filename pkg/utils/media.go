@@ -14,7 +14,7 @@ import (
 
 // IsAudioFile checks if a file is an audio file based on its filename extension and content type.
 func IsAudioFile(filename, contentType string) bool {
-	audioExtensions := []string{".mp3", ".wav", ".ogg", ".m4a", ".flac", ".aac", ".wma"}
+	audioExtensions := []string{".mp3", ".wav", ".ogg", ".oga", ".opus", ".m4a", ".flac", ".aac", ".wma", ".amr"}
 	audioTypes := []string{"audio/", "application/ogg", "application/x-ogg"}
 
 	for _, ext := range audioExtensions {
@@ -134,6 +134,39 @@ func DownloadFile(url, filename string, opts DownloadOptions) string {
 	return localPath
 }
 
+// SaveMediaFile writes data to the same temp directory as DownloadFile, for
+// media that channels fetch through an SDK instead of a URL. Returns the
+// local file path or empty string on error.
+func SaveMediaFile(data io.Reader, filename, loggerPrefix string) string {
+	mediaDir := filepath.Join(os.TempDir(), "picoclaw_media")
+	if err := os.MkdirAll(mediaDir, 0700); err != nil {
+		logger.ErrorCF(loggerPrefix, "Failed to create media directory", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return ""
+	}
+
+	localPath := filepath.Join(mediaDir, uuid.New().String()[:8]+"_"+SanitizeFilename(filename))
+	out, err := os.Create(localPath)
+	if err != nil {
+		logger.ErrorCF(loggerPrefix, "Failed to create local file", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return ""
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, data); err != nil {
+		out.Close()
+		os.Remove(localPath)
+		logger.ErrorCF(loggerPrefix, "Failed to write file", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return ""
+	}
+	return localPath
+}
+
 // DownloadFileSimple is a simplified version of DownloadFile without options
 func DownloadFileSimple(url, filename string) string {
 	return DownloadFile(url, filename, DownloadOptions{
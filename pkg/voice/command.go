@@ -0,0 +1,70 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// CommandTranscriber runs a local program, such as whisper.cpp's
+// whisper-cli or vosk, and uses its standard output as the transcript.
+type CommandTranscriber struct {
+	argv    []string
+	timeout time.Duration
+}
+
+// NewCommandTranscriber creates a transcriber that runs argv. The "{file}"
+// placeholder in any argument is replaced with the audio path; without one,
+// the path is appended as the last argument.
+func NewCommandTranscriber(argv []string, timeout time.Duration) *CommandTranscriber {
+	if timeout <= 0 {
+		timeout = defaultTranscriptionTimeout
+	}
+	return &CommandTranscriber{argv: argv, timeout: timeout}
+}
+
+func (t *CommandTranscriber) Name() string {
+	return BackendCommand
+}
+
+func (t *CommandTranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	args := make([]string, 0, len(t.argv)+1)
+	placed := false
+	for _, a := range t.argv {
+		if strings.Contains(a, "{file}") {
+			a = strings.ReplaceAll(a, "{file}", audioFilePath)
+			placed = true
+		}
+		args = append(args, a)
+	}
+	if !placed {
+		args = append(args, audioFilePath)
+	}
+
+	logger.InfoCF("voice", "Starting transcription", map[string]interface{}{"backend": BackendCommand, "command": args[0], "audio_file": audioFilePath})
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("transcription command failed: %w: %s", err, utils.Truncate(strings.TrimSpace(stderr.String()), 200))
+	}
+
+	text := strings.TrimSpace(stdout.String())
+	logger.InfoCF("voice", "Transcription completed successfully", map[string]interface{}{
+		"backend":               BackendCommand,
+		"text_length":           len(text),
+		"transcription_preview": utils.Truncate(text, 50),
+	})
+	return &TranscriptionResponse{Text: text}, nil
+}
@@ -10,16 +10,18 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
-type GroqTranscriber struct {
-	apiKey     string
-	apiBase    string
-	httpClient *http.Client
+// Transcriber converts a local audio file to text.
+type Transcriber interface {
+	Name() string
+	Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error)
 }
 
 type TranscriptionResponse struct {
@@ -28,21 +30,162 @@ type TranscriptionResponse struct {
 	Duration float64 `json:"duration,omitempty"`
 }
 
-func NewGroqTranscriber(apiKey string) *GroqTranscriber {
-	logger.DebugCF("voice", "Creating Groq transcriber", map[string]interface{}{"has_api_key": apiKey != ""})
+// Transcription backends selectable in voice.transcription.backend.
+const (
+	BackendOpenAI     = "openai"
+	BackendGroq       = "groq"
+	BackendWhisperCpp = "whisper_cpp"
+	BackendCommand    = "command"
+	BackendOff        = "off"
+)
+
+const defaultTranscriptionTimeout = 60 * time.Second
+
+// NewTranscriber returns the backend selected by voice.transcription, or nil
+// when transcription is off. With no backend configured, Groq is used when
+// providers.groq has an API key.
+func NewTranscriber(cfg *config.Config) (Transcriber, error) {
+	tc := cfg.Voice.Transcription
+	timeout := defaultTranscriptionTimeout
+	if tc.TimeoutSeconds > 0 {
+		timeout = time.Duration(tc.TimeoutSeconds) * time.Second
+	}
+
+	switch strings.ToLower(strings.TrimSpace(tc.Backend)) {
+	case "":
+		if cfg.Providers.Groq.APIKey == "" {
+			return nil, nil
+		}
+		return NewGroqTranscriber(cfg.Providers.Groq.APIKey), nil
+	case BackendOff, "none":
+		return nil, nil
+	case BackendGroq:
+		apiKey := firstNonEmpty(tc.APIKey, cfg.Providers.Groq.APIKey)
+		if apiKey == "" {
+			return nil, fmt.Errorf("voice transcription: groq backend needs an API key")
+		}
+		t := NewGroqTranscriber(apiKey)
+		t.apiBase = firstNonEmpty(tc.APIBase, cfg.Providers.Groq.APIBase, t.apiBase)
+		t.model = firstNonEmpty(tc.Model, t.model)
+		t.language = tc.Language
+		t.httpClient.Timeout = timeout
+		return t, nil
+	case BackendOpenAI:
+		apiBase := firstNonEmpty(tc.APIBase, cfg.Providers.OpenAI.APIBase, "https://api.openai.com/v1")
+		apiKey := firstNonEmpty(tc.APIKey, cfg.Providers.OpenAI.APIKey)
+		t := NewOpenAITranscriber(BackendOpenAI, apiBase, apiKey, firstNonEmpty(tc.Model, "whisper-1"))
+		t.language = tc.Language
+		t.httpClient.Timeout = timeout
+		return t, nil
+	case BackendWhisperCpp:
+		t := NewWhisperCppTranscriber(firstNonEmpty(tc.APIBase, "http://127.0.0.1:8080"))
+		t.language = tc.Language
+		t.httpClient.Timeout = timeout
+		return t, nil
+	case BackendCommand:
+		if len(tc.Command) == 0 {
+			return nil, fmt.Errorf("voice transcription: command backend needs a command")
+		}
+		return NewCommandTranscriber(tc.Command, timeout), nil
+	default:
+		return nil, fmt.Errorf("voice transcription: unknown backend %q", tc.Backend)
+	}
+}
+
+// OpenAITranscriber calls an OpenAI-compatible /audio/transcriptions
+// endpoint, as offered by OpenAI, Groq and most self-hosted speech servers.
+type OpenAITranscriber struct {
+	name       string
+	apiKey     string
+	apiBase    string
+	model      string
+	language   string
+	httpClient *http.Client
+}
 
-	apiBase := "https://api.groq.com/openai/v1"
-	return &GroqTranscriber{
+// NewOpenAITranscriber creates a transcriber for the OpenAI-compatible API
+// at apiBase, such as "https://api.openai.com/v1".
+func NewOpenAITranscriber(name, apiBase, apiKey, model string) *OpenAITranscriber {
+	logger.DebugCF("voice", "Creating transcriber", map[string]interface{}{"backend": name, "api_base": apiBase, "has_api_key": apiKey != ""})
+
+	return &OpenAITranscriber{
+		name:    name,
 		apiKey:  apiKey,
-		apiBase: apiBase,
+		apiBase: strings.TrimRight(apiBase, "/"),
+		model:   model,
+		httpClient: &http.Client{
+			Timeout: defaultTranscriptionTimeout,
+		},
+	}
+}
+
+// NewGroqTranscriber creates a transcriber for Groq's Whisper API.
+func NewGroqTranscriber(apiKey string) *OpenAITranscriber {
+	return NewOpenAITranscriber(BackendGroq, "https://api.groq.com/openai/v1", apiKey, "whisper-large-v3")
+}
+
+func (t *OpenAITranscriber) Name() string {
+	return t.name
+}
+
+func (t *OpenAITranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
+	fields := map[string]string{
+		"model":           t.model,
+		"response_format": "json",
+	}
+	if t.language != "" {
+		fields["language"] = t.language
+	}
+	headers := map[string]string{}
+	if t.apiKey != "" {
+		headers["Authorization"] = "Bearer " + t.apiKey
+	}
+	return postAudio(ctx, t.httpClient, t.name, t.apiBase+"/audio/transcriptions", audioFilePath, fields, headers)
+}
+
+// WhisperCppTranscriber calls the /inference endpoint of a whisper.cpp
+// server (examples/server in the whisper.cpp repository).
+type WhisperCppTranscriber struct {
+	apiBase    string
+	language   string
+	httpClient *http.Client
+}
+
+// NewWhisperCppTranscriber creates a transcriber for the whisper.cpp server
+// at apiBase, such as "http://127.0.0.1:8080".
+func NewWhisperCppTranscriber(apiBase string) *WhisperCppTranscriber {
+	return &WhisperCppTranscriber{
+		apiBase: strings.TrimRight(apiBase, "/"),
 		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
+			Timeout: defaultTranscriptionTimeout,
 		},
 	}
 }
 
-func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
-	logger.InfoCF("voice", "Starting transcription", map[string]interface{}{"audio_file": audioFilePath})
+func (t *WhisperCppTranscriber) Name() string {
+	return BackendWhisperCpp
+}
+
+func (t *WhisperCppTranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
+	fields := map[string]string{
+		"response_format": "json",
+		"temperature":     "0.0",
+	}
+	if t.language != "" {
+		fields["language"] = t.language
+	}
+	result, err := postAudio(ctx, t.httpClient, BackendWhisperCpp, t.apiBase+"/inference", audioFilePath, fields, nil)
+	if err != nil {
+		return nil, err
+	}
+	result.Text = strings.TrimSpace(result.Text)
+	return result, nil
+}
+
+// postAudio uploads audioFilePath as the multipart "file" field with fields
+// and decodes a {"text": ...} JSON response.
+func postAudio(ctx context.Context, client *http.Client, backend, url, audioFilePath string, fields, headers map[string]string) (*TranscriptionResponse, error) {
+	logger.InfoCF("voice", "Starting transcription", map[string]interface{}{"backend": backend, "audio_file": audioFilePath})
 
 	audioFile, err := os.Open(audioFilePath)
 	if err != nil {
@@ -51,66 +194,41 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 	}
 	defer audioFile.Close()
 
-	fileInfo, err := audioFile.Stat()
-	if err != nil {
-		logger.ErrorCF("voice", "Failed to get file info", map[string]interface{}{"path": audioFilePath, "error": err})
-		return nil, fmt.Errorf("failed to get file info: %w", err)
-	}
-
-	logger.DebugCF("voice", "Audio file details", map[string]interface{}{
-		"size_bytes": fileInfo.Size(),
-		"file_name":  filepath.Base(audioFilePath),
-	})
-
 	var requestBody bytes.Buffer
 	writer := multipart.NewWriter(&requestBody)
 
 	part, err := writer.CreateFormFile("file", filepath.Base(audioFilePath))
 	if err != nil {
-		logger.ErrorCF("voice", "Failed to create form file", map[string]interface{}{"error": err})
 		return nil, fmt.Errorf("failed to create form file: %w", err)
 	}
-
 	copied, err := io.Copy(part, audioFile)
 	if err != nil {
-		logger.ErrorCF("voice", "Failed to copy file content", map[string]interface{}{"error": err})
 		return nil, fmt.Errorf("failed to copy file content: %w", err)
 	}
-
-	logger.DebugCF("voice", "File copied to request", map[string]interface{}{"bytes_copied": copied})
-
-	if err := writer.WriteField("model", "whisper-large-v3"); err != nil {
-		logger.ErrorCF("voice", "Failed to write model field", map[string]interface{}{"error": err})
-		return nil, fmt.Errorf("failed to write model field: %w", err)
-	}
-
-	if err := writer.WriteField("response_format", "json"); err != nil {
-		logger.ErrorCF("voice", "Failed to write response_format field", map[string]interface{}{"error": err})
-		return nil, fmt.Errorf("failed to write response_format field: %w", err)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return nil, fmt.Errorf("failed to write %s field: %w", name, err)
+		}
 	}
-
 	if err := writer.Close(); err != nil {
-		logger.ErrorCF("voice", "Failed to close multipart writer", map[string]interface{}{"error": err})
 		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
 	}
 
-	url := t.apiBase + "/audio/transcriptions"
 	req, err := http.NewRequestWithContext(ctx, "POST", url, &requestBody)
 	if err != nil {
-		logger.ErrorCF("voice", "Failed to create request", map[string]interface{}{"error": err})
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
 
-	logger.DebugCF("voice", "Sending transcription request to Groq API", map[string]interface{}{
-		"url":                url,
-		"request_size_bytes": requestBody.Len(),
-		"file_size_bytes":    fileInfo.Size(),
+	logger.DebugCF("voice", "Sending transcription request", map[string]interface{}{
+		"url":             url,
+		"file_size_bytes": copied,
 	})
 
-	resp, err := t.httpClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		logger.ErrorCF("voice", "Failed to send request", map[string]interface{}{"error": err})
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -119,7 +237,6 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		logger.ErrorCF("voice", "Failed to read response", map[string]interface{}{"error": err})
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
@@ -131,18 +248,13 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
-	logger.DebugCF("voice", "Received response from Groq API", map[string]interface{}{
-		"status_code":         resp.StatusCode,
-		"response_size_bytes": len(body),
-	})
-
 	var result TranscriptionResponse
 	if err := json.Unmarshal(body, &result); err != nil {
-		logger.ErrorCF("voice", "Failed to unmarshal response", map[string]interface{}{"error": err})
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
 	logger.InfoCF("voice", "Transcription completed successfully", map[string]interface{}{
+		"backend":               backend,
 		"text_length":           len(result.Text),
 		"language":              result.Language,
 		"duration_seconds":      result.Duration,
@@ -152,8 +264,11 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 	return &result, nil
 }
 
-func (t *GroqTranscriber) IsAvailable() bool {
-	available := t.apiKey != ""
-	logger.DebugCF("voice", "Checking transcriber availability", map[string]interface{}{"available": available})
-	return available
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
 }
@@ -0,0 +1,139 @@
+package voice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func writeAudio(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "voice.ogg")
+	if err := os.WriteFile(path, []byte("OggS fake audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewTranscriber_Backends(t *testing.T) {
+	tests := []struct {
+		name    string
+		voice   config.TranscriptionConfig
+		groqKey string
+		want    string // Name() of the backend, "" for none
+		wantErr bool
+	}{
+		{name: "default without groq key", want: ""},
+		{name: "default with groq key", groqKey: "gsk_x", want: BackendGroq},
+		{name: "off", voice: config.TranscriptionConfig{Backend: "off"}, groqKey: "gsk_x", want: ""},
+		{name: "openai", voice: config.TranscriptionConfig{Backend: "openai", APIKey: "sk-x"}, want: BackendOpenAI},
+		{name: "whisper.cpp", voice: config.TranscriptionConfig{Backend: "whisper_cpp"}, want: BackendWhisperCpp},
+		{name: "command", voice: config.TranscriptionConfig{Backend: "command", Command: []string{"whisper-cli", "-f", "{file}"}}, want: BackendCommand},
+		{name: "command without argv", voice: config.TranscriptionConfig{Backend: "command"}, wantErr: true},
+		{name: "groq without key", voice: config.TranscriptionConfig{Backend: "groq"}, wantErr: true},
+		{name: "unknown", voice: config.TranscriptionConfig{Backend: "vosk"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg := config.DefaultConfig()
+		cfg.Voice.Transcription = tt.voice
+		cfg.Providers.Groq.APIKey = tt.groqKey
+
+		got, err := NewTranscriber(cfg)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: NewTranscriber() error: %v", tt.name, err)
+			continue
+		}
+		name := ""
+		if got != nil {
+			name = got.Name()
+		}
+		if name != tt.want {
+			t.Errorf("%s: backend = %q, want %q", tt.name, name, tt.want)
+		}
+	}
+}
+
+func TestOpenAITranscriber(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			http.Error(w, "bad request "+r.URL.Path, http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("model") != "whisper-1" || r.FormValue("language") != "de" {
+			http.Error(w, "bad fields", http.StatusBadRequest)
+			return
+		}
+		if _, header, err := r.FormFile("file"); err != nil || header.Filename != "voice.ogg" {
+			http.Error(w, "missing file", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"text": "Hallo Welt", "language": "de"}`))
+	}))
+	defer server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Voice.Transcription = config.TranscriptionConfig{Backend: "openai", APIBase: server.URL + "/v1/", APIKey: "sk-test", Language: "de"}
+	tr, err := NewTranscriber(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := tr.Transcribe(context.Background(), writeAudio(t))
+	if err != nil {
+		t.Fatalf("Transcribe() error: %v", err)
+	}
+	if result.Text != "Hallo Welt" || result.Language != "de" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestWhisperCppTranscriber(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/inference" || r.FormValue("response_format") != "json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"text": " turn on the lights\n"}`))
+	}))
+	defer server.Close()
+
+	result, err := NewWhisperCppTranscriber(server.URL).Transcribe(context.Background(), writeAudio(t))
+	if err != nil {
+		t.Fatalf("Transcribe() error: %v", err)
+	}
+	if result.Text != "turn on the lights" {
+		t.Errorf("Text = %q", result.Text)
+	}
+}
+
+func TestCommandTranscriber(t *testing.T) {
+	audio := writeAudio(t)
+
+	result, err := NewCommandTranscriber([]string{"echo", "heard {file}"}, 0).Transcribe(context.Background(), audio)
+	if err != nil {
+		t.Fatalf("Transcribe() error: %v", err)
+	}
+	if result.Text != "heard "+audio {
+		t.Errorf("Text = %q", result.Text)
+	}
+
+	// Without a placeholder the path is the last argument.
+	result, err = NewCommandTranscriber([]string{"echo", "-n"}, 0).Transcribe(context.Background(), audio)
+	if err != nil || result.Text != audio {
+		t.Errorf("Transcribe() = %+v, %v", result, err)
+	}
+
+	if _, err := NewCommandTranscriber([]string{"false"}, 0).Transcribe(context.Background(), audio); err == nil {
+		t.Error("expected an error from a failing command")
+	}
+}
@@ -0,0 +1,264 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// Synthesizer converts text to speech.
+type Synthesizer interface {
+	Name() string
+	// Synthesize writes speech for text to a temp file. The caller removes
+	// the file.
+	Synthesize(ctx context.Context, text string) (*Speech, error)
+}
+
+// Speech is a synthesized audio file.
+type Speech struct {
+	Path   string
+	Format string // "ogg" (OGG/Opus), "mp3" or "wav"
+}
+
+// Voice reply modes selectable in voice.tts.reply, agents.list[].voice_reply
+// and with /voice.
+const (
+	ReplyOff    = "off"
+	ReplyAuto   = "auto" // answer voice messages with voice
+	ReplyAlways = "always"
+)
+
+// NormalizeReplyMode returns the reply mode for s, or "" if s is not one.
+func NormalizeReplyMode(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case ReplyOff, "false", "no":
+		return ReplyOff
+	case ReplyAuto, "on", "true", "yes":
+		return ReplyAuto
+	case ReplyAlways:
+		return ReplyAlways
+	}
+	return ""
+}
+
+// NewSynthesizer returns the backend selected by voice.tts, or nil when no
+// backend is configured.
+func NewSynthesizer(cfg *config.Config) (Synthesizer, error) {
+	tc := cfg.Voice.TTS
+	timeout := defaultTranscriptionTimeout
+	if tc.TimeoutSeconds > 0 {
+		timeout = time.Duration(tc.TimeoutSeconds) * time.Second
+	}
+
+	switch strings.ToLower(strings.TrimSpace(tc.Backend)) {
+	case "", BackendOff, "none":
+		return nil, nil
+	case BackendOpenAI:
+		apiBase := firstNonEmpty(tc.APIBase, cfg.Providers.OpenAI.APIBase, "https://api.openai.com/v1")
+		apiKey := firstNonEmpty(tc.APIKey, cfg.Providers.OpenAI.APIKey)
+		s := NewOpenAISynthesizer(apiBase, apiKey, firstNonEmpty(tc.Model, "tts-1"), firstNonEmpty(tc.Voice, "alloy"))
+		s.httpClient.Timeout = timeout
+		return s, nil
+	case BackendCommand:
+		if len(tc.Command) == 0 {
+			return nil, fmt.Errorf("voice tts: command backend needs a command")
+		}
+		return NewCommandSynthesizer(tc.Command, timeout), nil
+	default:
+		return nil, fmt.Errorf("voice tts: unknown backend %q", tc.Backend)
+	}
+}
+
+// OpenAISynthesizer calls an OpenAI-compatible /audio/speech endpoint and
+// asks for OGG/Opus, which voice messages need.
+type OpenAISynthesizer struct {
+	apiBase    string
+	apiKey     string
+	model      string
+	voice      string
+	httpClient *http.Client
+}
+
+// NewOpenAISynthesizer creates a synthesizer for the OpenAI-compatible API
+// at apiBase.
+func NewOpenAISynthesizer(apiBase, apiKey, model, voice string) *OpenAISynthesizer {
+	return &OpenAISynthesizer{
+		apiBase: strings.TrimRight(apiBase, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		voice:   voice,
+		httpClient: &http.Client{
+			Timeout: defaultTranscriptionTimeout,
+		},
+	}
+}
+
+func (s *OpenAISynthesizer) Name() string {
+	return BackendOpenAI
+}
+
+func (s *OpenAISynthesizer) Synthesize(ctx context.Context, text string) (*Speech, error) {
+	body, err := json.Marshal(map[string]string{
+		"model":           s.model,
+		"input":           text,
+		"voice":           s.voice,
+		"response_format": "opus",
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.apiBase+"/audio/speech", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(msg))
+	}
+	path := utils.SaveMediaFile(resp.Body, "reply.ogg", "voice")
+	if path == "" {
+		return nil, fmt.Errorf("failed to save speech")
+	}
+	logger.DebugCF("voice", "Speech synthesized", map[string]interface{}{"backend": BackendOpenAI, "chars": len(text)})
+	return &Speech{Path: path, Format: "ogg"}, nil
+}
+
+// CommandSynthesizer runs a local TTS program such as Piper or espeak-ng.
+type CommandSynthesizer struct {
+	argv    []string
+	timeout time.Duration
+}
+
+// NewCommandSynthesizer creates a synthesizer that runs argv. "{output}" is
+// replaced with the path of a WAV file the program must write; without it,
+// the program's standard output is the audio. "{text}" is replaced with the
+// text; without it, the text is written to standard input. For example:
+//
+//	["piper", "--model", "en_US-lessac-medium.onnx", "--output_file", "{output}"]
+//	["espeak-ng", "-w", "{output}", "{text}"]
+func NewCommandSynthesizer(argv []string, timeout time.Duration) *CommandSynthesizer {
+	if timeout <= 0 {
+		timeout = defaultTranscriptionTimeout
+	}
+	return &CommandSynthesizer{argv: argv, timeout: timeout}
+}
+
+func (s *CommandSynthesizer) Name() string {
+	return BackendCommand
+}
+
+func (s *CommandSynthesizer) Synthesize(ctx context.Context, text string) (*Speech, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	output := utils.SaveMediaFile(strings.NewReader(""), "reply.wav", "voice")
+	if output == "" {
+		return nil, fmt.Errorf("failed to create output file")
+	}
+
+	args := make([]string, 0, len(s.argv))
+	toFile, textInArgs := false, false
+	for _, a := range s.argv {
+		if strings.Contains(a, "{output}") {
+			a = strings.ReplaceAll(a, "{output}", output)
+			toFile = true
+		}
+		if strings.Contains(a, "{text}") {
+			a = strings.ReplaceAll(a, "{text}", text)
+			textInArgs = true
+		}
+		args = append(args, a)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if !textInArgs {
+		cmd.Stdin = strings.NewReader(text)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(output)
+		return nil, fmt.Errorf("tts command failed: %w: %s", err, utils.Truncate(strings.TrimSpace(stderr.String()), 200))
+	}
+	if !toFile {
+		if err := os.WriteFile(output, stdout.Bytes(), 0600); err != nil {
+			os.Remove(output)
+			return nil, err
+		}
+	}
+	if info, err := os.Stat(output); err != nil || info.Size() == 0 {
+		os.Remove(output)
+		return nil, fmt.Errorf("tts command produced no audio")
+	}
+	return &Speech{Path: output, Format: "wav"}, nil
+}
+
+// ToOggOpus converts speech to OGG/Opus, the codec Telegram and WhatsApp
+// voice messages require, using ffmpeg. OGG input is returned unchanged;
+// otherwise the input file is removed.
+func ToOggOpus(ctx context.Context, ffmpeg string, speech *Speech) (string, error) {
+	if speech.Format == "ogg" {
+		return speech.Path, nil
+	}
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+	out := strings.TrimSuffix(speech.Path, filepath.Ext(speech.Path)) + ".ogg"
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, "-y", "-loglevel", "error", "-i", speech.Path,
+		"-c:a", "libopus", "-b:a", "32k", "-ac", "1", "-ar", "48000", "-application", "voip", out)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	os.Remove(speech.Path)
+	if err != nil {
+		os.Remove(out)
+		return "", fmt.Errorf("ffmpeg conversion failed: %w: %s", err, utils.Truncate(strings.TrimSpace(stderr.String()), 200))
+	}
+	return out, nil
+}
+
+var (
+	codeFencePattern = regexp.MustCompile("(?s)```.*?```")
+	linkPattern      = regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`)
+	headingPattern   = regexp.MustCompile(`(?m)^\s*#+\s*`)
+	bulletPattern    = regexp.MustCompile(`(?m)^\s*[-*]\s+`)
+	quotePattern     = regexp.MustCompile(`(?m)^\s*>\s?`)
+)
+
+// SpeakableText strips Markdown from a reply so it is not read out: code
+// blocks are dropped, links keep their text, and emphasis, headings,
+// quotes and bullets lose their markers.
+func SpeakableText(s string) string {
+	s = codeFencePattern.ReplaceAllString(s, "")
+	s = linkPattern.ReplaceAllString(s, "$1")
+	s = headingPattern.ReplaceAllString(s, "")
+	s = bulletPattern.ReplaceAllString(s, "")
+	s = quotePattern.ReplaceAllString(s, "")
+	s = strings.NewReplacer("**", "", "__", "", "`", "", "~~", "").Replace(s)
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,151 @@
+package voice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestNormalizeReplyMode(t *testing.T) {
+	tests := map[string]string{
+		"":          "",
+		"auto":      ReplyAuto,
+		" On ":      ReplyAuto,
+		"always":    ReplyAlways,
+		"OFF":       ReplyOff,
+		"false":     ReplyOff,
+		"shout":     "",
+		"sometimes": "",
+	}
+	for in, want := range tests {
+		if got := NormalizeReplyMode(in); got != want {
+			t.Errorf("NormalizeReplyMode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNewSynthesizer_Backends(t *testing.T) {
+	tests := []struct {
+		name    string
+		tts     config.TTSConfig
+		want    string
+		wantErr bool
+	}{
+		{name: "default", want: ""},
+		{name: "off", tts: config.TTSConfig{Backend: "off"}, want: ""},
+		{name: "openai", tts: config.TTSConfig{Backend: "openai", APIKey: "sk-x"}, want: BackendOpenAI},
+		{name: "command", tts: config.TTSConfig{Backend: "command", Command: []string{"espeak-ng", "-w", "{output}", "{text}"}}, want: BackendCommand},
+		{name: "command without argv", tts: config.TTSConfig{Backend: "command"}, wantErr: true},
+		{name: "unknown", tts: config.TTSConfig{Backend: "polly"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg := config.DefaultConfig()
+		cfg.Voice.TTS = tt.tts
+
+		got, err := NewSynthesizer(cfg)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: NewSynthesizer() error: %v", tt.name, err)
+			continue
+		}
+		name := ""
+		if got != nil {
+			name = got.Name()
+		}
+		if name != tt.want {
+			t.Errorf("%s: backend = %q, want %q", tt.name, name, tt.want)
+		}
+	}
+}
+
+func TestOpenAISynthesizer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/speech" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			http.Error(w, "bad request "+r.URL.Path, http.StatusBadRequest)
+			return
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body["input"] != "hello" || body["voice"] != "nova" || body["model"] != "tts-1" || body["response_format"] != "opus" {
+			http.Error(w, "bad fields", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("OggS speech"))
+	}))
+	defer server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Voice.TTS = config.TTSConfig{Backend: "openai", APIBase: server.URL + "/v1", APIKey: "sk-test", Voice: "nova"}
+	s, err := NewSynthesizer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	speech, err := s.Synthesize(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("Synthesize() error: %v", err)
+	}
+	defer os.Remove(speech.Path)
+
+	data, _ := os.ReadFile(speech.Path)
+	if speech.Format != "ogg" || string(data) != "OggS speech" {
+		t.Errorf("speech = %+v, content %q", speech, data)
+	}
+	// OGG output needs no conversion.
+	if path, err := ToOggOpus(context.Background(), "/nonexistent/ffmpeg", speech); err != nil || path != speech.Path {
+		t.Errorf("ToOggOpus() = %q, %v", path, err)
+	}
+}
+
+func TestCommandSynthesizer(t *testing.T) {
+	ctx := context.Background()
+
+	// Text on stdin, audio written to {output}.
+	speech, err := NewCommandSynthesizer([]string{"sh", "-c", `cat > "$0"`, "{output}"}, 0).Synthesize(ctx, "stdin text")
+	if err != nil {
+		t.Fatalf("Synthesize() error: %v", err)
+	}
+	data, _ := os.ReadFile(speech.Path)
+	os.Remove(speech.Path)
+	if speech.Format != "wav" || string(data) != "stdin text" {
+		t.Errorf("speech = %+v, content %q", speech, data)
+	}
+
+	// Text as an argument, audio on stdout.
+	speech, err = NewCommandSynthesizer([]string{"echo", "-n", "{text}"}, 0).Synthesize(ctx, "arg text")
+	if err != nil {
+		t.Fatalf("Synthesize() error: %v", err)
+	}
+	data, _ = os.ReadFile(speech.Path)
+	os.Remove(speech.Path)
+	if string(data) != "arg text" {
+		t.Errorf("content = %q", data)
+	}
+
+	if _, err := NewCommandSynthesizer([]string{"true"}, 0).Synthesize(ctx, "silence"); err == nil {
+		t.Error("expected an error when the command produces no audio")
+	}
+	if _, err := NewCommandSynthesizer([]string{"false"}, 0).Synthesize(ctx, "x"); err == nil {
+		t.Error("expected an error from a failing command")
+	}
+}
+
+func TestSpeakableText(t *testing.T) {
+	in := "# Weather\n> thinking it over\n\nIt is **sunny** in [Berlin](https://example.com).\n- take `sunscreen`\n```go\nfmt.Println()\n```\nBye"
+	want := "Weather\nthinking it over\n\nIt is sunny in Berlin.\ntake sunscreen\n\nBye"
+	if got := SpeakableText(in); got != want {
+		t.Errorf("SpeakableText() = %q, want %q", got, want)
+	}
+}
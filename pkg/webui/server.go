@@ -9,11 +9,14 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/agent"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/costs"
+	"github.com/sipeed/picoclaw/pkg/heartbeat"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/skills"
@@ -50,6 +53,9 @@ type PageData struct {
 	Version      string
 	Uptime       string
 	EnabledCount int
+	Heartbeats   []heartbeat.Outcome
+	CostsToday   *costs.Report
+	CostsMonth   *costs.Report
 
 	// Skills-specific
 	Skills []skills.SkillInfo
@@ -63,10 +69,22 @@ func NewServer(cfg *config.Config, configPath string, host string, port int) *Se
 	tmpl := template.Must(template.ParseFS(templatesFS, "templates/*.html"))
 
 	// Initialize LLM provider for chat
-	provider := providers.NewProvider(cfg)
+	provider, err := providers.CreateProvider(cfg)
+	if err != nil {
+		logger.WarnCF("webui", "LLM provider unavailable, chat disabled", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
 
 	// Create agent instance for webui chat
 	agentInstance := agent.NewAgentInstance(nil, &cfg.Agents.Defaults, cfg, provider)
+	if provider != nil {
+		agentInstance.Provider = costs.NewTracker(cfg.WorkspacePath(), cfg.Costs).Meter(provider, costs.Entry{
+			Agent:    agentInstance.ID,
+			Channel:  "webui",
+			Provider: agentInstance.ProviderName,
+		})
+	}
 
 	s := &Server{
 		config:       cfg,
@@ -99,6 +117,8 @@ func NewServer(cfg *config.Config, configPath string, host string, port int) *Se
 	mux.HandleFunc("/api/skills/remove", s.apiRemoveSkill)
 	mux.HandleFunc("/api/logs/stream", s.apiStreamLogs)
 	mux.HandleFunc("/api/restart", s.apiRestart)
+	mux.HandleFunc("/api/heartbeat/history", s.apiHeartbeatHistory)
+	mux.HandleFunc("/api/costs", s.apiCosts)
 
 	// Chat API endpoints
 	mux.HandleFunc("/api/chat/conversations", s.apiGetConversations)
@@ -170,6 +190,16 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 		LogEntries:   s.logBuffer,
 	}
 
+	if history, err := heartbeat.LoadHistory(s.config.WorkspacePath(), 10); err == nil {
+		data.Heartbeats = history
+	}
+
+	now := time.Now()
+	dayFrom, dayTo := costs.Today(now)
+	data.CostsToday, _ = costs.LoadReport(s.config.WorkspacePath(), dayFrom, dayTo)
+	monthFrom, monthTo := costs.MonthToDate(now)
+	data.CostsMonth, _ = costs.LoadReport(s.config.WorkspacePath(), monthFrom, monthTo)
+
 	if err := s.templates.ExecuteTemplate(w, "layout", data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
@@ -412,6 +442,41 @@ func (s *Server) apiListSkills(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(skillsList)
 }
 
+// API: Recent heartbeat outcomes
+func (s *Server) apiHeartbeatHistory(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+
+	history, err := heartbeat.LoadHistory(s.config.WorkspacePath(), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// API: LLM cost report for the last ?days= days (default 30)
+func (s *Server) apiCosts(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if n, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && n > 0 {
+		days = n
+	}
+
+	from, to := costs.LastDays(time.Now(), days)
+	report, err := costs.LoadReport(s.config.WorkspacePath(), from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
 // API: Install skill
 func (s *Server) apiInstallSkill(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -660,7 +725,7 @@ func (s *Server) apiSendMessage(w http.ResponseWriter, r *http.Request) {
 	sessionKey := "webui-chat" // Single session for web chat
 
 	// Get or create session
-	sess := s.agent.Sessions.GetOrCreate(sessionKey)
+	s.agent.Sessions.GetOrCreate(sessionKey)
 
 	// Convert history to provider messages
 	var history []providers.Message
@@ -679,7 +744,17 @@ func (s *Server) apiSendMessage(w http.ResponseWriter, r *http.Request) {
 	messages := s.agent.ContextBuilder.BuildMessages(history, "", req.Message, nil, "webui", sessionKey)
 
 	// Call LLM
-	response, err := s.agent.Provider.Complete(ctx, messages, s.agent.Model, s.agent.Temperature, s.agent.MaxTokens, nil)
+	if s.agent.Provider == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "error",
+			"error":  "No LLM provider configured",
+		})
+		return
+	}
+	response, err := s.agent.Provider.Chat(ctx, messages, nil, s.agent.Model, map[string]interface{}{
+		"max_tokens":  s.agent.MaxTokens,
+		"temperature": s.agent.Temperature,
+	})
 	if err != nil {
 		logger.ErrorCF("webui", "Chat completion error", map[string]interface{}{
 			"error": err.Error(),
@@ -692,8 +767,9 @@ func (s *Server) apiSendMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update session history
-	sess.AddMessage(providers.Message{Role: "user", Content: req.Message})
-	sess.AddMessage(providers.Message{Role: "assistant", Content: response.Content})
+	s.agent.Sessions.AddMessage(sessionKey, "user", req.Message)
+	s.agent.Sessions.AddMessage(sessionKey, "assistant", response.Content)
+	s.agent.Sessions.Save(sessionKey)
 
 	logger.InfoCF("webui", "Chat response generated", map[string]interface{}{
 		"length": len(response.Content),